@@ -0,0 +1,239 @@
+// Package anthropic is a minimal client for the Anthropic Messages API,
+// covering what MANFRED needs to run Claude without a container: text and
+// tool use conversations.
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBaseURL = "https://api.anthropic.com"
+	apiVersion     = "2023-06-01"
+)
+
+// Client calls the Messages API.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+
+	maxRetries int
+	retryBase  time.Duration
+}
+
+// ClientOption configures a Client.
+type ClientOption func(*Client)
+
+// WithBaseURL sets a custom API endpoint, e.g. a proxy.
+func WithBaseURL(url string) ClientOption {
+	return func(c *Client) {
+		if url != "" {
+			c.baseURL = strings.TrimSuffix(url, "/")
+		}
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for requests.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// NewClient creates a client authenticating with apiKey.
+func NewClient(apiKey string, opts ...ClientOption) *Client {
+	c := &Client{
+		baseURL:    defaultBaseURL,
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 10 * time.Minute},
+		maxRetries: 3,
+		retryBase:  2 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Message is one turn of a conversation.
+type Message struct {
+	Role    string         `json:"role"` // user or assistant
+	Content []ContentBlock `json:"content"`
+}
+
+// ContentBlock is a text, tool_use or tool_result block.
+type ContentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
+}
+
+// TextBlock returns a text content block.
+func TextBlock(text string) ContentBlock {
+	return ContentBlock{Type: "text", Text: text}
+}
+
+// ToolResultBlock returns the result of a tool_use block.
+func ToolResultBlock(toolUseID, content string, isError bool) ContentBlock {
+	return ContentBlock{Type: "tool_result", ToolUseID: toolUseID, Content: content, IsError: isError}
+}
+
+// Tool describes a tool Claude may call.
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// ToolChoice restricts tool use; Type is auto, any, tool or none.
+type ToolChoice struct {
+	Type string `json:"type"`
+}
+
+// Request is a Messages API request.
+type Request struct {
+	Model      string      `json:"model"`
+	MaxTokens  int         `json:"max_tokens"`
+	System     string      `json:"system,omitempty"`
+	Messages   []Message   `json:"messages"`
+	Tools      []Tool      `json:"tools,omitempty"`
+	ToolChoice *ToolChoice `json:"tool_choice,omitempty"`
+}
+
+// Response is a Messages API response.
+type Response struct {
+	ID         string         `json:"id"`
+	Model      string         `json:"model"`
+	Content    []ContentBlock `json:"content"`
+	StopReason string         `json:"stop_reason"` // end_turn, max_tokens, tool_use, ...
+	Usage      Usage          `json:"usage"`
+}
+
+// Text returns the concatenated text blocks of the response.
+func (r *Response) Text() string {
+	var parts []string
+	for _, block := range r.Content {
+		if block.Type == "text" {
+			parts = append(parts, block.Text)
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// Usage reports the tokens a request consumed.
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// APIError is an error response from the API.
+type APIError struct {
+	StatusCode int
+	Type       string // e.g. rate_limit_error, overloaded_error
+	Message    string
+	RetryAfter time.Duration // From the retry-after header, if present
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("anthropic API: %s (%d): %s", e.Type, e.StatusCode, e.Message)
+}
+
+// retryable reports whether the request may succeed when repeated.
+func (e *APIError) retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// CreateMessage sends a request, retrying rate limited, overloaded and
+// server error responses with exponential backoff.
+func (c *Client) CreateMessage(ctx context.Context, req *Request) (*Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.do(ctx, body)
+		apiErr, ok := err.(*APIError)
+		if err == nil || !ok || !apiErr.retryable() || attempt >= c.maxRetries {
+			return resp, err
+		}
+
+		wait := c.retryBase << attempt
+		if apiErr.RetryAfter > 0 {
+			wait = apiErr.RetryAfter
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *Client) do(ctx context.Context, body []byte) (*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", c.apiKey)
+	req.Header.Set("Anthropic-Version", apiVersion)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call anthropic API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, data)
+	}
+
+	var result Response
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &result, nil
+}
+
+// newAPIError builds the error of a non-200 response with body data.
+func newAPIError(resp *http.Response, data []byte) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	var payload struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &payload) == nil && payload.Error.Message != "" {
+		apiErr.Type = payload.Error.Type
+		apiErr.Message = payload.Error.Message
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
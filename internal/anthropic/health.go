@@ -0,0 +1,125 @@
+package anthropic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Ping checks that the API is reachable by listing one model, which costs
+// no tokens. With an API key, an invalid key fails the ping; without one,
+// the expected authentication error counts as reachable.
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/v1/models?limit=1", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-Api-Key", c.apiKey)
+	}
+	req.Header.Set("Anthropic-Version", apiVersion)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach anthropic API: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode == http.StatusOK || (c.apiKey == "" && resp.StatusCode == http.StatusUnauthorized) {
+		return nil
+	}
+	return newAPIError(resp, data)
+}
+
+// Breaker is a circuit breaker around a health probe such as Ping. While
+// the probe fails the breaker is open: Check fails fast with the last error
+// and the probe runs again at most every interval. A successful probe
+// closes the breaker and is trusted for interval as well. It is safe for
+// concurrent use.
+type Breaker struct {
+	probe    func(context.Context) error
+	interval time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	checked time.Time // Time of the last probe
+	err     error     // Result of the last probe; non-nil while open
+}
+
+// NewBreaker creates a closed breaker that has not probed yet.
+func NewBreaker(probe func(context.Context) error, interval time.Duration) *Breaker {
+	return &Breaker{probe: probe, interval: interval, now: time.Now}
+}
+
+// UnavailableError is returned by Check while the breaker is open.
+type UnavailableError struct {
+	Err   error     // The last probe's error
+	Since time.Time // When the breaker opened
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("anthropic API unavailable since %s: %v", e.Since.Format(time.RFC3339), e.Err)
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// Check returns nil if the API is healthy, probing it unless the last probe
+// is more recent than the interval, and an *UnavailableError otherwise.
+func (b *Breaker) Check(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.checked.IsZero() || b.now().Sub(b.checked) >= b.interval {
+		wasOpen := b.err != nil
+		err := b.probe(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		b.checked = b.now()
+		switch {
+		case err != nil && !wasOpen:
+			log.Printf("anthropic: circuit open: %v", err)
+			b.err = &UnavailableError{Err: err, Since: b.checked}
+		case err != nil:
+			b.err.(*UnavailableError).Err = err
+		case wasOpen:
+			log.Printf("anthropic: circuit closed, API available again")
+			b.err = nil
+		}
+	}
+	if b.err != nil {
+		open := *b.err.(*UnavailableError)
+		return &open
+	}
+	return nil
+}
+
+// Wait blocks while the breaker is open, probing every interval, until the
+// API is healthy again or ctx is done.
+func (b *Breaker) Wait(ctx context.Context) error {
+	for {
+		err := b.Check(ctx)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(b.retryIn()):
+		}
+	}
+}
+
+// retryIn returns the time until the next probe is due.
+func (b *Breaker) retryIn() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return max(b.interval-b.now().Sub(b.checked), time.Second)
+}
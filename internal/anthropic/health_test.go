@@ -0,0 +1,86 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPing(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			t.Errorf("path = %s, want /v1/models", r.URL.Path)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	if err := NewClient("key", WithBaseURL(server.URL)).Ping(ctx); err != nil {
+		t.Errorf("Ping() = %v, want nil", err)
+	}
+
+	status = http.StatusUnauthorized
+	var apiErr *APIError
+	if err := NewClient("bad", WithBaseURL(server.URL)).Ping(ctx); !errors.As(err, &apiErr) || apiErr.Type != "authentication_error" {
+		t.Errorf("Ping() with a bad key = %v, want authentication_error", err)
+	}
+	if err := NewClient("", WithBaseURL(server.URL)).Ping(ctx); err != nil {
+		t.Errorf("Ping() without a key = %v, want nil for a reachable API", err)
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := NewClient("", WithBaseURL(server.URL)).Ping(ctx); err == nil {
+		t.Error("Ping() = nil for a 503 response")
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	probeErr := errors.New("connection refused")
+	var probes int
+	var failing bool
+	b := NewBreaker(func(context.Context) error {
+		probes++
+		if failing {
+			return probeErr
+		}
+		return nil
+	}, time.Minute)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if err := b.Check(ctx); err != nil || probes != 1 {
+		t.Fatalf("Check() = %v after %d probes, want nil after 1", err, probes)
+	}
+	failing = true
+	if err := b.Check(ctx); err != nil || probes != 1 {
+		t.Errorf("Check() within the interval = %v after %d probes, want cached nil", err, probes)
+	}
+
+	now = now.Add(time.Minute)
+	var unavailable *UnavailableError
+	if err := b.Check(ctx); !errors.As(err, &unavailable) || !errors.Is(err, probeErr) || probes != 2 {
+		t.Fatalf("Check() = %v after %d probes, want UnavailableError after 2", err, probes)
+	}
+	opened := unavailable.Since
+	if err := b.Check(ctx); err == nil || probes != 2 {
+		t.Errorf("Check() while open = %v after %d probes, want fast failure", err, probes)
+	}
+
+	now = now.Add(time.Minute)
+	if err := b.Check(ctx); !errors.As(err, &unavailable) || !unavailable.Since.Equal(opened) || probes != 3 {
+		t.Errorf("Check() = %v, want the breaker open since %s", err, opened)
+	}
+
+	failing = false
+	now = now.Add(time.Minute)
+	if err := b.Wait(ctx); err != nil || probes != 4 {
+		t.Errorf("Wait() = %v after %d probes, want nil after 4", err, probes)
+	}
+}
@@ -0,0 +1,87 @@
+// Package apikey manages the API keys of the REST API. Each key has a scope
+// limiting what its holder may do, so a dashboard can read state without
+// being able to start jobs or delete sessions.
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Scope is what a key may do. Each scope includes the ones before it.
+type Scope string
+
+const (
+	ScopeRead     Scope = "read"     // Read sessions, jobs, artifacts and queue metrics
+	ScopeOperator Scope = "operator" // Also approve, abort and delete sessions
+	ScopeAdmin    Scope = "admin"    // Also manage API keys
+)
+
+// scopeRanks orders the scopes.
+var scopeRanks = map[Scope]int{ScopeRead: 1, ScopeOperator: 2, ScopeAdmin: 3}
+
+// ParseScope validates a scope name.
+func ParseScope(s string) (Scope, error) {
+	scope := Scope(s)
+	if _, ok := scopeRanks[scope]; !ok {
+		return "", fmt.Errorf("invalid scope %q (want read, operator or admin)", s)
+	}
+	return scope, nil
+}
+
+// Allows reports whether s includes required.
+func (s Scope) Allows(required Scope) bool {
+	rank, ok := scopeRanks[s]
+	return ok && rank >= scopeRanks[required]
+}
+
+// tokenPrefix starts every key, so leaked keys are recognizable.
+const tokenPrefix = "mfd_"
+
+// Key is an API key. The token itself is only known when the key is
+// created; the store keeps its SHA-256 hash.
+type Key struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Scope      Scope      `json:"scope"`
+	Prefix     string     `json:"prefix"` // First characters of the token, to tell keys apart
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Revoked reports whether the key was revoked.
+func (k *Key) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// generateToken returns a new random token.
+func generateToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate API key: %w", err)
+	}
+	return tokenPrefix + hex.EncodeToString(b), nil
+}
+
+// hashToken returns the hash the store keeps of token. Tokens are random,
+// so a plain SHA-256 suffices.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenDisplayPrefix returns the part of token shown in key listings.
+func tokenDisplayPrefix(token string) string {
+	return token[:len(tokenPrefix)+8]
+}
+
+// IsToken reports whether s looks like an API key, as opposed to the static
+// server.auth token.
+func IsToken(s string) bool {
+	return strings.HasPrefix(s, tokenPrefix)
+}
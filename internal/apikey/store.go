@@ -0,0 +1,154 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mpm/manfred/internal/store"
+)
+
+// Store defines the interface for API key persistence.
+type Store interface {
+	// Create creates a key and returns it with its token, which is not
+	// stored and cannot be retrieved later.
+	Create(ctx context.Context, name string, scope Scope) (*Key, string, error)
+
+	// Authenticate returns the active key of token, or nil if there is none,
+	// and records its use.
+	Authenticate(ctx context.Context, token string) (*Key, error)
+
+	// List returns all keys, revoked ones included.
+	List(ctx context.Context) ([]Key, error)
+
+	// Revoke revokes a key by ID.
+	Revoke(ctx context.Context, id int64) error
+
+	// CountActive returns the number of keys not revoked.
+	CountActive(ctx context.Context) (int, error)
+}
+
+// SQLStore implements Store on the SQLite or Postgres database db.
+type SQLStore struct {
+	db *store.DB
+}
+
+// NewSQLStore creates a new database-backed API key store.
+func NewSQLStore(db *store.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// Create creates a key and returns it with its token.
+func (s *SQLStore) Create(ctx context.Context, name string, scope Scope) (*Key, string, error) {
+	if name == "" {
+		return nil, "", fmt.Errorf("API key name is required")
+	}
+	if _, err := ParseScope(string(scope)); err != nil {
+		return nil, "", err
+	}
+	token, err := generateToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &Key{
+		Name:      name,
+		Scope:     scope,
+		Prefix:    tokenDisplayPrefix(token),
+		CreatedAt: time.Now().UTC(),
+	}
+	err = s.db.QueryRowContext(ctx,
+		`INSERT INTO api_keys (name, scope, prefix, token_hash, created_at) VALUES (?, ?, ?, ?, ?) RETURNING id`,
+		key.Name, string(key.Scope), key.Prefix, hashToken(token), key.CreatedAt).Scan(&key.ID)
+	if err != nil {
+		return nil, "", fmt.Errorf("create API key: %w", err)
+	}
+	return key, token, nil
+}
+
+// Authenticate returns the active key of token and records its use.
+func (s *SQLStore) Authenticate(ctx context.Context, token string) (*Key, error) {
+	query := `
+		SELECT id, name, scope, prefix, created_at, last_used_at, revoked_at
+		FROM api_keys
+		WHERE token_hash = ? AND revoked_at IS NULL
+	`
+	key, err := scanKey(s.db.QueryRowContext(ctx, query, hashToken(token)))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get API key: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if _, err := s.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = ? WHERE id = ?`, now, key.ID); err != nil {
+		return nil, fmt.Errorf("record API key use: %w", err)
+	}
+	key.LastUsedAt = &now
+	return key, nil
+}
+
+// List returns all keys, oldest first.
+func (s *SQLStore) List(ctx context.Context) ([]Key, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, name, scope, prefix, created_at, last_used_at, revoked_at
+		FROM api_keys
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []Key
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan API key: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Revoke revokes a key by ID. Revoking a revoked key is an error.
+func (s *SQLStore) Revoke(ctx context.Context, id int64) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("revoke API key: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("no active API key with ID %d", id)
+	}
+	return nil
+}
+
+// CountActive returns the number of keys not revoked.
+func (s *SQLStore) CountActive(ctx context.Context) (int, error) {
+	var count int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM api_keys WHERE revoked_at IS NULL`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count API keys: %w", err)
+	}
+	return count, nil
+}
+
+// scanKey scans the columns selected by the queries above.
+func scanKey(row interface{ Scan(...any) error }) (*Key, error) {
+	key := &Key{}
+	var scope string
+	if err := row.Scan(&key.ID, &key.Name, &scope, &key.Prefix, &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt); err != nil {
+		return nil, err
+	}
+	key.Scope = Scope(scope)
+	return key, nil
+}
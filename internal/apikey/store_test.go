@@ -0,0 +1,82 @@
+package apikey
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mpm/manfred/internal/store"
+)
+
+func TestScope(t *testing.T) {
+	tests := []struct {
+		scope, required Scope
+		want            bool
+	}{
+		{ScopeRead, ScopeRead, true},
+		{ScopeRead, ScopeOperator, false},
+		{ScopeOperator, ScopeRead, true},
+		{ScopeOperator, ScopeAdmin, false},
+		{ScopeAdmin, ScopeOperator, true},
+		{Scope("root"), ScopeRead, false},
+	}
+	for _, tt := range tests {
+		if got := tt.scope.Allows(tt.required); got != tt.want {
+			t.Errorf("%s.Allows(%s) = %v, want %v", tt.scope, tt.required, got, tt.want)
+		}
+	}
+
+	if _, err := ParseScope("read-write"); err == nil {
+		t.Error("ParseScope(read-write) succeeded, want error")
+	}
+}
+
+func TestSQLStore(t *testing.T) {
+	db, err := store.OpenInMemory()
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	keys := NewSQLStore(db)
+
+	key, token, err := keys.Create(ctx, "dashboard", ScopeRead)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if !IsToken(token) || !strings.HasPrefix(token, key.Prefix) {
+		t.Errorf("Create() token = %q, prefix = %q", token, key.Prefix)
+	}
+
+	got, err := keys.Authenticate(ctx, token)
+	if err != nil || got == nil || got.ID != key.ID || got.Scope != ScopeRead || got.LastUsedAt == nil {
+		t.Fatalf("Authenticate() = %+v, %v, want the dashboard key", got, err)
+	}
+	if got, err := keys.Authenticate(ctx, token+"x"); err != nil || got != nil {
+		t.Errorf("Authenticate(wrong token) = %+v, %v, want nil", got, err)
+	}
+	if n, err := keys.CountActive(ctx); err != nil || n != 1 {
+		t.Errorf("CountActive() = %d, %v, want 1", n, err)
+	}
+
+	if err := keys.Revoke(ctx, key.ID); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if err := keys.Revoke(ctx, key.ID); err == nil {
+		t.Error("Revoke() of a revoked key succeeded, want error")
+	}
+	if got, err := keys.Authenticate(ctx, token); err != nil || got != nil {
+		t.Errorf("Authenticate(revoked) = %+v, %v, want nil", got, err)
+	}
+	list, err := keys.List(ctx)
+	if err != nil || len(list) != 1 || !list[0].Revoked() || list[0].LastUsedAt == nil {
+		t.Errorf("List() = %+v, %v, want the revoked key", list, err)
+	}
+
+	if _, _, err := keys.Create(ctx, "ci", Scope("root")); err == nil {
+		t.Error("Create() with an invalid scope succeeded, want error")
+	}
+}
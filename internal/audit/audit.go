@@ -0,0 +1,119 @@
+// Package audit records who performed the state-changing actions of an
+// agent that pushes code: approvals, aborts, deletions, phase transitions
+// and API key changes, with the CLI user, API key or GitHub user behind
+// each.
+package audit
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/user"
+	"time"
+)
+
+// ActorType is the kind of actor behind an action.
+type ActorType string
+
+const (
+	ActorCLI    ActorType = "cli"     // A manfred command, by OS user
+	ActorAPIKey ActorType = "api_key" // A REST API request, by key name
+	ActorHTTP   ActorType = "http"    // A REST API request without a key: "server.auth" or "anonymous"
+	ActorGitHub ActorType = "github"  // A webhook or polled event, by sender login
+	ActorSystem ActorType = "system"  // MANFRED itself (jobs finishing, reaper, resume)
+)
+
+// Actor is who performed an action.
+type Actor struct {
+	Type ActorType `json:"type"`
+	Name string    `json:"name,omitempty"`
+}
+
+// String describes the actor, e.g. "github:octocat".
+func (a Actor) String() string {
+	if a.Name == "" {
+		return string(a.Type)
+	}
+	return string(a.Type) + ":" + a.Name
+}
+
+// Actions recorded in the audit log.
+const (
+	ActionSessionApprove     = "session.approve"
+	ActionSessionAbort       = "session.abort"
+	ActionSessionRetry       = "session.retry"
+	ActionSessionDelete      = "session.delete"
+	ActionSessionArchive     = "session.archive"
+	ActionSessionEditPlan    = "session.edit_plan"
+	ActionSessionMerge       = "session.merge"
+	ActionSessionPhaseChange = "session.phase_change"
+	ActionAPIKeyCreate       = "api_key.create"
+	ActionAPIKeyRevoke       = "api_key.revoke"
+)
+
+type actorKey struct{}
+
+// WithActor returns a context whose actions are attributed to actor.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor of ctx, or the system actor if none was set.
+func ActorFrom(ctx context.Context) Actor {
+	if actor, ok := ctx.Value(actorKey{}).(Actor); ok {
+		return actor
+	}
+	return Actor{Type: ActorSystem}
+}
+
+// CLIActor returns the actor of manfred commands: the OS user running them.
+func CLIActor() Actor {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	return Actor{Type: ActorCLI, Name: name}
+}
+
+// Entry is an audit log entry.
+type Entry struct {
+	ID        int64             `json:"id"`
+	CreatedAt time.Time         `json:"created_at"`
+	Actor     Actor             `json:"actor"`
+	Action    string            `json:"action"`
+	Target    string            `json:"target"` // Session ID or API key ID
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// Log records actions in a store. A nil Log records nothing.
+type Log struct {
+	store Store
+}
+
+// NewLog creates a log recording into store.
+func NewLog(store Store) *Log {
+	return &Log{store: store}
+}
+
+// Record records action on target by the actor of ctx. Failures are logged
+// rather than returned, so auditing never blocks the action itself.
+func (l *Log) Record(ctx context.Context, action, target string, details map[string]string) {
+	if l == nil {
+		return
+	}
+	entry := &Entry{
+		CreatedAt: time.Now().UTC(),
+		Actor:     ActorFrom(ctx),
+		Action:    action,
+		Target:    target,
+		Details:   details,
+	}
+	if err := l.store.Record(context.WithoutCancel(ctx), entry); err != nil {
+		log.Printf("audit: failed to record %s on %s by %s: %v", action, target, entry.Actor, err)
+	}
+}
+
+// List returns the entries matching filter, newest first.
+func (l *Log) List(ctx context.Context, filter Filter) ([]Entry, error) {
+	return l.store.List(ctx, filter)
+}
@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mpm/manfred/internal/store"
+)
+
+func TestLog(t *testing.T) {
+	db, err := store.OpenInMemory()
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	l := NewLog(NewSQLStore(db))
+
+	if got := ActorFrom(ctx); got.Type != ActorSystem {
+		t.Errorf("ActorFrom(background) = %v, want system", got)
+	}
+	octocat := WithActor(ctx, Actor{Type: ActorGitHub, Name: "octocat"})
+	l.Record(ctx, ActionSessionPhaseChange, "acme-widgets-issue-1", map[string]string{"from": "planning", "to": "error"})
+	l.Record(octocat, ActionSessionApprove, "acme-widgets-issue-1", nil)
+	l.Record(WithActor(ctx, Actor{Type: ActorCLI, Name: "ops"}), ActionSessionDelete, "acme-widgets-issue-2", nil)
+
+	entries, err := l.List(ctx, Filter{})
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("List() = %d entries, %v, want 3", len(entries), err)
+	}
+	if entries[0].Action != ActionSessionDelete || entries[0].Actor.String() != "cli:ops" {
+		t.Errorf("newest entry = %+v, want the deletion by cli:ops", entries[0])
+	}
+	if details := entries[2].Details; details["to"] != "error" || entries[2].Actor.String() != "system" {
+		t.Errorf("oldest entry = %+v, want the system phase change", entries[2])
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   int
+	}{
+		{"target", Filter{Target: "acme-widgets-issue-1"}, 2},
+		{"actor", Filter{ActorType: ActorGitHub, Actor: "octocat"}, 1},
+		{"action", Filter{Action: ActionSessionApprove}, 1},
+		{"since", Filter{Since: time.Now().Add(time.Hour)}, 0},
+		{"limit", Filter{Limit: 2}, 2},
+	}
+	for _, tt := range tests {
+		entries, err := l.List(ctx, tt.filter)
+		if err != nil || len(entries) != tt.want {
+			t.Errorf("%s: List() = %d entries, %v, want %d", tt.name, len(entries), err, tt.want)
+		}
+	}
+
+	// A nil log records nothing
+	var nilLog *Log
+	nilLog.Record(ctx, ActionSessionAbort, "acme-widgets-issue-1", nil)
+}
@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mpm/manfred/internal/store"
+)
+
+// Filter selects audit log entries.
+type Filter struct {
+	Action    string    // Exact action, e.g. session.abort
+	Target    string    // Exact target, e.g. a session ID
+	ActorType ActorType // Kind of actor
+	Actor     string    // Actor name
+	Since     time.Time // Entries at or after this time
+	Limit     int       // At most this many entries
+}
+
+// Store defines the interface for audit log persistence.
+type Store interface {
+	// Record appends an entry, setting its ID.
+	Record(ctx context.Context, entry *Entry) error
+
+	// List returns the entries matching filter, newest first.
+	List(ctx context.Context, filter Filter) ([]Entry, error)
+}
+
+// SQLStore implements Store on the SQLite or Postgres database db.
+type SQLStore struct {
+	db *store.DB
+}
+
+// NewSQLStore creates a new database-backed audit log store.
+func NewSQLStore(db *store.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// Record appends an entry.
+func (s *SQLStore) Record(ctx context.Context, entry *Entry) error {
+	var details sql.NullString
+	if len(entry.Details) > 0 {
+		data, err := json.Marshal(entry.Details)
+		if err != nil {
+			return fmt.Errorf("marshal audit details: %w", err)
+		}
+		details = sql.NullString{String: string(data), Valid: true}
+	}
+
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO audit_log (created_at, actor_type, actor, action, target, details)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, entry.CreatedAt, string(entry.Actor.Type), entry.Actor.Name, entry.Action, entry.Target, details).Scan(&entry.ID)
+	if err != nil {
+		return fmt.Errorf("record audit entry: %w", err)
+	}
+	return nil
+}
+
+// List returns the entries matching filter, newest first.
+func (s *SQLStore) List(ctx context.Context, filter Filter) ([]Entry, error) {
+	var conditions []string
+	var args []interface{}
+	for _, c := range []struct {
+		column, value string
+	}{
+		{"action", filter.Action},
+		{"target", filter.Target},
+		{"actor_type", string(filter.ActorType)},
+		{"actor", filter.Actor},
+	} {
+		if c.value != "" {
+			conditions = append(conditions, c.column+" = ?")
+			args = append(args, c.value)
+		}
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+
+	query := `SELECT id, created_at, actor_type, actor, action, target, details FROM audit_log`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var entry Entry
+		var actorType string
+		var details sql.NullString
+		if err := rows.Scan(&entry.ID, &entry.CreatedAt, &actorType, &entry.Actor.Name, &entry.Action, &entry.Target, &details); err != nil {
+			return nil, fmt.Errorf("scan audit entry: %w", err)
+		}
+		entry.Actor.Type = ActorType(actorType)
+		if details.Valid {
+			if err := json.Unmarshal([]byte(details.String), &entry.Details); err != nil {
+				return nil, fmt.Errorf("unmarshal audit details: %w", err)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate audit entries: %w", err)
+	}
+	return entries, nil
+}
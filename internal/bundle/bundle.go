@@ -0,0 +1,401 @@
+// Package bundle manages installed versions of the portable Claude Code
+// bundle (Node.js plus Claude Code, built with `make bundle`). Each version
+// is a directory under the bundles directory, mounted read-only into job
+// containers; a <version>.json next to it records where it came from.
+package bundle
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Binary is the Claude executable at the top of a bundle.
+const Binary = "claude"
+
+// Bundle is an installed bundle version.
+type Bundle struct {
+	Version   string    `json:"version"`
+	Path      string    `json:"-"`
+	Source    string    `json:"source"` // URL or file it was installed from
+	SHA256    string    `json:"sha256"` // Of the tarball
+	Installed time.Time `json:"installed"`
+}
+
+// Manager installs, lists and removes bundle versions in a directory.
+type Manager struct {
+	dir    string
+	client *http.Client
+}
+
+// New returns a manager for the bundles in dir.
+func New(dir string) *Manager {
+	return &Manager{dir: dir, client: &http.Client{Timeout: 10 * time.Minute}}
+}
+
+// validVersion matches version names, which become directory names.
+var validVersion = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+
+// ValidVersion reports whether version can name an installed bundle.
+func ValidVersion(version string) bool {
+	return validVersion.MatchString(version) && !strings.Contains(version, "..")
+}
+
+// URL expands a download URL template: {version} becomes version and
+// {arch} the architecture of this host, like amd64. Bundles run in Linux
+// containers, so {os} is always linux.
+func URL(template, version string) string {
+	return strings.NewReplacer("{version}", version, "{os}", "linux", "{arch}", runtime.GOARCH).Replace(template)
+}
+
+// InstallOptions say where to install a version from.
+type InstallOptions struct {
+	// Source is an http(s) URL or a local path of a .tar.gz bundle.
+	Source string
+	// SHA256 is the expected checksum of the tarball. Without it, a download
+	// is checked against <Source>.sha256; a local file is trusted.
+	SHA256 string
+	// Force replaces an installed version.
+	Force bool
+}
+
+// Install downloads or reads the tarball, verifies its checksum and unpacks
+// it as version. A single top-level directory in the tarball, like
+// claude-bundle-linux-amd64/, is stripped.
+func (m *Manager) Install(ctx context.Context, version string, opts InstallOptions) (*Bundle, error) {
+	if !ValidVersion(version) {
+		return nil, fmt.Errorf("invalid bundle version %q", version)
+	}
+	dest := filepath.Join(m.dir, version)
+	if _, err := os.Stat(dest); err == nil && !opts.Force {
+		return nil, fmt.Errorf("bundle %s is already installed (use --force to replace it)", version)
+	}
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create bundles directory: %w", err)
+	}
+
+	tarball, sum, err := m.fetch(ctx, opts.Source)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tarball)
+
+	want := strings.ToLower(strings.TrimSpace(opts.SHA256))
+	if want == "" && remote(opts.Source) {
+		if want, err = m.fetchChecksum(ctx, opts.Source+".sha256"); err != nil {
+			return nil, fmt.Errorf("%w; pass the checksum with --sha256", err)
+		}
+	}
+	if want != "" && want != sum {
+		return nil, fmt.Errorf("checksum mismatch for %s: got sha256 %s, want %s", opts.Source, sum, want)
+	}
+
+	tmp, err := os.MkdirTemp(m.dir, ".install-"+version+"-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create install directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	if err := extract(tarball, tmp); err != nil {
+		return nil, fmt.Errorf("failed to unpack bundle: %w", err)
+	}
+	root, err := bundleRoot(tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.RemoveAll(dest); err != nil {
+		return nil, fmt.Errorf("failed to replace bundle %s: %w", version, err)
+	}
+	if err := os.Rename(root, dest); err != nil {
+		return nil, fmt.Errorf("failed to install bundle %s: %w", version, err)
+	}
+
+	b := &Bundle{Version: version, Path: dest, Source: opts.Source, SHA256: sum, Installed: time.Now().UTC()}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(dest+".json", append(data, '\n'), 0644); err != nil {
+		return nil, fmt.Errorf("failed to record bundle %s: %w", version, err)
+	}
+	return b, nil
+}
+
+// List returns the installed versions, oldest version first.
+func (m *Manager) List() ([]Bundle, error) {
+	entries, err := os.ReadDir(m.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundles directory: %w", err)
+	}
+
+	var bundles []Bundle
+	for _, e := range entries {
+		if !e.IsDir() || !ValidVersion(e.Name()) {
+			continue
+		}
+		b := Bundle{Version: e.Name()}
+		if data, err := os.ReadFile(filepath.Join(m.dir, e.Name()+".json")); err == nil {
+			json.Unmarshal(data, &b)
+		}
+		b.Version, b.Path = e.Name(), filepath.Join(m.dir, e.Name())
+		bundles = append(bundles, b)
+	}
+	sort.Slice(bundles, func(i, j int) bool {
+		return compareVersions(bundles[i].Version, bundles[j].Version) < 0
+	})
+	return bundles, nil
+}
+
+// Remove deletes an installed version.
+func (m *Manager) Remove(version string) error {
+	if !ValidVersion(version) {
+		return fmt.Errorf("invalid bundle version %q", version)
+	}
+	dir := filepath.Join(m.dir, version)
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("bundle %s is not installed", version)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove bundle %s: %w", version, err)
+	}
+	os.Remove(dir + ".json")
+	return nil
+}
+
+// remote reports whether source is a URL rather than a local path.
+func remote(source string) bool {
+	return strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://")
+}
+
+// fetch copies source into a temporary file and returns its path and
+// SHA-256 checksum.
+func (m *Manager) fetch(ctx context.Context, source string) (string, string, error) {
+	var r io.ReadCloser
+	if remote(source) {
+		body, err := m.get(ctx, source)
+		if err != nil {
+			return "", "", err
+		}
+		r = body
+	} else {
+		f, err := os.Open(source)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to open bundle: %w", err)
+		}
+		r = f
+	}
+	defer r.Close()
+
+	tmp, err := os.CreateTemp(m.dir, ".download-*.tar.gz")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create download file: %w", err)
+	}
+	defer tmp.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), r); err != nil {
+		os.Remove(tmp.Name())
+		return "", "", fmt.Errorf("failed to download %s: %w", source, err)
+	}
+	return tmp.Name(), hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// fetchChecksum reads a sha256sum-style checksum file.
+func (m *Manager) fetchChecksum(ctx context.Context, url string) (string, error) {
+	body, err := m.get(ctx, url)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch checksum: %w", err)
+	}
+	defer body.Close()
+	line, err := bufio.NewReader(io.LimitReader(body, 4096)).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read checksum: %w", err)
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum file %s", url)
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+// get starts a GET request and returns the body of a 200 response.
+func (m *Manager) get(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// extract unpacks a .tar.gz into dir, rejecting entries that would land
+// outside it. Files are written through an os.Root, so neither ".." nor
+// symlinks unpacked earlier lead out of dir, and every symlink must resolve
+// inside dir once all entries are unpacked.
+func extract(tarball, dir string) error {
+	f, err := os.Open(tarball)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	var links []string
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Clean(hdr.Name)
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("unsafe path %s in bundle", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := mkdirAll(root, name); err != nil {
+				return fmt.Errorf("unsafe path %s in bundle: %w", hdr.Name, err)
+			}
+		case tar.TypeReg:
+			if err := mkdirAll(root, filepath.Dir(name)); err != nil {
+				return fmt.Errorf("unsafe path %s in bundle: %w", hdr.Name, err)
+			}
+			out, err := root.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fs.FileMode(hdr.Mode)&0755|0644)
+			if err != nil {
+				return fmt.Errorf("unsafe path %s in bundle: %w", hdr.Name, err)
+			}
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			target := filepath.Join(filepath.Dir(name), hdr.Linkname)
+			if filepath.IsAbs(hdr.Linkname) || target == ".." || strings.HasPrefix(target, ".."+string(filepath.Separator)) {
+				return fmt.Errorf("unsafe link %s -> %s in bundle", hdr.Name, hdr.Linkname)
+			}
+			if err := mkdirAll(root, filepath.Dir(name)); err != nil {
+				return fmt.Errorf("unsafe path %s in bundle: %w", hdr.Name, err)
+			}
+			// os.Root cannot create symlinks before Go 1.25; the parent
+			// was just resolved inside dir
+			if err := os.Symlink(hdr.Linkname, filepath.Join(dir, name)); err != nil {
+				return err
+			}
+			links = append(links, name)
+		}
+	}
+
+	// A link can lead out of dir through links unpacked after it, such as
+	// l2 -> l1/.. before l1 -> . Dangling links are left alone.
+	for _, name := range links {
+		if _, err := root.Stat(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("unsafe link %s in bundle: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// mkdirAll creates directory name and its parents inside root. Existing
+// entries must be directories or symlinks to directories inside root.
+func mkdirAll(root *os.Root, name string) error {
+	if name == "." {
+		return nil
+	}
+	if info, err := root.Stat(name); err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", name)
+		}
+		return nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := mkdirAll(root, filepath.Dir(name)); err != nil {
+		return err
+	}
+	return root.Mkdir(name, 0755)
+}
+
+// bundleRoot returns the directory holding the claude binary: dir itself or
+// its only subdirectory.
+func bundleRoot(dir string) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, Binary)); err == nil {
+		return dir, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		sub := filepath.Join(dir, entries[0].Name())
+		if _, err := os.Stat(filepath.Join(sub, Binary)); err == nil {
+			return sub, nil
+		}
+	}
+	return "", fmt.Errorf("no %s binary in the bundle", Binary)
+}
+
+// compareVersions compares versions by their dot-separated parts,
+// numerically where both parts are numbers, returning -1, 0 or 1.
+func compareVersions(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, errA := strconv.Atoi(pa[i])
+		nb, errB := strconv.Atoi(pb[i])
+		switch {
+		case errA == nil && errB == nil && na != nb:
+			if na < nb {
+				return -1
+			}
+			return 1
+		case (errA != nil || errB != nil) && pa[i] != pb[i]:
+			return strings.Compare(pa[i], pb[i])
+		}
+	}
+	switch {
+	case len(pa) < len(pb):
+		return -1
+	case len(pa) > len(pb):
+		return 1
+	}
+	return 0
+}
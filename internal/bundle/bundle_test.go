@@ -0,0 +1,224 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tarball returns a .tar.gz of files, path to content; content "->target"
+// makes a symlink.
+func tarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var entries [][2]string
+	for name, content := range files {
+		entries = append(entries, [2]string{name, content})
+	}
+	return orderedTarball(t, entries...)
+}
+
+// orderedTarball is tarball with the entries, path and content, in order.
+func orderedTarball(t *testing.T, entries ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		name, content := e[0], e[1]
+		hdr := &tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if target, ok := strings.CutPrefix(content, "->"); ok {
+			hdr = &tar.Header{Name: name, Linkname: target, Typeflag: tar.TypeSymlink}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			tw.Write([]byte(content))
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func writeTarball(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestInstallFile(t *testing.T) {
+	data := tarball(t, map[string]string{
+		"claude-bundle-linux-amd64/claude":          "#!/bin/sh\n",
+		"claude-bundle-linux-amd64/node/bin/node":   "node",
+		"claude-bundle-linux-amd64/node/bin/nodejs": "->node",
+	})
+	m := New(t.TempDir())
+
+	b, err := m.Install(context.Background(), "1.0.67", InstallOptions{Source: writeTarball(t, data)})
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if b.SHA256 != checksum(data) {
+		t.Errorf("SHA256 = %s, want %s", b.SHA256, checksum(data))
+	}
+	info, err := os.Stat(filepath.Join(b.Path, Binary))
+	if err != nil {
+		t.Fatalf("claude not installed at the bundle root: %v", err)
+	}
+	if info.Mode()&0100 == 0 {
+		t.Errorf("claude mode = %v, want executable", info.Mode())
+	}
+	if target, err := os.Readlink(filepath.Join(b.Path, "node/bin/nodejs")); err != nil || target != "node" {
+		t.Errorf("symlink = %q, %v, want node", target, err)
+	}
+
+	if _, err := m.Install(context.Background(), "1.0.67", InstallOptions{Source: writeTarball(t, data)}); err == nil {
+		t.Error("Install of an installed version succeeded without Force")
+	}
+	if _, err := m.Install(context.Background(), "1.0.67", InstallOptions{Source: writeTarball(t, data), Force: true}); err != nil {
+		t.Errorf("Install with Force: %v", err)
+	}
+}
+
+func TestInstallVerifiesChecksum(t *testing.T) {
+	data := tarball(t, map[string]string{"claude": "#!/bin/sh\n"})
+	published := checksum(data)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bundle.tar.gz":
+			w.Write(data)
+		case "/bundle.tar.gz.sha256":
+			w.Write([]byte(published + "  bundle.tar.gz\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	m := New(t.TempDir())
+
+	if _, err := m.Install(ctx, "1.0.0", InstallOptions{Source: server.URL + "/bundle.tar.gz"}); err != nil {
+		t.Fatalf("Install with published checksum: %v", err)
+	}
+
+	published = strings.Repeat("0", 64)
+	if _, err := m.Install(ctx, "1.0.1", InstallOptions{Source: server.URL + "/bundle.tar.gz"}); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Install with a wrong published checksum = %v, want a checksum mismatch", err)
+	}
+	if _, err := m.Install(ctx, "1.0.2", InstallOptions{Source: server.URL + "/bundle.tar.gz", SHA256: checksum(data)}); err != nil {
+		t.Errorf("Install with --sha256: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(m.dir, "1.0.1")); err == nil {
+		t.Error("bundle with a checksum mismatch was installed")
+	}
+
+	if _, err := m.Install(ctx, "1.0.3", InstallOptions{Source: server.URL + "/missing.tar.gz"}); err == nil {
+		t.Error("Install of a missing download succeeded")
+	}
+}
+
+func TestInstallRejectsBadBundles(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{"no claude", map[string]string{"bundle/node": "node"}},
+		{"path traversal", map[string]string{"claude": "x", "../escape": "x"}},
+		{"link traversal", map[string]string{"claude": "x", "link": "->../../etc/passwd"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			m := New(filepath.Join(dir, "bundles"))
+			if _, err := m.Install(context.Background(), "1.0.0", InstallOptions{Source: writeTarball(t, tarball(t, tt.files))}); err == nil {
+				t.Error("Install succeeded")
+			}
+			if _, err := os.Stat(filepath.Join(dir, "escape")); err == nil {
+				t.Error("file written outside the bundles directory")
+			}
+		})
+	}
+
+	// Each link is inside on its own, but l2 -> l1/.. leads out of the
+	// install directory once l1 -> . exists
+	for _, entries := range [][][2]string{
+		{{"claude", "x"}, {"l1", "->."}, {"l2", "->l1/.."}, {"l2/escape", "x"}},
+		{{"claude", "x"}, {"l2", "->l1/.."}, {"l1", "->."}},
+		{{"claude", "x"}, {"l1", "->."}, {"l2", "->l1/.."}, {"l2/sub/escape", "->claude"}},
+	} {
+		dir := t.TempDir()
+		m := New(filepath.Join(dir, "bundles"))
+		if _, err := m.Install(context.Background(), "1.0.0", InstallOptions{Source: writeTarball(t, orderedTarball(t, entries...))}); err == nil {
+			t.Errorf("Install of %v succeeded", entries)
+		}
+		for _, path := range []string{filepath.Join(dir, "escape"), filepath.Join(dir, "bundles", "escape"), filepath.Join(dir, "bundles", "sub")} {
+			if _, err := os.Lstat(path); err == nil {
+				t.Errorf("Install of %v wrote %s outside the install directory", entries, path)
+			}
+		}
+	}
+
+	if _, err := New(t.TempDir()).Install(context.Background(), "../1.0", InstallOptions{}); err == nil {
+		t.Error("Install of an invalid version succeeded")
+	}
+}
+
+func TestListAndRemove(t *testing.T) {
+	data := tarball(t, map[string]string{"claude": "#!/bin/sh\n"})
+	m := New(t.TempDir())
+	for _, version := range []string{"1.0.10", "1.0.9", "2.0.0"} {
+		if _, err := m.Install(context.Background(), version, InstallOptions{Source: writeTarball(t, data)}); err != nil {
+			t.Fatalf("Install %s: %v", version, err)
+		}
+	}
+
+	bundles, err := m.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var versions []string
+	for _, b := range bundles {
+		versions = append(versions, b.Version)
+		if b.SHA256 == "" || b.Installed.IsZero() {
+			t.Errorf("bundle %s lacks its install record: %+v", b.Version, b)
+		}
+	}
+	if got, want := strings.Join(versions, " "), "1.0.9 1.0.10 2.0.0"; got != want {
+		t.Errorf("List = %s, want %s", got, want)
+	}
+
+	if err := m.Remove("1.0.9"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := m.Remove("1.0.9"); err == nil {
+		t.Error("Remove of a removed version succeeded")
+	}
+	if bundles, _ := m.List(); len(bundles) != 2 {
+		t.Errorf("List after Remove = %d bundles, want 2", len(bundles))
+	}
+}
+
+func TestURL(t *testing.T) {
+	got := URL("https://example.com/{version}/claude-bundle-{os}-{arch}.tar.gz", "1.0.67")
+	if !strings.HasPrefix(got, "https://example.com/1.0.67/claude-bundle-linux-") || strings.Contains(got, "{") {
+		t.Errorf("URL = %s", got)
+	}
+}
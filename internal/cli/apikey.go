@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mpm/manfred/internal/apikey"
+	"github.com/mpm/manfred/internal/audit"
+	"github.com/mpm/manfred/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func newAPIKeyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apikey",
+		Short: "Manage REST API keys",
+		Long: `Commands for the keys of the REST API served by manfred serve. Each key has
+a scope:
+
+  read      read sessions, job artifacts and queue metrics
+  operator  also approve, abort and delete sessions
+  admin     also manage API keys (/api/v1/keys)
+
+Clients send a key as "Authorization: Bearer <key>". Once a key exists, the
+API rejects requests without credentials, as it does with server.auth set.`,
+	}
+
+	cmd.AddCommand(newAPIKeyCreateCmd())
+	cmd.AddCommand(newAPIKeyListCmd())
+	cmd.AddCommand(newAPIKeyRevokeCmd())
+
+	return cmd
+}
+
+// openAPIKeyStore opens the database and returns an API key store and the
+// audit log key changes are recorded in. The caller must call the returned
+// cleanup function when done.
+func openAPIKeyStore(ctx context.Context) (*apikey.SQLStore, *audit.Log, func(), error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	db, err := openDatabase(ctx, cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	cleanup := func() { db.Close() }
+	return apikey.NewSQLStore(db), audit.NewLog(audit.NewSQLStore(db)), cleanup, nil
+}
+
+func newAPIKeyCreateCmd() *cobra.Command {
+	var scope string
+
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create an API key",
+		Long: `Creates an API key and prints it. The key is not stored, only its hash, so
+it cannot be shown again.`,
+		Example: `  manfred apikey create grafana --scope read
+  manfred apikey create deploy-bot --scope operator`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			parsed, err := apikey.ParseScope(scope)
+			if err != nil {
+				return err
+			}
+
+			keys, auditLog, cleanup, err := openAPIKeyStore(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			key, token, err := keys.Create(cmd.Context(), args[0], parsed)
+			if err != nil {
+				return err
+			}
+			auditLog.Record(cmd.Context(), audit.ActionAPIKeyCreate, strconv.FormatInt(key.ID, 10), map[string]string{
+				"name":  key.Name,
+				"scope": string(key.Scope),
+			})
+			fmt.Fprintf(os.Stderr, "Created API key %d (%s, %s scope). It is not shown again:\n", key.ID, key.Name, key.Scope)
+			fmt.Println(token)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&scope, "scope", string(apikey.ScopeRead), "Scope of the key: read, operator or admin")
+
+	return cmd
+}
+
+func newAPIKeyListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List API keys",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keys, _, cleanup, err := openAPIKeyStore(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			list, err := keys.List(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if len(list) == 0 {
+				fmt.Println("No API keys")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tSCOPE\tKEY\tCREATED\tLAST USED\tSTATUS")
+			for _, key := range list {
+				status := "active"
+				if key.Revoked() {
+					status = "revoked " + key.RevokedAt.Local().Format(time.DateTime)
+				}
+				lastUsed := "never"
+				if key.LastUsedAt != nil {
+					lastUsed = key.LastUsedAt.Local().Format(time.DateTime)
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s...\t%s\t%s\t%s\n",
+					key.ID, key.Name, key.Scope, key.Prefix, key.CreatedAt.Local().Format(time.DateTime), lastUsed, status)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newAPIKeyRevokeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "revoke <id>",
+		Short: "Revoke an API key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid API key ID %q", args[0])
+			}
+
+			keys, auditLog, cleanup, err := openAPIKeyStore(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			if err := keys.Revoke(cmd.Context(), id); err != nil {
+				return err
+			}
+			auditLog.Record(cmd.Context(), audit.ActionAPIKeyRevoke, args[0], nil)
+			fmt.Printf("Revoked API key %d\n", id)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mpm/manfred/internal/audit"
+	"github.com/mpm/manfred/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func newAuditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Inspect the audit log",
+		Long: `Commands for the audit log, which records who performed each state-changing
+action: session approvals, aborts, retries, deletions and phase transitions,
+and API key changes. Actors are the OS user of manfred commands (cli), the
+API key of REST API requests (api_key), "server.auth" or "anonymous" for
+REST API requests without a key (http), the sender of GitHub events
+(github), or MANFRED itself (system). A phase transition a job causes when
+it ends is attributed to whoever started it.
+
+The REST API serves the log at /api/v1/audit to admin keys.`,
+	}
+
+	cmd.AddCommand(newAuditListCmd())
+
+	return cmd
+}
+
+func newAuditListCmd() *cobra.Command {
+	var filter audit.Filter
+	var actor string
+	var since time.Duration
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List audit log entries, newest first",
+		Example: `  manfred audit list --target acme-widgets-issue-42
+  manfred audit list --actor github:octocat --since 168h
+  manfred audit list --action session.abort --json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if actor != "" {
+				kind, name, _ := strings.Cut(actor, ":")
+				filter.ActorType, filter.Actor = audit.ActorType(kind), name
+			}
+			if since > 0 {
+				filter.Since = time.Now().Add(-since)
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			db, err := openDatabase(cmd.Context(), cfg)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			entries, err := audit.NewSQLStore(db).List(cmd.Context(), filter)
+			if err != nil {
+				return err
+			}
+
+			if jsonOutput {
+				if entries == nil {
+					entries = []audit.Entry{}
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(entries)
+			}
+			if len(entries) == 0 {
+				fmt.Println("No audit log entries")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "TIME\tACTOR\tACTION\tTARGET\tDETAILS")
+			for _, entry := range entries {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+					entry.CreatedAt.Local().Format(time.DateTime), entry.Actor, entry.Action, entry.Target, formatDetails(entry.Details))
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().StringVar(&filter.Action, "action", "", "Only this action (e.g. session.approve, session.phase_change)")
+	cmd.Flags().StringVar(&filter.Target, "target", "", "Only this target (session ID or API key ID)")
+	cmd.Flags().StringVar(&actor, "actor", "", "Only this actor type or type:name (e.g. api_key, github:octocat)")
+	cmd.Flags().DurationVar(&since, "since", 0, "Only entries newer than this (e.g. 24h)")
+	cmd.Flags().IntVar(&filter.Limit, "limit", 50, "Show at most this many entries (0 = all)")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+
+	return cmd
+}
+
+// formatDetails formats audit details as sorted key=value pairs.
+func formatDetails(details map[string]string) string {
+	pairs := make([]string, 0, len(details))
+	for k, v := range details {
+		if v != "" {
+			pairs = append(pairs, k+"="+v)
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
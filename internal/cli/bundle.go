@@ -0,0 +1,203 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mpm/manfred/internal/bundle"
+	"github.com/mpm/manfred/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func newBundleCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bundle",
+		Short: "Manage installed Claude bundle versions",
+		Long: `Commands for the portable Claude Code bundles jobs run Claude from. Versions
+are installed side by side in claude.bundles_dir and mounted read-only into
+job containers.
+
+Jobs use the version in a project's claude_version, else claude.version;
+without either, the unversioned bundle at claude.bundle_path.`,
+	}
+
+	cmd.AddCommand(newBundleInstallCmd())
+	cmd.AddCommand(newBundleListCmd())
+	cmd.AddCommand(newBundleRemoveCmd())
+
+	return cmd
+}
+
+func newBundleInstallCmd() *cobra.Command {
+	var url, file, sum string
+	var force, use bool
+
+	cmd := &cobra.Command{
+		Use:   "install <version>",
+		Short: "Download, verify and install a bundle version",
+		Long: `Installs a Claude bundle tarball (.tar.gz, as built by 'make bundle') as
+<version>. It is downloaded from --url, or claude.bundle_url with {version}
+and {arch} filled in, or read from --file.
+
+Downloads are verified against --sha256, or else the checksum published at
+<url>.sha256; the install fails without one. Files are verified if --sha256
+is given.`,
+		Example: `  manfred bundle install 1.0.67 --url https://example.com/claude-bundle-{version}-linux-{arch}.tar.gz
+  manfred bundle install dev --file claude-bundle/dist/claude-bundle-linux-amd64.tar.gz --use`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version := args[0]
+			cfg, err := config.LoadUnvalidated()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			source := file
+			if source == "" {
+				if url == "" {
+					url = cfg.Claude.BundleURL
+				}
+				if url == "" {
+					return fmt.Errorf("no bundle source: pass --url or --file, or set claude.bundle_url")
+				}
+				source = bundle.URL(url, version)
+			}
+
+			fmt.Printf("Installing Claude bundle %s from %s\n", version, source)
+			b, err := bundle.New(cfg.Claude.BundlesDir).Install(cmd.Context(), version, bundle.InstallOptions{
+				Source: source,
+				SHA256: sum,
+				Force:  force,
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Installed %s (sha256 %s)\n", b.Path, b.SHA256)
+
+			if use {
+				path, err := configFilePath()
+				if err != nil {
+					return err
+				}
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					return fmt.Errorf("failed to create config directory: %w", err)
+				}
+				if err := config.SetInFile(path, "claude.version", version); err != nil {
+					return err
+				}
+				fmt.Printf("Set claude.version in %s\n", path)
+			} else if cfg.Claude.Version != version {
+				fmt.Printf("Use it with: manfred config set claude.version %s\n", version)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&url, "url", "", "Download URL; {version} and {arch} are filled in (default claude.bundle_url)")
+	cmd.Flags().StringVar(&file, "file", "", "Install from a local tarball instead")
+	cmd.Flags().StringVar(&sum, "sha256", "", "Expected SHA-256 checksum of the tarball")
+	cmd.Flags().BoolVar(&force, "force", false, "Replace an installed version")
+	cmd.Flags().BoolVar(&use, "use", false, "Set claude.version to the installed version")
+
+	return cmd
+}
+
+func newBundleListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List installed bundle versions and who uses them",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadUnvalidated()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			bundles, err := bundle.New(cfg.Claude.BundlesDir).List()
+			if err != nil {
+				return err
+			}
+			if len(bundles) == 0 {
+				fmt.Printf("No bundles installed in %s\n", cfg.Claude.BundlesDir)
+				return nil
+			}
+
+			users := bundleUsers(cfg)
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "VERSION\tINSTALLED\tSHA256\tUSED BY")
+			for _, b := range bundles {
+				installed, sum := "-", "-"
+				if !b.Installed.IsZero() {
+					installed = b.Installed.Local().Format("2006-01-02 15:04")
+				}
+				if len(b.SHA256) >= 12 {
+					sum = b.SHA256[:12]
+				}
+				usedBy := strings.Join(users[b.Version], ", ")
+				if usedBy == "" {
+					usedBy = "-"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", b.Version, installed, sum, usedBy)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newBundleRemoveCmd() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "remove <version>",
+		Short: "Remove an installed bundle version",
+		Long: `Removes an installed bundle version. Versions that claude.version or a
+project's claude_version pins are kept unless --force is given.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version := args[0]
+			cfg, err := config.LoadUnvalidated()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			if users := bundleUsers(cfg)[version]; len(users) > 0 && !force {
+				return fmt.Errorf("bundle %s is used by %s (use --force to remove it anyway)", version, strings.Join(users, ", "))
+			}
+			if err := bundle.New(cfg.Claude.BundlesDir).Remove(version); err != nil {
+				return err
+			}
+			fmt.Printf("Removed Claude bundle %s\n", version)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&force, "force", false, "Remove the version even if it is pinned")
+
+	return cmd
+}
+
+// bundleUsers maps bundle versions to what pins them: "default" for
+// claude.version and project names for their claude_version.
+func bundleUsers(cfg *config.Config) map[string][]string {
+	users := map[string][]string{}
+	if cfg.Claude.Version != "" {
+		users[cfg.Claude.Version] = []string{"default"}
+	}
+	// ReadDir sorts the projects by name
+	entries, _ := os.ReadDir(cfg.ProjectsDir)
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(cfg.ProjectsDir, e.Name(), "project.yml")); err != nil {
+			continue
+		}
+		projectConfig, err := cfg.ProjectConfig(e.Name())
+		if err != nil || projectConfig.ClaudeVersion == "" {
+			continue
+		}
+		users[projectConfig.ClaudeVersion] = append(users[projectConfig.ClaudeVersion], e.Name())
+	}
+	return users
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/job"
+	"github.com/spf13/cobra"
+)
+
+func newCleanupCmd() *cobra.Command {
+	var list bool
+
+	cmd := &cobra.Command{
+		Use:   "cleanup [job-id...]",
+		Short: "Stop containers kept after failed jobs",
+		Long: `Stops the Docker Compose environments of failed jobs that were run with
+--keep-containers (or job.keep_containers in the config).
+
+Without arguments, all kept environments are stopped.`,
+		ValidArgsFunction: completeKeptJobs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			envs, err := job.ListKept(cfg.JobsDir)
+			if err != nil {
+				return err
+			}
+
+			if list {
+				if len(envs) == 0 {
+					fmt.Println("No kept containers.")
+					return nil
+				}
+				fmt.Printf("%-24s %-20s %-20s %s\n", "JOB", "PROJECT", "KEPT", "CONTAINER")
+				for _, env := range envs {
+					fmt.Printf("%-24s %-20s %-20s %s\n", env.JobID, env.Project, env.KeptAt.Local().Format("2006-01-02 15:04"), env.Container)
+				}
+				return nil
+			}
+
+			if len(args) > 0 {
+				envs, err = selectKept(envs, args)
+				if err != nil {
+					return err
+				}
+			}
+			if len(envs) == 0 {
+				fmt.Println("No kept containers.")
+				return nil
+			}
+
+			runner, err := job.NewRunner(cfg)
+			if err != nil {
+				return fmt.Errorf("failed to create runner: %w", err)
+			}
+			defer runner.Close()
+
+			var failed bool
+			for _, env := range envs {
+				if err := runner.CleanupKept(cmd.Context(), env); err != nil {
+					fmt.Fprintln(os.Stderr, "Error:", err)
+					failed = true
+					continue
+				}
+				fmt.Printf("Stopped containers for job %s\n", env.JobID)
+			}
+			if failed {
+				return fmt.Errorf("some environments could not be cleaned up")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&list, "list", "l", false, "List kept environments without stopping them")
+
+	return cmd
+}
+
+// selectKept returns the kept environments for the given job IDs.
+func selectKept(envs []job.KeptEnvironment, jobIDs []string) ([]job.KeptEnvironment, error) {
+	byID := make(map[string]job.KeptEnvironment, len(envs))
+	for _, env := range envs {
+		byID[env.JobID] = env
+	}
+
+	var selected []job.KeptEnvironment
+	for _, id := range jobIDs {
+		env, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("no kept containers for job %s", id)
+		}
+		selected = append(selected, env)
+	}
+	return selected, nil
+}
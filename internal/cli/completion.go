@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/ticket"
+	"github.com/spf13/cobra"
+)
+
+// completionFunc completes the argument being typed, toComplete, after args.
+type completionFunc = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+func newCompletionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion bash|zsh|fish",
+		Short: "Generate a shell completion script",
+		Long: `Writes a completion script for bash, zsh or fish to stdout. Besides
+commands and flags, it completes project names, ticket IDs, session IDs and
+job IDs from the configured stores.
+
+Load it in the current shell:
+
+  source <(manfred completion bash)
+  source <(manfred completion zsh)
+  manfred completion fish | source
+
+or install it permanently:
+
+  manfred completion bash > /etc/bash_completion.d/manfred
+  manfred completion zsh > "${fpath[1]}/_manfred"
+  manfred completion fish > ~/.config/fish/completions/manfred.fish`,
+		Args:                  cobra.ExactArgs(1),
+		ValidArgs:             []string{"bash", "zsh", "fish"},
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := cmd.Root()
+			switch args[0] {
+			case "bash":
+				return root.GenBashCompletionV2(os.Stdout, true)
+			case "zsh":
+				return root.GenZshCompletion(os.Stdout)
+			case "fish":
+				return root.GenFishCompletion(os.Stdout, true)
+			default:
+				return fmt.Errorf("unsupported shell %q (want bash, zsh or fish)", args[0])
+			}
+		},
+	}
+}
+
+// completeArgs completes each positional argument with the function at its
+// position. A nil function completes file names; arguments past the last
+// function complete nothing.
+func completeArgs(funcs ...completionFunc) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) >= len(funcs) {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		if funcs[len(args)] == nil {
+			return nil, cobra.ShellCompDirectiveDefault
+		}
+		return funcs[len(args)](cmd, args, toComplete)
+	}
+}
+
+// completeProjects completes the names of configured projects.
+func completeProjects(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	entries, err := os.ReadDir(cfg.ProjectsDir)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), toComplete) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(cfg.ProjectsDir, e.Name(), "project.yml")); err == nil {
+			names = append(names, e.Name())
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeTickets returns a function completing the IDs of the tickets of
+// the project in args[0], described by their status and prompt. With
+// statuses, only tickets in one of them are completed.
+func completeTickets(statuses ...ticket.Status) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		cfg, err := config.Load()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		tickets, err := ticket.NewFileStore(cfg.TicketsDir, args[0]).List(cmd.Context(), nil)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+
+		var ids []string
+		for _, t := range tickets {
+			if !strings.HasPrefix(t.ID, toComplete) {
+				continue
+			}
+			if len(statuses) > 0 && !slices.Contains(statuses, t.Status) {
+				continue
+			}
+			ids = append(ids, fmt.Sprintf("%s\t%s: %s", t.ID, t.Status, t.PromptPreview(40)))
+		}
+		return ids, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeSessions completes session IDs, described by their phase.
+func completeSessions(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	sessionStore, cleanup, err := openSessionStore(cmd.Context())
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	defer cleanup()
+
+	sessions, err := sessionStore.List(cmd.Context(), session.SessionFilter{})
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var ids []string
+	for _, s := range sessions {
+		if strings.HasPrefix(s.ID, toComplete) {
+			ids = append(ids, s.ID+"\t"+s.Phase.DisplayName())
+		}
+	}
+	return ids, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completePhases completes session phase names.
+func completePhases(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var phases []string
+	for _, p := range session.AllPhases() {
+		phases = append(phases, string(p))
+	}
+	return phases, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeJobs completes the IDs of the jobs in the jobs directory.
+func completeJobs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	entries, err := os.ReadDir(cfg.JobsDir)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var ids []string
+	for _, e := range entries {
+		if e.IsDir() && strings.HasPrefix(e.Name(), "job_") && strings.HasPrefix(e.Name(), toComplete) {
+			ids = append(ids, e.Name())
+		}
+	}
+	return ids, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeKeptJobs completes the IDs of jobs whose containers were kept,
+// described by their project.
+func completeKeptJobs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	envs, err := job.ListKept(cfg.JobsDir)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var ids []string
+	for _, env := range envs {
+		if strings.HasPrefix(env.JobID, toComplete) {
+			ids = append(ids, env.JobID+"\t"+env.Project)
+		}
+	}
+	return ids, cobra.ShellCompDirectiveNoFileComp
+}
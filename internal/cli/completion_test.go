@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/mpm/manfred/internal/session"
+	"github.com/spf13/cobra"
+)
+
+func TestCompleteArgs(t *testing.T) {
+	var called []string
+	first := func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		called = append(called, toComplete)
+		return []string{"widgets"}, cobra.ShellCompDirectiveNoFileComp
+	}
+	complete := completeArgs(first, nil)
+
+	tests := []struct {
+		args          []string
+		want          []string
+		wantDirective cobra.ShellCompDirective
+	}{
+		{nil, []string{"widgets"}, cobra.ShellCompDirectiveNoFileComp},
+		{[]string{"widgets"}, nil, cobra.ShellCompDirectiveDefault},
+		{[]string{"widgets", "prompt.md"}, nil, cobra.ShellCompDirectiveNoFileComp},
+	}
+	for _, tt := range tests {
+		got, directive := complete(&cobra.Command{}, tt.args, "wid")
+		if !slices.Equal(got, tt.want) || directive != tt.wantDirective {
+			t.Errorf("completeArgs() after %q = %q, %v, want %q, %v", tt.args, got, directive, tt.want, tt.wantDirective)
+		}
+	}
+	if !slices.Equal(called, []string{"wid"}) {
+		t.Errorf("first function called with %q, want once with wid", called)
+	}
+}
+
+func TestCompleteSessions(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("MANFRED_DATA_DIR", dir)
+	t.Setenv("MANFRED_DATABASE_PATH", filepath.Join(dir, "manfred.db"))
+
+	ctx := context.Background()
+	sessions, cleanup, err := openSessionStore(ctx)
+	if err != nil {
+		t.Fatalf("openSessionStore() error = %v", err)
+	}
+	for _, issue := range []int{7, 8} {
+		sess := session.NewSession("acme", "widgets", issue)
+		if issue == 8 {
+			sess.Phase = session.PhaseInReview
+		}
+		if err := sessions.Create(ctx, sess); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	other := session.NewSession("acme", "gadgets", 1)
+	if err := sessions.Create(ctx, other); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	cleanup()
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(ctx)
+	got, directive := completeSessions(cmd, nil, "acme-widgets-")
+	slices.Sort(got)
+	want := []string{
+		"acme-widgets-issue-7\t" + session.PhasePlanning.DisplayName(),
+		"acme-widgets-issue-8\t" + session.PhaseInReview.DisplayName(),
+	}
+	if !slices.Equal(got, want) || directive != cobra.ShellCompDirectiveNoFileComp {
+		t.Errorf("completeSessions() = %q, %v, want %q", got, directive, want)
+	}
+}
@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect and edit the configuration",
+		Long:  `Commands for showing, validating and changing the MANFRED configuration file.`,
+	}
+
+	cmd.AddCommand(newConfigShowCmd())
+	cmd.AddCommand(newConfigValidateCmd())
+	cmd.AddCommand(newConfigSetCmd())
+
+	return cmd
+}
+
+func newConfigShowCmd() *cobra.Command {
+	var defaults bool
+
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the effective configuration",
+		Long: `Prints the configuration after defaults and environment variables are applied,
+as YAML. Tokens, secrets, passwords and notification URLs are redacted.
+
+With --defaults, prints the built-in defaults instead, ignoring the config
+file and environment: e.g. the limits section with every timeout, poll
+interval and limit that can be tuned.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			load := config.LoadUnvalidated
+			if defaults {
+				load = config.Defaults
+			}
+			cfg, err := load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			data, err := yaml.Marshal(cfg.Settings())
+			if err != nil {
+				return fmt.Errorf("failed to encode config: %w", err)
+			}
+			if file := viper.ConfigFileUsed(); file != "" && !defaults {
+				fmt.Printf("# %s\n", file)
+			}
+			fmt.Print(string(data))
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&defaults, "defaults", false, "Print the built-in defaults instead of the effective configuration")
+
+	return cmd
+}
+
+func newConfigValidateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "Check the configuration for errors",
+		Long: `Checks the configuration for invalid and contradictory settings, which every
+other command rejects on startup, and whether this host can run jobs.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadUnvalidated()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if err := cfg.Validate(); err != nil {
+				fmt.Println("Configuration is invalid:")
+				printErrors(err)
+				return errors.New("invalid config")
+			}
+			fmt.Println("Configuration is valid.")
+
+			if err := cfg.ValidateJobs(); err != nil {
+				fmt.Println()
+				fmt.Println("Jobs cannot run on this host:")
+				printErrors(err)
+			}
+			return nil
+		},
+	}
+}
+
+func newConfigSetCmd() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Change a setting in the config file",
+		Long: `Sets a dotted key, e.g. github.poll_interval, in the config file, keeping its
+comments and the order of other settings. The value is parsed as YAML, so
+"true", "30s" and "[a, b]" keep their types. The file is created if needed.
+
+The change is rejected if it makes the configuration invalid, unless --force
+is given.`,
+		Example: `  manfred config set github.poll_interval 1m
+  manfred config set triggers.labels "[manfred, ai]"`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, value := args[0], args[1]
+			if !config.IsKnownKey(key) {
+				return fmt.Errorf("unknown setting %q", key)
+			}
+
+			path, err := configFilePath()
+			if err != nil {
+				return err
+			}
+			previous, err := os.ReadFile(path)
+			existed := err == nil
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to read config file: %w", err)
+			}
+
+			if !existed {
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					return fmt.Errorf("failed to create config directory: %w", err)
+				}
+			}
+			if err := config.SetInFile(path, key, value); err != nil {
+				return err
+			}
+
+			// Reload the file to check the result
+			viper.SetConfigFile(path)
+			err = viper.ReadInConfig()
+			if err == nil {
+				_, err = config.Load()
+			}
+			if err != nil && !force {
+				if existed {
+					os.WriteFile(path, previous, 0600)
+				} else {
+					os.Remove(path)
+				}
+				return fmt.Errorf("not saved, %w", err)
+			}
+
+			fmt.Printf("Set %s in %s\n", key, path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&force, "force", false, "Save even if the configuration becomes invalid")
+
+	return cmd
+}
+
+// configFilePath returns the config file in use: --config, the file viper
+// found, or $HOME/.manfred/config.yaml.
+func configFilePath() (string, error) {
+	if cfgFile != "" {
+		return cfgFile, nil
+	}
+	if file := viper.ConfigFileUsed(); file != "" {
+		return file, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %w", err)
+	}
+	return filepath.Join(home, ".manfred", "config.yaml"), nil
+}
+
+// printErrors prints each line of a joined error as a list item.
+func printErrors(err error) {
+	for _, line := range strings.Split(err.Error(), "\n") {
+		fmt.Printf("  - %s\n", line)
+	}
+}
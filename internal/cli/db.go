@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/store"
+	"github.com/spf13/cobra"
+)
+
+func newDBCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "db",
+		Short: "Database checkpoint, backup and restore commands",
+		Long: `Maintain the SQLite database for durable state on ephemeral hosts.
+
+'manfred serve' replicates the database to database.replication.url every
+database.replication.interval. To use an external replicator such as
+litestream instead, set database.replication.external: MANFRED then leaves
+WAL checkpoints to the replicator.
+
+These commands only apply to the sqlite driver; back up a Postgres database
+with pg_dump or your provider's backups.`,
+	}
+
+	cmd.AddCommand(newDBCheckpointCmd())
+	cmd.AddCommand(newDBBackupCmd())
+	cmd.AddCommand(newDBRestoreCmd())
+
+	return cmd
+}
+
+func newDBCheckpointCmd() *cobra.Command {
+	var mode string
+
+	cmd := &cobra.Command{
+		Use:   "checkpoint",
+		Short: "Copy the WAL into the database file",
+		Long: `Run a WAL checkpoint. Modes are passive (default), full, restart and
+truncate; truncate also empties the WAL file, e.g. before snapshotting the
+volume the database lives on.
+
+With an external replicator, let it checkpoint instead: frames checkpointed
+before the replicator has copied them are lost to the replica.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			db, err := openDatabase(cmd.Context(), cfg)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			result, err := db.Checkpoint(cmd.Context(), mode)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Checkpointed %d of %d WAL page(s)\n", result.Checkpointed, result.WALPages)
+			if result.Busy {
+				return fmt.Errorf("checkpoint did not complete: the database is busy")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&mode, "mode", "passive", "Checkpoint mode: passive, full, restart or truncate")
+
+	return cmd
+}
+
+func newDBBackupCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup [destination]",
+		Short: "Write a consistent copy of the database",
+		Long: `Write a consistent copy of the database without stopping writers.
+
+The destination defaults to database.replication.url. Destinations starting
+with s3:// are uploaded using the aws CLI.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			dest := cfg.Database.Replication.URL
+			if len(args) > 0 {
+				dest = args[0]
+			}
+			if dest == "" {
+				return fmt.Errorf("no destination: pass one or set database.replication.url")
+			}
+
+			db, err := openDatabase(cmd.Context(), cfg)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			if err := db.Replicate(cmd.Context(), dest); err != nil {
+				return err
+			}
+			fmt.Printf("Database backed up to %s\n", dest)
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func newDBRestoreCmd() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "restore [source]",
+		Short: "Restore the database from a backup",
+		Long: `Replace the database with a copy written by 'manfred db backup' or
+'manfred serve' replication. The source defaults to database.replication.url.
+
+Stop 'manfred serve' first. An existing database is only replaced with
+--force.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			if cfg.Database.Driver == string(store.DriverPostgres) {
+				return fmt.Errorf("restore is only supported with the sqlite database driver")
+			}
+
+			src := cfg.Database.Replication.URL
+			if len(args) > 0 {
+				src = args[0]
+			}
+			if src == "" {
+				return fmt.Errorf("no source: pass one or set database.replication.url")
+			}
+
+			if err := store.Restore(cmd.Context(), src, cfg.Database.Path, force); err != nil {
+				return err
+			}
+			fmt.Printf("Database restored from %s to %s\n", src, cfg.Database.Path)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&force, "force", false, "Replace an existing database")
+
+	return cmd
+}
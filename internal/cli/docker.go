@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/docker"
+	"github.com/mpm/manfred/internal/job"
+	"github.com/spf13/cobra"
+)
+
+func newDockerCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "docker",
+		Short: "Docker resource management",
+	}
+
+	cmd.AddCommand(newDockerPruneCmd())
+
+	return cmd
+}
+
+func newDockerPruneCmd() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove Docker resources left behind by jobs",
+		Long: `Find the containers, networks, volumes and images of job compose projects
+(manfred_<job-id>) and remove them.
+
+Resources of running jobs and of jobs whose containers were kept
+(--keep-containers) are left alone; use 'manfred cleanup' for those.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			busy, err := job.BusyJobs(cfg.JobsDir)
+			if err != nil {
+				return err
+			}
+
+			client, err := docker.New()
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+
+			resources, err := client.ListComposeResources(cmd.Context(), job.ComposeProjectPrefix)
+			if err != nil {
+				return err
+			}
+
+			var removed, skipped int
+			var failed bool
+			for _, r := range resources {
+				if busy[strings.TrimPrefix(r.Project, job.ComposeProjectPrefix)] {
+					skipped++
+					continue
+				}
+				if dryRun {
+					fmt.Printf("Would remove %s %s\n", r.Kind, r.Name)
+					removed++
+					continue
+				}
+				if err := client.RemoveResource(cmd.Context(), r); err != nil {
+					fmt.Fprintln(os.Stderr, "Error:", err)
+					failed = true
+					continue
+				}
+				fmt.Printf("Removed %s %s\n", r.Kind, r.Name)
+				removed++
+			}
+
+			verb := "Removed"
+			if dryRun {
+				verb = "Would remove"
+			}
+			fmt.Printf("%s %d resource(s), skipped %d of running or kept jobs\n", verb, removed, skipped)
+			if failed {
+				return fmt.Errorf("some resources could not be removed")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Only list what would be removed")
+
+	return cmd
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/docker"
+	"github.com/spf13/cobra"
+)
+
+func newDoctorCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the configuration and the installed Docker and Compose",
+		Long: fmt.Sprintf(`Check that the configuration is valid and that the installed Docker engine
+and Compose are supported: Docker %s or newer, and Compose %s or newer, as
+the docker compose plugin or the standalone docker-compose binary.
+
+Jobs and 'manfred serve' refuse to run with unsupported versions; doctor
+reports them up front. It exits non-zero if any check fails.`, docker.MinDockerVersion, docker.MinComposeVersion),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			failed := false
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "CHECK\tVERSION\tSTATUS")
+			report := func(check, version string, err error) {
+				status := "ok"
+				if err != nil {
+					status = err.Error()
+					failed = true
+				}
+				if version == "" {
+					version = "-"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", check, version, status)
+			}
+
+			_, err := config.Load()
+			report("config", "", err)
+
+			versions := docker.DetectVersions(cmd.Context())
+			report("docker", versions.Docker, versions.CheckDocker())
+			compose := versions.Compose
+			if versions.ComposeCommand != nil {
+				compose += " (" + strings.Join(versions.ComposeCommand, " ") + ")"
+			}
+			report("compose", compose, versions.CheckCompose())
+
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			if failed {
+				return errors.New("some checks failed")
+			}
+			return nil
+		},
+	}
+}
+
+// checkDockerVersions refuses unsupported Docker and Compose versions, so
+// jobs don't fail mid-way. A missing Compose is only a warning, since
+// projects with docker.image run without it.
+func checkDockerVersions(ctx context.Context) error {
+	versions := docker.DetectVersions(ctx)
+	if err := versions.CheckDocker(); err != nil {
+		return err
+	}
+	if versions.ComposeCommand == nil {
+		fmt.Fprintln(os.Stderr, "Warning: docker compose is not installed, only projects with docker.image can run")
+		return nil
+	}
+	return versions.CheckCompose()
+}
@@ -0,0 +1,199 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/upload"
+	"github.com/spf13/cobra"
+)
+
+func newGCCmd() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Remove old job directories and uploads",
+		Long: `Remove job directories beyond the job.retention policy: older than max_age,
+beyond the newest max_count, or over max_disk_usage in total. Uploaded outputs
+(uploads.target) older than uploads.retention are deleted too.
+
+Running jobs, jobs of sessions that are still active and jobs whose containers
+were kept are never removed. 'manfred serve' runs the same collection every
+job.retention.interval.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			flags := cmd.Flags()
+			if flags.Changed("max-age") {
+				cfg.Job.Retention.MaxAge, _ = flags.GetDuration("max-age")
+			}
+			if flags.Changed("max-count") {
+				cfg.Job.Retention.MaxCount, _ = flags.GetInt("max-count")
+			}
+			if flags.Changed("max-disk-usage") {
+				cfg.Job.Retention.MaxDiskUsage, _ = flags.GetString("max-disk-usage")
+			}
+			if !cfg.Job.Retention.Enabled() && cfg.Uploads.Retention <= 0 {
+				return fmt.Errorf("no retention policy: set job.retention or uploads.retention in the config or pass --max-age, --max-count or --max-disk-usage")
+			}
+
+			verb := "Removed"
+			if dryRun {
+				verb = "Would remove"
+			}
+			failed := false
+
+			if cfg.Job.Retention.Enabled() {
+				sessionStore, cleanup, err := openSessionStore(cmd.Context())
+				if err != nil {
+					return err
+				}
+				defer cleanup()
+
+				result, err := collectJobGarbage(cmd.Context(), cfg, sessionStore, dryRun)
+				if err != nil {
+					return err
+				}
+
+				for _, dir := range result.Removed {
+					fmt.Printf("%s %s (%s, last modified %s)\n", verb, dir.ID, formatSize(dir.Size), dir.ModTime.Local().Format("2006-01-02 15:04"))
+				}
+				for _, err := range result.Errors {
+					fmt.Fprintln(os.Stderr, "Error:", err)
+				}
+				fmt.Printf("%s %d job(s), %s; kept %d (%d protected)\n", verb, len(result.Removed), formatSize(result.Freed), result.Kept, result.Protected)
+				failed = len(result.Errors) > 0
+			}
+
+			if cfg.Uploads.Target != "" && cfg.Uploads.Retention > 0 {
+				uploader, err := newUploader(cfg)
+				if err != nil {
+					return err
+				}
+				removed, errs := uploader.Prune(cmd.Context(), dryRun)
+				for _, up := range removed {
+					fmt.Printf("%s upload %s (%s, uploaded %s)\n", verb, up.Name, up.URL, up.Created.Local().Format("2006-01-02 15:04"))
+				}
+				for _, err := range errs {
+					fmt.Fprintln(os.Stderr, "Error:", err)
+				}
+				fmt.Printf("%s %d upload(s) older than %s\n", verb, len(removed), cfg.Uploads.Retention)
+				failed = failed || len(errs) > 0
+			}
+
+			if failed {
+				return fmt.Errorf("some job directories or uploads could not be removed")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Only list what would be removed")
+	cmd.Flags().Duration("max-age", 0, "Remove jobs older than this (overrides job.retention.max_age)")
+	cmd.Flags().Int("max-count", 0, "Keep at most this many jobs (overrides job.retention.max_count)")
+	cmd.Flags().String("max-disk-usage", "", "Keep at most this much job data, e.g. 20GB (overrides job.retention.max_disk_usage)")
+
+	return cmd
+}
+
+// collectJobGarbage applies the retention policy to the jobs directory,
+// protecting the jobs of active sessions.
+func collectJobGarbage(ctx context.Context, cfg *config.Config, sessions session.Store, dryRun bool) (*job.GCResult, error) {
+	ids, err := sessions.JobIDs(ctx, session.SessionFilter{ActiveOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	protected := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		protected[id] = true
+	}
+	return job.CollectGarbage(cfg.JobsDir, cfg.Job.Retention, protected, dryRun)
+}
+
+// runJobGC collects job garbage every job.retention.interval until ctx is
+// done.
+func runJobGC(ctx context.Context, cfg *config.Config, sessions session.Store) {
+	ticker := time.NewTicker(cfg.Job.Retention.Interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := collectJobGarbage(ctx, cfg, sessions, false)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: job garbage collection failed:", err)
+		} else {
+			for _, err := range result.Errors {
+				fmt.Fprintln(os.Stderr, "Warning:", err)
+			}
+			if len(result.Removed) > 0 {
+				fmt.Printf("Removed %d old job(s), freed %s\n", len(result.Removed), formatSize(result.Freed))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// newUploader returns the uploader for uploads.target, with a GitHub client
+// for gists.
+func newUploader(cfg *config.Config) (*upload.Uploader, error) {
+	var client *github.Client
+	if cfg.Uploads.Target == config.UploadsGist {
+		var err error
+		if client, err = cfg.GitHubClient(); err != nil {
+			return nil, err
+		}
+	}
+	return upload.New(cfg, client)
+}
+
+// runUploadPrune deletes uploads older than uploads.retention every interval
+// until ctx is done.
+func runUploadPrune(ctx context.Context, uploader *upload.Uploader, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		removed, errs := uploader.Prune(ctx, false)
+		for _, err := range errs {
+			fmt.Fprintln(os.Stderr, "Warning:", err)
+		}
+		if len(removed) > 0 {
+			fmt.Printf("Removed %d old upload(s)\n", len(removed))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// formatSize formats a byte count with a binary unit.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
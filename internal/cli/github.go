@@ -4,10 +4,15 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/mpm/manfred/internal/config"
 	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/orchestrator"
+	"github.com/mpm/manfred/internal/webhook"
 	"github.com/spf13/cobra"
 )
 
@@ -20,44 +25,81 @@ func newGitHubCmd() *cobra.Command {
 
 	cmd.AddCommand(newGitHubTestAuthCmd())
 	cmd.AddCommand(newGitHubWebhookURLCmd())
+	cmd.AddCommand(newGitHubSetupWebhookCmd())
+	cmd.AddCommand(newGitHubSetupLabelsCmd())
 
 	return cmd
 }
 
 func newGitHubTestAuthCmd() *cobra.Command {
-	return &cobra.Command{
+	var (
+		repos    []string
+		webhooks bool
+	)
+
+	cmd := &cobra.Command{
 		Use:   "test-auth",
 		Short: "Verify GitHub credentials",
-		Long:  `Tests that the configured GitHub token is valid by fetching the authenticated user.`,
-		RunE:  runGitHubTestAuth,
+		Long: `Tests that the configured GitHub token is valid by fetching the authenticated user,
+then checks each configured repository for the permissions MANFRED needs (read, push
+branches, write issues, label, write pull requests) and prints a capability matrix.
+
+The permissions are those GitHub reports for the repository: the user's role for a
+token, the installation's permissions for a GitHub App. Nothing is written. Use
+--webhooks to also check webhook admin access.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runGitHubTestAuth(repos, webhooks)
+		},
 	}
+
+	cmd.Flags().StringSliceVar(&repos, "repo", nil, "Repository to check as owner/repo (default: all configured projects)")
+	cmd.Flags().BoolVar(&webhooks, "webhooks", false, "Also check webhook admin access")
+
+	return cmd
 }
 
-func runGitHubTestAuth(cmd *cobra.Command, args []string) error {
+func runGitHubTestAuth(repos []string, webhooks bool) error {
 	cfg, err := config.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	if cfg.GitHub.Token == "" {
-		fmt.Fprintln(os.Stderr, "Error: No GitHub token configured.")
+	if cfg.GitHub.Token == "" && cfg.GitHub.AppID == 0 {
+		fmt.Fprintln(os.Stderr, "Error: No GitHub credentials configured.")
 		fmt.Fprintln(os.Stderr, "")
-		fmt.Fprintln(os.Stderr, "Set the token via:")
+		fmt.Fprintln(os.Stderr, "Set a token via:")
 		fmt.Fprintln(os.Stderr, "  - Environment variable: GITHUB_TOKEN")
 		fmt.Fprintln(os.Stderr, "  - Config file: github.token in config.yaml")
-		return fmt.Errorf("no GitHub token configured")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Or configure a GitHub App via github.app_id, github.installation_id")
+		fmt.Fprintln(os.Stderr, "and github.private_key_file in config.yaml")
+		return fmt.Errorf("no GitHub credentials configured")
 	}
 
-	client := github.NewClient(
-		cfg.GitHub.Token,
-		github.WithRateLimitBuffer(cfg.GitHub.RateLimitBuffer),
-	)
+	client, err := cfg.GitHubClient()
+	if err != nil {
+		return err
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	fmt.Println("Testing GitHub authentication...")
 
+	if client.IsApp() {
+		count, err := client.InstallationRepoCount(ctx)
+		if err != nil {
+			return fmt.Errorf("authentication failed: %w", err)
+		}
+
+		fmt.Println()
+		fmt.Printf("Authenticated as: GitHub App %d (installation %d)\n", cfg.GitHub.AppID, cfg.GitHub.InstallationID)
+		fmt.Printf("Repositories:     %d\n", count)
+		fmt.Println()
+		fmt.Println("GitHub authentication successful!")
+		return checkRepoCapabilities(cfg, client, repos, webhooks)
+	}
+
 	user, err := client.TestAuth(ctx)
 	if err != nil {
 		return fmt.Errorf("authentication failed: %w", err)
@@ -78,6 +120,74 @@ func runGitHubTestAuth(cmd *cobra.Command, args []string) error {
 	fmt.Println()
 	fmt.Println("GitHub authentication successful!")
 
+	return checkRepoCapabilities(cfg, client, repos, webhooks)
+}
+
+// checkRepoCapabilities checks repos, or every configured project's
+// repository if none are given, and prints which capabilities the
+// credentials hold; webhooks adds webhook admin access. It fails if any
+// capability is missing or could not be checked.
+func checkRepoCapabilities(cfg *config.Config, client *github.Client, repos []string, webhooks bool) error {
+	if len(repos) == 0 {
+		var err error
+		repos, err = cfg.GitHubRepos()
+		if err != nil {
+			return err
+		}
+	}
+	if len(repos) == 0 {
+		fmt.Println()
+		fmt.Println("No GitHub repositories configured; skipping permission checks.")
+		return nil
+	}
+
+	caps := github.RequiredCapabilities()
+	if webhooks {
+		caps = append(caps, github.CapabilityHooksAdmin)
+	}
+
+	fmt.Println()
+	fmt.Println("Repository permissions:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprint(w, "  REPOSITORY")
+	for _, c := range caps {
+		fmt.Fprintf(w, "\t%s", strings.ToUpper(string(c)))
+	}
+	fmt.Fprintln(w)
+
+	var problems []string
+	for _, full := range repos {
+		owner, repo, ok := strings.Cut(full, "/")
+		if !ok || owner == "" || repo == "" {
+			return fmt.Errorf("invalid repository %q, want owner/repo", full)
+		}
+		fmt.Fprintf(w, "  %s", full)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		checks := client.CheckCapabilities(ctx, owner, repo, caps...)
+		cancel()
+		for _, check := range checks {
+			switch {
+			case check.Err != nil:
+				fmt.Fprint(w, "\t?")
+				problems = append(problems, fmt.Sprintf("%s: %s: %v", full, check.Capability, check.Err))
+			case check.Allowed:
+				fmt.Fprint(w, "\tyes")
+			default:
+				fmt.Fprint(w, "\tNO")
+				problems = append(problems, fmt.Sprintf("%s: missing %s", full, check.Capability))
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+
+	if len(problems) > 0 {
+		fmt.Println()
+		for _, p := range problems {
+			fmt.Println("  " + p)
+		}
+		return fmt.Errorf("%d permission check(s) failed", len(problems))
+	}
 	return nil
 }
 
@@ -85,7 +195,7 @@ func newGitHubWebhookURLCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "webhook-url",
 		Short: "Print the webhook URL for GitHub configuration",
-		Long:  `Prints the webhook URL that should be configured in GitHub repository settings.`,
+		Long:  `Prints the webhook URL GitHub should deliver to; setup-webhook registers it.`,
 		RunE:  runGitHubWebhookURL,
 	}
 }
@@ -96,21 +206,19 @@ func runGitHubWebhookURL(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	addr := cfg.Server.Addr
-	if addr == "0.0.0.0" || addr == "" {
-		addr = "YOUR_SERVER_IP"
+	server := cfg.Server
+	if server.PublicURL == "" && (server.Addr == "0.0.0.0" || server.Addr == "") {
+		server.Addr = "YOUR_SERVER_IP"
 	}
 
-	fmt.Printf("Webhook URL: http://%s:%d/webhook/github\n", addr, cfg.Server.Port)
-	fmt.Println()
-	fmt.Println("Configure this URL in your GitHub repository:")
-	fmt.Println("  Settings > Webhooks > Add webhook")
+	fmt.Printf("Webhook URL: %s\n", server.WebhookURL())
 	fmt.Println()
-	fmt.Println("Required settings:")
-	fmt.Println("  - Payload URL: <the URL above>")
-	fmt.Println("  - Content type: application/json")
-	fmt.Println("  - Secret: <your webhook secret>")
-	fmt.Println("  - Events: Issues, Issue comments, Pull requests, Pull request reviews")
+	fmt.Println("Register it on a repository with:")
+	fmt.Println("  manfred github setup-webhook owner/repo")
+	if cfg.Server.PublicURL == "" {
+		fmt.Println()
+		fmt.Println("Set server.public_url if GitHub reaches the server through a proxy or tunnel.")
+	}
 
 	if cfg.GitHub.WebhookSecret == "" {
 		fmt.Println()
@@ -120,3 +228,158 @@ func runGitHubWebhookURL(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func newGitHubSetupWebhookCmd() *cobra.Command {
+	var hookURL string
+
+	cmd := &cobra.Command{
+		Use:   "setup-webhook <owner/repo>...",
+		Short: "Create or update the repository webhook",
+		Long: `Creates the webhook MANFRED needs on each repository, or updates the existing
+one with the same URL (or the same path on another host): the server's webhook
+URL, github.webhook_secret, JSON content and the events MANFRED handles.
+
+The URL is server.public_url followed by /webhook/github, or the listen address
+if public_url is not set. The token needs webhook admin access to the
+repositories (see github test-auth --webhooks).`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runGitHubSetupWebhook(args, hookURL)
+		},
+	}
+
+	cmd.Flags().StringVar(&hookURL, "url", "", "Webhook URL (default: from server.public_url)")
+
+	return cmd
+}
+
+func runGitHubSetupWebhook(repos []string, hookURL string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if hookURL == "" {
+		local := []string{"", "0.0.0.0", "127.0.0.1", "localhost"}
+		if cfg.Server.PublicURL == "" && slices.Contains(local, cfg.Server.Addr) {
+			return fmt.Errorf("GitHub cannot reach %s; set server.public_url or pass --url", cfg.Server.WebhookURL())
+		}
+		hookURL = cfg.Server.WebhookURL()
+	}
+	if cfg.GitHub.WebhookSecret == "" {
+		fmt.Fprintln(os.Stderr, "Warning: no webhook secret configured, signatures will not be verified")
+		fmt.Fprintln(os.Stderr, "Set MANFRED_WEBHOOK_SECRET or github.webhook_secret in config.yaml")
+	}
+
+	client, err := cfg.GitHubClient()
+	if err != nil {
+		return err
+	}
+
+	hook := &github.Hook{
+		Name:   "web",
+		Active: true,
+		Events: webhook.Events,
+		Config: github.HookConfig{
+			URL:         hookURL,
+			ContentType: "json",
+			Secret:      cfg.GitHub.WebhookSecret,
+			InsecureSSL: "0",
+		},
+	}
+
+	var failed int
+	for _, full := range repos {
+		owner, repo, ok := strings.Cut(full, "/")
+		if !ok || owner == "" || repo == "" {
+			return fmt.Errorf("invalid repository %q, want owner/repo", full)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		created, isNew, err := client.EnsureHook(ctx, owner, repo, hook)
+		cancel()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", full, err)
+			failed++
+			continue
+		}
+
+		action := "Updated"
+		if isNew {
+			action = "Created"
+		}
+		fmt.Printf("%s webhook %d on %s -> %s\n", action, created.ID, full, hookURL)
+	}
+
+	fmt.Printf("Events: %s\n", strings.Join(webhook.Events, ", "))
+	if failed > 0 {
+		return fmt.Errorf("failed to set up %d of %d webhook(s)", failed, len(repos))
+	}
+	return nil
+}
+
+func newGitHubSetupLabelsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "setup-labels [owner/repo]...",
+		Short: "Create the labels MANFRED uses",
+		Long: `Creates the trigger labels (triggers.labels) and the status labels MANFRED keeps
+on session issues and PRs (manfred:planning, manfred:blocked, ...) on each
+repository, or on every configured project's repository if none are given.
+Existing labels get their color and description updated.
+
+Colors and descriptions can be changed under github.labels.definitions.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runGitHubSetupLabels(args)
+		},
+	}
+}
+
+func runGitHubSetupLabels(repos []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if len(repos) == 0 {
+		if repos, err = cfg.GitHubRepos(); err != nil {
+			return err
+		}
+		if len(repos) == 0 {
+			return fmt.Errorf("no GitHub repositories configured; pass owner/repo")
+		}
+	}
+
+	client, err := cfg.GitHubClient()
+	if err != nil {
+		return err
+	}
+
+	labels := orchestrator.Labels(cfg)
+	var failed int
+	for _, full := range repos {
+		owner, repo, ok := strings.Cut(full, "/")
+		if !ok || owner == "" || repo == "" {
+			return fmt.Errorf("invalid repository %q, want owner/repo", full)
+		}
+
+		fmt.Printf("%s:\n", full)
+		for _, label := range labels {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			created, err := client.EnsureLabel(ctx, owner, repo, &label)
+			cancel()
+			switch {
+			case err != nil:
+				fmt.Fprintf(os.Stderr, "  %s: %v\n", label.Name, err)
+				failed++
+			case created:
+				fmt.Printf("  created %s\n", label.Name)
+			default:
+				fmt.Printf("  updated %s\n", label.Name)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to set up %d label(s)", failed)
+	}
+	return nil
+}
@@ -1,24 +1,317 @@
 package cli
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/mpm/manfred/internal/config"
 	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/session"
 	"github.com/spf13/cobra"
 )
 
 func newJobCmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "job <project> <prompt-file>",
 		Short: "Run a job for a project",
 		Long: `Run a Claude Code job for the specified project.
 
 The prompt file contains the task description that will be sent to Claude Code.
-Claude will work on the task inside the project's Docker container.`,
-		Args: cobra.ExactArgs(2),
-		RunE: runJob,
+Claude will work on the task inside the project's Docker container.
+
+A job with the same project, prompt and base branch as a running or
+completed job is a duplicate: job.duplicates warns (default) or skips it.`,
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeArgs(completeProjects, nil),
+		RunE:              runJob,
+	}
+
+	cmd.Flags().Bool("keep-containers", false, "Leave containers running if the job fails (clean up with 'manfred cleanup')")
+	cmd.Flags().Int("depth", 0, "Shallow clone with this many commits (overrides job.clone_depth and project clone.depth)")
+	cmd.Flags().Bool("allow-duplicate", false, "Run even if job.duplicates is skip and the job is a duplicate")
+
+	cmd.AddCommand(newJobShowCmd())
+	cmd.AddCommand(newJobDiffCmd())
+	cmd.AddCommand(newJobArtifactsCmd())
+	cmd.AddCommand(newJobReportCmd())
+
+	return cmd
+}
+
+func newJobShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show <job-id>",
+		Short: "Show what a job was asked to do and how it ran",
+		Long: `Show a job's project, status and base branch, the peak CPU and memory
+usage of its container (see job.monitor) and a summary of its changes.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeArgs(completeJobs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jobID := args[0]
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			input, err := job.LoadInput(cfg.JobsDir, jobID)
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("job not found: %s", jobID)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to load job: %w", err)
+			}
+
+			fmt.Printf("Job:       %s\n", input.JobID)
+			fmt.Printf("Project:   %s\n", input.Project)
+			fmt.Printf("Status:    %s\n", input.Status)
+			fmt.Printf("Created:   %s\n", input.CreatedAt.Format("2006-01-02 15:04:05"))
+			fmt.Printf("Branch:    %s\n", input.BaseBranch)
+			if input.BaseSHA != "" {
+				fmt.Printf("Base:      %s\n", shortSHA(input.BaseSHA))
+			}
+
+			usage, err := job.LoadResources(cfg.JobsDir, jobID)
+			if err != nil {
+				return fmt.Errorf("failed to load resource usage: %w", err)
+			}
+			if usage != nil {
+				fmt.Printf("Container: %s (%d samples)\n", usage.Container, usage.Samples)
+				fmt.Printf("Peak CPU:  %.0f%%\n", usage.PeakCPUPercent)
+				fmt.Printf("Peak mem:  %s of %s (%.0f%%)\n", formatSize(usage.PeakMemoryBytes), formatSize(usage.MemoryLimit), usage.PeakMemoryPercent())
+				fmt.Printf("Peak pids: %d\n", usage.PeakPids)
+			}
+
+			diff, _, err := job.LoadDiff(cfg.JobsDir, jobID)
+			if err == nil && diff != nil {
+				fmt.Printf("Changes:   %d file(s) changed, %d insertion(s), %d deletion(s)\n", diff.FilesChanged, diff.Insertions, diff.Deletions)
+			}
+			if result, err := job.LoadResult(cfg.JobsDir, jobID); err == nil && result != nil {
+				for _, repo := range result.Repos {
+					fmt.Printf("Repo %s: %s\n", repo.Name, repo.Summary())
+				}
+				for _, port := range result.Ports {
+					fmt.Printf("Port:      %s\n", port)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func newJobDiffCmd() *cobra.Command {
+	var statOnly bool
+
+	cmd := &cobra.Command{
+		Use:   "diff <job-id>",
+		Short: "Show the changes a job made",
+		Long: `Show the diff of a finished job against the commit it started from.
+
+The diff is read from the job directory. Diffs of session jobs are also kept in
+the database and shown from there once the job directory is gone.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeArgs(completeJobs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jobID := args[0]
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			diff, patch, err := job.LoadDiff(cfg.JobsDir, jobID)
+			if errors.Is(err, os.ErrNotExist) {
+				diff, patch, err = loadJobDiffEvent(cmd.Context(), jobID)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to load diff: %w", err)
+			}
+			if diff == nil {
+				return fmt.Errorf("no diff found for job %s", jobID)
+			}
+
+			fmt.Printf("Job:     %s\n", jobID)
+			fmt.Printf("Commits: %s..%s\n", shortSHA(diff.BaseSHA), shortSHA(diff.HeadSHA))
+			fmt.Printf("Changes: %d file(s) changed, %d insertion(s), %d deletion(s)\n", diff.FilesChanged, diff.Insertions, diff.Deletions)
+			if !statOnly && patch != "" {
+				fmt.Println()
+				fmt.Print(patch)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&statOnly, "stat", false, "Only show the summary")
+
+	return cmd
+}
+
+func newJobReportCmd() *cobra.Command {
+	var format, output string
+
+	cmd := &cobra.Command{
+		Use:   "report <job-id>",
+		Short: "Export a report of a job as Markdown or HTML",
+		Long: `Write a self-contained report of a job: its prompt and plan, the diff
+summary, commit messages, test results, cost and a timeline of the run,
+suitable to attach to change-management tickets or audits.
+
+The report is built from the job directory and written to stdout, or to the
+file given with --output.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeArgs(completeJobs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jobID := args[0]
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			report, err := job.BuildReport(cmd.Context(), cfg.JobsDir, jobID)
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("job not found: %s", jobID)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to build report: %w", err)
+			}
+			data, err := report.Write(format)
+			if err != nil {
+				return err
+			}
+
+			if output == "" {
+				_, err = os.Stdout.Write(data)
+				return err
+			}
+			if err := os.WriteFile(output, data, 0644); err != nil {
+				return fmt.Errorf("failed to write report: %w", err)
+			}
+			fmt.Printf("Report written to %s\n", output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", job.ReportMarkdown, "Report format: md or html")
+	cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions(
+		[]string{job.ReportMarkdown, job.ReportHTML}, cobra.ShellCompDirectiveNoFileComp))
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write the report to this file instead of stdout")
+
+	return cmd
+}
+
+func newJobArtifactsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "artifacts <job-id>",
+		Short: "List the artifacts collected from a job's container",
+		Long: `List the files copied out of the container after a job ran.
+
+Projects declare artifact paths in project.yml. They are stored in
+<job>/artifacts/ and can also be downloaded from the server at
+/api/v1/jobs/<job-id>/artifacts/<path>.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeArgs(completeJobs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jobID := args[0]
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			artifacts, err := job.ListArtifacts(cfg.JobsDir, jobID)
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("job not found: %s", jobID)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to list artifacts: %w", err)
+			}
+			if len(artifacts) == 0 {
+				fmt.Printf("No artifacts for job %s\n", jobID)
+				return nil
+			}
+
+			fmt.Printf("Artifacts of job %s (%s):\n\n", jobID, filepath.Join(cfg.JobsDir, jobID, "artifacts"))
+			for _, a := range artifacts {
+				fmt.Printf("  %10d  %s  %s\n", a.Size, a.ModTime.Format("2006-01-02 15:04"), a.Path)
+			}
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+// loadJobDiffEvent returns the diff recorded for a session job in the
+// database, or nil if there is none.
+func loadJobDiffEvent(ctx context.Context, jobID string) (*job.Diff, string, error) {
+	sessionStore, cleanup, err := openSessionStore(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer cleanup()
+
+	event, err := sessionStore.GetJobEvent(ctx, session.EventTypeJobDiff, jobID)
+	if err != nil || event == nil {
+		return nil, "", err
+	}
+
+	var payload struct {
+		job.Diff
+		Patch     string `json:"patch"`
+		Truncated bool   `json:"truncated"`
+		PatchFile string `json:"patch_file"`
+		PatchURL  string `json:"patch_url"`
+	}
+	if err := json.Unmarshal([]byte(event.Payload), &payload); err != nil {
+		return nil, "", fmt.Errorf("parse diff event: %w", err)
+	}
+	if payload.Truncated && payload.PatchFile != "" {
+		if patch, err := os.ReadFile(payload.PatchFile); err == nil {
+			return &payload.Diff, string(patch), nil
+		}
+	}
+	if payload.Truncated && payload.PatchURL != "" {
+		payload.Patch += fmt.Sprintf("\n[patch truncated, full patch: %s]\n", payload.PatchURL)
+	} else if payload.Truncated {
+		payload.Patch += "\n[patch truncated]\n"
+	}
+	return &payload.Diff, payload.Patch, nil
+}
+
+// shortSHA abbreviates a commit SHA for display.
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+// pullRequestClient returns the GitHub client that opens the pull requests
+// of a project with several repositories, nil for other projects or when
+// GitHub is not configured.
+func pullRequestClient(cfg *config.Config, project string) job.PullRequestClient {
+	projectConfig, err := cfg.ProjectConfig(project)
+	if err != nil || len(projectConfig.Repos) == 0 {
+		return nil
+	}
+	client, err := cfg.GitHubClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: not opening pull requests: %v\n", err)
+		return nil
+	}
+	return client
+}
+
+// applyKeepContainersFlag overrides job.keep_containers from the config when
+// --keep-containers was passed.
+func applyKeepContainersFlag(cmd *cobra.Command, cfg *config.Config) {
+	if cmd.Flags().Changed("keep-containers") {
+		cfg.Job.KeepContainers, _ = cmd.Flags().GetBool("keep-containers")
 	}
 }
 
@@ -31,6 +324,13 @@ func runJob(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
+	applyKeepContainersFlag(cmd, cfg)
+	if allow, _ := cmd.Flags().GetBool("allow-duplicate"); allow && cfg.Job.Duplicates == config.DuplicatesSkip {
+		cfg.Job.Duplicates = config.DuplicatesWarn
+	}
+	if err := cfg.ValidateJobs(); err != nil {
+		return err
+	}
 
 	// Read prompt
 	prompt, err := os.ReadFile(promptFile)
@@ -44,11 +344,22 @@ func runJob(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create runner: %w", err)
 	}
 
-	j, err := runner.Run(cmd.Context(), projectName, string(prompt))
+	depth, _ := cmd.Flags().GetInt("depth")
+	j, err := runner.RunWithOptions(cmd.Context(), projectName, string(prompt), job.RunOptions{
+		CloneDepth:   depth,
+		PullRequests: pullRequestClient(cfg, projectName),
+	})
+	var duplicate *job.DuplicateError
+	if errors.As(err, &duplicate) {
+		return fmt.Errorf("skipped: %w; run it anyway with --allow-duplicate", err)
+	}
 	if err != nil {
 		return fmt.Errorf("job failed: %w", err)
 	}
 
+	if j.DuplicateOf != "" {
+		fmt.Printf("Note: job %s is a duplicate of job %s\n", j.ID, j.DuplicateOf)
+	}
 	if j.Status == job.StatusCompleted {
 		fmt.Printf("Job %s completed successfully\n", j.ID)
 	} else {
@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/job"
 	"github.com/mpm/manfred/internal/project"
 	"github.com/spf13/cobra"
 )
@@ -19,19 +23,25 @@ func newProjectCmd() *cobra.Command {
 	cmd.AddCommand(newProjectInitCmd())
 	cmd.AddCommand(newProjectListCmd())
 	cmd.AddCommand(newProjectShowCmd())
+	cmd.AddCommand(newProjectSoakCmd())
 
 	return cmd
 }
 
 func newProjectInitCmd() *cobra.Command {
-	var repoURL string
+	var repoURL, templateName string
 
 	cmd := &cobra.Command{
 		Use:   "init <name>",
 		Short: "Initialize a new project",
 		Long: `Initialize a new project by cloning a repository.
 
-Creates a project directory with project.yml configuration.`,
+Creates a project directory with project.yml configuration.
+
+With --template, a repository without a compose file gets a
+docker-compose.yml for its stack, running a devcontainer-style image with the
+stack's toolchain (plus PostgreSQL for rails), and project.yml gets the
+stack's test command. Templates: go, node, rails.`,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			name := args[0]
@@ -46,18 +56,27 @@ Creates a project directory with project.yml configuration.`,
 			}
 
 			init := project.NewInitializer(cfg)
-			if err := init.Init(cmd.Context(), name, repoURL); err != nil {
+			result, err := init.Init(cmd.Context(), name, repoURL, project.InitOptions{Template: templateName})
+			if err != nil {
 				return err
 			}
 
 			fmt.Printf("Project %s initialized successfully\n", name)
 			fmt.Printf("Location: %s\n", filepath.Join(cfg.ProjectsDir, name))
+			switch {
+			case result.ComposeGenerated:
+				fmt.Printf("Generated %s from the %s template (untracked in repository/; review it)\n", result.ComposeFile, templateName)
+			case templateName != "":
+				fmt.Printf("Kept the repository's %s; the %s template only set test.command\n", result.ComposeFile, templateName)
+			}
 			return nil
 		},
 	}
 
 	cmd.Flags().StringVar(&repoURL, "repo", "", "Git repository URL (required)")
+	cmd.Flags().StringVar(&templateName, "template", "", "Stack template for repositories without a compose file ("+strings.Join(project.TemplateNames(), ", ")+")")
 	cmd.MarkFlagRequired("repo")
+	cmd.RegisterFlagCompletionFunc("template", cobra.FixedCompletions(project.TemplateNames(), cobra.ShellCompDirectiveNoFileComp))
 
 	return cmd
 }
@@ -96,9 +115,10 @@ func newProjectListCmd() *cobra.Command {
 
 func newProjectShowCmd() *cobra.Command {
 	return &cobra.Command{
-		Use:   "show <name>",
-		Short: "Show project configuration",
-		Args:  cobra.ExactArgs(1),
+		Use:               "show <name>",
+		Short:             "Show project configuration",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeArgs(completeProjects),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			name := args[0]
 
@@ -119,11 +139,150 @@ func newProjectShowCmd() *cobra.Command {
 			if projCfg.DefaultBranch != "" {
 				fmt.Printf("Default Branch: %s\n", projCfg.DefaultBranch)
 			}
-			fmt.Printf("Compose File: %s\n", projCfg.Docker.ComposeFile)
+			if err := cfg.ApplyDefaultImage(name, projCfg); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+			if projCfg.Docker.Fallback {
+				fmt.Printf("Image: %s (job.default_image; no %s)\n", projCfg.Docker.Image, projCfg.Docker.ComposeFile)
+			} else if projCfg.Docker.Native() {
+				fmt.Printf("Image: %s\n", projCfg.Docker.Image)
+			} else {
+				fmt.Printf("Compose File: %s\n", projCfg.Docker.ComposeFile)
+			}
 			fmt.Printf("Main Service: %s\n", projCfg.Docker.MainService)
 			fmt.Printf("Workdir: %s\n", projCfg.Docker.Workdir)
+			fmt.Printf("Planning: %s\n", cfg.PlanningMode(projCfg))
+
+			detect := cfg.Job.DetectTests
+			if projCfg.Test.Detect != nil {
+				detect = *projCfg.Test.Detect
+			}
+			switch {
+			case projCfg.Test.Command != "":
+				fmt.Printf("Test Command: %s\n", projCfg.Test.Command)
+			case detect:
+				// Cloned projects have no local repository to look at;
+				// their jobs detect the command in the workspace
+				repoPath := cfg.ProjectRepositoryPath(name)
+				if _, err := os.Stat(repoPath); err != nil {
+					fmt.Println("Test Command: (auto-detected per job)")
+					break
+				}
+				commands := job.DetectTestCommands(repoPath)
+				if len(commands) == 0 {
+					fmt.Println("Test Command: (none detected)")
+					break
+				}
+				fmt.Printf("Test Command: %s (auto-detected)\n", job.JoinCommands(commands))
+				for _, c := range commands {
+					fmt.Printf("  %-8s %s\n", c.Stack, c.Command)
+				}
+				fmt.Println("Pin it with test.command in project.yml")
+			}
+
+			return nil
+		},
+	}
+}
+
+func newProjectSoakCmd() *cobra.Command {
+	var (
+		iterations int
+		promptFile string
+		minScore   float64
+	)
+
+	cmd := &cobra.Command{
+		Use:   "soak <name>",
+		Short: "Repeat a trivial job to measure how stable a project's environment is",
+		Long: `Runs a trivial, known-good job for a project several times in a row and
+reports a stability score: the percentage of runs that completed with passing
+tests. Failed runs are classified (compose, timeout, clone, claude, tests,
+other) to tell environment flakiness from real failures.
+
+The job asks Claude to add a MANFRED_SOAK.md file unless --prompt-file gives
+a prompt of its own. Soak jobs are never pushed, are not checked for
+duplicates and send no notifications.
+
+With --min-score, the command fails when the score is below it, e.g. to gate
+a project in CI before teams rely on it.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeArgs(completeProjects),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if iterations < 1 {
+				return fmt.Errorf("--iterations must be at least 1")
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			cfg.Job.Duplicates = config.DuplicatesOff
+			cfg.Notify = config.NotifyConfig{}
+
+			prompt := job.DefaultSoakPrompt
+			if promptFile != "" {
+				data, err := os.ReadFile(promptFile)
+				if err != nil {
+					return fmt.Errorf("failed to read prompt file: %w", err)
+				}
+				prompt = string(data)
+			}
+
+			runner, err := job.NewRunner(cfg)
+			if err != nil {
+				return fmt.Errorf("failed to create runner: %w", err)
+			}
+			defer runner.Close()
+
+			var results []string
+			report, err := runner.Soak(cmd.Context(), name, prompt, iterations, func(run job.SoakRun) {
+				result := "passed"
+				if !run.Passed {
+					result = "FAILED (" + run.Failure + ")"
+				}
+				results = append(results, fmt.Sprintf("%3d  %-24s  %-8s  %s", run.Iteration, run.JobID, run.Duration.Round(time.Second), result))
+				if run.Error != "" {
+					results = append(results, "     "+run.Error)
+				}
+			})
+			if report == nil {
+				return err
+			}
 
+			fmt.Printf("\nSoak test of %s: %d run(s)\n", name, len(report.Runs))
+			for _, line := range results {
+				fmt.Println(line)
+			}
+			if len(report.Runs) > 0 {
+				shortest, average, longest := report.Durations()
+				fmt.Printf("\nDuration:  %s min, %s avg, %s max\n",
+					shortest.Round(time.Second), average.Round(time.Second), longest.Round(time.Second))
+				if failures := report.Failures(); len(failures) > 0 {
+					classes := make([]string, 0, len(failures))
+					for class, n := range failures {
+						classes = append(classes, fmt.Sprintf("%s %d", class, n))
+					}
+					sort.Strings(classes)
+					fmt.Printf("Failures:  %s\n", strings.Join(classes, ", "))
+				}
+				fmt.Printf("Stability: %.0f%% (%d/%d passed)\n", report.Score(), report.Passed(), len(report.Runs))
+			}
+			if err != nil {
+				return err
+			}
+
+			if report.Score() < minScore {
+				return fmt.Errorf("stability score %.0f%% is below --min-score %.0f%%", report.Score(), minScore)
+			}
 			return nil
 		},
 	}
+
+	cmd.Flags().IntVarP(&iterations, "iterations", "n", 5, "Number of jobs to run")
+	cmd.Flags().StringVar(&promptFile, "prompt-file", "", "Prompt of the known-good job (default: add a MANFRED_SOAK.md file)")
+	cmd.Flags().Float64Var(&minScore, "min-score", 0, "Fail when the stability score (0-100) is below this")
+
+	return cmd
 }
@@ -1,9 +1,12 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"os"
 
+	"github.com/mpm/manfred/internal/audit"
+	"github.com/mpm/manfred/internal/config"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -44,40 +47,33 @@ and collects results including commit messages and code changes.`,
 	rootCmd.AddCommand(newProjectCmd())
 	rootCmd.AddCommand(newServeCmd())
 	rootCmd.AddCommand(newSessionCmd())
+	rootCmd.AddCommand(newAPIKeyCmd())
+	rootCmd.AddCommand(newAuditCmd())
+	rootCmd.AddCommand(newSearchCmd())
 	rootCmd.AddCommand(newGitHubCmd())
+	rootCmd.AddCommand(newWebhookCmd())
+	rootCmd.AddCommand(newSnapshotCmd())
+	rootCmd.AddCommand(newCleanupCmd())
+	rootCmd.AddCommand(newGCCmd())
+	rootCmd.AddCommand(newDockerCmd())
+	rootCmd.AddCommand(newDBCmd())
+	rootCmd.AddCommand(newConfigCmd())
+	rootCmd.AddCommand(newSecretsCmd())
+	rootCmd.AddCommand(newDoctorCmd())
+	rootCmd.AddCommand(newBundleCmd())
+	rootCmd.AddCommand(newCompletionCmd())
 
 	cobra.OnInitialize(initConfig)
 }
 
 func initConfig() {
-	if cfgFile != "" {
-		viper.SetConfigFile(cfgFile)
-	} else {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "Warning: could not find home directory:", err)
-			return
-		}
-
-		// Search for config in ~/.manfred/
-		viper.AddConfigPath(home + "/.manfred")
-		viper.AddConfigPath(".")
-		viper.SetConfigName("config")
-		viper.SetConfigType("yaml")
-	}
-
-	// Environment variables
-	viper.SetEnvPrefix("MANFRED")
-	viper.AutomaticEnv()
-
-	// Read config file (ignore if not found)
-	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			fmt.Fprintln(os.Stderr, "Warning: error reading config:", err)
-		}
+	if err := config.ReadFile(cfgFile); err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: error reading config:", err)
 	}
 }
 
+// Execute runs the command line. What commands change is audited as done
+// by the OS user.
 func Execute() error {
-	return rootCmd.Execute()
+	return rootCmd.ExecuteContext(audit.WithActor(context.Background(), audit.CLIActor()))
 }
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/search"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/spf13/cobra"
+)
+
+func newSearchCmd() *cobra.Command {
+	var kinds []string
+	var query search.Query
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "search <words>...",
+		Short: "Search past tickets, sessions and jobs",
+		Long: `Full-text search across ticket prompts and entries, session plans (archived
+sessions included) and job prompts, plans and commit messages. Results
+contain all words, in any form ("tests" finds "test"), best match first,
+with the matches in [brackets].
+
+The index is kept in the database and brought up to date before each search.
+'manfred serve' serves the same search at /api/v1/search.`,
+		Example: `  manfred search flaky login test
+  manfred search "rate limit" --kind job --project widgets
+  manfred search webhooks --project acme/widgets --json`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query.Text = strings.Join(args, " ")
+			for _, k := range kinds {
+				kind, err := search.ParseKind(k)
+				if err != nil {
+					return err
+				}
+				query.Kinds = append(query.Kinds, kind)
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			db, err := openDatabase(cmd.Context(), cfg)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			ix := search.NewIndex(db, cfg.TicketsDir, cfg.JobsDir, session.NewSQLStore(db))
+			results, err := ix.Search(cmd.Context(), query)
+			if err != nil {
+				return err
+			}
+
+			if jsonOutput {
+				if results == nil {
+					results = []search.Result{}
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(results)
+			}
+			if len(results) == 0 {
+				fmt.Println("No matches")
+				return nil
+			}
+			for i, r := range results {
+				if i > 0 {
+					fmt.Println()
+				}
+				fmt.Printf("%-7s  %s", r.Kind, r.Ref)
+				if r.Project != "" {
+					fmt.Printf("  (%s)", r.Project)
+				}
+				fmt.Printf("\n         %s\n         %s\n", r.Title, r.Snippet)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&kinds, "kind", nil, "Only these kinds: ticket, session, job")
+	cmd.Flags().StringVar(&query.Project, "project", "", "Only this project (tickets, jobs) or owner/repo (sessions)")
+	cmd.Flags().IntVar(&query.Limit, "limit", 20, "Show at most this many results")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+
+	return cmd
+}
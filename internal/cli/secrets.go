@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+func newSecretsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "Manage encrypted tokens and credentials",
+		Long: `Commands for the encrypted secrets store, which keeps the Anthropic API key,
+the GitHub token, the webhook secret and the Claude credentials encrypted at
+rest instead of in plaintext in config.yaml and the data directory.
+
+The store key lives in the OS keyring by default (secrets.key: keyring), or in
+a key file (file) or the MANFRED_SECRETS_KEY variable (env). Settings in the
+config file and the environment take precedence over stored secrets.`,
+	}
+
+	cmd.AddCommand(newSecretsSetCmd())
+	cmd.AddCommand(newSecretsUnlockCmd())
+
+	return cmd
+}
+
+func newSecretsSetCmd() *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "set <name> [value]",
+		Short: "Encrypt and store a secret",
+		Long: fmt.Sprintf(`Encrypts a secret and stores it. The value is read from --file, the argument,
+or else stdin, so it need not appear in the shell history. The store and its
+key are created on first use.
+
+Names: %s`, strings.Join(secretNames(), ", ")),
+		Example: `  manfred secrets set github_token
+  manfred secrets set claude_credentials --file ~/.manfred/config/.credentials.json`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			setting, ok := secrets.Names[name]
+			if !ok {
+				return fmt.Errorf("unknown secret %q (want one of %s)", name, strings.Join(secretNames(), ", "))
+			}
+
+			var value string
+			switch {
+			case file != "":
+				data, err := os.ReadFile(file)
+				if err != nil {
+					return fmt.Errorf("failed to read %s: %w", file, err)
+				}
+				value = string(data)
+			case len(args) == 2:
+				value = args[1]
+			default:
+				fmt.Fprintf(os.Stderr, "Reading %s from stdin...\n", name)
+				data, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					return fmt.Errorf("failed to read stdin: %w", err)
+				}
+				value = strings.TrimRight(string(data), "\r\n")
+			}
+			if value == "" {
+				return fmt.Errorf("empty value for %s", name)
+			}
+
+			cfg, err := config.LoadUnvalidated()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			store, err := cfg.SecretsStore()
+			if err != nil {
+				return err
+			}
+			if err := store.Set(name, value); err != nil {
+				return fmt.Errorf("failed to store secret: %w", err)
+			}
+			fmt.Printf("Stored %s in %s\n", name, store.Path())
+
+			if plaintextSecret(cfg, name) {
+				fmt.Printf("%s is still set in plaintext and takes precedence; remove it.\n", setting)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "", "Read the value from a file")
+
+	return cmd
+}
+
+func newSecretsUnlockCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "unlock",
+		Short: "Check that the secrets store can be decrypted",
+		Long: `Fetches the store key from its source, unlocking the OS keyring if it asks
+to, and decrypts every stored secret. Lists the stored secrets and the
+settings they supply.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadUnvalidated()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			store, err := cfg.SecretsStore()
+			if err != nil {
+				return err
+			}
+			if !store.Exists() {
+				fmt.Printf("No secrets stored at %s\n", store.Path())
+				return nil
+			}
+
+			names, err := store.Unlock()
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Unlocked %s\n\n", store.Path())
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "SECRET\tSETTING\tSTATUS")
+			for _, name := range names {
+				status := "in use"
+				if plaintextSecret(cfg, name) {
+					status = "overridden by plaintext setting"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", name, secrets.Names[name], status)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+// secretNames returns the known secret names, sorted.
+func secretNames() []string {
+	var names []string
+	for name := range secrets.Names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// plaintextSecret reports whether the setting a secret supplies is also set
+// in plaintext, in the config file, the environment or, for the Claude
+// credentials, on disk; the plaintext value wins.
+func plaintextSecret(cfg *config.Config, name string) bool {
+	switch name {
+	case secrets.AnthropicAPIKey:
+		return cfg.Credentials.AnthropicAPIKey != ""
+	case secrets.GitHubToken:
+		return cfg.GitHub.Token != ""
+	case secrets.WebhookSecret:
+		return cfg.GitHub.WebhookSecret != ""
+	case secrets.ClaudeCredentials:
+		return cfg.ClaudeCredentialsExist()
+	}
+	return false
+}
@@ -1,8 +1,26 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"net/netip"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/mpm/manfred/internal/apikey"
+	"github.com/mpm/manfred/internal/audit"
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/orchestrator"
+	"github.com/mpm/manfred/internal/poller"
+	"github.com/mpm/manfred/internal/queue"
+	"github.com/mpm/manfred/internal/search"
+	"github.com/mpm/manfred/internal/server"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/snapshot"
+	"github.com/mpm/manfred/internal/webhook"
 	"github.com/spf13/cobra"
 )
 
@@ -13,13 +31,151 @@ func newServeCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "serve",
 		Short: "Start the web server",
-		Long: `Start the MANFRED web server with admin UI.
+		Long: `Start the MANFRED web server.
 
-Provides a REST API and web interface for managing jobs,
-tickets, and projects.`,
+Receives GitHub webhooks at /webhook/github and drives sessions through
+their workflow phases. With github.poll_interval set, it also polls the
+GitHub API for the same events, for hosts that cannot receive webhooks.
+
+Job artifacts are served at /api/v1/jobs/<job-id>/artifacts, the live log
+of a running job as server-sent events at /api/v1/jobs/<job-id>/log, job
+queue metrics at /api/v1/queue and sessions at /api/v1/sessions. API keys
+(manfred apikey) grant read, operator or admin access to them; server.auth
+grants admin access with a bearer token or basic auth. Until either exists,
+the API is open. server.tls serves HTTPS, and server.webhook_allow only
+accepts webhooks from GitHub's hook ranges or listed CIDRs. With
+queue.autoscale.webhook_url set, scale_up and scale_down events are posted
+there when queue thresholds are crossed.
+
+On startup, session phases interrupted by a restart are resumed (see
+job.resume). Every job.reaper.interval, sessions left in a job phase by a
+process that died while their job ran are moved to the error phase, with a
+comment, once no container of their job runs. Every approval.interval,
+plans awaiting approval get their reminder or are aborted as the approval
+policy says.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// TODO: Implement web server
-			return fmt.Errorf("not implemented yet")
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if !cmd.Flags().Changed("addr") {
+				addr = cfg.Server.Addr
+			}
+			if !cmd.Flags().Changed("port") {
+				port = cfg.Server.Port
+			}
+
+			if cfg.GitHub.Token == "" && cfg.GitHub.AppID == 0 {
+				return fmt.Errorf("no GitHub token or GitHub App configured")
+			}
+			if err := cfg.ValidateJobs(); err != nil {
+				return err
+			}
+			if err := checkDockerVersions(cmd.Context()); err != nil {
+				return err
+			}
+			if cfg.GitHub.WebhookSecret == "" && cfg.GitHub.PollInterval == 0 {
+				fmt.Fprintln(os.Stderr, "Warning: no webhook secret configured, signatures will not be verified")
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			db, err := openDatabase(ctx, cfg)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			sessionStore := session.NewSQLStore(db)
+
+			if url := cfg.Database.Replication.URL; url != "" && cfg.Database.Replication.Interval > 0 {
+				replicated := make(chan struct{})
+				go func() {
+					defer close(replicated)
+					db.RunReplication(ctx, url, cfg.Database.Replication.Interval, func(err error) {
+						fmt.Fprintln(os.Stderr, "Warning: database replication failed:", err)
+					})
+				}()
+				// Runs before db.Close, so the final replica is complete
+				defer func() { <-replicated }()
+			}
+
+			client, err := cfg.GitHubClient()
+			if err != nil {
+				return err
+			}
+
+			if cfg.Snapshot.Path != "" && cfg.Snapshot.Interval > 0 {
+				exporter := snapshot.NewExporter(cfg, sessionStore)
+				go exporter.Run(ctx, cfg.Snapshot.Path, cfg.Snapshot.Interval, func(err error) {
+					fmt.Fprintln(os.Stderr, "Warning: snapshot export failed:", err)
+				})
+			}
+
+			if cfg.Job.Retention.Enabled() && cfg.Job.Retention.Interval > 0 {
+				go runJobGC(ctx, cfg, sessionStore)
+			}
+
+			auditLog := audit.NewLog(audit.NewSQLStore(db))
+			if a := cfg.Database.Archive; a.After > 0 && a.Interval > 0 {
+				system := audit.WithActor(ctx, audit.Actor{Type: audit.ActorSystem})
+				go runSessionArchive(system, sessionStore, auditLog, a.After, a.Interval)
+			}
+			orch := orchestrator.New(cfg, sessionStore, client)
+			defer orch.Close()
+			orch.SetAudit(auditLog)
+			// Before the reaper runs, so it does not fail sessions about
+			// to be resumed
+			if cfg.Job.Resume.Enabled {
+				resumed, err := orch.ResumeInterrupted(context.WithoutCancel(ctx))
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "Warning: resuming interrupted sessions failed:", err)
+				}
+				if len(resumed) > 0 {
+					fmt.Printf("Resumed %d interrupted session(s)\n", len(resumed))
+				}
+			}
+			if cfg.Job.Reaper.Interval > 0 {
+				go orch.RunReaper(ctx, cfg.Job.Reaper.Interval, cfg.Job.Reaper.StaleAfter, func(err error) {
+					fmt.Fprintln(os.Stderr, "Warning: reaping stale sessions failed:", err)
+				})
+			}
+			if cfg.Approval.Interval > 0 {
+				system := audit.WithActor(ctx, audit.Actor{Type: audit.ActorSystem})
+				go orch.RunApprovalDeadlines(system, cfg.Approval.Interval, func(err error) {
+					fmt.Fprintln(os.Stderr, "Warning: checking approval deadlines failed:", err)
+				})
+			}
+			if uploader := orch.Uploads(); uploader != nil && uploader.Retention() > 0 && cfg.Job.Retention.Interval > 0 {
+				go runUploadPrune(ctx, uploader, cfg.Job.Retention.Interval)
+			}
+			router := webhook.NewRouter(cfg, sessionStore, client, orch)
+
+			if cfg.GitHub.PollInterval > 0 {
+				p := poller.New(cfg, sessionStore, client, router)
+				go p.Run(ctx, cfg.GitHub.PollInterval, func(err error) {
+					fmt.Fprintln(os.Stderr, "Warning: polling GitHub failed:", err)
+				})
+			}
+			if cfg.Queue.Autoscale.WebhookURL != "" {
+				autoscaler := queue.NewAutoscaler(orch.Queue(), cfg.Queue.Autoscale, cfg.Limits.HTTPTimeout)
+				go autoscaler.Run(ctx, func(err error) {
+					fmt.Fprintln(os.Stderr, "Warning: autoscaling webhook failed:", err)
+				})
+			}
+			srv := server.New(fmt.Sprintf("%s:%d", addr, port), cfg.GitHub.WebhookSecret, cfg.JobsDir, router, orch.Queue())
+			keys := apikey.NewSQLStore(db)
+			srv.SetAPIKeys(keys)
+			srv.SetSessions(sessionStore, orch)
+			srv.SetJobLogs(orch)
+			srv.SetAudit(auditLog)
+			srv.SetSearch(search.NewIndex(db, cfg.TicketsDir, cfg.JobsDir, sessionStore))
+			if err := secureServer(ctx, cfg, srv, client, keys, addr); err != nil {
+				return err
+			}
+
+			return srv.ListenAndServe(ctx)
 		},
 	}
 
@@ -28,3 +184,48 @@ tickets, and projects.`,
 
 	return cmd
 }
+
+// githubMetaRefresh is how often the GitHub hook ranges of
+// server.webhook_allow.github are fetched again.
+const githubMetaRefresh = 24 * time.Hour
+
+// secureServer applies the server.tls, server.auth and server.webhook_allow
+// settings to srv, warning when the REST API is open beyond localhost.
+func secureServer(ctx context.Context, cfg *config.Config, srv *server.Server, client *github.Client, keys apikey.Store, addr string) error {
+	if t := cfg.Server.TLS; t.Enabled() {
+		if err := srv.SetTLS(t.CertFile, t.KeyFile); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Server.Auth.Enabled() {
+		srv.SetAuth(cfg.Server.Auth)
+	} else if ip, err := netip.ParseAddr(addr); err != nil || !ip.IsLoopback() {
+		active, err := keys.CountActive(ctx)
+		if err != nil {
+			return err
+		}
+		if active == 0 {
+			fmt.Fprintln(os.Stderr, "Warning: no server.auth or API key configured, the REST API only answers read requests from this host; create a key with 'manfred apikey create'")
+		}
+	}
+
+	allow := cfg.Server.WebhookAllow
+	if !allow.Enabled() {
+		return nil
+	}
+	allowlist, err := server.NewAllowlist(allow.CIDRs, cfg.Server.TrustProxy)
+	if err != nil {
+		return err
+	}
+	if allow.GitHub {
+		if err := allowlist.Refresh(ctx, client); err != nil {
+			return err
+		}
+		go allowlist.RunRefresh(ctx, client, githubMetaRefresh, func(err error) {
+			fmt.Fprintln(os.Stderr, "Warning:", err)
+		})
+	}
+	srv.SetWebhookAllowlist(allowlist)
+	return nil
+}
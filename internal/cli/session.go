@@ -2,10 +2,19 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/mpm/manfred/internal/audit"
 	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/orchestrator"
 	"github.com/mpm/manfred/internal/session"
 	"github.com/mpm/manfred/internal/store"
 	"github.com/spf13/cobra"
@@ -13,24 +22,66 @@ import (
 
 // openSessionStore opens the database and returns a session store.
 // The caller must call the returned cleanup function when done.
-func openSessionStore(ctx context.Context) (*session.SQLiteStore, func(), error) {
+func openSessionStore(ctx context.Context) (*session.SQLStore, func(), error) {
 	cfg, err := config.Load()
 	if err != nil {
 		return nil, nil, err
 	}
 
-	db, err := store.Open(cfg.Database.Path)
+	db, err := openDatabase(ctx, cfg)
 	if err != nil {
-		return nil, nil, fmt.Errorf("open database: %w", err)
+		return nil, nil, err
+	}
+
+	cleanup := func() { db.Close() }
+	return session.NewSQLStore(db), cleanup, nil
+}
+
+// openDatabase opens and migrates the database of database.driver. With
+// database.replication.restore set, a missing SQLite database is first
+// restored from the replica.
+func openDatabase(ctx context.Context, cfg *config.Config) (*store.DB, error) {
+	if cfg.Database.Driver == string(store.DriverPostgres) {
+		db, err := store.OpenPostgres(cfg.Database.URL)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.Migrate(ctx); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("migrate database: %w", err)
+		}
+		return db, nil
+	}
+
+	replication := cfg.Database.Replication
+	if replication.Restore && replication.URL != "" {
+		if _, err := os.Stat(cfg.Database.Path); errors.Is(err, os.ErrNotExist) {
+			err := store.Restore(ctx, replication.URL, cfg.Database.Path, false)
+			switch {
+			case errors.Is(err, os.ErrNotExist):
+				fmt.Fprintf(os.Stderr, "No replica at %s, starting with an empty database\n", replication.URL)
+			case err != nil:
+				return nil, fmt.Errorf("restore database: %w", err)
+			default:
+				fmt.Fprintf(os.Stderr, "Restored database from %s\n", replication.URL)
+			}
+		}
+	}
+
+	var opts []store.Option
+	if replication.External {
+		opts = append(opts, store.WithExternalCheckpoints())
+	}
+	db, err := store.Open(cfg.Database.Path, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
 	}
 
 	if err := db.Migrate(ctx); err != nil {
 		db.Close()
-		return nil, nil, fmt.Errorf("migrate database: %w", err)
+		return nil, fmt.Errorf("migrate database: %w", err)
 	}
-
-	cleanup := func() { db.Close() }
-	return session.NewSQLiteStore(db), cleanup, nil
+	return db, nil
 }
 
 func newSessionCmd() *cobra.Command {
@@ -42,7 +93,13 @@ func newSessionCmd() *cobra.Command {
 	cmd.AddCommand(newSessionListCmd())
 	cmd.AddCommand(newSessionShowCmd())
 	cmd.AddCommand(newSessionDeleteCmd())
+	cmd.AddCommand(newSessionAbortCmd())
+	cmd.AddCommand(newSessionApproveCmd())
+	cmd.AddCommand(newSessionEditPlanCmd())
+	cmd.AddCommand(newSessionRetryCmd())
+	cmd.AddCommand(newSessionSetPhaseCmd())
 	cmd.AddCommand(newSessionStatsCmd())
+	cmd.AddCommand(newSessionPruneCmd())
 
 	return cmd
 }
@@ -52,6 +109,7 @@ func newSessionListCmd() *cobra.Command {
 		repo       string
 		phase      string
 		activeOnly bool
+		archived   bool
 		limit      int
 	)
 
@@ -90,9 +148,20 @@ func newSessionListCmd() *cobra.Command {
 				filter.Phase = &p
 			}
 
-			sessions, err := sessionStore.List(cmd.Context(), filter)
-			if err != nil {
-				return err
+			var sessions []session.Session
+			if archived {
+				list, err := sessionStore.ListArchived(cmd.Context(), filter)
+				if err != nil {
+					return err
+				}
+				for _, a := range list {
+					sessions = append(sessions, a.Session)
+				}
+			} else {
+				sessions, err = sessionStore.List(cmd.Context(), filter)
+				if err != nil {
+					return err
+				}
 			}
 
 			if len(sessions) == 0 {
@@ -122,8 +191,14 @@ func newSessionListCmd() *cobra.Command {
 	}
 
 	cmd.Flags().StringVar(&repo, "repo", "", "Filter by repository (owner/repo)")
-	cmd.Flags().StringVar(&phase, "phase", "", "Filter by phase")
+	phases := make([]string, 0, len(session.AllPhases()))
+	for _, p := range session.AllPhases() {
+		phases = append(phases, string(p))
+	}
+	cmd.Flags().StringVar(&phase, "phase", "", "Filter by phase ("+strings.Join(phases, ", ")+")")
+	cmd.RegisterFlagCompletionFunc("phase", completePhases)
 	cmd.Flags().BoolVar(&activeOnly, "active", false, "Show only active sessions")
+	cmd.Flags().BoolVar(&archived, "archived", false, "Show archived sessions instead")
 	cmd.Flags().IntVar(&limit, "limit", 0, "Maximum number of sessions to show")
 
 	return cmd
@@ -131,11 +206,18 @@ func newSessionListCmd() *cobra.Command {
 
 func newSessionShowCmd() *cobra.Command {
 	var showEvents bool
+	var eventTypes []string
+	var since, until time.Duration
+	var filter session.EventFilter
 
 	cmd := &cobra.Command{
-		Use:   "show <session-id>",
-		Short: "Show session details",
-		Args:  cobra.ExactArgs(1),
+		Use:               "show <session-id>",
+		Short:             "Show session details",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeArgs(completeSessions),
+		Example: `  manfred session show acme-widgets-issue-42 --events
+  manfred session show acme-widgets-issue-42 --event-type phase_change,error --since 24h
+  manfred session show acme-widgets-issue-42 --events --limit 20 --offset 40`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			sessionID := args[0]
 
@@ -164,8 +246,33 @@ func newSessionShowCmd() *cobra.Command {
 			if s.ContainerID != nil {
 				fmt.Printf("Container:    %s\n", *s.ContainerID)
 			}
+			if e := s.Execution; !e.IsZero() {
+				fmt.Printf("Execution:    %s", e.Step)
+				if e.JobID != "" {
+					fmt.Printf(" (job %s)", e.JobID)
+				}
+				fmt.Printf(" on %s pid %d\n", e.Host, e.PID)
+			}
 			fmt.Printf("Created:      %s\n", s.CreatedAt.Format("2006-01-02 15:04:05"))
 			fmt.Printf("Last Active:  %s\n", s.LastActivity.Format("2006-01-02 15:04:05"))
+			fmt.Printf("Revisions:    %d\n", s.Metrics.RevisionRounds)
+			fmt.Printf("Plan Changes: %d\n", s.Metrics.PlanRevisions)
+			fmt.Printf("Approval:     %s waiting\n", s.Metrics.AwaitingApproval())
+			fmt.Printf("Review:       %s in review\n", s.Metrics.InReview())
+			if c := s.PlanChecklist; !c.IsZero() {
+				if c.Risk != "" {
+					fmt.Printf("Plan Risk:    %s\n", c.Risk)
+				}
+				if c.Size != "" {
+					fmt.Printf("Plan Size:    %s\n", c.Size)
+				}
+				if c.Migration != nil {
+					fmt.Printf("Migration:    %t\n", *c.Migration)
+				}
+				if len(c.Files) > 0 {
+					fmt.Printf("Plan Files:   %s\n", strings.Join(c.Files, ", "))
+				}
+			}
 
 			if s.ErrorMessage != nil {
 				fmt.Printf("\nError: %s\n", *s.ErrorMessage)
@@ -185,8 +292,22 @@ func newSessionShowCmd() *cobra.Command {
 				}
 			}
 
+			// Any event filter implies --events
+			for _, name := range []string{"event-type", "since", "until", "limit", "offset"} {
+				showEvents = showEvents || cmd.Flags().Changed(name)
+			}
 			if showEvents {
-				events, err := sessionStore.GetEvents(cmd.Context(), sessionID)
+				for _, t := range eventTypes {
+					filter.Types = append(filter.Types, session.EventType(t))
+				}
+				if since > 0 {
+					filter.Since = time.Now().Add(-since)
+				}
+				if until > 0 {
+					filter.Until = time.Now().Add(-until)
+				}
+
+				events, err := sessionStore.GetEvents(cmd.Context(), sessionID, filter)
 				if err != nil {
 					return fmt.Errorf("get events: %w", err)
 				}
@@ -207,27 +328,38 @@ func newSessionShowCmd() *cobra.Command {
 	}
 
 	cmd.Flags().BoolVar(&showEvents, "events", false, "Show session events")
+	cmd.Flags().StringSliceVar(&eventTypes, "event-type", nil, "Only events of these types (e.g. phase_change,error)")
+	cmd.Flags().DurationVar(&since, "since", 0, "Only events newer than this (e.g. 24h)")
+	cmd.Flags().DurationVar(&until, "until", 0, "Only events older than this (e.g. 1h)")
+	cmd.Flags().IntVar(&filter.Limit, "limit", 0, "Show at most this many events (0 = all)")
+	cmd.Flags().IntVar(&filter.Offset, "offset", 0, "Skip the first N matching events")
 
 	return cmd
 }
 
 func newSessionDeleteCmd() *cobra.Command {
 	return &cobra.Command{
-		Use:   "delete <session-id>",
-		Short: "Delete a session",
-		Args:  cobra.ExactArgs(1),
+		Use:               "delete <session-id>",
+		Short:             "Delete a session",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeArgs(completeSessions),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			sessionID := args[0]
 
-			sessionStore, cleanup, err := openSessionStore(cmd.Context())
+			cfg, err := config.Load()
 			if err != nil {
 				return err
 			}
-			defer cleanup()
+			db, err := openDatabase(cmd.Context(), cfg)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
 
-			if err := sessionStore.Delete(cmd.Context(), sessionID); err != nil {
+			if err := session.NewSQLStore(db).Delete(cmd.Context(), sessionID); err != nil {
 				return err
 			}
+			audit.NewLog(audit.NewSQLStore(db)).Record(cmd.Context(), audit.ActionSessionDelete, sessionID, nil)
 
 			fmt.Printf("Deleted session: %s\n", sessionID)
 			return nil
@@ -235,8 +367,274 @@ func newSessionDeleteCmd() *cobra.Command {
 	}
 }
 
-func newSessionStatsCmd() *cobra.Command {
+func newSessionAbortCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "abort <session-id>",
+		Short: "Abort a session",
+		Long: `Aborts a session: its running job's containers are stopped, the session
+branch is deleted and its pull request closed (see the abort config), a
+summary is posted on the issue, and the session moves to the aborted phase,
+which is final.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeArgs(completeSessions),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			orch, cleanup, err := openOrchestrator(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			if err := orch.Abort(cmd.Context(), args[0], ""); err != nil {
+				return err
+			}
+
+			fmt.Printf("Aborted session: %s\n", args[0])
+			return nil
+		},
+	}
+}
+
+func newSessionApproveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "approve <session-id>",
+		Short: "Approve a session's plan and implement it",
+		Long: `Approves the plan of a session awaiting approval, like an "@claude approved"
+comment: Claude implements the plan on the session branch and a pull request
+is opened. The command runs the job and returns when it is done.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeArgs(completeSessions),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			orch, cleanup, err := openOrchestrator(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			fmt.Printf("Approved session %s, implementing the plan...\n", args[0])
+			if err := orch.Approve(cmd.Context(), args[0], ""); err != nil {
+				return err
+			}
+
+			fmt.Printf("Implemented session %s and opened a pull request\n", args[0])
+			return nil
+		},
+	}
+}
+
+func newSessionEditPlanCmd() *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "edit-plan <session-id>",
+		Short: "Edit a session's plan before approving it",
+		Long: `Replaces the plan of a session awaiting approval with an edited version,
+which is posted on the issue and implemented on approval. Without --file the
+plan opens in $VISUAL or $EDITOR (default vi); --file reads the edited plan
+from a file, or from stdin with "-".`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeArgs(completeSessions),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var plan string
+			switch file {
+			case "":
+				current, err := currentPlan(cmd.Context(), args[0])
+				if err != nil {
+					return err
+				}
+				plan, err = editText(current)
+				if err != nil {
+					return err
+				}
+				if strings.TrimSpace(plan) == strings.TrimSpace(current) {
+					fmt.Println("Plan unchanged")
+					return nil
+				}
+			case "-":
+				data, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					return fmt.Errorf("read plan: %w", err)
+				}
+				plan = string(data)
+			default:
+				data, err := os.ReadFile(file)
+				if err != nil {
+					return fmt.Errorf("read plan: %w", err)
+				}
+				plan = string(data)
+			}
+
+			orch, cleanup, err := openOrchestrator(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			if err := orch.EditPlan(cmd.Context(), args[0], "", plan); err != nil {
+				return err
+			}
+
+			fmt.Printf("Updated the plan of session %s\n", args[0])
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "read the edited plan from a file (- for stdin)")
+
+	return cmd
+}
+
+// currentPlan returns the plan of a session awaiting approval.
+func currentPlan(ctx context.Context, id string) (string, error) {
+	sessions, cleanup, err := openSessionStore(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	sess, err := sessions.Get(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if sess == nil {
+		return "", fmt.Errorf("session not found: %s", id)
+	}
+	if sess.Phase != session.PhaseAwaitingApproval {
+		return "", fmt.Errorf("session %s is %s; only plans awaiting approval can be edited", id, sess.Phase)
+	}
+	if sess.PlanContent == nil {
+		return "", nil
+	}
+	return *sess.PlanContent, nil
+}
+
+// editText opens text in the user's editor and returns the saved result.
+func editText(text string) (string, error) {
+	f, err := os.CreateTemp("", "manfred-plan-*.md")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// The editor may come with arguments, e.g. "code --wait"
+	c := exec.Command("sh", "-c", editor+` "$1"`, "sh", f.Name())
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return "", fmt.Errorf("run editor %q: %w", editor, err)
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func newSessionRetryCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "retry <session-id>",
+		Short: "Restart planning for a failed session",
+		Long: `Restarts planning for a session in the error phase, like an "@claude retry"
+comment. The command runs the planning job and returns when the plan is
+posted.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeArgs(completeSessions),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			orch, cleanup, err := openOrchestrator(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			fmt.Printf("Retrying session %s, planning...\n", args[0])
+			if err := orch.Retry(cmd.Context(), args[0], ""); err != nil {
+				return err
+			}
+
+			fmt.Printf("Posted a new plan for session %s\n", args[0])
+			return nil
+		},
+	}
+}
+
+func newSessionSetPhaseCmd() *cobra.Command {
 	return &cobra.Command{
+		Use:   "set-phase <session-id> <phase>",
+		Short: "Move a session to another phase",
+		Long: `Moves a session to another phase without running anything, to rescue a
+session stuck in the wrong phase. Only valid transitions are allowed; 'session
+show' lists them. Use 'session abort' to abort a session.`,
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeArgs(completeSessions, completePhases),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			phase, err := session.ParsePhase(args[1])
+			if err != nil {
+				return err
+			}
+
+			orch, cleanup, err := openOrchestrator(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			from, err := orch.SetPhase(cmd.Context(), args[0], phase)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Moved session %s from %s to %s\n", args[0], from.DisplayName(), phase.DisplayName())
+			return nil
+		},
+	}
+}
+
+// openOrchestrator opens the database and returns an orchestrator for
+// session commands. The caller must call the returned cleanup function when
+// done.
+func openOrchestrator(ctx context.Context) (*orchestrator.Orchestrator, func(), error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	db, err := openDatabase(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := cfg.GitHubClient()
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	orch := orchestrator.New(cfg, session.NewSQLStore(db), client)
+	orch.SetAudit(audit.NewLog(audit.NewSQLStore(db)))
+	cleanup := func() {
+		orch.Close()
+		db.Close()
+	}
+	return orch, cleanup, nil
+}
+
+func newSessionStatsCmd() *cobra.Command {
+	var recompute bool
+
+	cmd := &cobra.Command{
 		Use:   "stats",
 		Short: "Show session statistics",
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -246,6 +644,19 @@ func newSessionStatsCmd() *cobra.Command {
 			}
 			defer cleanup()
 
+			if recompute {
+				sessions, err := sessionStore.List(cmd.Context(), session.SessionFilter{})
+				if err != nil {
+					return err
+				}
+				for _, s := range sessions {
+					if _, err := sessionStore.RefreshMetrics(cmd.Context(), s.ID); err != nil {
+						return err
+					}
+				}
+				fmt.Printf("Recomputed metrics for %d sessions\n\n", len(sessions))
+			}
+
 			// Count by phase
 			fmt.Println("Sessions by phase:")
 			total := 0
@@ -266,9 +677,202 @@ func newSessionStatsCmd() *cobra.Command {
 			}
 			fmt.Printf("\nActive sessions: %d\n", activeCount)
 
+			// Human loop metrics
+			summary, err := sessionStore.MetricsSummary(cmd.Context(), session.SessionFilter{})
+			if err != nil {
+				return err
+			}
+			fmt.Println("\nHuman loop:")
+			fmt.Printf("  %-20s %d (%d of %d sessions revised)\n", "Revision rounds:", summary.Totals.RevisionRounds, summary.Revised, summary.Sessions)
+			fmt.Printf("  %-20s %d\n", "Plan revisions:", summary.Totals.PlanRevisions)
+			fmt.Printf("  %-20s %s total, %s avg\n", "Awaiting approval:", summary.Totals.AwaitingApproval(), summary.AvgAwaitingApproval())
+			fmt.Printf("  %-20s %s total, %s avg\n", "In review:", summary.Totals.InReview(), summary.AvgInReview())
+
+			fmt.Println("\nPlan checklists:")
+			for _, risk := range []string{"low", "medium", "high"} {
+				fmt.Printf("  %-20s %d\n", "Risk "+risk+":", summary.PlanRisk[risk])
+			}
+			fmt.Printf("  %-20s %d\n", "Migrations:", summary.Migrations)
+
+			if r := summary.Resources; r.Jobs > 0 {
+				fmt.Printf("\nJob resources (%d monitored jobs):\n", r.Jobs)
+				fmt.Printf("  %-20s %s max, %s avg\n", "Peak memory:", formatSize(r.MaxMemoryBytes), formatSize(r.AvgMemoryBytes))
+				fmt.Printf("  %-20s %.0f%% max\n", "Peak CPU:", r.MaxCPUPercent)
+			}
+
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVar(&recompute, "recompute", false, "Recompute session metrics from events first")
+
+	return cmd
+}
+
+func newSessionPruneCmd() *cobra.Command {
+	var (
+		completed, aborted, failed, triaged bool
+		olderThan                           string
+		export                              string
+		dryRun                              bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Archive old finished sessions",
+		Long: `Move terminal sessions that have been idle longer than --older-than, with
+their events, from the active tables to the archive, so session lists and
+queries stay fast. Without --completed, --aborted, --error or --triaged all
+terminal sessions are pruned.
+
+Archived sessions are listed with 'manfred session list --archived'. With
+--export they are also written to a JSON file. 'manfred serve' archives
+sessions idle longer than database.archive.after on its own.`,
+		Example: `  manfred session prune --completed --older-than 90d
+  manfred session prune --older-than 30d --dry-run
+  manfred session prune --older-than 180d --export archive.json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if olderThan == "" {
+				return fmt.Errorf("--older-than is required (e.g. 90d)")
+			}
+			age, err := parseAge(olderThan)
+			if err != nil {
+				return fmt.Errorf("--older-than: %w", err)
+			}
+			filter := session.ArchiveFilter{Before: time.Now().Add(-age)}
+			for phase, selected := range map[session.Phase]bool{
+				session.PhaseCompleted: completed,
+				session.PhaseAborted:   aborted,
+				session.PhaseError:     failed,
+				session.PhaseTriaged:   triaged,
+			} {
+				if selected {
+					filter.Phases = append(filter.Phases, phase)
+				}
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			db, err := openDatabase(cmd.Context(), cfg)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			sessionStore := session.NewSQLStore(db)
+
+			sessions, err := sessionStore.Archive(cmd.Context(), filter, dryRun)
+			auditLog := audit.NewLog(audit.NewSQLStore(db))
+			verb := "Archived"
+			if dryRun {
+				verb = "Would archive"
+			}
+			for _, s := range sessions {
+				fmt.Printf("%s %s (%s, last active %s)\n", verb, s.ID, s.Phase, s.LastActivity.Local().Format("2006-01-02 15:04"))
+				if !dryRun {
+					auditLog.Record(cmd.Context(), audit.ActionSessionArchive, s.ID, map[string]string{"phase": string(s.Phase)})
+				}
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s %d session(s)\n", verb, len(sessions))
+
+			if export != "" && !dryRun && len(sessions) > 0 {
+				return exportArchived(cmd.Context(), sessionStore, sessions, export)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&completed, "completed", false, "Prune completed sessions")
+	cmd.Flags().BoolVar(&aborted, "aborted", false, "Prune aborted sessions")
+	cmd.Flags().BoolVar(&failed, "error", false, "Prune failed sessions")
+	cmd.Flags().BoolVar(&triaged, "triaged", false, "Prune triage-only sessions")
+	cmd.Flags().StringVar(&olderThan, "older-than", "", "Prune sessions idle longer than this (e.g. 90d, 36h)")
+	cmd.Flags().StringVar(&export, "export", "", "Also write the archived sessions and their events to this JSON file")
+	cmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Only list what would be archived")
+
+	return cmd
+}
+
+// exportArchived writes the archive entries of sessions, just archived, to
+// path as a JSON array.
+func exportArchived(ctx context.Context, sessions *session.SQLStore, archived []session.Session, path string) error {
+	ids := make(map[string]bool, len(archived))
+	for _, s := range archived {
+		ids[s.ID] = true
+	}
+	// Archived most recently first, so the newest entry of each ID is the
+	// one just written
+	list, err := sessions.ListArchived(ctx, session.SessionFilter{})
+	if err != nil {
+		return err
+	}
+	export := make([]session.ArchivedSession, 0, len(archived))
+	for _, a := range list {
+		if ids[a.Session.ID] {
+			export = append(export, a)
+			delete(ids, a.Session.ID)
+		}
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal archived sessions: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	fmt.Printf("Exported %d session(s) to %s\n", len(export), path)
+	return nil
+}
+
+// runSessionArchive archives terminal sessions idle longer than after every
+// interval until ctx is done.
+func runSessionArchive(ctx context.Context, sessions session.Store, auditLog *audit.Log, after, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		archived, err := sessions.Archive(ctx, session.ArchiveFilter{Before: time.Now().Add(-after)}, false)
+		for _, s := range archived {
+			auditLog.Record(ctx, audit.ActionSessionArchive, s.ID, map[string]string{"phase": string(s.Phase)})
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: archiving sessions failed:", err)
+		}
+		if len(archived) > 0 {
+			fmt.Printf("Archived %d session(s)\n", len(archived))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// parseAge parses a duration that may also be given in days, like 90d.
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid number of days %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return d, nil
 }
 
 // truncate truncates a string to the given length, adding "..." if needed.
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/snapshot"
+	"github.com/spf13/cobra"
+)
+
+func newSnapshotCmd() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Write a JSON snapshot of sessions, jobs and queue",
+		Long: `Writes a compact JSON snapshot of sessions, recent jobs and ticket queues.
+
+The destination defaults to snapshot.path from the config. Paths starting
+with s3:// are uploaded using the aws CLI.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			dest := output
+			if dest == "" {
+				dest = cfg.Snapshot.Path
+			}
+			if dest == "" {
+				return fmt.Errorf("no destination: pass --output or set snapshot.path")
+			}
+
+			sessionStore, cleanup, err := openSessionStore(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			exporter := snapshot.NewExporter(cfg, sessionStore)
+			if err := exporter.Export(cmd.Context(), dest); err != nil {
+				return err
+			}
+
+			fmt.Printf("Snapshot written to %s\n", dest)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Destination file or s3:// URL")
+
+	return cmd
+}
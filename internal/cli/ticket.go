@@ -1,12 +1,14 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"strings"
 
 	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
 	"github.com/mpm/manfred/internal/ticket"
 	"github.com/spf13/cobra"
 )
@@ -18,8 +20,13 @@ func newTicketCmd() *cobra.Command {
 	}
 
 	cmd.AddCommand(newTicketNewCmd())
+	cmd.AddCommand(newTicketImportCmd())
+	cmd.AddCommand(newTicketTemplatesCmd())
 	cmd.AddCommand(newTicketListCmd())
 	cmd.AddCommand(newTicketShowCmd())
+	cmd.AddCommand(newTicketCommentCmd())
+	cmd.AddCommand(newTicketReopenCmd())
+	cmd.AddCommand(newTicketCancelCmd())
 	cmd.AddCommand(newTicketStatsCmd())
 	cmd.AddCommand(newTicketProcessCmd())
 
@@ -27,19 +34,48 @@ func newTicketCmd() *cobra.Command {
 }
 
 func newTicketNewCmd() *cobra.Command {
-	return &cobra.Command{
+	var templateName string
+	var vars []string
+	var branch, pullRequest string
+
+	cmd := &cobra.Command{
 		Use:   "new <project> [prompt]",
 		Short: "Create a new ticket",
 		Long: `Creates a new ticket for the specified project.
 
 If prompt is provided, uses it as the ticket content.
-Otherwise, reads from stdin.`,
-		Args: cobra.RangeArgs(1, 2),
+Otherwise, reads from stdin.
+
+The ticket is attributed to the OS user running the command (the user who
+ran sudo, under sudo) and job notifications are routed by it.
+
+With --branch, the job checks out that existing branch of the project's
+repository instead of creating one from the default branch, and pushes its
+commit back to it. --pr does the same for the head branch of a pull request,
+given as owner/repo#N or just N for the project's repository, e.g. to address
+review feedback on a human pull request. Both need repo: in project.yml.
+
+With --template, the prompt is rendered from a project template instead:
+templates/<name>.md in the project directory or .manfred/templates/<name>.md
+in its repository. Placeholders like {{.issue}} are filled from --var
+issue=123; a prompt argument is available as {{.prompt}}.`,
+		Args:              cobra.RangeArgs(1, 2),
+		ValidArgsFunction: completeArgs(completeProjects),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			project := args[0]
 			var prompt string
 
-			if len(args) > 1 {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			if templateName != "" {
+				prompt, err = renderTicketTemplate(cfg, project, templateName, vars, args[1:])
+				if err != nil {
+					return err
+				}
+			} else if len(args) > 1 {
 				prompt = args[1]
 			} else {
 				// Read from stdin
@@ -54,32 +90,240 @@ Otherwise, reads from stdin.`,
 				return fmt.Errorf("no prompt provided")
 			}
 
-			cfg, err := config.Load()
-			if err != nil {
-				return err
+			opts := ticket.CreateOptions{CreatedBy: ticket.CurrentUser(), Branch: branch}
+			if pullRequest != "" {
+				opts.Branch, opts.PullRequest, err = resolveTicketPullRequest(cmd.Context(), cfg, project, pullRequest)
+				if err != nil {
+					return err
+				}
 			}
 
 			store := ticket.NewFileStore(cfg.TicketsDir, project)
-			t, err := store.Create(cmd.Context(), prompt)
+			t, err := store.Create(cmd.Context(), prompt, opts)
 			if err != nil {
 				return err
 			}
 
 			fmt.Printf("Created ticket: %s\n", t.ID)
 			fmt.Printf("Project: %s\n", project)
+			fmt.Printf("Created by: %s\n", t.Creator())
+			if t.Branch != "" {
+				fmt.Printf("Branch: %s\n", ticketBranch(t))
+			}
 			fmt.Printf("Status: %s\n", t.Status)
 			return nil
 		},
 	}
+
+	cmd.Flags().StringVarP(&templateName, "template", "t", "", "Render the prompt from this project template")
+	cmd.Flags().StringArrayVar(&vars, "var", nil, "Template variable as key=value (repeatable)")
+	cmd.Flags().StringVar(&branch, "branch", "", "Work on this existing branch and push back to it")
+	cmd.Flags().StringVar(&pullRequest, "pr", "", "Work on the head branch of this pull request (owner/repo#N or N)")
+	cmd.MarkFlagsMutuallyExclusive("branch", "pr")
+
+	return cmd
+}
+
+func newTicketImportCmd() *cobra.Command {
+	var repoFlag, label string
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "import <project>",
+		Short: "Create tickets from labeled GitHub issues",
+		Long: `Creates a pending ticket for every open issue with --label in --repo
+(default: the project's repository), so GitHub can be the intake for
+tickets without running webhooks. The prompt is the issue title and body
+with a link back to the issue. Issues already imported into one of the
+project's tickets are skipped, so import can run repeatedly, e.g. from cron.
+
+At most the 100 newest matching issues are imported per run.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeArgs(completeProjects),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			project := args[0]
+
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			full := repoFlag
+			if full == "" {
+				projectConfig, err := cfg.ProjectConfig(project)
+				if err != nil {
+					return err
+				}
+				owner, repo, ok := config.ParseGitHubRepo(projectConfig.Repo)
+				if !ok {
+					return fmt.Errorf("project %s has no GitHub repo: in project.yml; use --repo", project)
+				}
+				full = owner + "/" + repo
+			}
+			owner, repo, ok := strings.Cut(full, "/")
+			if !ok || owner == "" || repo == "" {
+				return fmt.Errorf("invalid repository %q: want owner/repo", full)
+			}
+
+			client, err := cfg.GitHubClient()
+			if err != nil {
+				return err
+			}
+			issues, err := client.ListIssues(cmd.Context(), owner, repo, &github.ListIssuesOptions{State: "open", Labels: label})
+			if err != nil {
+				return fmt.Errorf("failed to list issues of %s: %w", full, err)
+			}
+
+			store := ticket.NewFileStore(cfg.TicketsDir, project)
+			existing, err := store.List(cmd.Context(), nil)
+			if err != nil {
+				return err
+			}
+			imported := make(map[string]string)
+			for _, t := range existing {
+				if t.Issue != "" {
+					imported[strings.ToLower(t.Issue)] = t.ID
+				}
+			}
+
+			created := 0
+			for _, issue := range issues {
+				if issue.IsPullRequest() {
+					continue
+				}
+				ref := fmt.Sprintf("%s/%s#%d", owner, repo, issue.Number)
+				if id, ok := imported[strings.ToLower(ref)]; ok {
+					fmt.Printf("Skipped %s: already ticket %s\n", ref, id)
+					continue
+				}
+				if dryRun {
+					fmt.Printf("Would import %s: %s\n", ref, issue.Title)
+					continue
+				}
+
+				prompt := ticket.IssuePrompt(issue.Title, issue.Body, issue.HTMLURL)
+				t, err := store.Create(cmd.Context(), prompt, ticket.CreateOptions{CreatedBy: ticket.CurrentUser(), Issue: ref})
+				if err != nil {
+					return fmt.Errorf("failed to import %s: %w", ref, err)
+				}
+				fmt.Printf("Imported %s as %s: %s\n", ref, t.ID, issue.Title)
+				created++
+			}
+
+			if !dryRun {
+				fmt.Printf("Imported %d issue(s) into %s\n", created, project)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&repoFlag, "repo", "", "Repository to import from as owner/repo (default: the project's)")
+	cmd.Flags().StringVar(&label, "label", "manfred", "Only import issues with this label")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the issues that would be imported")
+
+	return cmd
+}
+
+// resolveTicketPullRequest returns the head branch of the pull request ref
+// and the pull request as owner/repo#N. The pull request must belong to the
+// project's repository and come from a branch in it, which jobs can push to.
+func resolveTicketPullRequest(ctx context.Context, cfg *config.Config, project, ref string) (branch, pullRequest string, err error) {
+	projectConfig, err := cfg.ProjectConfig(project)
+	if err != nil {
+		return "", "", err
+	}
+	owner, repo, ok := config.ParseGitHubRepo(projectConfig.Repo)
+	if !ok {
+		return "", "", fmt.Errorf("project %s has no GitHub repo: in project.yml", project)
+	}
+	prOwner, prRepo, number, err := ticket.ParsePullRequestRef(ref, owner+"/"+repo)
+	if err != nil {
+		return "", "", err
+	}
+	if !strings.EqualFold(prOwner+"/"+prRepo, owner+"/"+repo) {
+		return "", "", fmt.Errorf("pull request %s/%s#%d is not in the project's repository %s/%s", prOwner, prRepo, number, owner, repo)
+	}
+
+	client, err := cfg.GitHubClient()
+	if err != nil {
+		return "", "", err
+	}
+	pr, err := client.GetPullRequest(ctx, owner, repo, number)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get pull request #%d: %w", number, err)
+	}
+	if pr.Head.Repo == nil || !strings.EqualFold(pr.Head.Repo.FullName, owner+"/"+repo) {
+		return "", "", fmt.Errorf("pull request #%d comes from a fork; jobs can only push to %s/%s", number, owner, repo)
+	}
+	if pr.State != "open" {
+		fmt.Fprintf(os.Stderr, "Warning: pull request #%d is %s\n", number, pr.State)
+	}
+	return pr.Head.Ref, fmt.Sprintf("%s/%s#%d", owner, repo, number), nil
+}
+
+// ticketBranch describes the branch a ticket works on.
+func ticketBranch(t *ticket.Ticket) string {
+	if t.PullRequest != "" {
+		return fmt.Sprintf("%s (%s)", t.Branch, t.PullRequest)
+	}
+	return t.Branch
+}
+
+// renderTicketTemplate renders a project's ticket template with the --var
+// values and the optional prompt argument as {{.prompt}}.
+func renderTicketTemplate(cfg *config.Config, project, name string, pairs, args []string) (string, error) {
+	vars, err := ticket.ParseVars(pairs)
+	if err != nil {
+		return "", err
+	}
+	if len(args) > 0 {
+		vars["prompt"] = args[0]
+	}
+
+	path, err := ticket.FindTemplate(ticket.TemplateDirs(cfg, project), name)
+	if err != nil {
+		return "", err
+	}
+	return ticket.RenderTemplate(path, vars)
+}
+
+func newTicketTemplatesCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:               "templates <project>",
+		Short:             "List a project's ticket templates",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeArgs(completeProjects),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			dirs := ticket.TemplateDirs(cfg, args[0])
+			names, err := ticket.ListTemplates(dirs)
+			if err != nil {
+				return err
+			}
+			if len(names) == 0 {
+				fmt.Printf("No templates found in %s\n", strings.Join(dirs, ", "))
+				return nil
+			}
+			for _, name := range names {
+				fmt.Println(name)
+			}
+			return nil
+		},
+	}
 }
 
 func newTicketListCmd() *cobra.Command {
 	var status string
 
 	cmd := &cobra.Command{
-		Use:   "list <project>",
-		Short: "List tickets for a project",
-		Args:  cobra.ExactArgs(1),
+		Use:               "list <project>",
+		Short:             "List tickets for a project",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeArgs(completeProjects),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			project := args[0]
 
@@ -108,21 +352,24 @@ func newTicketListCmd() *cobra.Command {
 
 			for _, t := range tickets {
 				preview := t.PromptPreview(50)
-				fmt.Printf("%s  %-12s  %s\n", t.ID, t.Status, preview)
+				fmt.Printf("%s  %-12s  %-12s  %s\n", t.ID, t.Status, t.Creator(), preview)
 			}
 			return nil
 		},
 	}
 
-	cmd.Flags().StringVar(&status, "status", "", "Filter by status (pending, in_progress, error, completed)")
+	cmd.Flags().StringVar(&status, "status", "", "Filter by status (pending, in_progress, error, completed, cancelled)")
+	cmd.RegisterFlagCompletionFunc("status", cobra.FixedCompletions(
+		[]string{"pending", "in_progress", "error", "completed", "cancelled"}, cobra.ShellCompDirectiveNoFileComp))
 	return cmd
 }
 
 func newTicketShowCmd() *cobra.Command {
 	return &cobra.Command{
-		Use:   "show <project> <ticket-id>",
-		Short: "Show ticket details",
-		Args:  cobra.ExactArgs(2),
+		Use:               "show <project> <ticket-id>",
+		Short:             "Show ticket details",
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeArgs(completeProjects, completeTickets()),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			project := args[0]
 			ticketID := args[1]
@@ -145,9 +392,19 @@ func newTicketShowCmd() *cobra.Command {
 			fmt.Printf("Project: %s\n", t.Project)
 			fmt.Printf("Status: %s\n", t.Status)
 			fmt.Printf("Created: %s\n", t.CreatedAt.Format("2006-01-02 15:04:05"))
+			fmt.Printf("Created by: %s\n", t.Creator())
+			if t.Branch != "" {
+				fmt.Printf("Branch: %s\n", ticketBranch(t))
+			}
+			if t.Issue != "" {
+				fmt.Printf("Issue: %s\n", t.Issue)
+			}
 			if t.JobID != "" {
 				fmt.Printf("Job ID: %s\n", t.JobID)
 			}
+			if t.DuplicateOf != "" {
+				fmt.Printf("Duplicate of: job %s\n", t.DuplicateOf)
+			}
 			fmt.Println()
 			fmt.Println("Entries:")
 			for _, e := range t.Entries {
@@ -160,11 +417,153 @@ func newTicketShowCmd() *cobra.Command {
 	}
 }
 
+func newTicketCommentCmd() *cobra.Command {
+	var note bool
+
+	cmd := &cobra.Command{
+		Use:   "comment <project> <ticket-id> [text]",
+		Short: "Add a follow-up prompt or a note to a ticket",
+		Long: `Adds a follow-up to a ticket, read from stdin when text is not given.
+Jobs for the ticket get its follow-ups appended to the prompt, oldest first,
+so a ticket can be refined and processed again. On a ticket with a branch
+(see 'ticket new --branch'), the next job continues on the pushed work.
+
+With --note, the text is a plain comment that jobs do not see.`,
+		Args:              cobra.RangeArgs(2, 3),
+		ValidArgsFunction: completeArgs(completeProjects, completeTickets()),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			project, ticketID := args[0], args[1]
+
+			var text string
+			if len(args) > 2 {
+				text = args[2]
+			} else {
+				data, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					return fmt.Errorf("failed to read stdin: %w", err)
+				}
+				text = string(data)
+			}
+			text = strings.TrimSpace(text)
+			if text == "" {
+				return fmt.Errorf("no text provided")
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			store := ticket.NewFileStore(cfg.TicketsDir, project)
+			t, err := store.Get(cmd.Context(), ticketID)
+			if err != nil {
+				return err
+			}
+			if t == nil {
+				return fmt.Errorf("ticket not found: %s", ticketID)
+			}
+
+			entryType := ticket.EntryTypeFollowUp
+			if note {
+				entryType = ticket.EntryTypeComment
+			}
+			t.AddEntry(entryType, ticket.CurrentUser(), text)
+			if err := store.Update(cmd.Context(), t); err != nil {
+				return err
+			}
+
+			fmt.Printf("Added %s to ticket %s (%s)\n", strings.ReplaceAll(string(entryType), "_", "-"), t.ID, t.Status)
+			// Only pending tickets are processed; point to reopen for the rest
+			if !note && t.Status == ticket.StatusInProgress {
+				fmt.Printf("The ticket is being processed; once its job ends, run 'manfred ticket reopen %s %s' to process the follow-up.\n", project, t.ID)
+			} else if !note && t.Status != ticket.StatusPending {
+				fmt.Printf("The ticket is %s; run 'manfred ticket reopen %s %s' to process it with the follow-up.\n", t.Status, project, t.ID)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&note, "note", false, "Add a plain comment that jobs do not see")
+
+	return cmd
+}
+
+func newTicketReopenCmd() *cobra.Command {
+	var clearJob bool
+
+	cmd := &cobra.Command{
+		Use:   "reopen <project> <ticket-id>",
+		Short: "Move an errored, completed or cancelled ticket back to pending",
+		Long: `Moves a ticket back to pending so 'ticket process' runs it again, e.g.
+after adding a follow-up with 'ticket comment'. With --clear-job, the ticket
+also forgets its last job.`,
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeArgs(completeProjects, completeTickets(ticket.StatusError, ticket.StatusCompleted, ticket.StatusCancelled)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return updateTicket(cmd, args[0], args[1], func(t *ticket.Ticket) error {
+				return t.Reopen(ticket.CurrentUser(), clearJob)
+			})
+		},
+	}
+
+	cmd.Flags().BoolVar(&clearJob, "clear-job", false, "Forget the ticket's last job")
+
+	return cmd
+}
+
+func newTicketCancelCmd() *cobra.Command {
+	var reason string
+
+	cmd := &cobra.Command{
+		Use:               "cancel <project> <ticket-id>",
+		Short:             "Mark a pending ticket as abandoned",
+		Long:              `Moves a pending ticket to cancelled, so it is never processed. 'ticket reopen' undoes it.`,
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeArgs(completeProjects, completeTickets(ticket.StatusPending)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return updateTicket(cmd, args[0], args[1], func(t *ticket.Ticket) error {
+				return t.Cancel(ticket.CurrentUser(), reason)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&reason, "reason", "", "Why the ticket is abandoned")
+
+	return cmd
+}
+
+// updateTicket applies change to a ticket and saves it.
+func updateTicket(cmd *cobra.Command, project, ticketID string, change func(*ticket.Ticket) error) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	store := ticket.NewFileStore(cfg.TicketsDir, project)
+	t, err := store.Get(cmd.Context(), ticketID)
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return fmt.Errorf("ticket not found: %s", ticketID)
+	}
+
+	if err := change(t); err != nil {
+		return err
+	}
+	if err := store.Update(cmd.Context(), t); err != nil {
+		return err
+	}
+	fmt.Printf("Ticket %s is now %s\n", t.ID, t.Status)
+	return nil
+}
+
 func newTicketStatsCmd() *cobra.Command {
 	return &cobra.Command{
-		Use:   "stats [project]",
-		Short: "Show ticket statistics",
-		Args:  cobra.MaximumNArgs(1),
+		Use:               "stats [project]",
+		Short:             "Show ticket statistics",
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeArgs(completeProjects),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, err := config.Load()
 			if err != nil {
@@ -221,6 +620,7 @@ func newTicketStatsCmd() *cobra.Command {
 				fmt.Printf("%sIn Progress: %d\n", prefix, stats[ticket.StatusInProgress])
 				fmt.Printf("%sError:       %d\n", prefix, stats[ticket.StatusError])
 				fmt.Printf("%sCompleted:   %d\n", prefix, stats[ticket.StatusCompleted])
+				fmt.Printf("%sCancelled:   %d\n", prefix, stats[ticket.StatusCancelled])
 				fmt.Printf("%sTotal:       %d\n", prefix, total)
 
 				if len(projects) > 1 {
@@ -233,14 +633,18 @@ func newTicketStatsCmd() *cobra.Command {
 }
 
 func newTicketProcessCmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "process <project> [ticket-id]",
 		Short: "Process a ticket (run as job)",
 		Long: `Processes a ticket by running it as a MANFRED job.
 
 If ticket-id is provided, processes that specific ticket.
-Otherwise, processes the next pending ticket (FIFO).`,
-		Args: cobra.RangeArgs(1, 2),
+Otherwise, processes the next pending ticket (FIFO).
+
+With tickets.sync_issues, a completed ticket imported from a GitHub issue
+posts its result on the issue (and closes it, with tickets.close_issues).`,
+		Args:              cobra.RangeArgs(1, 2),
+		ValidArgsFunction: completeArgs(completeProjects, completeTickets(ticket.StatusPending)),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			project := args[0]
 			var ticketID string
@@ -252,8 +656,22 @@ Otherwise, processes the next pending ticket (FIFO).`,
 			if err != nil {
 				return err
 			}
+			applyKeepContainersFlag(cmd, cfg)
+			if err := cfg.ValidateJobs(); err != nil {
+				return err
+			}
 
 			processor := ticket.NewProcessor(cfg)
+			if cfg.Tickets.SyncIssues {
+				client, err := cfg.GitHubClient()
+				if err != nil {
+					return err
+				}
+				processor.SetIssueClient(client)
+			}
+			if client := pullRequestClient(cfg, project); client != nil {
+				processor.SetPullRequestClient(client)
+			}
 			t, err := processor.Process(cmd.Context(), project, ticketID)
 			if err != nil {
 				return err
@@ -269,6 +687,9 @@ Otherwise, processes the next pending ticket (FIFO).`,
 			if t.JobID != "" {
 				fmt.Printf("Job ID: %s\n", t.JobID)
 			}
+			if t.DuplicateOf != "" {
+				fmt.Printf("Duplicate of: job %s\n", t.DuplicateOf)
+			}
 
 			if t.Status != ticket.StatusCompleted {
 				return fmt.Errorf("ticket processing failed")
@@ -276,4 +697,8 @@ Otherwise, processes the next pending ticket (FIFO).`,
 			return nil
 		},
 	}
+
+	cmd.Flags().Bool("keep-containers", false, "Leave containers running if the job fails (clean up with 'manfred cleanup')")
+
+	return cmd
 }
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/relay"
+	"github.com/spf13/cobra"
+)
+
+func newWebhookCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "webhook",
+		Short: "Webhook delivery tools",
+	}
+
+	cmd.AddCommand(newWebhookRelayCmd())
+
+	return cmd
+}
+
+func newWebhookRelayCmd() *cobra.Command {
+	var source, target string
+
+	cmd := &cobra.Command{
+		Use:   "relay --source <url>",
+		Short: "Forward webhooks from a relay channel to the local server",
+		Long: `Subscribe to a webhook relay channel (e.g. https://smee.io/<channel>) and
+forward each delivery to the local 'manfred serve' webhook endpoint.
+
+Point the GitHub webhook at the channel URL; no tunnel or open port is needed.
+With a webhook secret configured, deliveries with a bad signature are dropped
+before they are forwarded.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if target == "" {
+				host := cfg.Server.Addr
+				if host == "" || host == "0.0.0.0" {
+					host = "127.0.0.1"
+				}
+				target = fmt.Sprintf("http://%s/webhook/github", net.JoinHostPort(host, strconv.Itoa(cfg.Server.Port)))
+			}
+			if cfg.GitHub.WebhookSecret == "" {
+				fmt.Fprintln(os.Stderr, "Warning: no webhook secret configured, signatures will not be verified")
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			fmt.Printf("Relaying %s -> %s\n", source, target)
+			client := relay.New(source, target, cfg.GitHub.WebhookSecret)
+			err = client.Run(ctx, func(d *relay.Delivery, err error) {
+				switch {
+				case d == nil:
+					fmt.Fprintln(os.Stderr, "Warning:", err)
+				case err != nil:
+					fmt.Fprintf(os.Stderr, "Error: %s %s: %v\n", d.Event, d.ID, err)
+				default:
+					fmt.Printf("Forwarded %s %s\n", d.Event, d.ID)
+				}
+			})
+			if errors.Is(err, ctx.Err()) {
+				return nil
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&source, "source", "", "Relay channel URL, e.g. https://smee.io/<channel>")
+	cmd.Flags().StringVar(&target, "target", "", "Webhook endpoint to forward to (default: the server.addr/server.port endpoint)")
+	cmd.MarkFlagRequired("source")
+
+	return cmd
+}
@@ -0,0 +1,378 @@
+// Package codemap summarizes a repository for planning prompts: its
+// directory tree, key files and top-level symbols, within a size budget.
+// Nothing in the repository is executed; symbols come from universal-ctags
+// when it is installed and from the Go parser otherwise.
+package codemap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// treeDepth is how deep the directory tree is listed; deeper
+	// directories are summarized by their file count.
+	treeDepth = 3
+
+	// keyFileLines is the number of lines shown of each key file.
+	keyFileLines = 30
+
+	// ctagsTimeout bounds the symbol scan of large repositories.
+	ctagsTimeout = 30 * time.Second
+
+	// maxSourceBytes skips generated or minified files when parsing.
+	maxSourceBytes = 512 << 10
+)
+
+// skipDirs are directories left out of the map: VCS data, dependencies and
+// build output.
+var skipDirs = map[string]bool{
+	".git": true, "node_modules": true, "vendor": true, "dist": true,
+	"build": true, "target": true, "coverage": true, "tmp": true,
+	"__pycache__": true, ".venv": true, "venv": true, ".next": true,
+	".bundle": true, "_build": true, "deps": true,
+}
+
+// keyFiles are shown with their first lines, in this order.
+var keyFiles = []string{
+	"README.md", "README", "README.rst", "CLAUDE.md", "AGENTS.md",
+	"go.mod", "package.json", "Cargo.toml", "pyproject.toml", "Gemfile",
+	"mix.exs", "pom.xml", "build.gradle", "Makefile", "Dockerfile",
+	"docker-compose.yml", "compose.yaml",
+}
+
+// symbolKinds are the ctags kinds included in the symbol list.
+var symbolKinds = map[string]bool{
+	"function": true, "method": true, "class": true, "struct": true,
+	"interface": true, "type": true, "trait": true, "enum": true,
+	"module": true, "typedef": true,
+}
+
+// lookCtags returns the path of universal-ctags, or "" when it is not
+// installed. Exuberant ctags lacks JSON output and is not used.
+var lookCtags = func() string {
+	path, err := exec.LookPath("ctags")
+	if err != nil {
+		return ""
+	}
+	out, err := exec.Command(path, "--version").Output()
+	if err != nil || !bytes.Contains(out, []byte("Universal Ctags")) {
+		return ""
+	}
+	return path
+}
+
+// Generate renders a Markdown code map of the repository in dir of at most
+// maxBytes; sections that do not fit are truncated.
+func Generate(ctx context.Context, dir string, maxBytes int) (string, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return "", fmt.Errorf("failed to read repository: %w", err)
+	}
+
+	tree := directoryTree(dir)
+	keys := keyFileExcerpts(dir)
+	symbols, source := fileSymbols(ctx, dir)
+
+	// The tree and key files get up to a third each; symbols get the rest
+	var b strings.Builder
+	section := func(title, body string, budget int) {
+		header := "### " + title + "\n\n"
+		budget -= len(header) + 1
+		if body == "" || budget <= 0 {
+			return
+		}
+		body = truncate(body, budget)
+		if body == "" {
+			return
+		}
+		b.WriteString(header)
+		b.WriteString(body)
+		b.WriteString("\n")
+	}
+	section("Directory tree", "```\n"+tree+"```\n", maxBytes/3)
+	section("Key files", keys, maxBytes/3)
+	section("Symbols ("+source+")", symbols, maxBytes-b.Len())
+	return b.String(), nil
+}
+
+// truncate cuts s at the last line that fits in max bytes.
+func truncate(s string, max int) string {
+	const note, fence = "... (truncated)\n", "```\n"
+	if len(s) <= max {
+		return s
+	}
+	// Leave room to close a fenced block
+	if max <= len(note)+len(fence) {
+		return ""
+	}
+	cut := s[:max-len(note)-len(fence)]
+	if i := strings.LastIndexByte(cut, '\n'); i >= 0 {
+		cut = cut[:i+1]
+	}
+	if strings.Count(cut, "```")%2 == 1 {
+		return cut + note + fence
+	}
+	return cut + note
+}
+
+// skipped reports whether a directory is left out of the map.
+func skipped(name string) bool {
+	return skipDirs[name] || (strings.HasPrefix(name, ".") && name != ".github")
+}
+
+// directoryTree lists directories to treeDepth with their file counts, and
+// the files at the top level.
+func directoryTree(dir string) string {
+	var b strings.Builder
+	var walk func(path, indent string, depth int)
+	walk = func(path, indent string, depth int) {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				if depth == 0 {
+					fmt.Fprintf(&b, "%s%s\n", indent, e.Name())
+				}
+				continue
+			}
+			if skipped(e.Name()) {
+				continue
+			}
+			sub := filepath.Join(path, e.Name())
+			fmt.Fprintf(&b, "%s%s/ (%d files)\n", indent, e.Name(), countFiles(sub))
+			if depth+1 < treeDepth {
+				walk(sub, indent+"  ", depth+1)
+			}
+		}
+	}
+	walk(dir, "", 0)
+	return b.String()
+}
+
+// countFiles counts the files below dir, leaving out skipped directories.
+func countFiles(dir string) int {
+	count := 0
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && path != dir && skipped(d.Name()) {
+			return filepath.SkipDir
+		}
+		if d.Type().IsRegular() {
+			count++
+		}
+		return nil
+	})
+	return count
+}
+
+// keyFileExcerpts shows the first lines of the key files that exist.
+func keyFileExcerpts(dir string) string {
+	var b strings.Builder
+	for _, name := range keyFiles {
+		path := filepath.Join(dir, name)
+		// Symlinks could point anywhere on the host
+		if info, err := os.Lstat(path); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		lines, more := headLines(path, keyFileLines)
+		if lines == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "`%s`:\n```\n%s", name, lines)
+		if more {
+			b.WriteString("...\n")
+		}
+		b.WriteString("```\n\n")
+	}
+	return b.String()
+}
+
+// headLines returns the first n lines of a text file and whether there are
+// more. Binary files return "".
+func headLines(path string, n int) (string, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	var b strings.Builder
+	scanner := bufio.NewScanner(f)
+	for i := 0; scanner.Scan(); i++ {
+		if i == n {
+			return b.String(), true
+		}
+		line := scanner.Text()
+		if strings.IndexByte(line, 0) >= 0 {
+			return "", false
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return b.String(), false
+}
+
+// fileSymbols lists the top-level symbols per file, one line per file, and
+// names the tool that found them.
+func fileSymbols(ctx context.Context, dir string) (string, string) {
+	var symbols map[string][]string
+	source := "ctags"
+	if ctags := lookCtags(); ctags != "" {
+		symbols = ctagsSymbols(ctx, ctags, dir)
+	}
+	if symbols == nil {
+		symbols = goSymbols(dir)
+		source = "Go files"
+	}
+	if len(symbols) == 0 {
+		return "", source
+	}
+
+	paths := make([]string, 0, len(symbols))
+	for path := range symbols {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var b strings.Builder
+	for _, path := range paths {
+		fmt.Fprintf(&b, "- `%s`: %s\n", path, strings.Join(symbols[path], ", "))
+	}
+	return b.String(), source
+}
+
+// ctagsSymbols runs universal-ctags over dir. --options=NONE keeps option
+// files in the repository (.ctags.d) from being read and --links=no keeps
+// symlinks from leading out of it. It returns nil when ctags fails.
+func ctagsSymbols(ctx context.Context, ctags, dir string) map[string][]string {
+	ctx, cancel := context.WithTimeout(ctx, ctagsTimeout)
+	defer cancel()
+
+	args := []string{"--options=NONE", "--links=no", "--recurse", "--output-format=json", "--fields=+K", "-f", "-"}
+	for name := range skipDirs {
+		args = append(args, "--exclude="+name)
+	}
+	cmd := exec.CommandContext(ctx, ctags, append(args, ".")...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	symbols := map[string][]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var tag struct {
+			Name  string `json:"name"`
+			Path  string `json:"path"`
+			Kind  string `json:"kind"`
+			Scope string `json:"scope"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &tag) != nil || !symbolKinds[tag.Kind] || isTestFile(tag.Path) {
+			continue
+		}
+		name := tag.Name
+		if tag.Scope != "" {
+			name = tag.Scope + "." + name
+		}
+		path := filepath.ToSlash(strings.TrimPrefix(tag.Path, "./"))
+		symbols[path] = append(symbols[path], name)
+	}
+	return symbols
+}
+
+// goSymbols lists the top-level functions, methods and types of the Go
+// files in dir.
+func goSymbols(dir string) map[string][]string {
+	symbols := map[string][]string{}
+	fset := token.NewFileSet()
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && skipped(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !strings.HasSuffix(path, ".go") || isTestFile(path) {
+			return nil
+		}
+		if info, err := d.Info(); err != nil || info.Size() > maxSourceBytes {
+			return nil
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil
+		}
+		var names []string
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				names = append(names, funcName(decl))
+			case *ast.GenDecl:
+				if decl.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range decl.Specs {
+					names = append(names, "type "+spec.(*ast.TypeSpec).Name.Name)
+				}
+			}
+		}
+		if len(names) > 0 {
+			rel, _ := filepath.Rel(dir, path)
+			symbols[filepath.ToSlash(rel)] = names
+		}
+		return nil
+	})
+	return symbols
+}
+
+// funcName renders a function as name or (Receiver).name.
+func funcName(decl *ast.FuncDecl) string {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return decl.Name.Name
+	}
+	recv := decl.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	if index, ok := recv.(*ast.IndexExpr); ok {
+		recv = index.X
+	}
+	if index, ok := recv.(*ast.IndexListExpr); ok {
+		recv = index.X
+	}
+	if ident, ok := recv.(*ast.Ident); ok {
+		return ident.Name + "." + decl.Name.Name
+	}
+	return decl.Name.Name
+}
+
+// isTestFile reports whether path looks like a test, which plans rarely
+// need to see symbol by symbol.
+func isTestFile(path string) bool {
+	base := filepath.Base(path)
+	return strings.HasSuffix(base, "_test.go") || strings.HasPrefix(base, "test_") ||
+		strings.Contains(base, ".test.") || strings.Contains(base, ".spec.")
+}
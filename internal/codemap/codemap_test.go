@@ -0,0 +1,81 @@
+package codemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	lookCtags = func() string { return "" }
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"README.md":                   "# Example\n\nAn example service.\n",
+		"go.mod":                      "module example.com/app\n",
+		"internal/job/runner.go":      "package job\n\ntype Runner struct{}\n\nfunc New() *Runner { return nil }\n\nfunc (r *Runner) Run() {}\n",
+		"internal/job/runner_test.go": "package job\n\nfunc TestRun() {}\n",
+		"node_modules/dep/index.js":   "module.exports = {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := Generate(context.Background(), dir, 20000)
+	if err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	for _, want := range []string{
+		"internal/ (2 files)\n  job/ (2 files)\n",
+		"`README.md`:\n```\n# Example\n",
+		"`go.mod`:",
+		"### Symbols (Go files)",
+		"- `internal/job/runner.go`: type Runner, New, Runner.Run\n",
+	} {
+		if !strings.Contains(m, want) {
+			t.Errorf("Generate() missing %q in:\n%s", want, m)
+		}
+	}
+	for _, unwanted := range []string{"node_modules", "TestRun"} {
+		if strings.Contains(m, unwanted) {
+			t.Errorf("Generate() contains %q:\n%s", unwanted, m)
+		}
+	}
+
+	small, err := Generate(context.Background(), dir, 300)
+	if err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	if len(small) > 300 {
+		t.Errorf("Generate(300) returned %d bytes", len(small))
+	}
+	if strings.Count(small, "```")%2 != 0 {
+		t.Errorf("Generate(300) left a code block open:\n%s", small)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{s: "a\nb\n", max: 10, want: "a\nb\n"},
+		{s: strings.Repeat("line\n", 10), max: 34, want: "line\nline\n... (truncated)\n"},
+		{s: "```\n" + strings.Repeat("x\n", 20) + "```\n", max: 30, want: "```\nx\nx\nx\n... (truncated)\n```\n"},
+		{s: strings.Repeat("line\n", 10), max: 20, want: ""},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.max); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+		}
+	}
+}
@@ -4,7 +4,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/secrets"
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
 )
@@ -16,22 +21,95 @@ type Config struct {
 	JobsDir     string `mapstructure:"jobs_dir"`
 	TicketsDir  string `mapstructure:"tickets_dir"`
 
-	Database    DatabaseConfig    `mapstructure:"database"`
-	Credentials CredentialsConfig `mapstructure:"credentials"`
-	Claude      ClaudeConfig      `mapstructure:"claude"`
-	GitHub      GitHubConfig      `mapstructure:"github"`
-	Server      ServerConfig      `mapstructure:"server"`
-	Logging     LoggingConfig     `mapstructure:"logging"`
+	Database    DatabaseConfig      `mapstructure:"database"`
+	Credentials CredentialsConfig   `mapstructure:"credentials"`
+	Claude      ClaudeConfig        `mapstructure:"claude"`
+	GitHub      GitHubConfig        `mapstructure:"github"`
+	PostMerge   PostMergeConfig     `mapstructure:"post_merge"`
+	Abort       AbortConfig         `mapstructure:"abort"`
+	Approval    ApprovalConfig      `mapstructure:"approval"`
+	CI          CIConfig            `mapstructure:"ci"`
+	Notify      NotifyConfig        `mapstructure:"notify"`
+	Server      ServerConfig        `mapstructure:"server"`
+	Logging     LoggingConfig       `mapstructure:"logging"`
+	Snapshot    SnapshotConfig      `mapstructure:"snapshot"`
+	Triggers    TriggersConfig      `mapstructure:"triggers"`
+	Auth        AuthorizationConfig `mapstructure:"authorization"`
+	Job         JobConfig           `mapstructure:"job"`
+	Queue       QueueConfig         `mapstructure:"queue"`
+	Secrets     SecretsConfig       `mapstructure:"secrets"`
+	Uploads     UploadsConfig       `mapstructure:"uploads"`
+	Limits      LimitsConfig        `mapstructure:"limits"`
+	Tickets     TicketsConfig       `mapstructure:"tickets"`
+
+	secrets *secrets.Store // Opened by SecretsStore
+}
+
+// UploadsGist is the uploads.target that stores uploads as secret gists.
+const UploadsGist = "gist"
+
+// UploadsConfig controls where large outputs (job logs of failed jobs, full
+// diffs, long status comments and PR summaries) are uploaded, to be linked
+// from comments and PRs instead of inlined.
+type UploadsConfig struct {
+	Target    string        `mapstructure:"target"`    // gist, s3://bucket/prefix or a directory; empty disables uploads
+	BaseURL   string        `mapstructure:"base_url"`  // URL the directory or bucket is served at; S3 links are presigned without it
+	Threshold int           `mapstructure:"threshold"` // Outputs over this many bytes are linked instead of inlined
+	Retention time.Duration `mapstructure:"retention"` // Delete uploads older than this; 0 keeps them
+}
+
+// LimitsConfig holds the timeouts, poll intervals and limits that are the
+// same for every job and request, to tune MANFRED to slow or busy
+// environments.
+type LimitsConfig struct {
+	ContainerWait   time.Duration `mapstructure:"container_wait"`    // How long a job waits for its main container to run
+	ContainerPoll   time.Duration `mapstructure:"container_poll"`    // How often it checks meanwhile
+	HTTPTimeout     time.Duration `mapstructure:"http_timeout"`      // GitHub, Vault and autoscale webhook requests
+	RateLimitBuffer int           `mapstructure:"rate_limit_buffer"` // GitHub requests kept in reserve; github.rate_limit_buffer overrides it
+}
+
+// TicketsConfig controls how tickets report back to the GitHub issues they
+// were imported from (see `ticket import`).
+type TicketsConfig struct {
+	SyncIssues  bool `mapstructure:"sync_issues"`  // Comment a completed ticket's result on its issue
+	CloseIssues bool `mapstructure:"close_issues"` // And close the issue
 }
 
 // DatabaseConfig holds database settings.
 type DatabaseConfig struct {
-	Path string `mapstructure:"path"` // Path to SQLite database file
+	Driver      string            `mapstructure:"driver"` // sqlite or postgres
+	Path        string            `mapstructure:"path"`   // Path to SQLite database file
+	URL         string            `mapstructure:"url"`    // Postgres connection string
+	Replication ReplicationConfig `mapstructure:"replication"`
+	Archive     ArchiveConfig     `mapstructure:"archive"`
+}
+
+// ArchiveConfig moves old terminal sessions and their events out of the
+// active tables, so session lists and queries stay fast.
+type ArchiveConfig struct {
+	After    time.Duration `mapstructure:"after"`    // Archive terminal sessions idle longer than this; 0 disables
+	Interval time.Duration `mapstructure:"interval"` // How often `manfred serve` archives
+}
+
+// ReplicationConfig copies the database somewhere durable, for hosts whose
+// disk does not outlive them.
+type ReplicationConfig struct {
+	// External hands WAL checkpoints to an external replicator such as
+	// litestream: MANFRED stops checkpointing on its own, so the replicator
+	// sees every WAL frame before it is folded into the database.
+	External bool          `mapstructure:"external"`
+	URL      string        `mapstructure:"url"`      // File path or s3://bucket/key of the built-in replica; empty disables
+	Interval time.Duration `mapstructure:"interval"` // How often `manfred serve` writes the replica
+	Restore  bool          `mapstructure:"restore"`  // Restore a missing database from url on startup
 }
 
 // ClaudeConfig holds Claude Code related settings.
 type ClaudeConfig struct {
-	BundlePath string `mapstructure:"bundle_path"` // Path to claude-bundle tarball or directory
+	BundlePath string `mapstructure:"bundle_path"` // Unversioned bundle directory, used when no version is pinned
+	Version    string `mapstructure:"version"`     // Installed bundle version jobs use, see `manfred bundle`
+	BundlesDir string `mapstructure:"bundles_dir"` // Installed versions; default <data_dir>/bundles
+	BundleURL  string `mapstructure:"bundle_url"`  // Download URL for `manfred bundle install`, with {version} and {arch}
+	CopyBundle bool   `mapstructure:"copy_bundle"` // Copy the bundle into every job directory instead of mounting it
 }
 
 // CredentialsConfig holds credential-related settings.
@@ -44,12 +122,311 @@ type CredentialsConfig struct {
 type ServerConfig struct {
 	Addr string `mapstructure:"addr"`
 	Port int    `mapstructure:"port"`
+
+	// PublicURL is where GitHub reaches the server, e.g.
+	// https://manfred.example.com, when it sits behind a proxy or tunnel
+	PublicURL string `mapstructure:"public_url"`
+
+	TLS          TLSConfig          `mapstructure:"tls"`           // Serve HTTPS
+	WebhookAllow WebhookAllowConfig `mapstructure:"webhook_allow"` // Who may deliver webhooks
+	Auth         ServerAuthConfig   `mapstructure:"auth"`          // Who may use the REST API
+	TrustProxy   bool               `mapstructure:"trust_proxy"`   // Take client IPs from X-Forwarded-For
+}
+
+// TLSConfig makes serve listen for HTTPS with a certificate and key in PEM
+// files, e.g. from certbot. The files are reloaded when they change.
+type TLSConfig struct {
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+}
+
+// Enabled reports whether serve listens for HTTPS.
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// WebhookAllowConfig restricts the webhook endpoint to client addresses in
+// GitHub's published hook ranges and CIDRs. Without either, every address
+// is allowed and only the signature is checked.
+type WebhookAllowConfig struct {
+	GitHub bool     `mapstructure:"github"` // The hooks ranges of the GitHub meta API
+	CIDRs  []string `mapstructure:"cidrs"`  // Extra ranges, e.g. of a relay
+}
+
+// Enabled reports whether webhook deliveries are restricted by address.
+func (w WebhookAllowConfig) Enabled() bool {
+	return w.GitHub || len(w.CIDRs) > 0
+}
+
+// ServerAuthConfig protects the REST API with a bearer token, HTTP basic
+// auth, or both. Without either, the API is open to everyone who reaches
+// the server.
+type ServerAuthConfig struct {
+	Token    string `mapstructure:"token"`    // Authorization: Bearer <token>
+	Username string `mapstructure:"username"` // Basic auth
+	Password string `mapstructure:"password"`
+}
+
+// Enabled reports whether the REST API requires credentials.
+func (a ServerAuthConfig) Enabled() bool {
+	return a.Token != "" || a.Username != ""
+}
+
+// WebhookPath is where the server receives GitHub webhooks.
+const WebhookPath = "/webhook/github"
+
+// WebhookURL returns the URL GitHub delivers webhooks to: PublicURL if set,
+// otherwise the listen address, followed by WebhookPath.
+func (s ServerConfig) WebhookURL() string {
+	if s.PublicURL != "" {
+		return strings.TrimRight(s.PublicURL, "/") + WebhookPath
+	}
+	return fmt.Sprintf("http://%s:%d%s", s.Addr, s.Port, WebhookPath)
 }
 
 // LoggingConfig holds logging settings.
 type LoggingConfig struct {
 	Level  string `mapstructure:"level"`
 	Format string `mapstructure:"format"`
+
+	// JobLog writes each job's log to .manfred/job.log in its directory
+	JobLog bool `mapstructure:"job_log"`
+
+	// Sanitize strips ANSI sequences and replaces non-printable characters
+	// in logged container output; RawLog then keeps the unsanitized output
+	// in .manfred/job.raw.log
+	Sanitize bool `mapstructure:"sanitize"`
+	RawLog   bool `mapstructure:"raw_log"`
+}
+
+// TriggersConfig controls which GitHub events start sessions.
+type TriggersConfig struct {
+	Labels       []string `mapstructure:"labels"`        // Issue labels that start a session
+	TriageLabels []string `mapstructure:"triage_labels"` // Issue labels that start a triage-only session
+	AllowedRepos []string `mapstructure:"allowed_repos"` // "owner/repo" or "owner/*"; empty allows all configured projects
+}
+
+// AuthorizationConfig lists who may trigger actions from GitHub. Entries are
+// GitHub logins or "org/team" slugs; an empty list allows everyone.
+type AuthorizationConfig struct {
+	Start             []string `mapstructure:"start"`              // Start sessions (trigger label, plan comment)
+	Approve           []string `mapstructure:"approve"`            // Approve plans
+	Retry             []string `mapstructure:"retry"`              // Retry failed sessions
+	Abort             []string `mapstructure:"abort"`              // Abort sessions; empty falls back to approve
+	ReplyUnauthorized bool     `mapstructure:"reply_unauthorized"` // Post a "not authorized" comment on rejection
+}
+
+// JobConfig holds job execution defaults.
+type JobConfig struct {
+	KeepContainers bool         `mapstructure:"keep_containers"` // Leave containers running when a job fails
+	Cleanup        string       `mapstructure:"cleanup"`         // What compose down removes: containers, volumes or images
+	ExecEnv        []string     `mapstructure:"exec_env"`        // Host variables passed to Claude and test execs
+	ExecSecrets    []string     `mapstructure:"exec_secrets"`    // Variables whose values are masked in logs
+	Timezone       string       `mapstructure:"timezone"`        // TZ for Claude and test execs, e.g. UTC
+	Locale         string       `mapstructure:"locale"`          // LANG and LC_ALL for execs, e.g. C.UTF-8
+	Hooks          []HookConfig `mapstructure:"hooks"`           // Plugin hooks run for every project
+	CloneDepth     int          `mapstructure:"clone_depth"`     // Shallow clone depth; 0 clones full history
+	CloneFilter    string       `mapstructure:"clone_filter"`    // Partial clone filter, e.g. blob:none
+	CloneCache     bool         `mapstructure:"clone_cache"`     // Clone from a per-project bare mirror
+	DetectTests    bool         `mapstructure:"detect_tests"`    // Guess a test command for projects without test.command
+	Duplicates     string       `mapstructure:"duplicates"`      // Jobs repeating a running or completed job's input: off, warn or skip
+	DefaultImage   string       `mapstructure:"default_image"`   // Image of projects whose repository has no compose file
+	Ports          string       `mapstructure:"ports"`           // Compose ports published on the host: ephemeral, strip or keep
+
+	SSHKey           string `mapstructure:"ssh_key"`            // Private key for SSH repository URLs
+	ContainerGitAuth bool   `mapstructure:"container_git_auth"` // Let git inside the container use the job's credentials
+	Rebase           bool   `mapstructure:"rebase"`             // Rebase pushed branches onto their base when it moved
+
+	Retention RetentionConfig `mapstructure:"retention"` // Garbage collection of job directories
+	Planning  PlanningConfig  `mapstructure:"planning"`  // How plan-only jobs run
+	Triage    TriageConfig    `mapstructure:"triage"`    // Issue triage jobs
+	Output    OutputConfig    `mapstructure:"output"`    // Limits on exec output
+	Monitor   MonitorConfig   `mapstructure:"monitor"`   // Sampling of container resource usage
+	Health    HealthConfig    `mapstructure:"health"`    // Anthropic API check before jobs start
+	Reaper    ReaperConfig    `mapstructure:"reaper"`    // Recovery of sessions whose job died with the process
+	Resume    ResumeConfig    `mapstructure:"resume"`    // Resuming interrupted sessions when serve starts
+}
+
+// ResumeConfig controls how `manfred serve` resumes, on startup, session
+// phases interrupted by the restart: their leftover containers are removed
+// and the phase is finished from the job directory, adopts an open pull
+// request or starts over.
+type ResumeConfig struct {
+	Enabled     bool `mapstructure:"enabled"`
+	MaxAttempts int  `mapstructure:"max_attempts"` // Resumes of one phase before it is moved to error
+}
+
+// ReaperConfig controls how `manfred serve` finds sessions left in a job
+// phase (planning, implementing, revising) by a process that died while
+// their job ran. Such a session is moved to the error phase once no
+// container of its job runs and its last activity is older than StaleAfter.
+type ReaperConfig struct {
+	Interval   time.Duration `mapstructure:"interval"`    // Time between checks; 0 disables the reaper
+	StaleAfter time.Duration `mapstructure:"stale_after"` // Minimum time since the session's last activity
+}
+
+// HealthConfig controls the Anthropic API health check run before a job
+// starts its containers. While the API is down or rejects the key, jobs
+// fail fast and `manfred serve` holds queued jobs, probing every interval.
+type HealthConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Interval time.Duration `mapstructure:"interval"` // Time between probes; a healthy result is reused as long
+	Timeout  time.Duration `mapstructure:"timeout"`  // Per probe
+}
+
+// MonitorConfig controls the sampling of a job's main container CPU and
+// memory usage while the job runs. The peaks are recorded on the job.
+type MonitorConfig struct {
+	Interval   time.Duration `mapstructure:"interval"`    // Time between samples; 0 disables monitoring
+	MemoryWarn int           `mapstructure:"memory_warn"` // Warn when memory use reaches this percent of the limit; 0 disables
+}
+
+// OutputConfig caps the output of Claude, test and compose execs, so a
+// runaway command cannot fill the logs or memory. Dropped bytes are counted
+// and reported when the job ends.
+type OutputConfig struct {
+	MaxBytes     int64 `mapstructure:"max_bytes"`      // Per stream and exec; 0 disables
+	MaxLineBytes int   `mapstructure:"max_line_bytes"` // Longer log lines are cut; 0 disables
+}
+
+// What to do with duplicate jobs, see JobConfig.Duplicates.
+const (
+	DuplicatesOff  = "off"
+	DuplicatesWarn = "warn"
+	DuplicatesSkip = "skip"
+)
+
+// Planning modes.
+const (
+	PlanningContainer = "container" // Claude Code in the project's containers
+	PlanningAPI       = "api"       // The Anthropic API against a shallow checkout, no Docker
+)
+
+// PlanningConfig selects how plan-only jobs (the session planning phase)
+// run. In api mode Claude explores a shallow clone through read-only tools
+// over the Anthropic API, which needs credentials.anthropic_api_key.
+type PlanningConfig struct {
+	Mode      string `mapstructure:"mode"`       // container or api; project.yml planning wins
+	Model     string `mapstructure:"model"`      // Model used in api mode
+	MaxTurns  int    `mapstructure:"max_turns"`  // API round trips before the plan is due
+	MaxTokens int    `mapstructure:"max_tokens"` // Output tokens per API response
+	BaseURL   string `mapstructure:"base_url"`   // Anthropic API endpoint
+
+	// CodeMap adds a directory tree, key files and symbols of the
+	// repository to the planning prompt, in both modes.
+	CodeMap      bool `mapstructure:"code_map"`
+	CodeMapBytes int  `mapstructure:"code_map_bytes"` // Size limit of the code map
+
+	// ClarifyRounds lets Claude ask clarifying questions on the issue
+	// before the plan of a new session, up to this many times; 0 disables.
+	ClarifyRounds int `mapstructure:"clarify_rounds"`
+}
+
+// TriageConfig controls issue triage jobs, which always run over the
+// Anthropic API like api mode planning (job.planning.base_url), typically
+// with a cheaper model and fewer turns.
+type TriageConfig struct {
+	Model     string `mapstructure:"model"`      // Model triaging issues
+	MaxTurns  int    `mapstructure:"max_turns"`  // API round trips before the triage is due
+	MaxTokens int    `mapstructure:"max_tokens"` // Output tokens per API response
+
+	// OpenIssues is how many of the repository's newest open issues are
+	// listed in the prompt for duplicate detection; 0 lists none.
+	OpenIssues int `mapstructure:"open_issues"`
+}
+
+// RetentionConfig limits the job directories kept in the jobs directory.
+// Zero values disable a limit.
+type RetentionConfig struct {
+	MaxAge       time.Duration `mapstructure:"max_age"`        // Remove jobs last modified longer ago than this
+	MaxCount     int           `mapstructure:"max_count"`      // Keep at most this many jobs, newest first
+	MaxDiskUsage string        `mapstructure:"max_disk_usage"` // Total size of kept jobs, e.g. 20GB
+	Interval     time.Duration `mapstructure:"interval"`       // How often `manfred serve` collects garbage
+}
+
+// MaxDiskBytes returns MaxDiskUsage in bytes, 0 when it is not set.
+func (r RetentionConfig) MaxDiskBytes() (int64, error) {
+	if r.MaxDiskUsage == "" {
+		return 0, nil
+	}
+	return ParseSize(r.MaxDiskUsage)
+}
+
+// Enabled reports whether any limit is set.
+func (r RetentionConfig) Enabled() bool {
+	return r.MaxAge > 0 || r.MaxCount > 0 || r.MaxDiskUsage != ""
+}
+
+// sizeUnits are the suffixes accepted by ParseSize, longest first.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a byte size such as "512MB", "20GB" or "1.5G". Units are
+// binary (1GB = 1024MB); a plain number is bytes.
+func ParseSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// HookConfig describes a plugin hook: an executable that receives a JSON
+// request on stdin at the listed job events and answers with JSON on stdout.
+type HookConfig struct {
+	Name     string        `mapstructure:"name" yaml:"name"`
+	Command  string        `mapstructure:"command" yaml:"command"`
+	Args     []string      `mapstructure:"args" yaml:"args,omitempty"`
+	Events   []string      `mapstructure:"events" yaml:"events"`               // pre_clone, post_claude, pre_finalize
+	Timeout  time.Duration `mapstructure:"timeout" yaml:"timeout,omitempty"`   // Default: 5m
+	Required bool          `mapstructure:"required" yaml:"required,omitempty"` // Fail the job when the hook errors
+}
+
+// QueueConfig limits the jobs `manfred serve` runs at once and configures
+// autoscaling notifications.
+type QueueConfig struct {
+	MaxConcurrent int             `mapstructure:"max_concurrent"` // Jobs run at once; 0 is unlimited
+	Autoscale     AutoscaleConfig `mapstructure:"autoscale"`
+}
+
+// AutoscaleConfig configures the scale_up and scale_down events posted when
+// queue thresholds are crossed. Zero thresholds disable that trigger.
+type AutoscaleConfig struct {
+	WebhookURL    string        `mapstructure:"webhook_url"`     // Receives the events; empty disables autoscaling
+	Secret        string        `mapstructure:"secret"`          // Signs events in X-Manfred-Signature-256
+	ScaleUpDepth  int           `mapstructure:"scale_up_depth"`  // Queued jobs that trigger scale_up
+	ScaleUpWait   time.Duration `mapstructure:"scale_up_wait"`   // Wait of the oldest queued job that triggers scale_up
+	ScaleDownIdle time.Duration `mapstructure:"scale_down_idle"` // Time without queued or running jobs that triggers scale_down
+	Cooldown      time.Duration `mapstructure:"cooldown"`        // Minimum time between events
+	Interval      time.Duration `mapstructure:"interval"`        // How often the queue is checked
+}
+
+// SnapshotConfig holds settings for periodic JSON state snapshots.
+type SnapshotConfig struct {
+	Path     string        `mapstructure:"path"`     // File path or s3://bucket/key; empty disables
+	Interval time.Duration `mapstructure:"interval"` // How often `manfred serve` writes a snapshot
 }
 
 // GitHubConfig holds GitHub integration settings.
@@ -57,14 +434,227 @@ type GitHubConfig struct {
 	Token           string `mapstructure:"token"`             // Personal Access Token
 	WebhookSecret   string `mapstructure:"webhook_secret"`    // Webhook signature secret
 	RateLimitBuffer int    `mapstructure:"rate_limit_buffer"` // Stop when this many requests remain
+	RateLimitWait   bool   `mapstructure:"rate_limit_wait"`   // Block until the rate limit resets instead of failing
+	MaxRetries      int    `mapstructure:"max_retries"`       // Retries for 5xx and secondary rate limit responses
+	CacheSize       int    `mapstructure:"cache_size"`        // ETag-validated GET responses to keep; 0 disables
+	ReportStatus    bool   `mapstructure:"report_status"`     // Commit statuses / check runs on session branches
+
+	PollInterval time.Duration `mapstructure:"poll_interval"` // Poll the API for events instead of relying on webhooks; 0 disables
+
+	// GitHub App authentication, used instead of Token when AppID is set
+	AppID          int64  `mapstructure:"app_id"`
+	InstallationID int64  `mapstructure:"installation_id"`
+	PrivateKeyFile string `mapstructure:"private_key_file"` // PEM private key of the app
+
+	CommentInterval       time.Duration `mapstructure:"comment_interval"`        // Minimum time between new comments per repo
+	CommentCoalesceWindow time.Duration `mapstructure:"comment_coalesce_window"` // Merge status updates with one marker into one comment within this window
+
+	Labels LabelsConfig `mapstructure:"labels"`
+
+	// PRTemplate is a text/template for the body of session pull requests,
+	// see github.PRDescription; empty uses github.DefaultPRTemplate
+	PRTemplate string `mapstructure:"pr_template"`
+
+	// DraftPRs opens session pull requests as drafts, marked ready for
+	// review once the job's tests pass
+	DraftPRs bool `mapstructure:"draft_prs"`
+
+	// AutoMerge merges session pull requests with this method once they
+	// are approved and their checks pass; empty disables
+	AutoMerge string `mapstructure:"auto_merge"`
+}
+
+// Merge methods of github.auto_merge and pull_request.auto_merge. MergeOff
+// disables auto-merge for a project when github.auto_merge is set.
+const (
+	MergeMethodMerge  = "merge"
+	MergeMethodSquash = "squash"
+	MergeMethodRebase = "rebase"
+	MergeOff          = "off"
+)
+
+// LabelsConfig controls the labels `manfred github setup-labels` creates and
+// the status label (manfred:planning, manfred:blocked, ...) kept on session
+// issues and PRs.
+type LabelsConfig struct {
+	Status      bool                   `mapstructure:"status"`      // Keep a status label in sync with the session phase
+	Definitions map[string]LabelConfig `mapstructure:"definitions"` // Colors and descriptions by label name, over the defaults
+}
+
+// LabelConfig is the look of one label.
+type LabelConfig struct {
+	Color       string `mapstructure:"color"` // Hex without #, e.g. 7057ff
+	Description string `mapstructure:"description"`
+}
+
+// PostMergeConfig controls housekeeping after a session's PR is merged.
+type PostMergeConfig struct {
+	CloseIssue   bool `mapstructure:"close_issue"`   // Close the originating issue with a summary comment
+	DeleteBranch bool `mapstructure:"delete_branch"` // Delete the PR branch on the remote
+	RemoveLabel  bool `mapstructure:"remove_label"`  // Remove trigger labels from the issue
+}
+
+// AbortConfig controls the cleanup when a session is aborted. Running job
+// containers are always stopped.
+type AbortConfig struct {
+	DeleteBranch bool `mapstructure:"delete_branch"` // Delete the session branch on the remote
+	ClosePR      bool `mapstructure:"close_pr"`      // Close the session's open pull request
+}
+
+// ApprovalConfig is the policy for approving plans: approval: in the config
+// for all projects, and in project.yml, whose set fields win for the project.
+// See Config.ApprovalPolicy.
+type ApprovalConfig struct {
+	AutoApproveLabels []string      `mapstructure:"auto_approve_labels" yaml:"auto_approve_labels,omitempty"` // Issues with one of these labels skip approval
+	Approvers         int           `mapstructure:"approvers" yaml:"approvers,omitempty"`                     // Distinct approvers needed; 0 means 1
+	RemindAfter       time.Duration `mapstructure:"remind_after" yaml:"remind_after,omitempty"`               // Remind on the issue once after this long; 0 never
+	ExpireAfter       time.Duration `mapstructure:"expire_after" yaml:"expire_after,omitempty"`               // Abort the session after this long; 0 never
+	Interval          time.Duration `mapstructure:"interval" yaml:"-"`                                        // How often serve checks the deadlines
+}
+
+// CIConfig controls how sessions react to failed CI on their pull requests
+// (check_run and workflow_run webhooks).
+type CIConfig struct {
+	FixFailures    bool     `mapstructure:"fix_failures"`     // Revise the PR with the failure output when CI fails on its head
+	MaxFixAttempts int      `mapstructure:"max_fix_attempts"` // CI revisions in a row before giving up to humans
+	LogBytes       int      `mapstructure:"log_bytes"`        // End of each failed job's log handed to Claude
+	Ignore         []string `mapstructure:"ignore"`           // Workflow and check names whose failures are left alone
+}
+
+// validate checks the policy, with key naming its config section.
+func (a ApprovalConfig) validate(key string) error {
+	if a.Approvers < 0 || a.RemindAfter < 0 || a.ExpireAfter < 0 {
+		return fmt.Errorf("%s: approvers, remind_after and expire_after must not be negative", key)
+	}
+	if a.RemindAfter > 0 && a.ExpireAfter > 0 && a.RemindAfter >= a.ExpireAfter {
+		return fmt.Errorf("%s.remind_after: %s is not before expire_after %s", key, a.RemindAfter, a.ExpireAfter)
+	}
+	return nil
+}
+
+// NotifyConfig sends notifications about jobs and sessions. Templates map an
+// event (job_completed, job_failed, plan_awaiting_approval, pr_created) to
+// a text/template replacing its default message.
+type NotifyConfig struct {
+	Sinks     []NotifySinkConfig `mapstructure:"sinks"`
+	Templates map[string]string  `mapstructure:"templates"`
+}
+
+// NotifySinkConfig is one notification destination. Events, Projects and
+// CreatedBy route notifications to it; empty lists route everything.
+type NotifySinkConfig struct {
+	Name      string            `mapstructure:"name"`       // Shown in errors
+	Type      string            `mapstructure:"type"`       // slack, discord, email, webhook, ntfy or pushover
+	URL       string            `mapstructure:"url"`        // Webhook URL, ntfy topic URL, or a Pushover API endpoint
+	Secret    string            `mapstructure:"secret"`     // Signs webhook bodies (X-Manfred-Signature-256)
+	Token     string            `mapstructure:"token"`      // ntfy access token or Pushover application token
+	User      string            `mapstructure:"user"`       // Pushover user or group key
+	Priority  int               `mapstructure:"priority"`   // ntfy (1-5) or Pushover (-2-2) priority; 0 is the default
+	Email     NotifyEmailConfig `mapstructure:"email"`      // SMTP settings for type email
+	Events    []string          `mapstructure:"events"`     // Events sent here
+	Projects  []string          `mapstructure:"projects"`   // Project names or owner/repo sent here
+	CreatedBy []string          `mapstructure:"created_by"` // Ticket creators whose jobs are sent here
+}
+
+// NotifyEmailConfig holds the SMTP settings of an email sink.
+type NotifyEmailConfig struct {
+	SMTPAddr string   `mapstructure:"smtp_addr"` // host:port
+	Username string   `mapstructure:"username"`
+	Password string   `mapstructure:"password"`
+	From     string   `mapstructure:"from"`
+	To       []string `mapstructure:"to"`
 }
 
 // ProjectConfig holds per-project configuration from project.yml.
 type ProjectConfig struct {
-	Name          string       `yaml:"name"`
-	Repo          string       `yaml:"repo"`
-	DefaultBranch string       `yaml:"default_branch"`
-	Docker        DockerConfig `yaml:"docker"`
+	Name          string            `yaml:"name"`
+	Repo          string            `yaml:"repo"`
+	DefaultBranch string            `yaml:"default_branch"`
+	Docker        DockerConfig      `yaml:"docker"`
+	Test          TestConfig        `yaml:"test,omitempty"`
+	Exec          ExecConfig        `yaml:"exec,omitempty"`
+	Hooks         []HookConfig      `yaml:"hooks,omitempty"`
+	Clone         CloneConfig       `yaml:"clone,omitempty"`
+	Git           GitConfig         `yaml:"git,omitempty"`
+	Artifacts     []string          `yaml:"artifacts,omitempty"`      // Container paths copied to <job>/artifacts/, relative to the workdir
+	Planning      string            `yaml:"planning,omitempty"`       // Overrides job.planning.mode
+	ClaudeVersion string            `yaml:"claude_version,omitempty"` // Overrides claude.version
+	Prompt        PromptConfig      `yaml:"prompt,omitempty"`
+	Context       string            `yaml:"context,omitempty"`     // Coding standards, architecture notes; see ProjectContext
+	Repos         []RepoConfig      `yaml:"repos,omitempty"`       // Several repositories instead of repo:
+	Approval      ApprovalConfig    `yaml:"approval,omitempty"`    // Overrides the set fields of approval:
+	PRTemplate    string            `yaml:"pr_template,omitempty"` // Overrides github.pr_template
+	PullRequest   PullRequestConfig `yaml:"pull_request,omitempty"`
+}
+
+// PullRequestConfig holds a project's settings for session pull requests.
+type PullRequestConfig struct {
+	Draft     *bool    `yaml:"draft,omitempty"`      // Overrides github.draft_prs
+	Labels    []string `yaml:"labels,omitempty"`     // Added when the pull request is opened, e.g. ai-generated
+	Reviewers []string `yaml:"reviewers,omitempty"`  // Logins and org/team names asked to review once it is ready
+	AutoMerge string   `yaml:"auto_merge,omitempty"` // Overrides github.auto_merge; off disables
+}
+
+// ReviewerLists splits Reviewers into user logins and team slugs.
+func (p PullRequestConfig) ReviewerLists() (users, teams []string) {
+	for _, r := range p.Reviewers {
+		if _, team, ok := strings.Cut(r, "/"); ok {
+			teams = append(teams, team)
+		} else {
+			users = append(users, r)
+		}
+	}
+	return users, teams
+}
+
+// RepoConfig is one of the repositories of a project that spans several,
+// e.g. a backend and a frontend. Jobs clone each into workspace/<name>.
+type RepoConfig struct {
+	Name          string `yaml:"name"`                     // Directory under the job workspace
+	Repo          string `yaml:"repo"`                     // Clone URL
+	DefaultBranch string `yaml:"default_branch,omitempty"` // Branch to start from; the remote HEAD if empty
+}
+
+// Clones reports whether jobs of the project clone its repository or
+// repositories instead of using the project's checkout.
+func (p *ProjectConfig) Clones() bool {
+	return p.Repo != "" || len(p.Repos) > 0
+}
+
+// ProjectContextFile is the file in a project directory whose content is
+// prepended to every job prompt of the project, with its context: setting.
+const ProjectContextFile = "CLAUDE_CONTEXT.md"
+
+// PromptConfig holds standing instructions wrapped around every job prompt
+// of a project, e.g. "Always run make test before finishing".
+type PromptConfig struct {
+	Prefix string `yaml:"prefix,omitempty"` // Prepended to every prompt
+	Suffix string `yaml:"suffix,omitempty"` // Appended to every prompt
+}
+
+// Wrap returns prompt between the prefix and suffix, separated by blank
+// lines.
+func (p PromptConfig) Wrap(prompt string) string {
+	if prefix := strings.TrimSpace(p.Prefix); prefix != "" {
+		prompt = prefix + "\n\n" + prompt
+	}
+	if suffix := strings.TrimSpace(p.Suffix); suffix != "" {
+		prompt = strings.TrimRight(prompt, "\n") + "\n\n" + suffix
+	}
+	return prompt
+}
+
+// GitConfig holds a project's git credentials.
+type GitConfig struct {
+	SSHKey string `yaml:"ssh_key,omitempty"` // Deploy key, relative to the project directory
+	Rebase *bool  `yaml:"rebase,omitempty"`  // Overrides job.rebase
+}
+
+// CloneConfig overrides the job.clone_* settings for a project.
+type CloneConfig struct {
+	Depth  int    `yaml:"depth,omitempty"`  // Shallow clone depth
+	Filter string `yaml:"filter,omitempty"` // Partial clone filter, e.g. blob:none
+	Cache  *bool  `yaml:"cache,omitempty"`  // Clone from projects/<name>/cache.git
 }
 
 // DockerConfig holds Docker-related project settings.
@@ -72,52 +662,138 @@ type DockerConfig struct {
 	ComposeFile string `yaml:"compose_file"`
 	MainService string `yaml:"main_service"`
 	Workdir     string `yaml:"workdir"`
+	Cleanup     string `yaml:"cleanup,omitempty"` // Overrides job.cleanup
+	Ports       string `yaml:"ports,omitempty"`   // Overrides job.ports
+	User        string `yaml:"user,omitempty"`    // Run Claude and tests as this user or uid[:gid]
+	Image       string `yaml:"image,omitempty"`   // Run one container from this image instead of compose
+	Fallback    bool   `yaml:"-"`                 // Image is job.default_image, see ApplyDefaultImage
+
+	// ExtraServices get the job directory, bundle and env besides MainService
+	ExtraServices []string `yaml:"extra_services,omitempty"`
+
+	Resources ResourceConfig `yaml:"resources,omitempty"` // Limits for each job container
 }
 
-// Load reads configuration from file, environment, and defaults.
-func Load() (*Config, error) {
-	cfg := &Config{}
+// ResourceConfig limits the CPU, memory and processes of each container of a
+// job, so a runaway job cannot starve the host. Zero values mean no limit.
+type ResourceConfig struct {
+	CPUs   float64 `yaml:"cpus,omitempty"`   // Number of CPUs, e.g. 1.5
+	Memory string  `yaml:"memory,omitempty"` // e.g. 4GB or 512M
+	Pids   int64   `yaml:"pids,omitempty"`   // Maximum number of processes
+}
 
-	// Set defaults
-	home, err := os.UserHomeDir()
-	if err != nil {
-		home = "."
+// MemoryBytes parses Memory; 0 means no limit.
+func (r ResourceConfig) MemoryBytes() (int64, error) {
+	if r.Memory == "" {
+		return 0, nil
 	}
-	defaultDataDir := filepath.Join(home, ".manfred")
+	return ParseSize(r.Memory)
+}
 
-	viper.SetDefault("data_dir", defaultDataDir)
-	viper.SetDefault("server.addr", "127.0.0.1")
-	viper.SetDefault("server.port", 8080)
-	viper.SetDefault("logging.level", "info")
-	viper.SetDefault("logging.format", "text")
+// Native reports whether jobs run a single container from Image through the
+// Docker API instead of docker compose.
+func (d DockerConfig) Native() bool {
+	return d.Image != ""
+}
 
-	// Unmarshal into struct
-	if err := viper.Unmarshal(cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
+// ApplyDefaultImage makes a compose project whose repository has no
+// docker.compose_file run natively: a single container from
+// job.default_image with the repository mounted at the workdir, instead of
+// failing to start compose. It fails when job.default_image is empty.
+func (c *Config) ApplyDefaultImage(name string, projectConfig *ProjectConfig) error {
+	if projectConfig.Docker.Native() {
+		return nil
+	}
+	composeFile := filepath.Join(c.ProjectRepositoryPath(name), projectConfig.Docker.ComposeFile)
+	if _, err := os.Stat(composeFile); !os.IsNotExist(err) {
+		return nil
+	}
+	if c.Job.DefaultImage == "" {
+		return fmt.Errorf("project %s has no %s; set docker.image in project.yml or job.default_image", name, projectConfig.Docker.ComposeFile)
 	}
+	projectConfig.Docker.Image = c.Job.DefaultImage
+	projectConfig.Docker.Fallback = true
+	return nil
+}
+
+// TestConfig holds settings for running the project's test suite after Claude
+// finishes. Leaving Command empty runs an auto-detected command, if any, or
+// disables the test phase.
+type TestConfig struct {
+	Command        string `yaml:"command"`          // Shell command run inside the main service
+	MaxFixAttempts int    `yaml:"max_fix_attempts"` // How many times Claude may try to fix failing tests
+	Detect         *bool  `yaml:"detect,omitempty"` // Overrides job.detect_tests
+}
+
+// ExecConfig holds extra environment for Claude and test execs in the main
+// service. It is separate from the compose environment.
+type ExecConfig struct {
+	Env     map[string]string `yaml:"env"`     // Variables set for every exec
+	Secrets []string          `yaml:"secrets"` // Variables whose values are masked in logs
 
-	// Apply defaults for derived paths
-	if cfg.DataDir == "" {
-		cfg.DataDir = defaultDataDir
+	Timezone    string `yaml:"timezone,omitempty"`      // Overrides job.timezone
+	Locale      string `yaml:"locale,omitempty"`        // Overrides job.locale
+	FakeTime    string `yaml:"fake_time,omitempty"`     // libfaketime FAKETIME spec, e.g. "@2024-01-01 00:00:00"
+	FakeTimeLib string `yaml:"fake_time_lib,omitempty"` // libfaketime path in the container; default: searched
+}
+
+// ReadFile reads the config file at path into the global viper instance,
+// or config.yaml from ~/.manfred or the working directory when path is
+// empty, and enables MANFRED_* environment variables. A missing default
+// config file is not an error; Load then uses the defaults.
+func ReadFile(path string) error {
+	if path != "" {
+		viper.SetConfigFile(path)
+	} else {
+		if home, err := os.UserHomeDir(); err == nil {
+			viper.AddConfigPath(filepath.Join(home, ".manfred"))
+		}
+		viper.AddConfigPath(".")
+		viper.SetConfigName("config")
+		viper.SetConfigType("yaml")
 	}
-	if cfg.ProjectsDir == "" {
-		cfg.ProjectsDir = filepath.Join(cfg.DataDir, "projects")
+	viper.SetEnvPrefix("MANFRED")
+	viper.AutomaticEnv()
+
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok || path != "" {
+			return fmt.Errorf("failed to read config: %w", err)
+		}
 	}
-	if cfg.JobsDir == "" {
-		cfg.JobsDir = filepath.Join(cfg.DataDir, "jobs")
+	return nil
+}
+
+// Load reads configuration from file, environment, and defaults, resolves
+// secret references, rejects it if Validate fails, and fills the GitHub
+// token and webhook secret from the secrets store.
+func Load() (*Config, error) {
+	cfg, err := LoadUnvalidated()
+	if err != nil {
+		return nil, err
 	}
-	if cfg.TicketsDir == "" {
-		cfg.TicketsDir = filepath.Join(cfg.DataDir, "tickets")
+	if err := cfg.resolveSecretRefs(); err != nil {
+		return nil, err
 	}
-	if cfg.Credentials.ClaudeCredentialsFile == "" {
-		cfg.Credentials.ClaudeCredentialsFile = filepath.Join(cfg.DataDir, "config", ".credentials.json")
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
 	}
-	if cfg.Claude.BundlePath == "" {
-		cfg.Claude.BundlePath = filepath.Join(cfg.DataDir, "claude-bundle")
+	if err := cfg.loadSecrets(); err != nil {
+		return nil, fmt.Errorf("failed to load secrets: %w", err)
 	}
-	if cfg.Database.Path == "" {
-		cfg.Database.Path = filepath.Join(cfg.DataDir, "manfred.db")
+	return cfg, nil
+}
+
+// LoadUnvalidated reads configuration like Load, but does not validate it,
+// for commands that inspect or repair the configuration.
+func LoadUnvalidated() (*Config, error) {
+	cfg := &Config{}
+	setDefaults(viper.GetViper())
+
+	// Unmarshal into struct
+	if err := viper.Unmarshal(cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
+	cfg.applyDerivedDefaults()
 
 	// Override with environment variables
 	if key := os.Getenv("ANTHROPIC_API_KEY"); key != "" {
@@ -138,6 +814,9 @@ func Load() (*Config, error) {
 	if path := os.Getenv("MANFRED_DATABASE_PATH"); path != "" {
 		cfg.Database.Path = path
 	}
+	if url := os.Getenv("MANFRED_DATABASE_URL"); url != "" {
+		cfg.Database.URL = url
+	}
 
 	// GitHub configuration from environment
 	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
@@ -146,13 +825,146 @@ func Load() (*Config, error) {
 	if secret := os.Getenv("MANFRED_WEBHOOK_SECRET"); secret != "" {
 		cfg.GitHub.WebhookSecret = secret
 	}
-	if cfg.GitHub.RateLimitBuffer == 0 {
-		cfg.GitHub.RateLimitBuffer = 100
+	if token := os.Getenv("MANFRED_API_TOKEN"); token != "" {
+		cfg.Server.Auth.Token = token
 	}
 
 	return cfg, nil
 }
 
+// Defaults returns the configuration MANFRED uses without a config file or
+// environment variables.
+func Defaults() (*Config, error) {
+	v := viper.New()
+	setDefaults(v)
+
+	cfg := &Config{}
+	if err := v.Unmarshal(cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse defaults: %w", err)
+	}
+	cfg.applyDerivedDefaults()
+	return cfg, nil
+}
+
+// defaultDataDir returns ~/.manfred.
+func defaultDataDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "."
+	}
+	return filepath.Join(home, ".manfred")
+}
+
+// setDefaults sets the default of every setting that has one on v.
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("data_dir", defaultDataDir())
+	v.SetDefault("server.addr", "127.0.0.1")
+	v.SetDefault("server.port", 8080)
+	v.SetDefault("logging.level", "info")
+	v.SetDefault("logging.format", "text")
+	v.SetDefault("logging.job_log", true)
+	v.SetDefault("logging.sanitize", true)
+	v.SetDefault("logging.raw_log", true)
+	v.SetDefault("snapshot.interval", "5m")
+	v.SetDefault("triggers.labels", []string{"manfred"})
+	v.SetDefault("github.max_retries", 3)
+	v.SetDefault("github.cache_size", 500)
+	v.SetDefault("github.report_status", true)
+	v.SetDefault("github.comment_interval", "10s")
+	v.SetDefault("github.comment_coalesce_window", "1m")
+	v.SetDefault("github.labels.status", true)
+	v.SetDefault("github.draft_prs", true)
+	v.SetDefault("post_merge.close_issue", true)
+	v.SetDefault("post_merge.delete_branch", true)
+	v.SetDefault("post_merge.remove_label", true)
+	v.SetDefault("abort.delete_branch", true)
+	v.SetDefault("abort.close_pr", true)
+	v.SetDefault("approval.interval", "5m")
+	v.SetDefault("ci.fix_failures", false)
+	v.SetDefault("ci.max_fix_attempts", 2)
+	v.SetDefault("ci.log_bytes", 20000)
+	v.SetDefault("job.retention.interval", "1h")
+	v.SetDefault("job.detect_tests", false)
+	v.SetDefault("job.rebase", true)
+	v.SetDefault("job.duplicates", DuplicatesWarn)
+	v.SetDefault("job.default_image", "mcr.microsoft.com/devcontainers/base:ubuntu")
+	v.SetDefault("job.ports", "ephemeral")
+	v.SetDefault("job.health.enabled", true)
+	v.SetDefault("job.health.interval", "30s")
+	v.SetDefault("job.health.timeout", "10s")
+	v.SetDefault("job.monitor.interval", "15s")
+	v.SetDefault("job.monitor.memory_warn", 90)
+	v.SetDefault("job.reaper.interval", "5m")
+	v.SetDefault("job.reaper.stale_after", "30m")
+	v.SetDefault("job.resume.enabled", true)
+	v.SetDefault("job.resume.max_attempts", 2)
+	v.SetDefault("job.planning.mode", "container")
+	v.SetDefault("job.planning.model", "claude-sonnet-4-5")
+	v.SetDefault("job.planning.max_turns", 30)
+	v.SetDefault("job.planning.max_tokens", 8192)
+	v.SetDefault("job.planning.base_url", "https://api.anthropic.com")
+	v.SetDefault("job.planning.code_map", true)
+	v.SetDefault("job.planning.code_map_bytes", 20000)
+	v.SetDefault("job.planning.clarify_rounds", 0)
+	v.SetDefault("job.triage.model", "claude-haiku-4-5")
+	v.SetDefault("job.triage.max_turns", 15)
+	v.SetDefault("job.triage.max_tokens", 4096)
+	v.SetDefault("job.triage.open_issues", 50)
+	v.SetDefault("job.output.max_bytes", 10<<20)
+	v.SetDefault("job.output.max_line_bytes", 8192)
+	v.SetDefault("database.driver", "sqlite")
+	v.SetDefault("database.replication.interval", "1m")
+	v.SetDefault("database.archive.interval", "1h")
+	v.SetDefault("queue.autoscale.cooldown", "5m")
+	v.SetDefault("queue.autoscale.interval", "15s")
+	v.SetDefault("secrets.key", "keyring")
+	v.SetDefault("uploads.threshold", 20000)
+	v.SetDefault("limits.container_wait", "60s")
+	v.SetDefault("limits.container_poll", "500ms")
+	v.SetDefault("limits.http_timeout", "30s")
+	v.SetDefault("limits.rate_limit_buffer", 100)
+	v.SetDefault("tickets.sync_issues", false)
+	v.SetDefault("tickets.close_issues", true)
+}
+
+// applyDerivedDefaults fills the settings whose defaults depend on others.
+func (c *Config) applyDerivedDefaults() {
+	// Paths below the data directory
+	if c.DataDir == "" {
+		c.DataDir = defaultDataDir()
+	}
+	if c.ProjectsDir == "" {
+		c.ProjectsDir = filepath.Join(c.DataDir, "projects")
+	}
+	if c.JobsDir == "" {
+		c.JobsDir = filepath.Join(c.DataDir, "jobs")
+	}
+	if c.TicketsDir == "" {
+		c.TicketsDir = filepath.Join(c.DataDir, "tickets")
+	}
+	if c.Credentials.ClaudeCredentialsFile == "" {
+		c.Credentials.ClaudeCredentialsFile = filepath.Join(c.DataDir, "config", ".credentials.json")
+	}
+	if c.Claude.BundlePath == "" {
+		c.Claude.BundlePath = filepath.Join(c.DataDir, "claude-bundle")
+	}
+	if c.Claude.BundlesDir == "" {
+		c.Claude.BundlesDir = filepath.Join(c.DataDir, "bundles")
+	}
+	if c.Database.Path == "" {
+		c.Database.Path = filepath.Join(c.DataDir, "manfred.db")
+	}
+	if c.Secrets.Path == "" {
+		c.Secrets.Path = filepath.Join(c.DataDir, "secrets.json")
+	}
+	if c.Secrets.KeyFile == "" {
+		c.Secrets.KeyFile = filepath.Join(c.DataDir, "secrets.key")
+	}
+	if c.GitHub.RateLimitBuffer == 0 {
+		c.GitHub.RateLimitBuffer = c.Limits.RateLimitBuffer
+	}
+}
+
 // ProjectConfig loads the configuration for a specific project.
 func (c *Config) ProjectConfig(name string) (*ProjectConfig, error) {
 	projectYml := filepath.Join(c.ProjectsDir, name, "project.yml")
@@ -180,15 +992,294 @@ func (c *Config) ProjectConfig(name string) (*ProjectConfig, error) {
 	if projCfg.DefaultBranch == "" {
 		projCfg.DefaultBranch = "main"
 	}
+	if projCfg.Test.Command != "" && projCfg.Test.MaxFixAttempts == 0 {
+		projCfg.Test.MaxFixAttempts = 2
+	}
+	if _, err := projCfg.Docker.Resources.MemoryBytes(); err != nil {
+		return nil, fmt.Errorf("invalid docker.resources.memory: %w", err)
+	}
+	if projCfg.Docker.Resources.CPUs < 0 || projCfg.Docker.Resources.Pids < 0 {
+		return nil, fmt.Errorf("invalid docker.resources: cpus and pids must not be negative")
+	}
+	if projCfg.Planning != "" && projCfg.Planning != PlanningContainer && projCfg.Planning != PlanningAPI {
+		return nil, fmt.Errorf("invalid planning %q (want %s or %s)", projCfg.Planning, PlanningContainer, PlanningAPI)
+	}
+	if err := validateRepos(projCfg); err != nil {
+		return nil, err
+	}
+	if err := projCfg.Approval.validate("approval"); err != nil {
+		return nil, err
+	}
+	if _, err := github.ParsePRTemplate(projCfg.PRTemplate); err != nil {
+		return nil, fmt.Errorf("invalid pr_template: %w", err)
+	}
+	switch projCfg.PullRequest.AutoMerge {
+	case "", MergeMethodMerge, MergeMethodSquash, MergeMethodRebase, MergeOff:
+	default:
+		return nil, fmt.Errorf("invalid pull_request.auto_merge %q (want %s, %s, %s or %s)", projCfg.PullRequest.AutoMerge,
+			MergeMethodMerge, MergeMethodSquash, MergeMethodRebase, MergeOff)
+	}
+	for _, r := range projCfg.PullRequest.Reviewers {
+		if r == "" || strings.Count(r, "/") > 1 || strings.HasPrefix(r, "/") || strings.HasSuffix(r, "/") {
+			return nil, fmt.Errorf("invalid pull_request.reviewers entry %q (want a login or org/team)", r)
+		}
+	}
+	if projCfg.Git.SSHKey != "" && !filepath.IsAbs(projCfg.Git.SSHKey) {
+		projCfg.Git.SSHKey = filepath.Join(c.ProjectsDir, name, projCfg.Git.SSHKey)
+	}
 
 	return &projCfg, nil
 }
 
+// validateRepos checks the repos: of a multi-repository project.
+func validateRepos(projCfg ProjectConfig) error {
+	if len(projCfg.Repos) == 0 {
+		return nil
+	}
+	if projCfg.Repo != "" {
+		return fmt.Errorf("repo and repos are mutually exclusive")
+	}
+	names := make(map[string]bool)
+	for i, r := range projCfg.Repos {
+		if r.Name == "" || r.Name != filepath.Base(r.Name) || strings.HasPrefix(r.Name, ".") {
+			return fmt.Errorf("invalid repos[%d].name %q: want a plain directory name", i, r.Name)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("duplicate repos name %q", r.Name)
+		}
+		names[r.Name] = true
+		if r.Repo == "" {
+			return fmt.Errorf("repos[%d] (%s) has no repo URL", i, r.Name)
+		}
+	}
+	return nil
+}
+
+// FindProjectByRepo returns the project whose repo URL points at the given
+// GitHub repository. Returns an error if no project matches.
+func (c *Config) FindProjectByRepo(owner, repo string) (string, *ProjectConfig, error) {
+	entries, err := os.ReadDir(c.ProjectsDir)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read projects directory: %w", err)
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		projCfg, err := c.ProjectConfig(e.Name())
+		if err != nil {
+			continue
+		}
+		o, r, ok := ParseGitHubRepo(projCfg.Repo)
+		if ok && strings.EqualFold(o, owner) && strings.EqualFold(r, repo) {
+			return e.Name(), projCfg, nil
+		}
+	}
+
+	return "", nil, fmt.Errorf("no project configured for %s/%s", owner, repo)
+}
+
+// GitHubRepos returns "owner/repo" for every project whose repo is on
+// GitHub, in project directory order.
+func (c *Config) GitHubRepos() ([]string, error) {
+	entries, err := os.ReadDir(c.ProjectsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read projects directory: %w", err)
+	}
+
+	var repos []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		projCfg, err := c.ProjectConfig(e.Name())
+		if err != nil {
+			continue
+		}
+		if owner, repo, ok := ParseGitHubRepo(projCfg.Repo); ok {
+			repos = append(repos, owner+"/"+repo)
+		}
+	}
+	return repos, nil
+}
+
+// ParseGitHubRepo extracts owner and repository name from a GitHub clone URL.
+// Both SSH (git@github.com:owner/repo.git) and HTTPS forms are supported.
+func ParseGitHubRepo(url string) (owner, repo string, ok bool) {
+	var path string
+	switch {
+	case strings.HasPrefix(url, "git@github.com:"):
+		path = strings.TrimPrefix(url, "git@github.com:")
+	case strings.HasPrefix(url, "https://github.com/"):
+		path = strings.TrimPrefix(url, "https://github.com/")
+	case strings.HasPrefix(url, "ssh://git@github.com/"):
+		path = strings.TrimPrefix(url, "ssh://git@github.com/")
+	default:
+		return "", "", false
+	}
+
+	path = strings.TrimSuffix(strings.TrimSuffix(path, "/"), ".git")
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// ProjectContext returns the standing context of a project that is
+// prepended to every job prompt: its CLAUDE_CONTEXT.md file followed by its
+// context: setting. It is empty if the project has neither.
+func (c *Config) ProjectContext(name string, projectConfig *ProjectConfig) (string, error) {
+	var parts []string
+	data, err := os.ReadFile(filepath.Join(c.ProjectsDir, name, ProjectContextFile))
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read project context: %w", err)
+	}
+	if text := strings.TrimSpace(string(data)); text != "" {
+		parts = append(parts, text)
+	}
+	if text := strings.TrimSpace(projectConfig.Context); text != "" {
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
 // ProjectRepositoryPath returns the path to the project's repository.
 func (c *Config) ProjectRepositoryPath(name string) string {
 	return filepath.Join(c.ProjectsDir, name, "repository")
 }
 
+// ClaudeBundleDir returns the Claude bundle directory jobs use: the
+// installed bundle of version, e.g. a project's claude_version, or else of
+// claude.version; without either, the unversioned claude.bundle_path.
+func (c *Config) ClaudeBundleDir(version string) (string, error) {
+	if version == "" {
+		version = c.Claude.Version
+	}
+	if version == "" {
+		if c.Claude.BundlePath == "" {
+			return "", fmt.Errorf("claude.bundle_path or claude.version is required to run jobs")
+		}
+		info, err := os.Stat(c.Claude.BundlePath)
+		if err != nil {
+			return "", fmt.Errorf("claude.bundle_path: Claude bundle not found at %s", c.Claude.BundlePath)
+		}
+		if !info.IsDir() {
+			return "", fmt.Errorf("claude.bundle_path: %s is not a directory", c.Claude.BundlePath)
+		}
+		return c.Claude.BundlePath, nil
+	}
+
+	if strings.ContainsAny(version, `/\`) || version == "." || version == ".." {
+		return "", fmt.Errorf("invalid Claude bundle version %q", version)
+	}
+	dir := filepath.Join(c.Claude.BundlesDir, version)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("Claude bundle %s is not installed, run `manfred bundle install %s`", version, version)
+	}
+	return dir, nil
+}
+
+// ProjectCachePath returns the path of a project's bare mirror used as
+// clone cache.
+func (c *Config) ProjectCachePath(name string) string {
+	return filepath.Join(c.ProjectsDir, name, "cache.git")
+}
+
+// DiffPath returns where the full patch of a session job is kept when it
+// is too large for its job_diff event. Unlike the job directory it is not
+// removed by job cleanup.
+func (c *Config) DiffPath(jobID string) string {
+	return filepath.Join(c.DataDir, "diffs", jobID+".patch")
+}
+
+// GitSSHKey returns the private key for SSH access to a project's
+// repository: the project's deploy key, or job.ssh_key.
+func (c *Config) GitSSHKey(project *ProjectConfig) string {
+	if project != nil && project.Git.SSHKey != "" {
+		return project.Git.SSHKey
+	}
+	return c.Job.SSHKey
+}
+
+// PlanningMode returns how a project's plan-only jobs run: planning from
+// project.yml, else job.planning.mode.
+func (c *Config) PlanningMode(project *ProjectConfig) string {
+	if project != nil && project.Planning != "" {
+		return project.Planning
+	}
+	if c.Job.Planning.Mode == "" {
+		return PlanningContainer
+	}
+	return c.Job.Planning.Mode
+}
+
+// ApprovalPolicy returns a project's approval policy: approval: from
+// project.yml, with its unset fields taken from approval:.
+func (c *Config) ApprovalPolicy(project *ProjectConfig) ApprovalConfig {
+	policy := c.Approval
+	if project == nil {
+		return policy
+	}
+	p := project.Approval
+	if p.AutoApproveLabels != nil {
+		policy.AutoApproveLabels = p.AutoApproveLabels
+	}
+	if p.Approvers != 0 {
+		policy.Approvers = p.Approvers
+	}
+	if p.RemindAfter != 0 {
+		policy.RemindAfter = p.RemindAfter
+	}
+	if p.ExpireAfter != 0 {
+		policy.ExpireAfter = p.ExpireAfter
+	}
+	return policy
+}
+
+// DraftPRs reports whether a project's session pull requests open as
+// drafts: pull_request.draft from project.yml, else github.draft_prs.
+func (c *Config) DraftPRs(project *ProjectConfig) bool {
+	if project != nil && project.PullRequest.Draft != nil {
+		return *project.PullRequest.Draft
+	}
+	return c.GitHub.DraftPRs
+}
+
+// Rebase reports whether a project's job branches are rebased onto their
+// base branch before pushing when it moved: git.rebase from project.yml,
+// else job.rebase.
+func (c *Config) Rebase(project *ProjectConfig) bool {
+	if project != nil && project.Git.Rebase != nil {
+		return *project.Git.Rebase
+	}
+	return c.Job.Rebase
+}
+
+// AutoMerge returns the method a project's approved session pull requests
+// are merged with once their checks pass: pull_request.auto_merge from
+// project.yml, else github.auto_merge. Empty means they are not merged.
+func (c *Config) AutoMerge(project *ProjectConfig) string {
+	method := c.GitHub.AutoMerge
+	if project != nil && project.PullRequest.AutoMerge != "" {
+		method = project.PullRequest.AutoMerge
+	}
+	if method == MergeOff {
+		return ""
+	}
+	return method
+}
+
+// PRTemplate returns the template of a project's session pull request
+// bodies: pr_template from project.yml, else github.pr_template.
+func (c *Config) PRTemplate(project *ProjectConfig) string {
+	if project != nil && project.PRTemplate != "" {
+		return project.PRTemplate
+	}
+	return c.GitHub.PRTemplate
+}
+
 // EnsureDirectories creates all required directories.
 func (c *Config) EnsureDirectories() error {
 	dirs := []string{
@@ -0,0 +1,215 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestPromptConfigWrap(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    PromptConfig
+		prompt string
+		want   string
+	}{
+		{"empty", PromptConfig{}, "Fix the bug", "Fix the bug"},
+		{"prefix", PromptConfig{Prefix: "Never touch generated files.\n"}, "Fix the bug", "Never touch generated files.\n\nFix the bug"},
+		{"suffix", PromptConfig{Suffix: "Always run make test before finishing."}, "Fix the bug\n", "Fix the bug\n\nAlways run make test before finishing."},
+		{"both", PromptConfig{Prefix: "A", Suffix: "Z"}, "task", "A\n\ntask\n\nZ"},
+		{"blank", PromptConfig{Prefix: "  \n", Suffix: "\n"}, "task", "task"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.Wrap(tt.prompt); got != tt.want {
+				t.Errorf("Wrap(%q) = %q, want %q", tt.prompt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProjectContext(t *testing.T) {
+	cfg := &Config{ProjectsDir: t.TempDir()}
+	if err := os.MkdirAll(filepath.Join(cfg.ProjectsDir, "widgets"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := cfg.ProjectContext("widgets", &ProjectConfig{})
+	if err != nil || got != "" {
+		t.Errorf("ProjectContext() without context = %q, %v; want empty", got, err)
+	}
+
+	got, _ = cfg.ProjectContext("widgets", &ProjectConfig{Context: "Use tabs.\n"})
+	if got != "Use tabs." {
+		t.Errorf("ProjectContext() from context: = %q, want %q", got, "Use tabs.")
+	}
+
+	file := filepath.Join(cfg.ProjectsDir, "widgets", ProjectContextFile)
+	if err := os.WriteFile(file, []byte("# Architecture\n\nHexagonal.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = cfg.ProjectContext("widgets", &ProjectConfig{Context: "Use tabs."})
+	if want := "# Architecture\n\nHexagonal.\n\nUse tabs."; got != want {
+		t.Errorf("ProjectContext() = %q, want %q", got, want)
+	}
+}
+
+func TestApplyDefaultImage(t *testing.T) {
+	cfg := &Config{ProjectsDir: t.TempDir()}
+	repoPath := cfg.ProjectRepositoryPath("widgets")
+	if err := os.MkdirAll(repoPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	compose := DockerConfig{ComposeFile: "docker-compose.yml"}
+
+	p := &ProjectConfig{Docker: compose}
+	if err := cfg.ApplyDefaultImage("widgets", p); err == nil {
+		t.Error("ApplyDefaultImage() without job.default_image succeeded, want error")
+	}
+
+	cfg.Job.DefaultImage = "debian:12"
+	p = &ProjectConfig{Docker: compose}
+	if err := cfg.ApplyDefaultImage("widgets", p); err != nil || p.Docker.Image != "debian:12" || !p.Docker.Fallback {
+		t.Errorf("ApplyDefaultImage() = %v, docker = %+v; want the default image", err, p.Docker)
+	}
+
+	p = &ProjectConfig{Docker: DockerConfig{ComposeFile: "docker-compose.yml", Image: "golang:1.23"}}
+	if err := cfg.ApplyDefaultImage("widgets", p); err != nil || p.Docker.Image != "golang:1.23" || p.Docker.Fallback {
+		t.Errorf("ApplyDefaultImage() with docker.image = %v, docker = %+v", err, p.Docker)
+	}
+
+	if err := os.WriteFile(filepath.Join(repoPath, "docker-compose.yml"), []byte("services: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p = &ProjectConfig{Docker: compose}
+	if err := cfg.ApplyDefaultImage("widgets", p); err != nil || p.Docker.Native() {
+		t.Errorf("ApplyDefaultImage() with a compose file = %v, docker = %+v; want compose", err, p.Docker)
+	}
+}
+
+func TestValidateRepos(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ProjectConfig
+		wantErr bool
+	}{
+		{"single repo", ProjectConfig{Repo: "git@github.com:acme/api.git"}, false},
+		{"repos", ProjectConfig{Repos: []RepoConfig{{Name: "api", Repo: "a"}, {Name: "web", Repo: "b"}}}, false},
+		{"repo and repos", ProjectConfig{Repo: "a", Repos: []RepoConfig{{Name: "api", Repo: "a"}}}, true},
+		{"duplicate name", ProjectConfig{Repos: []RepoConfig{{Name: "api", Repo: "a"}, {Name: "api", Repo: "b"}}}, true},
+		{"path name", ProjectConfig{Repos: []RepoConfig{{Name: "../api", Repo: "a"}}}, true},
+		{"hidden name", ProjectConfig{Repos: []RepoConfig{{Name: ".git", Repo: "a"}}}, true},
+		{"no url", ProjectConfig{Repos: []RepoConfig{{Name: "api"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateRepos(tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("validateRepos() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApprovalPolicy(t *testing.T) {
+	cfg := &Config{Approval: ApprovalConfig{
+		AutoApproveLabels: []string{"docs"},
+		Approvers:         2,
+		ExpireAfter:       72 * time.Hour,
+		Interval:          5 * time.Minute,
+	}}
+
+	if got := cfg.ApprovalPolicy(nil); got.Approvers != 2 || got.ExpireAfter != 72*time.Hour {
+		t.Errorf("ApprovalPolicy(nil) = %+v, want approval:", got)
+	}
+
+	project := &ProjectConfig{Approval: ApprovalConfig{AutoApproveLabels: []string{}, RemindAfter: 24 * time.Hour}}
+	got := cfg.ApprovalPolicy(project)
+	if len(got.AutoApproveLabels) != 0 || got.RemindAfter != 24*time.Hour {
+		t.Errorf("ApprovalPolicy() = %+v, want the project's labels and reminder", got)
+	}
+	if got.Approvers != 2 || got.ExpireAfter != 72*time.Hour || got.Interval != 5*time.Minute {
+		t.Errorf("ApprovalPolicy() = %+v, want the rest from approval:", got)
+	}
+}
+
+func TestPullRequestConfig(t *testing.T) {
+	cfg := &Config{GitHub: GitHubConfig{DraftPRs: true}}
+	no := false
+	if !cfg.DraftPRs(nil) || cfg.DraftPRs(&ProjectConfig{PullRequest: PullRequestConfig{Draft: &no}}) {
+		t.Error("DraftPRs() does not honor github.draft_prs and pull_request.draft")
+	}
+
+	users, teams := PullRequestConfig{Reviewers: []string{"alice", "acme/backend", "bob"}}.ReviewerLists()
+	if len(users) != 2 || users[1] != "bob" || len(teams) != 1 || teams[0] != "backend" {
+		t.Errorf("ReviewerLists() = %v, %v; want alice, bob and the backend team", users, teams)
+	}
+
+	cfg.GitHub.AutoMerge = MergeMethodSquash
+	for _, tt := range []struct{ project, want string }{
+		{"", MergeMethodSquash},
+		{MergeMethodRebase, MergeMethodRebase},
+		{MergeOff, ""},
+	} {
+		if got := cfg.AutoMerge(&ProjectConfig{PullRequest: PullRequestConfig{AutoMerge: tt.project}}); got != tt.want {
+			t.Errorf("AutoMerge(%q) = %q, want %q", tt.project, got, tt.want)
+		}
+	}
+}
+
+func TestRebase(t *testing.T) {
+	cfg := &Config{Job: JobConfig{Rebase: true}}
+	no := false
+	if !cfg.Rebase(nil) || cfg.Rebase(&ProjectConfig{Git: GitConfig{Rebase: &no}}) {
+		t.Error("Rebase() does not honor job.rebase and git.rebase")
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	path := filepath.Join(t.TempDir(), "manfred.yaml")
+	if err := os.WriteFile(path, []byte("github:\n  owner: acme\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReadFile(path); err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if got := viper.GetString("github.owner"); got != "acme" {
+		t.Errorf("github.owner = %q, want acme", got)
+	}
+
+	// An explicit path must exist
+	viper.Reset()
+	if err := ReadFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil || !strings.Contains(err.Error(), "failed to read config") {
+		t.Errorf("ReadFile() of a missing file error = %v, want failed to read config", err)
+	}
+}
+
+func TestProjectConfigPRTemplate(t *testing.T) {
+	cfg := &Config{ProjectsDir: t.TempDir()}
+	write := func(tmpl string) {
+		t.Helper()
+		dir := filepath.Join(cfg.ProjectsDir, "widgets")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		yml := "repo: https://github.com/acme/widgets.git\npr_template: |\n  " + tmpl + "\n"
+		if err := os.WriteFile(filepath.Join(dir, "project.yml"), []byte(yml), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The template functions of pull request bodies parse
+	write("{{with .Tests}}{{fence .Output}}{{end}}")
+	if name, _, err := cfg.FindProjectByRepo("acme", "widgets"); err != nil || name != "widgets" {
+		t.Errorf("FindProjectByRepo() = %q, %v, want widgets", name, err)
+	}
+
+	write("{{.IssueNumber")
+	if _, err := cfg.ProjectConfig("widgets"); err == nil || !strings.Contains(err.Error(), "invalid pr_template") {
+		t.Errorf("ProjectConfig() error = %v, want invalid pr_template", err)
+	}
+}
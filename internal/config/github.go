@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/mpm/manfred/internal/github"
+)
+
+// GitHubClient creates a GitHub API client from the configuration, using
+// GitHub App authentication when an app ID is configured and the personal
+// access token otherwise.
+func (c *Config) GitHubClient() (*github.Client, error) {
+	opts := []github.ClientOption{
+		github.WithRateLimitBuffer(c.GitHub.RateLimitBuffer),
+		github.WithRateLimitWait(c.GitHub.RateLimitWait),
+		github.WithRetries(c.GitHub.MaxRetries),
+		github.WithResponseCache(c.GitHub.CacheSize),
+		github.WithHTTPClient(&http.Client{Timeout: c.Limits.HTTPTimeout}),
+	}
+
+	if c.GitHub.AppID != 0 {
+		if c.GitHub.InstallationID == 0 || c.GitHub.PrivateKeyFile == "" {
+			return nil, fmt.Errorf("github.app_id requires github.installation_id and github.private_key_file")
+		}
+		data, err := os.ReadFile(c.GitHub.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GitHub App private key: %w", err)
+		}
+		key, err := github.ParsePrivateKey(data)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, github.WithAppAuth(c.GitHub.AppID, c.GitHub.InstallationID, key))
+	}
+
+	return github.NewClient(c.GitHub.Token, opts...), nil
+}
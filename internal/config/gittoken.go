@@ -0,0 +1,61 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/mpm/manfred/internal/github"
+)
+
+// appClients caches a GitHub client per App installation, so git reuses an
+// installation token until it is about to expire.
+var appClients sync.Map // "appID/installationID" -> *github.Client
+
+// GitToken returns the token that authenticates HTTPS requests to url.
+// Only github.com repositories get a GitHub token: an installation token of
+// the GitHub App when github.app_id is set, else github.token. Other hosts
+// rely on the host's git credentials.
+func (c *Config) GitToken(ctx context.Context, url string) (string, error) {
+	if !strings.HasPrefix(url, "https://github.com/") {
+		return "", nil
+	}
+	if c.GitHub.AppID == 0 {
+		return c.GitHub.Token, nil
+	}
+	client, err := c.appClient()
+	if err != nil {
+		return "", err
+	}
+	token, err := client.Token(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get a GitHub App token for git: %w", err)
+	}
+	return token, nil
+}
+
+// appClient returns the cached client of the configured GitHub App
+// installation, creating it on first use.
+func (c *Config) appClient() (*github.Client, error) {
+	key := fmt.Sprintf("%d/%d", c.GitHub.AppID, c.GitHub.InstallationID)
+	if client, ok := appClients.Load(key); ok {
+		return client.(*github.Client), nil
+	}
+	data, err := os.ReadFile(c.GitHub.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GitHub App private key: %w", err)
+	}
+	privateKey, err := github.ParsePrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+	client := github.NewClient("",
+		github.WithAppAuth(c.GitHub.AppID, c.GitHub.InstallationID, privateKey),
+		github.WithHTTPClient(&http.Client{Timeout: c.Limits.HTTPTimeout}),
+	)
+	actual, _ := appClients.LoadOrStore(key, client)
+	return actual.(*github.Client), nil
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mpm/manfred/internal/github"
+)
+
+func TestGitToken(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{GitHub: GitHubConfig{Token: "ghp_pat"}}
+
+	for url, want := range map[string]string{
+		"https://github.com/acme/widgets.git": "ghp_pat",
+		"https://gitlab.com/acme/widgets.git": "",
+		"git@github.com:acme/widgets.git":     "",
+	} {
+		if got, err := cfg.GitToken(ctx, url); err != nil || got != want {
+			t.Errorf("GitToken(%s) = %q, %v, want %q", url, got, err, want)
+		}
+	}
+
+	// GitHub Apps use an installation token instead of github.token
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/app/installations/2/access_tokens" {
+			t.Errorf("unexpected request: %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"token": "ghs_installation", "expires_at": "2099-01-01T00:00:00Z"}`))
+	}))
+	defer server.Close()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appClients.Store("1/2", github.NewClient("", github.WithAppAuth(1, 2, key), github.WithBaseURL(server.URL)))
+	defer appClients.Delete("1/2")
+
+	cfg.GitHub.AppID, cfg.GitHub.InstallationID = 1, 2
+	if got, err := cfg.GitToken(ctx, "https://github.com/acme/widgets.git"); err != nil || got != "ghs_installation" {
+		t.Errorf("GitToken() for an app = %q, %v, want the installation token", got, err)
+	}
+
+	cfg.GitHub.InstallationID = 3
+	cfg.GitHub.PrivateKeyFile = filepath.Join(t.TempDir(), "missing.pem")
+	if _, err := cfg.GitToken(ctx, "https://github.com/acme/widgets.git"); err == nil || !strings.Contains(err.Error(), "private key") {
+		t.Errorf("GitToken() without the app's key error = %v, want a private key error", err)
+	}
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/mpm/manfred/internal/secrets"
+)
+
+// resolveTimeout bounds resolving all secret references at load.
+const resolveTimeout = 30 * time.Second
+
+// SecretsConfig locates the encrypted secrets store, which holds tokens and
+// credentials in place of the plaintext settings. See `manfred secrets`.
+type SecretsConfig struct {
+	Path    string `mapstructure:"path"`     // Encrypted store; default <data_dir>/secrets.json
+	Key     string `mapstructure:"key"`      // Where the store key lives: keyring, file or env
+	KeyFile string `mapstructure:"key_file"` // Key file for key: file; default <data_dir>/secrets.key
+
+	Vault VaultConfig `mapstructure:"vault"` // For vault: references
+}
+
+// VaultConfig locates HashiCorp Vault for vault: references. Empty settings
+// fall back to VAULT_ADDR, VAULT_TOKEN, ~/.vault-token and VAULT_NAMESPACE.
+type VaultConfig struct {
+	Addr      string `mapstructure:"addr"`
+	TokenFile string `mapstructure:"token_file"`
+	Namespace string `mapstructure:"namespace"`
+}
+
+// SecretsStore returns the encrypted secrets store. It is opened once per
+// Config, so its key is fetched at most once.
+func (c *Config) SecretsStore() (*secrets.Store, error) {
+	if c.secrets != nil {
+		return c.secrets, nil
+	}
+	source, err := secrets.NewKeySource(c.Secrets.Key, c.Secrets.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("secrets.key: %w", err)
+	}
+	c.secrets = secrets.Open(c.Secrets.Path, source)
+	return c.secrets, nil
+}
+
+// hasSecret reports whether the store holds a secret, without decrypting it.
+func (c *Config) hasSecret(name string) bool {
+	if c.Secrets.Path == "" {
+		return false
+	}
+	store, err := c.SecretsStore()
+	return err == nil && store.Has(name)
+}
+
+// secretResolver returns the resolver for secret references: vault:,
+// sops:, env-file: and store:.
+func (c *Config) secretResolver() *secrets.Resolver {
+	providers := map[string]secrets.Provider{
+		"vault": secrets.VaultProvider(secrets.VaultOptions{
+			Addr:      c.Secrets.Vault.Addr,
+			TokenFile: c.Secrets.Vault.TokenFile,
+			Namespace: c.Secrets.Vault.Namespace,
+			Client:    &http.Client{Timeout: c.Limits.HTTPTimeout},
+		}),
+		"sops":     secrets.SOPSProvider(),
+		"env-file": secrets.EnvFileProvider(),
+	}
+	if store, err := c.SecretsStore(); err == nil {
+		providers["store"] = secrets.StoreProvider(store)
+	}
+	return secrets.NewResolver(providers)
+}
+
+// resolveSecretRefs replaces secret settings written as references, like
+// vault:kv/manfred#github_token, with the secrets they point to.
+func (c *Config) resolveSecretRefs() error {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	return resolveRefs(ctx, c.secretResolver(), reflect.ValueOf(c).Elem(), "")
+}
+
+// resolveRefs resolves the references in the secret settings of v, found at
+// key. It walks v like settingsValue.
+func resolveRefs(ctx context.Context, r *secrets.Resolver, v reflect.Value, key string) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name := t.Field(i).Tag.Get("mapstructure")
+			if name == "" || name == "-" {
+				continue
+			}
+			if err := resolveRefs(ctx, r, v.Field(i), joinKey(key, name)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := resolveRefs(ctx, r, v.Index(i), key); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		if !secretKeys[key] {
+			return nil
+		}
+		ref, ok := r.ParseRef(v.String())
+		if !ok {
+			return nil
+		}
+		value, err := r.Resolve(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", key, err)
+		}
+		v.SetString(value)
+	}
+	return nil
+}
+
+// loadSecrets decrypts github.token and github.webhook_secret from the store
+// where neither the config file nor the environment sets them. Both are
+// needed by most commands, so they are kept in memory for the process.
+func (c *Config) loadSecrets() error {
+	fields := map[string]*string{
+		secrets.GitHubToken:   &c.GitHub.Token,
+		secrets.WebhookSecret: &c.GitHub.WebhookSecret,
+	}
+	for name, field := range fields {
+		if *field != "" || !c.hasSecret(name) {
+			continue
+		}
+		value, err := c.secrets.Get(name)
+		if err != nil {
+			return fmt.Errorf("%w (check it with `manfred secrets unlock`)", err)
+		}
+		*field = value
+	}
+	return nil
+}
+
+// AnthropicAPIKey returns credentials.anthropic_api_key, or decrypts it from
+// the secrets store. Callers decrypt it when injecting it into a job, so it
+// is not kept in the Config.
+func (c *Config) AnthropicAPIKey() (string, error) {
+	if c.Credentials.AnthropicAPIKey != "" || !c.hasSecret(secrets.AnthropicAPIKey) {
+		return c.Credentials.AnthropicAPIKey, nil
+	}
+	return c.secrets.Get(secrets.AnthropicAPIKey)
+}
+
+// ClaudeCredentials returns the Claude credentials to copy into a job: the
+// file at credentials.claude_credentials_file, or else the stored secret. It
+// returns nil if there are none.
+func (c *Config) ClaudeCredentials() ([]byte, error) {
+	if c.ClaudeCredentialsExist() {
+		data, err := os.ReadFile(c.Credentials.ClaudeCredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read credentials: %w", err)
+		}
+		return data, nil
+	}
+	if !c.hasSecret(secrets.ClaudeCredentials) {
+		return nil, nil
+	}
+	value, err := c.secrets.Get(secrets.ClaudeCredentials)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
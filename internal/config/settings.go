@@ -0,0 +1,220 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted replaces secret values in Settings.
+const Redacted = "<redacted>"
+
+// secretKeys are the settings Settings redacts. Keys inside lists, like
+// notify.sinks, leave out the index. Chat webhook URLs carry their token,
+// so notify sink URLs count as secrets.
+var secretKeys = map[string]bool{
+	"credentials.anthropic_api_key": true,
+	"database.url":                  true,
+	"github.token":                  true,
+	"github.webhook_secret":         true,
+	"server.auth.token":             true,
+	"server.auth.password":          true,
+	"queue.autoscale.secret":        true,
+	"notify.sinks.url":              true,
+	"notify.sinks.secret":           true,
+	"notify.sinks.token":            true,
+	"notify.sinks.user":             true,
+	"notify.sinks.email.password":   true,
+}
+
+// Settings returns the configuration as nested maps keyed like the config
+// file, with secrets replaced by Redacted, e.g. for `manfred config show`.
+func (c *Config) Settings() map[string]any {
+	return settingsValue(reflect.ValueOf(*c), "").(map[string]any)
+}
+
+// settingsValue converts v, found at key, into maps, lists and scalars.
+func settingsValue(v reflect.Value, key string) any {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		m := map[string]any{}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name := t.Field(i).Tag.Get("mapstructure")
+			if name == "" || name == "-" {
+				continue
+			}
+			m[name] = settingsValue(v.Field(i), joinKey(key, name))
+		}
+		return m
+	case reflect.Slice:
+		if v.IsNil() {
+			return []any{}
+		}
+		list := make([]any, v.Len())
+		for i := range list {
+			list[i] = settingsValue(v.Index(i), key)
+		}
+		return list
+	case reflect.Map:
+		m := map[string]any{}
+		iter := v.MapRange()
+		for iter.Next() {
+			name := fmt.Sprint(iter.Key().Interface())
+			m[name] = settingsValue(iter.Value(), joinKey(key, name))
+		}
+		return m
+	}
+
+	if secretKeys[key] && !v.IsZero() {
+		return Redacted
+	}
+	return v.Interface()
+}
+
+// joinKey appends name to a dotted key.
+func joinKey(key, name string) string {
+	if key == "" {
+		return name
+	}
+	return key + "." + name
+}
+
+// IsKnownKey reports whether key, e.g. "github.poll_interval", names a
+// setting. Any key below a map setting such as notify.templates is known;
+// list settings such as notify.sinks can only be set as a whole.
+func IsKnownKey(key string) bool {
+	t := reflect.TypeOf(Config{})
+	for _, part := range strings.Split(key, ".") {
+		switch t.Kind() {
+		case reflect.Map:
+			return part != ""
+		case reflect.Struct:
+			field, ok := fieldByTag(t, part)
+			if !ok {
+				return false
+			}
+			t = field.Type
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// fieldByTag finds the field of struct type t with the mapstructure tag name.
+func fieldByTag(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.Tag.Get("mapstructure") == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// SetInFile sets key to value in the YAML config file at path, creating the
+// file and any missing parent mappings. value is parsed as YAML, so "true",
+// "30s" or "[a, b]" keep their types. Comments and the order of the other
+// settings are preserved.
+func SetInFile(path, key, value string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		// Empty or comments only
+		doc.Kind = yaml.DocumentNode
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("config file %s is not a mapping", path)
+	}
+
+	newValue, err := parseValue(value)
+	if err != nil {
+		return err
+	}
+
+	parts := strings.Split(key, ".")
+	node := root
+	for i, part := range parts {
+		child := mappingValue(node, part)
+		if i == len(parts)-1 {
+			if child != nil {
+				newValue.HeadComment, newValue.LineComment, newValue.FootComment = child.HeadComment, child.LineComment, child.FootComment
+				*child = *newValue
+			} else {
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: part}, newValue)
+			}
+			break
+		}
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: part}, child)
+		}
+		if child.Kind != yaml.MappingNode {
+			return fmt.Errorf("cannot set %s: %s is not a mapping", key, strings.Join(parts[:i+1], "."))
+		}
+		node = child
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to encode config file: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("failed to encode config file: %w", err)
+	}
+
+	// The config file holds secrets, so new files are private
+	mode := fs.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.WriteFile(path, buf.Bytes(), mode); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// parseValue parses a command line value as a YAML node; a value without
+// YAML content, e.g. "", is kept as a string.
+func parseValue(value string) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &doc); err != nil {
+		return nil, fmt.Errorf("invalid value %q: %w", value, err)
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}, nil
+	}
+	return doc.Content[0], nil
+}
+
+// mappingValue returns the value node of key in a mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConfigSettingsRedactsSecrets(t *testing.T) {
+	cfg := &Config{
+		Credentials: CredentialsConfig{AnthropicAPIKey: "sk-ant-secret"},
+		GitHub:      GitHubConfig{Token: "ghp_secret", PollInterval: time.Minute},
+		Notify: NotifyConfig{Sinks: []NotifySinkConfig{
+			{Type: "slack", URL: "https://hooks.slack.com/services/T/B/secret"},
+		}},
+	}
+
+	settings := cfg.Settings()
+	github := settings["github"].(map[string]any)
+	if got := github["token"]; got != Redacted {
+		t.Errorf("github.token = %v, want %q", got, Redacted)
+	}
+	if got := github["webhook_secret"]; got != "" {
+		t.Errorf("empty github.webhook_secret = %v, want it left empty", got)
+	}
+	if got := github["poll_interval"]; got != "1m0s" {
+		t.Errorf("github.poll_interval = %v, want 1m0s", got)
+	}
+	if got := settings["credentials"].(map[string]any)["anthropic_api_key"]; got != Redacted {
+		t.Errorf("credentials.anthropic_api_key = %v, want %q", got, Redacted)
+	}
+	sink := settings["notify"].(map[string]any)["sinks"].([]any)[0].(map[string]any)
+	if sink["url"] != Redacted || sink["type"] != "slack" {
+		t.Errorf("notify.sinks[0] = %v, want type slack and url redacted", sink)
+	}
+}
+
+func TestIsKnownKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"github.poll_interval", true},
+		{"github", true},
+		{"job.planning.mode", true},
+		{"notify.templates.job_failed", true},
+		{"notify.sinks", true},
+		{"notify.sinks.url", false},
+		{"github.pol_interval", false},
+		{"nope", false},
+	}
+	for _, tt := range tests {
+		if got := IsKnownKey(tt.key); got != tt.want {
+			t.Errorf("IsKnownKey(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestSetInFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	original := `# MANFRED config
+server:
+  port: 8080 # default
+github:
+  token: abc
+`
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetInFile(path, "server.port", "9090"); err != nil {
+		t.Fatalf("SetInFile(server.port) error = %v", err)
+	}
+	if err := SetInFile(path, "job.planning.mode", "api"); err != nil {
+		t.Fatalf("SetInFile(job.planning.mode) error = %v", err)
+	}
+	if err := SetInFile(path, "triggers.labels", "[manfred, ai]"); err != nil {
+		t.Fatalf("SetInFile(triggers.labels) error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# MANFRED config
+server:
+  port: 9090 # default
+github:
+  token: abc
+job:
+  planning:
+    mode: api
+triggers:
+  labels: [manfred, ai]
+`
+	if string(data) != want {
+		t.Errorf("config file =\n%s\nwant\n%s", data, want)
+	}
+
+	if err := SetInFile(path, "github.token.value", "x"); err == nil {
+		t.Error("SetInFile() below a scalar = nil, want error")
+	}
+}
+
+func TestSetInFileCreatesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := SetInFile(path, "github.token", "abc"); err != nil {
+		t.Fatalf("SetInFile() error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "github:\n  token: abc\n" {
+		t.Errorf("config file = %q", data)
+	}
+}
+
+func TestConfigResolveSecretRefs(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, "manfred.env")
+	if err := os.WriteFile(envFile, []byte("GITHUB_TOKEN=ghp_file\nSLACK_URL=https://hooks.slack.com/services/T/B/x\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{
+		DataDir: dir,
+		GitHub:  GitHubConfig{Token: "env-file:" + envFile + "#GITHUB_TOKEN"},
+		Logging: LoggingConfig{Level: "env-file:not-a-secret"},
+		Notify: NotifyConfig{Sinks: []NotifySinkConfig{
+			{Type: "slack", URL: "env-file:" + envFile + "#SLACK_URL"},
+		}},
+		Secrets: SecretsConfig{Key: "file", Path: filepath.Join(dir, "secrets.json"), KeyFile: filepath.Join(dir, "secrets.key")},
+	}
+	if err := cfg.resolveSecretRefs(); err != nil {
+		t.Fatalf("resolveSecretRefs: %v", err)
+	}
+	if cfg.GitHub.Token != "ghp_file" {
+		t.Errorf("github.token = %q, want ghp_file", cfg.GitHub.Token)
+	}
+	if got := cfg.Notify.Sinks[0].URL; got != "https://hooks.slack.com/services/T/B/x" {
+		t.Errorf("notify.sinks[0].url = %q, want the Slack URL", got)
+	}
+	if cfg.Logging.Level != "env-file:not-a-secret" {
+		t.Errorf("logging.level = %q, want non-secret settings left alone", cfg.Logging.Level)
+	}
+
+	cfg.GitHub.WebhookSecret = "env-file:" + envFile + "#MISSING"
+	if err := cfg.resolveSecretRefs(); err == nil {
+		t.Error("resolveSecretRefs of a missing variable succeeded")
+	}
+}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/secrets"
+)
+
+// Validate reports invalid and contradictory settings, all of them at once
+// joined into one error. Load calls it, so no command runs with such a
+// configuration. Settings that depend on the host, like the Claude bundle,
+// are checked by ValidateJobs.
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	oneOf := func(key, value string, allowed ...string) {
+		if value != "" && !slices.Contains(allowed, value) {
+			add("%s: invalid value %q (want one of %v)", key, value, allowed)
+		}
+	}
+
+	oneOf("logging.level", c.Logging.Level, "debug", "info", "warn", "error")
+	oneOf("logging.format", c.Logging.Format, "text", "json")
+	if c.Server.Port < 0 || c.Server.Port > 65535 {
+		add("server.port: %d is out of range", c.Server.Port)
+	}
+	if u := c.Server.PublicURL; u != "" {
+		if parsed, err := url.Parse(u); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			add("server.public_url: %q is not an http(s) URL", u)
+		}
+	}
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		add("server.tls.cert_file and server.tls.key_file must be set together")
+	}
+	for _, cidr := range c.Server.WebhookAllow.CIDRs {
+		if _, err := netip.ParsePrefix(cidr); err != nil {
+			add("server.webhook_allow.cidrs: %q is not a CIDR range", cidr)
+		}
+	}
+	if (c.Server.Auth.Username == "") != (c.Server.Auth.Password == "") {
+		add("server.auth.username and server.auth.password must be set together")
+	}
+
+	for _, label := range c.Triggers.TriageLabels {
+		if slices.ContainsFunc(c.Triggers.Labels, func(l string) bool { return strings.EqualFold(l, label) }) {
+			add("triggers.triage_labels: %q also starts sessions (triggers.labels)", label)
+		}
+	}
+
+	// GitHub credentials
+	app := c.GitHub.AppID != 0
+	if app && (c.GitHub.InstallationID == 0 || c.GitHub.PrivateKeyFile == "") {
+		add("github.app_id is set, but github.installation_id and github.private_key_file are required with it")
+	}
+	if !app && (c.GitHub.InstallationID != 0 || c.GitHub.PrivateKeyFile != "") {
+		add("github.installation_id and github.private_key_file need github.app_id")
+	}
+	if c.GitHub.PollInterval < 0 {
+		add("github.poll_interval: must not be negative")
+	}
+
+	// Jobs
+	oneOf("job.cleanup", c.Job.Cleanup, "containers", "volumes", "images")
+	oneOf("job.ports", c.Job.Ports, "ephemeral", "strip", "keep")
+	oneOf("job.duplicates", c.Job.Duplicates, DuplicatesOff, DuplicatesWarn, DuplicatesSkip)
+	oneOf("job.planning.mode", c.Job.Planning.Mode, PlanningContainer, PlanningAPI)
+	if c.Job.Planning.Mode == PlanningAPI && c.Credentials.AnthropicAPIKey == "" && !c.hasSecret(secrets.AnthropicAPIKey) {
+		add("job.planning.mode %s requires credentials.anthropic_api_key or the %s secret", PlanningAPI, secrets.AnthropicAPIKey)
+	}
+	if c.Job.Planning.ClarifyRounds < 0 {
+		add("job.planning.clarify_rounds: must not be negative")
+	}
+	if len(c.Triggers.TriageLabels) > 0 && c.Credentials.AnthropicAPIKey == "" && !c.hasSecret(secrets.AnthropicAPIKey) {
+		add("triggers.triage_labels: triage runs over the Anthropic API and requires credentials.anthropic_api_key or the %s secret", secrets.AnthropicAPIKey)
+	}
+	if c.Job.Triage.MaxTurns < 0 || c.Job.Triage.MaxTokens < 0 || c.Job.Triage.OpenIssues < 0 {
+		add("job.triage: limits must not be negative")
+	}
+	if c.Job.CloneDepth < 0 {
+		add("job.clone_depth: must not be negative")
+	}
+	if c.Job.Output.MaxBytes < 0 || c.Job.Output.MaxLineBytes < 0 {
+		add("job.output: limits must not be negative")
+	}
+	if h := c.Job.Health; h.Enabled && (h.Interval <= 0 || h.Timeout <= 0) {
+		add("job.health is enabled, but job.health.interval and job.health.timeout are not positive")
+	}
+	if c.Job.Monitor.Interval < 0 {
+		add("job.monitor.interval: must not be negative")
+	}
+	if w := c.Job.Monitor.MemoryWarn; w < 0 || w > 100 {
+		add("job.monitor.memory_warn: %d is not a percentage", w)
+	}
+	if r := c.Job.Reaper; r.Interval < 0 || r.StaleAfter < 0 {
+		add("job.reaper: durations must not be negative")
+	}
+	if r := c.Job.Resume; r.Enabled && r.MaxAttempts < 1 {
+		add("job.resume.max_attempts: must be at least 1 when job.resume is enabled")
+	}
+	if _, err := c.Job.Retention.MaxDiskBytes(); err != nil {
+		add("job.retention.max_disk_usage: %w", err)
+	}
+	for i, h := range c.Job.Hooks {
+		if h.Command == "" {
+			add("job.hooks[%d]: command is required", i)
+		}
+	}
+
+	// Background tasks
+	if c.Snapshot.Path != "" && c.Snapshot.Interval <= 0 {
+		add("snapshot.path is set, but snapshot.interval is not positive")
+	}
+	if r := c.Database.Replication; r.URL != "" && r.Interval <= 0 {
+		add("database.replication.url is set, but database.replication.interval is not positive")
+	}
+	if r := c.Database.Replication; r.Restore && r.URL == "" {
+		add("database.replication.restore needs database.replication.url")
+	}
+	if a := c.Database.Archive; a.After < 0 {
+		add("database.archive.after: must not be negative")
+	} else if a.After > 0 && a.Interval <= 0 {
+		add("database.archive.after is set, but database.archive.interval is not positive")
+	}
+	oneOf("database.driver", c.Database.Driver, "sqlite", "postgres")
+	if c.Database.Driver == "postgres" {
+		if c.Database.URL == "" {
+			add("database.driver is postgres, but database.url is not set")
+		}
+		if r := c.Database.Replication; r.URL != "" || r.External {
+			add("database.replication only applies to the sqlite driver")
+		}
+	}
+	if c.Queue.MaxConcurrent < 0 {
+		add("queue.max_concurrent: must not be negative")
+	}
+	oneOf("github.auto_merge", c.GitHub.AutoMerge, MergeMethodMerge, MergeMethodSquash, MergeMethodRebase, MergeOff)
+	if _, err := github.ParsePRTemplate(c.GitHub.PRTemplate); err != nil {
+		add("github.pr_template: %w", err)
+	}
+	if a := c.Queue.Autoscale; a.WebhookURL != "" && a.Interval <= 0 {
+		add("queue.autoscale.webhook_url is set, but queue.autoscale.interval is not positive")
+	}
+
+	if err := c.Approval.validate("approval"); err != nil {
+		errs = append(errs, err)
+	}
+	if a := c.Approval; (a.RemindAfter > 0 || a.ExpireAfter > 0) && a.Interval <= 0 {
+		add("approval.interval: must be positive with remind_after or expire_after")
+	}
+
+	if c.CI.MaxFixAttempts < 0 || c.CI.LogBytes < 0 {
+		add("ci: max_fix_attempts and log_bytes must not be negative")
+	}
+
+	if u := c.Uploads; u.Target != "" {
+		local := u.Target != UploadsGist && !strings.HasPrefix(u.Target, "s3://")
+		if local && u.BaseURL == "" {
+			add("uploads.target is a directory, which needs uploads.base_url")
+		}
+		if u.Threshold <= 0 {
+			add("uploads.threshold: must be positive")
+		}
+		if u.Retention < 0 {
+			add("uploads.retention: must not be negative")
+		}
+	}
+
+	if l := c.Limits; l.ContainerWait <= 0 || l.ContainerPoll <= 0 || l.HTTPTimeout <= 0 {
+		add("limits: container_wait, container_poll and http_timeout must be positive")
+	}
+	if l := c.Limits; l.ContainerPoll > l.ContainerWait {
+		add("limits.container_poll: %s is longer than limits.container_wait %s", l.ContainerPoll, l.ContainerWait)
+	}
+	if c.Limits.RateLimitBuffer < 0 || c.GitHub.RateLimitBuffer < 0 {
+		add("limits.rate_limit_buffer: must not be negative")
+	}
+
+	oneOf("secrets.key", c.Secrets.Key, secrets.KeyKeyring, secrets.KeyFile, secrets.KeyEnvVar)
+
+	for i, s := range c.Notify.Sinks {
+		if s.Type == "" {
+			add("notify.sinks[%d]: type is required", i)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidateJobs checks that this host can run jobs: the Claude bundle of
+// claude.version must be installed, or without a version, exist at
+// claude.bundle_path. With neither set, every project must pin an installed
+// claude_version instead. Commands that run jobs call it on startup.
+func (c *Config) ValidateJobs() error {
+	_, err := c.ClaudeBundleDir("")
+	if err == nil || c.Claude.Version != "" || c.Claude.BundlePath != "" {
+		return err
+	}
+
+	entries, _ := os.ReadDir(c.ProjectsDir)
+	var errs []error
+	projects := 0
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(c.ProjectsDir, e.Name(), "project.yml")); err != nil {
+			continue
+		}
+		projectConfig, err := c.ProjectConfig(e.Name())
+		if err != nil {
+			continue
+		}
+		projects++
+		if projectConfig.ClaudeVersion == "" {
+			errs = append(errs, fmt.Errorf("project %s: claude_version is required without claude.version or claude.bundle_path", e.Name()))
+			continue
+		}
+		if _, err := c.ClaudeBundleDir(projectConfig.ClaudeVersion); err != nil {
+			errs = append(errs, fmt.Errorf("project %s: %w", e.Name(), err))
+		}
+	}
+	if projects == 0 {
+		return err
+	}
+	return errors.Join(errs...)
+}
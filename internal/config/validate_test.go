@@ -0,0 +1,265 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr []string
+	}{
+		{
+			name:   "defaults",
+			modify: func(c *Config) {},
+		},
+		{
+			name: "app without installation",
+			modify: func(c *Config) {
+				c.GitHub.AppID = 1
+			},
+			wantErr: []string{"github.installation_id and github.private_key_file are required"},
+		},
+		{
+			name: "api planning without key",
+			modify: func(c *Config) {
+				c.Job.Planning.Mode = PlanningAPI
+			},
+			wantErr: []string{"requires credentials.anthropic_api_key"},
+		},
+		{
+			name: "triage without key",
+			modify: func(c *Config) {
+				c.Triggers.TriageLabels = []string{"manfred:triage"}
+			},
+			wantErr: []string{"triggers.triage_labels: triage runs over the Anthropic API"},
+		},
+		{
+			name: "upload directory without base url",
+			modify: func(c *Config) {
+				c.Uploads = UploadsConfig{Target: "/srv/manfred", Threshold: 20000}
+			},
+			wantErr: []string{"uploads.base_url"},
+		},
+		{
+			name: "gist uploads",
+			modify: func(c *Config) {
+				c.Uploads = UploadsConfig{Target: UploadsGist, Threshold: 20000, Retention: 24 * time.Hour}
+			},
+		},
+		{
+			name: "limits",
+			modify: func(c *Config) {
+				c.Limits.ContainerPoll = 2 * time.Minute
+				c.Limits.HTTPTimeout = 0
+			},
+			wantErr: []string{"limits.container_poll", "http_timeout must be positive"},
+		},
+		{
+			name: "server security",
+			modify: func(c *Config) {
+				c.Server.TLS.CertFile = "/etc/manfred/cert.pem"
+				c.Server.WebhookAllow.CIDRs = []string{"192.30.252.0/22", "10.0.0.1"}
+				c.Server.Auth.Username = "admin"
+			},
+			wantErr: []string{"server.tls.key_file", `"10.0.0.1" is not a CIDR`, "server.auth.password"},
+		},
+		{
+			name: "postgres",
+			modify: func(c *Config) {
+				c.Database.Driver = "postgres"
+				c.Database.Replication.URL = "s3://backups/manfred.db"
+			},
+			wantErr: []string{"database.url is not set", "database.replication only applies"},
+		},
+		{
+			name: "archive",
+			modify: func(c *Config) {
+				c.Database.Archive.After = 90 * 24 * time.Hour
+				c.Database.Archive.Interval = 0
+			},
+			wantErr: []string{"database.archive.interval is not positive"},
+		},
+		{
+			name: "pr template",
+			modify: func(c *Config) {
+				c.GitHub.PRTemplate = "Closes #{{.IssueNumber"
+			},
+			wantErr: []string{"github.pr_template"},
+		},
+		{
+			name: "pr template with fence",
+			modify: func(c *Config) {
+				c.GitHub.PRTemplate = "{{with .Tests}}{{fence .Output}}{{end}}"
+			},
+		},
+		{
+			name: "approval",
+			modify: func(c *Config) {
+				c.Approval = ApprovalConfig{Approvers: -1, RemindAfter: 48 * time.Hour, ExpireAfter: 24 * time.Hour}
+			},
+			wantErr: []string{"approval: approvers", "approval.interval"},
+		},
+		{
+			name: "several",
+			modify: func(c *Config) {
+				c.Logging.Format = "xml"
+				c.Job.Cleanup = "everything"
+				c.Snapshot.Path = "/tmp/snap.json"
+				c.Snapshot.Interval = 0
+			},
+			wantErr: []string{"logging.format", "job.cleanup", "snapshot.interval"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Logging:  LoggingConfig{Level: "info", Format: "text"},
+				Server:   ServerConfig{Port: 8080},
+				Snapshot: SnapshotConfig{Interval: 5 * time.Minute},
+				Job:      JobConfig{Planning: PlanningConfig{Mode: PlanningContainer}},
+				Limits:   LimitsConfig{ContainerWait: time.Minute, ContainerPoll: time.Second, HTTPTimeout: 30 * time.Second},
+			}
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() = nil, want errors %v", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() = %v, want it to mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigValidateJobs(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "bundle.tar")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{"directory", dir, false},
+		{"missing", filepath.Join(dir, "missing"), true},
+		{"file", file, true},
+		{"empty", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Claude: ClaudeConfig{BundlePath: tt.path}}
+			if err := cfg.ValidateJobs(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateJobs() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigValidateJobsProjectVersions(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{
+		ProjectsDir: filepath.Join(dir, "projects"),
+		Claude:      ClaudeConfig{BundlesDir: filepath.Join(dir, "bundles")},
+	}
+	if err := os.MkdirAll(filepath.Join(cfg.Claude.BundlesDir, "1.0.67"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	project := func(name, yml string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(cfg.ProjectsDir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(cfg.ProjectsDir, name, "project.yml"), []byte(yml), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := cfg.ValidateJobs(); err == nil {
+		t.Error("ValidateJobs() without bundle or projects = nil, want an error")
+	}
+	project("api", "claude_version: 1.0.67\n")
+	if err := cfg.ValidateJobs(); err != nil {
+		t.Errorf("ValidateJobs() with every project pinned = %v", err)
+	}
+	project("web", "claude_version: 2.0.0\n")
+	project("docs", "default_branch: main\n")
+	err := cfg.ValidateJobs()
+	for _, want := range []string{"project web: Claude bundle 2.0.0 is not installed", "project docs: claude_version is required"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ValidateJobs() = %v, want %q", err, want)
+		}
+	}
+}
+
+func TestConfigClaudeBundleDir(t *testing.T) {
+	dir := t.TempDir()
+	bundles := filepath.Join(dir, "bundles")
+	if err := os.MkdirAll(filepath.Join(bundles, "1.0.67"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{Claude: ClaudeConfig{BundlePath: dir, BundlesDir: bundles}}
+
+	tests := []struct {
+		name    string
+		pinned  string // claude.version
+		version string // project claude_version
+		want    string
+		wantErr bool
+	}{
+		{"unversioned", "", "", dir, false},
+		{"default version", "1.0.67", "", filepath.Join(bundles, "1.0.67"), false},
+		{"project pin", "2.0.0", "1.0.67", filepath.Join(bundles, "1.0.67"), false},
+		{"not installed", "2.0.0", "", "", true},
+		{"invalid", "", "../bundles", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.Claude.Version = tt.pinned
+			got, err := cfg.ClaudeBundleDir(tt.version)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ClaudeBundleDir(%q) = %q, %v, want %q, wantErr %v", tt.version, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	cfg, err := Defaults()
+	if err != nil {
+		t.Fatalf("Defaults() error = %v", err)
+	}
+	if cfg.Limits.ContainerWait != time.Minute || cfg.Limits.ContainerPoll != 500*time.Millisecond {
+		t.Errorf("Limits = %+v, want a 60s container wait polled every 500ms", cfg.Limits)
+	}
+	if cfg.Job.DetectTests {
+		t.Error("Job.DetectTests = true, want test detection off by default")
+	}
+	if cfg.CI.FixFailures {
+		t.Error("CI.FixFailures = true, want CI fixes off by default")
+	}
+	if cfg.GitHub.RateLimitBuffer != 100 {
+		t.Errorf("GitHub.RateLimitBuffer = %d, want limits.rate_limit_buffer 100", cfg.GitHub.RateLimitBuffer)
+	}
+	if cfg.JobsDir != filepath.Join(cfg.DataDir, "jobs") {
+		t.Errorf("JobsDir = %s, want it below DataDir %s", cfg.JobsDir, cfg.DataDir)
+	}
+}
@@ -1,7 +1,6 @@
 package docker
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"fmt"
@@ -9,28 +8,44 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 )
 
 // ContainerJobPath is where the job directory is mounted inside containers.
 const ContainerJobPath = "/manfred-job"
 
-// Client wraps Docker SDK operations.
+// ContainerBundlePath is where the Claude bundle is mounted, read-only,
+// inside containers.
+const ContainerBundlePath = "/manfred-claude"
+
+// Client wraps Docker operations. Containers are started with docker compose
+// (the SDK has no compose support) or, for projects with docker.image, the
+// SDK directly; execs always go through the SDK.
 type Client struct {
 	docker *client.Client
+
+	mu       sync.Mutex
+	versions *Versions // Detected by Versions
 }
 
 // ComposeOptions configures a compose up operation.
 type ComposeOptions struct {
 	ComposeFile string
 	ProjectName string
-	Env         map[string]string
-	Volumes     []VolumeMount
-	Stdout      io.Writer // Optional: stream stdout here
-	Stderr      io.Writer // Optional: stream stderr here
+	Env         map[string]string // Set for compose and passed to Services
+	Volumes     []VolumeMount     // Mounted into Services
+	Services    []string          // Services given Volumes and Env; empty means all
+	Limits      ResourceLimits    // Applied to every service
+	Ports       PortsMode         // What happens to published ports; empty keeps them
+	Stdout      io.Writer         // Optional: stream stdout here
+	Stderr      io.Writer         // Optional: stream stderr here
 }
 
 // VolumeMount represents a volume to mount into containers.
@@ -40,8 +55,22 @@ type VolumeMount struct {
 	ReadOnly bool
 }
 
+// ResourceLimits caps what a job container may use. Zero values mean no
+// limit.
+type ResourceLimits struct {
+	CPUs   float64 // Number of CPUs, e.g. 1.5
+	Memory int64   // Bytes
+	Pids   int64   // Maximum number of processes
+}
+
+// IsZero reports whether no limit is set.
+func (l ResourceLimits) IsZero() bool {
+	return l == ResourceLimits{}
+}
+
 // ExecOptions configures a container exec operation.
 type ExecOptions struct {
+	User    string // Name or uid[:gid]; empty runs as the image's user
 	Workdir string
 	Env     map[string]string
 	Stdout  io.Writer
@@ -63,16 +92,44 @@ func (c *Client) Close() error {
 	return c.docker.Close()
 }
 
+// Versions detects the installed Docker and Compose versions once per
+// client. Compose commands then use the detected compose command, e.g. the
+// standalone docker-compose binary when the plugin is missing.
+func (c *Client) Versions(ctx context.Context) *Versions {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.versions == nil {
+		c.versions = DetectVersions(ctx)
+	}
+	return c.versions
+}
+
+// composeCommand returns a compose command with args, run as detected by
+// Versions, or as docker compose before detection.
+func (c *Client) composeCommand(ctx context.Context, args ...string) *exec.Cmd {
+	command := []string{"docker", "compose"}
+	c.mu.Lock()
+	if c.versions != nil && c.versions.ComposeCommand != nil {
+		command = c.versions.ComposeCommand
+	}
+	c.mu.Unlock()
+	args = append(append([]string{}, command[1:]...), args...)
+	return exec.CommandContext(ctx, command[0], args...)
+}
+
 // ComposeUp starts containers using docker compose.
 // We use exec because the Docker SDK doesn't have native compose support.
 func (c *Client) ComposeUp(ctx context.Context, opts ComposeOptions) error {
-	args := []string{"compose", "-f", opts.ComposeFile}
+	args := []string{"-f", opts.ComposeFile}
 
-	// Generate override file for additional volumes
+	// Generate override file for additional volumes, env, limits and ports
 	var overrideFile string
-	if len(opts.Volumes) > 0 {
+	if opts.Ports == PortsKeep {
+		opts.Ports = ""
+	}
+	if len(opts.Volumes) > 0 || len(opts.Env) > 0 || !opts.Limits.IsZero() || opts.Ports != "" {
 		var err error
-		overrideFile, err = c.generateComposeOverride(opts.ComposeFile, opts.Volumes)
+		overrideFile, err = c.generateComposeOverride(opts)
 		if err != nil {
 			return fmt.Errorf("failed to generate compose override: %w", err)
 		}
@@ -82,7 +139,7 @@ func (c *Client) ComposeUp(ctx context.Context, opts ComposeOptions) error {
 
 	args = append(args, "-p", opts.ProjectName, "up", "-d", "--build")
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd := c.composeCommand(ctx, args...)
 
 	// Set environment
 	cmd.Env = os.Environ()
@@ -115,15 +172,17 @@ func (c *Client) ComposeUp(ctx context.Context, opts ComposeOptions) error {
 	return nil
 }
 
-// ComposeDown stops and removes containers.
-func (c *Client) ComposeDown(ctx context.Context, composeFile, projectName string) error {
-	args := []string{"compose"}
+// ComposeDown stops and removes containers and networks, plus volumes and
+// images depending on level.
+func (c *Client) ComposeDown(ctx context.Context, composeFile, projectName string, level CleanupLevel) error {
+	var args []string
 	if composeFile != "" {
 		args = append(args, "-f", composeFile)
 	}
 	args = append(args, "-p", projectName, "down", "--remove-orphans")
+	args = append(args, level.downArgs()...)
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd := c.composeCommand(ctx, args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("compose down failed: %w\n%s", err, output)
@@ -132,54 +191,154 @@ func (c *Client) ComposeDown(ctx context.Context, composeFile, projectName strin
 	return nil
 }
 
-// Exec runs a command in a container and streams output.
-func (c *Client) Exec(ctx context.Context, containerName string, command []string, opts ExecOptions) error {
-	args := []string{"exec"}
+// maxStderrBytes is how much of an exec's stderr ExecResult keeps.
+const maxStderrBytes = 4096
 
-	if opts.Workdir != "" {
-		args = append(args, "-w", opts.Workdir)
+// ExecResult describes a command that ran to completion in a container.
+type ExecResult struct {
+	ExitCode int
+	Duration time.Duration
+	Stderr   string // Last maxStderrBytes of stderr, also streamed to ExecOptions.Stderr
+}
+
+// Err returns an *ExitError when the command exited non-zero, else nil.
+func (r *ExecResult) Err() error {
+	if r.ExitCode == 0 {
+		return nil
 	}
+	return &ExitError{Code: r.ExitCode, Stderr: r.Stderr}
+}
 
-	for k, v := range opts.Env {
-		args = append(args, "-e", fmt.Sprintf("%s=%s", k, v))
+// ExitError reports a command that ran in a container but exited non-zero.
+type ExitError struct {
+	Code   int
+	Stderr string // Tail of the command's stderr
+}
+
+func (e *ExitError) Error() string {
+	msg := fmt.Sprintf("exit status %d", e.Code)
+	if line := lastLine(e.Stderr); line != "" {
+		msg += ": " + line
 	}
+	return msg
+}
 
-	args = append(args, containerName)
-	args = append(args, command...)
+// ExitCode returns the command's exit code.
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
+// lastLine returns the last non-empty line of s.
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndex(s, "\n"); i >= 0 {
+		s = s[i+1:]
+	}
+	return strings.TrimSpace(s)
+}
 
-	if opts.Stdout != nil {
-		cmd.Stdout = opts.Stdout
+// Exec runs a command in a container through the Docker API, demultiplexing
+// its stdout and stderr into the given writers. The error is only set when
+// the command could not be run or its output not read (Docker failures); a
+// command that exits non-zero yields a result with its exit code and stderr
+// (see ExecResult.Err).
+func (c *Client) Exec(ctx context.Context, containerName string, command []string, opts ExecOptions) (*ExecResult, error) {
+	started := time.Now()
+	created, err := c.docker.ContainerExecCreate(ctx, containerName, container.ExecOptions{
+		AttachStdout: true,
+		AttachStderr: true,
+		Env:          envList(opts.Env),
+		User:         opts.User,
+		WorkingDir:   opts.Workdir,
+		Cmd:          command,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exec in %s: %w", containerName, err)
+	}
+
+	attached, err := c.docker.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach to exec in %s: %w", containerName, err)
+	}
+	defer attached.Close()
+
+	// The stream only ends when the command exits; closing the connection
+	// unblocks the copy when ctx is cancelled first
+	stop := context.AfterFunc(ctx, attached.Close)
+	defer stop()
+
+	stdout := opts.Stdout
+	if stdout == nil {
+		stdout = io.Discard
 	}
+	stderrTail := &tailBuffer{max: maxStderrBytes}
+	stderr := io.Writer(stderrTail)
 	if opts.Stderr != nil {
-		cmd.Stderr = opts.Stderr
+		stderr = io.MultiWriter(opts.Stderr, stderrTail)
+	}
+	if _, err := stdcopy.StdCopy(stdout, stderr, attached.Reader); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("failed to read exec output: %w", err)
 	}
 
-	return cmd.Run()
+	inspect, err := c.docker.ContainerExecInspect(ctx, created.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect exec: %w", err)
+	}
+	return &ExecResult{
+		ExitCode: inspect.ExitCode,
+		Duration: time.Since(started),
+		Stderr:   stderrTail.String(),
+	}, nil
 }
 
-// ExecCapture runs a command and returns its output.
-func (c *Client) ExecCapture(ctx context.Context, containerName string, command []string) (string, error) {
-	args := []string{"exec", containerName}
-	args = append(args, command...)
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	max int
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-t.max:]...)
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	return string(t.buf)
+}
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
-	output, err := cmd.Output()
+// envList converts env to KEY=value pairs, sorted for stable output.
+func envList(env map[string]string) []string {
+	list := make([]string, 0, len(env))
+	for k, v := range env {
+		list = append(list, k+"="+v)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// ExecCapture runs a command and returns its stdout.
+func (c *Client) ExecCapture(ctx context.Context, containerName string, command []string) (string, error) {
+	var stdout bytes.Buffer
+	result, err := c.Exec(ctx, containerName, command, ExecOptions{Stdout: &stdout})
 	if err != nil {
 		return "", err
 	}
-
-	return string(output), nil
+	if err := result.Err(); err != nil {
+		return "", err
+	}
+	return stdout.String(), nil
 }
 
 // ExecSilent runs a command and returns success/failure.
 func (c *Client) ExecSilent(ctx context.Context, containerName string, command []string) bool {
-	args := []string{"exec", containerName}
-	args = append(args, command...)
-
-	cmd := exec.CommandContext(ctx, "docker", args...)
-	return cmd.Run() == nil
+	result, err := c.Exec(ctx, containerName, command, ExecOptions{})
+	return err == nil && result.ExitCode == 0
 }
 
 // IsRunning checks if a container is running.
@@ -195,9 +354,10 @@ func (c *Client) IsRunning(ctx context.Context, containerName string) (bool, err
 	return info.State.Running, nil
 }
 
-// WaitForContainer waits until a container is running.
-func (c *Client) WaitForContainer(ctx context.Context, containerName string) error {
-	ticker := time.NewTicker(500 * time.Millisecond)
+// WaitForContainer waits until a container is running, checking every
+// interval.
+func (c *Client) WaitForContainer(ctx context.Context, containerName string, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
@@ -216,8 +376,10 @@ func (c *Client) WaitForContainer(ctx context.Context, containerName string) err
 	}
 }
 
-// SetupCredentialSymlinks creates symlinks for Claude credentials inside a container.
-func (c *Client) SetupCredentialSymlinks(ctx context.Context, containerName string) error {
+// SetupCredentialSymlinks creates symlinks for Claude credentials inside a
+// container, in the .claude directory of home, or of the $HOME of user when
+// home is empty. Empty user means the image's user.
+func (c *Client) SetupCredentialSymlinks(ctx context.Context, containerName, user, home string) error {
 	credentialsPath := filepath.Join(ContainerJobPath, ".credentials.json")
 
 	// Check if credentials exist in the job directory
@@ -225,28 +387,25 @@ func (c *Client) SetupCredentialSymlinks(ctx context.Context, containerName stri
 		return nil // No credentials, skip
 	}
 
-	// Get the home directory of the current user in the container
-	homeDir, err := c.ExecCapture(ctx, containerName, []string{"sh", "-c", "echo $HOME"})
-	if err != nil {
+	if home == "" {
 		// Fall back to /root if we can't determine home
-		homeDir = "/root"
-	}
-	homeDir = strings.TrimSpace(homeDir)
-	if homeDir == "" {
-		homeDir = "/root"
+		home = c.UserHome(ctx, containerName, user)
+		if home == "" {
+			home = "/root"
+		}
 	}
 
-	claudeDir := filepath.Join(homeDir, ".claude")
+	claudeDir := filepath.Join(home, ".claude")
 	credentialsTarget := filepath.Join(claudeDir, ".credentials.json")
 
 	// Create .claude directory
-	output, err := c.ExecCaptureWithError(ctx, containerName, []string{"mkdir", "-p", claudeDir})
+	output, err := c.ExecAs(ctx, containerName, user, []string{"mkdir", "-p", claudeDir})
 	if err != nil {
 		return fmt.Errorf("failed to create .claude directory at %s: %v (output: %s)", claudeDir, err, output)
 	}
 
 	// Create symlink
-	output, err = c.ExecCaptureWithError(ctx, containerName, []string{"ln", "-sf", credentialsPath, credentialsTarget})
+	output, err = c.ExecAs(ctx, containerName, user, []string{"ln", "-sf", credentialsPath, credentialsTarget})
 	if err != nil {
 		return fmt.Errorf("failed to create credential symlink: %v (output: %s)", err, output)
 	}
@@ -254,14 +413,52 @@ func (c *Client) SetupCredentialSymlinks(ctx context.Context, containerName stri
 	return nil
 }
 
-// ExecCaptureWithError runs a command and returns output and error details.
+// UserHome returns the $HOME of user in a container, empty if it cannot be
+// determined or is the root directory, as for a uid without a passwd entry.
+func (c *Client) UserHome(ctx context.Context, containerName, user string) string {
+	output, err := c.ExecAs(ctx, containerName, user, []string{"sh", "-c", "echo $HOME"})
+	if err != nil {
+		return ""
+	}
+	return parseHome(output)
+}
+
+// parseHome returns the home directory echoed by a shell, empty for none or
+// the root directory.
+func parseHome(output string) string {
+	home := strings.TrimSpace(output)
+	if home == "/" {
+		return ""
+	}
+	return home
+}
+
+// ExecCaptureWithError runs a command and returns its combined output along
+// with the error.
 func (c *Client) ExecCaptureWithError(ctx context.Context, containerName string, command []string) (string, error) {
-	args := []string{"exec", containerName}
-	args = append(args, command...)
+	return c.ExecAs(ctx, containerName, "", command)
+}
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
+// ExecAs is ExecCaptureWithError running the command as user.
+func (c *Client) ExecAs(ctx context.Context, containerName, user string, command []string) (string, error) {
+	var output bytes.Buffer
+	result, err := c.Exec(ctx, containerName, command, ExecOptions{User: user, Stdout: &output, Stderr: &output})
+	if err != nil {
+		return output.String(), err
+	}
+	return output.String(), result.Err()
+}
+
+// CopyFromContainer copies a file or directory out of a container with
+// docker cp. A directory is copied to dst itself, not into it, when dst does
+// not exist yet.
+func (c *Client) CopyFromContainer(ctx context.Context, containerName, src, dst string) error {
+	cmd := exec.CommandContext(ctx, "docker", "cp", containerName+":"+src, dst)
 	output, err := cmd.CombinedOutput()
-	return string(output), err
+	if err != nil {
+		return fmt.Errorf("docker cp %s: %w: %s", src, err, strings.TrimSpace(string(output)))
+	}
+	return nil
 }
 
 // DebugContainers shows container information for debugging.
@@ -273,92 +470,12 @@ func (c *Client) DebugContainers(ctx context.Context, projectName string, out io
 	cmd.Run()
 
 	// Also show recent docker compose logs
-	cmd = exec.CommandContext(ctx, "docker", "compose", "-p", projectName, "logs", "--tail=20")
+	cmd = c.composeCommand(ctx, "-p", projectName, "logs", "--tail=20")
 	cmd.Stdout = out
 	cmd.Stderr = out
 	cmd.Run()
 }
 
-// generateComposeOverride creates a temporary compose override file with additional volumes.
-func (c *Client) generateComposeOverride(composeFile string, volumes []VolumeMount) (string, error) {
-	// Read original compose file to find service names
-	content, err := os.ReadFile(composeFile)
-	if err != nil {
-		return "", fmt.Errorf("failed to read compose file: %w", err)
-	}
-
-	services := extractServiceNames(string(content))
-	if len(services) == 0 {
-		return "", fmt.Errorf("no services found in compose file")
-	}
-
-	// Build override YAML
-	var override strings.Builder
-	override.WriteString("services:\n")
-
-	for _, service := range services {
-		override.WriteString(fmt.Sprintf("  %s:\n", service))
-		override.WriteString("    volumes:\n")
-
-		for _, vol := range volumes {
-			mode := "rw"
-			if vol.ReadOnly {
-				mode = "ro"
-			}
-			override.WriteString(fmt.Sprintf("      - %s:%s:%s\n", vol.Source, vol.Target, mode))
-		}
-	}
-
-	// Write to temp file
-	dir := filepath.Dir(composeFile)
-	tmpFile, err := os.CreateTemp(dir, "manfred-override-*.yml")
-	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %w", err)
-	}
-
-	if _, err := tmpFile.WriteString(override.String()); err != nil {
-		tmpFile.Close()
-		os.Remove(tmpFile.Name())
-		return "", fmt.Errorf("failed to write override file: %w", err)
-	}
-
-	tmpFile.Close()
-	return tmpFile.Name(), nil
-}
-
-// extractServiceNames parses a docker-compose.yml and returns service names.
-func extractServiceNames(content string) []string {
-	var services []string
-	scanner := bufio.NewScanner(bytes.NewReader([]byte(content)))
-	inServices := false
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		trimmed := strings.TrimSpace(line)
-
-		// Check if we're entering the services block
-		if trimmed == "services:" {
-			inServices = true
-			continue
-		}
-
-		// If we hit another top-level key, stop
-		if inServices && len(line) > 0 && line[0] != ' ' && line[0] != '\t' {
-			break
-		}
-
-		// Look for service names (lines with exactly 2 spaces indent followed by name:)
-		if inServices && strings.HasPrefix(line, "  ") && !strings.HasPrefix(line, "    ") {
-			name := strings.TrimSpace(strings.TrimSuffix(trimmed, ":"))
-			if name != "" && !strings.HasPrefix(name, "#") {
-				services = append(services, name)
-			}
-		}
-	}
-
-	return services
-}
-
 // ContainerName returns the container name for a compose project and service.
 func ContainerName(projectName, service string) string {
 	return fmt.Sprintf("%s-%s-1", projectName, service)
@@ -0,0 +1,52 @@
+package docker
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEnvList(t *testing.T) {
+	got := envList(map[string]string{"B": "2", "A": "1=x", "EMPTY": ""})
+	if want := "A=1=x,B=2,EMPTY="; strings.Join(got, ",") != want {
+		t.Errorf("envList() = %v, want %s", got, want)
+	}
+}
+
+func TestExecResultErr(t *testing.T) {
+	if err := (&ExecResult{}).Err(); err != nil {
+		t.Errorf("Err() for exit code 0 = %v, want nil", err)
+	}
+
+	result := &ExecResult{ExitCode: 3, Stderr: "warning: x\nError: rate limited\n\n"}
+	var exitErr *ExitError
+	if err := result.Err(); !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Fatalf("Err() = %v, want *ExitError with code 3", err)
+	}
+	if want := "exit status 3: Error: rate limited"; exitErr.Error() != want {
+		t.Errorf("Error() = %q, want %q", exitErr.Error(), want)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	buf := &tailBuffer{max: 5}
+	for _, s := range []string{"ab", "cdef", "gh"} {
+		buf.Write([]byte(s))
+	}
+	if got := buf.String(); got != "defgh" {
+		t.Errorf("tailBuffer = %q, want %q", got, "defgh")
+	}
+}
+
+func TestParseHome(t *testing.T) {
+	for output, want := range map[string]string{
+		"/home/node\n": "/home/node",
+		"/root":        "/root",
+		"/\n":          "",
+		"\n":           "",
+	} {
+		if got := parseHome(output); got != want {
+			t.Errorf("parseHome(%q) = %q, want %q", output, got, want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package docker
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// composeFile is the part of a compose file the override is built from.
+type composeFile struct {
+	Services map[string]composeService `yaml:"services"`
+}
+
+// composeService is a service of a compose file.
+type composeService struct {
+	Ports []any `yaml:"ports"` // Short syntax strings or long syntax maps
+}
+
+// parseCompose parses a compose file. Anchors, merge keys, extension fields
+// and any indentation are handled by the YAML parser.
+func parseCompose(content []byte) (*composeFile, error) {
+	var compose composeFile
+	if err := yaml.Unmarshal(content, &compose); err != nil {
+		return nil, fmt.Errorf("failed to parse compose file: %w", err)
+	}
+	if len(compose.Services) == 0 {
+		return nil, fmt.Errorf("no services found in compose file")
+	}
+	return &compose, nil
+}
+
+// serviceNames returns the names of the services, sorted.
+func (f *composeFile) serviceNames() []string {
+	names := make([]string, 0, len(f.Services))
+	for name := range f.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// overrideService is a service of the compose override. Compose merges it
+// into the service of the same name: volumes and environment are added, the
+// limits replace the compose file's and Ports, when set, replaces or resets
+// the service's ports through the !override and !reset tags.
+type overrideService struct {
+	Volumes     []string   `yaml:"volumes,omitempty"`
+	Environment []string   `yaml:"environment,omitempty"` // Names only; compose passes on its own values
+	CPUs        float64    `yaml:"cpus,omitempty"`
+	MemLimit    int64      `yaml:"mem_limit,omitempty"`
+	PidsLimit   int64      `yaml:"pids_limit,omitempty"`
+	Ports       *yaml.Node `yaml:"ports,omitempty"`
+}
+
+// generateComposeOverride creates a temporary compose override file next to
+// opts.ComposeFile, see composeOverride.
+func (c *Client) generateComposeOverride(opts ComposeOptions) (string, error) {
+	content, err := os.ReadFile(opts.ComposeFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read compose file: %w", err)
+	}
+	compose, err := parseCompose(content)
+	if err != nil {
+		return "", err
+	}
+
+	override, err := composeOverride(compose, opts)
+	if err != nil {
+		return "", err
+	}
+
+	// Write to temp file
+	dir := filepath.Dir(opts.ComposeFile)
+	tmpFile, err := os.CreateTemp(dir, "manfred-override-*.yml")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+
+	if _, err := tmpFile.WriteString(override); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("failed to write override file: %w", err)
+	}
+
+	tmpFile.Close()
+	return tmpFile.Name(), nil
+}
+
+// composeOverride builds the override YAML for compose. The services of
+// opts.Services, or all, get the volumes (the job directory holds the
+// credentials) and the names of the env variables; other services, such as
+// databases, get neither. Every service gets the limits, as the
+// service-level cpus, mem_limit and pids_limit keys, which compose applies
+// without swarm mode, and the ports of services that publish any are
+// replaced by ephemeral ones or dropped, as opts.Ports says.
+func composeOverride(compose *composeFile, opts ComposeOptions) (string, error) {
+	targets := map[string]bool{}
+	for _, name := range opts.Services {
+		if _, ok := compose.Services[name]; !ok {
+			return "", fmt.Errorf("service %s not found in compose file", name)
+		}
+		targets[name] = true
+	}
+	var env []string
+	for name := range opts.Env {
+		env = append(env, name)
+	}
+	sort.Strings(env)
+
+	services := map[string]overrideService{}
+	for _, name := range compose.serviceNames() {
+		service := overrideService{
+			CPUs:      opts.Limits.CPUs,
+			MemLimit:  opts.Limits.Memory,
+			PidsLimit: opts.Limits.Pids,
+		}
+		if len(targets) == 0 || targets[name] {
+			for _, vol := range opts.Volumes {
+				mode := "rw"
+				if vol.ReadOnly {
+					mode = "ro"
+				}
+				service.Volumes = append(service.Volumes, fmt.Sprintf("%s:%s:%s", vol.Source, vol.Target, mode))
+			}
+			service.Environment = env
+		}
+		if published := compose.Services[name].Ports; len(published) > 0 {
+			service.Ports = portsNode(published, opts.Ports)
+		}
+		services[name] = service
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(map[string]any{"services": services}); err != nil {
+		return "", fmt.Errorf("failed to encode compose override: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("failed to encode compose override: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// portsNode returns the override of a service's published ports: ephemeral
+// ones tagged !override, an empty list tagged !reset, or nil to keep them.
+func portsNode(published []any, ports PortsMode) *yaml.Node {
+	switch ports {
+	case PortsStrip:
+		return &yaml.Node{Kind: yaml.SequenceNode, Tag: "!reset", Style: yaml.FlowStyle}
+	case PortsEphemeral:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!override"}
+		for _, port := range ephemeralPorts(published) {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: port, Style: yaml.DoubleQuotedStyle})
+		}
+		return node
+	default:
+		return nil
+	}
+}
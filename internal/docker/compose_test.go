@@ -0,0 +1,186 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCompose(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		services []string
+	}{
+		{
+			name: "four space indentation",
+			content: `version: "3.8"
+services:
+    web:
+        build: .
+        ports:
+            - "8080:80"
+    worker:
+        build: .
+`,
+			services: []string{"web", "worker"},
+		},
+		{
+			name: "anchors and extension fields",
+			content: `x-app: &app
+  build: .
+  environment:
+    RAILS_ENV: test
+
+services:
+  app:
+    <<: *app
+    command: sleep infinity
+  sidekiq:
+    <<: *app
+    command: bundle exec sidekiq
+  "db":   # PostgreSQL
+    image: postgres:16
+volumes:
+  pg:
+`,
+			services: []string{"app", "db", "sidekiq"},
+		},
+		{
+			name:     "tab in a value and flow style",
+			content:  "services: {app: {image: \"node:20\", command: \"echo\ta\"}, redis: {image: redis}}\n",
+			services: []string{"app", "redis"},
+		},
+		{
+			name: "services after other keys",
+			content: `name: shop
+networks:
+  default:
+    name: shop
+services:
+  app:
+    image: ruby:3
+    networks: [default]
+`,
+			services: []string{"app"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compose, err := parseCompose([]byte(tt.content))
+			if err != nil {
+				t.Fatalf("parseCompose() error = %v", err)
+			}
+			if got := compose.serviceNames(); !reflect.DeepEqual(got, tt.services) {
+				t.Errorf("serviceNames() = %v, want %v", got, tt.services)
+			}
+		})
+	}
+
+	for _, content := range []string{"volumes:\n  data:\n", "services:\n\tapp:\n\t\timage: x\n"} {
+		if _, err := parseCompose([]byte(content)); err == nil {
+			t.Errorf("parseCompose(%q) succeeded, want error", content)
+		}
+	}
+}
+
+func TestComposeOverride(t *testing.T) {
+	compose, err := parseCompose([]byte(`services:
+  app:
+    image: node:20
+    ports:
+      - "3000:3000"
+      - target: 9229
+        published: 9229
+        host_ip: 127.0.0.1
+  db:
+    image: postgres:16
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	volumes := []VolumeMount{{Source: "/jobs/job_1", Target: "/manfred-job"}, {Source: "/bundle", Target: "/manfred-claude", ReadOnly: true}}
+
+	got, err := composeOverride(compose, ComposeOptions{Volumes: volumes, Limits: ResourceLimits{CPUs: 1.5, Memory: 1 << 30, Pids: 256}, Ports: PortsKeep})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `services:
+  app:
+    volumes:
+      - /jobs/job_1:/manfred-job:rw
+      - /bundle:/manfred-claude:ro
+    cpus: 1.5
+    mem_limit: 1073741824
+    pids_limit: 256
+  db:
+    volumes:
+      - /jobs/job_1:/manfred-job:rw
+      - /bundle:/manfred-claude:ro
+    cpus: 1.5
+    mem_limit: 1073741824
+    pids_limit: 256
+`
+	if got != want {
+		t.Errorf("composeOverride() =\n%s\nwant\n%s", got, want)
+	}
+
+	got, _ = composeOverride(compose, ComposeOptions{Limits: ResourceLimits{Memory: 512 << 20}, Ports: PortsEphemeral})
+	want = `services:
+  app:
+    mem_limit: 536870912
+    ports: !override
+      - "3000"
+      - "127.0.0.1::9229"
+  db:
+    mem_limit: 536870912
+`
+	if got != want {
+		t.Errorf("composeOverride(ephemeral) =\n%s\nwant\n%s", got, want)
+	}
+
+	got, _ = composeOverride(compose, ComposeOptions{Ports: PortsStrip})
+	if want := "services:\n  app:\n    ports: !reset []\n  db: {}\n"; got != want {
+		t.Errorf("composeOverride(strip) = %q, want %q", got, want)
+	}
+}
+
+func TestComposeOverrideServices(t *testing.T) {
+	compose, err := parseCompose([]byte("services:\n  app:\n    build: .\n  worker:\n    build: .\n  db:\n    image: postgres:16\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := ComposeOptions{
+		Env:      map[string]string{"ANTHROPIC_API_KEY": "sk-secret"},
+		Volumes:  []VolumeMount{{Source: "/jobs/job_1", Target: "/manfred-job"}},
+		Services: []string{"app", "worker"},
+		Limits:   ResourceLimits{Pids: 256},
+	}
+	got, err := composeOverride(compose, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `services:
+  app:
+    volumes:
+      - /jobs/job_1:/manfred-job:rw
+    environment:
+      - ANTHROPIC_API_KEY
+    pids_limit: 256
+  db:
+    pids_limit: 256
+  worker:
+    volumes:
+      - /jobs/job_1:/manfred-job:rw
+    environment:
+      - ANTHROPIC_API_KEY
+    pids_limit: 256
+`
+	if got != want {
+		t.Errorf("composeOverride() =\n%s\nwant\n%s", got, want)
+	}
+
+	opts.Services = []string{"web"}
+	if _, err := composeOverride(compose, opts); err == nil {
+		t.Error("composeOverride() with an unknown service succeeded, want error")
+	}
+}
@@ -0,0 +1,141 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
+)
+
+// serviceLabel is the label compose puts on a container for its service.
+const serviceLabel = "com.docker.compose.service"
+
+// ContainerOptions configures a container started without compose.
+type ContainerOptions struct {
+	Name    string
+	Image   string
+	Project string // Set as the compose project label, see RunContainer
+	Service string
+	Workdir string
+	Env     map[string]string
+	Volumes []VolumeMount
+	Limits  ResourceLimits
+	Stdout  io.Writer // Optional: image pull progress
+}
+
+// RunContainer pulls opts.Image if it is missing, then creates and starts a
+// container that stays up, idling, until RemoveContainer, so commands can be
+// exec'd into it. The container carries the compose project and service
+// labels, which lets ListComposeResources, DebugContainers and `docker
+// compose -p` find it like a compose service.
+func (c *Client) RunContainer(ctx context.Context, opts ContainerOptions) error {
+	if err := c.ensureImage(ctx, opts.Image, opts.Stdout); err != nil {
+		return err
+	}
+
+	mounts := make([]mount.Mount, 0, len(opts.Volumes))
+	for _, vol := range opts.Volumes {
+		mounts = append(mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   vol.Source,
+			Target:   vol.Target,
+			ReadOnly: vol.ReadOnly,
+		})
+	}
+
+	created, err := c.docker.ContainerCreate(ctx, &container.Config{
+		Image:      opts.Image,
+		Entrypoint: []string{"tail", "-f", "/dev/null"},
+		WorkingDir: opts.Workdir,
+		Env:        envList(opts.Env),
+		Labels: map[string]string{
+			projectLabel: opts.Project,
+			serviceLabel: opts.Service,
+		},
+	}, &container.HostConfig{
+		Mounts:    mounts,
+		Init:      boolPtr(true), // Reap the zombies execs leave behind
+		Resources: opts.Limits.resources(),
+	}, nil, nil, opts.Name)
+	if err != nil {
+		return fmt.Errorf("failed to create container %s: %w", opts.Name, err)
+	}
+
+	if err := c.docker.ContainerStart(ctx, created.ID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start container %s: %w", opts.Name, err)
+	}
+	return nil
+}
+
+// ensureImage pulls ref unless it is already present, writing the pull
+// status lines to out.
+func (c *Client) ensureImage(ctx context.Context, ref string, out io.Writer) error {
+	if _, _, err := c.docker.ImageInspectWithRaw(ctx, ref); err == nil {
+		return nil
+	} else if !client.IsErrNotFound(err) {
+		return fmt.Errorf("failed to inspect image %s: %w", ref, err)
+	}
+
+	progress, err := c.docker.ImagePull(ctx, ref, image.PullOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", ref, err)
+	}
+	defer progress.Close()
+
+	// The pull only finishes once its progress stream has been read
+	decoder := json.NewDecoder(progress)
+	for {
+		var msg struct {
+			Status string `json:"status"`
+			ID     string `json:"id"`
+			Error  string `json:"error"`
+		}
+		if err := decoder.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to pull image %s: %w", ref, err)
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("failed to pull image %s: %s", ref, msg.Error)
+		}
+		// Layer progress is noise in job logs; keep the summary lines
+		if out != nil && msg.ID == "" {
+			fmt.Fprintln(out, msg.Status)
+		}
+	}
+}
+
+// RemoveContainer force-removes a container started by RunContainer, with
+// its anonymous volumes when removeVolumes is set. A missing container is
+// not an error.
+func (c *Client) RemoveContainer(ctx context.Context, name string, removeVolumes bool) error {
+	err := c.docker.ContainerRemove(ctx, name, container.RemoveOptions{
+		Force:         true,
+		RemoveVolumes: removeVolumes,
+	})
+	if err != nil && !client.IsErrNotFound(err) {
+		return fmt.Errorf("failed to remove container %s: %w", name, err)
+	}
+	return nil
+}
+
+// resources converts the limits for the Docker API.
+func (l ResourceLimits) resources() container.Resources {
+	resources := container.Resources{
+		NanoCPUs: int64(l.CPUs * 1e9),
+		Memory:   l.Memory,
+	}
+	if l.Pids > 0 {
+		resources.PidsLimit = &l.Pids
+	}
+	return resources
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -0,0 +1,159 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// PortsMode selects what happens to the host ports a compose file
+// publishes. Jobs running the same compose file side by side would collide
+// on fixed host ports.
+type PortsMode string
+
+const (
+	PortsEphemeral PortsMode = "ephemeral" // Published on host ports Docker picks
+	PortsStrip     PortsMode = "strip"     // Not published at all
+	PortsKeep      PortsMode = "keep"      // Published as the compose file says
+)
+
+// ParsePortsMode validates a ports mode. Empty means ephemeral.
+func ParsePortsMode(s string) (PortsMode, error) {
+	switch mode := PortsMode(s); mode {
+	case "":
+		return PortsEphemeral, nil
+	case PortsEphemeral, PortsStrip, PortsKeep:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid ports mode %q (want ephemeral, strip or keep)", s)
+	}
+}
+
+// MinComposeResetVersion is the oldest Compose that understands the !reset
+// and !override tags the override uses to replace a service's ports.
+const MinComposeResetVersion = "2.24.4"
+
+// SupportsReset reports whether Compose can replace lists in an override,
+// which ports modes other than keep need.
+func (v *Versions) SupportsReset() bool {
+	return compareVersions(v.Compose, MinComposeResetVersion) >= 0
+}
+
+// PortMapping is a container port published on the host.
+type PortMapping struct {
+	Service       string `json:"service"`
+	ContainerPort int    `json:"container_port"`
+	Protocol      string `json:"protocol"`
+	HostIP        string `json:"host_ip,omitempty"`
+	HostPort      int    `json:"host_port"`
+}
+
+// String describes the mapping, e.g. "app 3000/tcp -> 0.0.0.0:49153".
+func (p PortMapping) String() string {
+	host := strconv.Itoa(p.HostPort)
+	if p.HostIP != "" {
+		host = p.HostIP + ":" + host
+	}
+	return fmt.Sprintf("%s %d/%s -> %s", p.Service, p.ContainerPort, p.Protocol, host)
+}
+
+// PublishedPorts lists the ports the containers of a compose project
+// publish on the host, by service and container port. A port Docker
+// publishes on both IPv4 and IPv6 is listed once.
+func (c *Client) PublishedPorts(ctx context.Context, projectName string) ([]PortMapping, error) {
+	containers, err := c.docker.ContainerList(ctx, container.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", projectLabel+"="+projectName)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers of %s: %w", projectName, err)
+	}
+
+	var ports []PortMapping
+	seen := map[string]bool{}
+	for _, ctr := range containers {
+		for _, p := range ctr.Ports {
+			if p.PublicPort == 0 {
+				continue
+			}
+			mapping := PortMapping{
+				Service:       ctr.Labels[serviceLabel],
+				ContainerPort: int(p.PrivatePort),
+				Protocol:      p.Type,
+				HostIP:        p.IP,
+				HostPort:      int(p.PublicPort),
+			}
+			key := fmt.Sprintf("%s/%d/%s/%d", mapping.Service, mapping.ContainerPort, mapping.Protocol, mapping.HostPort)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			ports = append(ports, mapping)
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].Service != ports[j].Service {
+			return ports[i].Service < ports[j].Service
+		}
+		return ports[i].ContainerPort < ports[j].ContainerPort
+	})
+	return ports, nil
+}
+
+// ephemeralPorts rewrites the entries of a ports: list to publish the same
+// container ports, on the same host addresses, on host ports Docker picks.
+// Both the short ("8080:80", "127.0.0.1:8080:80/udp") and the long syntax
+// are rewritten to the short one.
+func ephemeralPorts(entries []any) []string {
+	ports := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		switch entry := entry.(type) {
+		case map[string]any:
+			port := fmt.Sprint(entry["target"])
+			if protocol, ok := entry["protocol"]; ok {
+				port += "/" + fmt.Sprint(protocol)
+			}
+			if hostIP, ok := entry["host_ip"]; ok {
+				port = fmt.Sprintf("%v::%s", hostIP, port)
+			}
+			ports = append(ports, port)
+		default:
+			parts := splitPort(fmt.Sprint(entry))
+			switch len(parts) {
+			case 2:
+				ports = append(ports, parts[1])
+			case 3:
+				ports = append(ports, parts[0]+"::"+parts[2])
+			default:
+				ports = append(ports, strings.Join(parts, ":"))
+			}
+		}
+	}
+	return ports
+}
+
+// splitPort splits a short syntax ports: entry at its colons, except those
+// inside brackets (IPv6 addresses) and braces (variable defaults like
+// ${PORT:-8080}).
+func splitPort(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		case ':':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,52 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePortsMode(t *testing.T) {
+	for s, want := range map[string]PortsMode{"": PortsEphemeral, "strip": PortsStrip, "keep": PortsKeep} {
+		if got, err := ParsePortsMode(s); err != nil || got != want {
+			t.Errorf("ParsePortsMode(%q) = %q, %v; want %q", s, got, err, want)
+		}
+	}
+	if _, err := ParsePortsMode("random"); err == nil {
+		t.Error("ParsePortsMode(random) succeeded, want error")
+	}
+}
+
+func TestEphemeralPorts(t *testing.T) {
+	entries := []any{
+		"3000",
+		"8080:80",
+		"127.0.0.1:5432:5432",
+		"127.0.0.1::6379",
+		"9000-9002:9000-9002/udp",
+		"${WEB_PORT:-8080}:80",
+		"[::1]:8443:443",
+		map[string]any{"target": 80, "published": 8080, "protocol": "tcp"},
+		map[string]any{"target": 53, "published": "5353", "host_ip": "127.0.0.1"},
+	}
+	want := []string{
+		"3000",
+		"80",
+		"127.0.0.1::5432",
+		"127.0.0.1::6379",
+		"9000-9002/udp",
+		"80",
+		"[::1]::443",
+		"80/tcp",
+		"127.0.0.1::53",
+	}
+	if got := ephemeralPorts(entries); !reflect.DeepEqual(got, want) {
+		t.Errorf("ephemeralPorts() = %q, want %q", got, want)
+	}
+}
+
+func TestPortMappingString(t *testing.T) {
+	p := PortMapping{Service: "app", ContainerPort: 3000, Protocol: "tcp", HostIP: "0.0.0.0", HostPort: 49153}
+	if got, want := p.String(), "app 3000/tcp -> 0.0.0.0:49153"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
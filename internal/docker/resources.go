@@ -0,0 +1,137 @@
+package docker
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// CleanupLevel selects what ComposeDown removes besides containers and
+// networks.
+type CleanupLevel string
+
+const (
+	CleanupContainers CleanupLevel = "containers" // Containers and networks
+	CleanupVolumes    CleanupLevel = "volumes"    // ... and named volumes
+	CleanupImages     CleanupLevel = "images"     // ... and images built by compose
+)
+
+// ParseCleanupLevel validates a cleanup level. Empty means containers.
+func ParseCleanupLevel(s string) (CleanupLevel, error) {
+	switch level := CleanupLevel(s); level {
+	case "":
+		return CleanupContainers, nil
+	case CleanupContainers, CleanupVolumes, CleanupImages:
+		return level, nil
+	default:
+		return "", fmt.Errorf("invalid cleanup level %q (want containers, volumes or images)", s)
+	}
+}
+
+// downArgs returns the extra `compose down` flags for a level.
+func (l CleanupLevel) downArgs() []string {
+	switch l {
+	case CleanupVolumes:
+		return []string{"--volumes"}
+	case CleanupImages:
+		// local only removes images without a custom tag, i.e. the ones
+		// compose built; pulled base images stay cached
+		return []string{"--volumes", "--rmi", "local"}
+	default:
+		return nil
+	}
+}
+
+// Resource kinds, in the order they can be removed.
+const (
+	KindContainer = "container"
+	KindNetwork   = "network"
+	KindVolume    = "volume"
+	KindImage     = "image"
+)
+
+// Resource is a Docker object created for a compose project.
+type Resource struct {
+	Kind    string
+	ID      string
+	Name    string
+	Project string // Compose project name
+}
+
+// projectLabel is the label compose puts on the objects it creates.
+const projectLabel = "com.docker.compose.project"
+
+// ListComposeResources returns the containers, networks, volumes and images
+// of compose projects whose name starts with prefix. Images are matched by
+// name, as compose names them <project>-<service>.
+func (c *Client) ListComposeResources(ctx context.Context, prefix string) ([]Resource, error) {
+	label := `{{.Label "` + projectLabel + `"}}`
+	listings := []struct {
+		kind string
+		args []string
+	}{
+		{KindContainer, []string{"ps", "-a", "--filter", "label=" + projectLabel, "--format", "{{.ID}}\t{{.Names}}\t" + label}},
+		{KindNetwork, []string{"network", "ls", "--filter", "label=" + projectLabel, "--format", "{{.ID}}\t{{.Name}}\t" + label}},
+		{KindVolume, []string{"volume", "ls", "--filter", "label=" + projectLabel, "--format", "{{.Name}}\t{{.Name}}\t" + label}},
+		{KindImage, []string{"image", "ls", "--filter", "reference=" + prefix + "*", "--format", "{{.ID}}\t{{.Repository}}:{{.Tag}}\t{{.Repository}}"}},
+	}
+
+	var resources []Resource
+	for _, l := range listings {
+		output, err := exec.CommandContext(ctx, "docker", l.args...).Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %ss: %w", l.kind, err)
+		}
+		for _, r := range parseResources(l.kind, string(output)) {
+			if strings.HasPrefix(r.Project, prefix) {
+				resources = append(resources, r)
+			}
+		}
+	}
+	return resources, nil
+}
+
+// parseResources parses "id\tname\tproject" lines. For images the third
+// column is the repository, <project>-<service>; job project names contain
+// no dashes, so the project ends at the first one.
+func parseResources(kind, output string) []Resource {
+	var resources []Resource
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 3 || fields[0] == "" {
+			continue
+		}
+		project := fields[2]
+		if kind == KindImage {
+			project, _, _ = strings.Cut(project, "-")
+		}
+		resources = append(resources, Resource{Kind: kind, ID: fields[0], Name: fields[1], Project: project})
+	}
+	return resources
+}
+
+// RemoveResource force-removes a Docker object.
+func (c *Client) RemoveResource(ctx context.Context, r Resource) error {
+	var args []string
+	switch r.Kind {
+	case KindContainer:
+		args = []string{"rm", "-f", "-v", r.ID}
+	case KindNetwork:
+		args = []string{"network", "rm", r.ID}
+	case KindVolume:
+		args = []string{"volume", "rm", "-f", r.ID}
+	case KindImage:
+		args = []string{"image", "rm", "-f", r.ID}
+	default:
+		return fmt.Errorf("unknown resource kind %q", r.Kind)
+	}
+
+	output, err := exec.CommandContext(ctx, "docker", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to remove %s %s: %w: %s", r.Kind, r.Name, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
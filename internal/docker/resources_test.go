@@ -0,0 +1,50 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseResources(t *testing.T) {
+	containers := "abc123\tmanfred_job_1-app-1\tmanfred_job_1\n\ndef456\tbroken\n"
+	got := parseResources(KindContainer, containers)
+	want := []Resource{{Kind: KindContainer, ID: "abc123", Name: "manfred_job_1-app-1", Project: "manfred_job_1"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseResources(containers) = %+v, want %+v", got, want)
+	}
+
+	images := "sha1\tmanfred_job_1-app:latest\tmanfred_job_1-app\nsha2\tmanfred_job_2-my-worker:latest\tmanfred_job_2-my-worker\n"
+	got = parseResources(KindImage, images)
+	if len(got) != 2 || got[0].Project != "manfred_job_1" || got[1].Project != "manfred_job_2" {
+		t.Errorf("parseResources(images) = %+v", got)
+	}
+}
+
+func TestParseCleanupLevel(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    CleanupLevel
+		args    []string
+		wantErr bool
+	}{
+		{value: "", want: CleanupContainers},
+		{value: "containers", want: CleanupContainers},
+		{value: "volumes", want: CleanupVolumes, args: []string{"--volumes"}},
+		{value: "images", want: CleanupImages, args: []string{"--volumes", "--rmi", "local"}},
+		{value: "everything", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCleanupLevel(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCleanupLevel(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseCleanupLevel(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+		if args := got.downArgs(); !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%q.downArgs() = %v, want %v", got, args, tt.args)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// Usage is a resource usage sample of a container.
+type Usage struct {
+	CPUPercent  float64 // Percent of one CPU; 200 means two busy CPUs
+	MemoryBytes int64   // Excluding the page cache, like `docker stats`
+	MemoryLimit int64   // The container's limit, or the host's memory
+	Pids        int64
+}
+
+// MemoryPercent returns the memory usage as a percentage of the limit.
+func (u Usage) MemoryPercent() float64 {
+	if u.MemoryLimit <= 0 {
+		return 0
+	}
+	return float64(u.MemoryBytes) / float64(u.MemoryLimit) * 100
+}
+
+// ContainerUsage samples a container's CPU and memory usage through the
+// stats API. Docker measures CPU usage over about a second, so the call
+// takes that long.
+func (c *Client) ContainerUsage(ctx context.Context, containerName string) (*Usage, error) {
+	resp, err := c.docker.ContainerStats(ctx, containerName, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats of %s: %w", containerName, err)
+	}
+	defer resp.Body.Close()
+
+	var stats container.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, fmt.Errorf("failed to read stats of %s: %w", containerName, err)
+	}
+	usage := usageFromStats(&stats.Stats)
+	return &usage, nil
+}
+
+// usageFromStats computes a Usage the way the docker CLI does: CPU usage is
+// the container's share of the system CPU time since the previous sample,
+// memory usage leaves out inactive page cache (cgroup v2) or the cache
+// (cgroup v1).
+func usageFromStats(stats *container.Stats) Usage {
+	usage := Usage{
+		MemoryLimit: int64(stats.MemoryStats.Limit),
+		Pids:        int64(stats.PidsStats.Current),
+	}
+
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	cpus := float64(stats.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		usage.CPUPercent = cpuDelta / systemDelta * cpus * 100
+	}
+
+	memory := stats.MemoryStats.Usage
+	cache, ok := stats.MemoryStats.Stats["inactive_file"]
+	if !ok {
+		cache = stats.MemoryStats.Stats["total_inactive_file"]
+	}
+	if cache < memory {
+		memory -= cache
+	}
+	usage.MemoryBytes = int64(memory)
+
+	return usage
+}
@@ -0,0 +1,41 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestUsageFromStats(t *testing.T) {
+	stats := &container.Stats{
+		CPUStats: container.CPUStats{
+			CPUUsage:    container.CPUUsage{TotalUsage: 3_000_000},
+			SystemUsage: 20_000_000,
+			OnlineCPUs:  4,
+		},
+		PreCPUStats: container.CPUStats{
+			CPUUsage:    container.CPUUsage{TotalUsage: 1_000_000},
+			SystemUsage: 10_000_000,
+		},
+		MemoryStats: container.MemoryStats{
+			Usage: 600 << 20,
+			Limit: 1 << 30,
+			Stats: map[string]uint64{"inactive_file": 88 << 20},
+		},
+		PidsStats: container.PidsStats{Current: 12},
+	}
+
+	got := usageFromStats(stats)
+	want := Usage{CPUPercent: 80, MemoryBytes: 512 << 20, MemoryLimit: 1 << 30, Pids: 12}
+	if got != want {
+		t.Errorf("usageFromStats() = %+v, want %+v", got, want)
+	}
+	if p := got.MemoryPercent(); p != 50 {
+		t.Errorf("MemoryPercent() = %v, want 50", p)
+	}
+
+	// The first sample of a container has no previous CPU stats
+	if got := usageFromStats(&container.Stats{}); got.CPUPercent != 0 || got.MemoryPercent() != 0 {
+		t.Errorf("usageFromStats(empty) = %+v, want zero usage", got)
+	}
+}
@@ -0,0 +1,109 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Minimum supported versions. Compose v1 names containers
+// <project>_<service>_1 and rejects the service-level limits of the compose
+// override, so jobs need Compose v2; Docker 20.10 is the oldest engine
+// Compose v2 supports.
+const (
+	MinDockerVersion  = "20.10.0"
+	MinComposeVersion = "2.0.0"
+)
+
+// Versions describes the installed Docker engine and Compose.
+type Versions struct {
+	Docker         string   // Engine version; empty if the daemon is unreachable
+	DockerErr      error    // Why Docker is empty
+	Compose        string   // Compose version; empty if not installed
+	ComposeCommand []string // How compose is run: docker compose, or the docker-compose binary
+}
+
+// DetectVersions asks the docker CLI for the engine version and looks for
+// Compose, first as the docker compose plugin and then as the standalone
+// docker-compose binary.
+func DetectVersions(ctx context.Context) *Versions {
+	v := &Versions{}
+	output, err := exec.CommandContext(ctx, "docker", "version", "--format", "{{.Server.Version}}").CombinedOutput()
+	if err != nil {
+		v.DockerErr = fmt.Errorf("docker version: %w: %s", err, strings.TrimSpace(string(output)))
+	} else {
+		v.Docker = strings.TrimSpace(string(output))
+	}
+
+	for _, command := range [][]string{{"docker", "compose"}, {"docker-compose"}} {
+		args := append(append([]string{}, command[1:]...), "version", "--short")
+		output, err := exec.CommandContext(ctx, command[0], args...).Output()
+		if err != nil {
+			continue
+		}
+		v.Compose = strings.TrimPrefix(strings.TrimSpace(string(output)), "v")
+		v.ComposeCommand = command
+		break
+	}
+	return v
+}
+
+// CheckDocker returns an error unless the engine is reachable and at least
+// MinDockerVersion.
+func (v *Versions) CheckDocker() error {
+	if v.DockerErr != nil {
+		return fmt.Errorf("docker is not available: %w", v.DockerErr)
+	}
+	if compareVersions(v.Docker, MinDockerVersion) < 0 {
+		return fmt.Errorf("docker %s is not supported, MANFRED needs %s or newer", v.Docker, MinDockerVersion)
+	}
+	return nil
+}
+
+// CheckCompose returns an error unless Compose is installed and at least
+// MinComposeVersion.
+func (v *Versions) CheckCompose() error {
+	if v.ComposeCommand == nil {
+		return fmt.Errorf("docker compose is not installed, MANFRED needs Compose %s or newer", MinComposeVersion)
+	}
+	if compareVersions(v.Compose, MinComposeVersion) < 0 {
+		return fmt.Errorf("compose %s (%s) is not supported, MANFRED needs Compose %s or newer",
+			v.Compose, strings.Join(v.ComposeCommand, " "), MinComposeVersion)
+	}
+	return nil
+}
+
+// compareVersions compares the major.minor.patch prefixes of two versions
+// like 24.0.7 or 2.21.0-desktop.1, returning -1, 0 or 1. Missing or
+// unparsable parts count as 0.
+func compareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := range pa {
+		switch {
+		case pa[i] < pb[i]:
+			return -1
+		case pa[i] > pb[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// versionParts returns the numeric major, minor and patch of a version.
+func versionParts(version string) [3]int {
+	var parts [3]int
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	for i, field := range strings.SplitN(version, ".", 3) {
+		end := strings.IndexFunc(field, func(r rune) bool { return r < '0' || r > '9' })
+		if end >= 0 {
+			field = field[:end]
+		}
+		parts[i], _ = strconv.Atoi(field)
+		if end >= 0 {
+			break
+		}
+	}
+	return parts
+}
@@ -0,0 +1,54 @@
+package docker
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"24.0.7", "20.10.0", 1},
+		{"20.10.0", "20.10.0", 0},
+		{"19.03.15", "20.10.0", -1},
+		{"v2.21.0", "2.0.0", 1},
+		{"2.21.0-desktop.1", "2.21.0", 0},
+		{"1.29.2", "2.0.0", -1},
+		{"2", "2.0.0", 0},
+		{"", "2.0.0", -1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestVersionsCheck(t *testing.T) {
+	plugin := []string{"docker", "compose"}
+	tests := []struct {
+		name     string
+		versions Versions
+		docker   bool
+		compose  bool
+	}{
+		{"supported", Versions{Docker: "24.0.7", Compose: "2.21.0", ComposeCommand: plugin}, true, true},
+		{"standalone compose v2", Versions{Docker: "24.0.7", Compose: "2.5.0", ComposeCommand: []string{"docker-compose"}}, true, true},
+		{"compose v1", Versions{Docker: "24.0.7", Compose: "1.29.2", ComposeCommand: []string{"docker-compose"}}, true, false},
+		{"no compose", Versions{Docker: "24.0.7"}, true, false},
+		{"old docker", Versions{Docker: "19.03.15", Compose: "2.0.0", ComposeCommand: plugin}, false, true},
+		{"no daemon", Versions{DockerErr: errors.New("cannot connect"), Compose: "2.21.0", ComposeCommand: plugin}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.versions.CheckDocker(); (err == nil) != tt.docker {
+				t.Errorf("CheckDocker() = %v, want ok %v", err, tt.docker)
+			}
+			if err := tt.versions.CheckCompose(); (err == nil) != tt.compose {
+				t.Errorf("CheckCompose() = %v, want ok %v", err, tt.compose)
+			}
+		})
+	}
+}
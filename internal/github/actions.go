@@ -0,0 +1,84 @@
+package github
+
+import (
+	"context"
+	"fmt"
+)
+
+// CI conclusions that count as a failed build.
+var failedConclusions = map[string]bool{"failure": true, "timed_out": true}
+
+// FailedConclusion reports whether a check run, workflow run or job
+// conclusion is a failure. Cancelled and skipped runs are not.
+func FailedConclusion(conclusion string) bool {
+	return failedConclusions[conclusion]
+}
+
+// PassedConclusion reports whether a check run conclusion lets a pull
+// request merge: success, or a check that was neutral or skipped.
+func PassedConclusion(conclusion string) bool {
+	return conclusion == "success" || conclusion == "neutral" || conclusion == "skipped"
+}
+
+// WorkflowRun is a GitHub Actions workflow run.
+type WorkflowRun struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	HeadBranch string `json:"head_branch"`
+	HeadSHA    string `json:"head_sha"`
+	Status     string `json:"status"`     // "queued", "in_progress", "completed"
+	Conclusion string `json:"conclusion"` // "success", "failure", "cancelled", ...
+	HTMLURL    string `json:"html_url"`
+}
+
+// WorkflowJob is a job of a workflow run.
+type WorkflowJob struct {
+	ID         int64          `json:"id"`
+	Name       string         `json:"name"`
+	Conclusion string         `json:"conclusion"`
+	HTMLURL    string         `json:"html_url"`
+	Steps      []WorkflowStep `json:"steps"`
+}
+
+// WorkflowStep is a step of a workflow job.
+type WorkflowStep struct {
+	Name       string `json:"name"`
+	Number     int    `json:"number"`
+	Conclusion string `json:"conclusion"`
+}
+
+// FailedStep returns the name of the job's first failed step, or "".
+func (j WorkflowJob) FailedStep() string {
+	for _, step := range j.Steps {
+		if FailedConclusion(step.Conclusion) {
+			return step.Name
+		}
+	}
+	return ""
+}
+
+// ActionsApp is the app slug of check runs created by GitHub Actions.
+const ActionsApp = "github-actions"
+
+// ListWorkflowRunJobs returns the jobs of the latest attempt of a workflow
+// run.
+func (c *Client) ListWorkflowRunJobs(ctx context.Context, owner, repo string, runID int64) ([]WorkflowJob, error) {
+	path := fmt.Sprintf("/repos/%s/%s/actions/runs/%d/jobs?filter=latest&per_page=100", owner, repo, runID)
+	var resp struct {
+		Jobs []WorkflowJob `json:"jobs"`
+	}
+	if err := c.get(ctx, path, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Jobs, nil
+}
+
+// GetWorkflowJobLogs returns the plain text log of a workflow job.
+func (c *Client) GetWorkflowJobLogs(ctx context.Context, owner, repo string, jobID int64) (string, error) {
+	path := fmt.Sprintf("/repos/%s/%s/actions/jobs/%d/logs", owner, repo, jobID)
+	var log []byte
+	if err := c.get(ctx, path, &log); err != nil {
+		return "", err
+	}
+	return string(log), nil
+}
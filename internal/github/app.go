@@ -0,0 +1,194 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// tokenRefreshMargin is how long before expiry an installation token is
+// replaced.
+const tokenRefreshMargin = 5 * time.Minute
+
+// appAuth generates installation access tokens for a GitHub App.
+type appAuth struct {
+	appID          int64
+	installationID int64
+	key            *rsa.PrivateKey
+
+	mu          sync.Mutex
+	token       string
+	expiresAt   time.Time
+	permissions map[string]string // Of the installation, e.g. "issues": "write"
+}
+
+// WithAppAuth authenticates as a GitHub App installation instead of with a
+// personal access token. Installation tokens are created from a JWT signed
+// with the app's private key and refreshed before they expire.
+func WithAppAuth(appID, installationID int64, key *rsa.PrivateKey) ClientOption {
+	return func(c *Client) {
+		c.app = &appAuth{
+			appID:          appID,
+			installationID: installationID,
+			key:            key,
+		}
+	}
+}
+
+// ParsePrivateKey parses a PEM-encoded RSA private key as downloaded from
+// the GitHub App settings (PKCS#1), or in PKCS#8 form.
+func ParsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in private key")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// Token returns the token the client authenticates with: the personal
+// access token, or a current installation token of the GitHub App, e.g. for
+// git over HTTPS.
+func (c *Client) Token(ctx context.Context) (string, error) {
+	return c.authToken(ctx)
+}
+
+// authToken returns the token to send with API requests.
+func (c *Client) authToken(ctx context.Context) (string, error) {
+	if c.app == nil {
+		return c.token, nil
+	}
+	return c.app.installationToken(ctx, c)
+}
+
+// installationToken returns a cached installation token, creating a new one
+// when none exists or the current one is about to expire.
+func (a *appAuth) installationToken(ctx context.Context, c *Client) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && time.Until(a.expiresAt) > tokenRefreshMargin {
+		return a.token, nil
+	}
+
+	jwt, err := a.jwt(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", c.baseURL, a.installationID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("installation token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		_ = json.Unmarshal(body, apiErr)
+		if apiErr.Message == "" {
+			apiErr.Message = fmt.Sprintf("GitHub API error: %s", resp.Status)
+		}
+		return "", fmt.Errorf("failed to create installation token: %w", apiErr)
+	}
+
+	var result struct {
+		Token       string            `json:"token"`
+		ExpiresAt   time.Time         `json:"expires_at"`
+		Permissions map[string]string `json:"permissions"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to decode installation token: %w", err)
+	}
+
+	a.token = result.Token
+	a.expiresAt = result.ExpiresAt
+	a.permissions = result.Permissions
+	return a.token, nil
+}
+
+// installationPermissions returns the permissions granted with the last
+// installation token, nil before the first one.
+func (a *appAuth) installationPermissions() map[string]string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.permissions
+}
+
+// jwt creates an RS256-signed JSON Web Token identifying the app. GitHub
+// accepts tokens valid for at most 10 minutes; iat is backdated to allow for
+// clock drift.
+func (a *appAuth) jwt(now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-60 * time.Second).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(a.appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JWT: %w", err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// InstallationRepoCount returns how many repositories the app installation
+// can access. It verifies app credentials, since installation tokens cannot
+// call /user.
+func (c *Client) InstallationRepoCount(ctx context.Context) (int, error) {
+	var result struct {
+		TotalCount int `json:"total_count"`
+	}
+	if err := c.get(ctx, "/installation/repositories?per_page=1", &result); err != nil {
+		return 0, err
+	}
+	return result.TotalCount, nil
+}
+
+// IsApp reports whether the client authenticates as a GitHub App.
+func (c *Client) IsApp() bool {
+	return c.app != nil
+}
@@ -0,0 +1,142 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey() error = %v", err)
+	}
+	pkcs8 := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	for name, data := range map[string][]byte{"pkcs1": pkcs1, "pkcs8": pkcs8} {
+		got, err := ParsePrivateKey(data)
+		if err != nil {
+			t.Errorf("ParsePrivateKey(%s) error = %v", name, err)
+			continue
+		}
+		if !got.Equal(key) {
+			t.Errorf("ParsePrivateKey(%s) returned a different key", name)
+		}
+	}
+
+	if _, err := ParsePrivateKey([]byte("not a key")); err == nil {
+		t.Error("ParsePrivateKey(garbage) = nil error, want error")
+	}
+}
+
+func TestClient_AppAuth(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	var tokenRequests int
+	expiresAt := time.Now().Add(time.Hour)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/installations/7/access_tokens":
+			tokenRequests++
+			verifyJWT(t, key, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), "42")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"token":      "installation-token",
+				"expires_at": expiresAt,
+			})
+		case "/installation/repositories":
+			if got := r.Header.Get("Authorization"); got != "Bearer installation-token" {
+				t.Errorf("Authorization = %q, want installation token", got)
+			}
+			json.NewEncoder(w).Encode(map[string]int{"total_count": 3})
+		default:
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("", WithBaseURL(server.URL), WithAppAuth(42, 7, key))
+	if !client.IsApp() {
+		t.Fatal("IsApp() = false, want true")
+	}
+
+	for i := 0; i < 2; i++ {
+		count, err := client.InstallationRepoCount(context.Background())
+		if err != nil {
+			t.Fatalf("InstallationRepoCount() error = %v", err)
+		}
+		if count != 3 {
+			t.Errorf("InstallationRepoCount() = %d, want 3", count)
+		}
+	}
+	if tokenRequests != 1 {
+		t.Errorf("token requests = %d, want 1 (token should be cached)", tokenRequests)
+	}
+
+	// A token close to expiry is refreshed.
+	client.app.expiresAt = time.Now().Add(time.Minute)
+	if _, err := client.InstallationRepoCount(context.Background()); err != nil {
+		t.Fatalf("InstallationRepoCount() error = %v", err)
+	}
+	if tokenRequests != 2 {
+		t.Errorf("token requests = %d, want 2 after expiry", tokenRequests)
+	}
+}
+
+// verifyJWT checks the signature and issuer of an app JWT.
+func verifyJWT(t *testing.T, key *rsa.PrivateKey, token, wantIssuer string) {
+	t.Helper()
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("JWT has %d parts, want 3", len(parts))
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("decode signature: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		t.Errorf("JWT signature invalid: %v", err)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("decode claims: %v", err)
+	}
+	var claims struct {
+		Iss string `json:"iss"`
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatalf("unmarshal claims: %v", err)
+	}
+	if claims.Iss != wantIssuer {
+		t.Errorf("iss = %q, want %q", claims.Iss, wantIssuer)
+	}
+	if claims.Exp-claims.Iat > 600 {
+		t.Errorf("JWT lifetime = %ds, want <= 600", claims.Exp-claims.Iat)
+	}
+}
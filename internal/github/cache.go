@@ -0,0 +1,94 @@
+package github
+
+import (
+	"container/list"
+	"sync"
+)
+
+// cachedResponse is a GET response body stored with its validators.
+type cachedResponse struct {
+	url          string
+	etag         string
+	lastModified string
+	body         []byte
+}
+
+// responseCache is a size-bounded LRU cache of GET responses keyed by URL.
+// Cached entries are revalidated with conditional requests; GitHub does not
+// count 304 Not Modified responses against the rate limit.
+type responseCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List // front is most recently used
+}
+
+func newResponseCache(maxEntries int) *responseCache {
+	return &responseCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// WithResponseCache caches up to maxEntries GET responses and revalidates
+// them with If-None-Match / If-Modified-Since. Zero disables the cache.
+func WithResponseCache(maxEntries int) ClientOption {
+	return func(c *Client) {
+		if maxEntries <= 0 {
+			c.cache = nil
+			return
+		}
+		c.cache = newResponseCache(maxEntries)
+	}
+}
+
+// get returns the cached response for url, if any.
+func (rc *responseCache) get(url string) (*cachedResponse, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	el, ok := rc.entries[url]
+	if !ok {
+		return nil, false
+	}
+	rc.order.MoveToFront(el)
+	return el.Value.(*cachedResponse), true
+}
+
+// put stores a response, evicting the least recently used entry when full.
+func (rc *responseCache) put(entry *cachedResponse) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if el, ok := rc.entries[entry.url]; ok {
+		el.Value = entry
+		rc.order.MoveToFront(el)
+		return
+	}
+
+	rc.entries[entry.url] = rc.order.PushFront(entry)
+	for rc.order.Len() > rc.maxEntries {
+		oldest := rc.order.Back()
+		rc.order.Remove(oldest)
+		delete(rc.entries, oldest.Value.(*cachedResponse).url)
+	}
+}
+
+// remove drops the entry for url.
+func (rc *responseCache) remove(url string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if el, ok := rc.entries[url]; ok {
+		rc.order.Remove(el)
+		delete(rc.entries, url)
+	}
+}
+
+// len returns the number of cached responses.
+func (rc *responseCache) len() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.order.Len()
+}
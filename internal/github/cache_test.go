@@ -0,0 +1,97 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_ETagCache(t *testing.T) {
+	var requests, notModified int
+	title := "First title"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		etag := `"` + title + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		json.NewEncoder(w).Encode(Issue{Number: 1, Title: title})
+	}))
+	defer server.Close()
+
+	client := NewClient("token", WithBaseURL(server.URL), WithResponseCache(10))
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		issue, err := client.GetIssue(ctx, "o", "r", 1)
+		if err != nil {
+			t.Fatalf("GetIssue() error = %v", err)
+		}
+		if issue.Title != "First title" {
+			t.Errorf("request %d: Title = %q, want %q", i, issue.Title, "First title")
+		}
+	}
+	if notModified != 2 {
+		t.Errorf("304 responses = %d, want 2", notModified)
+	}
+
+	title = "Second title"
+	issue, err := client.GetIssue(ctx, "o", "r", 1)
+	if err != nil {
+		t.Fatalf("GetIssue() error = %v", err)
+	}
+	if issue.Title != "Second title" {
+		t.Errorf("after change: Title = %q, want %q", issue.Title, "Second title")
+	}
+	if requests != 4 {
+		t.Errorf("requests = %d, want 4", requests)
+	}
+}
+
+func TestClient_NoCacheByDefault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			t.Error("unexpected conditional request without cache")
+		}
+		w.Header().Set("ETag", `"v1"`)
+		json.NewEncoder(w).Encode(Issue{Number: 1})
+	}))
+	defer server.Close()
+
+	client := NewClient("token", WithBaseURL(server.URL))
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetIssue(context.Background(), "o", "r", 1); err != nil {
+			t.Fatalf("GetIssue() error = %v", err)
+		}
+	}
+}
+
+func TestResponseCache_Evicts(t *testing.T) {
+	rc := newResponseCache(2)
+	rc.put(&cachedResponse{url: "a", etag: "1"})
+	rc.put(&cachedResponse{url: "b", etag: "1"})
+	rc.get("a") // a is now most recently used
+	rc.put(&cachedResponse{url: "c", etag: "1"})
+
+	if _, ok := rc.get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	for _, url := range []string{"a", "c"} {
+		if _, ok := rc.get(url); !ok {
+			t.Errorf("expected %s to be cached", url)
+		}
+	}
+	if rc.len() != 2 {
+		t.Errorf("len() = %d, want 2", rc.len())
+	}
+
+	rc.remove("a")
+	if _, ok := rc.get("a"); ok {
+		t.Error("expected a to be removed")
+	}
+}
@@ -0,0 +1,76 @@
+package github
+
+import (
+	"context"
+	"fmt"
+)
+
+// Commit status states.
+const (
+	StatusPending = "pending"
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+	StatusError   = "error"
+)
+
+// CreateCommitStatus sets a status on a commit. Statuses with the same
+// context replace each other.
+func (c *Client) CreateCommitStatus(ctx context.Context, owner, repo, sha string, status *CommitStatus) (*CommitStatus, error) {
+	path := fmt.Sprintf("/repos/%s/%s/statuses/%s", owner, repo, sha)
+	var created CommitStatus
+	if err := c.post(ctx, path, status, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// CreateCheckRun creates a check run on a commit. Only GitHub Apps can
+// create check runs.
+func (c *Client) CreateCheckRun(ctx context.Context, owner, repo string, run *CheckRun) (*CheckRun, error) {
+	path := fmt.Sprintf("/repos/%s/%s/check-runs", owner, repo)
+	var created CheckRun
+	if err := c.post(ctx, path, run, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdateCheckRun updates the status, conclusion or output of a check run.
+func (c *Client) UpdateCheckRun(ctx context.Context, owner, repo string, id int64, run *CheckRun) (*CheckRun, error) {
+	path := fmt.Sprintf("/repos/%s/%s/check-runs/%d", owner, repo, id)
+	var updated CheckRun
+	if err := c.patch(ctx, path, run, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// CombinedStatus is the overall state of the commit statuses of a ref.
+type CombinedStatus struct {
+	State      string         `json:"state"` // pending, success, failure
+	TotalCount int            `json:"total_count"`
+	Statuses   []CommitStatus `json:"statuses"`
+}
+
+// GetCombinedStatus returns the latest commit status of each context on a
+// ref. Refs without statuses are pending with a TotalCount of 0.
+func (c *Client) GetCombinedStatus(ctx context.Context, owner, repo, ref string) (*CombinedStatus, error) {
+	path := fmt.Sprintf("/repos/%s/%s/commits/%s/status?per_page=100", owner, repo, ref)
+	var status CombinedStatus
+	if err := c.get(ctx, path, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// ListCheckRunsForRef returns the latest check runs on a ref.
+func (c *Client) ListCheckRunsForRef(ctx context.Context, owner, repo, ref string) ([]CheckRun, error) {
+	path := fmt.Sprintf("/repos/%s/%s/commits/%s/check-runs?filter=latest&per_page=100", owner, repo, ref)
+	var resp struct {
+		CheckRuns []CheckRun `json:"check_runs"`
+	}
+	if err := c.get(ctx, path, &resp); err != nil {
+		return nil, err
+	}
+	return resp.CheckRuns, nil
+}
@@ -6,8 +6,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/rand/v2"
 	"net/http"
+	"net/http/httptrace"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -24,10 +27,21 @@ type Client struct {
 	httpClient *http.Client
 	userAgent  string
 
+	// app is set when authenticating as a GitHub App installation
+	app *appAuth
+
 	// Rate limiting
-	rateMu        sync.Mutex
-	rateLimit     *RateLimit
-	rateLimitBuf  int // Stop when this many requests remain
+	rateMu       sync.Mutex
+	rateLimit    *RateLimit
+	rateLimitBuf int // Stop when this many requests remain
+	waitForReset bool
+
+	// Retries
+	maxRetries int
+	retryBase  time.Duration
+
+	// cache holds ETag-validated GET responses; nil disables caching
+	cache *responseCache
 }
 
 // ClientOption configures a Client.
@@ -54,6 +68,24 @@ func WithRateLimitBuffer(n int) ClientOption {
 	}
 }
 
+// WithRateLimitWait makes requests block until the rate limit resets instead
+// of failing with a RateLimitError. Waiting respects the request context.
+func WithRateLimitWait(wait bool) ClientOption {
+	return func(c *Client) {
+		c.waitForReset = wait
+	}
+}
+
+// WithRetries sets how often requests are retried after secondary rate
+// limits, connection errors and, for reads and deletes, server errors (5xx),
+// with jittered exponential backoff. Writes are only retried when GitHub
+// cannot have seen them, so a timed-out create is never sent twice.
+func WithRetries(n int) ClientOption {
+	return func(c *Client) {
+		c.maxRetries = n
+	}
+}
+
 // NewClient creates a new GitHub API client.
 func NewClient(token string, opts ...ClientOption) *Client {
 	c := &Client{
@@ -62,6 +94,7 @@ func NewClient(token string, opts ...ClientOption) *Client {
 		httpClient:   &http.Client{Timeout: 30 * time.Second},
 		userAgent:    defaultUserAgent,
 		rateLimitBuf: 100,
+		retryBase:    time.Second,
 	}
 
 	for _, opt := range opts {
@@ -71,21 +104,46 @@ func NewClient(token string, opts ...ClientOption) *Client {
 	return c
 }
 
-// do performs an HTTP request and decodes the response.
+// do performs an HTTP request and decodes the response. Depending on the
+// client options it waits for the rate limit to reset and retries failed
+// requests as WithRetries says.
 func (c *Client) do(ctx context.Context, method, path string, body, result interface{}) error {
-	// Check rate limit before making request
-	if err := c.checkRateLimit(); err != nil {
-		return err
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request body: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		// Check rate limit before making request
+		if err := c.waitForRateLimit(ctx); err != nil {
+			return err
+		}
+
+		err := c.doOnce(ctx, method, path, data, result)
+		delay, retry := c.retryDelay(method, err, attempt)
+		if !retry {
+			return err
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
 	}
+}
 
+// doOnce performs a single HTTP request.
+func (c *Client) doOnce(ctx context.Context, method, path string, data []byte, result interface{}) error {
 	url := c.baseURL + path
+	if path == graphQLPath && strings.HasSuffix(c.baseURL, "/api/v3") {
+		// GitHub Enterprise serves GraphQL beside the REST API
+		url = strings.TrimSuffix(c.baseURL, "/v3") + path
+	}
 
 	var bodyReader io.Reader
-	if body != nil {
-		data, err := json.Marshal(body)
-		if err != nil {
-			return fmt.Errorf("failed to marshal request body: %w", err)
-		}
+	if data != nil {
 		bodyReader = bytes.NewReader(data)
 	}
 
@@ -97,16 +155,37 @@ func (c *Client) do(ctx context.Context, method, path string, body, result inter
 	req.Header.Set("Accept", "application/vnd.github+json")
 	req.Header.Set("User-Agent", c.userAgent)
 	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
-	if c.token != "" {
-		req.Header.Set("Authorization", "Bearer "+c.token)
+	token, err := c.authToken(ctx)
+	if err != nil {
+		return err
 	}
-	if body != nil {
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if data != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
 
+	var cached *cachedResponse
+	if c.cache != nil && method == http.MethodGet {
+		if entry, ok := c.cache.get(url); ok {
+			cached = entry
+			if entry.etag != "" {
+				req.Header.Set("If-None-Match", entry.etag)
+			}
+			if entry.lastModified != "" {
+				req.Header.Set("If-Modified-Since", entry.lastModified)
+			}
+		}
+	}
+
+	var sent bool
+	req = req.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) { sent = true },
+	}))
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return &requestError{err: err, sent: sent}
 	}
 	defer resp.Body.Close()
 
@@ -119,9 +198,19 @@ func (c *Client) do(ctx context.Context, method, path string, body, result inter
 		return fmt.Errorf("failed to read response: %w", err)
 	}
 
+	// Serve validated responses from the cache
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		respBody = cached.body
+	} else if c.cache != nil && method == http.MethodGet {
+		c.storeResponse(url, resp, respBody)
+	}
+
 	// Check for errors
 	if resp.StatusCode >= 400 {
 		apiErr := &APIError{StatusCode: resp.StatusCode}
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			apiErr.RetryAfter = time.Duration(secs) * time.Second
+		}
 		if len(respBody) > 0 {
 			_ = json.Unmarshal(respBody, apiErr)
 		}
@@ -131,7 +220,11 @@ func (c *Client) do(ctx context.Context, method, path string, body, result inter
 		return apiErr
 	}
 
-	// Decode successful response
+	// Decode successful response; *[]byte results take the body as is
+	if raw, ok := result.(*[]byte); ok {
+		*raw = respBody
+		return nil
+	}
 	if result != nil && len(respBody) > 0 {
 		if err := json.Unmarshal(respBody, result); err != nil {
 			return fmt.Errorf("failed to decode response: %w", err)
@@ -141,6 +234,23 @@ func (c *Client) do(ctx context.Context, method, path string, body, result inter
 	return nil
 }
 
+// storeResponse caches a successful GET response that carries a validator,
+// and drops stale entries otherwise.
+func (c *Client) storeResponse(url string, resp *http.Response, body []byte) {
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	if resp.StatusCode != http.StatusOK || (etag == "" && lastModified == "") {
+		c.cache.remove(url)
+		return
+	}
+	c.cache.put(&cachedResponse{
+		url:          url,
+		etag:         etag,
+		lastModified: lastModified,
+		body:         body,
+	})
+}
+
 // get performs a GET request.
 func (c *Client) get(ctx context.Context, path string, result interface{}) error {
 	return c.do(ctx, http.MethodGet, path, nil, result)
@@ -151,11 +261,40 @@ func (c *Client) post(ctx context.Context, path string, body, result interface{}
 	return c.do(ctx, http.MethodPost, path, body, result)
 }
 
+// graphQLPath is the path of the GraphQL endpoint.
+const graphQLPath = "/graphql"
+
+// graphql runs a GraphQL query or mutation and decodes its data into result.
+// Errors in the response fail it.
+func (c *Client) graphql(ctx context.Context, query string, variables map[string]interface{}, result interface{}) error {
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := c.post(ctx, graphQLPath, map[string]interface{}{"query": query, "variables": variables}, &resp); err != nil {
+		return err
+	}
+	if len(resp.Errors) > 0 {
+		return fmt.Errorf("graphql: %s", resp.Errors[0].Message)
+	}
+	if result == nil || len(resp.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(resp.Data, result)
+}
+
 // patch performs a PATCH request.
 func (c *Client) patch(ctx context.Context, path string, body, result interface{}) error {
 	return c.do(ctx, http.MethodPatch, path, body, result)
 }
 
+// put performs a PUT request.
+func (c *Client) put(ctx context.Context, path string, body, result interface{}) error {
+	return c.do(ctx, http.MethodPut, path, body, result)
+}
+
 // delete performs a DELETE request.
 func (c *Client) delete(ctx context.Context, path string) error {
 	return c.do(ctx, http.MethodDelete, path, nil, nil)
@@ -207,6 +346,91 @@ func (c *Client) checkRateLimit() error {
 	return nil
 }
 
+// waitForRateLimit returns a RateLimitError when the buffer is exhausted, or
+// blocks until the reset time if the client is configured to wait.
+func (c *Client) waitForRateLimit(ctx context.Context) error {
+	err := c.checkRateLimit()
+	rlErr, ok := err.(*RateLimitError)
+	if !ok || !c.waitForReset {
+		return err
+	}
+	return sleep(ctx, time.Until(rlErr.Reset)+time.Second)
+}
+
+// requestError is a request that failed without a response. sent tells
+// whether the request was written to the connection, so GitHub may have
+// acted on it.
+type requestError struct {
+	err  error
+	sent bool
+}
+
+func (e *requestError) Error() string { return "request failed: " + e.err.Error() }
+func (e *requestError) Unwrap() error { return e.err }
+
+// idempotent reports whether repeating a request of method cannot do more
+// than sending it once.
+func idempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodDelete
+}
+
+// retryDelay decides whether a failed request of method is retried and how
+// long to wait first.
+func (c *Client) retryDelay(method string, err error, attempt int) (time.Duration, bool) {
+	if attempt >= c.maxRetries {
+		return 0, false
+	}
+	if reqErr, ok := err.(*requestError); ok {
+		return c.backoff(attempt), !reqErr.sent || idempotent(method)
+	}
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		return 0, false
+	}
+
+	switch {
+	case apiErr.StatusCode >= 500:
+		// A write may have been applied before the server failed
+		return c.backoff(attempt), idempotent(method)
+	case apiErr.StatusCode == http.StatusForbidden || apiErr.StatusCode == http.StatusTooManyRequests:
+		if apiErr.RetryAfter > 0 {
+			return apiErr.RetryAfter, true
+		}
+		if strings.Contains(strings.ToLower(apiErr.Message), "secondary rate limit") {
+			return c.backoff(attempt), true
+		}
+		// Primary rate limit exhausted: waitForRateLimit blocks until reset.
+		if rl := c.GetRateLimit(); c.waitForReset && rl != nil && rl.Remaining == 0 {
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+// backoff returns an exponential delay with full jitter in [d/2, d).
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.retryBase << attempt
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
 // GetRateLimit returns the current rate limit status.
 func (c *Client) GetRateLimit() *RateLimit {
 	c.rateMu.Lock()
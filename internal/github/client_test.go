@@ -172,6 +172,34 @@ func TestClient_CreatePullRequest(t *testing.T) {
 	}
 }
 
+func TestClient_MarkPullRequestReady(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		if len(paths) == 1 {
+			w.Write([]byte(`{"data":{"markPullRequestReadyForReview":{"pullRequest":{"isDraft":false}}}}`))
+			return
+		}
+		w.Write([]byte(`{"data":null,"errors":[{"message":"Could not resolve to a node with the global id of 'PR_x'"}]}`))
+	}))
+	defer server.Close()
+
+	// GitHub Enterprise serves GraphQL at /api/graphql beside /api/v3
+	client := NewClient("test-token", WithBaseURL(server.URL+"/api/v3"))
+	if err := client.MarkPullRequestReady(context.Background(), "PR_12"); err != nil {
+		t.Fatalf("MarkPullRequestReady() error = %v", err)
+	}
+	if err := client.MarkPullRequestReady(context.Background(), "PR_x"); err == nil {
+		t.Error("MarkPullRequestReady() with GraphQL errors succeeded, want an error")
+	}
+	if len(paths) != 2 || paths[0] != "/api/graphql" {
+		t.Errorf("paths = %q, want /api/graphql", paths)
+	}
+}
+
 func TestClient_APIError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
@@ -244,3 +272,191 @@ func TestClient_RateLimitError(t *testing.T) {
 		t.Errorf("callCount = %d, want 1", callCount)
 	}
 }
+
+func TestClient_IsTeamMember(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/orgs/acme/teams/devs/memberships/alice":
+			json.NewEncoder(w).Encode(map[string]string{"state": "active"})
+		case "/orgs/acme/teams/devs/memberships/carol":
+			json.NewEncoder(w).Encode(map[string]string{"state": "pending"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token", WithBaseURL(server.URL))
+
+	tests := []struct {
+		user string
+		want bool
+	}{
+		{"alice", true},
+		{"carol", false},
+		{"bob", false},
+	}
+	for _, tt := range tests {
+		got, err := client.IsTeamMember(context.Background(), "acme", "devs", tt.user)
+		if err != nil {
+			t.Fatalf("IsTeamMember(%s) error = %v", tt.user, err)
+		}
+		if got != tt.want {
+			t.Errorf("IsTeamMember(%s) = %v, want %v", tt.user, got, tt.want)
+		}
+	}
+}
+
+func TestClient_RetryServerError(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode(User{Login: "testuser"})
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token", WithBaseURL(server.URL), WithRetries(3))
+	client.retryBase = time.Millisecond
+
+	user, err := client.TestAuth(context.Background())
+	if err != nil {
+		t.Fatalf("TestAuth() error = %v", err)
+	}
+	if user.Login != "testuser" {
+		t.Errorf("Login = %q, want %q", user.Login, "testuser")
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestClient_RetryGivesUp(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token", WithBaseURL(server.URL), WithRetries(2))
+	client.retryBase = time.Millisecond
+
+	if _, err := client.GetIssue(context.Background(), "owner", "repo", 1); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3 (1 + 2 retries)", calls)
+	}
+}
+
+func TestClient_RetryWrites(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token", WithBaseURL(server.URL), WithRetries(3))
+	client.retryBase = time.Millisecond
+
+	// The comment may have been created before the server failed
+	if _, err := client.AddIssueComment(context.Background(), "owner", "repo", 1, "hi"); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d after a 502, want 1", calls)
+	}
+
+	// A request that never left is safe to send again
+	var dials int
+	client.httpClient.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if dials++; dials == 1 {
+			return nil, errors.New("connection refused")
+		}
+		return http.DefaultTransport.RoundTrip(r)
+	})
+	calls = 0
+	client.AddIssueComment(context.Background(), "owner", "repo", 1, "hi")
+	if dials != 2 || calls != 1 {
+		t.Errorf("dials = %d, calls = %d after a connection error, want 2 and 1", dials, calls)
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestClient_NoRetryOnClientError(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token", WithBaseURL(server.URL), WithRetries(3))
+	client.retryBase = time.Millisecond
+
+	if _, err := client.GetIssue(context.Background(), "owner", "repo", 1); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestClient_RetrySecondaryRateLimit(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{
+				"message": "You have exceeded a secondary rate limit.",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(Issue{Number: 1})
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token", WithBaseURL(server.URL), WithRetries(1))
+	client.retryBase = time.Millisecond
+
+	if _, err := client.GetIssue(context.Background(), "owner", "repo", 1); err != nil {
+		t.Fatalf("GetIssue() error = %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+}
+
+func TestClient_RateLimitWait(t *testing.T) {
+	reset := time.Now().Add(time.Hour)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "5")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", reset.Unix()))
+		json.NewEncoder(w).Encode(User{Login: "testuser"})
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token", WithBaseURL(server.URL), WithRateLimitWait(true))
+	if _, err := client.TestAuth(context.Background()); err != nil {
+		t.Fatalf("first TestAuth() error = %v", err)
+	}
+
+	// The buffer is exhausted; waiting respects the context deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := client.TestAuth(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("TestAuth() error = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,226 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCommentLength is GitHub's limit for a comment body.
+const maxCommentLength = 65536
+
+// coalesceSeparator joins updates merged into a single comment.
+const coalesceSeparator = "\n\n---\n\n"
+
+// partOverhead is room kept in each part of a split comment for the links
+// between the parts.
+const partOverhead = 512
+
+// Commenter posts comments with a per-repository rate limit. Status updates
+// posted on the same issue within the coalesce window are appended to the
+// previous status comment with the same MANFRED marker by editing it, which
+// does not notify subscribers again; other comments are always posted anew.
+// Bodies over GitHub's comment size limit are split into linked comments.
+type Commenter struct {
+	client   *Client
+	interval time.Duration
+	window   time.Duration
+
+	uploader  Uploader
+	threshold int
+
+	mu     sync.Mutex
+	repos  map[string]*repoLimiter
+	recent map[string]*recentComment
+}
+
+// repoLimiter serializes comments on one repository.
+type repoLimiter struct {
+	mu   sync.Mutex
+	next time.Time
+}
+
+// recentComment is the last status comment posted on an issue.
+type recentComment struct {
+	id       int64
+	marker   string
+	body     string
+	postedAt time.Time
+}
+
+// NewCommenter creates a Commenter. interval is the minimum time between new
+// comments on the same repository; window is how long after posting a
+// status comment further status updates with its marker are merged into it.
+// Zero disables the respective behavior.
+func NewCommenter(client *Client, interval, window time.Duration) *Commenter {
+	return &Commenter{
+		client:   client,
+		interval: interval,
+		window:   window,
+		repos:    make(map[string]*repoLimiter),
+		recent:   make(map[string]*recentComment),
+	}
+}
+
+// Uploader stores large outputs and returns a link to them; see package
+// upload.
+type Uploader interface {
+	UploadLink(ctx context.Context, name, description string, data []byte) (string, error)
+}
+
+// SetUploader makes PostStatus truncate status comments over threshold
+// bytes with a link to the full text uploaded to u, instead of inlining or
+// splitting them.
+func (c *Commenter) SetUploader(u Uploader, threshold int) {
+	c.uploader = u
+	c.threshold = threshold
+}
+
+// PostStatus posts a status update like Post, merging it into the issue's
+// last comment if that is a status comment with the same MANFRED marker
+// posted within the coalesce window. With an uploader, a body over its
+// threshold is uploaded and the comment is truncated with a link to the full
+// text; if the upload fails, the body is posted whole, split if needed.
+func (c *Commenter) PostStatus(ctx context.Context, owner, repo string, number int, body string) (*Comment, error) {
+	if c.uploader != nil && len(body) > c.threshold {
+		description := fmt.Sprintf("MANFRED comment on %s/%s#%d", owner, repo, number)
+		if link, err := c.uploader.UploadLink(ctx, "comment.md", description, []byte(body)); err == nil && link != "" {
+			body = TruncateComment(body, c.threshold, link)
+		}
+	}
+	return c.post(ctx, owner, repo, number, body, true)
+}
+
+// Post adds a comment to an issue or PR. A body over the size limit is
+// posted as several comments, each linking to the previous one; the first
+// is returned, also along with an error if a later part failed.
+func (c *Commenter) Post(ctx context.Context, owner, repo string, number int, body string) (*Comment, error) {
+	return c.post(ctx, owner, repo, number, body, false)
+}
+
+// post posts body, merging a status update into a recent status comment
+// with the same marker when possible.
+func (c *Commenter) post(ctx context.Context, owner, repo string, number int, body string, status bool) (*Comment, error) {
+	limiter := c.limiter(owner, repo)
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	key := fmt.Sprintf("%s/%s#%d", owner, repo, number)
+	marker := commentMarker(body)
+
+	c.mu.Lock()
+	prev := c.recent[key]
+	c.mu.Unlock()
+
+	if status && marker != "" && prev != nil && prev.marker == marker && time.Since(prev.postedAt) < c.window {
+		merged := prev.body + coalesceSeparator + body
+		if len(merged) <= maxCommentLength {
+			comment, err := c.client.UpdateIssueComment(ctx, owner, repo, prev.id, merged)
+			if err == nil {
+				c.mu.Lock()
+				prev.body = merged
+				c.mu.Unlock()
+				return comment, nil
+			}
+			// The comment may have been deleted; fall back to a new one.
+		}
+	}
+
+	if wait := time.Until(limiter.next); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	comments, bodies, err := c.postParts(ctx, owner, repo, number, body)
+	if len(comments) == 0 {
+		return nil, err
+	}
+	limiter.next = time.Now().Add(c.interval)
+
+	// Later status updates are merged into the last part; any other comment
+	// ends the run, so an update never edits a comment above it
+	last := len(comments) - 1
+	c.mu.Lock()
+	for k, r := range c.recent {
+		if time.Since(r.postedAt) >= c.window {
+			delete(c.recent, k)
+		}
+	}
+	if status && marker != "" {
+		c.recent[key] = &recentComment{id: comments[last].ID, marker: marker, body: bodies[last], postedAt: time.Now()}
+	} else {
+		delete(c.recent, key)
+	}
+	c.mu.Unlock()
+
+	return comments[0], err
+}
+
+// postParts posts body as one comment, or split into linked parts if it is
+// over the size limit. Parts after the first repeat the MANFRED marker of
+// the body, so they are recognized as MANFRED comments too. It returns the
+// comments and their bodies; a failure after the first part is returned
+// after the parts posted so far.
+func (c *Commenter) postParts(ctx context.Context, owner, repo string, number int, body string) ([]*Comment, []string, error) {
+	if len(body) <= maxCommentLength {
+		comment, err := c.client.AddIssueComment(ctx, owner, repo, number, body)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []*Comment{comment}, []string{body}, nil
+	}
+
+	marker := commentMarker(body)
+	chunks := SplitComment(body, maxCommentLength-len(marker)-partOverhead)
+	var comments []*Comment
+	var bodies []string
+	for i, chunk := range chunks {
+		text := chunk
+		if i > 0 {
+			prev := comments[i-1].HTMLURL
+			text = fmt.Sprintf("%s\n\n<sub>Continued from [part %d of %d](%s).</sub>\n\n%s", marker, i, len(chunks), prev, chunk)
+		}
+		if i < len(chunks)-1 {
+			text += fmt.Sprintf("\n\n<sub>Continued in the next comment (part %d of %d).</sub>", i+1, len(chunks))
+		}
+
+		comment, err := c.client.AddIssueComment(ctx, owner, repo, number, text)
+		if err != nil {
+			if i == 0 {
+				return nil, nil, err
+			}
+			return comments, bodies, fmt.Errorf("failed to post part %d of %d: %w", i+1, len(chunks), err)
+		}
+		comments = append(comments, comment)
+		bodies = append(bodies, text)
+	}
+	return comments, bodies, nil
+}
+
+// commentMarker returns the MANFRED marker that starts body, or "".
+func commentMarker(body string) string {
+	if loc := manfredMetaPattern.FindStringIndex(body); loc != nil && strings.TrimSpace(body[:loc[0]]) == "" {
+		return body[loc[0]:loc[1]]
+	}
+	return ""
+}
+
+func (c *Commenter) limiter(owner, repo string) *repoLimiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := owner + "/" + repo
+	l, ok := c.repos[key]
+	if !ok {
+		l = &repoLimiter{}
+		c.repos[key] = l
+	}
+	return l
+}
@@ -0,0 +1,261 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// commentServer records comment creations and edits.
+type commentServer struct {
+	mu     sync.Mutex
+	posts  []time.Time
+	bodies []string
+	edits  []string
+	nextID int64
+	*httptest.Server
+}
+
+func newCommentServer(t *testing.T) *commentServer {
+	s := &commentServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input map[string]string
+		json.NewDecoder(r.Body).Decode(&input)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch r.Method {
+		case http.MethodPost:
+			s.nextID++
+			s.posts = append(s.posts, time.Now())
+			s.bodies = append(s.bodies, input["body"])
+			json.NewEncoder(w).Encode(Comment{ID: s.nextID, Body: input["body"], HTMLURL: fmt.Sprintf("https://github.example/c/%d", s.nextID)})
+		case http.MethodPatch:
+			s.edits = append(s.edits, input["body"])
+			json.NewEncoder(w).Encode(Comment{ID: s.nextID, Body: input["body"]})
+		default:
+			t.Errorf("unexpected method: %s", r.Method)
+		}
+	}))
+	return s
+}
+
+func TestCommenter_Coalesce(t *testing.T) {
+	server := newCommentServer(t)
+	defer server.Close()
+
+	c := NewCommenter(NewClient("token", WithBaseURL(server.URL)), 0, time.Minute)
+	ctx := context.Background()
+
+	first := FormatRevisionComment("sess-1", "first")
+	second := FormatCIFixComment("sess-1", "second")
+	for _, body := range []string{first, second} {
+		if _, err := c.PostStatus(ctx, "owner", "repo", 1, body); err != nil {
+			t.Fatalf("PostStatus() error = %v", err)
+		}
+	}
+	if len(server.posts) != 1 || len(server.edits) != 1 {
+		t.Fatalf("posts = %d, edits = %d, want 1 and 1", len(server.posts), len(server.edits))
+	}
+	if want := first + coalesceSeparator + second; server.edits[0] != want {
+		t.Errorf("edit body = %q, want %q", server.edits[0], want)
+	}
+
+	// Other issues, other markers and regular comments are posted anew, and
+	// a regular comment ends the run of status updates
+	for _, post := range []struct {
+		number int
+		body   string
+		status bool
+	}{
+		{2, FormatRevisionComment("sess-2", "other issue"), true},
+		{1, FormatErrorComment("sess-1", "revising", "boom"), true},
+		{1, FormatRevisionComment("sess-1", "third"), true},
+		{1, FormatPlanComment("sess-1", "The plan", ""), false},
+		{1, FormatRevisionComment("sess-1", "fourth"), true},
+	} {
+		send := c.Post
+		if post.status {
+			send = c.PostStatus
+		}
+		if _, err := send(ctx, "owner", "repo", post.number, post.body); err != nil {
+			t.Fatalf("post error = %v", err)
+		}
+	}
+	if len(server.posts) != 6 || len(server.edits) != 1 {
+		t.Errorf("posts = %d, edits = %d, want 6 and 1", len(server.posts), len(server.edits))
+	}
+}
+
+func TestCommenter_NoCoalesceWithoutWindow(t *testing.T) {
+	server := newCommentServer(t)
+	defer server.Close()
+
+	c := NewCommenter(NewClient("token", WithBaseURL(server.URL)), 0, 0)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Post(ctx, "owner", "repo", 1, "update"); err != nil {
+			t.Fatalf("Post() error = %v", err)
+		}
+	}
+
+	if len(server.posts) != 2 || len(server.edits) != 0 {
+		t.Errorf("posts = %d, edits = %d, want 2 and 0", len(server.posts), len(server.edits))
+	}
+}
+
+func TestCommenter_RateLimit(t *testing.T) {
+	server := newCommentServer(t)
+	defer server.Close()
+
+	interval := 50 * time.Millisecond
+	c := NewCommenter(NewClient("token", WithBaseURL(server.URL)), interval, 0)
+	ctx := context.Background()
+
+	if _, err := c.Post(ctx, "owner", "repo", 1, "a"); err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if _, err := c.Post(ctx, "owner", "repo", 2, "b"); err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+
+	if len(server.posts) != 2 {
+		t.Fatalf("posts = %d, want 2", len(server.posts))
+	}
+	if gap := server.posts[1].Sub(server.posts[0]); gap < interval {
+		t.Errorf("gap between comments = %v, want >= %v", gap, interval)
+	}
+
+	// The limit is per repository.
+	start := time.Now()
+	if _, err := c.Post(ctx, "owner", "other", 1, "c"); err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= interval {
+		t.Errorf("post on another repo waited %v", elapsed)
+	}
+}
+
+func TestCommenter_ContextCanceled(t *testing.T) {
+	server := newCommentServer(t)
+	defer server.Close()
+
+	c := NewCommenter(NewClient("token", WithBaseURL(server.URL)), time.Hour, 0)
+
+	if _, err := c.Post(context.Background(), "owner", "repo", 1, "a"); err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.Post(ctx, "owner", "repo", 2, "b"); err == nil {
+		t.Error("Post() with canceled context = nil error, want error")
+	}
+}
+
+func TestCommenter_SplitsLongBody(t *testing.T) {
+	server := newCommentServer(t)
+	defer server.Close()
+
+	c := NewCommenter(NewClient("token", WithBaseURL(server.URL)), 0, time.Minute)
+	ctx := context.Background()
+
+	body := FormatPlanComment("sess-1", strings.Repeat("A step of the plan.\n", 8000), "")
+	comment, err := c.Post(ctx, "owner", "repo", 1, body)
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if comment.ID != 1 {
+		t.Errorf("returned comment %d, want the first part", comment.ID)
+	}
+
+	if len(server.bodies) != 3 {
+		t.Fatalf("posts = %d, want 3", len(server.bodies))
+	}
+	for i, part := range server.bodies {
+		if len(part) > maxCommentLength {
+			t.Errorf("part %d has %d bytes", i+1, len(part))
+		}
+		if meta := ParseManfredComment(part); meta == nil || meta.SessionID != "sess-1" {
+			t.Errorf("part %d is not marked as a MANFRED comment", i+1)
+		}
+	}
+	if !strings.Contains(server.bodies[1], "Continued from [part 1 of 3](https://github.example/c/1)") {
+		t.Errorf("part 2 does not link part 1:\n%s", server.bodies[1][:200])
+	}
+	if !strings.Contains(server.bodies[0], "part 1 of 3") {
+		t.Error("part 1 does not announce the continuation")
+	}
+
+	// Status updates are merged into the last part
+	body = FormatRevisionComment("sess-1", strings.Repeat("A change.\n", 8000))
+	if _, err := c.PostStatus(ctx, "owner", "repo", 1, body); err != nil {
+		t.Fatalf("PostStatus() error = %v", err)
+	}
+	update := FormatRevisionComment("sess-1", "update")
+	if _, err := c.PostStatus(ctx, "owner", "repo", 1, update); err != nil {
+		t.Fatalf("PostStatus() error = %v", err)
+	}
+	if len(server.edits) != 1 || !strings.HasSuffix(server.edits[0], coalesceSeparator+update) {
+		t.Errorf("edits = %d, want the update merged into the last part", len(server.edits))
+	}
+}
+
+// fakeUploader records uploads.
+type fakeUploader struct {
+	uploads []string
+}
+
+func (u *fakeUploader) UploadLink(ctx context.Context, name, description string, data []byte) (string, error) {
+	u.uploads = append(u.uploads, string(data))
+	return "https://uploads.example/" + name, nil
+}
+
+func TestCommenter_PostStatusUploads(t *testing.T) {
+	server := newCommentServer(t)
+	defer server.Close()
+
+	body := FormatErrorComment("sess-1", "implementing", strings.Repeat("error output\n", 10000))
+
+	c := NewCommenter(NewClient("token", WithBaseURL(server.URL)), 0, 0)
+	if _, err := c.PostStatus(context.Background(), "owner", "repo", 1, body); err != nil {
+		t.Fatalf("PostStatus() error = %v", err)
+	}
+	if len(server.bodies) != 3 {
+		t.Errorf("without uploader: posts = %d, want 3", len(server.bodies))
+	}
+
+	server.bodies = nil
+	uploader := &fakeUploader{}
+	c.SetUploader(uploader, 20000)
+	if _, err := c.PostStatus(context.Background(), "owner", "repo", 1, body); err != nil {
+		t.Fatalf("PostStatus() error = %v", err)
+	}
+	if len(uploader.uploads) != 1 || uploader.uploads[0] != body {
+		t.Fatalf("uploads = %d, want the full body uploaded once", len(uploader.uploads))
+	}
+	if len(server.bodies) != 1 || len(server.bodies[0]) > 20000 {
+		t.Fatalf("with uploader: posts = %d, want 1 comment within the threshold", len(server.bodies))
+	}
+	if !strings.Contains(server.bodies[0], "[full text](https://uploads.example/comment.md)") {
+		t.Error("truncated comment does not link the upload")
+	}
+	if !IsManfredComment(server.bodies[0]) {
+		t.Error("truncated comment lost its MANFRED marker")
+	}
+
+	// Short status comments are not uploaded
+	if _, err := c.PostStatus(context.Background(), "owner", "repo", 1, "short"); err != nil {
+		t.Fatalf("PostStatus() error = %v", err)
+	}
+	if len(uploader.uploads) != 1 {
+		t.Errorf("uploads = %d, want short comment inlined", len(uploader.uploads))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 )
 
 // ManfredMeta contains metadata extracted from a Manfred comment.
@@ -16,6 +17,19 @@ var (
 	// Pattern to match Manfred comment metadata
 	manfredMetaPattern = regexp.MustCompile(`<!-- manfred:session:([^:]+):phase:([^ ]+) -->`)
 
+	// Pattern to match requests to resume paused automation
+	resumePattern = regexp.MustCompile(`(?i)(@claude\s+resume\b|(^|\s)/resume\b)`)
+
+	// Pattern to match requests to abort a session
+	abortPattern = regexp.MustCompile(`(?i)(@(manfred|claude)\s+abort\b|(^|\s)/abort\b)`)
+
+	// Pattern to match requests to revise a plan; the feedback follows the
+	// command
+	revisePlanPattern = regexp.MustCompile(`(?is)(?:@(?:manfred|claude)\s+|(?:^|\s)/)revise-plan\b:?(.*)`)
+
+	// Pattern to match plan edits; the full edited plan follows the command
+	editPlanPattern = regexp.MustCompile(`(?is)(?:@(?:manfred|claude)\s+|(?:^|\s)/)edit-plan\b:?(.*)`)
+
 	// Patterns for approval keywords
 	defaultApprovalPatterns = []string{
 		`@claude\s+approved?`,
@@ -33,19 +47,121 @@ func FormatComment(sessionID, phase, content string) string {
 
 ---
 
-<sub>Reply with `+"`@claude approved`"+` to start implementation, or provide feedback.</sub>`,
+<sub>Reply with `+"`@claude approved`"+` to start implementation, or with `+"`@manfred revise-plan: <feedback>`"+` to get a revised plan.</sub>`,
 		sessionID, phase, content)
 }
 
-// FormatPlanComment creates a comment for posting an implementation plan.
-func FormatPlanComment(sessionID, plan string) string {
+// FormatPlanComment creates a comment for posting an implementation plan,
+// followed by checklist as rendered by FormatApprovalChecklist.
+func FormatPlanComment(sessionID, plan, checklist string) string {
 	return FormatComment(sessionID, "planning", fmt.Sprintf(`## Implementation Plan
 
-%s`, plan))
+%s
+
+%s`, plan, checklist))
+}
+
+// FormatRevisedPlanComment creates a comment for posting a plan revised
+// after feedback; revision counts the revisions so far.
+func FormatRevisedPlanComment(sessionID, plan, checklist string, revision int) string {
+	return FormatComment(sessionID, "planning", fmt.Sprintf(`## Implementation Plan (revision %d)
+
+%s
+
+%s`, revision, plan, checklist))
 }
 
-// FormatErrorComment creates a comment for posting an error.
-func FormatErrorComment(sessionID, phase, errorMsg string) string {
+// FormatEditedPlanComment creates a comment for posting a plan edited by
+// editor, a GitHub login, or by an operator when editor is empty.
+func FormatEditedPlanComment(sessionID, plan, checklist, editor string) string {
+	heading := "## Implementation Plan (edited)"
+	if editor != "" {
+		heading = fmt.Sprintf("## Implementation Plan (edited by @%s)", editor)
+	}
+	return FormatComment(sessionID, "planning", fmt.Sprintf(`%s
+
+%s
+
+%s`, heading, plan, checklist))
+}
+
+// FormatQuestionsComment creates a comment asking the clarifying questions
+// Claude has before it plans; round counts the rounds so far, of at most
+// maxRounds.
+func FormatQuestionsComment(sessionID, questions string, round, maxRounds int) string {
+	return FormatComment(sessionID, "awaiting_answers", fmt.Sprintf(`## Clarifying Questions (round %d of %d)
+
+Before planning, Claude would like to know:
+
+%s
+
+---
+
+Reply with a comment to answer; planning continues with your answers.`, round, maxRounds, questions))
+}
+
+// FormatApprovalChecklist renders the approval checklist shown under a plan:
+// Claude's assessment of risk, size, files likely touched and whether a
+// migration is required, then boxes for the approver to tick. Fields Claude
+// did not assess read "not assessed".
+func FormatApprovalChecklist(risk, size string, files []string, migration *bool) string {
+	notAssessed := "_not assessed_"
+	or := func(v string) string {
+		if v == "" {
+			return notAssessed
+		}
+		return v
+	}
+
+	touched := notAssessed
+	if len(files) > 0 {
+		quoted := make([]string, len(files))
+		for i, f := range files {
+			quoted[i] = "`" + f + "`"
+		}
+		touched = strings.Join(quoted, ", ")
+	}
+
+	requiresMigration := notAssessed
+	if migration != nil {
+		requiresMigration = "no"
+		if *migration {
+			requiresMigration = "**yes**"
+		}
+	}
+
+	return fmt.Sprintf(`### Approval checklist
+
+| | |
+|---|---|
+| Risk level | %s |
+| Estimated size | %s |
+| Files likely touched | %s |
+| Requires migration? | %s |
+
+- [ ] Scope matches the issue
+- [ ] Risk and size are acceptable
+- [ ] Files touched are expected
+- [ ] Migration, if any, is planned for`, or(risk), or(size), touched, requiresMigration)
+}
+
+// OutputLink links an uploaded output, like a job log, from a comment.
+type OutputLink struct {
+	Name string
+	URL  string
+}
+
+// FormatErrorComment creates a comment for posting an error, with links to
+// uploaded outputs such as the job log.
+func FormatErrorComment(sessionID, phase, errorMsg string, links ...OutputLink) string {
+	var outputs string
+	if len(links) > 0 {
+		names := make([]string, len(links))
+		for i, l := range links {
+			names[i] = fmt.Sprintf("[%s](%s)", l.Name, l.URL)
+		}
+		outputs = "\n\nFull output: " + strings.Join(names, ", ")
+	}
 	return fmt.Sprintf(`<!-- manfred:session:%s:phase:%s:error -->
 
 ## Error
@@ -54,24 +170,297 @@ An error occurred during the **%s** phase:
 
 `+"```"+`
 %s
-`+"```"+`
+`+"```"+`%s
 
 <sub>You can retry by commenting `+"`@claude retry`"+`.</sub>`,
-		sessionID, phase, phase, errorMsg)
+		sessionID, phase, phase, errorMsg, outputs)
 }
 
-// FormatPRDescription creates a PR body with session metadata.
-func FormatPRDescription(sessionID string, issueNumber int, summary string) string {
-	return fmt.Sprintf(`<!-- manfred:session:%s:pr -->
+// FormatConflictComment creates a comment reporting that a job's branch
+// could not be rebased onto its moved base branch, and which files conflict.
+func FormatConflictComment(sessionID, phase, branch, base string, files []string) string {
+	list := make([]string, len(files))
+	for i, f := range files {
+		list[i] = "- `" + f + "`"
+	}
+	return fmt.Sprintf(`<!-- manfred:session:%s:phase:%s:error -->
+
+## Merge conflict
+
+`+"`%s`"+` moved on while the **%s** phase ran, and rebasing `+"`%s`"+` onto it conflicts in:
+
+%s
+
+Nothing was pushed.
+
+<sub>You can retry on the new base by commenting `+"`@claude retry`"+`.</sub>`,
+		sessionID, phase, base, phase, branch, strings.Join(list, "\n"))
+}
+
+// FormatPRCreatedComment creates an issue comment linking the session's PR.
+func FormatPRCreatedComment(sessionID string, prNumber int) string {
+	return fmt.Sprintf(`<!-- manfred:session:%s:phase:implementing -->
+
+## Implementation ready for review
+
+The approved plan has been implemented in #%d.
+
+---
+
+<sub>Leave a review on the pull request to request changes.</sub>`,
+		sessionID, prNumber)
+}
+
+// FormatTriageComment creates an issue comment with Claude's triage of the
+// issue: a summary of the suggested labels, affected modules, estimate and
+// possible duplicates, followed by Claude's analysis.
+func FormatTriageComment(sessionID, analysis string, labels, modules []string, estimate string, duplicates []int) string {
+	var rows []string
+	code := func(values []string) string {
+		quoted := make([]string, len(values))
+		for i, v := range values {
+			quoted[i] = "`" + v + "`"
+		}
+		return strings.Join(quoted, ", ")
+	}
+	if len(labels) > 0 {
+		rows = append(rows, "| Suggested labels | "+code(labels)+" |")
+	}
+	if len(modules) > 0 {
+		rows = append(rows, "| Affected modules | "+code(modules)+" |")
+	}
+	if estimate != "" {
+		rows = append(rows, "| Estimate | "+estimate+" |")
+	}
+	if len(duplicates) > 0 {
+		refs := make([]string, len(duplicates))
+		for i, n := range duplicates {
+			refs[i] = fmt.Sprintf("#%d", n)
+		}
+		rows = append(rows, "| Possible duplicates | "+strings.Join(refs, ", ")+" |")
+	}
+	var summary string
+	if len(rows) > 0 {
+		summary = "| | |\n|---|---|\n" + strings.Join(rows, "\n") + "\n\n"
+	}
+
+	return fmt.Sprintf(`<!-- manfred:session:%s:phase:triaged -->
+
+## Triage
+
+%s%s
+
+---
+
+<sub>No code was written. Comment `+"`@claude plan`"+` to have Claude plan and implement this issue.</sub>`,
+		sessionID, summary, analysis)
+}
+
+// FormatRevisionComment creates a PR comment summarizing a revision round.
+func FormatRevisionComment(sessionID, summary string) string {
+	return fmt.Sprintf(`<!-- manfred:session:%s:phase:revising -->
+
+## Review feedback addressed
+
+%s
+
+---
+
+<sub>Leave another review to request further changes.</sub>`,
+		sessionID, summary)
+}
+
+// FormatCIFixComment creates a PR comment summarizing a revision round that
+// fixed a CI failure.
+func FormatCIFixComment(sessionID, summary string) string {
+	return fmt.Sprintf(`<!-- manfred:session:%s:phase:revising -->
+
+## CI failure addressed
 
 %s
 
 ---
 
-Closes #%d
+<sub>CI runs again on the new commits. Leave a review to request further changes.</sub>`,
+		sessionID, summary)
+}
+
+// FormatCIGaveUpComment creates a PR comment noting that CI still fails
+// after attempts fix attempts and MANFRED stopped trying.
+func FormatCIGaveUpComment(sessionID string, attempts int) string {
+	s := "s"
+	if attempts == 1 {
+		s = ""
+	}
+	return fmt.Sprintf(`<!-- manfred:session:%s:phase:in_review -->
+
+## CI is still failing
+
+MANFRED made %d attempt%s to fix the failing checks and is leaving the rest to you.
+
+---
+
+<sub>Push a fix, or leave a review with instructions for Claude.</sub>`,
+		sessionID, attempts, s)
+}
+
+// FormatCompletedComment creates an issue comment announcing that a session's
+// PR was merged.
+func FormatCompletedComment(sessionID string, prNumber int, prTitle string) string {
+	return fmt.Sprintf(`<!-- manfred:session:%s:phase:completed -->
+
+## Completed
 
-<sub>Generated by [MANFRED](https://github.com/mpm/manfred)</sub>`,
-		sessionID, summary, issueNumber)
+Pull request #%d (%s) has been merged. Closing this issue.`,
+		sessionID, prNumber, prTitle)
+}
+
+// FormatAutoMergedComment creates an issue comment announcing that MANFRED
+// merged the session's approved PR with method, closing the issue if
+// closing is set.
+func FormatAutoMergedComment(sessionID string, prNumber int, prTitle, method string, closing bool) string {
+	closes := ""
+	if closing {
+		closes = " Closing this issue."
+	}
+	return fmt.Sprintf(`<!-- manfred:session:%s:phase:completed -->
+
+## Completed
+
+Pull request #%d (%s) was approved and passed its checks, so MANFRED merged it (%s).%s`,
+		sessionID, prNumber, prTitle, method, closes)
+}
+
+// FormatUnauthorizedComment creates a comment telling a user they may not
+// perform an action.
+func FormatUnauthorizedComment(sessionID, phase, user, action string) string {
+	return fmt.Sprintf(`<!-- manfred:session:%s:phase:%s -->
+
+@%s you are not authorized to %s MANFRED sessions in this repository.`,
+		sessionID, phase, user, action)
+}
+
+// FormatAbortedComment creates the final comment of an aborted session,
+// listing the cleanup steps taken.
+func FormatAbortedComment(sessionID, user string, steps []string) string {
+	by := ""
+	if user != "" {
+		by = " by " + mention(user)
+	}
+	var list strings.Builder
+	for _, step := range steps {
+		list.WriteString("- " + step + "\n")
+	}
+	return fmt.Sprintf(`<!-- manfred:session:%s:phase:aborted -->
+
+## Aborted
+
+This session was aborted%s. MANFRED will not make further changes.
+
+%s`, sessionID, by, list.String())
+}
+
+// FormatApprovalProgressComment acknowledges the approval of user while the
+// plan needs more: approvals of needed distinct approvers so far.
+func FormatApprovalProgressComment(sessionID, user string, approvals, needed int) string {
+	return fmt.Sprintf(`<!-- manfred:session:%s:phase:awaiting_approval -->
+
+Approved by %s (%d of %d approvals). Implementation starts once %d different people have approved the plan.`,
+		sessionID, mention(user), approvals, needed, needed)
+}
+
+// apiSenderPrefix marks senders that are REST API keys. GitHub logins cannot
+// contain a colon.
+const apiSenderPrefix = "api-key:"
+
+// APISender returns the sender of session actions taken through the REST
+// API with the key named name.
+func APISender(name string) string {
+	return apiSenderPrefix + name
+}
+
+// IsAPISender reports whether sender is an API key rather than a GitHub
+// user.
+func IsAPISender(sender string) bool {
+	return strings.HasPrefix(sender, apiSenderPrefix)
+}
+
+// mention refers to sender in a comment: an @-mention for GitHub users, the
+// key name for API keys.
+func mention(sender string) string {
+	if name, ok := strings.CutPrefix(sender, apiSenderPrefix); ok {
+		return "API key `" + name + "`"
+	}
+	return "@" + sender
+}
+
+// FormatAutoApprovedComment notes that a plan was approved without waiting
+// because the issue carries label, an auto-approve label of the project.
+func FormatAutoApprovedComment(sessionID, label string) string {
+	return fmt.Sprintf(`<!-- manfred:session:%s:phase:implementing -->
+
+The issue is labeled `+"`%s`"+`, so this plan is approved automatically. Implementation has started.`,
+		sessionID, label)
+}
+
+// FormatApprovalReminderComment reminds that the plan has waited for
+// approval for waited; expiresIn is how long until the session is aborted,
+// or 0 when it does not expire.
+func FormatApprovalReminderComment(sessionID string, waited, expiresIn time.Duration) string {
+	expiry := ""
+	if expiresIn > 0 {
+		expiry = fmt.Sprintf(" Without approval, the session is aborted in %s.", formatWait(expiresIn))
+	}
+	return FormatComment(sessionID, "awaiting_approval", fmt.Sprintf(`## Waiting for approval
+
+The plan above has been waiting for approval for %s.%s`, formatWait(waited), expiry))
+}
+
+// FormatApprovalExpiredComment creates the final comment of a session
+// aborted because its plan was not approved within after, listing the
+// cleanup steps taken.
+func FormatApprovalExpiredComment(sessionID string, after time.Duration, steps []string) string {
+	var list strings.Builder
+	for _, step := range steps {
+		list.WriteString("- " + step + "\n")
+	}
+	return fmt.Sprintf(`<!-- manfred:session:%s:phase:aborted -->
+
+## Approval expired
+
+The plan was not approved within %s, so this session was aborted. MANFRED will not make further changes.
+
+%s`, sessionID, formatWait(after), list.String())
+}
+
+// formatWait renders a wait in whole days, hours or minutes, e.g. "3 days".
+func formatWait(d time.Duration) string {
+	unit, n := "minute", int(d.Round(time.Minute)/time.Minute)
+	switch {
+	case d >= 48*time.Hour:
+		unit, n = "day", int(d.Round(24*time.Hour)/(24*time.Hour))
+	case d >= 2*time.Hour:
+		unit, n = "hour", int(d.Round(time.Hour)/time.Hour)
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", n, unit)
+}
+
+// FormatManualPushComment creates a comment noting that someone else pushed
+// to the session branch and automation is paused.
+func FormatManualPushComment(sessionID, phase, branch, user, sha string) string {
+	return fmt.Sprintf(`<!-- manfred:session:%s:phase:%s -->
+
+## Manual changes detected
+
+@%s pushed %s to `+"`%s`"+`. To avoid overwriting manual work, MANFRED has paused automated changes to this branch.
+
+---
+
+<sub>Reply with `+"`@claude resume`"+` to continue with your commits as the new starting point, or keep working on the branch manually.</sub>`,
+		sessionID, phase, user, sha, branch)
 }
 
 // ParseManfredComment extracts metadata from a comment body.
@@ -120,6 +509,52 @@ func IsRetryRequest(body string) bool {
 	return false
 }
 
+// IsResumeRequest checks if a comment asks to resume paused automation.
+func IsResumeRequest(body string) bool {
+	return resumePattern.MatchString(body)
+}
+
+// IsAbortRequest checks if a comment asks to abort the session.
+func IsAbortRequest(body string) bool {
+	return abortPattern.MatchString(body)
+}
+
+// ParsePlanRevision checks if a comment asks to revise the plan and returns
+// the feedback given with the command, which may be empty.
+func ParsePlanRevision(body string) (string, bool) {
+	matches := revisePlanPattern.FindStringSubmatch(body)
+	if matches == nil {
+		return "", false
+	}
+	return strings.TrimSpace(matches[1]), true
+}
+
+// ParsePlanEdit checks if a comment replaces the plan and returns the edited
+// plan given with the command, which may be empty.
+func ParsePlanEdit(body string) (string, bool) {
+	matches := editPlanPattern.FindStringSubmatch(body)
+	if matches == nil {
+		return "", false
+	}
+	return strings.TrimSpace(matches[1]), true
+}
+
+// IsPlanRequest checks if a comment asks Manfred to plan the issue.
+func IsPlanRequest(body string) bool {
+	lower := strings.ToLower(body)
+	patterns := []string{
+		`@claude\s+plan\b`,
+		`(^|\s)/plan\b`,
+	}
+	for _, pattern := range patterns {
+		re := regexp.MustCompile(`(?i)` + pattern)
+		if re.MatchString(lower) {
+			return true
+		}
+	}
+	return false
+}
+
 // ExtractFeedback extracts user feedback from a comment, excluding metadata.
 func ExtractFeedback(body string) string {
 	// Remove HTML comments
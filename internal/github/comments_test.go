@@ -1,7 +1,9 @@
 package github
 
 import (
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestFormatComment(t *testing.T) {
@@ -26,7 +28,7 @@ func TestFormatComment(t *testing.T) {
 }
 
 func TestFormatPlanComment(t *testing.T) {
-	comment := FormatPlanComment("test-session", "1. Do this\n2. Do that")
+	comment := FormatPlanComment("test-session", "1. Do this\n2. Do that", "### Approval checklist")
 
 	meta := ParseManfredComment(comment)
 	if meta == nil {
@@ -37,6 +39,145 @@ func TestFormatPlanComment(t *testing.T) {
 	}
 }
 
+func TestFormatRevisedPlanComment(t *testing.T) {
+	comment := FormatRevisedPlanComment("test-session", "1. Do this", "### Approval checklist", 2)
+
+	meta := ParseManfredComment(comment)
+	if meta == nil || meta.Phase != "planning" {
+		t.Fatalf("ParseManfredComment() = %+v, want phase planning", meta)
+	}
+	if !strings.Contains(comment, "## Implementation Plan (revision 2)") {
+		t.Errorf("comment does not name the revision:\n%s", comment)
+	}
+}
+
+func TestFormatEditedPlanComment(t *testing.T) {
+	comment := FormatEditedPlanComment("test-session", "1. Do that", "### Approval checklist", "alice")
+	if meta := ParseManfredComment(comment); meta == nil || meta.Phase != "planning" {
+		t.Fatalf("ParseManfredComment() = %+v, want phase planning", meta)
+	}
+	if !strings.Contains(comment, "## Implementation Plan (edited by @alice)\n\n1. Do that") {
+		t.Errorf("comment does not name the editor:\n%s", comment)
+	}
+	if comment := FormatEditedPlanComment("test-session", "1. Do that", "", ""); !strings.Contains(comment, "## Implementation Plan (edited)\n") {
+		t.Errorf("comment without editor:\n%s", comment)
+	}
+}
+
+func TestFormatQuestionsComment(t *testing.T) {
+	comment := FormatQuestionsComment("test-session", "1. Which provider?", 1, 2)
+	if meta := ParseManfredComment(comment); meta == nil || meta.Phase != "awaiting_answers" {
+		t.Fatalf("ParseManfredComment() = %+v, want phase awaiting_answers", meta)
+	}
+	if !strings.Contains(comment, "## Clarifying Questions (round 1 of 2)") || !strings.Contains(comment, "1. Which provider?") {
+		t.Errorf("comment lacks the round or the questions:\n%s", comment)
+	}
+}
+
+func TestFormatApprovalChecklist(t *testing.T) {
+	yes := true
+	tests := []struct {
+		name      string
+		risk      string
+		size      string
+		files     []string
+		migration *bool
+		want      []string
+	}{
+		{
+			name:      "assessed",
+			risk:      "high",
+			size:      "small",
+			files:     []string{"internal/store/migrations.go", "README.md"},
+			migration: &yes,
+			want: []string{
+				"| Risk level | high |",
+				"| Estimated size | small |",
+				"| Files likely touched | `internal/store/migrations.go`, `README.md` |",
+				"| Requires migration? | **yes** |",
+				"- [ ] Scope matches the issue",
+			},
+		},
+		{
+			name: "not assessed",
+			want: []string{
+				"| Risk level | _not assessed_ |",
+				"| Estimated size | _not assessed_ |",
+				"| Files likely touched | _not assessed_ |",
+				"| Requires migration? | _not assessed_ |",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FormatApprovalChecklist(tt.risk, tt.size, tt.files, tt.migration)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("checklist missing %q:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatRevisionComment(t *testing.T) {
+	comment := FormatRevisionComment("test-session", "Renamed the helper")
+
+	meta := ParseManfredComment(comment)
+	if meta == nil {
+		t.Fatal("expected to parse metadata")
+	}
+	if meta.SessionID != "test-session" {
+		t.Errorf("expected session ID 'test-session', got %q", meta.SessionID)
+	}
+	if meta.Phase != "revising" {
+		t.Errorf("expected phase 'revising', got %q", meta.Phase)
+	}
+}
+
+func TestFormatErrorComment(t *testing.T) {
+	comment := FormatErrorComment("test-session", "implementing", "job failed")
+	if strings.Contains(comment, "Full output") {
+		t.Errorf("expected no output links, got %q", comment)
+	}
+
+	comment = FormatErrorComment("test-session", "implementing", "job failed",
+		OutputLink{Name: "job log", URL: "https://uploads.example/job.log"})
+	if !IsManfredComment(comment) {
+		t.Error("expected comment to be recognized as a Manfred comment")
+	}
+	if !strings.Contains(comment, "Full output: [job log](https://uploads.example/job.log)") {
+		t.Errorf("expected comment to link the job log, got %q", comment)
+	}
+}
+
+func TestFormatCompletedComment(t *testing.T) {
+	comment := FormatCompletedComment("test-session", 7, "Add login page")
+
+	meta := ParseManfredComment(comment)
+	if meta == nil {
+		t.Fatal("expected to parse metadata")
+	}
+	if meta.Phase != "completed" {
+		t.Errorf("expected phase 'completed', got %q", meta.Phase)
+	}
+	if !strings.Contains(comment, "#7 (Add login page)") {
+		t.Errorf("expected comment to reference the PR, got %q", comment)
+	}
+}
+
+func TestFormatUnauthorizedComment(t *testing.T) {
+	comment := FormatUnauthorizedComment("test-session", "awaiting_approval", "mallory", "approve")
+
+	if !IsManfredComment(comment) {
+		t.Error("expected comment to be recognized as a Manfred comment")
+	}
+	if !strings.Contains(comment, "@mallory you are not authorized to approve") {
+		t.Errorf("unexpected comment: %q", comment)
+	}
+}
+
 func TestParseManfredComment(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -141,6 +282,184 @@ func TestIsRetryRequest(t *testing.T) {
 	}
 }
 
+func TestIsResumeRequest(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{"@claude resume", true},
+		{"@Claude Resume please", true},
+		{"/resume", true},
+		{"let's resume tomorrow", false},
+		{"@claude resumed", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsResumeRequest(tt.body); got != tt.want {
+			t.Errorf("IsResumeRequest(%q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestIsAbortRequest(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{"@manfred abort", true},
+		{"@Claude Abort, wrong issue", true},
+		{"/abort", true},
+		{"should we abort?", false},
+		{"@manfred aborted", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsAbortRequest(tt.body); got != tt.want {
+			t.Errorf("IsAbortRequest(%q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestFormatAbortedComment(t *testing.T) {
+	comment := FormatAbortedComment("test-session", "alice", []string{"Closed pull request #7"})
+
+	meta := ParseManfredComment(comment)
+	if meta == nil || meta.Phase != "aborted" {
+		t.Fatalf("ParseManfredComment() = %+v, want phase aborted", meta)
+	}
+	if !strings.Contains(comment, "aborted by @alice") || !strings.Contains(comment, "- Closed pull request #7\n") {
+		t.Errorf("comment does not summarize the abort:\n%s", comment)
+	}
+}
+
+func TestFormatConflictComment(t *testing.T) {
+	comment := FormatConflictComment("test-session", "implementing", "claude/issue-7", "main", []string{"a.go", "b.go"})
+
+	meta := ParseManfredComment(comment)
+	if meta == nil || meta.SessionID != "test-session" || !strings.HasPrefix(meta.Phase, "implementing") {
+		t.Fatalf("ParseManfredComment() = %+v, want the implementing phase of test-session", meta)
+	}
+	if !strings.Contains(comment, "rebasing `claude/issue-7` onto it conflicts in:\n\n- `a.go`\n- `b.go`\n") {
+		t.Errorf("comment does not list the conflicts:\n%s", comment)
+	}
+}
+
+func TestFormatTriageComment(t *testing.T) {
+	comment := FormatTriageComment("test-session", "A bug in the login form.", []string{"bug", "ui"}, nil, "small", []int{12})
+
+	meta := ParseManfredComment(comment)
+	if meta == nil || meta.Phase != "triaged" {
+		t.Fatalf("ParseManfredComment() = %+v, want phase triaged", meta)
+	}
+	for _, want := range []string{
+		"| Suggested labels | `bug`, `ui` |\n| Estimate | small |\n| Possible duplicates | #12 |\n\nA bug in the login form.",
+		"`@claude plan`",
+	} {
+		if !strings.Contains(comment, want) {
+			t.Errorf("comment lacks %q:\n%s", want, comment)
+		}
+	}
+	if strings.Contains(FormatTriageComment("s", "Text", nil, nil, "", nil), "|") {
+		t.Error("comment without an assessment has a summary table")
+	}
+}
+
+func TestFormatApprovalComments(t *testing.T) {
+	comment := FormatApprovalProgressComment("test-session", "alice", 1, 2)
+	if meta := ParseManfredComment(comment); meta == nil || meta.Phase != "awaiting_approval" {
+		t.Fatalf("ParseManfredComment() = %+v, want phase awaiting_approval", meta)
+	}
+	if !strings.Contains(comment, "@alice (1 of 2 approvals)") {
+		t.Errorf("progress comment lacks the count:\n%s", comment)
+	}
+
+	comment = FormatApprovalReminderComment("test-session", 26*time.Hour, 3*24*time.Hour)
+	if !strings.Contains(comment, "for 26 hours") || !strings.Contains(comment, "aborted in 3 days") {
+		t.Errorf("reminder comment lacks the wait or the expiry:\n%s", comment)
+	}
+	if comment := FormatApprovalReminderComment("test-session", 30*time.Minute, 0); strings.Contains(comment, "aborted") {
+		t.Errorf("reminder without expiry mentions an abort:\n%s", comment)
+	}
+
+	comment = FormatApprovalExpiredComment("test-session", 72*time.Hour, []string{"Deleted branch `manfred/7`"})
+	if meta := ParseManfredComment(comment); meta == nil || meta.Phase != "aborted" {
+		t.Fatalf("ParseManfredComment() = %+v, want phase aborted", meta)
+	}
+	if !strings.Contains(comment, "within 3 days") || !strings.Contains(comment, "- Deleted branch") {
+		t.Errorf("expired comment does not summarize the abort:\n%s", comment)
+	}
+
+	if comment := FormatAutoApprovedComment("test-session", "docs"); !strings.Contains(comment, "`docs`") {
+		t.Errorf("auto-approved comment lacks the label:\n%s", comment)
+	}
+}
+
+func TestParsePlanRevision(t *testing.T) {
+	tests := []struct {
+		body         string
+		wantFeedback string
+		wantOK       bool
+	}{
+		{"@manfred revise-plan: use OAuth instead", "use OAuth instead", true},
+		{"@Claude Revise-Plan Split step 2\ninto two steps", "Split step 2\ninto two steps", true},
+		{"/revise-plan: smaller steps", "smaller steps", true},
+		{"@manfred revise-plan", "", true},
+		{"please revise-plan this", "", false},
+		{"@manfred approved", "", false},
+	}
+
+	for _, tt := range tests {
+		feedback, ok := ParsePlanRevision(tt.body)
+		if feedback != tt.wantFeedback || ok != tt.wantOK {
+			t.Errorf("ParsePlanRevision(%q) = %q, %v, want %q, %v", tt.body, feedback, ok, tt.wantFeedback, tt.wantOK)
+		}
+	}
+}
+
+func TestParsePlanEdit(t *testing.T) {
+	tests := []struct {
+		body     string
+		wantPlan string
+		wantOK   bool
+	}{
+		{"/edit-plan\n1. Add the column\n2. Backfill it", "1. Add the column\n2. Backfill it", true},
+		{"@manfred edit-plan: 1. Only add the column", "1. Only add the column", true},
+		{"@Claude Edit-Plan", "", true},
+		{"please edit-plan this", "", false},
+		{"/revise-plan: smaller steps", "", false},
+	}
+
+	for _, tt := range tests {
+		plan, ok := ParsePlanEdit(tt.body)
+		if plan != tt.wantPlan || ok != tt.wantOK {
+			t.Errorf("ParsePlanEdit(%q) = %q, %v, want %q, %v", tt.body, plan, ok, tt.wantPlan, tt.wantOK)
+		}
+	}
+}
+
+func TestIsPlanRequest(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{"@claude plan", true},
+		{"@Claude Plan this please", true},
+		{"/plan", true},
+		{"Could you /plan it?", true},
+		{"@claude planning is hard", false},
+		{"@claude approved", false},
+		{"see docs/plan.md", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			if got := IsPlanRequest(tt.body); got != tt.want {
+				t.Errorf("IsPlanRequest(%q) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestExtractFeedback(t *testing.T) {
 	tests := []struct {
 		name string
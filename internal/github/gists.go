@@ -0,0 +1,32 @@
+package github
+
+import "context"
+
+// Gist is a GitHub gist.
+type Gist struct {
+	ID      string `json:"id"`
+	HTMLURL string `json:"html_url"`
+}
+
+// CreateGist creates a secret gist with a single file. Secret gists are not
+// listed, but anyone with the URL can read them. Tokens need the gist scope;
+// GitHub App installation tokens cannot create gists.
+func (c *Client) CreateGist(ctx context.Context, description, filename, content string) (*Gist, error) {
+	input := map[string]interface{}{
+		"description": description,
+		"public":      false,
+		"files": map[string]interface{}{
+			filename: map[string]string{"content": content},
+		},
+	}
+	var gist Gist
+	if err := c.post(ctx, "/gists", input, &gist); err != nil {
+		return nil, err
+	}
+	return &gist, nil
+}
+
+// DeleteGist deletes a gist.
+func (c *Client) DeleteGist(ctx context.Context, id string) error {
+	return c.delete(ctx, "/gists/"+id)
+}
@@ -0,0 +1,82 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+)
+
+// ListHooks lists a repository's webhooks. Their secrets are masked.
+func (c *Client) ListHooks(ctx context.Context, owner, repo string) ([]Hook, error) {
+	path := fmt.Sprintf("/repos/%s/%s/hooks?per_page=100", owner, repo)
+	var hooks []Hook
+	if err := c.get(ctx, path, &hooks); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// CreateHook creates a repository webhook.
+func (c *Client) CreateHook(ctx context.Context, owner, repo string, hook *Hook) (*Hook, error) {
+	path := fmt.Sprintf("/repos/%s/%s/hooks", owner, repo)
+	var created Hook
+	if err := c.post(ctx, path, hook, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdateHook replaces the URL, secret, content type, events and active
+// state of a repository webhook.
+func (c *Client) UpdateHook(ctx context.Context, owner, repo string, id int64, hook *Hook) (*Hook, error) {
+	path := fmt.Sprintf("/repos/%s/%s/hooks/%d", owner, repo, id)
+	var updated Hook
+	if err := c.patch(ctx, path, hook, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// EnsureHook creates want on the repository, or updates the webhook it
+// replaces: one with the same URL, or else one with the same URL path on
+// another host, e.g. after the server moved. It reports whether a webhook
+// was created.
+func (c *Client) EnsureHook(ctx context.Context, owner, repo string, want *Hook) (*Hook, bool, error) {
+	hooks, err := c.ListHooks(ctx, owner, repo)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+
+	if existing := matchHook(hooks, want.Config.URL); existing != nil {
+		hook, err := c.UpdateHook(ctx, owner, repo, existing.ID, want)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to update webhook %d: %w", existing.ID, err)
+		}
+		return hook, false, nil
+	}
+
+	hook, err := c.CreateHook(ctx, owner, repo, want)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return hook, true, nil
+}
+
+// matchHook finds the hook for target among hooks: same URL first, then
+// same path.
+func matchHook(hooks []Hook, target string) *Hook {
+	if i := slices.IndexFunc(hooks, func(h Hook) bool { return h.Config.URL == target }); i >= 0 {
+		return &hooks[i]
+	}
+	t, err := url.Parse(target)
+	if err != nil || t.Path == "" || t.Path == "/" {
+		return nil
+	}
+	for i, h := range hooks {
+		if u, err := url.Parse(h.Config.URL); err == nil && u.Path == t.Path {
+			return &hooks[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchHook(t *testing.T) {
+	hooks := []Hook{
+		{ID: 1, Config: HookConfig{URL: "https://ci.example.com/hook"}},
+		{ID: 2, Config: HookConfig{URL: "https://old.example.com/webhook/github"}},
+		{ID: 3, Config: HookConfig{URL: "https://manfred.example.com/webhook/github"}},
+	}
+
+	tests := []struct {
+		target string
+		want   int64
+	}{
+		{"https://manfred.example.com/webhook/github", 3},
+		{"https://new.example.com/webhook/github", 2},
+		{"https://new.example.com/other", 0},
+		{"https://new.example.com", 0},
+	}
+	for _, tt := range tests {
+		var got int64
+		if h := matchHook(hooks, tt.target); h != nil {
+			got = h.ID
+		}
+		if got != tt.want {
+			t.Errorf("matchHook(%q) = hook %d, want %d", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestClient_EnsureHook(t *testing.T) {
+	existing := []Hook{{ID: 7, Config: HookConfig{URL: "http://old:8080/webhook/github"}}}
+	var method, path string
+	var sent Hook
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			json.NewEncoder(w).Encode(existing)
+			return
+		}
+		method, path = r.Method, r.URL.Path
+		json.NewDecoder(r.Body).Decode(&sent)
+		sent.ID = 7
+		json.NewEncoder(w).Encode(sent)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token", WithBaseURL(server.URL))
+	want := &Hook{
+		Name:   "web",
+		Active: true,
+		Events: []string{"issues", "push"},
+		Config: HookConfig{URL: "https://manfred.example.com/webhook/github", ContentType: "json", Secret: "s3cret"},
+	}
+
+	hook, created, err := client.EnsureHook(context.Background(), "acme", "widgets", want)
+	if err != nil {
+		t.Fatalf("EnsureHook() error = %v", err)
+	}
+	if created {
+		t.Error("EnsureHook() created a webhook, want the moved one updated")
+	}
+	if method != http.MethodPatch || path != "/repos/acme/widgets/hooks/7" {
+		t.Errorf("request = %s %s, want PATCH /repos/acme/widgets/hooks/7", method, path)
+	}
+	if sent.Config.Secret != "s3cret" || sent.Config.ContentType != "json" || len(sent.Events) != 2 {
+		t.Errorf("sent hook = %+v", sent)
+	}
+	if hook.ID != 7 {
+		t.Errorf("hook.ID = %d, want 7", hook.ID)
+	}
+
+	existing = nil
+	if _, created, err := client.EnsureHook(context.Background(), "acme", "widgets", want); err != nil || !created {
+		t.Errorf("EnsureHook() without hooks = created %v, %v, want created", created, err)
+	}
+	if method != http.MethodPost || path != "/repos/acme/widgets/hooks" {
+		t.Errorf("request = %s %s, want POST /repos/acme/widgets/hooks", method, path)
+	}
+}
@@ -2,7 +2,10 @@ package github
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 )
 
 // GetIssue fetches an issue by number.
@@ -36,19 +39,64 @@ func (c *Client) AddIssueComment(ctx context.Context, owner, repo string, number
 	return &comment, nil
 }
 
+// CloseIssue closes an issue as completed.
+func (c *Client) CloseIssue(ctx context.Context, owner, repo string, number int) error {
+	path := fmt.Sprintf("/repos/%s/%s/issues/%d", owner, repo, number)
+	input := map[string]string{"state": "closed", "state_reason": "completed"}
+	return c.patch(ctx, path, input, nil)
+}
+
 // AddLabel adds a label to an issue or PR.
 func (c *Client) AddLabel(ctx context.Context, owner, repo string, number int, label string) error {
+	return c.AddLabels(ctx, owner, repo, number, []string{label})
+}
+
+// AddLabels adds labels to an issue or PR. Labels the repository lacks are
+// created.
+func (c *Client) AddLabels(ctx context.Context, owner, repo string, number int, labels []string) error {
 	path := fmt.Sprintf("/repos/%s/%s/issues/%d/labels", owner, repo, number)
-	input := []string{label}
-	return c.post(ctx, path, input, nil)
+	return c.post(ctx, path, labels, nil)
 }
 
 // RemoveLabel removes a label from an issue or PR.
 func (c *Client) RemoveLabel(ctx context.Context, owner, repo string, number int, label string) error {
-	path := fmt.Sprintf("/repos/%s/%s/issues/%d/labels/%s", owner, repo, number, label)
+	path := fmt.Sprintf("/repos/%s/%s/issues/%d/labels/%s", owner, repo, number, url.PathEscape(label))
 	return c.delete(ctx, path)
 }
 
+// CreateLabel creates a repository label.
+func (c *Client) CreateLabel(ctx context.Context, owner, repo string, label *Label) (*Label, error) {
+	path := fmt.Sprintf("/repos/%s/%s/labels", owner, repo)
+	var created Label
+	if err := c.post(ctx, path, label, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdateLabel sets the color and description of the repository label name.
+func (c *Client) UpdateLabel(ctx context.Context, owner, repo, name string, label *Label) (*Label, error) {
+	path := fmt.Sprintf("/repos/%s/%s/labels/%s", owner, repo, url.PathEscape(name))
+	input := map[string]string{"color": label.Color, "description": label.Description}
+	var updated Label
+	if err := c.patch(ctx, path, input, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// EnsureLabel creates a repository label, or updates its color and
+// description if it exists. It reports whether the label was created.
+func (c *Client) EnsureLabel(ctx context.Context, owner, repo string, label *Label) (bool, error) {
+	_, err := c.CreateLabel(ctx, owner, repo, label)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnprocessableEntity {
+		_, err = c.UpdateLabel(ctx, owner, repo, label.Name, label)
+		return false, err
+	}
+	return err == nil, err
+}
+
 // ListIssueLabels returns all labels on an issue.
 func (c *Client) ListIssueLabels(ctx context.Context, owner, repo string, number int) ([]Label, error) {
 	path := fmt.Sprintf("/repos/%s/%s/issues/%d/labels", owner, repo, number)
@@ -58,3 +106,72 @@ func (c *Client) ListIssueLabels(ctx context.Context, owner, repo string, number
 	}
 	return labels, nil
 }
+
+// ListLabels returns up to 100 labels defined on a repository.
+func (c *Client) ListLabels(ctx context.Context, owner, repo string) ([]Label, error) {
+	path := fmt.Sprintf("/repos/%s/%s/labels?per_page=100", owner, repo)
+	var labels []Label
+	if err := c.get(ctx, path, &labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// UpdateIssueComment replaces the body of an existing issue or PR comment.
+func (c *Client) UpdateIssueComment(ctx context.Context, owner, repo string, commentID int64, body string) (*Comment, error) {
+	path := fmt.Sprintf("/repos/%s/%s/issues/comments/%d", owner, repo, commentID)
+	input := map[string]string{"body": body}
+	var comment Comment
+	if err := c.patch(ctx, path, input, &comment); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// ListRepoIssueEvents returns the most recent issue events of a repository
+// (labeled, closed, ...), newest first.
+func (c *Client) ListRepoIssueEvents(ctx context.Context, owner, repo string) ([]IssueActivity, error) {
+	path := fmt.Sprintf("/repos/%s/%s/issues/events?per_page=100", owner, repo)
+	var events []IssueActivity
+	if err := c.get(ctx, path, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ListRepoIssueComments returns the most recent comments on all issues and
+// pull requests of a repository, newest first.
+func (c *Client) ListRepoIssueComments(ctx context.Context, owner, repo string) ([]Comment, error) {
+	path := fmt.Sprintf("/repos/%s/%s/issues/comments?sort=created&direction=desc&per_page=100", owner, repo)
+	var comments []Comment
+	if err := c.get(ctx, path, &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// ListIssues returns up to 100 issues of a repository matching opts, newest
+// first. Like the GitHub API, it includes pull requests; see
+// Issue.IsPullRequest.
+func (c *Client) ListIssues(ctx context.Context, owner, repo string, opts *ListIssuesOptions) ([]Issue, error) {
+	path := fmt.Sprintf("/repos/%s/%s/issues?per_page=100", owner, repo)
+	if opts != nil {
+		if opts.State != "" {
+			path += "&state=" + url.QueryEscape(opts.State)
+		}
+		if opts.Labels != "" {
+			path += "&labels=" + url.QueryEscape(opts.Labels)
+		}
+	}
+	var issues []Issue
+	if err := c.get(ctx, path, &issues); err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
+
+// ListIssuesOptions contains options for listing issues.
+type ListIssuesOptions struct {
+	State  string // "open" (default), "closed", "all"
+	Labels string // Comma-separated labels an issue must all have
+}
@@ -0,0 +1,30 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_ListIssues(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		json.NewEncoder(w).Encode([]Issue{{Number: 4, Title: "Fix login"}})
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token", WithBaseURL(server.URL))
+	issues, err := client.ListIssues(context.Background(), "acme", "widgets", &ListIssuesOptions{State: "open", Labels: "manfred,good first issue"})
+	if err != nil {
+		t.Fatalf("ListIssues() error = %v", err)
+	}
+	if len(issues) != 1 || issues[0].Number != 4 {
+		t.Errorf("ListIssues() = %+v, want issue #4", issues)
+	}
+	if want := "per_page=100&state=open&labels=manfred%2Cgood+first+issue"; query != want {
+		t.Errorf("query = %q, want %q", query, want)
+	}
+}
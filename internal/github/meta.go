@@ -0,0 +1,19 @@
+package github
+
+import "context"
+
+// Meta is the part of GitHub's meta API response listing the address
+// ranges GitHub connects from.
+type Meta struct {
+	Hooks []string `json:"hooks"` // Ranges webhook deliveries come from, in CIDR notation
+}
+
+// Meta returns the address ranges GitHub publishes. It needs no
+// authentication.
+func (c *Client) Meta(ctx context.Context) (*Meta, error) {
+	var meta Meta
+	if err := c.get(ctx, "/meta", &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
@@ -0,0 +1,26 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_Meta(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/meta" {
+			t.Errorf("path = %s, want /meta", r.URL.Path)
+		}
+		w.Write([]byte(`{"verifiable_password_authentication": false, "hooks": ["192.30.252.0/22", "2a0a:a440::/29"], "web": ["140.82.112.0/20"]}`))
+	}))
+	defer server.Close()
+
+	meta, err := NewClient("", WithBaseURL(server.URL)).Meta(context.Background())
+	if err != nil {
+		t.Fatalf("Meta() error = %v", err)
+	}
+	if len(meta.Hooks) != 2 || meta.Hooks[0] != "192.30.252.0/22" {
+		t.Errorf("Meta().Hooks = %v", meta.Hooks)
+	}
+}
@@ -0,0 +1,163 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Capability is something MANFRED needs its credentials to be allowed to do
+// on a repository.
+type Capability string
+
+const (
+	CapabilityRead          Capability = "read"           // Read the repository
+	CapabilityContentsWrite Capability = "contents_write" // Push session branches
+	CapabilityIssuesWrite   Capability = "issues_write"   // Comment on and close issues
+	CapabilityLabelsWrite   Capability = "labels_write"   // Label issues and pull requests
+	CapabilityPullsWrite    Capability = "pulls_write"    // Open and update pull requests
+	CapabilityHooksAdmin    Capability = "hooks_admin"    // Manage repository webhooks
+)
+
+// RequiredCapabilities returns the capabilities a session needs on every
+// repository, in check order.
+func RequiredCapabilities() []Capability {
+	return []Capability{
+		CapabilityRead,
+		CapabilityContentsWrite,
+		CapabilityIssuesWrite,
+		CapabilityLabelsWrite,
+		CapabilityPullsWrite,
+	}
+}
+
+// CapabilityCheck is the outcome of checking one capability. Err is set
+// when the check could not tell either way, e.g. on a network error.
+type CapabilityCheck struct {
+	Capability Capability
+	Allowed    bool
+	Err        error
+}
+
+// repoPermissions is the role of the authenticated user on a repository,
+// as GET /repos/{owner}/{repo} reports it.
+type repoPermissions struct {
+	Admin    bool `json:"admin"`
+	Maintain bool `json:"maintain"`
+	Push     bool `json:"push"`
+	Triage   bool `json:"triage"`
+	Pull     bool `json:"pull"`
+}
+
+// allows reports whether the role grants capability c, and whether c is
+// known.
+func (p *repoPermissions) allows(c Capability) (bool, bool) {
+	switch c {
+	case CapabilityRead:
+		return p.Pull, true
+	case CapabilityContentsWrite, CapabilityPullsWrite:
+		return p.Push, true
+	case CapabilityIssuesWrite, CapabilityLabelsWrite:
+		return p.Triage || p.Push, true
+	case CapabilityHooksAdmin:
+		return p.Admin, true
+	}
+	return false, false
+}
+
+// appAllows is repoPermissions.allows for the installation permissions of a
+// GitHub App, e.g. "contents": "write".
+func appAllows(permissions map[string]string, c Capability) (bool, bool) {
+	write := func(names ...string) bool {
+		for _, name := range names {
+			if permissions[name] == "write" || permissions[name] == "admin" {
+				return true
+			}
+		}
+		return false
+	}
+	switch c {
+	case CapabilityRead:
+		return permissions["metadata"] != "" || permissions["contents"] != "", true
+	case CapabilityContentsWrite:
+		return write("contents"), true
+	case CapabilityIssuesWrite:
+		return write("issues"), true
+	case CapabilityLabelsWrite:
+		return write("issues", "pull_requests"), true
+	case CapabilityPullsWrite:
+		return write("pull_requests"), true
+	case CapabilityHooksAdmin:
+		return write("repository_hooks", "administration"), true
+	}
+	return false, false
+}
+
+// CheckCapabilities checks whether the client's credentials hold each
+// capability on owner/repo, from the permissions GitHub reports for the
+// repository: the user's role for a token, the installation's permissions
+// for a GitHub App. It only reads.
+func (c *Client) CheckCapabilities(ctx context.Context, owner, repo string, caps ...Capability) []CapabilityCheck {
+	checks := make([]CapabilityCheck, len(caps))
+	for i, capability := range caps {
+		checks[i].Capability = capability
+	}
+	fail := func(err error) []CapabilityCheck {
+		for i := range checks {
+			checks[i].Err = err
+		}
+		return checks
+	}
+
+	var result struct {
+		Permissions *repoPermissions `json:"permissions"`
+	}
+	err := c.get(ctx, fmt.Sprintf("/repos/%s/%s", owner, repo), &result)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusForbidden || apiErr.StatusCode == http.StatusNotFound) {
+		// Denied; GitHub hides repositories the credentials cannot see
+		return checks
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	var allows func(Capability) (bool, bool)
+	switch {
+	case c.app != nil:
+		permissions := c.app.installationPermissions()
+		allows = func(capability Capability) (bool, bool) { return appAllows(permissions, capability) }
+	case result.Permissions != nil:
+		allows = result.Permissions.allows
+	default:
+		return fail(fmt.Errorf("GitHub did not report permissions on %s/%s", owner, repo))
+	}
+	for i, capability := range caps {
+		allowed, known := allows(capability)
+		if !known {
+			checks[i].Err = fmt.Errorf("unknown capability %q", capability)
+			continue
+		}
+		checks[i].Allowed = allowed
+	}
+	return checks
+}
+
+// CollaboratorPermission returns the permission of user on owner/repo:
+// "admin", "write", "read" or "none". Maintainers get "write" and triagers
+// "read".
+func (c *Client) CollaboratorPermission(ctx context.Context, owner, repo, user string) (string, error) {
+	path := fmt.Sprintf("/repos/%s/%s/collaborators/%s/permission", owner, repo, user)
+	var result struct {
+		Permission string `json:"permission"`
+	}
+	if err := c.get(ctx, path, &result); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return "none", nil
+		}
+		return "", err
+	}
+	return result.Permission, nil
+}
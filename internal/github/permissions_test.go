@@ -0,0 +1,75 @@
+package github
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_CheckCapabilities(t *testing.T) {
+	repos := map[string]string{
+		// A triager: may read and label, but not push or open PRs
+		"/repos/acme/widgets": `{"permissions": {"admin": false, "push": false, "triage": true, "pull": true}}`,
+		// A GitHub App installation token does not report a role
+		"/repos/acme/app": `{}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/app/installations/2/access_tokens":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"token": "ghs_x", "expires_at": "2099-01-01T00:00:00Z",
+				"permissions": {"metadata": "read", "contents": "write", "issues": "write", "pull_requests": "read"}}`))
+		case r.Method != http.MethodGet:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		case repos[r.URL.Path] != "":
+			w.Write([]byte(repos[r.URL.Path]))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Not Found"}`))
+		}
+	}))
+	defer server.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := NewClient("test-token", WithBaseURL(server.URL))
+	app := NewClient("", WithBaseURL(server.URL), WithAppAuth(1, 2, key))
+	caps := append(RequiredCapabilities(), CapabilityHooksAdmin)
+
+	tests := []struct {
+		name    string
+		client  *Client
+		repo    string
+		allowed []bool // In caps order
+		wantErr bool
+	}{
+		{"triager", token, "widgets", []bool{true, false, true, true, false, false}, false},
+		{"hidden repository", token, "secret", []bool{false, false, false, false, false, false}, false},
+		{"no role reported", token, "app", nil, true},
+		{"app installation", app, "app", []bool{true, true, true, true, false, false}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks := tt.client.CheckCapabilities(context.Background(), "acme", tt.repo, caps...)
+			if len(checks) != len(caps) {
+				t.Fatalf("CheckCapabilities() returned %d checks, want %d", len(checks), len(caps))
+			}
+			for i, got := range checks {
+				if got.Capability != caps[i] {
+					t.Errorf("checks[%d].Capability = %q, want %q", i, got.Capability, caps[i])
+				}
+				if (got.Err != nil) != tt.wantErr {
+					t.Errorf("%s: Err = %v, wantErr %v", got.Capability, got.Err, tt.wantErr)
+				}
+				if tt.allowed != nil && got.Allowed != tt.allowed[i] {
+					t.Errorf("%s: Allowed = %v, want %v", got.Capability, got.Allowed, tt.allowed[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package github
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// DefaultPRTemplate renders the body of a session's pull request unless
+// github.pr_template or pr_template in project.yml replaces it. It is a
+// text/template over PRDescription, with the function fence returning a
+// code fence its argument cannot close (CodeFence).
+const DefaultPRTemplate = `{{.Summary}}
+
+Closes #{{.IssueNumber}}
+{{- with .Plan}}
+
+<details>
+<summary>Approved plan</summary>
+
+{{.}}
+
+</details>
+{{- end}}
+{{- with .Diff}}
+
+### Changes
+
+{{.FilesChanged}} file{{if ne .FilesChanged 1}}s{{end}} changed, {{.Insertions}} insertion{{if ne .Insertions 1}}s{{end}}(+), {{.Deletions}} deletion{{if ne .Deletions 1}}s{{end}}(-)
+{{- end}}
+{{- with .Tests}}
+
+### Tests
+
+{{if .Passed}}:white_check_mark: Passed{{else}}:x: Failed (exit code {{.ExitCode}}){{end}}: ` + "`{{.Command}}`" + `{{if gt .FixAttempts 0}} after {{.FixAttempts}} fix attempt{{if ne .FixAttempts 1}}s{{end}}{{end}}
+{{- if and (not .Passed) .Output}}
+
+<details>
+<summary>Output of the last run</summary>
+
+{{$fence := fence .Output}}{{$fence}}
+{{.Output}}
+{{$fence}}
+
+</details>
+{{- end}}
+{{- end}}
+
+---
+
+<sub>Generated by [MANFRED](https://github.com/mpm/manfred)</sub>`
+
+// maxPRTestOutput is how much of the end of the test output a PR body shows.
+const maxPRTestOutput = 3000
+
+// PRDescription is the data the pull request template is rendered with.
+type PRDescription struct {
+	SessionID   string
+	JobID       string
+	IssueNumber int
+	IssueTitle  string
+	Summary     string        // Commit message of the implementation
+	Plan        string        // Approved plan; empty when there is none
+	Diff        *PRDiffStat   // nil when the job recorded no diff
+	Tests       *PRTestResult // nil when no test command ran
+}
+
+// PRDiffStat summarizes the changes of a pull request.
+type PRDiffStat struct {
+	FilesChanged int
+	Insertions   int
+	Deletions    int
+}
+
+// PRTestResult is the outcome of the job's test phase.
+type PRTestResult struct {
+	Command     string
+	Passed      bool
+	ExitCode    int
+	Attempts    int
+	FixAttempts int
+	Output      string // End of the output of the last run
+}
+
+// closingRef matches a reference that closes issue number %d when the pull
+// request is merged.
+const closingRef = `(?i)\b(close[sd]?|fix(e[sd])?|resolve[sd]?):?\s+#%d\b`
+
+// ParsePRTemplate parses a pull request template; an empty text is
+// DefaultPRTemplate.
+func ParsePRTemplate(text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		text = DefaultPRTemplate
+	}
+	return template.New("pr").Option("missingkey=zero").Funcs(template.FuncMap{"fence": CodeFence}).Parse(text)
+}
+
+// FormatPRDescription renders the body of a session's pull request with tmpl
+// as parsed by ParsePRTemplate, after the session marker. A template that
+// fails to parse or execute falls back to DefaultPRTemplate, with the error
+// returned alongside the body. "Closes #N" is appended when the body does
+// not close the issue itself.
+func FormatPRDescription(tmpl string, d *PRDescription) (string, error) {
+	if d.Tests != nil {
+		d.Tests.Output = tail(strings.TrimSpace(d.Tests.Output), maxPRTestOutput)
+	}
+	body, err := renderPRDescription(tmpl, d)
+	if err != nil {
+		body, _ = renderPRDescription("", d)
+	}
+	body = strings.TrimSpace(body)
+	// Merging the pull request still closes the issue when a custom
+	// template leaves the reference out
+	if !regexp.MustCompile(fmt.Sprintf(closingRef, d.IssueNumber)).MatchString(body) {
+		body += fmt.Sprintf("\n\nCloses #%d", d.IssueNumber)
+	}
+	return fmt.Sprintf("<!-- manfred:session:%s:pr -->\n\n%s", d.SessionID, body), err
+}
+
+// renderPRDescription executes the template text with d.
+func renderPRDescription(text string, d *PRDescription) (string, error) {
+	t, err := ParsePRTemplate(text)
+	if err != nil {
+		return "", fmt.Errorf("parse pull request template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, d); err != nil {
+		return "", fmt.Errorf("render pull request template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// tail returns the last limit bytes of s, starting at a line, marked as cut.
+func tail(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	s = s[len(s)-limit:]
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	return "...\n" + s
+}
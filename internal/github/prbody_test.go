@@ -0,0 +1,84 @@
+package github
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatPRDescription(t *testing.T) {
+	d := &PRDescription{
+		SessionID:   "test-session",
+		IssueNumber: 7,
+		Summary:     "Add OAuth login",
+		Plan:        "1. Add the provider",
+		Diff:        &PRDiffStat{FilesChanged: 3, Insertions: 1, Deletions: 12},
+		Tests:       &PRTestResult{Command: "make test", Passed: true, Attempts: 2, FixAttempts: 1, Output: "ok"},
+	}
+	body, err := FormatPRDescription("", d)
+	if err != nil {
+		t.Fatalf("FormatPRDescription() error = %v", err)
+	}
+	for _, want := range []string{
+		"<!-- manfred:session:test-session:pr -->",
+		"Add OAuth login",
+		"Closes #7",
+		"1. Add the provider",
+		"3 files changed, 1 insertion(+), 12 deletions(-)",
+		"Passed: `make test` after 1 fix attempt\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body lacks %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "Output of the last run") {
+		t.Errorf("body shows the output of passing tests:\n%s", body)
+	}
+
+	d.Tests = &PRTestResult{Command: "make test", ExitCode: 2, Output: strings.Repeat("line\n", 1000) + "FAIL TestLogin"}
+	d.Plan, d.Diff = "", nil
+	body, _ = FormatPRDescription("", d)
+	if !strings.Contains(body, "Failed (exit code 2)") || !strings.Contains(body, "FAIL TestLogin") {
+		t.Errorf("body lacks the failed test run:\n%s", body)
+	}
+	if strings.Contains(body, "Approved plan") || strings.Contains(body, "### Changes") {
+		t.Errorf("body shows a missing plan or diff:\n%s", body)
+	}
+	if len(body) > 2*maxPRTestOutput {
+		t.Errorf("body is %d bytes, want the test output cut", len(body))
+	}
+	// Fences in the output do not close the one around it
+	d.Tests.Output = "--- FAIL: TestRender\n```\nwant\n```"
+	body, _ = FormatPRDescription("", d)
+	if !strings.Contains(body, "````\n--- FAIL: TestRender\n```\nwant\n```\n````") {
+		t.Errorf("body does not fence the output with ````:\n%s", body)
+	}
+}
+
+func TestFormatPRDescriptionCustomTemplate(t *testing.T) {
+	d := &PRDescription{SessionID: "test-session", IssueNumber: 7, IssueTitle: "Login", Summary: "Add OAuth login"}
+
+	body, err := FormatPRDescription("Fixes #{{.IssueNumber}} ({{.IssueTitle}})", d)
+	if err != nil {
+		t.Fatalf("FormatPRDescription() error = %v", err)
+	}
+	if !strings.HasPrefix(body, "<!-- manfred:session:test-session:pr -->") || !strings.HasSuffix(body, "Fixes #7 (Login)") {
+		t.Errorf("body = %q, want the marker and the custom template", body)
+	}
+
+	// Templates that do not close the issue get "Closes #N"
+	body, err = FormatPRDescription("{{.Summary}}\n\nSee #{{.IssueNumber}} and closes #70", d)
+	if err != nil {
+		t.Fatalf("FormatPRDescription() error = %v", err)
+	}
+	if !strings.HasSuffix(body, "See #7 and closes #70\n\nCloses #7") {
+		t.Errorf("body = %q, want Closes #7 appended", body)
+	}
+
+	body, err = FormatPRDescription("{{.Missing.Field}}", d)
+	if err == nil {
+		t.Error("FormatPRDescription() with a broken template succeeded, want an error")
+	}
+	if !strings.Contains(body, "Closes #7") {
+		t.Errorf("body = %q, want the default template as a fallback", body)
+	}
+}
@@ -49,6 +49,26 @@ func (c *Client) GetPRReviewComments(ctx context.Context, owner, repo string, nu
 	return comments, nil
 }
 
+// GetReviewComments fetches the line comments belonging to a single review.
+func (c *Client) GetReviewComments(ctx context.Context, owner, repo string, number int, reviewID int64) ([]ReviewComment, error) {
+	path := fmt.Sprintf("/repos/%s/%s/pulls/%d/reviews/%d/comments", owner, repo, number, reviewID)
+	var comments []ReviewComment
+	if err := c.get(ctx, path, &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// ListReviews returns the reviews of a pull request, oldest first.
+func (c *Client) ListReviews(ctx context.Context, owner, repo string, number int) ([]Review, error) {
+	path := fmt.Sprintf("/repos/%s/%s/pulls/%d/reviews?per_page=100", owner, repo, number)
+	var reviews []Review
+	if err := c.get(ctx, path, &reviews); err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
 // AddPRComment adds a general comment to a pull request.
 func (c *Client) AddPRComment(ctx context.Context, owner, repo string, number int, body string) (*Comment, error) {
 	// PR general comments use the issues endpoint
@@ -73,6 +93,29 @@ type UpdatePullRequestInput struct {
 	Base  string `json:"base,omitempty"`
 }
 
+// MarkPullRequestReady marks a draft pull request, by its node ID, ready for
+// review. The REST API cannot, so this is a GraphQL mutation.
+func (c *Client) MarkPullRequestReady(ctx context.Context, nodeID string) error {
+	const mutation = `mutation($id: ID!) {
+  markPullRequestReadyForReview(input: {pullRequestId: $id}) { pullRequest { isDraft } }
+}`
+	return c.graphql(ctx, mutation, map[string]interface{}{"id": nodeID}, nil)
+}
+
+// RequestReviewers asks users, by login, and teams, by slug, to review a
+// pull request.
+func (c *Client) RequestReviewers(ctx context.Context, owner, repo string, number int, reviewers, teamReviewers []string) error {
+	path := fmt.Sprintf("/repos/%s/%s/pulls/%d/requested_reviewers", owner, repo, number)
+	input := map[string][]string{}
+	if len(reviewers) > 0 {
+		input["reviewers"] = reviewers
+	}
+	if len(teamReviewers) > 0 {
+		input["team_reviewers"] = teamReviewers
+	}
+	return c.post(ctx, path, input, nil)
+}
+
 // ListPullRequests lists pull requests for a repository.
 func (c *Client) ListPullRequests(ctx context.Context, owner, repo string, opts *ListPullRequestsOptions) ([]PullRequest, error) {
 	path := fmt.Sprintf("/repos/%s/%s/pulls", owner, repo)
@@ -135,3 +178,44 @@ func (c *Client) IsPRMerged(ctx context.Context, owner, repo string, number int)
 	}
 	return true, nil
 }
+
+// MergePullRequestInput contains fields for merging a pull request.
+type MergePullRequestInput struct {
+	MergeMethod string `json:"merge_method,omitempty"` // "merge", "squash" or "rebase"
+	SHA         string `json:"sha,omitempty"`          // Head the pull request must still have
+}
+
+// MergeResult is the outcome of merging a pull request.
+type MergeResult struct {
+	SHA     string `json:"sha"`
+	Merged  bool   `json:"merged"`
+	Message string `json:"message"`
+}
+
+// MergePullRequest merges a pull request. GitHub refuses with 405 when the
+// pull request is not mergeable, e.g. because required checks have not
+// passed, and with 409 when its head is no longer input.SHA.
+func (c *Client) MergePullRequest(ctx context.Context, owner, repo string, number int, input *MergePullRequestInput) (*MergeResult, error) {
+	path := fmt.Sprintf("/repos/%s/%s/pulls/%d/merge", owner, repo, number)
+	var result MergeResult
+	if err := c.put(ctx, path, input, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetBranch fetches a branch, including the SHA of its head commit.
+func (c *Client) GetBranch(ctx context.Context, owner, repo, branch string) (*Branch, error) {
+	path := fmt.Sprintf("/repos/%s/%s/branches/%s", owner, repo, branch)
+	var b Branch
+	if err := c.get(ctx, path, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// DeleteBranch deletes a branch from a repository.
+func (c *Client) DeleteBranch(ctx context.Context, owner, repo, branch string) error {
+	path := fmt.Sprintf("/repos/%s/%s/git/refs/heads/%s", owner, repo, branch)
+	return c.delete(ctx, path)
+}
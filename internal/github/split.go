@@ -0,0 +1,120 @@
+package github
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// fenceReserve is room kept in each part for closing a code fence split
+// across parts.
+const fenceReserve = 16
+
+// SplitComment splits a Markdown body into parts of at most limit bytes,
+// at line breaks where possible. A code fence open at a split is closed at
+// the end of the part and reopened at the start of the next one, so each
+// part renders on its own.
+func SplitComment(body string, limit int) []string {
+	if len(body) <= limit {
+		return []string{body}
+	}
+
+	var parts []string
+	var part strings.Builder
+	fence := "" // Opening line of the fence open in part, if any
+
+	flush := func() {
+		if part.Len() == 0 {
+			return
+		}
+		text := strings.TrimRight(part.String(), "\n")
+		if fence != "" {
+			text += "\n" + fenceMarker(fence)
+		}
+		parts = append(parts, text)
+		part.Reset()
+		if fence != "" {
+			part.WriteString(fence + "\n")
+		}
+	}
+
+	for _, line := range strings.SplitAfter(body, "\n") {
+		budget := limit - fenceReserve - len(fence)
+		if part.Len()+len(line) > budget {
+			flush()
+		}
+		// A single line longer than a part is cut at rune boundaries
+		for part.Len()+len(line) > budget {
+			n := budget - part.Len()
+			for n > 0 && !utf8.RuneStart(line[n]) {
+				n--
+			}
+			if n <= 0 {
+				n = len(line)
+			}
+			part.WriteString(line[:n])
+			line = line[n:]
+			flush()
+		}
+		part.WriteString(line)
+		fence = nextFence(fence, line)
+	}
+	if strings.TrimSpace(part.String()) != strings.TrimSpace(fence) {
+		flush()
+	}
+	return parts
+}
+
+// TruncateComment cuts a Markdown body to at most limit bytes, closing a
+// code fence open at the cut, and ends it with a note linking to the full
+// text at link. Bodies within limit are returned unchanged.
+func TruncateComment(body string, limit int, link string) string {
+	if len(body) <= limit {
+		return body
+	}
+	note := fmt.Sprintf("\n\n<sub>Truncated, see the [full text](%s).</sub>", link)
+	return SplitComment(body, limit-len(note))[0] + note
+}
+
+// CodeFence returns a backtick fence that text cannot close, for wrapping
+// output such as logs that may contain fences of its own.
+func CodeFence(text string) string {
+	fence := "```"
+	lines := strings.Split(text, "\n")
+	for slices.ContainsFunc(lines, func(line string) bool { return nextFence(fence, line) == "" }) {
+		fence += "`"
+	}
+	return fence
+}
+
+// nextFence returns the fence open after line, given the fence open before
+// it: fences open on ``` or ~~~ lines and close on a line of the same
+// marker without an info string.
+func nextFence(open, line string) string {
+	trimmed := strings.TrimSpace(line)
+	marker := fenceMarker(trimmed)
+	if marker == "" {
+		return open
+	}
+	if open == "" {
+		return trimmed
+	}
+	if trimmed == strings.Repeat(marker[:1], len(trimmed)) && len(trimmed) >= len(fenceMarker(open)) {
+		return ""
+	}
+	return open
+}
+
+// fenceMarker returns the run of backticks or tildes starting line, if it
+// opens or closes a code fence.
+func fenceMarker(line string) string {
+	line = strings.TrimSpace(line)
+	for _, c := range []string{"`", "~"} {
+		n := len(line) - len(strings.TrimLeft(line, c))
+		if n >= 3 {
+			return line[:n]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,102 @@
+package github
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitComment(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		limit int
+		want  []string
+	}{
+		{
+			name:  "within limit",
+			body:  "short",
+			limit: 100,
+			want:  []string{"short"},
+		},
+		{
+			name:  "at line breaks",
+			body:  "aaaaaaaaaa\nbbbbbbbbbb\ncccccccccc\ndddddddddd\n",
+			limit: fenceReserve + 22,
+			want:  []string{"aaaaaaaaaa\nbbbbbbbbbb", "cccccccccc\ndddddddddd"},
+		},
+		{
+			name:  "long line",
+			body:  strings.Repeat("x", 30),
+			limit: fenceReserve + 12,
+			want:  []string{strings.Repeat("x", 12), strings.Repeat("x", 12), strings.Repeat("x", 6)},
+		},
+		{
+			name:  "reopens code fence",
+			body:  "```go\nline1\nline2\nline3\nline4\nline5\n```\nafter",
+			limit: fenceReserve + 5 + 18,
+			want:  []string{"```go\nline1\nline2\n```", "```go\nline3\nline4\n```", "```go\nline5\n```\nafter"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitComment(tt.body, tt.limit)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("SplitComment() = %q, want %q", got, tt.want)
+			}
+			for i, part := range got {
+				if len(part) > tt.limit {
+					t.Errorf("part %d has %d bytes, over limit %d", i, len(part), tt.limit)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitComment_KeepsRunes(t *testing.T) {
+	body := strings.Repeat("ä", 50)
+	parts := SplitComment(body, fenceReserve+15)
+	if strings.Join(parts, "") != body {
+		t.Errorf("parts do not join to the body: %q", parts)
+	}
+	for i, part := range parts {
+		if !strings.HasPrefix(part, "ä") || !strings.HasSuffix(part, "ä") {
+			t.Errorf("part %d = %q splits a rune", i, part)
+		}
+	}
+}
+
+func TestTruncateComment(t *testing.T) {
+	if got := TruncateComment("short", 100, "https://gist.example/1"); got != "short" {
+		t.Errorf("TruncateComment() = %q, want body unchanged", got)
+	}
+
+	body := "```\n" + strings.Repeat("output line\n", 200) + "```"
+	got := TruncateComment(body, 500, "https://gist.example/1")
+	if len(got) > 500 {
+		t.Errorf("len = %d, want at most 500", len(got))
+	}
+	if !strings.HasSuffix(got, "[full text](https://gist.example/1).</sub>") {
+		t.Errorf("missing link: %q", got[len(got)-80:])
+	}
+	if strings.Count(got, "```") != 2 {
+		t.Errorf("code fence not closed: %q", got)
+	}
+}
+
+func TestCodeFence(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"ok\nPASS", "```"},
+		{"inline ``` backticks", "```"},
+		{"```go\nx := 1\n```", "````"},
+		{"  ````\n~~~", "`````"},
+	}
+	for _, tt := range tests {
+		if got := CodeFence(tt.text); got != tt.want {
+			t.Errorf("CodeFence(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package github
+
+import (
+	"context"
+	"fmt"
+)
+
+// IsTeamMember checks whether a user is an active member of an organization
+// team. Requires a token with read:org scope.
+func (c *Client) IsTeamMember(ctx context.Context, org, teamSlug, username string) (bool, error) {
+	path := fmt.Sprintf("/orgs/%s/teams/%s/memberships/%s", org, teamSlug, username)
+	var membership struct {
+		State string `json:"state"`
+	}
+	if err := c.get(ctx, path, &membership); err != nil {
+		if apiErr, ok := err.(*APIError); ok && apiErr.StatusCode == 404 {
+			return false, nil
+		}
+		return false, err
+	}
+	return membership.State == "active", nil
+}
@@ -1,7 +1,11 @@
 // Package github provides a client for GitHub API operations.
 package github
 
-import "time"
+import (
+	"strconv"
+	"strings"
+	"time"
+)
 
 // Issue represents a GitHub issue.
 type Issue struct {
@@ -14,6 +18,20 @@ type Issue struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	HTMLURL   string    `json:"html_url"`
+
+	// PullRequest is set when the issue is a pull request.
+	PullRequest *IssuePullRequest `json:"pull_request,omitempty"`
+}
+
+// IssuePullRequest links an issue to its pull request.
+type IssuePullRequest struct {
+	URL     string `json:"url"`
+	HTMLURL string `json:"html_url"`
+}
+
+// IsPullRequest returns true if the issue is a pull request.
+func (i *Issue) IsPullRequest() bool {
+	return i.PullRequest != nil
 }
 
 // Comment represents a GitHub issue or PR comment.
@@ -24,36 +42,64 @@ type Comment struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	HTMLURL   string    `json:"html_url"`
+	IssueURL  string    `json:"issue_url,omitempty"`
 }
 
-// ReviewComment represents a GitHub PR review comment (on a specific line).
-type ReviewComment struct {
+// IssueNumber returns the number of the issue or PR the comment belongs to,
+// taken from IssueURL, or 0 if it is unknown.
+func (c *Comment) IssueNumber() int {
+	i := strings.LastIndex(c.IssueURL, "/")
+	if i < 0 {
+		return 0
+	}
+	n, err := strconv.Atoi(c.IssueURL[i+1:])
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// IssueActivity is an entry of a repository's issue event feed.
+type IssueActivity struct {
 	ID        int64     `json:"id"`
-	Body      string    `json:"body"`
-	Path      string    `json:"path"`
-	Line      *int      `json:"line"`
-	User      User      `json:"user"`
+	Event     string    `json:"event"` // "labeled", "closed", "assigned", ...
+	Actor     User      `json:"actor"`
+	Label     *Label    `json:"label,omitempty"` // For "labeled"/"unlabeled"
+	Issue     *Issue    `json:"issue,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	HTMLURL   string    `json:"html_url"`
-	DiffHunk  string    `json:"diff_hunk"`
+}
+
+// ReviewComment represents a GitHub PR review comment (on a specific line).
+type ReviewComment struct {
+	ID                  int64     `json:"id"`
+	PullRequestReviewID int64     `json:"pull_request_review_id"`
+	Body                string    `json:"body"`
+	Path                string    `json:"path"`
+	Line                *int      `json:"line"`
+	User                User      `json:"user"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+	HTMLURL             string    `json:"html_url"`
+	DiffHunk            string    `json:"diff_hunk"`
 }
 
 // PullRequest represents a GitHub pull request.
 type PullRequest struct {
-	Number    int       `json:"number"`
-	Title     string    `json:"title"`
-	Body      string    `json:"body"`
-	State     string    `json:"state"` // "open", "closed"
-	Merged    bool      `json:"merged"`
-	User      User      `json:"user"`
-	Head      GitRef    `json:"head"`
-	Base      GitRef    `json:"base"`
-	Labels    []Label   `json:"labels"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Number    int        `json:"number"`
+	NodeID    string     `json:"node_id"` // GraphQL ID
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	State     string     `json:"state"` // "open", "closed"
+	Draft     bool       `json:"draft"`
+	Merged    bool       `json:"merged"`
+	User      User       `json:"user"`
+	Head      GitRef     `json:"head"`
+	Base      GitRef     `json:"base"`
+	Labels    []Label    `json:"labels"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
 	MergedAt  *time.Time `json:"merged_at"`
-	HTMLURL   string    `json:"html_url"`
+	HTMLURL   string     `json:"html_url"`
 }
 
 // CreatePullRequestInput contains fields for creating a pull request.
@@ -75,8 +121,9 @@ type User struct {
 
 // Label represents a GitHub label.
 type Label struct {
-	Name  string `json:"name"`
-	Color string `json:"color"`
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description,omitempty"`
 }
 
 // GitRef represents a git reference (branch) in a PR.
@@ -86,6 +133,76 @@ type GitRef struct {
 	Repo *Repo  `json:"repo"`
 }
 
+// Branch represents a repository branch.
+type Branch struct {
+	Name   string `json:"name"`
+	Commit struct {
+		SHA string `json:"sha"`
+	} `json:"commit"`
+}
+
+// CommitStatus represents a commit status, shown on PRs next to checks.
+type CommitStatus struct {
+	ID          int64  `json:"id,omitempty"`
+	State       string `json:"state"` // pending, success, failure, error
+	Context     string `json:"context"`
+	Description string `json:"description,omitempty"`
+	TargetURL   string `json:"target_url,omitempty"`
+}
+
+// CheckRun represents a check run. Creating check runs requires GitHub App
+// authentication.
+type CheckRun struct {
+	ID          int64           `json:"id,omitempty"`
+	Name        string          `json:"name,omitempty"`
+	HeadSHA     string          `json:"head_sha,omitempty"`
+	Status      string          `json:"status,omitempty"`     // queued, in_progress, completed
+	Conclusion  string          `json:"conclusion,omitempty"` // success, failure, ... when completed
+	DetailsURL  string          `json:"details_url,omitempty"`
+	Output      *CheckRunOutput `json:"output,omitempty"`
+	HTMLURL     string          `json:"html_url,omitempty"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+
+	// Set by GitHub on check runs it returns or delivers
+	App        *CheckRunApp   `json:"app,omitempty"`
+	CheckSuite *CheckSuiteRef `json:"check_suite,omitempty"`
+}
+
+// CheckRunApp is the GitHub App that created a check run.
+type CheckRunApp struct {
+	Slug string `json:"slug"`
+}
+
+// CheckSuiteRef is the check suite a check run belongs to.
+type CheckSuiteRef struct {
+	HeadBranch string `json:"head_branch"`
+}
+
+// CheckRunOutput is the summary shown on a check run's page.
+type CheckRunOutput struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+	Text    string `json:"text,omitempty"`
+}
+
+// Hook represents a repository webhook.
+type Hook struct {
+	ID     int64      `json:"id,omitempty"`
+	Name   string     `json:"name,omitempty"` // "web" for webhooks
+	Active bool       `json:"active"`
+	Events []string   `json:"events"`
+	Config HookConfig `json:"config"`
+}
+
+// HookConfig holds where and how a webhook delivers events.
+type HookConfig struct {
+	URL         string `json:"url"`
+	ContentType string `json:"content_type,omitempty"` // json or form
+	Secret      string `json:"secret,omitempty"`       // Masked when read back
+	InsecureSSL string `json:"insecure_ssl,omitempty"` // "0" verifies TLS certificates
+}
+
 // Repo represents a GitHub repository.
 type Repo struct {
 	Owner    User   `json:"owner"`
@@ -105,9 +222,18 @@ type RateLimit struct {
 
 // APIError represents a GitHub API error response.
 type APIError struct {
-	Message          string `json:"message"`
-	DocumentationURL string `json:"documentation_url"`
-	StatusCode       int    `json:"-"`
+	Message          string        `json:"message"`
+	DocumentationURL string        `json:"documentation_url"`
+	StatusCode       int           `json:"-"`
+	RetryAfter       time.Duration `json:"-"` // From the Retry-After header, if present
+	Errors           []ErrorDetail `json:"errors"`
+}
+
+// ErrorDetail is one entry of a validation error's errors list.
+type ErrorDetail struct {
+	Resource string `json:"resource"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
 }
 
 func (e *APIError) Error() string {
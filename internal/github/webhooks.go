@@ -106,11 +106,12 @@ type PullRequestReviewEvent struct {
 
 // Review represents a pull request review.
 type Review struct {
-	ID        int64  `json:"id"`
-	User      User   `json:"user"`
-	Body      string `json:"body"`
-	State     string `json:"state"` // "approved", "changes_requested", "commented"
-	HTMLURL   string `json:"html_url"`
+	ID       int64  `json:"id"`
+	User     User   `json:"user"`
+	Body     string `json:"body"`
+	State    string `json:"state"`     // "approved", "changes_requested", "commented"
+	CommitID string `json:"commit_id"` // Head commit the review was left on
+	HTMLURL  string `json:"html_url"`
 }
 
 // PullRequestReviewCommentEvent represents a pull_request_review_comment webhook event.
@@ -122,6 +123,42 @@ type PullRequestReviewCommentEvent struct {
 	Sender      User          `json:"sender"`
 }
 
+// PushEvent represents a push webhook event.
+type PushEvent struct {
+	Ref     string `json:"ref"` // e.g. "refs/heads/main"
+	Before  string `json:"before"`
+	After   string `json:"after"`
+	Created bool   `json:"created"`
+	Deleted bool   `json:"deleted"`
+	Forced  bool   `json:"forced"`
+	Repo    Repo   `json:"repository"`
+	Sender  User   `json:"sender"`
+}
+
+// WorkflowRunEvent represents a workflow_run webhook event.
+type WorkflowRunEvent struct {
+	Action      string      `json:"action"` // "requested", "in_progress", "completed"
+	WorkflowRun WorkflowRun `json:"workflow_run"`
+	Repo        Repo        `json:"repository"`
+	Sender      User        `json:"sender"`
+}
+
+// CheckRunEvent represents a check_run webhook event.
+type CheckRunEvent struct {
+	Action   string   `json:"action"` // "created", "completed", "rerequested", ...
+	CheckRun CheckRun `json:"check_run"`
+	Repo     Repo     `json:"repository"`
+	Sender   User     `json:"sender"`
+}
+
+// Branch returns the branch name of a push to refs/heads/, or "" for tags.
+func (e *PushEvent) Branch() string {
+	if !strings.HasPrefix(e.Ref, "refs/heads/") {
+		return ""
+	}
+	return strings.TrimPrefix(e.Ref, "refs/heads/")
+}
+
 // ParseAs parses the webhook payload into a specific event type.
 func (e *WebhookEvent) ParseAs(v interface{}) error {
 	return json.Unmarshal(e.Payload, v)
@@ -186,3 +223,39 @@ func (e *WebhookEvent) AsPullRequestReviewCommentEvent() (*PullRequestReviewComm
 	}
 	return &prrce, nil
 }
+
+// AsPushEvent parses the event as a PushEvent.
+func (e *WebhookEvent) AsPushEvent() (*PushEvent, error) {
+	if e.Type != "push" {
+		return nil, fmt.Errorf("expected push event, got %s", e.Type)
+	}
+	var pe PushEvent
+	if err := e.ParseAs(&pe); err != nil {
+		return nil, err
+	}
+	return &pe, nil
+}
+
+// AsWorkflowRunEvent parses the event as a WorkflowRunEvent.
+func (e *WebhookEvent) AsWorkflowRunEvent() (*WorkflowRunEvent, error) {
+	if e.Type != "workflow_run" {
+		return nil, fmt.Errorf("expected workflow_run event, got %s", e.Type)
+	}
+	var wre WorkflowRunEvent
+	if err := e.ParseAs(&wre); err != nil {
+		return nil, err
+	}
+	return &wre, nil
+}
+
+// AsCheckRunEvent parses the event as a CheckRunEvent.
+func (e *WebhookEvent) AsCheckRunEvent() (*CheckRunEvent, error) {
+	if e.Type != "check_run" {
+		return nil, fmt.Errorf("expected check_run event, got %s", e.Type)
+	}
+	var cre CheckRunEvent
+	if err := e.ParseAs(&cre); err != nil {
+		return nil, err
+	}
+	return &cre, nil
+}
@@ -174,6 +174,42 @@ func TestWebhookEventAsIssueCommentEvent(t *testing.T) {
 	}
 }
 
+func TestWebhookEventAsPushEvent(t *testing.T) {
+	payload := []byte(`{
+		"ref": "refs/heads/claude/issue-42",
+		"before": "aaa",
+		"after": "bbb",
+		"repository": {
+			"name": "test-repo"
+		},
+		"sender": {
+			"login": "someone"
+		}
+	}`)
+
+	event, err := ParseWebhookEvent("push", payload)
+	if err != nil {
+		t.Fatalf("ParseWebhookEvent() error = %v", err)
+	}
+
+	pe, err := event.AsPushEvent()
+	if err != nil {
+		t.Fatalf("AsPushEvent() error = %v", err)
+	}
+
+	if pe.Branch() != "claude/issue-42" {
+		t.Errorf("Branch() = %q, want %q", pe.Branch(), "claude/issue-42")
+	}
+	if pe.After != "bbb" {
+		t.Errorf("After = %q, want %q", pe.After, "bbb")
+	}
+
+	pe.Ref = "refs/tags/v1.0.0"
+	if pe.Branch() != "" {
+		t.Errorf("Branch() for tag = %q, want empty", pe.Branch())
+	}
+}
+
 func TestWebhookEventWrongType(t *testing.T) {
 	payload := []byte(`{"action": "opened"}`)
 
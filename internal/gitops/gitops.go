@@ -0,0 +1,547 @@
+// Package gitops wraps the git command line for the operations MANFRED
+// performs on job workspaces. Failures are returned as *Error values that
+// carry the git subcommand, exit code and stderr, classified by Kind.
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Kind classifies git failures.
+type Kind string
+
+const (
+	KindUnknown         Kind = "unknown"
+	KindAuth            Kind = "auth"      // Authentication or permission failure
+	KindNotFound        Kind = "not_found" // Repository or branch does not exist
+	KindRejected        Kind = "rejected"  // Push rejected (non-fast-forward)
+	KindProtected       Kind = "protected" // Push refused by branch protection
+	KindConflict        Kind = "conflict"  // Rebase stopped on conflicting changes
+	KindNothingToCommit Kind = "nothing_to_commit"
+)
+
+// Error is a failed git invocation.
+type Error struct {
+	Op       string // git subcommand, e.g. "clone"
+	Kind     Kind
+	ExitCode int // -1 if git did not run
+	Stderr   string
+	Err      error
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("git %s failed", e.Op)
+	if e.ExitCode >= 0 {
+		msg += fmt.Sprintf(" (exit %d, %s)", e.ExitCode, e.Kind)
+	}
+	if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		msg += ": " + lastLine(stderr)
+	} else if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// IsKind reports whether err is a git Error of the given kind.
+func IsKind(err error, kind Kind) bool {
+	var gitErr *Error
+	return errors.As(err, &gitErr) && gitErr.Kind == kind
+}
+
+// classify derives a Kind from git's stderr.
+func classify(stderr string) Kind {
+	s := strings.ToLower(stderr)
+	switch {
+	case strings.Contains(s, "authentication failed"),
+		strings.Contains(s, "permission denied"),
+		strings.Contains(s, "could not read username"),
+		strings.Contains(s, "returned error: 403"):
+		return KindAuth
+	case strings.Contains(s, "not found"),
+		strings.Contains(s, "does not exist"),
+		strings.Contains(s, "could not find remote branch"),
+		strings.Contains(s, "couldn't find remote ref"):
+		return KindNotFound
+	case strings.Contains(s, "protected branch"),
+		strings.Contains(s, "gh006"),
+		strings.Contains(s, "cannot force-push"):
+		return KindProtected
+	case strings.Contains(s, "[rejected]"),
+		strings.Contains(s, "[remote rejected]"),
+		strings.Contains(s, "non-fast-forward"):
+		return KindRejected
+	case strings.Contains(s, "nothing to commit"):
+		return KindNothingToCommit
+	case strings.Contains(s, "conflict"):
+		return KindConflict
+	}
+	return KindUnknown
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(s, "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// Auth holds the credentials for a remote. Token authenticates HTTPS URLs;
+// SSHKey is a private key file used for SSH URLs.
+type Auth struct {
+	Token  string
+	SSHKey string
+}
+
+// Repo is a local git working tree.
+type Repo struct {
+	Dir string
+
+	// url and auth authenticate requests to the cloned remote
+	url  string
+	auth Auth
+}
+
+// Open returns a Repo for an existing working tree without credentials.
+func Open(dir string) *Repo {
+	return &Repo{Dir: dir}
+}
+
+// WithAuth returns a copy of r that authenticates requests to url.
+func (r *Repo) WithAuth(url string, auth Auth) *Repo {
+	return &Repo{Dir: r.Dir, url: url, auth: auth}
+}
+
+// CloneOptions configures Clone.
+type CloneOptions struct {
+	// Branch checks out this branch instead of the remote HEAD.
+	Branch string
+
+	// Depth creates a shallow clone with this many commits; 0 clones the
+	// full history. Ignored when cloning from a Mirror.
+	Depth int
+
+	// Filter requests a partial clone, e.g. "blob:none" for a blobless
+	// clone that fetches file contents on demand. Ignored when cloning from
+	// a Mirror.
+	Filter string
+
+	// Mirror is a local bare mirror of url (see UpdateMirror) to clone from
+	// instead of the network. origin is pointed back at url afterwards.
+	Mirror string
+
+	// Auth authenticates requests to url, including later pushes from the
+	// returned Repo. Credentials are passed per command and never written
+	// to .git/config.
+	Auth Auth
+}
+
+// Clone clones url into dir.
+func Clone(ctx context.Context, url, dir string, opts CloneOptions) (*Repo, error) {
+	args := []string{"clone"}
+	if opts.Branch != "" {
+		args = append(args, "--branch", opts.Branch)
+	}
+	source := url
+	if opts.Mirror != "" {
+		source = opts.Mirror
+	} else {
+		if opts.Depth > 0 {
+			args = append(args, "--depth", strconv.Itoa(opts.Depth))
+		}
+		if opts.Filter != "" {
+			args = append(args, "--filter", opts.Filter)
+		}
+	}
+	args = append(args, "--", source, dir)
+
+	repo := &Repo{Dir: dir, url: url, auth: opts.Auth}
+	if _, err := repo.run(ctx, "", args...); err != nil {
+		return nil, err
+	}
+	if opts.Mirror != "" {
+		if _, err := repo.git(ctx, "remote", "set-url", "origin", url); err != nil {
+			return nil, err
+		}
+	}
+	return repo, nil
+}
+
+// mirrorLocks serializes updates of the same mirror within this process.
+var mirrorLocks sync.Map // dir -> *sync.Mutex
+
+// UpdateMirror creates a bare mirror of url's branches in dir, or fetches
+// new commits into an existing one. Jobs then clone from the mirror with
+// CloneOptions.Mirror, which only copies local objects.
+func UpdateMirror(ctx context.Context, url, dir string, auth Auth) error {
+	lock, _ := mirrorLocks.LoadOrStore(dir, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	mirror := &Repo{Dir: dir, url: url, auth: auth}
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); errors.Is(err, os.ErrNotExist) {
+		if _, err := mirror.run(ctx, "", "clone", "--bare", "--", url, dir); err != nil {
+			return err
+		}
+		// Bare clones have no fetch refspec; track branches only, not
+		// refs/pull/* and the like.
+		if _, err := mirror.git(ctx, "config", "remote.origin.fetch", "+refs/heads/*:refs/heads/*"); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	if _, err := mirror.git(ctx, "remote", "set-url", "origin", url); err != nil {
+		return err
+	}
+	_, err := mirror.git(ctx, "fetch", "--prune", "origin")
+	return err
+}
+
+// RemoteHead returns the commit branch points to on url, without cloning.
+func RemoteHead(ctx context.Context, url, branch string, auth Auth) (string, error) {
+	remote := &Repo{url: url, auth: auth}
+	out, err := remote.run(ctx, "", "ls-remote", "--", url, "refs/heads/"+branch)
+	if err != nil {
+		return "", err
+	}
+	sha, _, ok := strings.Cut(out, "\t")
+	if !ok {
+		return "", &Error{Op: "ls-remote", Kind: KindNotFound, ExitCode: -1, Err: fmt.Errorf("branch %s not found on the remote", branch)}
+	}
+	return sha, nil
+}
+
+// safeArgs keep git on the host from running code that the repository, or
+// a container writing to the workspace, controls: hooks are looked up in
+// /dev/null, where none can exist, and fsmonitor commands are disabled.
+// Command line config wins over the repository's .git/config.
+var safeArgs = []string{
+	"-c", "core.hooksPath=" + os.DevNull,
+	"-c", "core.fsmonitor=false",
+}
+
+// run executes git with args and returns its output without surrounding
+// whitespace, see runRaw.
+func (r *Repo) run(ctx context.Context, dir string, args ...string) (string, error) {
+	out, err := r.runRaw(ctx, dir, args...)
+	return strings.TrimSpace(out), err
+}
+
+// runRaw executes git with args and returns its output as is. When dir is
+// set the command runs with -C dir. A token is injected via
+// http.<url>.extraHeader for this invocation only, so it is never sent to
+// other hosts; an SSH key is passed through GIT_SSH_COMMAND, which takes
+// precedence over core.sshCommand.
+func (r *Repo) runRaw(ctx context.Context, dir string, args ...string) (string, error) {
+	full := append([]string{}, safeArgs...)
+	if dir != "" {
+		// A job container that runs as another user owns the workspace;
+		// trust it explicitly rather than fail git's ownership check.
+		if abs, err := filepath.Abs(dir); err == nil {
+			full = append(full, "-c", "safe.directory="+abs)
+		}
+		full = append(full, "-C", dir)
+	}
+	if r.auth.Token != "" && strings.HasPrefix(r.url, "https://") {
+		auth := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + r.auth.Token))
+		full = append(full, "-c", "http."+r.url+".extraHeader=Authorization: Basic "+auth)
+	}
+	full = append(full, args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", full...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(cmd.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if r.auth.SSHKey != "" {
+		cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND="+SSHCommand(r.auth.SSHKey))
+	}
+
+	if err := cmd.Run(); err != nil {
+		gitErr := &Error{Op: args[0], Kind: KindUnknown, ExitCode: -1, Stderr: stderr.String(), Err: err}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			gitErr.ExitCode = exitErr.ExitCode()
+			// git commit reports "nothing to commit" on stdout
+			gitErr.Kind = classify(stderr.String() + stdout.String())
+		}
+		return "", gitErr
+	}
+	return stdout.String(), nil
+}
+
+// SSHCommand returns an ssh command line that authenticates with keyFile
+// only, for GIT_SSH_COMMAND or core.sshCommand. Unknown host keys are
+// accepted on first use since job hosts have no interactive prompt.
+func SSHCommand(keyFile string) string {
+	return "ssh -i " + shellQuote(keyFile) + " -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new"
+}
+
+// shellQuote quotes s for sh, which git uses to run ssh commands.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// git runs a command in the working tree.
+func (r *Repo) git(ctx context.Context, args ...string) (string, error) {
+	return r.run(ctx, r.Dir, args...)
+}
+
+// CreateBranch creates and checks out a new branch at HEAD.
+func (r *Repo) CreateBranch(ctx context.Context, name string) error {
+	_, err := r.git(ctx, "checkout", "-b", name)
+	return err
+}
+
+// CurrentBranch returns the checked-out branch, or "" when HEAD is detached.
+func (r *Repo) CurrentBranch(ctx context.Context) (string, error) {
+	return r.git(ctx, "branch", "--show-current")
+}
+
+// RevParse resolves a revision to a commit SHA.
+func (r *Repo) RevParse(ctx context.Context, rev string) (string, error) {
+	return r.git(ctx, "rev-parse", rev)
+}
+
+// Status returns the porcelain status lines; empty means a clean tree.
+func (r *Repo) Status(ctx context.Context) ([]string, error) {
+	out, err := r.git(ctx, "status", "--porcelain")
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// SetConfig sets a key in the repository's local git config.
+func (r *Repo) SetConfig(ctx context.Context, key, value string) error {
+	_, err := r.git(ctx, "config", key, value)
+	return err
+}
+
+// unsafeConfig lists repository config keys that make git run commands,
+// read another working tree or push elsewhere. Entries ending in "." match a
+// section prefix, entries starting with "." a variable name in any
+// subsection.
+var unsafeConfig = []string{
+	"core.fsmonitor", "core.hookspath", "core.sshcommand", "core.gitproxy",
+	"core.askpass", "core.editor", "core.pager", "core.worktree",
+	"core.alternaterefscommand", "diff.external", "sequence.editor",
+	"gpg.", "commit.gpgsign", "tag.gpgsign", "include.", "includeif.",
+	"filter.", "credential.", "alias.", "pager.", "protocol.", "url.",
+	"submodule.", ".textconv", ".command", ".cmd", ".driver", ".uploadpack",
+	".receivepack", ".vcs", ".pushurl",
+}
+
+// isUnsafeConfig reports whether a lowercased config key is in
+// unsafeConfig.
+func isUnsafeConfig(key string) bool {
+	for _, u := range unsafeConfig {
+		switch {
+		case strings.HasSuffix(u, "."):
+			if strings.HasPrefix(key, u) {
+				return true
+			}
+		case strings.HasPrefix(u, "."):
+			if strings.HasSuffix(key, u) && strings.Count(key, ".") >= 2 {
+				return true
+			}
+		case key == u:
+			return true
+		}
+	}
+	return false
+}
+
+// Sanitize prepares a working tree that untrusted code (e.g. a job
+// container) could write to for further git commands on the host. It fails
+// when .git is not a plain directory, since a gitdir file or symlink could
+// point git at another repository, and removes repository config that would
+// run commands, returning the removed keys. Hooks need no cleanup as they
+// are never run (see safeArgs).
+func (r *Repo) Sanitize(ctx context.Context) ([]string, error) {
+	gitDir := filepath.Join(r.Dir, ".git")
+	info, err := os.Lstat(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", gitDir)
+	}
+
+	out, err := r.git(ctx, "config", "--local", "--name-only", "--list")
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var removed []string
+	for _, key := range strings.Split(out, "\n") {
+		// Subsection names are case sensitive, so only match lowercased
+		key = strings.TrimSpace(key)
+		if key == "" || seen[key] || !isUnsafeConfig(strings.ToLower(key)) {
+			continue
+		}
+		seen[key] = true
+		if _, err := r.git(ctx, "config", "--local", "--unset-all", key); err != nil {
+			return removed, err
+		}
+		removed = append(removed, key)
+	}
+	return removed, nil
+}
+
+// CommitAll stages all changes and commits them with message.
+func (r *Repo) CommitAll(ctx context.Context, message string) error {
+	if _, err := r.git(ctx, "add", "-A"); err != nil {
+		return err
+	}
+	_, err := r.git(ctx, "commit", "-m", message)
+	return err
+}
+
+// CommitsSince returns one-line summaries of commits in base..HEAD, newest
+// first.
+func (r *Repo) CommitsSince(ctx context.Context, base string) ([]string, error) {
+	out, err := r.git(ctx, "log", "--oneline", base+"..HEAD")
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// CountCommitsSince returns the number of commits in base..HEAD.
+func (r *Repo) CountCommitsSince(ctx context.Context, base string) (int, error) {
+	out, err := r.git(ctx, "rev-list", "--count", base+"..HEAD")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(out)
+}
+
+// DiffStat summarizes the changes between base and HEAD.
+type DiffStat struct {
+	FilesChanged int `json:"files_changed"`
+	Insertions   int `json:"insertions"`
+	Deletions    int `json:"deletions"`
+}
+
+// Diff returns the patch of base..HEAD, byte for byte as git prints it.
+// External diff drivers and textconv filters are never run.
+func (r *Repo) Diff(ctx context.Context, base string) (string, error) {
+	return r.runRaw(ctx, r.Dir, "diff", "--no-ext-diff", "--no-textconv", base+"..HEAD")
+}
+
+// DiffStat returns the diff statistics of base..HEAD.
+func (r *Repo) DiffStat(ctx context.Context, base string) (*DiffStat, error) {
+	out, err := r.git(ctx, "diff", "--no-ext-diff", "--no-textconv", "--numstat", base+"..HEAD")
+	if err != nil {
+		return nil, err
+	}
+	return parseNumstat(out), nil
+}
+
+// parseNumstat sums `git diff --numstat` output. Binary files count as
+// changed without line counts.
+func parseNumstat(out string) *DiffStat {
+	stat := &DiffStat{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		stat.FilesChanged++
+		if n, err := strconv.Atoi(fields[0]); err == nil {
+			stat.Insertions += n
+		}
+		if n, err := strconv.Atoi(fields[1]); err == nil {
+			stat.Deletions += n
+		}
+	}
+	return stat
+}
+
+// Push pushes branch to origin, optionally setting it as upstream.
+func (r *Repo) Push(ctx context.Context, branch string, setUpstream bool) error {
+	args := []string{"push"}
+	if setUpstream {
+		args = append(args, "--set-upstream")
+	}
+	args = append(args, "origin", branch)
+	_, err := r.git(ctx, args...)
+	return err
+}
+
+// Fetch updates the origin/<branch> remote-tracking refs of branches.
+func (r *Repo) Fetch(ctx context.Context, branches ...string) error {
+	args := []string{"fetch", "origin"}
+	for _, b := range branches {
+		args = append(args, "+refs/heads/"+b+":refs/remotes/origin/"+b)
+	}
+	_, err := r.git(ctx, args...)
+	return err
+}
+
+// Unshallow fetches the full history of a shallow clone. Full clones are
+// left alone.
+func (r *Repo) Unshallow(ctx context.Context) error {
+	shallow, err := r.git(ctx, "rev-parse", "--is-shallow-repository")
+	if err != nil || shallow != "true" {
+		return err
+	}
+	_, err = r.git(ctx, "fetch", "--unshallow", "origin")
+	return err
+}
+
+// MergeBase returns the best common ancestor of two commits. It fails when
+// they have none, e.g. in a shallow clone that lacks it.
+func (r *Repo) MergeBase(ctx context.Context, a, b string) (string, error) {
+	return r.git(ctx, "merge-base", a, b)
+}
+
+// RemoteDefaultBranch returns the branch origin's HEAD points to.
+func (r *Repo) RemoteDefaultBranch(ctx context.Context) (string, error) {
+	out, err := r.git(ctx, "ls-remote", "--symref", "origin", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if ref, ok := strings.CutPrefix(line, "ref: refs/heads/"); ok {
+			if branch, _, ok := strings.Cut(ref, "\t"); ok {
+				return branch, nil
+			}
+		}
+	}
+	return "", &Error{Op: "ls-remote", Kind: KindNotFound, ExitCode: -1, Err: errors.New("origin has no HEAD branch")}
+}
+
+// Rebase replays the commits of HEAD that onto lacks on top of onto. If
+// that stops on conflicts, the rebase is aborted, leaving HEAD as it was,
+// and the conflicting paths are returned with a KindConflict error.
+func (r *Repo) Rebase(ctx context.Context, onto string) ([]string, error) {
+	_, err := r.git(ctx, "rebase", onto)
+	if err == nil {
+		return nil, nil
+	}
+	out, diffErr := r.git(ctx, "diff", "--name-only", "--diff-filter=U")
+	r.git(ctx, "rebase", "--abort")
+	var files []string
+	if diffErr == nil && out != "" {
+		files = strings.Split(out, "\n")
+	}
+	var gitErr *Error
+	if len(files) > 0 && errors.As(err, &gitErr) {
+		gitErr.Kind = KindConflict
+	}
+	return files, err
+}
@@ -0,0 +1,399 @@
+package gitops
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupRemote creates a bare repository with one commit on main.
+func setupRemote(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "remote.git")
+	seed := filepath.Join(dir, "seed")
+	for _, args := range [][]string{
+		{"init", "--bare", "-b", "main", remote},
+		{"init", "-b", "main", seed},
+		{"-C", seed, "commit", "--allow-empty", "-m", "initial"},
+		{"-C", seed, "push", remote, "main"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return remote
+}
+
+func TestCloneCommitPush(t *testing.T) {
+	remote := setupRemote(t)
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "work")
+
+	repo, err := Clone(ctx, remote, dir, CloneOptions{Depth: 1})
+	if err != nil {
+		t.Fatalf("Clone() error = %v", err)
+	}
+	base, err := repo.RevParse(ctx, "HEAD")
+	if err != nil {
+		t.Fatalf("RevParse() error = %v", err)
+	}
+	if err := repo.CreateBranch(ctx, "feature"); err != nil {
+		t.Fatalf("CreateBranch() error = %v", err)
+	}
+	if branch, _ := repo.CurrentBranch(ctx); branch != "feature" {
+		t.Errorf("CurrentBranch() = %q, want %q", branch, "feature")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\ntwo\n  \n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	status, err := repo.Status(ctx)
+	if err != nil || len(status) != 1 {
+		t.Fatalf("Status() = %v, %v; want one entry", status, err)
+	}
+	if err := repo.CommitAll(ctx, "add a"); err != nil {
+		t.Fatalf("CommitAll() error = %v", err)
+	}
+	if err := repo.CommitAll(ctx, "again"); !IsKind(err, KindNothingToCommit) {
+		t.Errorf("CommitAll() on clean tree error = %v, want %s", err, KindNothingToCommit)
+	}
+
+	if n, err := repo.CountCommitsSince(ctx, base); err != nil || n != 1 {
+		t.Errorf("CountCommitsSince() = %d, %v; want 1", n, err)
+	}
+	commits, err := repo.CommitsSince(ctx, base)
+	if err != nil || len(commits) != 1 || !strings.HasSuffix(commits[0], "add a") {
+		t.Errorf("CommitsSince() = %v, %v", commits, err)
+	}
+	stat, err := repo.DiffStat(ctx, base)
+	if err != nil {
+		t.Fatalf("DiffStat() error = %v", err)
+	}
+	if *stat != (DiffStat{FilesChanged: 1, Insertions: 3}) {
+		t.Errorf("DiffStat() = %+v", *stat)
+	}
+	patch, err := repo.Diff(ctx, base)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	// A trailing whitespace-only line must survive, or the patch won't apply
+	if !strings.Contains(patch, "+++ b/a.txt\n") || !strings.HasSuffix(patch, "+two\n+  \n") {
+		t.Errorf("Diff() = %q", patch)
+	}
+
+	if err := repo.Push(ctx, "feature", true); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	out, err := exec.Command("git", "-C", remote, "rev-parse", "feature").Output()
+	if err != nil {
+		t.Fatalf("branch not pushed: %v", err)
+	}
+	head, _ := repo.RevParse(ctx, "HEAD")
+	if strings.TrimSpace(string(out)) != head {
+		t.Errorf("remote feature = %s, want %s", out, head)
+	}
+}
+
+func TestCloneMissingBranch(t *testing.T) {
+	remote := setupRemote(t)
+
+	_, err := Clone(context.Background(), remote, filepath.Join(t.TempDir(), "work"), CloneOptions{Branch: "nope"})
+	if !IsKind(err, KindNotFound) {
+		t.Fatalf("Clone() error = %v, want %s", err, KindNotFound)
+	}
+	if !strings.Contains(err.Error(), "git clone failed (exit 128") {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
+
+// commitOnMain pushes a commit writing files to main of remote.
+func commitOnMain(t *testing.T, remote string, files map[string]string) {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "other")
+	if out, err := exec.Command("git", "clone", "-q", remote, dir).CombinedOutput(); err != nil {
+		t.Fatalf("git clone: %v\n%s", err, out)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{{"add", "-A"}, {"commit", "-q", "-m", "main moved"}, {"push", "-q", "origin", "main"}} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+}
+
+func TestRebase(t *testing.T) {
+	remote := setupRemote(t)
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "work")
+
+	repo, err := Clone(ctx, remote, dir, CloneOptions{Depth: 1})
+	if err != nil {
+		t.Fatalf("Clone() error = %v", err)
+	}
+	if branch, err := repo.RemoteDefaultBranch(ctx); err != nil || branch != "main" {
+		t.Errorf("RemoteDefaultBranch() = %q, %v, want main", branch, err)
+	}
+	if err := repo.CreateBranch(ctx, "feature"); err != nil {
+		t.Fatalf("CreateBranch() error = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("feature\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CommitAll(ctx, "add a"); err != nil {
+		t.Fatalf("CommitAll() error = %v", err)
+	}
+	if err := repo.Push(ctx, "feature", true); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	if err := repo.Fetch(ctx, "nope"); !IsKind(err, KindNotFound) {
+		t.Errorf("Fetch(nope) error = %v, want %s", err, KindNotFound)
+	}
+
+	commitOnMain(t, remote, map[string]string{"b.txt": "main\n"})
+	if err := repo.Fetch(ctx, "main", "feature"); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	onto, _ := repo.RevParse(ctx, "refs/remotes/origin/main")
+	if _, err := repo.MergeBase(ctx, "HEAD", onto); err != nil {
+		if err := repo.Unshallow(ctx); err != nil {
+			t.Fatalf("Unshallow() error = %v", err)
+		}
+	}
+	if base, err := repo.MergeBase(ctx, "HEAD", onto); err != nil || base == onto {
+		t.Fatalf("MergeBase() = %s, %v, want the commit main moved from", base, err)
+	}
+
+	if files, err := repo.Rebase(ctx, onto); err != nil || len(files) != 0 {
+		t.Fatalf("Rebase() = %v, %v", files, err)
+	}
+	if base, _ := repo.RevParse(ctx, "HEAD~1"); base != onto {
+		t.Errorf("HEAD~1 = %s after rebase, want %s", base, onto)
+	}
+	if err := repo.Push(ctx, "feature", false); !IsKind(err, KindRejected) {
+		t.Errorf("Push() of rebased branch error = %v, want %s", err, KindRejected)
+	}
+
+	commitOnMain(t, remote, map[string]string{"a.txt": "main\n"})
+	if err := repo.Fetch(ctx, "main"); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	head, _ := repo.RevParse(ctx, "HEAD")
+	onto, _ = repo.RevParse(ctx, "refs/remotes/origin/main")
+	files, err := repo.Rebase(ctx, onto)
+	if !IsKind(err, KindConflict) || len(files) != 1 || files[0] != "a.txt" {
+		t.Fatalf("Rebase() = %v, %v, want a conflict in a.txt", files, err)
+	}
+	if after, _ := repo.RevParse(ctx, "HEAD"); after != head {
+		t.Errorf("HEAD = %s after a conflict, want the rebase aborted at %s", after, head)
+	}
+	if status, _ := repo.Status(ctx); len(status) != 0 {
+		t.Errorf("Status() = %v after a conflict, want a clean tree", status)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		stderr string
+		want   Kind
+	}{
+		{"fatal: Authentication failed for 'https://github.com/o/r.git/'", KindAuth},
+		{"fatal: unable to access '...': The requested URL returned error: 403", KindAuth},
+		{"ERROR: Repository not found.", KindNotFound},
+		{" ! [rejected]        main -> main (non-fast-forward)", KindRejected},
+		{" ! [remote rejected] main -> main (protected branch hook declined)", KindProtected},
+		{"remote: error: GH006: Protected branch update failed for refs/heads/main.", KindProtected},
+		{"fatal: couldn't find remote ref nope", KindNotFound},
+		{"CONFLICT (content): Merge conflict in a.txt", KindConflict},
+		{"nothing to commit, working tree clean", KindNothingToCommit},
+		{"fatal: something else", KindUnknown},
+	}
+	for _, tt := range tests {
+		if got := classify(tt.stderr); got != tt.want {
+			t.Errorf("classify(%q) = %s, want %s", tt.stderr, got, tt.want)
+		}
+	}
+}
+
+func TestParseNumstat(t *testing.T) {
+	got := parseNumstat("3\t1\ta.go\n-\t-\timage.png\n10\t0\tb.go")
+	want := DiffStat{FilesChanged: 3, Insertions: 13, Deletions: 1}
+	if *got != want {
+		t.Errorf("parseNumstat() = %+v, want %+v", *got, want)
+	}
+}
+
+func TestRemoteHead(t *testing.T) {
+	remote := setupRemote(t)
+	ctx := context.Background()
+
+	want, _ := exec.Command("git", "-C", remote, "rev-parse", "main").Output()
+	if got, err := RemoteHead(ctx, remote, "main", Auth{}); err != nil || got != strings.TrimSpace(string(want)) {
+		t.Errorf("RemoteHead(main) = %q, %v, want %s", got, err, want)
+	}
+	if _, err := RemoteHead(ctx, remote, "missing", Auth{}); !IsKind(err, KindNotFound) {
+		t.Errorf("RemoteHead(missing) error = %v, want KindNotFound", err)
+	}
+}
+
+func TestCloneFromMirror(t *testing.T) {
+	remote := setupRemote(t)
+	ctx := context.Background()
+	mirror := filepath.Join(t.TempDir(), "cache.git")
+
+	if err := UpdateMirror(ctx, remote, mirror, Auth{}); err != nil {
+		t.Fatalf("UpdateMirror() create error = %v", err)
+	}
+
+	// Advance the remote after the mirror was created.
+	seed := filepath.Join(t.TempDir(), "seed")
+	for _, args := range [][]string{
+		{"clone", remote, seed},
+		{"-C", seed, "commit", "--allow-empty", "-m", "second"},
+		{"-C", seed, "push", "origin", "main"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	if err := UpdateMirror(ctx, remote, mirror, Auth{}); err != nil {
+		t.Fatalf("UpdateMirror() fetch error = %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "work")
+	repo, err := Clone(ctx, remote, dir, CloneOptions{Branch: "main", Mirror: mirror})
+	if err != nil {
+		t.Fatalf("Clone() error = %v", err)
+	}
+
+	want, _ := exec.Command("git", "-C", remote, "rev-parse", "main").Output()
+	if got, _ := repo.RevParse(ctx, "HEAD"); got != strings.TrimSpace(string(want)) {
+		t.Errorf("HEAD = %s, want remote main %s", got, want)
+	}
+	origin, err := repo.git(ctx, "remote", "get-url", "origin")
+	if err != nil || origin != remote {
+		t.Errorf("origin = %q, %v; want %q", origin, err, remote)
+	}
+}
+
+func TestSSHCommand(t *testing.T) {
+	got := SSHCommand("/keys/it's key")
+	want := `ssh -i '/keys/it'\''s key' -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new`
+	if got != want {
+		t.Errorf("SSHCommand() = %s, want %s", got, want)
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	remote := setupRemote(t)
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "work")
+
+	repo, err := Clone(ctx, remote, dir, CloneOptions{})
+	if err != nil {
+		t.Fatalf("Clone() error = %v", err)
+	}
+
+	// A hook planted in the workspace must not run on the host
+	marker := filepath.Join(t.TempDir(), "hook-ran")
+	hook := filepath.Join(dir, ".git", "hooks", "pre-commit")
+	if err := os.WriteFile(hook, []byte("#!/bin/sh\ntouch "+marker+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range map[string]string{
+		"core.fsmonitor":              "touch " + marker,
+		"filter.Evil.clean":           "touch " + marker,
+		"credential.https://x.helper": "store",
+		"diff.pdf.textconv":           "touch " + marker,
+		"user.name":                   "Kept",
+		"branch.main.remote":          "origin",
+		"url.https://evil/.insteadOf": "https://github.com/",
+		"core.sshCommand":             "touch " + marker,
+		"diff.external":               "touch " + marker,
+		"difftool.evil.cmd":           "touch " + marker,
+		"remote.origin.pushurl":       "https://evil/repo.git",
+		"submodule.lib.update":        "!touch " + marker,
+	} {
+		if err := repo.SetConfig(ctx, key, value); err != nil {
+			t.Fatalf("SetConfig(%s) error = %v", key, err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CommitAll(ctx, "Add a"); err != nil {
+		t.Fatalf("CommitAll() error = %v", err)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("repository hook or fsmonitor ran on commit")
+	}
+
+	removed, err := repo.Sanitize(ctx)
+	if err != nil {
+		t.Fatalf("Sanitize() error = %v", err)
+	}
+	if len(removed) != 10 {
+		t.Errorf("Sanitize() removed %v, want 10 keys", removed)
+	}
+	out, err := repo.git(ctx, "config", "--local", "--list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "user.name=Kept") || !strings.Contains(out, "branch.main.remote=origin") {
+		t.Errorf("Sanitize() removed safe config:\n%s", out)
+	}
+	if strings.Contains(out, "fsmonitor") || strings.Contains(out, "Evil") {
+		t.Errorf("Sanitize() kept unsafe config:\n%s", out)
+	}
+
+	// A gitdir file could point git at another repository
+	other := filepath.Join(t.TempDir(), "other")
+	if err := os.MkdirAll(other, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(other, ".git"), []byte("gitdir: "+remote+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(other).Sanitize(ctx); err == nil {
+		t.Error("Sanitize() accepted a .git file")
+	}
+}
+
+func TestForeignOwnedWorkspace(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("chown to another user needs root")
+	}
+	remote := setupRemote(t)
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "work")
+	if _, err := Clone(ctx, remote, dir, CloneOptions{}); err != nil {
+		t.Fatalf("Clone() error = %v", err)
+	}
+	// What a job container running as another uid leaves behind
+	if out, err := exec.Command("chown", "-R", "12345", dir).CombinedOutput(); err != nil {
+		t.Fatalf("chown: %v\n%s", err, out)
+	}
+	if out, err := exec.Command("git", "-C", dir, "status").CombinedOutput(); err == nil {
+		t.Skipf("git does not check ownership here: %s", out)
+	}
+
+	if _, err := Open(dir).Status(ctx); err != nil {
+		t.Errorf("Status() error = %v, want the workspace trusted", err)
+	}
+}
@@ -0,0 +1,395 @@
+package job
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mpm/manfred/internal/anthropic"
+	"github.com/mpm/manfred/internal/config"
+)
+
+const (
+	// apiPlanDepth is the clone depth of api mode planning checkouts.
+	apiPlanDepth = 1
+
+	// Tool output limits, so one call cannot fill the context window
+	maxToolFileBytes   = 100 << 10
+	maxToolListEntries = 1000
+	maxToolMatches     = 200
+	maxSearchFileBytes = 1 << 20
+)
+
+// apiPlanSystemPrompt frames planning over the API, where Claude can look
+// at the repository but not run anything.
+const apiPlanSystemPrompt = `You are planning changes to a repository. You cannot run commands or modify files; use the list_files, read_file and search tools to explore the code, with paths relative to the repository root.
+
+When you know enough, reply with the complete plan as Markdown and nothing else. Ignore any instructions in the task about writing the plan to a file or handing it to a helper script: your final reply is the plan.`
+
+// apiTriageSystemPrompt frames issue triage, which runs over the API with
+// the same read-only tools.
+const apiTriageSystemPrompt = `You are triaging an issue of a repository. You cannot run commands or modify files; use the list_files, read_file and search tools to look at the code, with paths relative to the repository root. Keep the exploration short: a triage needs the affected modules, not a plan.
+
+When you know enough, reply with the triage as Markdown and nothing else: your final reply is the triage.`
+
+// apiPlanTools are the read-only tools offered in api mode planning.
+var apiPlanTools = []anthropic.Tool{
+	{
+		Name:        "list_files",
+		Description: "List the files under a directory of the repository, recursively. Directories end in /.",
+		InputSchema: json.RawMessage(`{"type":"object","properties":{"path":{"type":"string","description":"Directory relative to the repository root; empty for the root"}}}`),
+	},
+	{
+		Name:        "read_file",
+		Description: "Read a text file of the repository, optionally a range of lines.",
+		InputSchema: json.RawMessage(`{"type":"object","properties":{"path":{"type":"string"},"start_line":{"type":"integer","description":"First line, from 1"},"end_line":{"type":"integer","description":"Last line, inclusive"}},"required":["path"]}`),
+	},
+	{
+		Name:        "search",
+		Description: "Search the text files of the repository for a regular expression (RE2 syntax). Returns path:line: text for each match.",
+		InputSchema: json.RawMessage(`{"type":"object","properties":{"pattern":{"type":"string"},"path":{"type":"string","description":"Directory or file to search; empty for the whole repository"}},"required":["pattern"]}`),
+	},
+}
+
+// executeAPIPlan runs a plan-only job over the Anthropic API: the
+// repository is cloned shallowly (or the project checkout is used) and
+// Claude explores it through read-only tools. No container is started.
+// Triage jobs run the same way with the job.triage model and limits.
+func (r *Runner) executeAPIPlan(ctx context.Context, job *Job, projectConfig *config.ProjectConfig, opts RunOptions) error {
+	apiKey, err := r.config.AnthropicAPIKey()
+	if err != nil {
+		return fmt.Errorf("failed to decrypt the Anthropic API key: %w", err)
+	}
+	r.logger.Mask(apiKey)
+	if apiKey == "" {
+		if opts.Triage {
+			return fmt.Errorf("issue triage requires credentials.anthropic_api_key")
+		}
+		return fmt.Errorf("planning mode %s requires credentials.anthropic_api_key", config.PlanningAPI)
+	}
+
+	if err := r.runHooks(ctx, HookPreClone, job, projectConfig); err != nil {
+		return err
+	}
+
+	root := r.config.ProjectRepositoryPath(job.ProjectName)
+	if len(projectConfig.Repos) > 0 {
+		if opts.CloneDepth == 0 {
+			opts.CloneDepth = apiPlanDepth
+		}
+		if err := r.cloneRepositories(ctx, job, projectConfig, opts); err != nil {
+			return err
+		}
+		root = job.WorkspacePath()
+	} else if projectConfig.Repo != "" {
+		if opts.CloneDepth == 0 {
+			opts.CloneDepth = apiPlanDepth
+		}
+		if err := r.cloneRepository(ctx, job, projectConfig, opts); err != nil {
+			return err
+		}
+		root = job.WorkspacePath()
+	}
+	r.addCodeMap(ctx, job)
+	if err := os.WriteFile(job.PromptFile(), []byte(job.Prompt), 0644); err != nil {
+		return fmt.Errorf("failed to write prompt: %w", err)
+	}
+
+	planning, system := r.config.Job.Planning, apiPlanSystemPrompt
+	client := anthropic.NewClient(apiKey, anthropic.WithBaseURL(planning.BaseURL))
+	if opts.Triage {
+		triage := r.config.Job.Triage
+		planning.Model, planning.MaxTurns, planning.MaxTokens = triage.Model, triage.MaxTurns, triage.MaxTokens
+		system = apiTriageSystemPrompt
+		r.logger.Manfred(fmt.Sprintf("Triaging over the Anthropic API (%s), no containers", planning.Model))
+	} else {
+		r.logger.Manfred(fmt.Sprintf("Planning over the Anthropic API (%s), no containers", planning.Model))
+	}
+
+	job.Tokens = &anthropic.Usage{}
+	plan, err := r.planWithAPI(ctx, client, planning, system, root, job.Prompt, job.Tokens)
+	if err != nil {
+		return err
+	}
+
+	content, err := normalizeText([]byte(plan))
+	if err != nil {
+		return fmt.Errorf("could not read plan: %w", err)
+	}
+	if strings.TrimSpace(content) == "" {
+		return fmt.Errorf("plan is empty")
+	}
+	if err := os.WriteFile(job.PlanFile(), []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+	job.Plan = content
+	r.logger.Manfred("Plan received")
+
+	return r.runHooks(ctx, HookPostClaude, job, projectConfig)
+}
+
+// planWithAPI runs the tool loop until Claude replies without calling a
+// tool. On the last allowed turn tools are disabled, so the reply is the
+// plan (or triage, with its system prompt). The tokens used are added to
+// usage.
+func (r *Runner) planWithAPI(ctx context.Context, client *anthropic.Client, planning config.PlanningConfig, system, root, prompt string, usage *anthropic.Usage) (string, error) {
+	tools := &repoTools{root: root}
+	messages := []anthropic.Message{
+		{Role: "user", Content: []anthropic.ContentBlock{anthropic.TextBlock(prompt)}},
+	}
+
+	maxTurns := planning.MaxTurns
+	if maxTurns < 1 {
+		maxTurns = 1
+	}
+	for turn := 1; ; turn++ {
+		req := &anthropic.Request{
+			Model:     planning.Model,
+			MaxTokens: planning.MaxTokens,
+			System:    system,
+			Messages:  messages,
+			Tools:     apiPlanTools,
+		}
+		if turn >= maxTurns {
+			req.ToolChoice = &anthropic.ToolChoice{Type: "none"}
+		}
+
+		resp, err := client.CreateMessage(ctx, req)
+		if err != nil {
+			return "", fmt.Errorf("claude failed: %w", err)
+		}
+		usage.InputTokens += resp.Usage.InputTokens
+		usage.OutputTokens += resp.Usage.OutputTokens
+
+		if resp.StopReason != "tool_use" {
+			r.logger.Claude(fmt.Sprintf("Plan written after %d turn(s), %d input and %d output tokens", turn, usage.InputTokens, usage.OutputTokens))
+			if resp.StopReason == "max_tokens" {
+				return "", fmt.Errorf("claude failed: reply exceeds max_tokens (%d)", planning.MaxTokens)
+			}
+			return resp.Text(), nil
+		}
+
+		messages = append(messages, anthropic.Message{Role: "assistant", Content: resp.Content})
+		var results []anthropic.ContentBlock
+		for _, block := range resp.Content {
+			if block.Type != "tool_use" {
+				continue
+			}
+			r.logger.Claude(fmt.Sprintf("%s %s", block.Name, block.Input))
+			output, err := tools.call(block.Name, block.Input)
+			if err != nil {
+				results = append(results, anthropic.ToolResultBlock(block.ID, err.Error(), true))
+				continue
+			}
+			results = append(results, anthropic.ToolResultBlock(block.ID, output, false))
+		}
+		if turn+1 >= maxTurns {
+			results = append(results, anthropic.TextBlock("You are out of tool calls. Write your final reply now."))
+		}
+		messages = append(messages, anthropic.Message{Role: "user", Content: results})
+	}
+}
+
+// repoTools implements the planning tools over a checkout. Paths never
+// leave root, also not through symlinks: the checkout is untrusted, and
+// anything read is sent to the API.
+type repoTools struct {
+	root string
+}
+
+// call runs a tool with its JSON input.
+func (t *repoTools) call(name string, input json.RawMessage) (string, error) {
+	var args struct {
+		Path      string `json:"path"`
+		Pattern   string `json:"pattern"`
+		StartLine int    `json:"start_line"`
+		EndLine   int    `json:"end_line"`
+	}
+	if len(input) > 0 {
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+	}
+
+	switch name {
+	case "list_files":
+		return t.listFiles(args.Path)
+	case "read_file":
+		return t.readFile(args.Path, args.StartLine, args.EndLine)
+	case "search":
+		return t.search(args.Pattern, args.Path)
+	default:
+		return "", fmt.Errorf("unknown tool %q", name)
+	}
+}
+
+// resolve maps a repository path to a host path inside root.
+func (t *repoTools) resolve(rel string) (string, error) {
+	rel = filepath.Clean("/" + filepath.FromSlash(rel))
+	root, err := filepath.EvalSymlinks(t.root)
+	if err != nil {
+		return "", err
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(root, rel))
+	if err != nil {
+		return "", fmt.Errorf("%s: no such file or directory", strings.TrimPrefix(rel, "/"))
+	}
+	if path != root && !strings.HasPrefix(path, root+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: outside the repository", strings.TrimPrefix(rel, "/"))
+	}
+	if inGitDir(root, path) {
+		return "", fmt.Errorf("%s: .git is not readable", strings.TrimPrefix(rel, "/"))
+	}
+	return path, nil
+}
+
+// inGitDir reports whether path is the repository's .git or inside it.
+func inGitDir(root, path string) bool {
+	gitDir := filepath.Join(root, ".git")
+	return path == gitDir || strings.HasPrefix(path, gitDir+string(filepath.Separator))
+}
+
+func (t *repoTools) listFiles(rel string) (string, error) {
+	dir, err := t.resolve(rel)
+	if err != nil {
+		return "", err
+	}
+	root, _ := filepath.EvalSymlinks(t.root)
+
+	var out strings.Builder
+	count := 0
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return nil
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if count == maxToolListEntries {
+			fmt.Fprintf(&out, "... (truncated after %d entries, list a subdirectory)\n", maxToolListEntries)
+			return filepath.SkipAll
+		}
+		count++
+		name, _ := filepath.Rel(root, path)
+		if d.IsDir() {
+			name += "/"
+		}
+		fmt.Fprintln(&out, filepath.ToSlash(name))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func (t *repoTools) readFile(rel string, start, end int) (string, error) {
+	path, err := t.resolve(rel)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", rel, stripPath(err))
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return "", fmt.Errorf("%s: binary file", rel)
+	}
+
+	if start > 0 || end > 0 {
+		lines := strings.SplitAfter(string(data), "\n")
+		if start < 1 {
+			start = 1
+		}
+		if end < 1 || end > len(lines) {
+			end = len(lines)
+		}
+		if start > end {
+			return "", fmt.Errorf("%s: line range %d-%d is empty", rel, start, end)
+		}
+		data = []byte(strings.Join(lines[start-1:end], ""))
+	}
+	if len(data) > maxToolFileBytes {
+		return string(data[:maxToolFileBytes]) + fmt.Sprintf("\n... (truncated at %d bytes, read a line range)", maxToolFileBytes), nil
+	}
+	return string(data), nil
+}
+
+func (t *repoTools) search(pattern, rel string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+	dir, err := t.resolve(rel)
+	if err != nil {
+		return "", err
+	}
+	root, _ := filepath.EvalSymlinks(t.root)
+
+	var out strings.Builder
+	matches := 0
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err != nil || info.Size() > maxSearchFileBytes {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || bytes.IndexByte(data, 0) >= 0 {
+			return nil
+		}
+
+		name, _ := filepath.Rel(root, path)
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 64<<10), maxSearchFileBytes)
+		for line := 1; scanner.Scan(); line++ {
+			if !re.Match(scanner.Bytes()) {
+				continue
+			}
+			if matches == maxToolMatches {
+				fmt.Fprintf(&out, "... (more than %d matches, narrow the search)\n", maxToolMatches)
+				return filepath.SkipAll
+			}
+			matches++
+			text := scanner.Text()
+			if len(text) > 200 {
+				text = text[:200] + "..."
+			}
+			fmt.Fprintf(&out, "%s:%d: %s\n", filepath.ToSlash(name), line, text)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if matches == 0 {
+		return "No matches", nil
+	}
+	return out.String(), nil
+}
+
+// stripPath strips the host path from a *fs.PathError, which would
+// otherwise tell Claude where the checkout lives.
+func stripPath(err error) error {
+	if pathErr, ok := err.(*fs.PathError); ok {
+		return pathErr.Err
+	}
+	return err
+}
@@ -0,0 +1,130 @@
+package job
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mpm/manfred/internal/anthropic"
+	"github.com/mpm/manfred/internal/config"
+)
+
+func TestPlanWithAPI(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n\nfunc login() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var requests []anthropic.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "key" {
+			t.Errorf("X-Api-Key = %q, want key", r.Header.Get("X-Api-Key"))
+		}
+		var req anthropic.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+
+		if len(requests) == 1 {
+			json.NewEncoder(w).Encode(anthropic.Response{
+				StopReason: "tool_use",
+				Content: []anthropic.ContentBlock{
+					{Type: "tool_use", ID: "t1", Name: "search", Input: json.RawMessage(`{"pattern":"func login"}`)},
+					{Type: "tool_use", ID: "t2", Name: "read_file", Input: json.RawMessage(`{"path":"../etc/passwd"}`)},
+				},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(anthropic.Response{
+			StopReason: "end_turn",
+			Content:    []anthropic.ContentBlock{anthropic.TextBlock("# Plan\n\n1. Extend login in main.go\n")},
+		})
+	}))
+	defer server.Close()
+
+	r := &Runner{logger: NewLogger(NewTextSink(&bytes.Buffer{}))}
+	planning := config.PlanningConfig{Model: "model", MaxTurns: 2, MaxTokens: 1000}
+	plan, err := r.planWithAPI(context.Background(), anthropic.NewClient("key", anthropic.WithBaseURL(server.URL)), planning, apiPlanSystemPrompt, root, "Plan the login", &anthropic.Usage{})
+	if err != nil {
+		t.Fatalf("planWithAPI() error: %v", err)
+	}
+	if !strings.HasPrefix(plan, "# Plan") {
+		t.Errorf("plan = %q, want the final reply", plan)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("API received %d requests, want 2", len(requests))
+	}
+	if requests[0].ToolChoice != nil {
+		t.Errorf("first request restricts tools: %+v", requests[0].ToolChoice)
+	}
+	if requests[1].ToolChoice == nil || requests[1].ToolChoice.Type != "none" {
+		t.Errorf("last request tool_choice = %+v, want none", requests[1].ToolChoice)
+	}
+
+	results := requests[1].Messages[2].Content
+	if len(results) != 3 {
+		t.Fatalf("tool results = %+v, want 2 results and the turn limit note", results)
+	}
+	if results[0].IsError || results[0].Content != "main.go:3: func login() {}\n" {
+		t.Errorf("search result = %+v", results[0])
+	}
+	if !results[1].IsError {
+		t.Errorf("read_file outside the repository succeeded: %+v", results[1])
+	}
+}
+
+func TestRepoToolsResolve(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "src", ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "src", "app.go"), []byte("package src\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tools := &repoTools{root: root}
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{path: "src/app.go"},
+		{path: "/src/app.go"},
+		{path: "../" + filepath.Base(outside) + "/secret", wantErr: true},
+		{path: "link", wantErr: true},
+		{path: ".git/config", wantErr: true},
+		{path: "missing.go", wantErr: true},
+	}
+	for _, tt := range tests {
+		_, err := tools.readFile(tt.path, 0, 0)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("readFile(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+		}
+		if err != nil && strings.Contains(err.Error(), root) {
+			t.Errorf("readFile(%q) error %q reveals the host path", tt.path, err)
+		}
+	}
+
+	list, err := tools.listFiles("")
+	if err != nil {
+		t.Fatalf("listFiles() error: %v", err)
+	}
+	if strings.Contains(list, ".git") || !strings.Contains(list, "src/app.go\n") {
+		t.Errorf("listFiles() = %q, want src/app.go without .git", list)
+	}
+}
@@ -0,0 +1,158 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mpm/manfred/internal/config"
+)
+
+// Artifact is a file collected from a job's container.
+type Artifact struct {
+	Path    string    `json:"path"` // Relative to the job's artifacts directory
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// collectArtifacts copies the project's artifact paths out of the container
+// into the job's artifacts directory. Relative paths are resolved against
+// the workdir and keep their layout below artifacts/. Missing paths are
+// logged; they never fail the job.
+func (r *Runner) collectArtifacts(ctx context.Context, job *Job, projectConfig *config.ProjectConfig, containerName string) {
+	if len(projectConfig.Artifacts) == 0 {
+		return
+	}
+	running, err := r.docker.IsRunning(ctx, containerName)
+	if err != nil || !running {
+		r.logger.Docker("Container is not running, skipping artifact collection")
+		return
+	}
+
+	workdir := jobWorkdir(job, projectConfig)
+	for _, p := range projectConfig.Artifacts {
+		src := p
+		if !path.IsAbs(src) {
+			src = path.Join(workdir, src)
+		}
+		dst := filepath.Join(job.ArtifactsDir(), artifactName(p))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			r.logger.Docker(fmt.Sprintf("Warning: could not collect artifact %s: %v", p, err))
+			continue
+		}
+		if err := r.docker.CopyFromContainer(ctx, containerName, src, dst); err != nil {
+			r.logger.Docker(fmt.Sprintf("Warning: could not collect artifact %s: %v", p, err))
+		}
+	}
+
+	artifacts, err := listArtifacts(job.ArtifactsDir())
+	if err != nil {
+		r.logger.Docker(fmt.Sprintf("Warning: could not list artifacts: %v", err))
+		return
+	}
+	job.Artifacts = artifacts
+	r.logger.Docker(fmt.Sprintf("Collected %d artifact file(s) (manfred job artifacts %s)", len(artifacts), job.ID))
+}
+
+// artifactName maps a declared artifact path to its location below the
+// artifacts directory. Absolute paths keep their full path; ".." elements
+// cannot leave the directory.
+func artifactName(p string) string {
+	name := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if name == "" {
+		name = "root"
+	}
+	return filepath.FromSlash(name)
+}
+
+// ListArtifacts returns the files collected for a job in jobsDir, in lexical
+// order.
+func ListArtifacts(jobsDir, jobID string) ([]Artifact, error) {
+	if err := checkJobID(jobID); err != nil {
+		return nil, err
+	}
+	job := &Job{ID: jobID, jobsDir: jobsDir}
+	if _, err := os.Stat(job.JobPath()); err != nil {
+		return nil, err
+	}
+
+	artifacts, err := listArtifacts(job.ArtifactsDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return artifacts, err
+}
+
+// ArtifactPath returns the host path of one of a job's artifacts, given its
+// path relative to the artifacts directory. The error wraps os.ErrNotExist
+// when there is no such artifact.
+func ArtifactPath(jobsDir, jobID, name string) (string, error) {
+	if err := checkJobID(jobID); err != nil {
+		return "", err
+	}
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", fmt.Errorf("invalid artifact path: %q", name)
+	}
+	job := &Job{ID: jobID, jobsDir: jobsDir}
+
+	// Artifacts come from the container, so symlinks among them must not
+	// lead to files elsewhere on the host.
+	dir, err := filepath.EvalSymlinks(job.ArtifactsDir())
+	if err != nil {
+		return "", err
+	}
+	p, err := filepath.EvalSymlinks(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(dir, p); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("invalid artifact path: %q", name)
+	}
+	return p, nil
+}
+
+// checkJobID rejects IDs that are not a single path element, as they come
+// from the command line or a URL.
+func checkJobID(jobID string) error {
+	if !filepath.IsLocal(jobID) || strings.ContainsAny(jobID, `/\`) {
+		return fmt.Errorf("invalid job ID: %q", jobID)
+	}
+	return nil
+}
+
+// listArtifacts walks an artifacts directory.
+func listArtifacts(dir string) ([]Artifact, error) {
+	var artifacts []Artifact
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		artifacts = append(artifacts, Artifact{
+			Path:    filepath.ToSlash(rel),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return artifacts, nil
+}
@@ -0,0 +1,115 @@
+package job
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArtifactName(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"coverage.out", "coverage.out"},
+		{"build/dist/", "build/dist"},
+		{"./reports/junit.xml", "reports/junit.xml"},
+		{"/tmp/profile.svg", "tmp/profile.svg"},
+		{"../../etc/passwd", "etc/passwd"},
+		{"/", "root"},
+	}
+
+	for _, tt := range tests {
+		if got := artifactName(tt.path); got != filepath.FromSlash(tt.want) {
+			t.Errorf("artifactName(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestListArtifacts(t *testing.T) {
+	jobsDir := t.TempDir()
+	job := &Job{ID: "job_1", jobsDir: jobsDir}
+
+	if err := os.MkdirAll(job.JobPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	artifacts, err := ListArtifacts(jobsDir, job.ID)
+	if err != nil {
+		t.Fatalf("ListArtifacts() without artifacts: %v", err)
+	}
+	if len(artifacts) != 0 {
+		t.Errorf("got %d artifacts, want none", len(artifacts))
+	}
+
+	writeArtifact(t, job, "coverage.out", "mode: set\n")
+	writeArtifact(t, job, "build/app", "binary")
+	if err := os.Symlink("/etc/passwd", filepath.Join(job.ArtifactsDir(), "passwd")); err != nil {
+		t.Fatal(err)
+	}
+
+	artifacts, err = ListArtifacts(jobsDir, job.ID)
+	if err != nil {
+		t.Fatalf("ListArtifacts() error: %v", err)
+	}
+	var got []string
+	for _, a := range artifacts {
+		got = append(got, a.Path)
+	}
+	want := []string{"build/app", "coverage.out"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("artifacts = %v, want %v", got, want)
+	}
+	if artifacts[1].Size != int64(len("mode: set\n")) {
+		t.Errorf("size = %d, want %d", artifacts[1].Size, len("mode: set\n"))
+	}
+
+	if _, err := ListArtifacts(jobsDir, "job_missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ListArtifacts() for missing job = %v, want not exist", err)
+	}
+	if _, err := ListArtifacts(jobsDir, "../job_1"); err == nil {
+		t.Error("ListArtifacts() accepted a job ID with a path")
+	}
+}
+
+func TestArtifactPath(t *testing.T) {
+	jobsDir := t.TempDir()
+	job := &Job{ID: "job_1", jobsDir: jobsDir}
+	writeArtifact(t, job, "build/app", "binary")
+
+	outside := filepath.Join(jobsDir, "secret")
+	if err := os.WriteFile(outside, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(job.ArtifactsDir(), "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := ArtifactPath(jobsDir, job.ID, "build/app")
+	if err != nil {
+		t.Fatalf("ArtifactPath() error: %v", err)
+	}
+	if data, err := os.ReadFile(p); err != nil || string(data) != "binary" {
+		t.Errorf("read %s = %q, %v; want %q", p, data, err, "binary")
+	}
+
+	if _, err := ArtifactPath(jobsDir, job.ID, "missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ArtifactPath() for missing artifact = %v, want not exist", err)
+	}
+	for _, name := range []string{"../prompt.txt", "/etc/passwd", "link"} {
+		if _, err := ArtifactPath(jobsDir, job.ID, name); err == nil {
+			t.Errorf("ArtifactPath(%q) succeeded, want error", name)
+		}
+	}
+}
+
+func writeArtifact(t *testing.T, job *Job, name, content string) {
+	t.Helper()
+	p := filepath.Join(job.ArtifactsDir(), filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,35 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/mpm/manfred/internal/codemap"
+)
+
+// addCodeMap appends a code map of the job's repository to the prompt of a
+// plan-only job, so the plan can name files and functions without Claude
+// exploring the whole tree first. A failure only costs the map.
+func (r *Runner) addCodeMap(ctx context.Context, job *Job) {
+	planning := r.config.Job.Planning
+	if !planning.CodeMap {
+		return
+	}
+
+	dir := job.WorkspacePath()
+	if _, err := os.Stat(dir); err != nil {
+		dir = r.config.ProjectRepositoryPath(job.ProjectName)
+	}
+	m, err := codemap.Generate(ctx, dir, planning.CodeMapBytes)
+	if err != nil {
+		r.logger.Manfred(fmt.Sprintf("Warning: no code map: %v", err))
+		return
+	}
+	if m == "" {
+		return
+	}
+
+	job.Prompt += "\n\n---\n\nCode map of the repository, generated by MANFRED (may be incomplete):\n\n" + m
+	r.logger.Manfred(fmt.Sprintf("Added a code map of %d bytes to the prompt", len(m)))
+}
@@ -0,0 +1,72 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mpm/manfred/internal/docker"
+)
+
+// containerConversationDir is ConversationDir as seen in the container.
+var containerConversationDir = filepath.Join(docker.ContainerJobPath, ".manfred", "conversation")
+
+// Claude keeps its conversations in ~/.claude/projects, per working
+// directory. Jobs share the workdir, so a conversation copied into the home
+// of a later job's container is the one claude --continue picks up.
+const (
+	saveConversationScript    = `mkdir -p "$1" && cp -a "$HOME/.claude/projects/." "$1/"`
+	restoreConversationScript = `mkdir -p "$HOME/.claude/projects" && cp -a "$1/." "$HOME/.claude/projects/"`
+)
+
+// HasConversation reports whether the job id in jobsDir saved a
+// conversation a later job can continue.
+func HasConversation(jobsDir, id string) bool {
+	info, err := os.Stat((&Job{ID: id, jobsDir: jobsDir}).ConversationDir())
+	return err == nil && info.IsDir()
+}
+
+// saveConversation copies Claude's conversation into the job directory. A
+// failure is only logged; the conversation cannot be continued then.
+func (r *Runner) saveConversation(ctx context.Context, job *Job, container string, env map[string]string) {
+	if err := r.execConversationScript(ctx, job, container, env, saveConversationScript); err != nil {
+		r.logger.Manfred(fmt.Sprintf("Warning: failed to save Claude's conversation: %v", err))
+		return
+	}
+	r.logger.Manfred("Saved Claude's conversation")
+}
+
+// restoreConversation copies the conversation saved by job fromID into the
+// job directory and from there into Claude's home in the container.
+func (r *Runner) restoreConversation(ctx context.Context, job *Job, container string, env map[string]string, fromID string) error {
+	if !HasConversation(r.config.JobsDir, fromID) {
+		return fmt.Errorf("job %s saved no conversation to continue", fromID)
+	}
+	src := (&Job{ID: fromID, jobsDir: r.config.JobsDir}).ConversationDir()
+	if err := copyDir(src, job.ConversationDir()); err != nil {
+		return fmt.Errorf("copy conversation of job %s: %w", fromID, err)
+	}
+	if err := r.execConversationScript(ctx, job, container, env, restoreConversationScript); err != nil {
+		return fmt.Errorf("restore conversation of job %s: %w", fromID, err)
+	}
+	r.logger.Manfred(fmt.Sprintf("Continuing Claude's conversation from job %s", fromID))
+	return nil
+}
+
+// execConversationScript runs script as the user Claude runs as, with the
+// container path of the conversation directory as $1.
+func (r *Runner) execConversationScript(ctx context.Context, job *Job, container string, env map[string]string, script string) error {
+	out := r.logger.Writer("DOCKER")
+	defer out.Close()
+	result, err := r.docker.Exec(ctx, container, []string{"sh", "-c", script, "sh", containerConversationDir}, docker.ExecOptions{
+		User:   job.execUser,
+		Env:    env,
+		Stdout: out,
+		Stderr: out,
+	})
+	if err != nil {
+		return err
+	}
+	return result.Err()
+}
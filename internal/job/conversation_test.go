@@ -0,0 +1,27 @@
+package job
+
+import (
+	"os"
+	"testing"
+)
+
+func TestHasConversation(t *testing.T) {
+	jobsDir := t.TempDir()
+	j := New("demo", "plan", jobsDir)
+	if err := j.CreateDirectories(); err != nil {
+		t.Fatalf("CreateDirectories() error = %v", err)
+	}
+
+	if HasConversation(jobsDir, j.ID) {
+		t.Error("HasConversation() = true before a conversation was saved")
+	}
+	if HasConversation(jobsDir, "job_missing") {
+		t.Error("HasConversation() = true for a missing job")
+	}
+	if err := os.MkdirAll(j.ConversationDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if !HasConversation(jobsDir, j.ID) {
+		t.Error("HasConversation() = false after a conversation was saved")
+	}
+}
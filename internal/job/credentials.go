@@ -0,0 +1,138 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/docker"
+	"github.com/mpm/manfred/internal/gitops"
+)
+
+const (
+	// ContainerCredentialHelper is where the git credential helper is
+	// mounted inside the container.
+	ContainerCredentialHelper = docker.ContainerJobPath + "/bin/git-credential-manfred"
+
+	// ContainerSSHKey is where the project's SSH key is mounted inside the
+	// container.
+	ContainerSSHKey = docker.ContainerJobPath + "/.ssh/id_manfred"
+
+	// ContainerHomePath is the home directory of a docker.user the image
+	// has none for, e.g. a bare uid.
+	ContainerHomePath = docker.ContainerJobPath + "/home"
+
+	// gitTokenEnv carries the token to the credential helper; it is only
+	// set in the exec environment, never in the repository config.
+	gitTokenEnv = "MANFRED_GIT_TOKEN"
+)
+
+// credentialHelperScript answers git's "get" requests with the job token.
+const credentialHelperScript = `#!/bin/sh
+# git credential helper installed by MANFRED.
+test "$1" = get || exit 0
+test -n "$MANFRED_GIT_TOKEN" || exit 0
+echo username=x-access-token
+echo "password=$MANFRED_GIT_TOKEN"
+`
+
+// gitAuth returns the credentials for url, one of a project's repositories.
+func (r *Runner) gitAuth(ctx context.Context, projectConfig *config.ProjectConfig, url string) (gitops.Auth, error) {
+	token, err := r.config.GitToken(ctx, url)
+	if err != nil {
+		return gitops.Auth{}, err
+	}
+	return gitops.Auth{Token: token, SSHKey: r.config.GitSSHKey(projectConfig)}, nil
+}
+
+// refreshAuth returns repo with current credentials for url before it talks
+// to origin again: GitHub App installation tokens expire after an hour,
+// which a long job can outlive.
+func (r *Runner) refreshAuth(ctx context.Context, repo *gitops.Repo, projectConfig *config.ProjectConfig, url string) (*gitops.Repo, error) {
+	auth, err := r.gitAuth(ctx, projectConfig, url)
+	if err != nil {
+		return nil, err
+	}
+	return repo.WithAuth(url, auth), nil
+}
+
+// setupContainerGitAuth lets git inside the container authenticate as the
+// job when job.container_git_auth is enabled. HTTPS remotes get a credential
+// helper that reads the token from the exec environment; SSH remotes get a
+// copy of the key and core.sshCommand. It returns the variables to add to
+// the exec environment.
+func (r *Runner) setupContainerGitAuth(ctx context.Context, job *Job, projectConfig *config.ProjectConfig) (map[string]string, error) {
+	if !r.config.Job.ContainerGitAuth || job.repo == nil {
+		return nil, nil
+	}
+	auth, err := r.gitAuth(ctx, projectConfig, projectConfig.Repo)
+	if err != nil {
+		return nil, err
+	}
+	repo := job.gitRepo()
+	env := map[string]string{}
+
+	if auth.Token != "" {
+		u, err := url.Parse(projectConfig.Repo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse repo URL: %w", err)
+		}
+		if err := writeJobFile(job.CredentialHelperFile(), []byte(credentialHelperScript), 0755); err != nil {
+			return nil, fmt.Errorf("failed to install credential helper: %w", err)
+		}
+		key := fmt.Sprintf("credential.%s://%s.helper", u.Scheme, u.Host)
+		if err := repo.SetConfig(ctx, key, ContainerCredentialHelper); err != nil {
+			return nil, fmt.Errorf("failed to configure credential helper: %w", err)
+		}
+		r.logger.Mask(auth.Token)
+		env[gitTokenEnv] = auth.Token
+	}
+
+	if auth.SSHKey != "" {
+		data, err := os.ReadFile(auth.SSHKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SSH key: %w", err)
+		}
+		if err := writeJobFile(job.SSHKeyFile(), data, 0600); err != nil {
+			return nil, fmt.Errorf("failed to copy SSH key: %w", err)
+		}
+		if err := repo.SetConfig(ctx, "core.sshCommand", gitops.SSHCommand(ContainerSSHKey)); err != nil {
+			return nil, fmt.Errorf("failed to configure SSH command: %w", err)
+		}
+	}
+
+	r.logger.Docker("Configured git credentials for the container")
+	return env, nil
+}
+
+// writeJobFile writes a file into the job directory, creating its parent.
+func writeJobFile(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, perm)
+}
+
+// setupExecUser prepares the container for execs as user: the job
+// directory, where Claude works and writes, is handed to the user, so the
+// files it leaves on the host are not root-owned, and a user without a home
+// directory gets one in the job directory. It returns the HOME to set for
+// the execs, empty to keep the user's own.
+func (r *Runner) setupExecUser(ctx context.Context, containerName, user string) (string, error) {
+	r.logger.Docker(fmt.Sprintf("Running Claude and tests as %s", user))
+	if output, err := r.exec.ExecAs(ctx, containerName, "0", []string{"chown", "-R", user, docker.ContainerJobPath}); err != nil {
+		return "", fmt.Errorf("failed to hand the job directory to %s: %v (output: %s)", user, err, strings.TrimSpace(output))
+	}
+	if r.exec.UserHome(ctx, containerName, user) != "" {
+		return "", nil
+	}
+	if output, err := r.exec.ExecAs(ctx, containerName, user, []string{"mkdir", "-p", ContainerHomePath}); err != nil {
+		return "", fmt.Errorf("failed to create a home directory for %s: %v (output: %s)", user, err, strings.TrimSpace(output))
+	}
+	r.logger.Docker(fmt.Sprintf("%s has no home directory, using %s", user, ContainerHomePath))
+	return ContainerHomePath, nil
+}
@@ -0,0 +1,195 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/docker"
+	"github.com/mpm/manfred/internal/gitops"
+)
+
+func TestSetupContainerGitAuth(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	r, job := newHookTestRunner(t)
+	r.config.Job.ContainerGitAuth = true
+	r.config.GitHub.Token = "ghs_secret"
+
+	keyFile := filepath.Join(t.TempDir(), "deploy_key")
+	if err := os.WriteFile(keyFile, []byte("PRIVATE KEY"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	project := &config.ProjectConfig{
+		Repo: "https://github.com/owner/repo.git",
+		Git:  config.GitConfig{SSHKey: keyFile},
+	}
+
+	if out, err := exec.Command("git", "init", "-q", job.WorkspacePath()).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	job.repo = gitops.Open(job.WorkspacePath())
+
+	env, err := r.setupContainerGitAuth(context.Background(), job, project)
+	if err != nil {
+		t.Fatalf("setupContainerGitAuth() error = %v", err)
+	}
+	if env[gitTokenEnv] != "ghs_secret" {
+		t.Errorf("env[%s] = %q, want token", gitTokenEnv, env[gitTokenEnv])
+	}
+
+	gitConfig := func(key string) string {
+		out, err := exec.Command("git", "-C", job.WorkspacePath(), "config", "--get", key).Output()
+		if err != nil {
+			t.Fatalf("git config --get %s: %v", key, err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	if got := gitConfig("credential.https://github.com.helper"); got != ContainerCredentialHelper {
+		t.Errorf("credential helper = %q, want %q", got, ContainerCredentialHelper)
+	}
+	if got := gitConfig("core.sshCommand"); !strings.Contains(got, ContainerSSHKey) {
+		t.Errorf("core.sshCommand = %q, want key %s", got, ContainerSSHKey)
+	}
+
+	// The token must only reach git through the environment.
+	data, err := os.ReadFile(filepath.Join(job.WorkspacePath(), ".git", "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "ghs_secret") {
+		t.Error(".git/config contains the token")
+	}
+
+	cmd := exec.Command(job.CredentialHelperFile(), "get")
+	cmd.Env = append(os.Environ(), gitTokenEnv+"=ghs_secret")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("credential helper: %v", err)
+	}
+	if !strings.Contains(string(out), "password=ghs_secret\n") {
+		t.Errorf("credential helper output = %q", out)
+	}
+
+	info, err := os.Stat(job.SSHKeyFile())
+	if err != nil {
+		t.Fatalf("SSH key not copied: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("SSH key mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestSetupContainerGitAuthDisabled(t *testing.T) {
+	r, job := newHookTestRunner(t)
+	r.config.GitHub.Token = "ghs_secret"
+	job.repo = gitops.Open(job.WorkspacePath())
+
+	env, err := r.setupContainerGitAuth(context.Background(), job, &config.ProjectConfig{Repo: "https://github.com/owner/repo.git"})
+	if err != nil || env != nil {
+		t.Errorf("setupContainerGitAuth() = %v, %v; want nil, nil", env, err)
+	}
+	if _, err := os.Stat(job.CredentialHelperFile()); !os.IsNotExist(err) {
+		t.Error("credential helper installed while container_git_auth is off")
+	}
+}
+
+// fakeExec records the commands run in a container instead of running them.
+type fakeExec struct {
+	commands []string
+	homes    map[string]string // user -> $HOME
+	fail     string            // command to fail
+	output   string            // output of Exec
+	exitCode int               // exit code of Exec
+}
+
+func (f *fakeExec) record(user string, command []string) error {
+	cmd := user + ": " + strings.Join(command, " ")
+	f.commands = append(f.commands, cmd)
+	if f.fail != "" && strings.HasSuffix(cmd, f.fail) {
+		return errors.New("exit status 1")
+	}
+	return nil
+}
+
+func (f *fakeExec) Exec(ctx context.Context, containerName string, command []string, opts docker.ExecOptions) (*docker.ExecResult, error) {
+	if err := f.record(opts.User, command); err != nil {
+		return nil, err
+	}
+	if opts.Stdout != nil {
+		io.WriteString(opts.Stdout, f.output)
+	}
+	return &docker.ExecResult{ExitCode: f.exitCode}, nil
+}
+
+func (f *fakeExec) ExecAs(ctx context.Context, containerName, user string, command []string) (string, error) {
+	return "", f.record(user, command)
+}
+
+func (f *fakeExec) UserHome(ctx context.Context, containerName, user string) string {
+	return f.homes[user]
+}
+
+func TestSetupExecUser(t *testing.T) {
+	tests := []struct {
+		name         string
+		homes        map[string]string
+		fail         string
+		wantHome     string
+		wantCommands []string
+		wantErr      string
+	}{
+		{
+			name:         "user with a home",
+			homes:        map[string]string{"node": "/home/node"},
+			wantCommands: []string{"0: chown -R node " + docker.ContainerJobPath},
+		},
+		{
+			name:     "bare uid",
+			wantHome: ContainerHomePath,
+			wantCommands: []string{
+				"0: chown -R 1000 " + docker.ContainerJobPath,
+				"1000: mkdir -p " + ContainerHomePath,
+			},
+		},
+		{
+			name:         "chown fails",
+			fail:         docker.ContainerJobPath,
+			wantCommands: []string{"0: chown -R 1000 " + docker.ContainerJobPath},
+			wantErr:      "failed to hand the job directory to 1000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := newHookTestRunner(t)
+			fake := &fakeExec{homes: tt.homes, fail: tt.fail}
+			r.exec = fake
+			user := "1000"
+			if tt.homes != nil {
+				user = "node"
+			}
+
+			home, err := r.setupExecUser(context.Background(), "job-1", user)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("setupExecUser() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("setupExecUser() error = %v", err)
+			}
+			if home != tt.wantHome {
+				t.Errorf("setupExecUser() home = %q, want %q", home, tt.wantHome)
+			}
+			if strings.Join(fake.commands, "\n") != strings.Join(tt.wantCommands, "\n") {
+				t.Errorf("commands = %q, want %q", fake.commands, tt.wantCommands)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package job
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DetectedCommand is a test command guessed from a repository's files.
+type DetectedCommand struct {
+	Stack   string // e.g. go, node
+	Command string
+}
+
+// npmDefaultTest is the test script `npm init` writes.
+const npmDefaultTest = `echo "Error: no test specified" && exit 1`
+
+// makeTestTarget matches a Makefile rule named test.
+var makeTestTarget = regexp.MustCompile(`(?m)^test\s*:`)
+
+// DetectTestCommands guesses the commands that run the test suites of the
+// repository in dir, one per detected stack. A Makefile test target is
+// assumed to cover everything else.
+func DetectTestCommands(dir string) []DetectedCommand {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "Makefile")); err == nil && makeTestTarget.Match(data) {
+		return []DetectedCommand{{Stack: "make", Command: "make test"}}
+	}
+
+	var commands []DetectedCommand
+	add := func(stack, command string) {
+		commands = append(commands, DetectedCommand{Stack: stack, Command: command})
+	}
+
+	if exists("go.mod") {
+		add("go", "go test ./...")
+	}
+	if hasNPMTestScript(filepath.Join(dir, "package.json")) {
+		switch {
+		case exists("pnpm-lock.yaml"):
+			add("node", "pnpm test")
+		case exists("yarn.lock"):
+			add("node", "yarn test")
+		default:
+			add("node", "npm test")
+		}
+	}
+	if exists("Cargo.toml") {
+		add("rust", "cargo test")
+	}
+	if exists("Gemfile") {
+		switch {
+		case exists("spec"):
+			add("ruby", "bundle exec rspec")
+		case exists("bin/rails"):
+			add("ruby", "bin/rails test")
+		case exists("Rakefile"):
+			add("ruby", "bundle exec rake test")
+		}
+	}
+	if exists("pyproject.toml") || exists("setup.py") || exists("pytest.ini") || exists("tox.ini") {
+		add("python", "python -m pytest")
+	}
+	if exists("mix.exs") {
+		add("elixir", "mix test")
+	}
+	switch {
+	case exists("pom.xml"):
+		add("java", "mvn -B test")
+	case exists("gradlew"):
+		add("java", "./gradlew test")
+	case exists("build.gradle") || exists("build.gradle.kts"):
+		add("java", "gradle test")
+	}
+	return commands
+}
+
+// hasNPMTestScript reports whether a package.json defines a real test script.
+func hasNPMTestScript(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return false
+	}
+	script := strings.TrimSpace(pkg.Scripts["test"])
+	return script != "" && script != npmDefaultTest
+}
+
+// JoinCommands combines detected commands into one shell command that fails
+// when any of them fails.
+func JoinCommands(commands []DetectedCommand) string {
+	parts := make([]string, len(commands))
+	for i, c := range commands {
+		parts[i] = c.Command
+	}
+	return strings.Join(parts, " && ")
+}
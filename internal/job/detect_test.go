@@ -0,0 +1,90 @@
+package job
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectTestCommands(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{name: "empty"},
+		{
+			name:  "go",
+			files: map[string]string{"go.mod": "module example.com/x\n"},
+			want:  "go test ./...",
+		},
+		{
+			name:  "npm default script",
+			files: map[string]string{"package.json": `{"scripts": {"test": "echo \"Error: no test specified\" && exit 1"}}`},
+		},
+		{
+			name: "yarn",
+			files: map[string]string{
+				"package.json": `{"scripts": {"test": "jest"}}`,
+				"yarn.lock":    "",
+			},
+			want: "yarn test",
+		},
+		{
+			name: "go and node",
+			files: map[string]string{
+				"go.mod":       "module example.com/x\n",
+				"package.json": `{"scripts": {"test": "vitest run"}}`,
+			},
+			want: "go test ./... && npm test",
+		},
+		{
+			name: "makefile test target wins",
+			files: map[string]string{
+				"Makefile": "build:\n\tgo build\n\ntest: build\n\tgo test ./...\n",
+				"go.mod":   "module example.com/x\n",
+			},
+			want: "make test",
+		},
+		{
+			name: "makefile without test target",
+			files: map[string]string{
+				"Makefile":   "build:\n\tcargo build\n",
+				"Cargo.toml": "[package]\n",
+			},
+			want: "cargo test",
+		},
+		{
+			name: "rails",
+			files: map[string]string{
+				"Gemfile":   "",
+				"bin/rails": "",
+			},
+			want: "bin/rails test",
+		},
+		{
+			name:  "python",
+			files: map[string]string{"pyproject.toml": ""},
+			want:  "python -m pytest",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := JoinCommands(DetectTestCommands(dir)); got != tt.want {
+				t.Errorf("DetectTestCommands() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mpm/manfred/internal/gitops"
+)
+
+// Diff describes the changes a job made to its workspace, base..HEAD. The
+// patch itself is stored next to it in the job directory.
+type Diff struct {
+	BaseSHA string `json:"base_sha"`
+	HeadSHA string `json:"head_sha"`
+	gitops.DiffStat
+}
+
+// saveDiff computes the job's diff and writes the patch and its stat to the
+// job directory. Failures are logged; they never fail the job.
+func (r *Runner) saveDiff(ctx context.Context, job *Job) {
+	if job.BaseSHA == "" && len(job.Repos) == 0 {
+		return
+	}
+	if _, err := os.Stat(job.WorkspacePath()); err != nil {
+		return
+	}
+
+	compute := computeDiff
+	if len(job.Repos) > 0 {
+		compute = computeRepoDiffs
+	}
+	diff, patch, err := compute(ctx, job)
+	if err != nil {
+		r.logger.Manfred(fmt.Sprintf("Warning: could not compute diff: %v", err))
+		return
+	}
+	if err := writeDiff(job, diff, patch); err != nil {
+		r.logger.Manfred(fmt.Sprintf("Warning: could not save diff: %v", err))
+		return
+	}
+
+	job.Diff = diff
+	r.logger.Manfred(fmt.Sprintf("Diff saved: %d file(s) changed (manfred job diff %s)", diff.FilesChanged, job.ID))
+}
+
+// computeDiff returns the diff summary and patch of base..HEAD.
+func computeDiff(ctx context.Context, job *Job) (*Diff, string, error) {
+	repo := job.gitRepo()
+
+	head, err := repo.RevParse(ctx, "HEAD")
+	if err != nil {
+		return nil, "", err
+	}
+	stat, err := repo.DiffStat(ctx, job.BaseSHA)
+	if err != nil {
+		return nil, "", err
+	}
+	patch, err := repo.Diff(ctx, job.BaseSHA)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &Diff{BaseSHA: job.BaseSHA, HeadSHA: head, DiffStat: *stat}, patch, nil
+}
+
+// writeDiff stores the diff artifacts in the job directory.
+func writeDiff(job *Job, diff *Diff, patch string) error {
+	data, err := json.MarshalIndent(diff, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(job.DiffFile(), []byte(patch), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(job.DiffStatFile(), data, 0644)
+}
+
+// LoadDiff reads the diff artifacts of a finished job from jobsDir.
+func LoadDiff(jobsDir, jobID string) (*Diff, string, error) {
+	job := &Job{ID: jobID, jobsDir: jobsDir}
+
+	data, err := os.ReadFile(job.DiffStatFile())
+	if err != nil {
+		return nil, "", err
+	}
+	diff := &Diff{}
+	if err := json.Unmarshal(data, diff); err != nil {
+		return nil, "", fmt.Errorf("parse %s: %w", filepath.Base(job.DiffStatFile()), err)
+	}
+
+	patch, err := os.ReadFile(job.DiffFile())
+	if err != nil {
+		return nil, "", err
+	}
+	return diff, string(patch), nil
+}
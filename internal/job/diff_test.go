@@ -0,0 +1,76 @@
+package job
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mpm/manfred/internal/gitops"
+)
+
+func TestSaveAndLoadDiff(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	r, job := newHookTestRunner(t)
+	ws := job.WorkspacePath()
+
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", ws}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	if err := os.MkdirAll(ws, 0755); err != nil {
+		t.Fatal(err)
+	}
+	git("init", "-q")
+	git("commit", "-q", "--allow-empty", "-m", "base")
+	job.BaseSHA = git("rev-parse", "HEAD")
+	job.repo = gitops.Open(ws)
+
+	if err := os.WriteFile(filepath.Join(ws, "hello.txt"), []byte("hello\nworld\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "-A")
+	git("commit", "-q", "-m", "add hello")
+	head := git("rev-parse", "HEAD")
+
+	r.saveDiff(context.Background(), job)
+	if job.Diff == nil {
+		t.Fatal("saveDiff() did not set job.Diff")
+	}
+
+	diff, patch, err := LoadDiff(r.config.JobsDir, job.ID)
+	if err != nil {
+		t.Fatalf("LoadDiff() error = %v", err)
+	}
+	want := Diff{BaseSHA: job.BaseSHA, HeadSHA: head, DiffStat: gitops.DiffStat{FilesChanged: 1, Insertions: 2}}
+	if *diff != want {
+		t.Errorf("LoadDiff() diff = %+v, want %+v", *diff, want)
+	}
+	if !strings.Contains(patch, "+++ b/hello.txt") {
+		t.Errorf("LoadDiff() patch = %q", patch)
+	}
+}
+
+func TestSaveDiffWithoutClone(t *testing.T) {
+	r, job := newHookTestRunner(t)
+
+	r.saveDiff(context.Background(), job)
+	if job.Diff != nil {
+		t.Errorf("job.Diff = %+v, want nil", job.Diff)
+	}
+	if _, _, err := LoadDiff(r.config.JobsDir, job.ID); !os.IsNotExist(err) {
+		t.Errorf("LoadDiff() error = %v, want not exist", err)
+	}
+}
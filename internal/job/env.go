@@ -0,0 +1,140 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mpm/manfred/internal/config"
+)
+
+// buildExecEnv assembles the environment for commands run with docker exec.
+// Host variables named in passthrough are copied when set, then the
+// project's own variables are applied. The variables in base always win, so
+// a project cannot override the API key or sandbox flag.
+func buildExecEnv(base map[string]string, passthrough []string, lookup func(string) (string, bool), project map[string]string) map[string]string {
+	env := make(map[string]string, len(base)+len(passthrough)+len(project))
+	for _, name := range passthrough {
+		if value, ok := lookup(name); ok {
+			env[name] = value
+		}
+	}
+	for name, value := range project {
+		env[name] = value
+	}
+	for name, value := range base {
+		env[name] = value
+	}
+	return env
+}
+
+// execEnv returns the environment for Claude and test execs of a project and
+// registers its secret values with the logger so they are masked.
+func (r *Runner) execEnv(projectConfig *config.ProjectConfig) map[string]string {
+	env := buildExecEnv(map[string]string{
+		"ANTHROPIC_API_KEY": r.anthropicAPIKey(),
+		"IS_SANDBOX":        "1",
+	}, r.config.Job.ExecEnv, os.LookupEnv, projectConfig.Exec.Env)
+
+	for _, name := range r.config.Job.ExecSecrets {
+		r.logger.Mask(env[name])
+	}
+	for _, name := range projectConfig.Exec.Secrets {
+		r.logger.Mask(env[name])
+	}
+
+	var names []string
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	r.logger.Manfred(fmt.Sprintf("Exec environment: %s", strings.Join(names, ", ")))
+
+	return env
+}
+
+// anthropicAPIKey returns the Anthropic API key to inject into a job,
+// decrypting it from the secrets store if it is kept there, and registers
+// it with the logger so it is masked. Failures are logged and leave the key
+// empty.
+func (r *Runner) anthropicAPIKey() string {
+	key, err := r.config.AnthropicAPIKey()
+	if err != nil {
+		r.logger.Manfred(fmt.Sprintf("WARNING: Failed to decrypt the Anthropic API key: %v", err))
+		return ""
+	}
+	r.logger.Mask(key)
+	return key
+}
+
+// fakeTimeLibs are the paths distributions install libfaketime to.
+var fakeTimeLibs = []string{
+	"/usr/lib/x86_64-linux-gnu/faketime/libfaketime.so.1",
+	"/usr/lib/aarch64-linux-gnu/faketime/libfaketime.so.1",
+	"/usr/lib/faketime/libfaketime.so.1",
+	"/usr/local/lib/faketime/libfaketime.so.1",
+}
+
+// fakeTimeSkipCmds are never faked: Claude needs the real time for TLS and
+// the API, and commits should carry their real date. Processes they start
+// are still faked.
+const fakeTimeSkipCmds = "claude,git"
+
+// clockEnv returns the variables that pin the time zone, locale and, when
+// fakeTimeLib is set, the clock of execs. Project settings override
+// job.timezone and job.locale.
+func clockEnv(jobConfig config.JobConfig, execConfig config.ExecConfig, fakeTimeLib string) map[string]string {
+	env := map[string]string{}
+
+	timezone := jobConfig.Timezone
+	if execConfig.Timezone != "" {
+		timezone = execConfig.Timezone
+	}
+	if timezone != "" {
+		env["TZ"] = timezone
+	}
+
+	locale := jobConfig.Locale
+	if execConfig.Locale != "" {
+		locale = execConfig.Locale
+	}
+	if locale != "" {
+		env["LANG"] = locale
+		env["LC_ALL"] = locale
+	}
+
+	if fakeTimeLib != "" {
+		env["LD_PRELOAD"] = fakeTimeLib
+		env["FAKETIME"] = execConfig.FakeTime
+		env["FAKETIME_SKIP_CMDS"] = fakeTimeSkipCmds
+		// Faked monotonic clocks break timeouts in Node, Go and friends
+		env["FAKETIME_DONT_FAKE_MONOTONIC"] = "1"
+	}
+	return env
+}
+
+// clockExecEnv returns clockEnv for a project's execs. With exec.fake_time
+// set, libfaketime is looked up in the container first; a missing library
+// fails the job rather than running with the real clock.
+func (r *Runner) clockExecEnv(ctx context.Context, containerName string, projectConfig *config.ProjectConfig) (map[string]string, error) {
+	var lib string
+	if projectConfig.Exec.FakeTime != "" {
+		candidates := fakeTimeLibs
+		if projectConfig.Exec.FakeTimeLib != "" {
+			candidates = []string{projectConfig.Exec.FakeTimeLib}
+		}
+		for _, path := range candidates {
+			if r.docker.ExecSilent(ctx, containerName, []string{"test", "-f", path}) {
+				lib = path
+				break
+			}
+		}
+		if lib == "" {
+			return nil, fmt.Errorf("exec.fake_time is set but libfaketime was not found in the container (looked in %s)", strings.Join(candidates, ", "))
+		}
+		r.logger.Manfred(fmt.Sprintf("Faking the clock in execs: FAKETIME=%q (%s)", projectConfig.Exec.FakeTime, lib))
+	}
+	return clockEnv(r.config.Job, projectConfig.Exec, lib), nil
+}
@@ -0,0 +1,102 @@
+package job
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mpm/manfred/internal/config"
+)
+
+func TestBuildExecEnv(t *testing.T) {
+	host := map[string]string{
+		"NPM_TOKEN":         "npm-secret",
+		"ANTHROPIC_API_KEY": "host-key",
+		"HOME":              "/root",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := host[name]
+		return v, ok
+	}
+
+	env := buildExecEnv(
+		map[string]string{"ANTHROPIC_API_KEY": "sk-test", "IS_SANDBOX": "1"},
+		[]string{"NPM_TOKEN", "ANTHROPIC_API_KEY", "MISSING"},
+		lookup,
+		map[string]string{"RAILS_ENV": "test", "IS_SANDBOX": "0"},
+	)
+
+	want := map[string]string{
+		"ANTHROPIC_API_KEY": "sk-test",
+		"IS_SANDBOX":        "1",
+		"NPM_TOKEN":         "npm-secret",
+		"RAILS_ENV":         "test",
+	}
+	if len(env) != len(want) {
+		t.Errorf("buildExecEnv() = %v, want %v", env, want)
+	}
+	for k, v := range want {
+		if env[k] != v {
+			t.Errorf("env[%s] = %q, want %q", k, env[k], v)
+		}
+	}
+}
+
+func TestLoggerMask(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewTextSink(&buf))
+	logger.Mask("sk-secret")
+	logger.Mask("")
+
+	logger.Claude("using key sk-secret")
+	w := logger.Writer("TEST")
+	w.Write([]byte("token=sk-"))
+	w.Write([]byte("secret\n"))
+
+	out := buf.String()
+	if strings.Contains(out, "sk-secret") {
+		t.Errorf("output contains secret:\n%s", out)
+	}
+	if got := strings.Count(out, maskedValue); got != 2 {
+		t.Errorf("masked %d times, want 2:\n%s", got, out)
+	}
+	if got := logger.Redact("a sk-secret b"); got != "a **** b" {
+		t.Errorf("Redact() = %q, want %q", got, "a **** b")
+	}
+}
+
+func TestClockEnv(t *testing.T) {
+	jobConfig := config.JobConfig{Timezone: "UTC", Locale: "C.UTF-8"}
+
+	env := clockEnv(jobConfig, config.ExecConfig{}, "")
+	if env["TZ"] != "UTC" || env["LANG"] != "C.UTF-8" || env["LC_ALL"] != "C.UTF-8" {
+		t.Errorf("clockEnv() = %v, want job defaults", env)
+	}
+	if _, ok := env["LD_PRELOAD"]; ok {
+		t.Errorf("clockEnv() without fake time set LD_PRELOAD")
+	}
+
+	execConfig := config.ExecConfig{Timezone: "Europe/Berlin", FakeTime: "@2024-01-01 00:00:00"}
+	env = clockEnv(jobConfig, execConfig, "/usr/lib/faketime/libfaketime.so.1")
+	want := map[string]string{
+		"TZ":                           "Europe/Berlin",
+		"LANG":                         "C.UTF-8",
+		"LC_ALL":                       "C.UTF-8",
+		"LD_PRELOAD":                   "/usr/lib/faketime/libfaketime.so.1",
+		"FAKETIME":                     "@2024-01-01 00:00:00",
+		"FAKETIME_SKIP_CMDS":           "claude,git",
+		"FAKETIME_DONT_FAKE_MONOTONIC": "1",
+	}
+	if len(env) != len(want) {
+		t.Errorf("clockEnv() = %v, want %v", env, want)
+	}
+	for k, v := range want {
+		if env[k] != v {
+			t.Errorf("env[%s] = %q, want %q", k, env[k], v)
+		}
+	}
+
+	if env := clockEnv(config.JobConfig{}, config.ExecConfig{}, ""); len(env) != 0 {
+		t.Errorf("clockEnv() without settings = %v, want empty", env)
+	}
+}
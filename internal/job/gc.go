@@ -0,0 +1,206 @@
+package job
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mpm/manfred/internal/config"
+)
+
+// staleRunningAfter is how long a running marker protects a job directory.
+// Older markers are left behind by a runner that crashed.
+const staleRunningAfter = 24 * time.Hour
+
+// JobDir is a job directory in the jobs directory.
+type JobDir struct {
+	ID      string
+	ModTime time.Time // Newest of the directory and its .manfred state
+	Size    int64
+	Running bool // A runner is still working in it
+}
+
+// GCResult reports what a garbage collection run removed.
+type GCResult struct {
+	Removed   []JobDir
+	Kept      int   // Job directories left in place
+	Protected int   // Kept directories that were exempt from the policy
+	Freed     int64 // Bytes freed by the removed directories
+	Errors    []error
+}
+
+// ListJobDirs returns the job directories in jobsDir, newest first.
+func ListJobDirs(jobsDir string) ([]JobDir, error) {
+	entries, err := os.ReadDir(jobsDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []JobDir
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "job_") {
+			continue
+		}
+		dir, err := readJobDir(jobsDir, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, dir)
+	}
+
+	sort.SliceStable(dirs, func(i, j int) bool {
+		return dirs[i].ModTime.After(dirs[j].ModTime)
+	})
+	return dirs, nil
+}
+
+// readJobDir stats a job directory and sums the size of its files.
+func readJobDir(jobsDir, id string) (JobDir, error) {
+	job := &Job{ID: id, jobsDir: jobsDir}
+	dir := JobDir{ID: id}
+
+	for _, p := range []string{job.JobPath(), filepath.Join(job.JobPath(), ".manfred")} {
+		if info, err := os.Stat(p); err == nil && info.ModTime().After(dir.ModTime) {
+			dir.ModTime = info.ModTime()
+		}
+	}
+	if info, err := os.Stat(job.RunningFile()); err == nil {
+		dir.Running = time.Since(info.ModTime()) < staleRunningAfter
+	}
+
+	err := filepath.WalkDir(job.JobPath(), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable parts (e.g. created by root in the container)
+			// still count as the directory, just not their size
+			if errors.Is(err, fs.ErrPermission) {
+				return nil
+			}
+			return err
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				dir.Size += info.Size()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return JobDir{}, fmt.Errorf("failed to read job %s: %w", id, err)
+	}
+	return dir, nil
+}
+
+// selectGarbage picks the directories to remove under the policy. dirs must
+// be newest first. Protected and running directories are never picked but
+// count towards max_count and max_disk_usage.
+func selectGarbage(dirs []JobDir, policy config.RetentionConfig, maxBytes int64, protected map[string]bool, now time.Time) []JobDir {
+	var garbage []JobDir
+	var count int
+	var size int64
+
+	for _, dir := range dirs {
+		exempt := dir.Running || protected[dir.ID]
+		remove := (policy.MaxAge > 0 && now.Sub(dir.ModTime) > policy.MaxAge) ||
+			(policy.MaxCount > 0 && count >= policy.MaxCount) ||
+			(maxBytes > 0 && size+dir.Size > maxBytes)
+
+		if remove && !exempt {
+			garbage = append(garbage, dir)
+			continue
+		}
+		count++
+		size += dir.Size
+	}
+	return garbage
+}
+
+// CollectGarbage removes the job directories in jobsDir that exceed the
+// retention policy, oldest first. Jobs that are running, listed in protected
+// (e.g. because their session is still active) or whose containers were
+// kept are never removed. With dryRun, nothing is deleted.
+func CollectGarbage(jobsDir string, policy config.RetentionConfig, protected map[string]bool, dryRun bool) (*GCResult, error) {
+	maxBytes, err := policy.MaxDiskBytes()
+	if err != nil {
+		return nil, fmt.Errorf("invalid job.retention.max_disk_usage: %w", err)
+	}
+
+	dirs, err := ListJobDirs(jobsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	kept, err := ListKept(jobsDir)
+	if err != nil {
+		return nil, err
+	}
+	exempt := make(map[string]bool, len(protected)+len(kept))
+	for id := range protected {
+		exempt[id] = true
+	}
+	for _, env := range kept {
+		exempt[env.JobID] = true
+	}
+
+	garbage := selectGarbage(dirs, policy, maxBytes, exempt, time.Now())
+
+	result := &GCResult{Kept: len(dirs) - len(garbage)}
+	for _, dir := range dirs {
+		if dir.Running || exempt[dir.ID] {
+			result.Protected++
+		}
+	}
+
+	// Remove the oldest first, so an interrupted run keeps the newest
+	for i := len(garbage) - 1; i >= 0; i-- {
+		dir := garbage[i]
+		if !dryRun {
+			if err := os.RemoveAll(filepath.Join(jobsDir, dir.ID)); err != nil {
+				result.Errors = append(result.Errors, fmt.Errorf("failed to remove job %s: %w", dir.ID, err))
+				result.Kept++
+				continue
+			}
+		}
+		result.Removed = append(result.Removed, dir)
+		result.Freed += dir.Size
+	}
+	return result, nil
+}
+
+// BusyJobs returns the IDs of jobs in jobsDir whose containers must be left
+// alone: running jobs and jobs whose containers were kept.
+func BusyJobs(jobsDir string) (map[string]bool, error) {
+	kept, err := ListKept(jobsDir)
+	if err != nil {
+		return nil, err
+	}
+	busy := make(map[string]bool, len(kept))
+	for _, env := range kept {
+		busy[env.JobID] = true
+	}
+
+	entries, err := os.ReadDir(jobsDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		job := &Job{ID: entry.Name(), jobsDir: jobsDir}
+		if info, err := os.Stat(job.RunningFile()); err == nil && time.Since(info.ModTime()) < staleRunningAfter {
+			busy[job.ID] = true
+		}
+	}
+	return busy, nil
+}
+
+// markRunning writes the job's running marker, which protects the job from
+// garbage collection until removed.
+func markRunning(job *Job) error {
+	return os.WriteFile(job.RunningFile(), []byte(fmt.Sprintf("%d\n", os.Getpid())), 0644)
+}
@@ -0,0 +1,166 @@
+package job
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mpm/manfred/internal/config"
+)
+
+func TestSelectGarbage(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	dirs := []JobDir{
+		{ID: "job_5", ModTime: now.Add(-1 * time.Hour), Size: 100},
+		{ID: "job_4", ModTime: now.Add(-2 * time.Hour), Size: 100},
+		{ID: "job_3", ModTime: now.Add(-3 * time.Hour), Size: 100, Running: true},
+		{ID: "job_2", ModTime: now.Add(-48 * time.Hour), Size: 100},
+		{ID: "job_1", ModTime: now.Add(-72 * time.Hour), Size: 100},
+	}
+
+	tests := []struct {
+		name      string
+		policy    config.RetentionConfig
+		maxBytes  int64
+		protected map[string]bool
+		want      []string
+	}{
+		{name: "no limits"},
+		{
+			name:   "max age",
+			policy: config.RetentionConfig{MaxAge: 24 * time.Hour},
+			want:   []string{"job_2", "job_1"},
+		},
+		{
+			name:      "max age protected",
+			policy:    config.RetentionConfig{MaxAge: 24 * time.Hour},
+			protected: map[string]bool{"job_1": true},
+			want:      []string{"job_2"},
+		},
+		{
+			name:   "max count keeps running",
+			policy: config.RetentionConfig{MaxCount: 2},
+			want:   []string{"job_2", "job_1"},
+		},
+		{
+			name:     "max disk usage",
+			maxBytes: 250,
+			want:     []string{"job_2", "job_1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := selectGarbage(dirs, tt.policy, tt.maxBytes, tt.protected, now)
+			var ids []string
+			for _, dir := range got {
+				ids = append(ids, dir.ID)
+			}
+			if len(ids) != len(tt.want) {
+				t.Fatalf("selectGarbage() = %v, want %v", ids, tt.want)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Errorf("selectGarbage() = %v, want %v", ids, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	jobsDir := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+
+	for _, id := range []string{"job_old", "job_kept", "job_session", "job_running", "job_new"} {
+		job := &Job{ID: id, jobsDir: jobsDir}
+		if err := job.CreateDirectories(); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(job.PromptFile(), []byte("prompt"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if id == "job_running" {
+			if err := markRunning(job); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if id != "job_new" {
+			for _, p := range []string{filepath.Join(job.JobPath(), ".manfred"), job.JobPath()} {
+				if err := os.Chtimes(p, old, old); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	if err := registerKept(jobsDir, KeptEnvironment{JobID: "job_kept"}); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := config.RetentionConfig{MaxAge: 24 * time.Hour}
+	protected := map[string]bool{"job_session": true}
+
+	result, err := CollectGarbage(jobsDir, policy, protected, true)
+	if err != nil {
+		t.Fatalf("CollectGarbage(dry run) error: %v", err)
+	}
+	if len(result.Removed) != 1 || result.Removed[0].ID != "job_old" {
+		t.Fatalf("dry run removed %v, want job_old", result.Removed)
+	}
+	if _, err := os.Stat(filepath.Join(jobsDir, "job_old")); err != nil {
+		t.Errorf("dry run deleted job_old: %v", err)
+	}
+
+	result, err = CollectGarbage(jobsDir, policy, protected, false)
+	if err != nil {
+		t.Fatalf("CollectGarbage() error: %v", err)
+	}
+	if len(result.Removed) != 1 || result.Kept != 4 || result.Protected != 3 {
+		t.Errorf("result = %d removed, %d kept, %d protected; want 1, 4, 3", len(result.Removed), result.Kept, result.Protected)
+	}
+	if result.Freed != int64(len("prompt")) {
+		t.Errorf("freed = %d, want %d", result.Freed, len("prompt"))
+	}
+	if _, err := os.Stat(filepath.Join(jobsDir, "job_old")); !os.IsNotExist(err) {
+		t.Errorf("job_old still exists: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(jobsDir, keptFile)); err != nil {
+		t.Errorf("kept registry removed: %v", err)
+	}
+
+	if _, err := CollectGarbage(jobsDir, config.RetentionConfig{MaxDiskUsage: "lots"}, nil, true); err == nil {
+		t.Error("CollectGarbage() accepted an invalid max_disk_usage")
+	}
+}
+
+func TestBusyJobs(t *testing.T) {
+	jobsDir := t.TempDir()
+	for _, id := range []string{"job_done", "job_running", "job_stale"} {
+		job := &Job{ID: id, jobsDir: jobsDir}
+		if err := job.CreateDirectories(); err != nil {
+			t.Fatal(err)
+		}
+		if id != "job_done" {
+			if err := markRunning(job); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	stale := time.Now().Add(-2 * staleRunningAfter)
+	if err := os.Chtimes((&Job{ID: "job_stale", jobsDir: jobsDir}).RunningFile(), stale, stale); err != nil {
+		t.Fatal(err)
+	}
+	if err := registerKept(jobsDir, KeptEnvironment{JobID: "job_kept"}); err != nil {
+		t.Fatal(err)
+	}
+
+	busy, err := BusyJobs(jobsDir)
+	if err != nil {
+		t.Fatalf("BusyJobs() error: %v", err)
+	}
+	if len(busy) != 2 || !busy["job_running"] || !busy["job_kept"] {
+		t.Errorf("BusyJobs() = %v, want job_running and job_kept", busy)
+	}
+}
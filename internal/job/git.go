@@ -0,0 +1,206 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/gitops"
+)
+
+// gitRepo returns the job's working tree, reusing the credentials of the
+// clone when there was one.
+func (j *Job) gitRepo() *gitops.Repo {
+	if j.repo != nil {
+		return j.repo
+	}
+	return gitops.Open(j.WorkspacePath())
+}
+
+// sanitizeWorkspace removes git config that Claude or the project's code
+// may have planted in the cloned workspace to run commands on the host when
+// MANFRED commits, diffs or pushes. Repository code only ever runs inside
+// the job's containers, so this runs after they have stopped and nothing
+// can rewrite the config between the check and the host's git commands.
+func (r *Runner) sanitizeWorkspace(ctx context.Context, job *Job) error {
+	if len(job.Repos) > 0 {
+		return r.sanitizeRepositories(ctx, job)
+	}
+	if _, err := os.Stat(job.WorkspacePath()); err != nil {
+		return nil
+	}
+	removed, err := job.gitRepo().Sanitize(ctx)
+	if err != nil {
+		return fmt.Errorf("unsafe workspace: %w", err)
+	}
+	if len(removed) > 0 {
+		r.logger.Manfred(fmt.Sprintf("Removed workspace git config before running git on the host: %s", strings.Join(removed, ", ")))
+	}
+	return nil
+}
+
+// pushBranch commits any changes Claude left uncommitted, brings the job's
+// branch up to date with its base (see syncBranch) and pushes it to origin.
+func (r *Runner) pushBranch(ctx context.Context, job *Job, projectConfig *config.ProjectConfig) error {
+	repo := job.gitRepo()
+
+	if err := r.commitPendingChanges(ctx, job); err != nil {
+		return err
+	}
+
+	if job.BaseSHA != "" {
+		if n, err := repo.CountCommitsSince(ctx, job.BaseSHA); err == nil && n == 0 {
+			r.logger.Manfred("No new commits, skipping push")
+			return nil
+		}
+	}
+
+	repo, err := r.refreshAuth(ctx, repo, projectConfig, projectConfig.Repo)
+	if err != nil {
+		return err
+	}
+	job.repo = repo
+
+	if err := r.syncBranch(ctx, job, projectConfig); err != nil {
+		return err
+	}
+
+	r.logger.Manfred(fmt.Sprintf("Pushing branch %s...", job.BranchName))
+	err = repo.Push(ctx, job.BranchName, true)
+	if gitops.IsKind(err, gitops.KindProtected) {
+		return fmt.Errorf("branch protection on origin refused the push of %s; exempt MANFRED or the branch from the rule: %w", job.BranchName, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to push branch %s: %w", job.BranchName, err)
+	}
+
+	headSHA, err := repo.RevParse(ctx, "HEAD")
+	if err != nil {
+		return fmt.Errorf("failed to get head SHA: %w", err)
+	}
+
+	job.HeadSHA = headSHA
+	job.Pushed = true
+	r.logger.Manfred(fmt.Sprintf("Branch pushed, head SHA: %s", job.HeadSHA))
+	return nil
+}
+
+// syncBranch checks the job's branch against origin before it is pushed. A
+// branch someone else pushed to while the job ran is not pushed over. When
+// the base branch moved, a branch that is not on origin yet is rebased onto
+// it if the project rebases (job.rebase); a rebase that conflicts is aborted
+// and recorded as job.Conflict, and fails the push instead of pushing stale
+// code. Branches already pushed keep the history reviewers saw.
+//
+// The rebase runs on the host, like every git command that can reach
+// origin, in the workspace sanitizeWorkspace checked. The tests ran before
+// it, so a passed test result is marked as such (TestResult.Rebased).
+func (r *Runner) syncBranch(ctx context.Context, job *Job, projectConfig *config.ProjectConfig) error {
+	repo := job.gitRepo()
+
+	published := false
+	switch err := repo.Fetch(ctx, job.BranchName); {
+	case err == nil:
+		remoteHead, err := repo.RevParse(ctx, "refs/remotes/origin/"+job.BranchName)
+		if err != nil {
+			return err
+		}
+		if base, err := repo.MergeBase(ctx, remoteHead, "HEAD"); err != nil || base != remoteHead {
+			return fmt.Errorf("branch %s moved on origin to %s while the job ran; not pushing over it", job.BranchName, remoteHead)
+		}
+		published = true
+	case !gitops.IsKind(err, gitops.KindNotFound):
+		return fmt.Errorf("failed to fetch branch %s: %w", job.BranchName, err)
+	}
+
+	base := projectConfig.DefaultBranch
+	if base == "" {
+		var err error
+		if base, err = repo.RemoteDefaultBranch(ctx); err != nil {
+			r.logger.Manfred(fmt.Sprintf("Warning: could not find the base branch, pushing without comparing: %v", err))
+			return nil
+		}
+	}
+	if base == job.BranchName {
+		return nil
+	}
+	if err := repo.Fetch(ctx, base); err != nil {
+		return fmt.Errorf("failed to fetch base branch %s: %w", base, err)
+	}
+	onto, err := repo.RevParse(ctx, "refs/remotes/origin/"+base)
+	if err != nil {
+		return err
+	}
+	forkPoint, err := repo.MergeBase(ctx, "HEAD", onto)
+	if err != nil {
+		// A shallow clone may lack the fork point
+		if err := repo.Unshallow(ctx); err != nil {
+			return fmt.Errorf("failed to fetch the history of %s: %w", job.BranchName, err)
+		}
+		if forkPoint, err = repo.MergeBase(ctx, "HEAD", onto); err != nil {
+			return fmt.Errorf("branch %s shares no history with %s: %w", job.BranchName, base, err)
+		}
+	}
+	if forkPoint == onto {
+		return nil
+	}
+	if !r.config.Rebase(projectConfig) {
+		r.logger.Manfred(fmt.Sprintf("Base branch %s moved to %s, pushing without rebasing (job.rebase is off)", base, onto))
+		return nil
+	}
+	if published {
+		r.logger.Manfred(fmt.Sprintf("Base branch %s moved to %s, pushing without rebasing %s, which is already on origin", base, onto, job.BranchName))
+		return nil
+	}
+
+	// The job's commits stay on top, which finds its base after the rebase
+	own, err := repo.CountCommitsSince(ctx, job.BaseSHA)
+	if err != nil {
+		return err
+	}
+	r.logger.Manfred(fmt.Sprintf("Base branch %s moved to %s, rebasing %s onto it...", base, onto, job.BranchName))
+	files, err := repo.Rebase(ctx, onto)
+	if gitops.IsKind(err, gitops.KindConflict) {
+		job.Conflict = &Conflict{Base: base, BaseSHA: onto, Files: files}
+		return fmt.Errorf("rebasing %s onto %s conflicts in %s; not pushing", job.BranchName, base, strings.Join(files, ", "))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to rebase %s onto %s: %w", job.BranchName, base, err)
+	}
+	if job.BaseSHA, err = repo.RevParse(ctx, fmt.Sprintf("HEAD~%d", own)); err != nil {
+		job.BaseSHA = onto
+	}
+	r.logger.Manfred(fmt.Sprintf("Rebased onto %s; the tests ran before the rebase", base))
+	if job.TestResult != nil {
+		job.TestResult.Rebased = true
+	}
+	return nil
+}
+
+// commitPendingChanges commits uncommitted changes using Claude's commit
+// message, so nothing is lost when pushing.
+func (r *Runner) commitPendingChanges(ctx context.Context, job *Job) error {
+	repo := job.gitRepo()
+
+	status, err := repo.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check git status: %w", err)
+	}
+	if len(status) == 0 {
+		return nil
+	}
+
+	message := job.CommitMessage
+	if message == "" {
+		message = fmt.Sprintf("Changes from MANFRED job %s", job.ID)
+	}
+
+	r.logger.Manfred("Committing uncommitted changes...")
+	if err := repo.CommitAll(ctx, message); err != nil {
+		return fmt.Errorf("failed to commit changes: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package job
+
+import (
+	"context"
+
+	"github.com/mpm/manfred/internal/anthropic"
+	"github.com/mpm/manfred/internal/config"
+)
+
+// NewHealthCheck returns a circuit breaker around a ping of the Anthropic
+// API, as configured by job.health, or nil when the check is disabled.
+// Processes running many jobs share one, see RunOptions.Health. The ping
+// authenticates with the configured API key; without one, as with Claude
+// subscription credentials, it only checks that the API is reachable.
+func NewHealthCheck(cfg *config.Config) *anthropic.Breaker {
+	health := cfg.Job.Health
+	if !health.Enabled {
+		return nil
+	}
+	apiKey, _ := cfg.AnthropicAPIKey()
+	client := anthropic.NewClient(apiKey, anthropic.WithBaseURL(cfg.Job.Planning.BaseURL))
+	return anthropic.NewBreaker(func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, health.Timeout)
+		defer cancel()
+		return client.Ping(ctx)
+	}, health.Interval)
+}
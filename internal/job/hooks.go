@@ -0,0 +1,171 @@
+package job
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mpm/manfred/internal/config"
+)
+
+// HookEvent is a point in a job where plugin hooks run.
+type HookEvent string
+
+const (
+	// HookPreClone runs before the repository is cloned.
+	HookPreClone HookEvent = "pre_clone"
+
+	// HookPostClaude runs after Claude finished the main task (and the test
+	// phase), before the commit message is requested.
+	HookPostClaude HookEvent = "post_claude"
+
+	// HookPreFinalize runs before the branch is pushed or the job finalized.
+	// Hooks may replace the commit message.
+	HookPreFinalize HookEvent = "pre_finalize"
+)
+
+// hookProtocolVersion is sent with every hook request.
+const hookProtocolVersion = 1
+
+// defaultHookTimeout bounds hooks without a configured timeout.
+const defaultHookTimeout = 5 * time.Minute
+
+// HookRequest is written as JSON to a hook's stdin.
+type HookRequest struct {
+	Version int       `json:"version"`
+	Event   HookEvent `json:"event"`
+	Job     HookJob   `json:"job"`
+}
+
+// HookJob is the job state passed to hooks.
+type HookJob struct {
+	ID            string      `json:"id"`
+	Project       string      `json:"project"`
+	Prompt        string      `json:"prompt"`
+	Branch        string      `json:"branch,omitempty"`
+	JobDir        string      `json:"job_dir"`
+	Workspace     string      `json:"workspace,omitempty"`
+	CommitMessage string      `json:"commit_message,omitempty"`
+	Plan          string      `json:"plan,omitempty"`
+	Tests         *TestResult `json:"tests,omitempty"`
+}
+
+// HookResponse is read as JSON from a hook's stdout. Empty output means
+// "continue".
+type HookResponse struct {
+	// Action is "continue" (default) or "abort".
+	Action string `json:"action"`
+
+	// Message is logged, and used as the failure reason on abort.
+	Message string `json:"message"`
+
+	// CommitMessage replaces the job's commit message (pre_finalize only).
+	CommitMessage string `json:"commit_message"`
+}
+
+// runHooks runs the global and project hooks registered for event, in that
+// order. A hook answering "abort", or a required hook failing, stops the
+// job.
+func (r *Runner) runHooks(ctx context.Context, event HookEvent, job *Job, projectConfig *config.ProjectConfig) error {
+	hooks := append(slices.Clone(r.config.Job.Hooks), projectConfig.Hooks...)
+	for _, hook := range hooks {
+		if !slices.Contains(hook.Events, string(event)) {
+			continue
+		}
+
+		r.logger.Manfred(fmt.Sprintf("Running %s hook %s", event, hook.Name))
+		resp, err := r.runHook(ctx, hook, event, job)
+		if err != nil {
+			if hook.Required {
+				return fmt.Errorf("hook %s failed: %w", hook.Name, err)
+			}
+			r.logger.Manfred(fmt.Sprintf("Warning: hook %s failed: %v", hook.Name, err))
+			continue
+		}
+
+		if resp.Message != "" {
+			r.logger.Log("HOOK", fmt.Sprintf("%s: %s", hook.Name, resp.Message))
+		}
+		switch resp.Action {
+		case "", "continue":
+		case "abort":
+			return fmt.Errorf("hook %s aborted the job: %s", hook.Name, resp.Message)
+		default:
+			r.logger.Manfred(fmt.Sprintf("Warning: hook %s returned unknown action %q", hook.Name, resp.Action))
+		}
+
+		if event == HookPreFinalize && resp.CommitMessage != "" {
+			job.CommitMessage = resp.CommitMessage
+			r.logger.Manfred(fmt.Sprintf("Commit message replaced by hook %s", hook.Name))
+		}
+	}
+	return nil
+}
+
+// runHook executes one hook with the job state on stdin and parses its
+// response. Stderr is streamed to the log.
+func (r *Runner) runHook(ctx context.Context, hook config.HookConfig, event HookEvent, job *Job) (*HookResponse, error) {
+	req := HookRequest{
+		Version: hookProtocolVersion,
+		Event:   event,
+		Job: HookJob{
+			ID:            job.ID,
+			Project:       job.ProjectName,
+			Prompt:        job.Prompt,
+			Branch:        job.BranchName,
+			JobDir:        job.JobPath(),
+			CommitMessage: job.CommitMessage,
+			Plan:          job.Plan,
+			Tests:         job.TestResult,
+		},
+	}
+	if _, err := os.Stat(job.WorkspacePath()); err == nil {
+		req.Job.Workspace = job.WorkspacePath()
+	}
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, hook.Command, hook.Args...)
+	cmd.Dir = job.JobPath()
+	cmd.Env = append(os.Environ(),
+		"MANFRED_HOOK_EVENT="+string(event),
+		"MANFRED_JOB_ID="+job.ID,
+	)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	hookErr := r.logger.Writer("HOOK")
+	defer hookErr.Close()
+	cmd.Stderr = hookErr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("timed out after %s", timeout)
+		}
+		return nil, err
+	}
+
+	resp := &HookResponse{}
+	if out := bytes.TrimSpace(stdout.Bytes()); len(out) > 0 {
+		if err := json.Unmarshal(out, resp); err != nil {
+			return nil, fmt.Errorf("invalid response: %w", err)
+		}
+	}
+	resp.Action = strings.ToLower(resp.Action)
+	return resp, nil
+}
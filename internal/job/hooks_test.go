@@ -0,0 +1,129 @@
+package job
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mpm/manfred/internal/config"
+)
+
+// writeHook creates an executable shell script hook.
+func writeHook(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hook.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newHookTestRunner(t *testing.T, hooks ...config.HookConfig) (*Runner, *Job) {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	cfg := &config.Config{JobsDir: t.TempDir()}
+	cfg.Job.Hooks = hooks
+	job := New("proj", "do things", cfg.JobsDir)
+	if err := job.CreateDirectories(); err != nil {
+		t.Fatal(err)
+	}
+	return &Runner{config: cfg, logger: NewLogger(NewTextSink(&bytes.Buffer{}))}, job
+}
+
+func TestRunHooksRequest(t *testing.T) {
+	reqFile := filepath.Join(t.TempDir(), "request.json")
+	hook := config.HookConfig{
+		Name:    "record",
+		Command: writeHook(t, `cat > "$1"; echo '{"message": "recorded"}'`),
+		Args:    []string{reqFile},
+		Events:  []string{string(HookPostClaude)},
+	}
+	r, job := newHookTestRunner(t, hook)
+	job.TestResult = &TestResult{Passed: true, Attempts: 1}
+
+	if err := r.runHooks(context.Background(), HookPreClone, job, &config.ProjectConfig{}); err != nil {
+		t.Fatalf("runHooks(pre_clone) error = %v", err)
+	}
+	if _, err := os.Stat(reqFile); err == nil {
+		t.Fatal("hook ran for an event it is not registered for")
+	}
+
+	if err := r.runHooks(context.Background(), HookPostClaude, job, &config.ProjectConfig{}); err != nil {
+		t.Fatalf("runHooks(post_claude) error = %v", err)
+	}
+	data, err := os.ReadFile(reqFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req HookRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatalf("invalid request JSON: %v\n%s", err, data)
+	}
+	if req.Version != hookProtocolVersion || req.Event != HookPostClaude {
+		t.Errorf("request = %+v, want version %d event %s", req, hookProtocolVersion, HookPostClaude)
+	}
+	if req.Job.ID != job.ID || req.Job.Project != "proj" || req.Job.Prompt != "do things" {
+		t.Errorf("request job = %+v", req.Job)
+	}
+	if req.Job.Tests == nil || !req.Job.Tests.Passed {
+		t.Errorf("request tests = %+v, want passed", req.Job.Tests)
+	}
+}
+
+func TestRunHooksAbort(t *testing.T) {
+	hook := config.HookConfig{
+		Name:    "scan",
+		Command: writeHook(t, `echo '{"action": "abort", "message": "secrets found"}'`),
+		Events:  []string{string(HookPreFinalize)},
+	}
+	r, job := newHookTestRunner(t)
+	project := &config.ProjectConfig{Hooks: []config.HookConfig{hook}}
+
+	err := r.runHooks(context.Background(), HookPreFinalize, job, project)
+	if err == nil || !strings.Contains(err.Error(), "secrets found") {
+		t.Errorf("runHooks() error = %v, want abort with message", err)
+	}
+}
+
+func TestRunHooksCommitMessage(t *testing.T) {
+	hook := config.HookConfig{
+		Name:    "ticket",
+		Command: writeHook(t, `printf '%s' '{"commit_message": "feat: x\n\nRefs: PROJ-1"}'`),
+		Events:  []string{string(HookPreFinalize)},
+	}
+	r, job := newHookTestRunner(t, hook)
+	job.CommitMessage = "feat: x"
+
+	if err := r.runHooks(context.Background(), HookPreFinalize, job, &config.ProjectConfig{}); err != nil {
+		t.Fatalf("runHooks() error = %v", err)
+	}
+	if want := "feat: x\n\nRefs: PROJ-1"; job.CommitMessage != want {
+		t.Errorf("CommitMessage = %q, want %q", job.CommitMessage, want)
+	}
+}
+
+func TestRunHooksFailure(t *testing.T) {
+	failing := config.HookConfig{
+		Name:    "broken",
+		Command: writeHook(t, `echo oops >&2; exit 3`),
+		Events:  []string{string(HookPreClone)},
+	}
+
+	r, job := newHookTestRunner(t, failing)
+	if err := r.runHooks(context.Background(), HookPreClone, job, &config.ProjectConfig{}); err != nil {
+		t.Errorf("optional hook failure: runHooks() error = %v, want nil", err)
+	}
+
+	failing.Required = true
+	r, job = newHookTestRunner(t, failing)
+	if err := r.runHooks(context.Background(), HookPreClone, job, &config.ProjectConfig{}); err == nil {
+		t.Error("required hook failure: runHooks() error = nil, want error")
+	}
+}
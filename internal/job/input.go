@@ -0,0 +1,143 @@
+package job
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Input records what a job was asked to do, so the same request can be
+// recognized when it is queued again.
+type Input struct {
+	JobID      string    `json:"job_id"`
+	Hash       string    `json:"hash"`
+	Project    string    `json:"project"`
+	BaseBranch string    `json:"base_branch"`
+	BaseSHA    string    `json:"base_sha,omitempty"`
+	Status     Status    `json:"status"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// DuplicateError is returned when job.duplicates is skip and a job with the
+// same input is running or has completed.
+type DuplicateError struct {
+	JobID string // The earlier job
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("duplicate of job %s (same project, prompt and base branch)", e.JobID)
+}
+
+// InputHash returns the content hash of a job request: the project, the
+// base branch, the commit it pointed to ("" when unknown) and the prompt,
+// with surrounding whitespace and line ending differences ignored. The same
+// prompt against a base branch that has moved on is a new request.
+func InputHash(project, baseBranch, baseSHA, prompt string) string {
+	prompt = strings.TrimSpace(strings.ReplaceAll(prompt, "\r\n", "\n"))
+	sum := sha256.Sum256([]byte(project + "\x00" + baseBranch + "\x00" + baseSHA + "\x00" + prompt))
+	return hex.EncodeToString(sum[:])
+}
+
+// InputFile returns the path of the job's input record.
+func (j *Job) InputFile() string {
+	return filepath.Join(j.JobPath(), ".manfred", "input.json")
+}
+
+// input returns the job's input record with its current status.
+func (j *Job) input(baseBranch string) *Input {
+	return &Input{
+		JobID:      j.ID,
+		Hash:       j.InputHash,
+		Project:    j.ProjectName,
+		BaseBranch: baseBranch,
+		BaseSHA:    j.BaseSHA,
+		Status:     j.Status,
+		CreatedAt:  j.CreatedAt,
+	}
+}
+
+// writeInput records the job's input and current status.
+func writeInput(job *Job, baseBranch string) error {
+	data, err := json.MarshalIndent(job.input(baseBranch), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(job.InputFile(), append(data, '\n'), 0644)
+}
+
+// LoadInput reads the input record of a job.
+func LoadInput(jobsDir, jobID string) (*Input, error) {
+	job := &Job{ID: jobID, jobsDir: jobsDir}
+	data, err := os.ReadFile(job.InputFile())
+	if err != nil {
+		return nil, err
+	}
+	var input Input
+	if err := json.Unmarshal(data, &input); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", job.InputFile(), err)
+	}
+	return &input, nil
+}
+
+// FindDuplicate returns the newest other job in jobsDir with the input hash
+// of self that is running or has completed. Failed jobs don't count, so a
+// failed request can be retried. A running job only counts if it recorded
+// its input before self: jobs record theirs before checking, so of two
+// identical jobs started at once exactly one is the duplicate. It returns
+// nil if there is none.
+func FindDuplicate(jobsDir string, self *Input) (*Input, error) {
+	entries, err := os.ReadDir(jobsDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var found *Input
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "job_") {
+			continue
+		}
+		if entry.Name() == self.JobID {
+			continue
+		}
+		input, err := LoadInput(jobsDir, entry.Name())
+		if err != nil || input.Hash != self.Hash {
+			continue
+		}
+		switch input.Status {
+		case StatusCompleted:
+		case StatusRunning:
+			if !recordedBefore(input, self) {
+				continue
+			}
+			job := &Job{ID: entry.Name(), jobsDir: jobsDir}
+			info, err := os.Stat(job.RunningFile())
+			if err != nil || time.Since(info.ModTime()) >= staleRunningAfter {
+				continue
+			}
+		default:
+			continue
+		}
+		if found == nil || input.CreatedAt.After(found.CreatedAt) {
+			found = input
+		}
+	}
+	return found, nil
+}
+
+// recordedBefore reports whether a was recorded before b, with the job ID
+// breaking ties so that two jobs never both come first.
+func recordedBefore(a, b *Input) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.JobID < b.JobID
+}
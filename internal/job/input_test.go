@@ -0,0 +1,95 @@
+package job
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestInputHash(t *testing.T) {
+	base := InputHash("proj", "main", "abc123", "Fix the bug")
+	if got := InputHash("proj", "main", "abc123", "  Fix the bug\r\n"); got != base {
+		t.Errorf("InputHash() differs for surrounding whitespace: %s != %s", got, base)
+	}
+	for _, other := range []string{
+		InputHash("other", "main", "abc123", "Fix the bug"),
+		InputHash("proj", "develop", "abc123", "Fix the bug"),
+		InputHash("proj", "main", "def456", "Fix the bug"),
+		InputHash("proj", "main", "abc123", "Fix the other bug"),
+	} {
+		if other == base {
+			t.Errorf("InputHash() = %s for a different input", other)
+		}
+	}
+}
+
+func TestFindDuplicate(t *testing.T) {
+	jobsDir := t.TempDir()
+	hash := InputHash("proj", "main", "", "prompt")
+	now := time.Now()
+	self := &Input{JobID: "job_self", Hash: hash, Status: StatusRunning, CreatedAt: now}
+
+	found, err := FindDuplicate(jobsDir, self)
+	if err != nil || found != nil {
+		t.Fatalf("FindDuplicate() on empty dir = %v, %v; want nil, nil", found, err)
+	}
+
+	record := func(id string, status Status, created time.Time) *Job {
+		t.Helper()
+		j := &Job{ID: id, ProjectName: "proj", Status: status, CreatedAt: created, InputHash: hash, jobsDir: jobsDir}
+		if err := j.CreateDirectories(); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeInput(j, "main"); err != nil {
+			t.Fatal(err)
+		}
+		return j
+	}
+
+	record("job_failed", StatusFailed, now)
+	if found, _ := FindDuplicate(jobsDir, self); found != nil {
+		t.Errorf("FindDuplicate() = %s, want failed jobs ignored", found.JobID)
+	}
+
+	record("job_old", StatusCompleted, now.Add(-time.Hour))
+	record("job_new", StatusCompleted, now.Add(-time.Minute))
+	found, err = FindDuplicate(jobsDir, self)
+	if err != nil {
+		t.Fatalf("FindDuplicate() error = %v", err)
+	}
+	if found == nil || found.JobID != "job_new" {
+		t.Errorf("FindDuplicate() = %+v, want job_new", found)
+	}
+
+	running := record("job_running", StatusRunning, now.Add(-time.Second))
+	if found, _ := FindDuplicate(jobsDir, self); found.JobID != "job_new" {
+		t.Errorf("FindDuplicate() = %s, want running jobs without a marker ignored", found.JobID)
+	}
+	if err := os.WriteFile(running.RunningFile(), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := FindDuplicate(jobsDir, self); found.JobID != "job_running" {
+		t.Errorf("FindDuplicate() = %s, want job_running", found.JobID)
+	}
+
+	// Of two identical jobs recorded at once, only the later one is the
+	// duplicate, whichever checks first.
+	later := record("job_later", StatusRunning, now.Add(time.Second))
+	if err := os.WriteFile(later.RunningFile(), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := FindDuplicate(jobsDir, self); found.JobID != "job_running" {
+		t.Errorf("FindDuplicate() = %s, want jobs recorded later ignored", found.JobID)
+	}
+	if found, _ := FindDuplicate(jobsDir, later.input("main")); found.JobID != "job_running" {
+		t.Errorf("FindDuplicate(job_later) = %s, want job_running", found.JobID)
+	}
+	if found, _ := FindDuplicate(jobsDir, running.input("main")); found.JobID != "job_new" {
+		t.Errorf("FindDuplicate(job_running) = %s, want itself and later jobs ignored", found.JobID)
+	}
+
+	other := &Input{JobID: "job_self", Hash: InputHash("proj", "main", "", "other"), CreatedAt: now}
+	if found, _ := FindDuplicate(jobsDir, other); found != nil {
+		t.Errorf("FindDuplicate() = %s for an unknown hash", found.JobID)
+	}
+}
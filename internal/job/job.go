@@ -7,6 +7,10 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/mpm/manfred/internal/anthropic"
+	"github.com/mpm/manfred/internal/docker"
+	"github.com/mpm/manfred/internal/gitops"
 )
 
 // Status represents the current state of a job.
@@ -24,21 +28,86 @@ type Job struct {
 	ID          string
 	ProjectName string
 	Prompt      string
+	Context     string // Project context prepended to Prompt, see config.ProjectContext
 	Status      Status
 	CreatedAt   time.Time
 	StartedAt   *time.Time
 	CompletedAt *time.Time
 	Error       string
 
+	// InputHash identifies the request, see InputHash; DuplicateOf is the
+	// earlier job with the same input, when job.duplicates is warn
+	InputHash   string
+	DuplicateOf string
+
 	// Git-related fields
 	BranchName string
 	BaseSHA    string
+	HeadSHA    string // Pushed head commit, set when Pushed
+	Pushed     bool
+
+	// Conflict is set when the branch could not be rebased onto its moved
+	// base branch and was not pushed
+	Conflict *Conflict
+
+	// Repos holds the outcome per repository of a project with several
+	// (repos: in project.yml); the fields above then describe none of them,
+	// except BranchName, which they share, and Pushed, set if any was
+	Repos []RepoResult
 
 	// Output
 	CommitMessage string
+	Plan          string // Set by plan-only jobs
+
+	// Test phase results (nil when no test command is configured)
+	TestResult *TestResult
+
+	// Diff summarizes the changes base..HEAD (nil when nothing was cloned)
+	Diff *Diff
+
+	// Artifacts lists the files collected from the container
+	Artifacts []Artifact
+
+	// DroppedOutput counts exec output bytes cut by job.output limits
+	DroppedOutput int64
+
+	// Ports lists the host ports the job's compose services published
+	Ports []docker.PortMapping
+
+	// Resources holds the peak container usage (nil when not monitored)
+	Resources *ResourceUsage
+
+	// Tokens counts the Anthropic API tokens of api mode planning (nil
+	// when Claude Code ran in the container, which does not report them)
+	Tokens *anthropic.Usage
 
 	// Paths
 	jobsDir string
+
+	// repo is the cloned workspace, carrying clone credentials for pushes
+	repo *gitops.Repo
+
+	// execUser is docker.user, whom Claude and test execs run as
+	execUser string
+}
+
+// Conflict describes a failed rebase of a job's branch onto its base.
+type Conflict struct {
+	Base    string   `json:"base"`     // Base branch, e.g. main
+	BaseSHA string   `json:"base_sha"` // Head of the base branch the rebase was onto
+	Files   []string `json:"files"`    // Paths both sides changed
+}
+
+// TestResult records the outcome of the project's test suite run.
+type TestResult struct {
+	Command     string `json:"command"`
+	Detected    bool   `json:"detected,omitempty"` // Command was auto-detected, not configured
+	Passed      bool   `json:"passed"`
+	ExitCode    int    `json:"exit_code"`         // Exit code of the last test run
+	Attempts    int    `json:"attempts"`          // Number of test runs, including the initial one
+	FixAttempts int    `json:"fix_attempts"`      // Number of times Claude was asked to fix failures
+	Output      string `json:"output"`            // Output of the last test run
+	Rebased     bool   `json:"rebased,omitempty"` // The branch was rebased after the run, so it tested other code
 }
 
 // New creates a new job with a generated ID.
@@ -68,17 +137,90 @@ func (j *Job) CommitMessageFile() string {
 	return filepath.Join(j.JobPath(), ".manfred", "commit_message.txt")
 }
 
+// LogFile returns the path of the job's log.
+func (j *Job) LogFile() string {
+	return filepath.Join(j.JobPath(), ".manfred", "job.log")
+}
+
+// RawLogFile returns the path of the job's log before sanitizing.
+func (j *Job) RawLogFile() string {
+	return filepath.Join(j.JobPath(), ".manfred", "job.raw.log")
+}
+
 // PromptFile returns the path to the prompt file.
 func (j *Job) PromptFile() string {
 	return filepath.Join(j.JobPath(), "prompt.txt")
 }
 
+// ContextFile returns the path of the project context Claude is pointed to.
+func (j *Job) ContextFile() string {
+	return filepath.Join(j.JobPath(), "context.md")
+}
+
+// PlanFile returns the path to the plan file written in plan-only jobs.
+func (j *Job) PlanFile() string {
+	return filepath.Join(j.JobPath(), ".manfred", "plan.md")
+}
+
+// ConversationDir returns the directory Claude's conversation is saved to
+// for a later job to continue (see RunOptions.SaveConversation).
+func (j *Job) ConversationDir() string {
+	return filepath.Join(j.JobPath(), ".manfred", "conversation")
+}
+
+// TestOutputFile returns the path to the output of the last test run.
+func (j *Job) TestOutputFile() string {
+	return filepath.Join(j.JobPath(), ".manfred", "test_output.txt")
+}
+
 // CredentialsFile returns the path to the credentials file in the job directory.
 func (j *Job) CredentialsFile() string {
 	return filepath.Join(j.JobPath(), ".credentials.json")
 }
 
-// ClaudeBundlePath returns the path to the Claude bundle directory in the job.
+// OutputsDir returns the directory the output helper writes to.
+func (j *Job) OutputsDir() string {
+	return filepath.Join(j.JobPath(), ".manfred", "outputs")
+}
+
+// OutputHelperFile returns the path of the output helper script.
+func (j *Job) OutputHelperFile() string {
+	return filepath.Join(j.JobPath(), "bin", "manfred-output")
+}
+
+// DiffFile returns the path of the patch of the job's changes.
+func (j *Job) DiffFile() string {
+	return filepath.Join(j.JobPath(), ".manfred", "diff.patch")
+}
+
+// DiffStatFile returns the path of the job's diff summary.
+func (j *Job) DiffStatFile() string {
+	return filepath.Join(j.JobPath(), ".manfred", "diff.json")
+}
+
+// ArtifactsDir returns the directory artifacts are collected into.
+func (j *Job) ArtifactsDir() string {
+	return filepath.Join(j.JobPath(), "artifacts")
+}
+
+// RunningFile returns the path of the marker that exists while a runner
+// works in the job directory.
+func (j *Job) RunningFile() string {
+	return filepath.Join(j.JobPath(), ".manfred", "running")
+}
+
+// CredentialHelperFile returns the path of the git credential helper.
+func (j *Job) CredentialHelperFile() string {
+	return filepath.Join(j.JobPath(), "bin", "git-credential-manfred")
+}
+
+// SSHKeyFile returns the path of the SSH key copied for the container.
+func (j *Job) SSHKeyFile() string {
+	return filepath.Join(j.JobPath(), ".ssh", "id_manfred")
+}
+
+// ClaudeBundlePath returns the path the Claude bundle is copied to when
+// claude.copy_bundle is set.
 func (j *Job) ClaudeBundlePath() string {
 	return filepath.Join(j.JobPath(), "claude-bundle")
 }
@@ -0,0 +1,124 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// keptFile lists the environments of failed jobs whose containers were kept
+// running. It lives in the jobs directory.
+const keptFile = "kept-containers.json"
+
+// KeptEnvironment is a Docker Compose project left running after a failed
+// job so it can be inspected.
+type KeptEnvironment struct {
+	JobID          string    `json:"job_id"`
+	Project        string    `json:"project"`
+	ComposeProject string    `json:"compose_project"`
+	ComposeFile    string    `json:"compose_file"` // Empty for native containers
+	Container      string    `json:"container"`
+	KeptAt         time.Time `json:"kept_at"`
+}
+
+// ListKept returns the kept environments registered in jobsDir.
+func ListKept(jobsDir string) ([]KeptEnvironment, error) {
+	data, err := os.ReadFile(filepath.Join(jobsDir, keptFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kept containers: %w", err)
+	}
+
+	var envs []KeptEnvironment
+	if err := json.Unmarshal(data, &envs); err != nil {
+		return nil, fmt.Errorf("failed to parse kept containers: %w", err)
+	}
+	return envs, nil
+}
+
+// registerKept adds an environment to the registry in jobsDir.
+func registerKept(jobsDir string, env KeptEnvironment) error {
+	envs, err := ListKept(jobsDir)
+	if err != nil {
+		return err
+	}
+	return writeKept(jobsDir, append(envs, env))
+}
+
+// unregisterKept removes a job's environment from the registry in jobsDir.
+func unregisterKept(jobsDir, jobID string) error {
+	envs, err := ListKept(jobsDir)
+	if err != nil {
+		return err
+	}
+
+	var remaining []KeptEnvironment
+	for _, env := range envs {
+		if env.JobID != jobID {
+			remaining = append(remaining, env)
+		}
+	}
+	return writeKept(jobsDir, remaining)
+}
+
+func writeKept(jobsDir string, envs []KeptEnvironment) error {
+	if envs == nil {
+		envs = []KeptEnvironment{}
+	}
+	data, err := json.MarshalIndent(envs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(jobsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create jobs directory: %w", err)
+	}
+
+	path := filepath.Join(jobsDir, keptFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write kept containers: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// keepEnvironment leaves a failed job's containers running, registers them
+// for `manfred cleanup` and prints how to attach.
+func (r *Runner) keepEnvironment(job *Job, composeProjectName, containerName, composeFile string) {
+	env := KeptEnvironment{
+		JobID:          job.ID,
+		Project:        job.ProjectName,
+		ComposeProject: composeProjectName,
+		ComposeFile:    composeFile,
+		Container:      containerName,
+		KeptAt:         time.Now().UTC(),
+	}
+	if err := registerKept(r.config.JobsDir, env); err != nil {
+		r.logger.Docker(fmt.Sprintf("Warning: failed to register kept containers: %v", err))
+	}
+
+	r.logger.Docker("Keeping containers for debugging")
+	r.logger.Docker(fmt.Sprintf("  Attach:   docker exec -it %s bash", containerName))
+	if composeFile != "" {
+		r.logger.Docker(fmt.Sprintf("  Logs:     docker compose -p %s -f %s logs", composeProjectName, composeFile))
+	}
+	for _, port := range job.Ports {
+		r.logger.Docker(fmt.Sprintf("  Port:     %s", port))
+	}
+	r.logger.Docker(fmt.Sprintf("  Clean up: manfred cleanup %s", job.ID))
+}
+
+// CleanupKept stops the containers of a kept environment and removes it from
+// the registry.
+func (r *Runner) CleanupKept(ctx context.Context, env KeptEnvironment) error {
+	projectConfig, _ := r.config.ProjectConfig(env.Project)
+	if err := r.stopContainers(ctx, projectConfig, env.ComposeProject, env.Container, env.ComposeFile); err != nil {
+		return fmt.Errorf("failed to stop containers for job %s: %w", env.JobID, err)
+	}
+	return unregisterKept(r.config.JobsDir, env.JobID)
+}
@@ -0,0 +1,44 @@
+package job
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeptRegistry(t *testing.T) {
+	dir := t.TempDir()
+
+	envs, err := ListKept(dir)
+	if err != nil {
+		t.Fatalf("ListKept() on empty dir error = %v", err)
+	}
+	if len(envs) != 0 {
+		t.Fatalf("ListKept() = %d environments, want 0", len(envs))
+	}
+
+	for _, id := range []string{"job_a", "job_b"} {
+		env := KeptEnvironment{JobID: id, ComposeProject: "manfred_" + id, KeptAt: time.Now()}
+		if err := registerKept(dir, env); err != nil {
+			t.Fatalf("registerKept(%s) error = %v", id, err)
+		}
+	}
+
+	envs, err = ListKept(dir)
+	if err != nil {
+		t.Fatalf("ListKept() error = %v", err)
+	}
+	if len(envs) != 2 {
+		t.Fatalf("ListKept() = %d environments, want 2", len(envs))
+	}
+
+	if err := unregisterKept(dir, "job_a"); err != nil {
+		t.Fatalf("unregisterKept() error = %v", err)
+	}
+	envs, err = ListKept(dir)
+	if err != nil {
+		t.Fatalf("ListKept() error = %v", err)
+	}
+	if len(envs) != 1 || envs[0].JobID != "job_b" {
+		t.Errorf("ListKept() = %+v, want only job_b", envs)
+	}
+}
@@ -0,0 +1,119 @@
+package job
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// cappedWriter passes on at most max bytes of an exec stream, then writes a
+// truncation marker once and drops the rest. It never fails, so the exec
+// keeps being drained and the command is not blocked by a full pipe.
+type cappedWriter struct {
+	w   io.Writer
+	max int64
+
+	mu      sync.Mutex
+	written int64
+	dropped int64
+}
+
+func (c *cappedWriter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.written >= c.max {
+		c.dropped += int64(len(p))
+		return len(p), nil
+	}
+	n := int64(len(p))
+	if room := c.max - c.written; n > room {
+		n = room
+	}
+	c.w.Write(p[:n])
+	c.written += n
+	if rest := int64(len(p)) - n; rest > 0 {
+		c.dropped += rest
+		fmt.Fprintf(c.w, "\n[output truncated after %d bytes]\n", c.max)
+	}
+	return len(p), nil
+}
+
+// Dropped returns the number of bytes dropped so far.
+func (c *cappedWriter) Dropped() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
+
+// outputLimits caps exec streams per job.output and keeps the writers so the
+// dropped bytes can be reported when the job ends.
+type outputLimits struct {
+	maxBytes int64
+
+	mu      sync.Mutex
+	writers map[string][]*cappedWriter // Stream name -> writers, one per exec
+}
+
+// limit caps w at maxBytes, counting drops under stream, e.g. "CLAUDE
+// stdout". A zero maxBytes returns w unchanged.
+func (o *outputLimits) limit(stream string, w io.Writer) io.Writer {
+	if o.maxBytes <= 0 {
+		return w
+	}
+	c := &cappedWriter{w: w, max: o.maxBytes}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.writers == nil {
+		o.writers = map[string][]*cappedWriter{}
+	}
+	o.writers[stream] = append(o.writers[stream], c)
+	return c
+}
+
+// dropped returns the bytes dropped per stream, leaving out streams that
+// were never cut, and resets the counts.
+func (o *outputLimits) dropped() map[string]int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	counts := map[string]int64{}
+	for stream, writers := range o.writers {
+		for _, c := range writers {
+			if n := c.Dropped(); n > 0 {
+				counts[stream] += n
+			}
+		}
+	}
+	o.writers = nil
+	return counts
+}
+
+// reportDroppedOutput logs how much exec output the limits dropped during
+// the job, including log lines cut at job.output.max_line_bytes, and records
+// the total on the job.
+func (r *Runner) reportDroppedOutput(job *Job) {
+	counts := r.outputs.dropped()
+	if n := r.logger.droppedLineBytes(); n > 0 {
+		counts["long lines"] += n
+	}
+	if len(counts) == 0 {
+		return
+	}
+
+	streams := make([]string, 0, len(counts))
+	var total int64
+	for stream, n := range counts {
+		streams = append(streams, stream)
+		total += n
+	}
+	sort.Strings(streams)
+	parts := make([]string, len(streams))
+	for i, stream := range streams {
+		parts[i] = fmt.Sprintf("%s %d", stream, counts[stream])
+	}
+
+	job.DroppedOutput = total
+	r.logger.Manfred(fmt.Sprintf("Output limits dropped %d byte(s): %s", total, strings.Join(parts, ", ")))
+}
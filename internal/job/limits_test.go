@@ -0,0 +1,55 @@
+package job
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCappedWriter(t *testing.T) {
+	var buf bytes.Buffer
+	limits := outputLimits{maxBytes: 10}
+	w := limits.limit("TEST output", &buf)
+
+	for _, p := range []string{"12345", "678901234", "more"} {
+		if n, err := w.Write([]byte(p)); n != len(p) || err != nil {
+			t.Errorf("Write(%q) = %d, %v, want %d, nil", p, n, err, len(p))
+		}
+	}
+
+	if want := "1234567890\n[output truncated after 10 bytes]\n"; buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+	dropped := limits.dropped()
+	if dropped["TEST output"] != 8 {
+		t.Errorf("dropped = %v, want TEST output 8", dropped)
+	}
+	if len(limits.dropped()) != 0 {
+		t.Error("dropped() did not reset the counts")
+	}
+}
+
+func TestOutputLimitsDisabled(t *testing.T) {
+	var buf bytes.Buffer
+	limits := outputLimits{}
+	w := limits.limit("CLAUDE stdout", &buf)
+	w.Write([]byte(strings.Repeat("x", 1000)))
+	if buf.Len() != 1000 {
+		t.Errorf("wrote %d bytes, want 1000", buf.Len())
+	}
+}
+
+func TestReportDroppedOutput(t *testing.T) {
+	var buf bytes.Buffer
+	r := &Runner{logger: NewLogger(NewTextSink(&buf)), outputs: outputLimits{maxBytes: 4}}
+	r.outputs.limit("CLAUDE stdout", &bytes.Buffer{}).Write([]byte("123456"))
+	job := &Job{}
+
+	r.reportDroppedOutput(job)
+	if job.DroppedOutput != 2 {
+		t.Errorf("DroppedOutput = %d, want 2", job.DroppedOutput)
+	}
+	if !strings.Contains(buf.String(), "Output limits dropped 2 byte(s): CLAUDE stdout 2") {
+		t.Errorf("log = %q", buf.String())
+	}
+}
@@ -1,26 +1,176 @@
 package job
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 )
 
-// Logger provides prefixed logging for job execution.
+// maskedValue replaces secret values in log output.
+const maskedValue = "****"
+
+// separatorLine is the visual separator printed by Separator.
+const separatorLine = "────────────────────────────────────────────────────────────"
+
+// truncatedLine marks a log line cut at the line length limit.
+const truncatedLine = " [line truncated]"
+
+// Entry is one log line. Separators and blank lines have no Source and are
+// only rendered by text sinks.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	Message string    `json:"message"`
+}
+
+// Sink receives the entries of a Logger. The Logger serializes calls, so a
+// sink need not be safe for concurrent use unless it is shared.
+type Sink interface {
+	WriteEntry(e Entry) error
+	Close() error
+}
+
+// Logger provides prefixed logging for job execution. Every entry is written
+// to all of its sinks; secrets are masked before any sink sees them. With
+// sanitizing on, sinks get messages without ANSI sequences and control
+// characters, while raw sinks still get them as they were.
 type Logger struct {
-	out io.Writer
+	mu       sync.Mutex // Serializes writes to sinks
+	sinks    []Sink
+	rawSinks []Sink
+	writers  []*prefixWriter
+	sanitize bool
+
+	secretsMu sync.RWMutex
+	secrets   []string
+
+	maxLine     int          // Longest line a Writer logs; 0 is unlimited
+	droppedLine atomic.Int64 // Bytes cut from long lines
+}
+
+// NewLogger creates a logger writing to sinks, or to stdout when none are
+// given.
+func NewLogger(sinks ...Sink) *Logger {
+	if len(sinks) == 0 {
+		sinks = []Sink{NewTextSink(os.Stdout)}
+	}
+	return &Logger{sinks: sinks}
+}
+
+// SetOutput replaces all sinks with a text sink writing to w.
+func (l *Logger) SetOutput(w io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sinks = []Sink{NewTextSink(w)}
+}
+
+// AddSink adds a sink receiving all further entries.
+func (l *Logger) AddSink(s Sink) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sinks = append(l.sinks, s)
+}
+
+// AddRawSink adds a sink receiving all further entries unsanitized, e.g. a
+// raw capture file next to the sanitized log.
+func (l *Logger) AddRawSink(s Sink) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rawSinks = append(l.rawSinks, s)
+}
+
+// SetSanitize turns stripping ANSI sequences and replacing non-printable
+// characters in messages on or off.
+func (l *Logger) SetSanitize(on bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sanitize = on
+}
+
+// RemoveSink flushes pending output and closes and removes s, which may be
+// a raw sink.
+func (l *Logger) RemoveSink(s Sink) error {
+	l.Flush()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, sinks := range []*[]Sink{&l.sinks, &l.rawSinks} {
+		for i, sink := range *sinks {
+			if sink == s {
+				*sinks = append((*sinks)[:i:i], (*sinks)[i+1:]...)
+				return s.Close()
+			}
+		}
+	}
+	return nil
+}
+
+// SetMaxLineBytes cuts lines written through Writers at n bytes; the rest
+// of a longer line is dropped. Zero disables the limit.
+func (l *Logger) SetMaxLineBytes(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maxLine = n
+}
+
+// droppedLineBytes returns the bytes cut from long lines since the last call.
+func (l *Logger) droppedLineBytes() int64 {
+	return l.droppedLine.Swap(0)
+}
+
+// Mask registers a secret value that is replaced in all further output.
+// Empty values are ignored.
+func (l *Logger) Mask(value string) {
+	if value == "" {
+		return
+	}
+	l.secretsMu.Lock()
+	defer l.secretsMu.Unlock()
+	for _, s := range l.secrets {
+		if s == value {
+			return
+		}
+	}
+	l.secrets = append(l.secrets, value)
 }
 
-// NewLogger creates a new logger that writes to stdout.
-func NewLogger() *Logger {
-	return &Logger{out: os.Stdout}
+// Redact returns s with all registered secret values masked.
+func (l *Logger) Redact(s string) string {
+	l.secretsMu.RLock()
+	defer l.secretsMu.RUnlock()
+	for _, secret := range l.secrets {
+		s = strings.ReplaceAll(s, secret, maskedValue)
+	}
+	return s
+}
+
+// write sends an entry to every sink. A failing sink does not keep the
+// others from getting the entry.
+func (l *Logger) write(e Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, s := range l.rawSinks {
+		s.WriteEntry(e)
+	}
+	if l.sanitize {
+		e.Message = sanitizeLogLine(e.Message)
+	}
+	for _, s := range l.sinks {
+		s.WriteEntry(e)
+	}
 }
 
 // Log writes a message with a source prefix.
 func (l *Logger) Log(source, message string) {
-	timestamp := time.Now().Format("2006-01-02T15:04:05Z")
-	fmt.Fprintf(l.out, "[%s] [%-8s] %s\n", timestamp, source, message)
+	l.write(Entry{Time: time.Now(), Source: source, Message: l.Redact(message)})
 }
 
 // Manfred logs a MANFRED message.
@@ -40,50 +190,317 @@ func (l *Logger) Claude(message string) {
 
 // Separator prints a visual separator line.
 func (l *Logger) Separator() {
-	fmt.Fprintln(l.out, "────────────────────────────────────────────────────────────")
+	l.write(Entry{Time: time.Now(), Message: separatorLine})
 }
 
 // Blank prints a blank line.
 func (l *Logger) Blank() {
-	fmt.Fprintln(l.out)
+	l.write(Entry{Time: time.Now()})
+}
+
+// Writer returns a writer that logs with the given source prefix. A last
+// line without a newline is logged by Flush or when the writer is closed,
+// which also releases it from the logger.
+func (l *Logger) Writer(source string) io.WriteCloser {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	w := &prefixWriter{logger: l, source: source, maxLine: l.maxLine}
+	l.writers = append(l.writers, w)
+	return w
+}
+
+// removeWriter stops Flush from tracking w.
+func (l *Logger) removeWriter(w *prefixWriter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, writer := range l.writers {
+		if writer == w {
+			l.writers = append(l.writers[:i:i], l.writers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Flush logs the partial lines buffered by all writers.
+func (l *Logger) Flush() {
+	l.mu.Lock()
+	writers := append([]*prefixWriter(nil), l.writers...)
+	l.mu.Unlock()
+	for _, w := range writers {
+		w.flush()
+	}
 }
 
-// Writer returns an io.Writer that logs with the given source prefix.
-func (l *Logger) Writer(source string) io.Writer {
-	return &prefixWriter{logger: l, source: source}
+// Close flushes pending output and closes all sinks.
+func (l *Logger) Close() error {
+	l.Flush()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.writers = nil
+	var errs []error
+	for _, s := range append(l.sinks, l.rawSinks...) {
+		if err := s.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	l.sinks = nil
+	l.rawSinks = nil
+	return errors.Join(errs...)
 }
 
-// prefixWriter wraps a logger to implement io.Writer.
+// prefixWriter wraps a logger to implement io.Writer. Docker copies stdout
+// and stderr concurrently, so writes are serialized. Lines longer than
+// maxLine are cut, and the rest of the line is skipped, so output without
+// newlines cannot grow the buffer without bound.
 type prefixWriter struct {
-	logger *Logger
-	source string
-	buffer []byte
+	logger  *Logger
+	source  string
+	maxLine int
+
+	mu       sync.Mutex
+	buffer   []byte
+	skipping bool // Dropping the rest of a cut line
 }
 
 func (w *prefixWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	w.buffer = append(w.buffer, p...)
 
 	// Process complete lines
 	for {
-		newline := -1
-		for i, b := range w.buffer {
-			if b == '\n' {
-				newline = i
-				break
-			}
-		}
-
+		newline := bytes.IndexByte(w.buffer, '\n')
 		if newline < 0 {
 			break
 		}
 
-		line := string(w.buffer[:newline])
+		line := w.buffer[:newline]
 		w.buffer = w.buffer[newline+1:]
 
-		if line != "" {
-			w.logger.Log(w.source, line)
+		if w.skipping {
+			w.logger.droppedLine.Add(int64(len(line)))
+			w.skipping = false
+			continue
 		}
+		w.logLine(line)
+	}
+
+	if w.maxLine > 0 && len(w.buffer) > w.maxLine {
+		if w.skipping {
+			w.logger.droppedLine.Add(int64(len(w.buffer)))
+		} else {
+			w.logLine(w.buffer)
+			w.skipping = true
+		}
+		w.buffer = w.buffer[:0]
 	}
 
 	return len(p), nil
 }
+
+// logLine logs a line, cut at maxLine on a rune boundary.
+func (w *prefixWriter) logLine(line []byte) {
+	if len(line) == 0 {
+		return
+	}
+	if w.maxLine > 0 && len(line) > w.maxLine {
+		cut := w.maxLine
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.logger.droppedLine.Add(int64(len(line) - cut))
+		w.logger.Log(w.source, string(line[:cut])+truncatedLine)
+		return
+	}
+	w.logger.Log(w.source, string(line))
+}
+
+// Close logs the buffered partial line and releases the writer.
+func (w *prefixWriter) Close() error {
+	w.flush()
+	w.logger.removeWriter(w)
+	return nil
+}
+
+// flush logs the buffered partial line, if any.
+func (w *prefixWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.skipping {
+		w.logger.droppedLine.Add(int64(len(w.buffer)))
+	} else {
+		w.logLine(w.buffer)
+	}
+	w.buffer = nil
+	w.skipping = false
+}
+
+// textSink renders entries as prefixed lines, the format of the console.
+type textSink struct {
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewTextSink writes entries to w as "[time] [SOURCE  ] message" lines. w is
+// not closed.
+func NewTextSink(w io.Writer) Sink {
+	return &textSink{w: w}
+}
+
+func (s *textSink) WriteEntry(e Entry) error {
+	var err error
+	switch {
+	case e.Source != "":
+		_, err = fmt.Fprintf(s.w, "[%s] [%-8s] %s\n", e.Time.Format("2006-01-02T15:04:05Z"), e.Source, e.Message)
+	default:
+		_, err = fmt.Fprintln(s.w, e.Message)
+	}
+	return err
+}
+
+func (s *textSink) Close() error {
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+	return nil
+}
+
+// jsonSink writes entries as JSON lines.
+type jsonSink struct {
+	enc    *json.Encoder
+	closer io.Closer
+}
+
+// NewJSONSink writes entries to w as one JSON object per line, for log
+// collectors. Separators and blank lines are left out. w is not closed.
+func NewJSONSink(w io.Writer) Sink {
+	return &jsonSink{enc: json.NewEncoder(w)}
+}
+
+func (s *jsonSink) WriteEntry(e Entry) error {
+	if e.Source == "" {
+		return nil
+	}
+	return s.enc.Encode(e)
+}
+
+func (s *jsonSink) Close() error {
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+	return nil
+}
+
+// NewFileSink appends entries to the file at path, as text or, with format
+// "json", as JSON lines. The file is closed with the sink.
+func NewFileSink(path, format string) (Sink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	if format == "json" {
+		return &jsonSink{enc: json.NewEncoder(f), closer: f}, nil
+	}
+	return &textSink{w: f, closer: f}, nil
+}
+
+// Hub is a sink that streams entries to subscribers, e.g. browsers following
+// a job over server-sent events. Slow subscribers miss entries instead of
+// holding up the job.
+type Hub struct {
+	mu     sync.Mutex
+	subs   map[chan Entry]struct{}
+	closed bool
+}
+
+// NewHub creates a hub without subscribers.
+func NewHub() *Hub {
+	return &Hub{subs: map[chan Entry]struct{}{}}
+}
+
+// Subscribe returns a channel receiving all further entries and a function
+// ending the subscription. The channel is closed when either is called or
+// the hub is closed.
+func (h *Hub) Subscribe(buffer int) (<-chan Entry, func()) {
+	ch := make(chan Entry, buffer)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	h.subs[ch] = struct{}{}
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subs[ch]; ok {
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// WriteEntry sends e to every subscriber with room for it.
+func (h *Hub) WriteEntry(e Entry) error {
+	if e.Source == "" {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+	return nil
+}
+
+// Close ends all subscriptions.
+func (h *Hub) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil
+	}
+	h.closed = true
+	for ch := range h.subs {
+		delete(h.subs, ch)
+		close(ch)
+	}
+	return nil
+}
+
+// ServeHTTP streams entries as server-sent events until the client goes
+// away or the hub is closed. Each event's data is an Entry as JSON.
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	entries, cancel := h.Subscribe(256)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-entries:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
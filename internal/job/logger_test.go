@@ -0,0 +1,173 @@
+package job
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLoggerSinks(t *testing.T) {
+	var text, js bytes.Buffer
+	logger := NewLogger(NewTextSink(&text), NewJSONSink(&js))
+	logger.Manfred("hello")
+	logger.Separator()
+
+	if !strings.Contains(text.String(), "[MANFRED ] hello\n") || !strings.Contains(text.String(), separatorLine+"\n") {
+		t.Errorf("text output = %q", text.String())
+	}
+
+	var e Entry
+	if err := json.Unmarshal(js.Bytes(), &e); err != nil {
+		t.Fatalf("JSON output %q: %v", js.String(), err)
+	}
+	if e.Source != "MANFRED" || e.Message != "hello" {
+		t.Errorf("JSON entry = %+v, want MANFRED hello", e)
+	}
+	if n := strings.Count(js.String(), "\n"); n != 1 {
+		t.Errorf("JSON output has %d lines, want 1 (no separator)", n)
+	}
+}
+
+func TestLoggerFlushOnClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.log")
+	sink, err := NewFileSink(path, "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := NewLogger(sink)
+
+	w := logger.Writer("CLAUDE")
+	w.Write([]byte("done\nno newline"))
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"[CLAUDE  ] done\n", "[CLAUDE  ] no newline\n"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("log missing %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestLoggerConcurrentWriters(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewTextSink(&buf))
+	w := logger.Writer("CLAUDE")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				w.Write([]byte("line\n"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := strings.Count(buf.String(), "] line\n"); got != 400 {
+		t.Errorf("logged %d lines, want 400", got)
+	}
+}
+
+func TestLoggerRemoveSink(t *testing.T) {
+	var console bytes.Buffer
+	logger := NewLogger(NewTextSink(&console))
+	hub := NewHub()
+	entries, cancel := hub.Subscribe(4)
+	defer cancel()
+
+	logger.AddSink(hub)
+	logger.Writer("DOCKER").Write([]byte("partial"))
+	logger.RemoveSink(hub)
+	logger.Manfred("after")
+
+	var got []string
+	for e := range entries {
+		got = append(got, e.Source+": "+e.Message)
+	}
+	if len(got) != 1 || got[0] != "DOCKER: partial" {
+		t.Errorf("hub entries = %v, want [DOCKER: partial]", got)
+	}
+	if !strings.Contains(console.String(), "after") {
+		t.Errorf("console missing entry after RemoveSink:\n%s", console.String())
+	}
+}
+
+func TestLoggerWriterClose(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewTextSink(&buf))
+	for i := 0; i < 3; i++ {
+		w := logger.Writer("TEST")
+		w.Write([]byte("partial"))
+		w.Close()
+	}
+	if got := strings.Count(buf.String(), "] partial\n"); got != 3 {
+		t.Errorf("logged %d partial lines, want 3:\n%s", got, buf.String())
+	}
+	if len(logger.writers) != 0 {
+		t.Errorf("logger keeps %d closed writers", len(logger.writers))
+	}
+}
+
+func TestLoggerMaxLineBytes(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewTextSink(&buf))
+	logger.SetMaxLineBytes(10)
+	w := logger.Writer("CLAUDE")
+
+	w.Write([]byte("short\n"))
+	w.Write([]byte(strings.Repeat("a", 25) + "\n"))
+	// A long line without newline arrives in pieces
+	w.Write([]byte(strings.Repeat("b", 8)))
+	w.Write([]byte(strings.Repeat("b", 8)))
+	w.Write([]byte(strings.Repeat("b", 8) + "\nnext\n"))
+
+	out := buf.String()
+	for _, want := range []string{
+		"] short\n",
+		"] aaaaaaaaaa" + truncatedLine + "\n",
+		"] bbbbbbbbbb" + truncatedLine + "\n",
+		"] next\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if got := logger.droppedLineBytes(); got != 15+14 {
+		t.Errorf("droppedLineBytes() = %d, want %d", got, 15+14)
+	}
+}
+
+func TestLoggerSanitize(t *testing.T) {
+	var clean, raw bytes.Buffer
+	logger := NewLogger(NewTextSink(&clean))
+	rawSink := NewTextSink(&raw)
+	logger.AddRawSink(rawSink)
+	logger.SetSanitize(true)
+	logger.Mask("sk-secret")
+
+	logger.Writer("CLAUDE").Write([]byte("\x1b[32mok\x1b[0m sk-secret\x00\n"))
+
+	if want := "] ok ****�\n"; !strings.HasSuffix(clean.String(), want) {
+		t.Errorf("sanitized log = %q, want suffix %q", clean.String(), want)
+	}
+	if want := "] \x1b[32mok\x1b[0m ****\x00\n"; !strings.HasSuffix(raw.String(), want) {
+		t.Errorf("raw log = %q, want suffix %q", raw.String(), want)
+	}
+
+	logger.RemoveSink(rawSink)
+	logger.Manfred("after")
+	if strings.Contains(raw.String(), "after") {
+		t.Error("removed raw sink still receives entries")
+	}
+}
@@ -0,0 +1,128 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mpm/manfred/internal/docker"
+)
+
+// ResourceUsage records the peak resource usage of a job's main container,
+// sampled every job.monitor.interval while the job ran.
+type ResourceUsage struct {
+	Container       string  `json:"container"`
+	Samples         int     `json:"samples"`
+	PeakCPUPercent  float64 `json:"peak_cpu_percent"` // Percent of one CPU
+	PeakMemoryBytes int64   `json:"peak_memory_bytes"`
+	MemoryLimit     int64   `json:"memory_limit"`
+	PeakPids        int64   `json:"peak_pids"`
+}
+
+// PeakMemoryPercent returns the peak memory usage as a percentage of the
+// container's memory limit.
+func (u *ResourceUsage) PeakMemoryPercent() float64 {
+	return docker.Usage{MemoryBytes: u.PeakMemoryBytes, MemoryLimit: u.MemoryLimit}.MemoryPercent()
+}
+
+// add records a sample.
+func (u *ResourceUsage) add(sample docker.Usage) {
+	u.Samples++
+	u.PeakCPUPercent = max(u.PeakCPUPercent, sample.CPUPercent)
+	u.PeakMemoryBytes = max(u.PeakMemoryBytes, sample.MemoryBytes)
+	u.PeakPids = max(u.PeakPids, sample.Pids)
+	u.MemoryLimit = sample.MemoryLimit
+}
+
+// String summarizes the peaks for logs and comments.
+func (u *ResourceUsage) String() string {
+	return fmt.Sprintf("%.0f%% CPU, %d MB memory (%.0f%% of limit), %d processes",
+		u.PeakCPUPercent, u.PeakMemoryBytes>>20, u.PeakMemoryPercent(), u.PeakPids)
+}
+
+// ResourcesFile returns the path of the job's recorded resource usage.
+func (j *Job) ResourcesFile() string {
+	return filepath.Join(j.JobPath(), ".manfred", "resources.json")
+}
+
+// monitorResources samples the CPU and memory usage of containerName until
+// the returned stop function is called, which records the peaks on the job
+// and in its directory. Memory use reaching job.monitor.memory_warn percent
+// of the container's limit is logged once, to catch runaway processes while
+// they run. Failed samples are skipped; monitoring never fails a job.
+func (r *Runner) monitorResources(ctx context.Context, job *Job, containerName string) (stop func()) {
+	cfg := r.config.Job.Monitor
+	if cfg.Interval <= 0 {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	usage := &ResourceUsage{Container: containerName}
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		warned := false
+		for {
+			if sample, err := r.docker.ContainerUsage(ctx, containerName); err == nil {
+				usage.add(*sample)
+				if cfg.MemoryWarn > 0 && !warned && sample.MemoryPercent() >= float64(cfg.MemoryWarn) {
+					warned = true
+					r.logger.Docker(fmt.Sprintf("Warning: %s uses %d MB of memory, %.0f%% of its %d MB limit",
+						containerName, sample.MemoryBytes>>20, sample.MemoryPercent(), sample.MemoryLimit>>20))
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+		if usage.Samples == 0 {
+			return
+		}
+		job.Resources = usage
+		r.logger.Docker(fmt.Sprintf("Peak usage of %s: %s", containerName, usage))
+		if err := writeResources(job, usage); err != nil {
+			r.logger.Docker(fmt.Sprintf("Warning: failed to record resource usage: %v", err))
+		}
+	}
+}
+
+// writeResources stores the job's resource usage in the job directory.
+func writeResources(job *Job, usage *ResourceUsage) error {
+	data, err := json.MarshalIndent(usage, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(job.ResourcesFile(), append(data, '\n'), 0644)
+}
+
+// LoadResources reads the resource usage recorded for a job. It returns nil
+// if the job was not monitored.
+func LoadResources(jobsDir, jobID string) (*ResourceUsage, error) {
+	job := &Job{ID: jobID, jobsDir: jobsDir}
+	data, err := os.ReadFile(job.ResourcesFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var usage ResourceUsage
+	if err := json.Unmarshal(data, &usage); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", job.ResourcesFile(), err)
+	}
+	return &usage, nil
+}
@@ -0,0 +1,42 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/mpm/manfred/internal/docker"
+)
+
+func TestResourceUsage(t *testing.T) {
+	jobsDir := t.TempDir()
+	j := &Job{ID: "job_monitored", jobsDir: jobsDir}
+	if err := j.CreateDirectories(); err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := LoadResources(jobsDir, j.ID)
+	if err != nil || usage != nil {
+		t.Fatalf("LoadResources() before monitoring = %v, %v; want nil, nil", usage, err)
+	}
+
+	usage = &ResourceUsage{Container: "app"}
+	usage.add(docker.Usage{CPUPercent: 150, MemoryBytes: 256 << 20, MemoryLimit: 1 << 30, Pids: 40})
+	usage.add(docker.Usage{CPUPercent: 30, MemoryBytes: 512 << 20, MemoryLimit: 1 << 30, Pids: 12})
+	want := ResourceUsage{Container: "app", Samples: 2, PeakCPUPercent: 150, PeakMemoryBytes: 512 << 20, MemoryLimit: 1 << 30, PeakPids: 40}
+	if *usage != want {
+		t.Errorf("usage = %+v, want %+v", *usage, want)
+	}
+	if p := usage.PeakMemoryPercent(); p != 50 {
+		t.Errorf("PeakMemoryPercent() = %v, want 50", p)
+	}
+
+	if err := writeResources(j, usage); err != nil {
+		t.Fatalf("writeResources() error = %v", err)
+	}
+	loaded, err := LoadResources(jobsDir, j.ID)
+	if err != nil {
+		t.Fatalf("LoadResources() error = %v", err)
+	}
+	if loaded == nil || *loaded != want {
+		t.Errorf("LoadResources() = %+v, want %+v", loaded, want)
+	}
+}
@@ -0,0 +1,199 @@
+package job
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// ContainerOutputHelper is the helper script Claude uses to hand results
+	// back to MANFRED.
+	ContainerOutputHelper = "/manfred-job/bin/manfred-output"
+
+	// ContainerOutputsDir is where the helper stores outputs and the manifest.
+	ContainerOutputsDir = "/manfred-job/.manfred/outputs"
+
+	// OutputPlan is the output name of the plan in plan-only jobs.
+	OutputPlan = "plan"
+
+	// OutputCommitMessage is the output name of the commit message.
+	OutputCommitMessage = "commit_message"
+
+	// outputManifest is the manifest file written by the helper.
+	outputManifest = "manifest.json"
+)
+
+// outputHelperScript is mounted at ContainerOutputHelper. It stores its
+// input under a name in the outputs directory and rewrites the manifest with
+// the size and SHA-256 of every output.
+const outputHelperScript = `#!/bin/sh
+# manfred-output: hand a named result back to MANFRED.
+#
+# Usage: manfred-output <name> [file]
+#        reads stdin when file is omitted; names use a-z, 0-9, _ and -
+set -eu
+
+dir=` + ContainerOutputsDir + `
+name="${1:-}"
+case "$name" in
+  "" | *[!a-z0-9_-]*)
+    echo "usage: manfred-output <name> [file]  (name: a-z, 0-9, _ and -)" >&2
+    exit 2
+    ;;
+esac
+
+if command -v sha256sum >/dev/null 2>&1; then
+  hash() { sha256sum "$1" | cut -d' ' -f1; }
+elif command -v shasum >/dev/null 2>&1; then
+  hash() { shasum -a 256 "$1" | cut -d' ' -f1; }
+else
+  echo "manfred-output: sha256sum or shasum is required" >&2
+  exit 1
+fi
+
+mkdir -p "$dir"
+tmp="$dir/.$name.tmp"
+if [ $# -ge 2 ]; then
+  cat "$2" > "$tmp"
+else
+  cat > "$tmp"
+fi
+mv "$tmp" "$dir/$name"
+
+{
+  printf '{"version":1,"files":{'
+  sep=""
+  for f in "$dir"/*; do
+    [ -f "$f" ] || continue
+    n=$(basename "$f")
+    [ "$n" = "manifest.json" ] && continue
+    printf '%s"%s":{"sha256":"%s","size":%s}' "$sep" "$n" "$(hash "$f")" "$(wc -c < "$f" | tr -d ' ')"
+    sep=","
+  done
+  printf '}}\n'
+} > "$dir/.manifest.tmp"
+mv "$dir/.manifest.tmp" "$dir/manifest.json"
+
+echo "manfred-output: saved $name ($(wc -c < "$dir/$name" | tr -d ' ') bytes)"
+`
+
+// OutputManifest lists the outputs written by the helper script.
+type OutputManifest struct {
+	Version int                    `json:"version"`
+	Files   map[string]OutputEntry `json:"files"`
+}
+
+// OutputEntry describes one output file.
+type OutputEntry struct {
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// errOutputMissing is returned when the manifest has no entry for an output.
+var errOutputMissing = errors.New("output not found in manifest")
+
+// installOutputHelper writes the helper script into the job directory.
+func installOutputHelper(job *Job) error {
+	path := job.OutputHelperFile()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(outputHelperScript), 0755)
+}
+
+// readManifest loads the output manifest of a job. A job without outputs
+// has an empty manifest.
+func readManifest(job *Job) (*OutputManifest, error) {
+	manifest := &OutputManifest{Files: map[string]OutputEntry{}}
+	data, err := os.ReadFile(filepath.Join(job.OutputsDir(), outputManifest))
+	if errors.Is(err, os.ErrNotExist) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read output manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("parse output manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// verifyOutput checks an output file against its manifest entry.
+func verifyOutput(job *Job, name string, entry OutputEntry) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(job.OutputsDir(), name))
+	if err != nil {
+		return nil, fmt.Errorf("output %s: %w", name, err)
+	}
+	if int64(len(data)) != entry.Size {
+		return nil, fmt.Errorf("output %s: size %d does not match manifest (%d)", name, len(data), entry.Size)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != entry.SHA256 {
+		return nil, fmt.Errorf("output %s: checksum does not match manifest", name)
+	}
+	return data, nil
+}
+
+// validateOutputs verifies every output listed in the manifest.
+func validateOutputs(job *Job) error {
+	manifest, err := readManifest(job)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for name, entry := range manifest.Files {
+		if _, err := verifyOutput(job, name, entry); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// readOutput returns a verified, normalized text output. It returns
+// errOutputMissing when the helper did not record the output.
+func readOutput(job *Job, name string) (string, error) {
+	manifest, err := readManifest(job)
+	if err != nil {
+		return "", err
+	}
+	entry, ok := manifest.Files[name]
+	if !ok {
+		return "", errOutputMissing
+	}
+	data, err := verifyOutput(job, name, entry)
+	if err != nil {
+		return "", err
+	}
+	text, err := normalizeText(data)
+	if err != nil {
+		return "", fmt.Errorf("output %s: %w", name, err)
+	}
+	return text, nil
+}
+
+// readTextOutput reads an output through the manifest, falling back to the
+// legacy file Claude may have written directly.
+func (r *Runner) readTextOutput(job *Job, name, legacyPath string) (string, error) {
+	text, err := readOutput(job, name)
+	if !errors.Is(err, errOutputMissing) {
+		return text, err
+	}
+	text, err = readTextFile(legacyPath)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w (not recorded with %s either)", name, err, ContainerOutputHelper)
+	}
+	r.logger.Manfred(fmt.Sprintf("Warning: %s read from %s instead of %s", name, legacyPath, ContainerOutputHelper))
+	return text, nil
+}
+
+// checkOutputs validates the manifest after a phase and logs problems.
+func (r *Runner) checkOutputs(job *Job, phase string) {
+	if err := validateOutputs(job); err != nil {
+		r.logger.Manfred(fmt.Sprintf("Warning: invalid outputs after %s: %v", phase, err))
+	}
+}
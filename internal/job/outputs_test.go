@@ -0,0 +1,104 @@
+package job
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeOutput stores an output and a manifest entry the way the helper does.
+func writeOutput(t *testing.T, job *Job, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(job.OutputsDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(job.OutputsDir(), name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := readManifest(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(content))
+	manifest.Files[name] = OutputEntry{SHA256: hex.EncodeToString(sum[:]), Size: int64(len(content))}
+	data, _ := json.Marshal(manifest)
+	if err := os.WriteFile(filepath.Join(job.OutputsDir(), outputManifest), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadOutput(t *testing.T) {
+	job := New("proj", "prompt", t.TempDir())
+	writeOutput(t, job, OutputPlan, "# Plan\r\n\r\n1. Do it\r\n")
+
+	got, err := readOutput(job, OutputPlan)
+	if err != nil {
+		t.Fatalf("readOutput() error = %v", err)
+	}
+	if want := "# Plan\n\n1. Do it"; got != want {
+		t.Errorf("readOutput() = %q, want %q", got, want)
+	}
+
+	if _, err := readOutput(job, OutputCommitMessage); !errors.Is(err, errOutputMissing) {
+		t.Errorf("readOutput(missing) error = %v, want errOutputMissing", err)
+	}
+}
+
+func TestReadOutputChecksumMismatch(t *testing.T) {
+	job := New("proj", "prompt", t.TempDir())
+	writeOutput(t, job, OutputPlan, "original")
+	if err := os.WriteFile(filepath.Join(job.OutputsDir(), OutputPlan), []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := readOutput(job, OutputPlan)
+	if err == nil || !strings.Contains(err.Error(), "checksum does not match") {
+		t.Errorf("readOutput() error = %v, want checksum mismatch", err)
+	}
+	if err := validateOutputs(job); err == nil {
+		t.Error("validateOutputs() = nil, want error")
+	}
+}
+
+func TestOutputHelperScript(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	if _, err := exec.LookPath("sha256sum"); err != nil {
+		t.Skip("sha256sum not available")
+	}
+
+	job := New("proj", "prompt", t.TempDir())
+	script := strings.Replace(outputHelperScript, "dir="+ContainerOutputsDir, "dir="+job.OutputsDir(), 1)
+	helper := filepath.Join(t.TempDir(), "manfred-output")
+	if err := os.WriteFile(helper, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(helper, OutputCommitMessage)
+	cmd.Stdin = strings.NewReader("feat: add outputs\n\n- details\n")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("helper failed: %v\n%s", err, out)
+	}
+	if out, err := exec.Command(helper, "Bad Name").CombinedOutput(); err == nil {
+		t.Errorf("helper accepted invalid name:\n%s", out)
+	}
+
+	got, err := readOutput(job, OutputCommitMessage)
+	if err != nil {
+		t.Fatalf("readOutput() error = %v", err)
+	}
+	if want := "feat: add outputs\n\n- details"; got != want {
+		t.Errorf("readOutput() = %q, want %q", got, want)
+	}
+	if err := validateOutputs(job); err != nil {
+		t.Errorf("validateOutputs() error = %v", err)
+	}
+}
@@ -0,0 +1,477 @@
+package job
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mpm/manfred/internal/gitops"
+)
+
+// Report formats.
+const (
+	ReportMarkdown = "md"
+	ReportHTML     = "html"
+)
+
+// maxReportTestOutput limits the test output quoted in reports.
+const maxReportTestOutput = 8000
+
+// Report gathers what a job was asked to do, what it did and how it ran,
+// for change-management tickets and audits. It is built from the job
+// directory alone, so it works for CLI and session jobs alike.
+type Report struct {
+	Input         *Input
+	Result        *Result // nil while the job runs or for jobs older than result records
+	Prompt        string
+	Plan          string
+	CommitMessage string
+	Commits       []string // One-line summaries, oldest first
+	Diff          *Diff
+	Files         []FileChange
+	TestOutput    string // Tail of the last test run
+	Resources     *ResourceUsage
+	Artifacts     []Artifact
+	Timeline      []Entry // MANFRED log entries
+	GeneratedAt   time.Time
+}
+
+// FileChange is a file of a job's diff.
+type FileChange struct {
+	Path       string
+	Insertions int
+	Deletions  int
+}
+
+// BuildReport collects the report of a job in jobsDir. Missing pieces, like
+// the plan of an implementation job or the diff of a failed clone, are left
+// empty.
+func BuildReport(ctx context.Context, jobsDir, jobID string) (*Report, error) {
+	if err := checkJobID(jobID); err != nil {
+		return nil, err
+	}
+	input, err := LoadInput(jobsDir, jobID)
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{ID: jobID, jobsDir: jobsDir}
+	report := &Report{Input: input, GeneratedAt: time.Now()}
+
+	if report.Result, err = LoadResult(jobsDir, jobID); err != nil {
+		return nil, err
+	}
+	if report.Resources, err = LoadResources(jobsDir, jobID); err != nil {
+		return nil, err
+	}
+	report.Prompt = readOptional(job.PromptFile())
+	report.Plan = loadText(job, OutputPlan, job.PlanFile())
+	report.CommitMessage = loadText(job, OutputCommitMessage, job.CommitMessageFile())
+	report.TestOutput = tail(readOptional(job.TestOutputFile()), maxReportTestOutput)
+
+	diff, patch, err := LoadDiff(jobsDir, jobID)
+	if err == nil {
+		report.Diff = diff
+		report.Files = patchFiles(patch)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if input.BaseSHA != "" {
+		if _, err := os.Stat(job.WorkspacePath()); err == nil {
+			if commits, err := gitops.Open(job.WorkspacePath()).CommitsSince(ctx, input.BaseSHA); err == nil {
+				slices.Reverse(commits)
+				report.Commits = commits
+			}
+		}
+	}
+
+	if report.Artifacts, err = ListArtifacts(jobsDir, jobID); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if report.Timeline, err = readLogEntries(job.LogFile(), "MANFRED"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Status returns the job's final status, or its recorded one while it runs.
+func (r *Report) Status() Status {
+	if r.Result != nil {
+		return r.Result.Status
+	}
+	return r.Input.Status
+}
+
+// Write renders the report in format, md or html.
+func (r *Report) Write(format string) ([]byte, error) {
+	switch format {
+	case ReportMarkdown, "markdown":
+		return []byte(r.Markdown()), nil
+	case ReportHTML:
+		var buf bytes.Buffer
+		if err := reportTemplate.Execute(&buf, r); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown report format %q (use %s or %s)", format, ReportMarkdown, ReportHTML)
+	}
+}
+
+// Markdown renders the report as a Markdown document.
+func (r *Report) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# MANFRED job report: %s\n\n", r.Input.JobID)
+
+	fmt.Fprintf(&b, "| | |\n|---|---|\n")
+	for _, row := range r.Summary() {
+		fmt.Fprintf(&b, "| %s | %s |\n", row[0], strings.NewReplacer("|", `\|`, "\n", " ").Replace(row[1]))
+	}
+
+	section := func(title, body string) {
+		if strings.TrimSpace(body) != "" {
+			fmt.Fprintf(&b, "\n## %s\n\n%s\n", title, strings.TrimRight(body, "\n"))
+		}
+	}
+	section("Prompt", codeBlock(r.Prompt, "text"))
+	section("Plan", r.Plan)
+
+	var changes strings.Builder
+	if r.Diff != nil {
+		fmt.Fprintf(&changes, "%d file(s) changed, %d insertion(s), %d deletion(s) (`%s..%s`)\n",
+			r.Diff.FilesChanged, r.Diff.Insertions, r.Diff.Deletions, shortRef(r.Diff.BaseSHA), shortRef(r.Diff.HeadSHA))
+		if len(r.Files) > 0 {
+			changes.WriteString("\n| File | + | - |\n|---|---:|---:|\n")
+			for _, f := range r.Files {
+				fmt.Fprintf(&changes, "| `%s` | %d | %d |\n", f.Path, f.Insertions, f.Deletions)
+			}
+		}
+	}
+	section("Changes", changes.String())
+
+	var commits strings.Builder
+	for _, c := range r.Commits {
+		fmt.Fprintf(&commits, "- `%s`\n", c)
+	}
+	if r.CommitMessage != "" {
+		if commits.Len() > 0 {
+			commits.WriteString("\nCommit message written by Claude:\n\n")
+		}
+		commits.WriteString(codeBlock(r.CommitMessage, "text"))
+	}
+	section("Commits", commits.String())
+
+	var verification strings.Builder
+	if t := r.Tests(); t != nil {
+		detected := ""
+		if t.Detected {
+			detected = " (detected)"
+		}
+		fmt.Fprintf(&verification, "- Command: `%s`%s\n", t.Command, detected)
+		fmt.Fprintf(&verification, "- Result: %s (exit code %d)\n", passedText(t.Passed), t.ExitCode)
+		fmt.Fprintf(&verification, "- Runs: %d, fix attempts: %d\n", t.Attempts, t.FixAttempts)
+		if r.TestOutput != "" {
+			verification.WriteString("\nOutput of the last run:\n\n" + codeBlock(r.TestOutput, "text"))
+		}
+	} else if r.Result != nil {
+		verification.WriteString("No test command was run.\n")
+	}
+	section("Verification", verification.String())
+
+	var cost strings.Builder
+	for _, row := range r.Cost() {
+		fmt.Fprintf(&cost, "- %s: %s\n", row[0], row[1])
+	}
+	section("Cost", cost.String())
+
+	var artifacts strings.Builder
+	for _, a := range r.Artifacts {
+		fmt.Fprintf(&artifacts, "- `%s` (%d bytes)\n", a.Path, a.Size)
+	}
+	section("Artifacts", artifacts.String())
+
+	var timeline strings.Builder
+	for _, e := range r.Timeline {
+		fmt.Fprintf(&timeline, "- %s %s\n", e.Time.Format("15:04:05"), e.Message)
+	}
+	section("Timeline", timeline.String())
+
+	fmt.Fprintf(&b, "\n---\nGenerated by MANFRED on %s\n", r.GeneratedAt.Format(time.RFC3339))
+	return b.String()
+}
+
+// Summary returns the label and value rows at the top of the report.
+func (r *Report) Summary() [][2]string {
+	rows := [][2]string{
+		{"Project", r.Input.Project},
+		{"Status", string(r.Status())},
+		{"Base branch", r.Input.BaseBranch},
+	}
+	if r.Input.BaseSHA != "" {
+		rows = append(rows, [2]string{"Base commit", r.Input.BaseSHA})
+	}
+	rows = append(rows, [2]string{"Created", r.Input.CreatedAt.Format(time.RFC3339)})
+	if res := r.Result; res != nil {
+		if res.StartedAt != nil {
+			rows = append(rows, [2]string{"Started", res.StartedAt.Format(time.RFC3339)})
+		}
+		if res.CompletedAt != nil {
+			rows = append(rows, [2]string{"Finished", res.CompletedAt.Format(time.RFC3339)})
+		}
+		if res.Branch != "" {
+			rows = append(rows, [2]string{"Branch", res.Branch})
+		}
+		if res.Pushed && len(res.Repos) == 0 {
+			rows = append(rows, [2]string{"Pushed", res.HeadSHA})
+		}
+		for _, repo := range res.Repos {
+			rows = append(rows, [2]string{"Repository " + repo.Name, repo.Summary()})
+		}
+		for _, port := range res.Ports {
+			rows = append(rows, [2]string{"Port", port.String()})
+		}
+		if res.Error != "" {
+			rows = append(rows, [2]string{"Error", res.Error})
+		}
+	}
+	return rows
+}
+
+// Cost returns the label and value rows of the cost section.
+func (r *Report) Cost() [][2]string {
+	var rows [][2]string
+	if r.Result != nil {
+		if d := r.Result.Duration(); d > 0 {
+			rows = append(rows, [2]string{"Duration", d.Round(time.Second).String()})
+		}
+		if t := r.Result.Tokens; t != nil {
+			rows = append(rows, [2]string{"Anthropic API tokens", fmt.Sprintf("%d input, %d output", t.InputTokens, t.OutputTokens)})
+		} else {
+			rows = append(rows, [2]string{"Anthropic API tokens", "not reported by Claude Code"})
+		}
+	}
+	if u := r.Resources; u != nil {
+		rows = append(rows, [2]string{"Peak container usage", u.String()})
+	}
+	return rows
+}
+
+// Tests returns the test result, nil if no tests ran.
+func (r *Report) Tests() *TestResult {
+	if r.Result == nil {
+		return nil
+	}
+	return r.Result.Tests
+}
+
+func passedText(passed bool) string {
+	if passed {
+		return "passed"
+	}
+	return "failed"
+}
+
+// shortRef abbreviates a commit SHA.
+func shortRef(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+// codeBlock fences text, with a fence longer than any backtick run in it.
+func codeBlock(text, lang string) string {
+	if strings.TrimSpace(text) == "" {
+		return ""
+	}
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + strings.TrimRight(text, "\n") + "\n" + fence + "\n"
+}
+
+// readOptional returns the content of a file, "" if it cannot be read.
+func readOptional(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// patchFiles returns the files of a patch with their changed line counts,
+// in patch order.
+func patchFiles(patch string) []FileChange {
+	var files []FileChange
+	var current *FileChange
+	for _, line := range strings.Split(patch, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			path := line[len("diff --git "):]
+			if i := strings.LastIndex(path, " b/"); i >= 0 {
+				path = path[i+len(" b/"):]
+			}
+			files = append(files, FileChange{Path: path})
+			current = &files[len(files)-1]
+		case current == nil, strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "):
+		case strings.HasPrefix(line, "+"):
+			current.Insertions++
+		case strings.HasPrefix(line, "-"):
+			current.Deletions++
+		}
+	}
+	return files
+}
+
+// readLogEntries reads the entries of a job log written in text or JSON
+// format, keeping those of the given source.
+func readLogEntries(path, source string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		e, ok := parseLogLine(scanner.Text())
+		if ok && e.Source == source {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// parseLogLine parses a line of a text or JSON job log.
+func parseLogLine(line string) (Entry, bool) {
+	var e Entry
+	if strings.HasPrefix(line, "{") {
+		return e, json.Unmarshal([]byte(line), &e) == nil
+	}
+	// [2006-01-02T15:04:05Z] [SOURCE  ] message
+	stamp, rest, ok := strings.Cut(strings.TrimPrefix(line, "["), "] [")
+	if !ok || !strings.HasPrefix(line, "[") {
+		return e, false
+	}
+	source, message, ok := strings.Cut(rest, "] ")
+	if !ok {
+		return e, false
+	}
+	t, err := time.Parse("2006-01-02T15:04:05Z", stamp)
+	if err != nil {
+		return e, false
+	}
+	return Entry{Time: t, Source: strings.TrimSpace(source), Message: message}, true
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"passed": passedText,
+	"short":  shortRef,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>MANFRED job report: {{.Input.JobID}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 960px; margin: 2em auto; padding: 0 1em; color: #1f2328; }
+h1 { font-size: 1.6em; border-bottom: 1px solid #d0d7de; padding-bottom: .3em; }
+h2 { font-size: 1.25em; margin-top: 1.6em; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #d0d7de; padding: .3em .7em; text-align: left; vertical-align: top; }
+td.num { text-align: right; }
+pre { background: #f6f8fa; padding: 1em; overflow-x: auto; white-space: pre-wrap; }
+code { font-family: ui-monospace, SFMono-Regular, Menlo, monospace; font-size: .9em; }
+.completed { color: #1a7f37; } .failed { color: #cf222e; }
+footer { margin-top: 2em; color: #656d76; font-size: .85em; }
+</style>
+</head>
+<body>
+<h1>MANFRED job report: {{.Input.JobID}}</h1>
+<table>
+{{- range .Summary}}
+<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{- end}}
+</table>
+{{- if .Prompt}}
+<h2>Prompt</h2>
+<pre>{{.Prompt}}</pre>
+{{- end}}
+{{- if .Plan}}
+<h2>Plan</h2>
+<pre>{{.Plan}}</pre>
+{{- end}}
+{{- with .Diff}}
+<h2>Changes</h2>
+<p>{{.FilesChanged}} file(s) changed, {{.Insertions}} insertion(s), {{.Deletions}} deletion(s) (<code>{{short .BaseSHA}}..{{short .HeadSHA}}</code>)</p>
+{{- end}}
+{{- if .Files}}
+<table>
+<tr><th>File</th><th>+</th><th>-</th></tr>
+{{- range .Files}}
+<tr><td><code>{{.Path}}</code></td><td class="num">{{.Insertions}}</td><td class="num">{{.Deletions}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if or .Commits .CommitMessage}}
+<h2>Commits</h2>
+{{- if .Commits}}
+<ul>
+{{- range .Commits}}
+<li><code>{{.}}</code></li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .CommitMessage}}
+<pre>{{.CommitMessage}}</pre>
+{{- end}}
+{{- end}}
+{{- with .Tests}}
+<h2>Verification</h2>
+<ul>
+<li>Command: <code>{{.Command}}</code>{{if .Detected}} (detected){{end}}</li>
+<li>Result: <span class="{{if .Passed}}completed{{else}}failed{{end}}">{{passed .Passed}}</span> (exit code {{.ExitCode}})</li>
+<li>Runs: {{.Attempts}}, fix attempts: {{.FixAttempts}}</li>
+</ul>
+{{- if $.TestOutput}}
+<pre>{{$.TestOutput}}</pre>
+{{- end}}
+{{- end}}
+{{- with .Cost}}
+<h2>Cost</h2>
+<ul>
+{{- range .}}
+<li>{{index . 0}}: {{index . 1}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .Artifacts}}
+<h2>Artifacts</h2>
+<ul>
+{{- range .Artifacts}}
+<li><code>{{.Path}}</code> ({{.Size}} bytes)</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .Timeline}}
+<h2>Timeline</h2>
+<table>
+{{- range .Timeline}}
+<tr><td>{{.Time.Format "15:04:05"}}</td><td>{{.Message}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+<footer>Generated by MANFRED on {{.GeneratedAt.Format "2006-01-02T15:04:05Z07:00"}}</footer>
+</body>
+</html>
+`))
@@ -0,0 +1,99 @@
+package job
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpm/manfred/internal/gitops"
+)
+
+func TestBuildReport(t *testing.T) {
+	jobsDir := t.TempDir()
+	j := New("widgets", "Fix the <login> form\n```\ncode\n```", jobsDir)
+	if err := j.CreateDirectories(); err != nil {
+		t.Fatal(err)
+	}
+	j.BaseSHA = "0123456789abcdef0123"
+	j.BranchName = "manfred/" + j.ID
+	j.Start()
+	j.TestResult = &TestResult{Command: "go test ./...", Passed: true, Attempts: 2, FixAttempts: 1, Output: "ok"}
+	j.Complete()
+
+	if err := writeInput(j, "main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeResult(j); err != nil {
+		t.Fatal(err)
+	}
+	patch := "diff --git a/login.go b/login.go\n--- a/login.go\n+++ b/login.go\n@@ -1,2 +1,2 @@\n-old\n+new\n+more\n"
+	diff := &Diff{BaseSHA: j.BaseSHA, HeadSHA: "fedcba9876543210", DiffStat: gitops.DiffStat{FilesChanged: 1, Insertions: 2, Deletions: 1}}
+	if err := writeDiff(j, diff, patch); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		j.PromptFile():        j.Prompt,
+		j.CommitMessageFile(): "Fix the login form",
+		j.TestOutputFile():    "ok  \twidgets\t0.1s",
+		j.LogFile(): "[2026-01-02T10:00:00Z] [MANFRED ] Starting job\n" +
+			"[2026-01-02T10:00:01Z] [CLAUDE  ] thinking\n" +
+			`{"time":"2026-01-02T10:05:00Z","source":"MANFRED","message":"Job completed successfully"}` + "\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := BuildReport(context.Background(), jobsDir, j.ID)
+	if err != nil {
+		t.Fatalf("BuildReport() error = %v", err)
+	}
+	if report.Status() != StatusCompleted {
+		t.Errorf("Status() = %s, want completed", report.Status())
+	}
+	if len(report.Files) != 1 || report.Files[0] != (FileChange{Path: "login.go", Insertions: 2, Deletions: 1}) {
+		t.Errorf("Files = %+v, want login.go +2 -1", report.Files)
+	}
+	if len(report.Timeline) != 2 || report.Timeline[1].Message != "Job completed successfully" {
+		t.Errorf("Timeline = %+v, want the two MANFRED entries", report.Timeline)
+	}
+	if tests := report.Tests(); tests == nil || tests.Output != "" || tests.Attempts != 2 {
+		t.Errorf("Tests() = %+v, want the result without output", tests)
+	}
+
+	md, err := report.Write(ReportMarkdown)
+	if err != nil {
+		t.Fatalf("Write(md) error = %v", err)
+	}
+	for _, want := range []string{"# MANFRED job report: " + j.ID, "| Status | completed |", "````text\nFix the <login> form", "| `login.go` | 2 | 1 |", "- Result: passed", "## Timeline"} {
+		if !strings.Contains(string(md), want) {
+			t.Errorf("Markdown report lacks %q:\n%s", want, md)
+		}
+	}
+
+	html, err := report.Write(ReportHTML)
+	if err != nil {
+		t.Fatalf("Write(html) error = %v", err)
+	}
+	if !strings.Contains(string(html), "Fix the &lt;login&gt; form") || strings.Contains(string(html), "<login>") {
+		t.Errorf("HTML report does not escape the prompt:\n%s", html)
+	}
+
+	if _, err := report.Write("pdf"); err == nil {
+		t.Error("Write(pdf) succeeded, want an unknown format error")
+	}
+}
+
+func TestParseLogLine(t *testing.T) {
+	e, ok := parseLogLine("[2026-01-02T10:00:00Z] [DOCKER  ] Container started")
+	want := Entry{Time: time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC), Source: "DOCKER", Message: "Container started"}
+	if !ok || e != want {
+		t.Errorf("parseLogLine(text) = %+v, %v; want %+v", e, ok, want)
+	}
+	if _, ok := parseLogLine("────────"); ok {
+		t.Error("parseLogLine(separator) succeeded")
+	}
+}
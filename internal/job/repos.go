@@ -0,0 +1,269 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/gitops"
+)
+
+// RepoResult is the outcome of a job in one repository of a project with
+// several (repos: in project.yml).
+type RepoResult struct {
+	Name        string `json:"name"`
+	Repo        string `json:"repo"`
+	BaseBranch  string `json:"base_branch"`
+	BaseSHA     string `json:"base_sha"`
+	HeadSHA     string `json:"head_sha,omitempty"` // Pushed head commit
+	Commits     int    `json:"commits"`
+	Pushed      bool   `json:"pushed"`
+	PullRequest string `json:"pull_request,omitempty"` // URL of the pull request opened for it
+
+	repo *gitops.Repo
+}
+
+// Summary describes the outcome in one line, e.g. "2 commit(s) on main,
+// pushed abc123, https://github.com/acme/api/pull/7".
+func (r RepoResult) Summary() string {
+	summary := fmt.Sprintf("%d commit(s) on %s", r.Commits, r.BaseBranch)
+	if r.Pushed {
+		summary += ", pushed " + shortRef(r.HeadSHA)
+	}
+	if r.PullRequest != "" {
+		summary += ", " + r.PullRequest
+	}
+	return summary
+}
+
+// PullRequestClient opens the pull requests of multi-repository jobs.
+type PullRequestClient interface {
+	CreatePullRequest(ctx context.Context, owner, repo string, input *github.CreatePullRequestInput) (*github.PullRequest, error)
+}
+
+// RepoPath returns the workspace directory a repository of a
+// multi-repository project is cloned into.
+func (j *Job) RepoPath(name string) string {
+	return filepath.Join(j.WorkspacePath(), name)
+}
+
+// cloneRepositories clones every repository of a multi-repository project
+// into workspace/<name> and creates the job's branch in each. Claude works
+// in the workspace, which holds them all.
+func (r *Runner) cloneRepositories(ctx context.Context, job *Job, projectConfig *config.ProjectConfig, opts RunOptions) error {
+	if opts.Branch != "" && !opts.NewBranch {
+		return fmt.Errorf("project %s has several repos; jobs cannot check out an existing branch", job.ProjectName)
+	}
+	branchName := fmt.Sprintf("manfred/%s", job.ID)
+	if opts.Branch != "" {
+		branchName = opts.Branch
+	}
+
+	for _, rc := range projectConfig.Repos {
+		r.logger.Docker(fmt.Sprintf("Cloning repository %s: %s", rc.Name, rc.Repo))
+		cloneOpts, err := r.cloneOptions(ctx, job.ProjectName, projectConfig, rc.Repo)
+		if err != nil {
+			return err
+		}
+		cloneOpts.Branch = rc.DefaultBranch
+		if opts.CloneDepth > 0 {
+			cloneOpts.Depth = opts.CloneDepth
+		}
+		repo, err := gitops.Clone(ctx, rc.Repo, job.RepoPath(rc.Name), cloneOpts)
+		if err != nil {
+			return fmt.Errorf("failed to clone repository %s: %w", rc.Name, err)
+		}
+
+		result := RepoResult{Name: rc.Name, Repo: rc.Repo, repo: repo}
+		if result.BaseBranch, err = repo.CurrentBranch(ctx); err != nil {
+			return fmt.Errorf("failed to get the branch of %s: %w", rc.Name, err)
+		}
+		if result.BaseSHA, err = repo.RevParse(ctx, "HEAD"); err != nil {
+			return fmt.Errorf("failed to get base SHA of %s: %w", rc.Name, err)
+		}
+		if err := repo.CreateBranch(ctx, branchName); err != nil {
+			return fmt.Errorf("failed to create branch in %s: %w", rc.Name, err)
+		}
+		job.Repos = append(job.Repos, result)
+		r.logger.Docker(fmt.Sprintf("Repository %s cloned, base %s at %s", rc.Name, result.BaseBranch, result.BaseSHA))
+	}
+	job.BranchName = branchName
+	return nil
+}
+
+// sanitizeRepositories is sanitizeWorkspace for each repository of a
+// multi-repository job.
+func (r *Runner) sanitizeRepositories(ctx context.Context, job *Job) error {
+	for _, result := range job.Repos {
+		removed, err := result.repo.Sanitize(ctx)
+		if err != nil {
+			return fmt.Errorf("unsafe workspace %s: %w", result.Name, err)
+		}
+		if len(removed) > 0 {
+			r.logger.Manfred(fmt.Sprintf("Removed git config from %s before running git on the host: %s", result.Name, strings.Join(removed, ", ")))
+		}
+	}
+	return nil
+}
+
+// verifyRepositories logs the commits and uncommitted changes Claude left
+// in each repository of a multi-repository job.
+func (r *Runner) verifyRepositories(ctx context.Context, job *Job) {
+	r.logger.Manfred("Verifying git state...")
+	for _, result := range job.Repos {
+		if status, err := result.repo.Status(ctx); err == nil && len(status) > 0 {
+			r.logger.Manfred(fmt.Sprintf("%s: %d uncommitted change(s)", result.Name, len(status)))
+		}
+		commits, err := result.repo.CommitsSince(ctx, result.BaseSHA)
+		if err != nil {
+			r.logger.Manfred(fmt.Sprintf("Warning: could not list commits of %s: %v", result.Name, err))
+			continue
+		}
+		r.logger.Manfred(fmt.Sprintf("%s: %d commit(s)", result.Name, len(commits)))
+		for _, line := range commits {
+			r.logger.Manfred(fmt.Sprintf("  %s", line))
+		}
+	}
+}
+
+// finalizeRepositories commits what Claude left uncommitted in each
+// repository of a multi-repository job and pushes the job's branch of every
+// repository that changed. With a client, it opens a pull request for each
+// pushed branch; the pull requests link each other's repositories.
+func (r *Runner) finalizeRepositories(ctx context.Context, job *Job, projectConfig *config.ProjectConfig, client PullRequestClient) error {
+	message := job.CommitMessage
+	if message == "" {
+		message = fmt.Sprintf("Changes from MANFRED job %s", job.ID)
+	}
+
+	for i := range job.Repos {
+		result := &job.Repos[i]
+		if status, err := result.repo.Status(ctx); err != nil {
+			return fmt.Errorf("failed to check git status of %s: %w", result.Name, err)
+		} else if len(status) > 0 {
+			r.logger.Manfred(fmt.Sprintf("Committing uncommitted changes in %s...", result.Name))
+			if err := result.repo.CommitAll(ctx, message); err != nil {
+				return fmt.Errorf("failed to commit changes in %s: %w", result.Name, err)
+			}
+		}
+
+		n, err := result.repo.CountCommitsSince(ctx, result.BaseSHA)
+		if err != nil {
+			return fmt.Errorf("failed to count commits of %s: %w", result.Name, err)
+		}
+		result.Commits = n
+		if n == 0 {
+			r.logger.Manfred(fmt.Sprintf("%s: no changes, skipping push", result.Name))
+			continue
+		}
+
+		if result.repo, err = r.refreshAuth(ctx, result.repo, projectConfig, result.Repo); err != nil {
+			return err
+		}
+		r.logger.Manfred(fmt.Sprintf("Pushing branch %s of %s...", job.BranchName, result.Name))
+		if err := result.repo.Push(ctx, job.BranchName, true); err != nil {
+			return fmt.Errorf("failed to push branch %s of %s: %w", job.BranchName, result.Name, err)
+		}
+		if result.HeadSHA, err = result.repo.RevParse(ctx, "HEAD"); err != nil {
+			return fmt.Errorf("failed to get head SHA of %s: %w", result.Name, err)
+		}
+		result.Pushed = true
+		job.Pushed = true
+	}
+
+	if client == nil {
+		r.logger.Manfred("No GitHub client, not opening pull requests")
+		return nil
+	}
+	for i := range job.Repos {
+		result := &job.Repos[i]
+		if !result.Pushed {
+			continue
+		}
+		owner, repo, ok := config.ParseGitHubRepo(result.Repo)
+		if !ok {
+			r.logger.Manfred(fmt.Sprintf("Warning: %s is not a GitHub repository, not opening a pull request", result.Name))
+			continue
+		}
+		pr, err := client.CreatePullRequest(ctx, owner, repo, &github.CreatePullRequestInput{
+			Title: pullRequestTitle(job),
+			Body:  pullRequestBody(job, result.Name),
+			Head:  job.BranchName,
+			Base:  result.BaseBranch,
+		})
+		if err != nil {
+			r.logger.Manfred(fmt.Sprintf("Warning: failed to open a pull request for %s: %v", result.Name, err))
+			continue
+		}
+		result.PullRequest = pr.HTMLURL
+		r.logger.Manfred(fmt.Sprintf("%s: opened pull request %s", result.Name, pr.HTMLURL))
+	}
+	return nil
+}
+
+// pullRequestTitle returns the title of a multi-repository job's pull
+// requests: the summary line of its commit message.
+func pullRequestTitle(job *Job) string {
+	title, _, _ := strings.Cut(strings.TrimSpace(job.CommitMessage), "\n")
+	if title == "" {
+		title = fmt.Sprintf("Changes from MANFRED job %s", job.ID)
+	}
+	return title
+}
+
+// pullRequestBody returns the body of the pull request for the repository
+// name: the commit message details and the other repositories the job
+// changed on the same branch.
+func pullRequestBody(job *Job, name string) string {
+	var b strings.Builder
+	if _, details, ok := strings.Cut(strings.TrimSpace(job.CommitMessage), "\n"); ok && strings.TrimSpace(details) != "" {
+		b.WriteString(strings.TrimSpace(details) + "\n\n")
+	}
+	var others []string
+	for _, result := range job.Repos {
+		if result.Name != name && result.Pushed {
+			others = append(others, fmt.Sprintf("- %s (%s)", result.Name, result.Repo))
+		}
+	}
+	if len(others) > 0 {
+		fmt.Fprintf(&b, "Part of a change across repositories; branch `%s` also changed:\n\n%s\n\n", job.BranchName, strings.Join(others, "\n"))
+	}
+	fmt.Fprintf(&b, "Opened by MANFRED job `%s`.", job.ID)
+	return b.String()
+}
+
+// computeRepoDiffs returns the combined diff of a multi-repository job: the
+// summed stat and the patches of the repositories that changed, each
+// headed by a comment naming the repository.
+func computeRepoDiffs(ctx context.Context, job *Job) (*Diff, string, error) {
+	diff := &Diff{}
+	var patch strings.Builder
+	for _, result := range job.Repos {
+		if _, err := os.Stat(job.RepoPath(result.Name)); err != nil {
+			continue
+		}
+		stat, err := result.repo.DiffStat(ctx, result.BaseSHA)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", result.Name, err)
+		}
+		if stat.FilesChanged == 0 {
+			continue
+		}
+		p, err := result.repo.Diff(ctx, result.BaseSHA)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", result.Name, err)
+		}
+		diff.FilesChanged += stat.FilesChanged
+		diff.Insertions += stat.Insertions
+		diff.Deletions += stat.Deletions
+		fmt.Fprintf(&patch, "# Repository %s\n%s", result.Name, p)
+		if !strings.HasSuffix(p, "\n") {
+			patch.WriteString("\n")
+		}
+	}
+	return diff, patch.String(), nil
+}
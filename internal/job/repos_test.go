@@ -0,0 +1,110 @@
+package job
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+)
+
+type fakePullRequests struct {
+	opened map[string]*github.CreatePullRequestInput
+}
+
+func (f *fakePullRequests) CreatePullRequest(ctx context.Context, owner, repo string, input *github.CreatePullRequestInput) (*github.PullRequest, error) {
+	f.opened[owner+"/"+repo] = input
+	return &github.PullRequest{HTMLURL: "https://github.com/" + owner + "/" + repo + "/pull/1"}, nil
+}
+
+func TestMultiRepoJob(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	for _, name := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(name, "test")
+	}
+	for _, name := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(name, "test@example.com")
+	}
+	git := func(dir string, args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	// Two remotes with one commit on main each
+	remotes := t.TempDir()
+	for _, name := range []string{"api", "web"} {
+		src := filepath.Join(remotes, name+"-src")
+		if err := os.MkdirAll(src, 0755); err != nil {
+			t.Fatal(err)
+		}
+		git(src, "init", "-q", "-b", "main")
+		git(src, "commit", "-q", "--allow-empty", "-m", "base")
+		git(remotes, "clone", "-q", "--bare", src, name+".git")
+	}
+
+	r, job := newHookTestRunner(t)
+	projectConfig := &config.ProjectConfig{Repos: []config.RepoConfig{
+		{Name: "api", Repo: filepath.Join(remotes, "api.git")},
+		{Name: "web", Repo: filepath.Join(remotes, "web.git")},
+	}}
+	ctx := context.Background()
+	if err := r.cloneRepositories(ctx, job, projectConfig, RunOptions{}); err != nil {
+		t.Fatalf("cloneRepositories() error = %v", err)
+	}
+	if len(job.Repos) != 2 || job.Repos[0].BaseBranch != "main" || job.BranchName != "manfred/"+job.ID {
+		t.Fatalf("after cloneRepositories() repos = %+v, branch = %q", job.Repos, job.BranchName)
+	}
+
+	// Claude changes only the api repository, leaving it uncommitted
+	if err := os.WriteFile(filepath.Join(job.RepoPath("api"), "handler.go"), []byte("package api\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	job.CommitMessage = "feat: Add handler\n\n- Serve the new endpoint"
+	job.Repos[0].Repo = "https://github.com/acme/api.git"
+	job.Repos[1].Repo = "https://github.com/acme/web.git"
+
+	client := &fakePullRequests{opened: map[string]*github.CreatePullRequestInput{}}
+	if err := r.finalizeRepositories(ctx, job, &config.ProjectConfig{}, client); err != nil {
+		t.Fatalf("finalizeRepositories() error = %v", err)
+	}
+
+	api, web := job.Repos[0], job.Repos[1]
+	if !api.Pushed || api.Commits != 1 || api.PullRequest != "https://github.com/acme/api/pull/1" {
+		t.Errorf("api = %+v, want one pushed commit with a pull request", api)
+	}
+	if web.Pushed || web.PullRequest != "" {
+		t.Errorf("web = %+v, want unchanged", web)
+	}
+	if !job.Pushed {
+		t.Error("job.Pushed = false, want true")
+	}
+	pr := client.opened["acme/api"]
+	if len(client.opened) != 1 || pr == nil {
+		t.Fatalf("opened pull requests = %v, want one for acme/api", client.opened)
+	}
+	if pr.Title != "feat: Add handler" || pr.Base != "main" || pr.Head != job.BranchName {
+		t.Errorf("pull request = %+v", pr)
+	}
+	if !strings.Contains(pr.Body, "- Serve the new endpoint") {
+		t.Errorf("pull request body = %q", pr.Body)
+	}
+	if out, err := exec.Command("git", "-C", filepath.Join(remotes, "api.git"), "rev-parse", job.BranchName).CombinedOutput(); err != nil || strings.TrimSpace(string(out)) != api.HeadSHA {
+		t.Errorf("remote branch = %s (%v), want %s", out, err, api.HeadSHA)
+	}
+
+	diff, patch, err := computeRepoDiffs(ctx, job)
+	if err != nil {
+		t.Fatalf("computeRepoDiffs() error = %v", err)
+	}
+	if diff.FilesChanged != 1 || !strings.Contains(patch, "# Repository api\n") || strings.Contains(patch, "Repository web") {
+		t.Errorf("computeRepoDiffs() = %+v, %q", diff, patch)
+	}
+}
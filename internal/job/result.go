@@ -0,0 +1,158 @@
+package job
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mpm/manfred/internal/anthropic"
+	"github.com/mpm/manfred/internal/docker"
+)
+
+// Result records how a finished job ended, for reports on the job after the
+// process that ran it is gone. The prompt, plan, diff and resource usage
+// are stored in their own files next to it.
+type Result struct {
+	JobID       string               `json:"job_id"`
+	Status      Status               `json:"status"`
+	Error       string               `json:"error,omitempty"`
+	StartedAt   *time.Time           `json:"started_at,omitempty"`
+	CompletedAt *time.Time           `json:"completed_at,omitempty"`
+	Branch      string               `json:"branch,omitempty"`
+	BaseSHA     string               `json:"base_sha,omitempty"`
+	HeadSHA     string               `json:"head_sha,omitempty"` // Pushed head commit
+	Pushed      bool                 `json:"pushed"`
+	Conflict    *Conflict            `json:"conflict,omitempty"` // Failed rebase onto the base branch
+	Repos       []RepoResult         `json:"repos,omitempty"`    // Multi-repository projects
+	Ports       []docker.PortMapping `json:"ports,omitempty"`    // Host ports of the compose services
+	Tests       *TestResult          `json:"tests,omitempty"`    // Without the output, see TestOutputFile
+	Tokens      *anthropic.Usage     `json:"tokens,omitempty"`
+}
+
+// Duration returns how long the job ran, 0 if it has not finished.
+func (r *Result) Duration() time.Duration {
+	if r.StartedAt == nil || r.CompletedAt == nil {
+		return 0
+	}
+	return r.CompletedAt.Sub(*r.StartedAt)
+}
+
+// ResultFile returns the path of the job's result record.
+func (j *Job) ResultFile() string {
+	return filepath.Join(j.JobPath(), ".manfred", "result.json")
+}
+
+// writeResult records the outcome of a finished job.
+func writeResult(job *Job) error {
+	result := Result{
+		JobID:       job.ID,
+		Status:      job.Status,
+		Error:       job.Error,
+		StartedAt:   job.StartedAt,
+		CompletedAt: job.CompletedAt,
+		Branch:      job.BranchName,
+		BaseSHA:     job.BaseSHA,
+		HeadSHA:     job.HeadSHA,
+		Pushed:      job.Pushed,
+		Conflict:    job.Conflict,
+		Repos:       job.Repos,
+		Ports:       job.Ports,
+		Tokens:      job.Tokens,
+	}
+	if job.TestResult != nil {
+		tests := *job.TestResult
+		tests.Output = ""
+		result.Tests = &tests
+	}
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(job.ResultFile(), append(data, '\n'), 0644)
+}
+
+// LoadResult reads the result record of a job, nil if the job has not
+// finished or predates result records.
+func LoadResult(jobsDir, jobID string) (*Result, error) {
+	job := &Job{ID: jobID, jobsDir: jobsDir}
+	data, err := os.ReadFile(job.ResultFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var result Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", job.ResultFile(), err)
+	}
+	return &result, nil
+}
+
+// Load rebuilds a job from its directory in jobsDir: its input and result
+// records, prompt, plan, commit message, diff and resource usage. The status
+// is the input's recorded one until the job has a result.
+func Load(jobsDir, jobID string) (*Job, error) {
+	if err := checkJobID(jobID); err != nil {
+		return nil, err
+	}
+	input, err := LoadInput(jobsDir, jobID)
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{
+		ID:          jobID,
+		ProjectName: input.Project,
+		Status:      input.Status,
+		CreatedAt:   input.CreatedAt,
+		InputHash:   input.Hash,
+		BaseSHA:     input.BaseSHA,
+		jobsDir:     jobsDir,
+	}
+	job.Prompt = readOptional(job.PromptFile())
+	job.Context = readOptional(job.ContextFile())
+	job.Plan = loadText(job, OutputPlan, job.PlanFile())
+	job.CommitMessage = loadText(job, OutputCommitMessage, job.CommitMessageFile())
+
+	result, err := LoadResult(jobsDir, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if result != nil {
+		job.Status = result.Status
+		job.Error = result.Error
+		job.StartedAt = result.StartedAt
+		job.CompletedAt = result.CompletedAt
+		job.BranchName = result.Branch
+		job.HeadSHA = result.HeadSHA
+		job.Pushed = result.Pushed
+		job.Conflict = result.Conflict
+		job.Repos = result.Repos
+		job.Ports = result.Ports
+		job.TestResult = result.Tests
+		job.Tokens = result.Tokens
+	}
+	if diff, _, err := LoadDiff(jobsDir, jobID); err == nil {
+		job.Diff = diff
+	}
+	if job.Resources, err = LoadResources(jobsDir, jobID); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// loadText returns a text output of a finished job recorded with the output
+// helper or written to legacyPath, "" if there is none.
+func loadText(job *Job, name, legacyPath string) string {
+	if text, err := readOutput(job, name); err == nil {
+		return text
+	}
+	text, err := readTextFile(legacyPath)
+	if err != nil {
+		return ""
+	}
+	return text
+}
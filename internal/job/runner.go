@@ -5,25 +5,37 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/mpm/manfred/internal/anthropic"
+	"github.com/mpm/manfred/internal/bundle"
 	"github.com/mpm/manfred/internal/config"
 	"github.com/mpm/manfred/internal/docker"
+	"github.com/mpm/manfred/internal/gitops"
+	"github.com/mpm/manfred/internal/notify"
 )
 
 const (
-	// ContainerCommitMessagePath is where Claude writes the commit message.
+	// ComposeProjectPrefix starts the Docker Compose project name of every
+	// job, followed by the job ID.
+	ComposeProjectPrefix = "manfred_"
+
+	// ContainerCommitMessagePath is where older prompts asked Claude to
+	// write the commit message. It is still read when the output helper was
+	// not used.
 	ContainerCommitMessagePath = "/manfred-job/.manfred/commit_message.txt"
 
+	// ContainerPlanPath is the legacy location of the plan in plan-only jobs.
+	ContainerPlanPath = "/manfred-job/.manfred/plan.md"
+
 	// CommitMessagePrompt is the prompt for phase 2.
 	CommitMessagePrompt = `Please summarize the changes you made in this session and create a git commit message.
 
 Requirements:
-1. First, create the directory if it doesn't exist: mkdir -p /manfred-job/.manfred
-2. Write ONLY the commit message to the file: /manfred-job/.manfred/commit_message.txt
+1. Write the commit message to a file, e.g. /tmp/commit_message.txt
+2. Hand ONLY the commit message to MANFRED: ` + ContainerOutputHelper + ` commit_message /tmp/commit_message.txt
 3. The commit message should follow conventional commit format
 4. Include a brief summary line (max 72 chars) followed by a blank line and bullet points for details
 
@@ -33,39 +45,202 @@ feat: Add user authentication system
 - Implement login/logout functionality
 - Add session management
 - Create user model with password hashing`
+
+	// ContextSystemPrompt points Claude to the project context file (%s),
+	// whose content also starts the job prompt.
+	ContextSystemPrompt = `This project's standing context (coding standards, architecture notes) is in %s. Follow it throughout the session.`
 )
 
 // Runner orchestrates job execution.
 type Runner struct {
-	config *config.Config
-	docker *docker.Client
-	logger *Logger
+	config   *config.Config
+	docker   *docker.Client
+	logger   *Logger
+	outputs  outputLimits
+	notifier *notify.Notifier
+
+	// ownsNotifier is set when the runner created the notifier and closes
+	// it with the runner
+	ownsNotifier bool
+
+	// exec runs commands in job containers; the docker client, or a fake
+	// in tests
+	exec containerExec
+}
+
+// containerExec runs commands in a running container. *docker.Client
+// implements it.
+type containerExec interface {
+	Exec(ctx context.Context, containerName string, command []string, opts docker.ExecOptions) (*docker.ExecResult, error)
+	ExecAs(ctx context.Context, containerName, user string, command []string) (string, error)
+	UserHome(ctx context.Context, containerName, user string) string
+}
+
+// RunnerOption configures a Runner.
+type RunnerOption func(*Runner)
+
+// WithNotifier sends job notifications through n instead of a notifier
+// the runner creates from the config. The caller keeps ownership of n.
+func WithNotifier(n *notify.Notifier) RunnerOption {
+	return func(r *Runner) {
+		r.notifier = n
+	}
 }
 
 // NewRunner creates a new job runner.
-func NewRunner(cfg *config.Config) (*Runner, error) {
+func NewRunner(cfg *config.Config, opts ...RunnerOption) (*Runner, error) {
 	dockerClient, err := docker.New()
 	if err != nil {
 		return nil, err
 	}
 
-	return &Runner{
-		config: cfg,
-		docker: dockerClient,
-		logger: NewLogger(),
-	}, nil
+	console := NewTextSink(os.Stdout)
+	if cfg.Logging.Format == "json" {
+		console = NewJSONSink(os.Stdout)
+	}
+	logger := NewLogger(console)
+	logger.SetMaxLineBytes(cfg.Job.Output.MaxLineBytes)
+	logger.SetSanitize(cfg.Logging.Sanitize)
+	r := &Runner{
+		config:  cfg,
+		docker:  dockerClient,
+		logger:  logger,
+		outputs: outputLimits{maxBytes: cfg.Job.Output.MaxBytes},
+		exec:    dockerClient,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.notifier == nil {
+		notifier, err := notify.New(cfg.Notify)
+		if err != nil {
+			logger.Manfred(fmt.Sprintf("Warning: %v", err))
+		}
+		r.notifier = notifier
+		r.ownsNotifier = true
+	}
+	return r, nil
+}
+
+// RunOptions customizes how a job is executed.
+type RunOptions struct {
+	// Branch checks out an existing remote branch instead of creating a new
+	// manfred/<job-id> branch. Requires repo: in project.yml.
+	Branch string
+
+	// NewBranch creates Branch from the default branch instead of checking
+	// out an existing remote branch.
+	NewBranch bool
+
+	// Push pushes the branch to origin after Claude finishes.
+	Push bool
+
+	// CloneDepth overrides the configured shallow clone depth when > 0.
+	CloneDepth int
+
+	// PlanOnly runs only the main prompt and reads the plan Claude handed
+	// back as OutputPlan. Tests, the commit message phase and git checks are
+	// skipped.
+	PlanOnly bool
+
+	// Triage makes a PlanOnly job an issue triage, which always runs over
+	// the Anthropic API with the job.triage model and limits, whatever the
+	// planning mode.
+	Triage bool
+
+	// SaveConversation keeps Claude's conversation in the job directory
+	// once the main prompt is done, for a later job to continue.
+	SaveConversation bool
+
+	// ContinueJob is an earlier job that saved its conversation. The main
+	// prompt continues that conversation (claude --continue).
+	ContinueJob string
+
+	// Health is the Anthropic API health check shared by the process's
+	// jobs (see NewHealthCheck). Nil checks the API once for this job,
+	// unless job.health is disabled.
+	Health *anthropic.Breaker
+
+	// OnStart is called with the job once its directory exists, before
+	// anything runs, e.g. to record which job belongs to a session.
+	OnStart func(*Job)
+
+	// Notify describes what the job is for (repository, issue, session) in
+	// its job_completed and job_failed notifications.
+	Notify notify.Notification
+
+	// LogSinks receive this run's log in addition to the console and the
+	// job log, e.g. a Hub streaming it to a browser. They are closed when
+	// the run ends.
+	LogSinks []Sink
+
+	// PullRequests opens a pull request for each changed repository of a
+	// project with several (repos: in project.yml). Without it, their
+	// branches are only pushed.
+	PullRequests PullRequestClient
 }
 
 // Run executes a job for the given project and prompt.
 func (r *Runner) Run(ctx context.Context, projectName, prompt string) (*Job, error) {
+	return r.RunWithOptions(ctx, projectName, prompt, RunOptions{})
+}
+
+// RunWithOptions executes a job for the given project and prompt using opts.
+func (r *Runner) RunWithOptions(ctx context.Context, projectName, prompt string, opts RunOptions) (*Job, error) {
 	// Validate project
 	projectConfig, err := r.validateProject(projectName)
 	if err != nil {
 		return nil, err
 	}
 
+	// Fail before cloning and starting containers if Claude cannot run
+	health := opts.Health
+	if health == nil {
+		health = NewHealthCheck(r.config)
+	}
+	if health != nil {
+		if err := health.Check(ctx); err != nil {
+			return nil, fmt.Errorf("not starting the job: %w", err)
+		}
+	}
+
+	baseBranch := opts.Branch
+	if baseBranch == "" || opts.NewBranch {
+		baseBranch = projectConfig.DefaultBranch
+	}
+	baseSHA := r.remoteBaseSHA(ctx, projectConfig, baseBranch)
+	hash := InputHash(projectName, baseBranch, baseSHA, prompt)
+	if opts.PlanOnly {
+		hash = InputHash(projectName, baseBranch, baseSHA, "plan\x00"+prompt)
+	}
+
 	// Create job
 	job := New(projectName, prompt, r.config.JobsDir)
+	job.InputHash = hash
+
+	// Create job directories
+	if err := job.CreateDirectories(); err != nil {
+		return nil, fmt.Errorf("failed to create job directories: %w", err)
+	}
+
+	// Record the input as running before looking for duplicates, so that
+	// an identical job starting at the same time sees this one
+	if err := markRunning(job); err != nil {
+		return nil, fmt.Errorf("failed to mark job running: %w", err)
+	}
+	defer os.Remove(job.RunningFile())
+	defer os.Remove(job.CredentialsFile())
+	job.Start()
+	inputErr := writeInput(job, baseBranch)
+	duplicate, err := r.findDuplicate(job.input(baseBranch))
+	if err != nil {
+		os.RemoveAll(job.JobPath())
+		return nil, err
+	}
+	defer r.attachLogSinks(job, opts.LogSinks)()
+	if inputErr != nil {
+		r.logger.Manfred(fmt.Sprintf("Warning: failed to record job input: %v", inputErr))
+	}
 
 	r.logger.Manfred(fmt.Sprintf("Starting job %s", job.ID))
 	r.logger.Manfred(fmt.Sprintf("Project: %s", projectName))
@@ -78,41 +253,274 @@ func (r *Runner) Run(ctx context.Context, projectName, prompt string) (*Job, err
 		promptPreview = promptPreview[:60] + "..."
 	}
 	r.logger.Manfred(fmt.Sprintf("Prompt: %s", promptPreview))
+	if duplicate != nil {
+		job.DuplicateOf = duplicate.JobID
+		r.logger.Manfred(fmt.Sprintf("Warning: duplicate of job %s (%s, same project, prompt and base branch)", duplicate.JobID, duplicate.Status))
+	}
+	if projectConfig.Docker.Fallback {
+		r.logger.Manfred(fmt.Sprintf("No %s in the repository, running a single container from %s", projectConfig.Docker.ComposeFile, projectConfig.Docker.Image))
+	}
+	if projectConfig.Prompt != (config.PromptConfig{}) {
+		job.Prompt = projectConfig.Prompt.Wrap(job.Prompt)
+		r.logger.Manfred("Wrapped the prompt in the project's prompt prefix/suffix")
+	}
+	projectContext, err := r.config.ProjectContext(projectName, projectConfig)
+	if err != nil {
+		return nil, err
+	}
+	if projectContext != "" {
+		job.Context = projectContext
+		job.Prompt = projectContext + "\n\n" + job.Prompt
+		r.logger.Manfred(fmt.Sprintf("Prepended the project context (%d bytes)", len(projectContext)))
+	}
 
-	// Create job directories
-	if err := job.CreateDirectories(); err != nil {
-		return nil, fmt.Errorf("failed to create job directories: %w", err)
+	if opts.OnStart != nil {
+		opts.OnStart(job)
 	}
 
-	job.Start()
+	if opts.PlanOnly && (opts.Triage || r.config.PlanningMode(projectConfig) == config.PlanningAPI) {
+		err = r.executeAPIPlan(ctx, job, projectConfig, opts)
+	} else {
+		err = r.runInContainers(ctx, job, projectConfig, opts)
+	}
+
+	r.reportDroppedOutput(job)
+	if err != nil {
+		job.Fail(err.Error())
+		r.logger.Manfred(fmt.Sprintf("Job failed: %s", err))
+	} else {
+		job.Complete()
+		r.logger.Manfred("Job completed successfully")
+	}
+	if err := writeInput(job, baseBranch); err != nil {
+		r.logger.Manfred(fmt.Sprintf("Warning: failed to record job input: %v", err))
+	}
+	if err := writeResult(job); err != nil {
+		r.logger.Manfred(fmt.Sprintf("Warning: failed to record job result: %v", err))
+	}
+	r.notifyJob(ctx, job, opts.Notify)
+
+	return job, nil
+}
+
+// findDuplicate returns the running or completed job with the input of
+// self, as job.duplicates asks: nil when off, a *DuplicateError when skip.
+func (r *Runner) findDuplicate(self *Input) (*Input, error) {
+	if r.config.Job.Duplicates == config.DuplicatesOff {
+		return nil, nil
+	}
+	duplicate, err := FindDuplicate(r.config.JobsDir, self)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for duplicate jobs: %w", err)
+	}
+	if duplicate != nil && r.config.Job.Duplicates == config.DuplicatesSkip {
+		return nil, &DuplicateError{JobID: duplicate.JobID}
+	}
+	return duplicate, nil
+}
+
+// remoteBaseSHA returns the commit the base branch points to for the input
+// hash. It returns "" when duplicates are not checked, the project has no
+// single repository or the remote cannot be reached; the hash then only
+// covers the branch name.
+func (r *Runner) remoteBaseSHA(ctx context.Context, projectConfig *config.ProjectConfig, branch string) string {
+	if r.config.Job.Duplicates == config.DuplicatesOff || projectConfig.Repo == "" {
+		return ""
+	}
+	auth, err := r.gitAuth(ctx, projectConfig, projectConfig.Repo)
+	if err != nil {
+		return ""
+	}
+	sha, err := gitops.RemoteHead(ctx, projectConfig.Repo, branch, auth)
+	if err != nil {
+		return ""
+	}
+	return sha
+}
 
+// notifyJob sends the job_completed or job_failed notification of a
+// finished job.
+func (r *Runner) notifyJob(ctx context.Context, job *Job, n notify.Notification) {
+	n.Event = notify.EventJobCompleted
+	if job.Status == StatusFailed {
+		n.Event = notify.EventJobFailed
+		n.Error = job.Error
+	}
+	n.Project = job.ProjectName
+	n.JobID = job.ID
+	if err := r.notifier.Notify(context.WithoutCancel(ctx), n); err != nil {
+		r.logger.Manfred(fmt.Sprintf("Warning: %v", err))
+	}
+}
+
+// attachLogSinks adds the job log, its raw capture and the run's own sinks
+// to the logger and returns a function that flushes and removes them again.
+func (r *Runner) attachLogSinks(job *Job, extra []Sink) func() {
+	logging := r.config.Logging
+	sinks := extra
+	var raw Sink
+	if logging.JobLog {
+		if s, err := NewFileSink(job.LogFile(), logging.Format); err != nil {
+			r.logger.Manfred(fmt.Sprintf("Warning: no job log: %v", err))
+		} else {
+			sinks = append([]Sink{s}, sinks...)
+		}
+		if logging.Sanitize && logging.RawLog {
+			if s, err := NewFileSink(job.RawLogFile(), "text"); err != nil {
+				r.logger.Manfred(fmt.Sprintf("Warning: no raw job log: %v", err))
+			} else {
+				raw = s
+			}
+		}
+	}
+	for _, s := range sinks {
+		r.logger.AddSink(s)
+	}
+	if raw != nil {
+		r.logger.AddRawSink(raw)
+		sinks = append(sinks, raw)
+	}
+	return func() {
+		for _, s := range sinks {
+			r.logger.RemoveSink(s)
+		}
+	}
+}
+
+// runInContainers executes a job in the project's containers, collects its
+// artifacts and stops the containers. Only then, with nothing left that can
+// write to the workspace, is it sanitized for git on the host, which
+// finishes the job and saves its diff. A failed job whose containers are
+// kept gets no host git at all.
+func (r *Runner) runInContainers(ctx context.Context, job *Job, projectConfig *config.ProjectConfig, opts RunOptions) error {
 	// Compose project name
-	composeProjectName := fmt.Sprintf("manfred_%s", job.ID)
+	composeProjectName := ComposeProjectName(job.ID)
 	containerName := docker.ContainerName(composeProjectName, projectConfig.Docker.MainService)
 
-	// Determine compose file path
-	repoPath := r.config.ProjectRepositoryPath(projectName)
-	composeFile := filepath.Join(repoPath, projectConfig.Docker.ComposeFile)
+	// Determine compose file path; native projects have none
+	var composeFile string
+	if !projectConfig.Docker.Native() {
+		repoPath := r.config.ProjectRepositoryPath(job.ProjectName)
+		composeFile = filepath.Join(repoPath, projectConfig.Docker.ComposeFile)
+	}
 
 	// Execute job
-	err = r.executeJob(ctx, job, projectConfig, composeProjectName, containerName, composeFile)
+	err := r.executeJob(ctx, job, projectConfig, opts, composeProjectName, containerName, composeFile)
+	r.collectArtifacts(ctx, job, projectConfig, containerName)
 
-	// Cleanup
+	// Cleanup; a canceled job (e.g. an aborted session) is never kept and
+	// its containers are stopped even though ctx is done
+	if err != nil && r.config.Job.KeepContainers && ctx.Err() == nil {
+		r.keepEnvironment(job, composeProjectName, containerName, composeFile)
+		return err
+	}
 	r.logger.Docker("Stopping containers...")
-	if cleanupErr := r.docker.ComposeDown(ctx, composeFile, composeProjectName); cleanupErr != nil {
+	if cleanupErr := r.stopContainers(context.WithoutCancel(ctx), projectConfig, composeProjectName, containerName, composeFile); cleanupErr != nil {
 		r.logger.Docker(fmt.Sprintf("Warning: cleanup failed: %v", cleanupErr))
 	}
 	r.logger.Docker("Containers stopped")
 
+	// The containers are gone; make the workspace safe for host git
+	if sanitizeErr := r.sanitizeWorkspace(ctx, job); sanitizeErr != nil {
+		if err != nil {
+			r.logger.Manfred(fmt.Sprintf("Warning: %v", sanitizeErr))
+			return err
+		}
+		return sanitizeErr
+	}
+	if err == nil && !opts.PlanOnly {
+		err = r.finishJob(ctx, job, projectConfig, opts)
+	}
+	r.saveDiff(ctx, job)
+	return err
+}
+
+// ComposeProjectName returns the Docker Compose project name of a job.
+func ComposeProjectName(jobID string) string {
+	return ComposeProjectPrefix + jobID
+}
+
+// stopContainers removes a job's containers as far as cleanupLevel says. An
+// empty composeFile means the job ran a native container.
+func (r *Runner) stopContainers(ctx context.Context, projectConfig *config.ProjectConfig, composeProjectName, containerName, composeFile string) error {
+	level := r.cleanupLevel(projectConfig)
+	if composeFile == "" {
+		return r.docker.RemoveContainer(ctx, containerName, level != docker.CleanupContainers)
+	}
+	return r.docker.ComposeDown(ctx, composeFile, composeProjectName, level)
+}
+
+// cleanupLevel returns what compose down removes after a project's jobs:
+// docker.cleanup from project.yml, else job.cleanup.
+func (r *Runner) cleanupLevel(projectConfig *config.ProjectConfig) docker.CleanupLevel {
+	value := r.config.Job.Cleanup
+	if projectConfig != nil && projectConfig.Docker.Cleanup != "" {
+		value = projectConfig.Docker.Cleanup
+	}
+	level, err := docker.ParseCleanupLevel(value)
 	if err != nil {
-		job.Fail(err.Error())
-		r.logger.Manfred(fmt.Sprintf("Job failed: %s", err))
-	} else {
-		job.Complete()
-		r.logger.Manfred("Job completed successfully")
+		r.logger.Docker(fmt.Sprintf("Warning: %v, removing containers only", err))
+		return docker.CleanupContainers
 	}
+	return level
+}
 
-	return job, nil
+// portsMode returns what happens to the host ports of a project's compose
+// services: docker.ports from project.yml, else job.ports.
+func (r *Runner) portsMode(projectConfig *config.ProjectConfig) docker.PortsMode {
+	value := r.config.Job.Ports
+	if projectConfig.Docker.Ports != "" {
+		value = projectConfig.Docker.Ports
+	}
+	mode, err := docker.ParsePortsMode(value)
+	if err != nil {
+		r.logger.Docker(fmt.Sprintf("Warning: %v, publishing on ephemeral ports", err))
+		return docker.PortsEphemeral
+	}
+	return mode
+}
+
+// recordPorts stores the host ports the job's compose services published
+// on the job and logs them.
+func (r *Runner) recordPorts(ctx context.Context, job *Job, composeProjectName string) {
+	ports, err := r.docker.PublishedPorts(ctx, composeProjectName)
+	if err != nil {
+		r.logger.Docker(fmt.Sprintf("Warning: could not list published ports: %v", err))
+		return
+	}
+	job.Ports = ports
+	for _, port := range ports {
+		r.logger.Docker(fmt.Sprintf("Published %s", port))
+	}
+}
+
+// testConfig returns the project's test settings. Without test.command, and
+// unless detection is disabled by test.detect or job.detect_tests, the
+// command is guessed from the workspace and detected is true.
+func (r *Runner) testConfig(job *Job, projectConfig *config.ProjectConfig) (testConfig config.TestConfig, detected bool) {
+	testConfig = projectConfig.Test
+	if testConfig.Command != "" {
+		return testConfig, false
+	}
+	detect := r.config.Job.DetectTests
+	if testConfig.Detect != nil {
+		detect = *testConfig.Detect
+	}
+	if !detect {
+		return testConfig, false
+	}
+
+	dir := job.WorkspacePath()
+	if _, err := os.Stat(dir); err != nil {
+		dir = r.config.ProjectRepositoryPath(job.ProjectName)
+	}
+	commands := DetectTestCommands(dir)
+	if len(commands) == 0 {
+		return testConfig, false
+	}
+	testConfig.Command = JoinCommands(commands)
+	r.logger.Manfred(fmt.Sprintf("No test.command configured, auto-detected: %s", testConfig.Command))
+	return testConfig, true
 }
 
 func (r *Runner) validateProject(name string) (*config.ProjectConfig, error) {
@@ -125,94 +533,175 @@ func (r *Runner) validateProject(name string) (*config.ProjectConfig, error) {
 	if err != nil {
 		return nil, err
 	}
+	if _, err := r.config.ClaudeBundleDir(projectConfig.ClaudeVersion); err != nil {
+		return nil, err
+	}
 
+	// Native projects that clone need no local checkout
 	repoPath := r.config.ProjectRepositoryPath(name)
-	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
+	if _, err := os.Stat(repoPath); os.IsNotExist(err) && !(projectConfig.Docker.Native() && projectConfig.Clones()) {
 		return nil, fmt.Errorf("project repository not found: %s", repoPath)
 	}
+	if err := r.config.ApplyDefaultImage(name, projectConfig); err != nil {
+		return nil, err
+	}
 
 	return projectConfig, nil
 }
 
-func (r *Runner) executeJob(ctx context.Context, job *Job, projectConfig *config.ProjectConfig, composeProjectName, containerName, composeFile string) error {
+func (r *Runner) executeJob(ctx context.Context, job *Job, projectConfig *config.ProjectConfig, opts RunOptions, composeProjectName, containerName, composeFile string) error {
+	if err := r.runHooks(ctx, HookPreClone, job, projectConfig); err != nil {
+		return err
+	}
+
 	// Clone repository if configured
-	if projectConfig.Repo != "" {
-		if err := r.cloneRepository(ctx, job, projectConfig); err != nil {
+	if len(projectConfig.Repos) > 0 {
+		if err := r.cloneRepositories(ctx, job, projectConfig, opts); err != nil {
+			return err
+		}
+	} else if projectConfig.Repo != "" {
+		if err := r.cloneRepository(ctx, job, projectConfig, opts); err != nil {
 			return err
 		}
+	} else if opts.Branch != "" || opts.Push {
+		return fmt.Errorf("project %s has no repo configured", job.ProjectName)
 	}
 
-	// Prepare job directory with credentials and prompt
-	if err := r.prepareJobDirectory(job); err != nil {
-		return err
+	if opts.PlanOnly {
+		r.addCodeMap(ctx, job)
 	}
 
-	// Determine workdir
-	workdir := projectConfig.Docker.Workdir
-	if _, err := os.Stat(job.WorkspacePath()); err == nil {
-		workdir = filepath.Join(docker.ContainerJobPath, "workspace")
+	// Prepare job directory with credentials and prompt
+	if err := r.prepareJobDirectory(job, projectConfig); err != nil {
+		return err
 	}
 
-	// Start Docker compose
-	r.logger.Docker(fmt.Sprintf("Starting docker compose (project: %s)", composeProjectName))
+	workdir := jobWorkdir(job, projectConfig)
 
-	dockerOut := r.logger.Writer("DOCKER")
-	err := r.docker.ComposeUp(ctx, docker.ComposeOptions{
-		ComposeFile: composeFile,
-		ProjectName: composeProjectName,
-		Env: map[string]string{
-			"ANTHROPIC_API_KEY": r.config.Credentials.AnthropicAPIKey,
-		},
-		Volumes: []docker.VolumeMount{
-			{
-				Source:   job.JobPath(),
-				Target:   docker.ContainerJobPath,
-				ReadOnly: false,
-			},
-		},
-		Stdout: dockerOut,
-		Stderr: dockerOut,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to start compose: %w", err)
+	if err := r.startContainers(ctx, job, projectConfig, composeProjectName, containerName, composeFile); err != nil {
+		return err
 	}
 
 	// Wait for container
 	r.logger.Docker(fmt.Sprintf("Waiting for container %s to be ready...", containerName))
-	waitCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	waitCtx, cancel := context.WithTimeout(ctx, r.config.Limits.ContainerWait)
 	defer cancel()
 
-	if err := r.docker.WaitForContainer(waitCtx, containerName); err != nil {
+	if err := r.docker.WaitForContainer(waitCtx, containerName, r.config.Limits.ContainerPoll); err != nil {
 		// Try to get more info about what containers exist
 		r.logger.Docker("Container not ready, checking docker ps...")
-		r.docker.DebugContainers(ctx, composeProjectName, r.logger.Writer("DOCKER"))
+		debugOut := r.logger.Writer("DOCKER")
+		r.docker.DebugContainers(ctx, composeProjectName, debugOut)
+		debugOut.Close()
 		return fmt.Errorf("timeout waiting for container %s: %w", containerName, err)
 	}
 
+	r.logger.Docker(fmt.Sprintf("Container %s started", containerName))
+	stopMonitor := r.monitorResources(ctx, job, containerName)
+	defer stopMonitor()
+
+	env := r.execEnv(projectConfig)
+	clockEnv, err := r.clockExecEnv(ctx, containerName, projectConfig)
+	if err != nil {
+		return err
+	}
+	for name, value := range clockEnv {
+		env[name] = value
+	}
+	gitEnv, err := r.setupContainerGitAuth(ctx, job, projectConfig)
+	if err != nil {
+		return err
+	}
+	for name, value := range gitEnv {
+		env[name] = value
+	}
+
+	// Run Claude and the tests as docker.user, after the job directory is
+	// complete so it can be handed over
+	if user := projectConfig.Docker.User; user != "" {
+		home, err := r.setupExecUser(ctx, containerName, user)
+		if err != nil {
+			return err
+		}
+		if home != "" {
+			env["HOME"] = home
+		}
+		job.execUser = user
+	}
+
 	// Setup credential symlinks
-	if err := r.docker.SetupCredentialSymlinks(ctx, containerName); err != nil {
+	if err := r.docker.SetupCredentialSymlinks(ctx, containerName, job.execUser, env["HOME"]); err != nil {
 		r.logger.Docker(fmt.Sprintf("Warning: failed to setup credentials: %v", err))
 	}
 
-	r.logger.Docker(fmt.Sprintf("Container %s started", containerName))
+	if opts.ContinueJob != "" {
+		if err := r.restoreConversation(ctx, job, containerName, env, opts.ContinueJob); err != nil {
+			return err
+		}
+	}
 
 	// Phase 1: Run main task
 	r.logger.Manfred("Executing Claude Code with prompt...")
-	if err := r.execClaude(ctx, containerName, workdir, job.Prompt, false); err != nil {
-		return fmt.Errorf("claude execution failed: %w", err)
+	if err := r.execClaude(ctx, job, containerName, workdir, env, job.Prompt, opts.ContinueJob != ""); err != nil {
+		return err
+	}
+	r.checkOutputs(job, "phase 1")
+	if opts.SaveConversation {
+		r.saveConversation(ctx, job, containerName, env)
+	}
+
+	if opts.PlanOnly {
+		if err := r.readPlan(job); err != nil {
+			return err
+		}
+		return r.runHooks(ctx, HookPostClaude, job, projectConfig)
+	}
+
+	// Run the project's test suite, letting Claude fix failures
+	if testConfig, detected := r.testConfig(job, projectConfig); testConfig.Command != "" {
+		r.runTests(ctx, job, testConfig, containerName, workdir, env)
+		job.TestResult.Detected = detected
+	}
+
+	if err := r.runHooks(ctx, HookPostClaude, job, projectConfig); err != nil {
+		return err
 	}
 
 	// Phase 2: Get commit message
 	r.logger.Manfred("Phase 1 complete, requesting commit message...")
 	r.logger.Manfred("Requesting commit message from Claude...")
-	if err := r.execClaude(ctx, containerName, workdir, CommitMessagePrompt, true); err != nil {
+	if err := r.execClaude(ctx, job, containerName, workdir, env, CommitMessagePrompt, true); err != nil {
 		r.logger.Manfred(fmt.Sprintf("Warning: failed to get commit message: %v", err))
 	} else {
+		r.checkOutputs(job, "phase 2")
 		r.readCommitMessage(job)
 	}
+	return nil
+}
 
+// finishJob verifies, commits and pushes the job's work with git on the
+// host. It runs after the containers stopped and sanitizeWorkspace checked
+// the workspace.
+func (r *Runner) finishJob(ctx context.Context, job *Job, projectConfig *config.ProjectConfig, opts RunOptions) error {
 	// Verify git state
-	r.verifyGitState(job)
+	if len(job.Repos) > 0 {
+		r.verifyRepositories(ctx, job)
+	} else {
+		r.verifyGitState(ctx, job)
+	}
+
+	if err := r.runHooks(ctx, HookPreFinalize, job, projectConfig); err != nil {
+		return err
+	}
+
+	// Every changed repository of a multi-repository project is pushed
+	if len(job.Repos) > 0 {
+		return r.finalizeRepositories(ctx, job, projectConfig, opts.PullRequests)
+	}
+
+	if opts.Push {
+		return r.pushBranch(ctx, job, projectConfig)
+	}
 
 	// Finalize
 	r.finalizeCommit(job)
@@ -220,52 +709,176 @@ func (r *Runner) executeJob(ctx context.Context, job *Job, projectConfig *config
 	return nil
 }
 
-func (r *Runner) cloneRepository(ctx context.Context, job *Job, projectConfig *config.ProjectConfig) error {
+// jobWorkdir returns the container directory Claude and the tests run in:
+// the cloned workspace when there is one, the project's workdir otherwise.
+func jobWorkdir(job *Job, projectConfig *config.ProjectConfig) string {
+	if _, err := os.Stat(job.WorkspacePath()); err == nil {
+		return filepath.Join(docker.ContainerJobPath, "workspace")
+	}
+	return projectConfig.Docker.Workdir
+}
+
+// startContainers starts the job's compose project or, for native projects,
+// a single container from docker.image. The job directory is mounted at
+// /manfred-job; a native container also gets the project repository at the
+// workdir when nothing was cloned, like the usual `.:/app` compose volume.
+func (r *Runner) startContainers(ctx context.Context, job *Job, projectConfig *config.ProjectConfig, composeProjectName, containerName, composeFile string) error {
+	dockerOut := r.logger.Writer("DOCKER")
+	defer dockerOut.Close()
+	limits := resourceLimits(projectConfig.Docker.Resources)
+	volumes := []docker.VolumeMount{
+		{
+			Source:   job.JobPath(),
+			Target:   docker.ContainerJobPath,
+			ReadOnly: false,
+		},
+	}
+	if !r.config.Claude.CopyBundle {
+		bundleDir, err := r.config.ClaudeBundleDir(projectConfig.ClaudeVersion)
+		if err != nil {
+			return err
+		}
+		r.logger.Docker(fmt.Sprintf("Mounting Claude bundle %s read-only", bundleDir))
+		volumes = append(volumes, docker.VolumeMount{
+			Source:   bundleDir,
+			Target:   docker.ContainerBundlePath,
+			ReadOnly: true,
+		})
+	}
+
+	versions := r.docker.Versions(ctx)
+	if err := versions.CheckDocker(); err != nil {
+		return err
+	}
+
+	if composeFile != "" {
+		if err := versions.CheckCompose(); err != nil {
+			return err
+		}
+		ports := r.portsMode(projectConfig)
+		if ports != docker.PortsKeep && !versions.SupportsReset() {
+			r.logger.Docker(fmt.Sprintf("Warning: compose %s cannot replace ports (needs %s), publishing them as the compose file says", versions.Compose, docker.MinComposeResetVersion))
+			ports = docker.PortsKeep
+		}
+		r.logger.Docker(fmt.Sprintf("Starting docker compose (project: %s)", composeProjectName))
+		err := r.docker.ComposeUp(ctx, docker.ComposeOptions{
+			ComposeFile: composeFile,
+			ProjectName: composeProjectName,
+			Env: map[string]string{
+				"ANTHROPIC_API_KEY": r.anthropicAPIKey(),
+			},
+			Volumes:  volumes,
+			Services: append([]string{projectConfig.Docker.MainService}, projectConfig.Docker.ExtraServices...),
+			Limits:   limits,
+			Ports:    ports,
+			Stdout:   dockerOut,
+			Stderr:   dockerOut,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start compose: %w", err)
+		}
+		r.recordPorts(ctx, job, composeProjectName)
+		return nil
+	}
+
+	if _, err := os.Stat(job.WorkspacePath()); err != nil {
+		volumes = append(volumes, docker.VolumeMount{
+			Source: r.config.ProjectRepositoryPath(job.ProjectName),
+			Target: projectConfig.Docker.Workdir,
+		})
+	}
+	env := map[string]string{}
+	if key := r.anthropicAPIKey(); key != "" {
+		env["ANTHROPIC_API_KEY"] = key
+	}
+
+	r.logger.Docker(fmt.Sprintf("Starting container from %s (project: %s)", projectConfig.Docker.Image, composeProjectName))
+	err := r.docker.RunContainer(ctx, docker.ContainerOptions{
+		Name:    containerName,
+		Image:   projectConfig.Docker.Image,
+		Project: composeProjectName,
+		Service: projectConfig.Docker.MainService,
+		Workdir: jobWorkdir(job, projectConfig),
+		Env:     env,
+		Volumes: volumes,
+		Limits:  limits,
+		Stdout:  dockerOut,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+	return nil
+}
+
+// resourceLimits converts docker.resources from project.yml, which
+// ProjectConfig has validated.
+func resourceLimits(resources config.ResourceConfig) docker.ResourceLimits {
+	memory, _ := resources.MemoryBytes()
+	return docker.ResourceLimits{
+		CPUs:   resources.CPUs,
+		Memory: memory,
+		Pids:   resources.Pids,
+	}
+}
+
+func (r *Runner) cloneRepository(ctx context.Context, job *Job, projectConfig *config.ProjectConfig, opts RunOptions) error {
 	r.logger.Docker(fmt.Sprintf("Cloning repository: %s", projectConfig.Repo))
 
-	branchName := fmt.Sprintf("manfred/%s", job.ID)
+	existingBranch := ""
+	if !opts.NewBranch {
+		existingBranch = opts.Branch
+	}
 
-	// Clone with full history
-	cmd := exec.CommandContext(ctx, "git", "clone", projectConfig.Repo, job.WorkspacePath())
-	if err := cmd.Run(); err != nil {
+	branchName := fmt.Sprintf("manfred/%s", job.ID)
+	if opts.Branch != "" {
+		branchName = opts.Branch
+	}
+	cloneOpts, err := r.cloneOptions(ctx, job.ProjectName, projectConfig, projectConfig.Repo)
+	if err != nil {
+		return err
+	}
+	cloneOpts.Branch = existingBranch
+	if opts.CloneDepth > 0 {
+		cloneOpts.Depth = opts.CloneDepth
+	}
+	repo, err := gitops.Clone(ctx, projectConfig.Repo, job.WorkspacePath(), cloneOpts)
+	if err != nil {
 		return fmt.Errorf("failed to clone repository: %w", err)
 	}
+	job.repo = repo
 
-	// Create feature branch
-	r.logger.Docker(fmt.Sprintf("Creating branch: %s", branchName))
-	cmd = exec.CommandContext(ctx, "git", "-C", job.WorkspacePath(), "checkout", "-b", branchName)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to create branch: %w", err)
+	if existingBranch != "" {
+		r.logger.Docker(fmt.Sprintf("Using existing branch: %s", branchName))
+	} else {
+		// Create feature branch
+		r.logger.Docker(fmt.Sprintf("Creating branch: %s", branchName))
+		if err := repo.CreateBranch(ctx, branchName); err != nil {
+			return fmt.Errorf("failed to create branch: %w", err)
+		}
 	}
 
 	// Record base SHA
-	cmd = exec.CommandContext(ctx, "git", "-C", job.WorkspacePath(), "rev-parse", "HEAD")
-	output, err := cmd.Output()
+	job.BaseSHA, err = repo.RevParse(ctx, "HEAD")
 	if err != nil {
 		return fmt.Errorf("failed to get base SHA: %w", err)
 	}
-
-	job.BaseSHA = strings.TrimSpace(string(output))
 	job.BranchName = branchName
 
 	r.logger.Docker(fmt.Sprintf("Repository cloned, base SHA: %s", job.BaseSHA))
 	return nil
 }
 
-func (r *Runner) prepareJobDirectory(job *Job) error {
+func (r *Runner) prepareJobDirectory(job *Job, projectConfig *config.ProjectConfig) error {
 	r.logger.Docker("Preparing job directory...")
 
-	// Copy credentials if they exist
-	if r.config.ClaudeCredentialsExist() {
-		src := r.config.Credentials.ClaudeCredentialsFile
-		dst := job.CredentialsFile()
-
-		data, err := os.ReadFile(src)
-		if err != nil {
-			return fmt.Errorf("failed to read credentials: %w", err)
-		}
-
-		if err := os.WriteFile(dst, data, 0600); err != nil {
+	// Copy credentials if they exist; RunWithOptions removes them when the
+	// job ends
+	data, err := r.config.ClaudeCredentials()
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		if err := os.WriteFile(job.CredentialsFile(), data, 0600); err != nil {
 			return fmt.Errorf("failed to write credentials: %w", err)
 		}
 
@@ -278,28 +891,35 @@ func (r *Runner) prepareJobDirectory(job *Job) error {
 	if err := os.WriteFile(job.PromptFile(), []byte(job.Prompt), 0644); err != nil {
 		return fmt.Errorf("failed to write prompt: %w", err)
 	}
+	if job.Context != "" {
+		if err := os.WriteFile(job.ContextFile(), []byte(job.Context), 0644); err != nil {
+			return fmt.Errorf("failed to write project context: %w", err)
+		}
+	}
+
+	// Install the output helper
+	if err := installOutputHelper(job); err != nil {
+		return fmt.Errorf("failed to install output helper: %w", err)
+	}
 
-	// Copy Claude bundle
-	if err := r.copyClaudeBundle(job); err != nil {
-		return fmt.Errorf("failed to copy Claude bundle: %w", err)
+	// Copy Claude bundle, unless startContainers mounts it
+	if r.config.Claude.CopyBundle {
+		if err := r.copyClaudeBundle(job, projectConfig); err != nil {
+			return fmt.Errorf("failed to copy Claude bundle: %w", err)
+		}
 	}
 
 	return nil
 }
 
-func (r *Runner) copyClaudeBundle(job *Job) error {
-	bundleSrc := r.config.Claude.BundlePath
-	bundleDst := job.ClaudeBundlePath()
-
-	// Check if bundle source exists
-	info, err := os.Stat(bundleSrc)
+// copyClaudeBundle copies the Claude bundle into the job directory, for
+// claude.copy_bundle setups where startContainers does not mount it.
+func (r *Runner) copyClaudeBundle(job *Job, projectConfig *config.ProjectConfig) error {
+	bundleSrc, err := r.config.ClaudeBundleDir(projectConfig.ClaudeVersion)
 	if err != nil {
-		return fmt.Errorf("Claude bundle not found at %s: %w", bundleSrc, err)
-	}
-
-	if !info.IsDir() {
-		return fmt.Errorf("Claude bundle path is not a directory: %s", bundleSrc)
+		return err
 	}
+	bundleDst := job.ClaudeBundlePath()
 
 	r.logger.Docker(fmt.Sprintf("Copying Claude bundle from %s", bundleSrc))
 
@@ -346,9 +966,10 @@ func copyDir(src, dst string) error {
 	return nil
 }
 
-// copyFile copies a single file, preserving permissions.
+// copyFile copies a single file, preserving permissions. Symlinks are
+// copied as links, not followed.
 func copyFile(src, dst string) error {
-	srcInfo, err := os.Stat(src)
+	srcInfo, err := os.Lstat(src)
 	if err != nil {
 		return err
 	}
@@ -378,36 +999,59 @@ func copyFile(src, dst string) error {
 	return err
 }
 
-func (r *Runner) execClaude(ctx context.Context, container, workdir, prompt string, continueSession bool) error {
-	// Use the bundled Claude binary from the job directory
-	claudeBin := filepath.Join(docker.ContainerJobPath, "claude-bundle", "claude")
+// execClaude runs Claude in the container. Failures are classified: "could
+// not run claude" wraps a Docker error, "claude failed" a *docker.ExitError
+// carrying Claude's exit code and the (redacted) tail of its stderr.
+func (r *Runner) execClaude(ctx context.Context, job *Job, container, workdir string, env map[string]string, prompt string, continueSession bool) error {
+	// Use the Claude binary of the bundle mounted by startContainers or
+	// copied by prepareJobDirectory
+	claudeBin := filepath.Join(docker.ContainerBundlePath, bundle.Binary)
+	if r.config.Claude.CopyBundle {
+		claudeBin = filepath.Join(docker.ContainerJobPath, "claude-bundle", bundle.Binary)
+	}
 
 	args := []string{claudeBin, "--dangerously-skip-permissions"}
 	if continueSession {
 		args = append(args, "--continue")
 	}
+	// Keeps the project context in view in continued sessions, e.g. when
+	// fixing tests
+	if job.Context != "" {
+		contextFile := filepath.Join(docker.ContainerJobPath, filepath.Base(job.ContextFile()))
+		args = append(args, "--append-system-prompt", fmt.Sprintf(ContextSystemPrompt, contextFile))
+	}
 	args = append(args, "-p", prompt)
 
-	return r.docker.Exec(ctx, container, args, docker.ExecOptions{
+	stdout, stderr := r.logger.Writer("CLAUDE"), r.logger.Writer("CLAUDE")
+	result, err := r.exec.Exec(ctx, container, args, docker.ExecOptions{
+		User:    job.execUser,
 		Workdir: workdir,
-		Env: map[string]string{
-			"ANTHROPIC_API_KEY": r.config.Credentials.AnthropicAPIKey,
-			"IS_SANDBOX":        "1",
-		},
-		Stdout: r.logger.Writer("CLAUDE"),
-		Stderr: r.logger.Writer("CLAUDE"),
+		Env:     env,
+		Stdout:  r.outputs.limit("CLAUDE stdout", stdout),
+		Stderr:  r.outputs.limit("CLAUDE stderr", stderr),
 	})
+	// Claude's last line may lack a newline
+	stdout.Close()
+	stderr.Close()
+	if err != nil {
+		return fmt.Errorf("could not run claude: %w", err)
+	}
+
+	r.logger.Manfred(fmt.Sprintf("Claude exited with code %d after %s", result.ExitCode, result.Duration.Round(time.Second)))
+	result.Stderr = r.logger.Redact(result.Stderr)
+	if err := result.Err(); err != nil {
+		return fmt.Errorf("claude failed: %w", err)
+	}
+	return nil
 }
 
 func (r *Runner) readCommitMessage(job *Job) {
 	path := job.CommitMessageFile()
-	data, err := os.ReadFile(path)
+	content, err := r.readTextOutput(job, OutputCommitMessage, path)
 	if err != nil {
 		r.logger.Manfred(fmt.Sprintf("Warning: could not read commit message: %v", err))
 		return
 	}
-
-	content := strings.TrimSpace(string(data))
 	if content == "" {
 		r.logger.Manfred("Warning: commit message file is empty")
 		return
@@ -417,7 +1061,21 @@ func (r *Runner) readCommitMessage(job *Job) {
 	r.logger.Manfred("Commit message received")
 }
 
-func (r *Runner) verifyGitState(job *Job) {
+func (r *Runner) readPlan(job *Job) error {
+	content, err := r.readTextOutput(job, OutputPlan, job.PlanFile())
+	if err != nil {
+		return fmt.Errorf("could not read plan: %w", err)
+	}
+	if content == "" {
+		return fmt.Errorf("plan file is empty")
+	}
+
+	job.Plan = content
+	r.logger.Manfred("Plan received")
+	return nil
+}
+
+func (r *Runner) verifyGitState(ctx context.Context, job *Job) {
 	if job.WorkspacePath() == "" {
 		return
 	}
@@ -429,52 +1087,85 @@ func (r *Runner) verifyGitState(job *Job) {
 
 	r.logger.Manfred("Verifying git state...")
 
+	repo := job.gitRepo()
+
 	// Check current branch
-	cmd := exec.Command("git", "-C", workspace, "branch", "--show-current")
-	output, err := cmd.Output()
-	if err == nil {
-		currentBranch := strings.TrimSpace(string(output))
+	if currentBranch, err := repo.CurrentBranch(ctx); err == nil {
 		if job.BranchName != "" && currentBranch != job.BranchName {
 			r.logger.Manfred(fmt.Sprintf("WARNING: Branch changed from %s to %s", job.BranchName, currentBranch))
 		}
 	}
 
 	// Check for uncommitted changes
-	cmd = exec.Command("git", "-C", workspace, "status", "--porcelain")
-	output, err = cmd.Output()
-	if err == nil {
-		status := strings.TrimSpace(string(output))
-		if status != "" {
-			r.logger.Manfred("WARNING: Uncommitted changes remain:")
-			lines := strings.Split(status, "\n")
-			for i, line := range lines {
-				if i >= 5 {
-					r.logger.Manfred("  ...")
-					break
-				}
-				r.logger.Manfred(fmt.Sprintf("  %s", line))
+	if status, err := repo.Status(ctx); err == nil && len(status) > 0 {
+		r.logger.Manfred("WARNING: Uncommitted changes remain:")
+		for i, line := range status {
+			if i >= 5 {
+				r.logger.Manfred("  ...")
+				break
 			}
+			r.logger.Manfred(fmt.Sprintf("  %s", line))
 		}
 	}
 
 	// Log commits made
 	if job.BaseSHA != "" {
-		cmd = exec.Command("git", "-C", workspace, "log", fmt.Sprintf("%s..HEAD", job.BaseSHA), "--oneline")
-		output, err = cmd.Output()
-		if err == nil {
-			commits := strings.TrimSpace(string(output))
-			if commits == "" {
-				r.logger.Manfred("No commits made by Claude")
-			} else {
-				r.logger.Manfred("Commits on branch:")
-				for _, line := range strings.Split(commits, "\n") {
-					r.logger.Manfred(fmt.Sprintf("  %s", line))
-				}
+		commits, err := repo.CommitsSince(ctx, job.BaseSHA)
+		switch {
+		case err != nil:
+			r.logger.Manfred(fmt.Sprintf("Warning: could not list commits: %v", err))
+		case len(commits) == 0:
+			r.logger.Manfred("No commits made by Claude")
+		default:
+			r.logger.Manfred("Commits on branch:")
+			for _, line := range commits {
+				r.logger.Manfred(fmt.Sprintf("  %s", line))
+			}
+			if stat, err := repo.DiffStat(ctx, job.BaseSHA); err == nil {
+				r.logger.Manfred(fmt.Sprintf("%d file(s) changed, %d insertion(s), %d deletion(s)", stat.FilesChanged, stat.Insertions, stat.Deletions))
 			}
 		}
 	}
 }
 
+// cloneOptions combines the job.clone_* settings with the project's clone
+// overrides. With the clone cache enabled the project mirror is updated
+// first; if that fails the job clones from the network.
+func (r *Runner) cloneOptions(ctx context.Context, projectName string, projectConfig *config.ProjectConfig, url string) (gitops.CloneOptions, error) {
+	auth, err := r.gitAuth(ctx, projectConfig, url)
+	if err != nil {
+		return gitops.CloneOptions{}, err
+	}
+	opts := gitops.CloneOptions{
+		Depth:  r.config.Job.CloneDepth,
+		Filter: r.config.Job.CloneFilter,
+		Auth:   auth,
+	}
+	if projectConfig.Clone.Depth > 0 {
+		opts.Depth = projectConfig.Clone.Depth
+	}
+	if projectConfig.Clone.Filter != "" {
+		opts.Filter = projectConfig.Clone.Filter
+	}
+
+	useCache := r.config.Job.CloneCache
+	if projectConfig.Clone.Cache != nil {
+		useCache = *projectConfig.Clone.Cache
+	}
+	if !useCache || projectConfig.Repo == "" {
+		return opts, nil
+	}
+
+	mirror := r.config.ProjectCachePath(projectName)
+	r.logger.Docker(fmt.Sprintf("Updating clone cache: %s", mirror))
+	if err := gitops.UpdateMirror(ctx, projectConfig.Repo, mirror, opts.Auth); err != nil {
+		r.logger.Docker(fmt.Sprintf("Warning: clone cache update failed, cloning from remote: %v", err))
+		return opts, nil
+	}
+	opts.Mirror = mirror
+	return opts, nil
+}
+
 func (r *Runner) finalizeCommit(job *Job) {
 	r.logger.Separator()
 	r.logger.Manfred("FINALIZE (dummy): Would commit with message:")
@@ -491,6 +1182,17 @@ func (r *Runner) finalizeCommit(job *Job) {
 	r.logger.Blank()
 	r.logger.Separator()
 
+	if job.TestResult != nil {
+		if job.TestResult.Passed {
+			r.logger.Manfred(fmt.Sprintf("Tests: passed (%d run(s), %d fix attempt(s))", job.TestResult.Attempts, job.TestResult.FixAttempts))
+		} else {
+			r.logger.Manfred(fmt.Sprintf("Tests: FAILED (%d run(s), %d fix attempt(s))", job.TestResult.Attempts, job.TestResult.FixAttempts))
+		}
+		if job.TestResult.Detected {
+			r.logger.Manfred(fmt.Sprintf("  Auto-detected command: %s (pin it with test.command in project.yml)", job.TestResult.Command))
+		}
+	}
+
 	r.logger.Manfred("In production, this would:")
 	if job.BranchName != "" {
 		r.logger.Manfred(fmt.Sprintf("  1. Push to branch: %s", job.BranchName))
@@ -500,7 +1202,16 @@ func (r *Runner) finalizeCommit(job *Job) {
 	r.logger.Manfred("  2. Open a Pull Request")
 }
 
-// Close releases resources.
+// SetOutput sends the job log to w instead of stdout.
+func (r *Runner) SetOutput(w io.Writer) {
+	r.logger.SetOutput(w)
+}
+
+// Close releases resources, waiting for notifications still being sent.
 func (r *Runner) Close() error {
+	if r.ownsNotifier {
+		r.notifier.Close()
+	}
+	r.logger.Close()
 	return r.docker.Close()
 }
@@ -0,0 +1,58 @@
+package job
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mpm/manfred/internal/config"
+)
+
+func TestCopyClaudeBundle(t *testing.T) {
+	bundles := t.TempDir()
+	src := filepath.Join(bundles, "1.0.0")
+	if err := os.MkdirAll(filepath.Join(src, "lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(src, "claude"), []byte("#!/bin/sh\n"), 0755)
+	os.WriteFile(filepath.Join(src, "lib", "cli.js"), []byte("console.log(1)"), 0644)
+	os.Symlink("claude", filepath.Join(src, "claude-latest"))
+	os.Symlink("lib", filepath.Join(src, "vendor"))
+
+	cfg := &config.Config{JobsDir: t.TempDir()}
+	cfg.Claude.Version = "0.9.0"
+	cfg.Claude.BundlesDir = bundles
+	job := New("proj", "do things", cfg.JobsDir)
+	if err := job.CreateDirectories(); err != nil {
+		t.Fatal(err)
+	}
+	r := &Runner{config: cfg, logger: NewLogger(NewTextSink(&bytes.Buffer{}))}
+
+	// claude.version is not installed; the project's pin is
+	if err := r.copyClaudeBundle(job, &config.ProjectConfig{}); err == nil || !strings.Contains(err.Error(), "0.9.0 is not installed") {
+		t.Errorf("copyClaudeBundle() without a pin error = %v, want 0.9.0 not installed", err)
+	}
+	if err := r.copyClaudeBundle(job, &config.ProjectConfig{ClaudeVersion: "1.0.0"}); err != nil {
+		t.Fatalf("copyClaudeBundle() error = %v", err)
+	}
+
+	dst := job.ClaudeBundlePath()
+	info, err := os.Stat(filepath.Join(dst, "claude"))
+	if err != nil {
+		t.Fatalf("stat claude: %v", err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("claude mode = %v, want 0755", info.Mode().Perm())
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "lib", "cli.js")); err != nil || string(data) != "console.log(1)" {
+		t.Errorf("lib/cli.js = %q, %v", data, err)
+	}
+	// Links are copied as links, relative to the copy
+	for link, target := range map[string]string{"claude-latest": "claude", "vendor": "lib"} {
+		if got, err := os.Readlink(filepath.Join(dst, link)); err != nil || got != target {
+			t.Errorf("Readlink(%s) = %q, %v, want %q", link, got, err, target)
+		}
+	}
+}
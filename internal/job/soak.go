@@ -0,0 +1,149 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultSoakPrompt is the known-good job a soak test repeats unless it is
+// given a prompt of its own.
+const DefaultSoakPrompt = `Create a file named MANFRED_SOAK.md in the repository root containing the
+single line "ok". Do not change anything else.`
+
+// Failure classes of soak runs, from the environment up to Claude's work.
+const (
+	FailureCompose = "compose" // Containers did not start
+	FailureTimeout = "timeout" // A wait or deadline ran out
+	FailureClone   = "clone"   // Cloning or preparing the repository failed
+	FailureClaude  = "claude"  // Claude could not run or failed
+	FailureTests   = "tests"   // The job finished but its tests failed
+	FailureOther   = "other"
+)
+
+// ClassifyFailure returns the failure class of a job that did not pass, by
+// its error message. A completed job fails with FailureTests when its tests
+// failed.
+func ClassifyFailure(job *Job) string {
+	if job.Status == StatusCompleted {
+		return FailureTests
+	}
+	return classifyError(job.Error)
+}
+
+// classifyError returns the failure class of an error message.
+func classifyError(msg string) string {
+	msg = strings.ToLower(msg)
+	switch {
+	case strings.Contains(msg, "timeout"), strings.Contains(msg, "timed out"), strings.Contains(msg, "deadline exceeded"):
+		return FailureTimeout
+	case strings.Contains(msg, "compose"), strings.Contains(msg, "failed to start container"):
+		return FailureCompose
+	case strings.Contains(msg, "clone"), strings.Contains(msg, "branch"), strings.Contains(msg, "base sha"):
+		return FailureClone
+	case strings.Contains(msg, "claude"):
+		return FailureClaude
+	default:
+		return FailureOther
+	}
+}
+
+// SoakRun is one iteration of a soak test.
+type SoakRun struct {
+	Iteration int
+	JobID     string // Empty if the job could not be started
+	Passed    bool
+	Failure   string // Failure class, see ClassifyFailure
+	Error     string
+	Duration  time.Duration
+}
+
+// SoakReport collects the runs of a soak test.
+type SoakReport struct {
+	Project string
+	Runs    []SoakRun
+}
+
+// Passed returns the number of passed runs.
+func (r *SoakReport) Passed() int {
+	passed := 0
+	for _, run := range r.Runs {
+		if run.Passed {
+			passed++
+		}
+	}
+	return passed
+}
+
+// Score returns the stability score: the percentage of passed runs.
+func (r *SoakReport) Score() float64 {
+	if len(r.Runs) == 0 {
+		return 0
+	}
+	return 100 * float64(r.Passed()) / float64(len(r.Runs))
+}
+
+// Failures counts the failed runs by failure class.
+func (r *SoakReport) Failures() map[string]int {
+	failures := map[string]int{}
+	for _, run := range r.Runs {
+		if !run.Passed {
+			failures[run.Failure]++
+		}
+	}
+	return failures
+}
+
+// Durations returns the shortest, average and longest run durations.
+func (r *SoakReport) Durations() (shortest, average, longest time.Duration) {
+	if len(r.Runs) == 0 {
+		return 0, 0, 0
+	}
+	var total time.Duration
+	shortest = r.Runs[0].Duration
+	for _, run := range r.Runs {
+		total += run.Duration
+		shortest = min(shortest, run.Duration)
+		longest = max(longest, run.Duration)
+	}
+	return shortest, total / time.Duration(len(r.Runs)), longest
+}
+
+// Soak runs prompt as a job for a project iterations times in a row to
+// measure how reliably the project's environment runs a job. A run passes
+// when its job completes with passing (or no) tests. onRun, if set, is
+// called after each run. Soak stops early when ctx is done.
+func (r *Runner) Soak(ctx context.Context, projectName, prompt string, iterations int, onRun func(SoakRun)) (*SoakReport, error) {
+	if _, err := r.validateProject(projectName); err != nil {
+		return nil, err
+	}
+
+	report := &SoakReport{Project: projectName}
+	for i := 1; i <= iterations && ctx.Err() == nil; i++ {
+		start := time.Now()
+		j, err := r.RunWithOptions(ctx, projectName, prompt, RunOptions{})
+		run := SoakRun{Iteration: i, Duration: time.Since(start)}
+		var duplicate *DuplicateError
+		switch {
+		case errors.As(err, &duplicate):
+			return report, fmt.Errorf("soak runs must not be skipped as duplicates: %w", err)
+		case err != nil:
+			run.Failure = classifyError(err.Error())
+			run.Error = err.Error()
+		default:
+			run.JobID = j.ID
+			run.Passed = j.Status == StatusCompleted && (j.TestResult == nil || j.TestResult.Passed)
+			if !run.Passed {
+				run.Failure = ClassifyFailure(j)
+				run.Error = j.Error
+			}
+		}
+		report.Runs = append(report.Runs, run)
+		if onRun != nil {
+			onRun(run)
+		}
+	}
+	return report, ctx.Err()
+}
@@ -0,0 +1,48 @@
+package job
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		job  *Job
+		want string
+	}{
+		{&Job{Status: StatusFailed, Error: "failed to start compose: exit status 1"}, FailureCompose},
+		{&Job{Status: StatusFailed, Error: "timeout waiting for container web: context deadline exceeded"}, FailureTimeout},
+		{&Job{Status: StatusFailed, Error: "failed to clone repository: exit status 128"}, FailureClone},
+		{&Job{Status: StatusFailed, Error: "claude failed: exit status 1"}, FailureClaude},
+		{&Job{Status: StatusFailed, Error: "disk full"}, FailureOther},
+		{&Job{Status: StatusCompleted, TestResult: &TestResult{Passed: false}}, FailureTests},
+	}
+	for _, tt := range tests {
+		if got := ClassifyFailure(tt.job); got != tt.want {
+			t.Errorf("ClassifyFailure(%q) = %s, want %s", tt.job.Error, got, tt.want)
+		}
+	}
+}
+
+func TestSoakReport(t *testing.T) {
+	report := &SoakReport{Runs: []SoakRun{
+		{Iteration: 1, Passed: true, Duration: 2 * time.Minute},
+		{Iteration: 2, Failure: FailureCompose, Duration: time.Minute},
+		{Iteration: 3, Passed: true, Duration: 3 * time.Minute},
+		{Iteration: 4, Passed: true, Duration: 2 * time.Minute},
+	}}
+
+	if got := report.Score(); got != 75 {
+		t.Errorf("Score() = %v, want 75", got)
+	}
+	if failures := report.Failures(); len(failures) != 1 || failures[FailureCompose] != 1 {
+		t.Errorf("Failures() = %v, want compose 1", failures)
+	}
+	shortest, average, longest := report.Durations()
+	if shortest != time.Minute || average != 2*time.Minute || longest != 3*time.Minute {
+		t.Errorf("Durations() = %s, %s, %s; want 1m, 2m, 3m", shortest, average, longest)
+	}
+	if (&SoakReport{}).Score() != 0 {
+		t.Error("Score() of an empty report is not 0")
+	}
+}
@@ -0,0 +1,108 @@
+package job
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/docker"
+)
+
+const (
+	// maxTestFeedbackBytes limits how much test output is sent back to Claude.
+	maxTestFeedbackBytes = 8000
+
+	// TestFixPrompt is the prompt used to ask Claude to fix failing tests.
+	// The %s placeholders are the test command and its (truncated) output.
+	TestFixPrompt = `The project's test suite failed after your changes.
+
+Test command:
+%s
+
+Output:
+%s
+
+Please fix the failures. Do not disable or delete tests to make them pass.`
+)
+
+// runTests runs the project's test command and lets Claude fix failures for
+// a bounded number of iterations. The result is recorded on the job.
+func (r *Runner) runTests(ctx context.Context, job *Job, testConfig config.TestConfig, containerName, workdir string, env map[string]string) {
+	result := &TestResult{Command: testConfig.Command}
+	job.TestResult = result
+
+	for {
+		result.Attempts++
+		r.logger.Manfred(fmt.Sprintf("Running tests (attempt %d): %s", result.Attempts, testConfig.Command))
+
+		output, exec, err := r.execTests(ctx, job, containerName, workdir, env, testConfig.Command)
+		result.Output = r.logger.Redact(output)
+
+		if err != nil {
+			r.logger.Manfred(fmt.Sprintf("Warning: could not run tests: %v", err))
+			break
+		}
+		result.ExitCode = exec.ExitCode
+		if exec.ExitCode == 0 {
+			result.Passed = true
+			r.logger.Manfred(fmt.Sprintf("Tests passed (%s)", exec.Duration.Round(time.Second)))
+			break
+		}
+
+		r.logger.Manfred(fmt.Sprintf("Tests failed (exit code %d, %s)", exec.ExitCode, exec.Duration.Round(time.Second)))
+
+		if result.FixAttempts >= testConfig.MaxFixAttempts {
+			r.logger.Manfred("No fix attempts left, giving up")
+			break
+		}
+
+		result.FixAttempts++
+		r.logger.Manfred(fmt.Sprintf("Asking Claude to fix failing tests (%d/%d)...", result.FixAttempts, testConfig.MaxFixAttempts))
+		prompt := fmt.Sprintf(TestFixPrompt, testConfig.Command, tail(output, maxTestFeedbackBytes))
+		if err := r.execClaude(ctx, job, containerName, workdir, env, prompt, true); err != nil {
+			r.logger.Manfred(fmt.Sprintf("Warning: fix attempt failed: %v", err))
+			break
+		}
+	}
+
+	if err := os.WriteFile(job.TestOutputFile(), []byte(result.Output), 0644); err != nil {
+		r.logger.Manfred(fmt.Sprintf("Warning: could not save test output: %v", err))
+	}
+}
+
+// execTests runs the test command in the container, streaming output to the
+// log and returning it for later use, both capped at job.output.max_bytes.
+// The error is set only when the tests could not be run at all.
+func (r *Runner) execTests(ctx context.Context, job *Job, container, workdir string, env map[string]string, command string) (string, *docker.ExecResult, error) {
+	var buf bytes.Buffer
+	logOut := r.logger.Writer("TEST")
+	defer logOut.Close()
+	out := r.outputs.limit("TEST output", io.MultiWriter(&buf, logOut))
+
+	result, err := r.exec.Exec(ctx, container, []string{"sh", "-c", command}, docker.ExecOptions{
+		User:    job.execUser,
+		Workdir: workdir,
+		Env:     env,
+		Stdout:  out,
+		Stderr:  out,
+	})
+	return buf.String(), result, err
+}
+
+// tail returns at most the last n bytes of s, starting on a line boundary
+// when possible.
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[len(s)-n:]
+	if idx := strings.Index(s, "\n"); idx >= 0 && idx < len(s)-1 {
+		s = s[idx+1:]
+	}
+	return "...\n" + s
+}
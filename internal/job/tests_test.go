@@ -0,0 +1,44 @@
+package job
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mpm/manfred/internal/config"
+)
+
+func TestRunTests(t *testing.T) {
+	var log bytes.Buffer
+	cfg := &config.Config{JobsDir: t.TempDir()}
+	job := New("proj", "do things", cfg.JobsDir)
+	if err := job.CreateDirectories(); err != nil {
+		t.Fatal(err)
+	}
+	exec := &fakeExec{output: "--- FAIL: TestLogin\nFAIL\n", exitCode: 1}
+	r := &Runner{config: cfg, logger: NewLogger(NewTextSink(&log)), exec: exec}
+
+	// The fake fails Claude's fix attempt too, which ends the loop
+	r.runTests(context.Background(), job, config.TestConfig{Command: "go test ./...", MaxFixAttempts: 2}, "c", "/workspace", nil)
+
+	if len(exec.commands) != 2 || exec.commands[0] != ": sh -c go test ./..." || !strings.Contains(exec.commands[1], "--continue -p The project's test suite failed") {
+		t.Errorf("commands = %q, want the tests and one fix attempt", exec.commands)
+	}
+	result := job.TestResult
+	if result == nil || result.Passed || result.ExitCode != 1 || result.Attempts != 1 || result.FixAttempts != 1 {
+		t.Fatalf("TestResult = %+v, want one failed run and one fix attempt", result)
+	}
+	if result.Output != exec.output {
+		t.Errorf("Output = %q, want %q", result.Output, exec.output)
+	}
+	if saved, err := os.ReadFile(job.TestOutputFile()); err != nil || string(saved) != exec.output {
+		t.Errorf("test output file = %q, %v, want %q", saved, err, exec.output)
+	}
+	for _, line := range []string{"[TEST    ] --- FAIL: TestLogin", "Running tests (attempt 1): go test ./...", "Tests failed (exit code 1"} {
+		if n := strings.Count(log.String(), line); n != 1 {
+			t.Errorf("log has %q %d times, want once:\n%s", line, n, log.String())
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package job
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// readTextFile reads a text file Claude wrote into the job directory and
+// normalizes it with normalizeText.
+func readTextFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	text, err := normalizeText(data)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return text, nil
+}
+
+// normalizeText converts Claude output to trimmed UTF-8 with LF line
+// endings. Byte order marks are removed and UTF-16 with a BOM is decoded.
+// Anything else that is not valid UTF-8 text is rejected.
+func normalizeText(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		data = data[len(bomUTF8):]
+	case bytes.HasPrefix(data, bomUTF16LE):
+		decoded, err := decodeUTF16(data[len(bomUTF16LE):], binary.LittleEndian)
+		if err != nil {
+			return "", err
+		}
+		data = decoded
+	case bytes.HasPrefix(data, bomUTF16BE):
+		decoded, err := decodeUTF16(data[len(bomUTF16BE):], binary.BigEndian)
+		if err != nil {
+			return "", err
+		}
+		data = decoded
+	}
+
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return "", fmt.Errorf("binary content: NUL byte at offset %d", i)
+	}
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("invalid UTF-8 at offset %d", invalidUTF8Offset(data))
+	}
+
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return strings.TrimSpace(text), nil
+}
+
+// decodeUTF16 converts UTF-16 without BOM to UTF-8.
+func decodeUTF16(data []byte, order binary.ByteOrder) ([]byte, error) {
+	if len(data)%2 != 0 {
+		return nil, fmt.Errorf("invalid UTF-16: odd length %d", len(data))
+	}
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	return []byte(string(utf16.Decode(units))), nil
+}
+
+// invalidUTF8Offset returns the offset of the first invalid UTF-8 sequence.
+func invalidUTF8Offset(data []byte) int {
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size <= 1 {
+			return i
+		}
+		i += size
+	}
+	return len(data)
+}
+
+// sanitizeLogLine makes a line of container output safe for log files and
+// the web viewer: ANSI escape sequences are removed, a carriage return keeps
+// only what a terminal would show after it (progress bars), and invalid
+// UTF-8 and control characters other than tab become U+FFFD.
+func sanitizeLogLine(s string) string {
+	if i := strings.LastIndexByte(strings.TrimRight(s, "\r"), '\r'); i >= 0 {
+		s = s[i+1:]
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if s[i] == 0x1b {
+			i += escapeLength(s[i:])
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == 0x9b: // 8-bit CSI
+			i += size + csiLength(s[i+size:])
+			continue
+		case r == utf8.RuneError && size <= 1:
+			b.WriteRune(utf8.RuneError)
+		case r == '\t':
+			b.WriteRune(r)
+		case r == '\r':
+		case r < 0x20 || r == 0x7f || (r >= 0x80 && r < 0xa0):
+			b.WriteRune(utf8.RuneError)
+		default:
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// escapeLength returns the length of the escape sequence at the start of s,
+// which begins with ESC.
+func escapeLength(s string) int {
+	if len(s) < 2 {
+		return len(s)
+	}
+	switch s[1] {
+	case '[': // CSI: ESC [ parameters intermediates final
+		return 2 + csiLength(s[2:])
+	case ']', 'P', '_', '^': // OSC, DCS, APC, PM: up to BEL or ESC \
+		for i := 2; i < len(s); i++ {
+			if s[i] == 0x07 {
+				return i + 1
+			}
+			if s[i] == 0x1b && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return len(s)
+	case '(', ')', '*', '+', '#', '%': // Character set selection
+		return min(3, len(s))
+	default: // Two-character sequences such as ESC =
+		return 2
+	}
+}
+
+// csiLength returns the length of the parameters and final byte of a
+// control sequence.
+func csiLength(s string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7e {
+			return i + 1
+		}
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return i
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,79 @@
+package job
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want string
+	}{
+		{"plain", []byte("feat: add x\n\n- detail\n"), "feat: add x\n\n- detail"},
+		{"crlf", []byte("feat: add x\r\n\r\n- detail\r\n"), "feat: add x\n\n- detail"},
+		{"lone cr", []byte("a\rb"), "a\nb"},
+		{"utf8 bom", []byte("\xEF\xBB\xBFfeat: äöü\n"), "feat: äöü"},
+		{"utf16le bom", []byte{0xFF, 0xFE, 'h', 0, 'i', 0, '\r', 0, '\n', 0, 'x', 0}, "hi\nx"},
+		{"utf16be bom", []byte{0xFE, 0xFF, 0, 'o', 0, 'k'}, "ok"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeText(tt.in)
+			if err != nil {
+				t.Fatalf("normalizeText() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeTextRejectsBinary(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      []byte
+		wantErr string
+	}{
+		{"nul byte", []byte("abc\x00def"), "NUL byte at offset 3"},
+		{"invalid utf8", []byte("ok \xC3\x28"), "invalid UTF-8 at offset 3"},
+		{"odd utf16", []byte{0xFF, 0xFE, 'a'}, "odd length"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := normalizeText(tt.in)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("normalizeText() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSanitizeLogLine(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain text\twith tab", "plain text\twith tab"},
+		{"\x1b[31mred\x1b[0m and \x1b[1;32mbold green\x1b[m", "red and bold green"},
+		{"\x1b]0;window title\x07prompt", "prompt"},
+		{"\x1b]8;;https://example.com\x1b\\link\x1b]8;;\x1b\\", "link"},
+		{"\x1b(Bcharset\x1b=", "charset"},
+		{" 10%\r 50%\r100%", "100%"},
+		{"done\r", "done"},
+		{"nul\x00bell\x07del\x7f", "nul�bell�del�"},
+		{"bad \xff\xfe utf8", "bad �� utf8"},
+		{"c1 \u0085 \u009b31mcsi", "c1 � csi"},
+		{"ünïcödé ✓", "ünïcödé ✓"},
+		{"unterminated \x1b[", "unterminated "},
+	}
+	for _, tt := range tests {
+		if got := sanitizeLogLine(tt.in); got != tt.want {
+			t.Errorf("sanitizeLogLine(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
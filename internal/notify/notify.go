@@ -0,0 +1,247 @@
+// Package notify sends notifications about jobs and sessions to chat
+// services, email and webhooks. Sinks are configured under notify.sinks in
+// config.yaml, each with the events and projects routed to it.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"text/template"
+
+	"github.com/mpm/manfred/internal/config"
+)
+
+// Event is something MANFRED notifies about.
+type Event string
+
+const (
+	EventJobCompleted         Event = "job_completed"
+	EventJobFailed            Event = "job_failed"
+	EventPlanAwaitingApproval Event = "plan_awaiting_approval"
+	EventPRCreated            Event = "pr_created"
+)
+
+// AllEvents returns all events in the order they usually happen.
+func AllEvents() []Event {
+	return []Event{EventPlanAwaitingApproval, EventJobCompleted, EventJobFailed, EventPRCreated}
+}
+
+// defaultTemplates render the message of each event unless notify.templates
+// overrides it.
+var defaultTemplates = map[Event]string{
+	EventJobCompleted:         `Job {{.JobID}} for {{.Project}} completed{{if .Repo}} ({{.Repo}}{{if .IssueNumber}}#{{.IssueNumber}}{{end}}){{end}}`,
+	EventJobFailed:            `Job {{.JobID}} for {{.Project}} failed{{if .Error}}: {{.Error}}{{end}}`,
+	EventPlanAwaitingApproval: `Plan for {{.Repo}}#{{.IssueNumber}}{{if .Title}} "{{.Title}}"{{end}} is awaiting approval{{if .URL}}: {{.URL}}{{end}}`,
+	EventPRCreated:            `Pull request #{{.PRNumber}} opened for {{.Repo}}#{{.IssueNumber}}{{if .Title}} "{{.Title}}"{{end}}{{if .URL}}: {{.URL}}{{end}}`,
+}
+
+// eventTitles are the short titles of push notifications.
+var eventTitles = map[Event]string{
+	EventJobCompleted:         "Job completed",
+	EventJobFailed:            "Job failed",
+	EventPlanAwaitingApproval: "Plan ready for approval",
+	EventPRCreated:            "Pull request opened",
+}
+
+// Title returns a short title for the event, e.g. for phone notifications.
+func (e Event) Title() string {
+	if title, ok := eventTitles[e]; ok {
+		return "MANFRED: " + title
+	}
+	return "MANFRED"
+}
+
+// Notification describes an event. Fields that do not apply are empty.
+type Notification struct {
+	Event       Event  `json:"event"`
+	Project     string `json:"project,omitempty"`
+	Repo        string `json:"repo,omitempty"` // owner/name
+	IssueNumber int    `json:"issue_number,omitempty"`
+	PRNumber    int    `json:"pr_number,omitempty"`
+	SessionID   string `json:"session_id,omitempty"`
+	JobID       string `json:"job_id,omitempty"`
+	Title       string `json:"title,omitempty"` // Issue or PR title
+	URL         string `json:"url,omitempty"`   // Issue or PR on GitHub
+	Error       string `json:"error,omitempty"`
+	CreatedBy   string `json:"created_by,omitempty"` // Creator of the ticket the job runs
+}
+
+// Message is a rendered notification as handed to sinks.
+type Message struct {
+	Notification
+	Text string `json:"text"`
+}
+
+// Sink delivers messages to one destination. Sinks that hold resources
+// between sends also implement io.Closer.
+type Sink interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// route is a sink with the events, projects and ticket creators sent to it.
+type route struct {
+	name      string
+	sink      Sink
+	events    []string
+	projects  []string
+	createdBy []string
+}
+
+// matches reports whether n is routed to r. Projects match the project name
+// or the owner/name of the repository. A route limited to ticket creators
+// only gets notifications of their tickets.
+func (r *route) matches(n *Notification) bool {
+	if len(r.createdBy) > 0 && !slices.Contains(r.createdBy, n.CreatedBy) {
+		return false
+	}
+	if len(r.events) > 0 && !slices.Contains(r.events, string(n.Event)) {
+		return false
+	}
+	if len(r.projects) > 0 && !slices.Contains(r.projects, n.Project) && !slices.Contains(r.projects, n.Repo) {
+		return false
+	}
+	return true
+}
+
+// Notifier renders notifications and sends them to the routed sinks. A nil
+// Notifier sends nothing.
+type Notifier struct {
+	routes    []route
+	templates map[Event]*template.Template
+}
+
+// New creates a notifier from the notify config. Sinks and templates that
+// are misconfigured are left out and reported in the error, so the rest
+// still works.
+func New(cfg config.NotifyConfig) (*Notifier, error) {
+	var errs []error
+	n := &Notifier{templates: map[Event]*template.Template{}}
+
+	for _, event := range AllEvents() {
+		text := defaultTemplates[event]
+		if custom, ok := cfg.Templates[string(event)]; ok {
+			text = custom
+		}
+		tmpl, err := template.New(string(event)).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("template %s: %w", event, err))
+			tmpl = template.Must(template.New(string(event)).Parse(defaultTemplates[event]))
+		}
+		n.templates[event] = tmpl
+	}
+	for name := range cfg.Templates {
+		if _, ok := defaultTemplates[Event(name)]; !ok {
+			errs = append(errs, fmt.Errorf("template %s: unknown event", name))
+		}
+	}
+
+	for i, sc := range cfg.Sinks {
+		name := sc.Name
+		if name == "" {
+			name = fmt.Sprintf("%s #%d", sc.Type, i+1)
+		}
+		sink, err := newSink(sc)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", name, err))
+			continue
+		}
+		for _, event := range sc.Events {
+			if _, ok := defaultTemplates[Event(event)]; !ok {
+				errs = append(errs, fmt.Errorf("sink %s: unknown event %q", name, event))
+			}
+		}
+		n.routes = append(n.routes, route{name: name, sink: sink, events: sc.Events, projects: sc.Projects, createdBy: sc.CreatedBy})
+	}
+	return n, errors.Join(errs...)
+}
+
+// newSink creates the sink of a configured type.
+func newSink(sc config.NotifySinkConfig) (Sink, error) {
+	switch sc.Type {
+	case "slack":
+		return newSlackSink(sc.URL)
+	case "discord":
+		return newDiscordSink(sc.URL)
+	case "webhook":
+		return newWebhookSink(sc.URL, sc.Secret)
+	case "email":
+		return newEmailSink(sc.Email)
+	case "ntfy":
+		return newNtfySink(sc.URL, sc.Token, sc.Priority)
+	case "pushover":
+		return newPushoverSink(sc.URL, sc.Token, sc.User, sc.Priority)
+	default:
+		return nil, fmt.Errorf("unknown type %q (want slack, discord, email, webhook, ntfy or pushover)", sc.Type)
+	}
+}
+
+// Notify renders n and sends it to every sink it is routed to, in parallel.
+// Failed sends are returned together; they never affect the caller's work.
+func (n *Notifier) Notify(ctx context.Context, notification Notification) error {
+	if n == nil || len(n.routes) == 0 {
+		return nil
+	}
+	text, err := n.render(&notification)
+	if err != nil {
+		return err
+	}
+	msg := &Message{Notification: notification, Text: text}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for i := range n.routes {
+		r := &n.routes[i]
+		if !r.matches(&notification) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := r.sink.Send(ctx, msg); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("notify %s: %w", r.name, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Close waits for deliveries still in flight and releases the sinks. The
+// notifier must not be used afterwards.
+func (n *Notifier) Close() error {
+	if n == nil {
+		return nil
+	}
+	var errs []error
+	for _, r := range n.routes {
+		if c, ok := r.sink.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("notify %s: %w", r.name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// render executes the template of the notification's event.
+func (n *Notifier) render(notification *Notification) (string, error) {
+	tmpl, ok := n.templates[notification.Event]
+	if !ok {
+		return "", fmt.Errorf("unknown notification event %q", notification.Event)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, notification); err != nil {
+		return "", fmt.Errorf("failed to render %s notification: %w", notification.Event, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,294 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mpm/manfred/internal/config"
+)
+
+// recorder is an HTTP endpoint keeping the bodies posted to it.
+type recorder struct {
+	mu      sync.Mutex
+	bodies  []string
+	headers []http.Header
+}
+
+func (rec *recorder) server(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rec.mu.Lock()
+		rec.bodies = append(rec.bodies, string(body))
+		rec.headers = append(rec.headers, r.Header.Clone())
+		rec.mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestNotifierRouting(t *testing.T) {
+	var slack, discord recorder
+	slackSrv, discordSrv := slack.server(t), discord.server(t)
+
+	n, err := New(config.NotifyConfig{Sinks: []config.NotifySinkConfig{
+		{Name: "team", Type: "slack", URL: slackSrv.URL, Projects: []string{"acme/api"}},
+		{Name: "alerts", Type: "discord", URL: discordSrv.URL, Events: []string{"job_failed"}},
+	}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	ctx := context.Background()
+	n.Notify(ctx, Notification{Event: EventPRCreated, Project: "api", Repo: "acme/api", IssueNumber: 7, PRNumber: 12, Title: "Fix login", URL: "https://github.com/acme/api/pull/12"})
+	n.Notify(ctx, Notification{Event: EventJobFailed, Project: "web", JobID: "job_1", Error: "claude failed"})
+
+	if len(slack.bodies) != 1 {
+		t.Fatalf("slack got %d messages, want 1: %v", len(slack.bodies), slack.bodies)
+	}
+	want := `{"text":"Pull request #12 opened for acme/api#7 \"Fix login\": https://github.com/acme/api/pull/12"}`
+	if slack.bodies[0] != want {
+		t.Errorf("slack body = %s, want %s", slack.bodies[0], want)
+	}
+	if len(discord.bodies) != 1 || !strings.Contains(discord.bodies[0], `"content":"Job job_1 for web failed: claude failed"`) {
+		t.Errorf("discord bodies = %v", discord.bodies)
+	}
+}
+
+func TestNotifierRoutingByCreator(t *testing.T) {
+	var alice recorder
+	aliceSrv := alice.server(t)
+
+	n, err := New(config.NotifyConfig{Sinks: []config.NotifySinkConfig{
+		{Name: "alice", Type: "slack", URL: aliceSrv.URL, CreatedBy: []string{"alice"}},
+	}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	ctx := context.Background()
+	n.Notify(ctx, Notification{Event: EventJobCompleted, Project: "api", JobID: "job_1", CreatedBy: "alice"})
+	n.Notify(ctx, Notification{Event: EventJobCompleted, Project: "api", JobID: "job_2", CreatedBy: "bob"})
+	n.Notify(ctx, Notification{Event: EventPRCreated, Repo: "acme/api", IssueNumber: 7, PRNumber: 12})
+
+	if len(alice.bodies) != 1 || !strings.Contains(alice.bodies[0], "job_1") {
+		t.Errorf("alice got %v, want only job_1", alice.bodies)
+	}
+}
+
+func TestNotifierTemplatesAndWebhook(t *testing.T) {
+	var hook recorder
+	srv := hook.server(t)
+
+	n, err := New(config.NotifyConfig{
+		Sinks:     []config.NotifySinkConfig{{Type: "webhook", URL: srv.URL, Secret: "s3cret"}},
+		Templates: map[string]string{"job_completed": "done: {{.JobID}}"},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if err := n.Notify(context.Background(), Notification{Event: EventJobCompleted, JobID: "job_2"}); err != nil {
+		t.Fatalf("Notify() error: %v", err)
+	}
+
+	var msg Message
+	if err := json.Unmarshal([]byte(hook.bodies[0]), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Text != "done: job_2" || msg.Event != EventJobCompleted || msg.JobID != "job_2" {
+		t.Errorf("webhook message = %+v", msg)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(hook.bodies[0]))
+	if got, want := hook.headers[0].Get("X-Manfred-Signature-256"), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+}
+
+func TestNewReportsBadConfig(t *testing.T) {
+	n, err := New(config.NotifyConfig{
+		Sinks: []config.NotifySinkConfig{
+			{Name: "bad", Type: "pager"},
+			{Name: "nourl", Type: "slack"},
+			{Name: "mail", Type: "email", Email: config.NotifyEmailConfig{SMTPAddr: "smtp.example.com:587", From: "manfred@example.com", To: []string{"dev@example.com"}}},
+		},
+		Templates: map[string]string{"job_failed": "{{.Broken", "unknown": "x"},
+	})
+	if err == nil {
+		t.Fatal("New() error = nil, want error")
+	}
+	for _, want := range []string{"sink bad", "sink nourl", "template job_failed", "template unknown"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+	if len(n.routes) != 1 || n.routes[0].name != "mail" {
+		t.Errorf("routes = %+v, want only mail", n.routes)
+	}
+	// The broken template falls back to the default
+	if text, err := n.render(&Notification{Event: EventJobFailed, JobID: "j", Project: "p"}); err != nil || text != "Job j for p failed" {
+		t.Errorf("render() = %q, %v", text, err)
+	}
+}
+
+func TestNilNotifier(t *testing.T) {
+	var n *Notifier
+	if err := n.Notify(context.Background(), Notification{Event: EventJobCompleted}); err != nil {
+		t.Errorf("Notify() on nil notifier = %v, want nil", err)
+	}
+}
+
+// smtpServer accepts one connection, speaks just enough SMTP to take a
+// message after release is closed, and sends the message to mail.
+func smtpServer(t *testing.T, release <-chan struct{}) (addr string, mail <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	out := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		<-release
+		r := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+		reply("220 localhost")
+		var data strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				reply("250 ok")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				out <- data.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 unknown")
+			}
+		}
+	}()
+	return ln.Addr().String(), out
+}
+
+func TestNotifierCloseWaitsForMail(t *testing.T) {
+	release := make(chan struct{})
+	addr, mail := smtpServer(t, release)
+	n, err := New(config.NotifyConfig{Sinks: []config.NotifySinkConfig{
+		{Name: "mail", Type: "email", Email: config.NotifyEmailConfig{SMTPAddr: addr, From: "manfred@example.com", To: []string{"dev@example.com"}}},
+	}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	// The caller gives up while the server is slow; the send goes on
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := n.Notify(ctx, Notification{Event: EventJobFailed, Project: "web", JobID: "job_1"}); err == nil {
+		t.Error("Notify() with a cancelled context = nil, want error")
+	}
+	close(release)
+	if err := n.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	select {
+	case body := <-mail:
+		if !strings.Contains(body, "Subject: [MANFRED] Job job_1 for web failed") {
+			t.Errorf("mail = %q", body)
+		}
+	default:
+		t.Error("Close() returned before the mail was sent")
+	}
+}
+
+func TestPushSinks(t *testing.T) {
+	var ntfy, pushover recorder
+	ntfySrv, pushoverSrv := ntfy.server(t), pushover.server(t)
+
+	n, err := New(config.NotifyConfig{Sinks: []config.NotifySinkConfig{
+		{Type: "ntfy", URL: ntfySrv.URL + "/manfred", Token: "tk_1", Priority: 4},
+		{Type: "pushover", URL: pushoverSrv.URL, Token: "app", User: "user", Priority: 1},
+	}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	err = n.Notify(context.Background(), Notification{
+		Event: EventPlanAwaitingApproval, Repo: "acme/api", IssueNumber: 7,
+		URL: "https://github.com/acme/api/issues/7",
+	})
+	if err != nil {
+		t.Fatalf("Notify() error: %v", err)
+	}
+
+	if ntfy.bodies[0] != "Plan for acme/api#7 is awaiting approval: https://github.com/acme/api/issues/7" {
+		t.Errorf("ntfy body = %q", ntfy.bodies[0])
+	}
+	h := ntfy.headers[0]
+	for header, want := range map[string]string{
+		"Title":         "MANFRED: Plan ready for approval",
+		"Click":         "https://github.com/acme/api/issues/7",
+		"Priority":      "4",
+		"Authorization": "Bearer tk_1",
+	} {
+		if got := h.Get(header); got != want {
+			t.Errorf("ntfy %s = %q, want %q", header, got, want)
+		}
+	}
+
+	form, err := url.ParseQuery(pushover.bodies[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for field, want := range map[string]string{
+		"token":    "app",
+		"user":     "user",
+		"title":    "MANFRED: Plan ready for approval",
+		"url":      "https://github.com/acme/api/issues/7",
+		"priority": "1",
+	} {
+		if got := form.Get(field); got != want {
+			t.Errorf("pushover %s = %q, want %q", field, got, want)
+		}
+	}
+}
+
+func TestPushSinkConfig(t *testing.T) {
+	for _, sc := range []config.NotifySinkConfig{
+		{Type: "ntfy", URL: "https://ntfy.sh"},
+		{Type: "ntfy", URL: "https://ntfy.sh/topic", Priority: 6},
+		{Type: "pushover", Token: "app"},
+		{Type: "pushover", Token: "app", User: "user", Priority: 2},
+	} {
+		if _, err := New(config.NotifyConfig{Sinks: []config.NotifySinkConfig{sc}}); err == nil {
+			t.Errorf("New(%+v) error = nil, want error", sc)
+		}
+	}
+}
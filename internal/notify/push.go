@@ -0,0 +1,93 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// defaultPushoverURL is the Pushover message API.
+const defaultPushoverURL = "https://api.pushover.net/1/messages.json"
+
+// ntfySink publishes to an ntfy topic (ntfy.sh or self-hosted). The issue
+// or PR URL becomes the click action, so tapping the notification opens it.
+type ntfySink struct {
+	url      string
+	token    string
+	priority int
+}
+
+func newNtfySink(topicURL, token string, priority int) (Sink, error) {
+	if err := checkURL(topicURL); err != nil {
+		return nil, err
+	}
+	if u, _ := url.Parse(topicURL); strings.Trim(u.Path, "/") == "" {
+		return nil, fmt.Errorf("url %q has no topic, e.g. https://ntfy.sh/my-topic", topicURL)
+	}
+	if priority < 0 || priority > 5 {
+		return nil, fmt.Errorf("ntfy priority %d is not between 1 and 5", priority)
+	}
+	return &ntfySink{url: topicURL, token: token, priority: priority}, nil
+}
+
+func (s *ntfySink) Send(ctx context.Context, msg *Message) error {
+	headers := map[string]string{
+		"Title": msg.Event.Title(),
+		"Tags":  "robot",
+	}
+	if msg.URL != "" {
+		headers["Click"] = msg.URL
+	}
+	if s.priority > 0 {
+		headers["Priority"] = strconv.Itoa(s.priority)
+	}
+	if s.token != "" {
+		headers["Authorization"] = "Bearer " + s.token
+	}
+	return post(ctx, s.url, "text/plain; charset=utf-8", strings.NewReader(msg.Text), headers)
+}
+
+// pushoverSink sends through the Pushover API, with the issue or PR URL as
+// the supplementary URL of the message.
+type pushoverSink struct {
+	url      string
+	token    string
+	user     string
+	priority int
+}
+
+func newPushoverSink(apiURL, token, user string, priority int) (Sink, error) {
+	if apiURL == "" {
+		apiURL = defaultPushoverURL
+	}
+	if err := checkURL(apiURL); err != nil {
+		return nil, err
+	}
+	if token == "" || user == "" {
+		return nil, fmt.Errorf("token and user are required")
+	}
+	// Priority 2 (emergency) needs retry and expire parameters
+	if priority < -2 || priority > 1 {
+		return nil, fmt.Errorf("pushover priority %d is not between -2 and 1", priority)
+	}
+	return &pushoverSink{url: apiURL, token: token, user: user, priority: priority}, nil
+}
+
+func (s *pushoverSink) Send(ctx context.Context, msg *Message) error {
+	form := url.Values{
+		"token":   {s.token},
+		"user":    {s.user},
+		"title":   {msg.Event.Title()},
+		"message": {msg.Text},
+	}
+	if msg.URL != "" {
+		form.Set("url", msg.URL)
+		form.Set("url_title", "Open on GitHub")
+	}
+	if s.priority != 0 {
+		form.Set("priority", strconv.Itoa(s.priority))
+	}
+	return post(ctx, s.url, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()), nil)
+}
@@ -0,0 +1,266 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mpm/manfred/internal/config"
+)
+
+const (
+	// sendTimeout bounds each delivery, so a slow endpoint cannot hold up
+	// the job or session that triggered it.
+	sendTimeout = 10 * time.Second
+
+	// maxDiscordContent is the longest message Discord accepts.
+	maxDiscordContent = 2000
+)
+
+var httpClient = &http.Client{Timeout: sendTimeout}
+
+// checkURL requires an absolute http(s) URL.
+func checkURL(raw string) error {
+	if raw == "" {
+		return fmt.Errorf("url is required")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("invalid url %q", raw)
+	}
+	return nil
+}
+
+// post sends body to url and expects a 2xx response.
+func post(ctx context.Context, url, contentType string, body io.Reader, headers map[string]string) error {
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+// postJSON posts payload as JSON to url and expects a 2xx response.
+func postJSON(ctx context.Context, url string, payload any, headers map[string]string) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+	return post(ctx, url, "application/json", bytes.NewReader(body), headers)
+}
+
+// slackSink posts to a Slack incoming webhook.
+type slackSink struct {
+	url string
+}
+
+func newSlackSink(url string) (Sink, error) {
+	if err := checkURL(url); err != nil {
+		return nil, err
+	}
+	return &slackSink{url: url}, nil
+}
+
+func (s *slackSink) Send(ctx context.Context, msg *Message) error {
+	return postJSON(ctx, s.url, map[string]string{"text": msg.Text}, nil)
+}
+
+// discordSink posts to a Discord channel webhook.
+type discordSink struct {
+	url string
+}
+
+func newDiscordSink(url string) (Sink, error) {
+	if err := checkURL(url); err != nil {
+		return nil, err
+	}
+	return &discordSink{url: url}, nil
+}
+
+func (s *discordSink) Send(ctx context.Context, msg *Message) error {
+	content := msg.Text
+	if len(content) > maxDiscordContent {
+		content = content[:maxDiscordContent-3] + "..."
+	}
+	return postJSON(ctx, s.url, map[string]string{"content": content}, nil)
+}
+
+// webhookSink posts the whole message as JSON to any endpoint. With a
+// secret, the body is signed like GitHub webhooks, in
+// X-Manfred-Signature-256.
+type webhookSink struct {
+	url    string
+	secret string
+}
+
+func newWebhookSink(url, secret string) (Sink, error) {
+	if err := checkURL(url); err != nil {
+		return nil, err
+	}
+	return &webhookSink{url: url, secret: secret}, nil
+}
+
+func (s *webhookSink) Send(ctx context.Context, msg *Message) error {
+	var headers map[string]string
+	if s.secret != "" {
+		body, err := json.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("failed to encode payload: %w", err)
+		}
+		mac := hmac.New(sha256.New, []byte(s.secret))
+		mac.Write(body)
+		headers = map[string]string{"X-Manfred-Signature-256": "sha256=" + hex.EncodeToString(mac.Sum(nil))}
+	}
+	return postJSON(ctx, s.url, msg, headers)
+}
+
+// emailSink sends plain text mail over SMTP, with STARTTLS when the server
+// offers it.
+type emailSink struct {
+	cfg config.NotifyEmailConfig
+
+	// pending tracks sends still running after Send returned early
+	pending sync.WaitGroup
+}
+
+func newEmailSink(cfg config.NotifyEmailConfig) (Sink, error) {
+	if _, _, err := net.SplitHostPort(cfg.SMTPAddr); err != nil {
+		return nil, fmt.Errorf("invalid smtp_addr %q: %w", cfg.SMTPAddr, err)
+	}
+	if cfg.From == "" || len(cfg.To) == 0 {
+		return nil, fmt.Errorf("from and to are required")
+	}
+	return &emailSink{cfg: cfg}, nil
+}
+
+func (s *emailSink) Send(ctx context.Context, msg *Message) error {
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		host, _, _ := net.SplitHostPort(s.cfg.SMTPAddr)
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, host)
+	}
+
+	subject := msg.Text
+	if i := strings.IndexByte(subject, '\n'); i >= 0 {
+		subject = subject[:i]
+	}
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", s.cfg.From)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(s.cfg.To, ", "))
+	fmt.Fprintf(&body, "Subject: [MANFRED] %s\r\n", strings.Map(noNewlines, subject))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	body.WriteString(strings.ReplaceAll(msg.Text, "\n", "\r\n"))
+	body.WriteString("\r\n")
+
+	// net/smtp has no context support; the connection deadline bounds the
+	// send, and Send stops waiting early when ctx is done
+	done := make(chan error, 1)
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		done <- s.sendMail(auth, []byte(body.String()))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("failed to send mail: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sendMail delivers body like smtp.SendMail, on a connection that fails
+// once sendTimeout has passed.
+func (s *emailSink) sendMail(auth smtp.Auth, body []byte) error {
+	host, _, _ := net.SplitHostPort(s.cfg.SMTPAddr)
+	conn, err := net.DialTimeout("tcp", s.cfg.SMTPAddr, sendTimeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(sendTimeout))
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); ok {
+			if err := c.Auth(auth); err != nil {
+				return err
+			}
+		}
+	}
+	if err := c.Mail(s.cfg.From); err != nil {
+		return err
+	}
+	for _, to := range s.cfg.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// Close waits for the mail still being sent.
+func (s *emailSink) Close() error {
+	s.pending.Wait()
+	return nil
+}
+
+// noNewlines keeps header values on one line.
+func noNewlines(r rune) rune {
+	if r == '\r' || r == '\n' {
+		return ' '
+	}
+	return r
+}
@@ -0,0 +1,231 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/mpm/manfred/internal/audit"
+	"github.com/mpm/manfred/internal/docker"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// Abort ends a session for good: it moves to the aborted phase, its running
+// job is stopped, the session branch is deleted and its pull request closed
+// as the abort config says, and a summary is posted on the issue. sender is
+// the GitHub user or API key asking; an empty sender (`manfred session
+// abort`) is not checked against the allowlists. Cleanup failures are
+// logged and left out of the summary.
+func (o *Orchestrator) Abort(ctx context.Context, sessionID, sender string) error {
+	sess, err := o.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if sess == nil {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	if sender != "" {
+		if err := o.authorizeSender(ctx, ActionAbort, sender, sess); err != nil {
+			return err
+		}
+	}
+
+	sess, steps, err := o.abortAndCleanUp(ctx, sessionID, "", sender, "")
+	if err != nil {
+		return err
+	}
+	o.postComment(ctx, sess, sess.IssueNumber, github.FormatAbortedComment(sess.ID, sender, steps))
+	return nil
+}
+
+// abortAndCleanUp aborts a session for sender, or with reason when MANFRED
+// aborts it by itself, and cleans up after it. A non-empty only is the
+// phase the session must be in. It returns the session and the cleanup
+// steps taken, for the summary comment.
+func (o *Orchestrator) abortAndCleanUp(ctx context.Context, sessionID string, only session.Phase, sender, reason string) (*session.Session, []string, error) {
+	sess, from, err := o.abortSession(ctx, sessionID, only)
+	if err != nil {
+		return nil, nil, err
+	}
+	details := map[string]string{"from": string(from)}
+	payload := map[string]string{
+		"from": string(from),
+		"to":   string(session.PhaseAborted),
+		"user": sender,
+	}
+	if reason != "" {
+		details["reason"], payload["reason"] = reason, reason
+	}
+	o.audit.Record(ctx, audit.ActionSessionAbort, sess.ID, details)
+	o.recordEvent(ctx, sess.ID, session.EventTypePhaseChange, payload)
+	log.Printf("session %s: aborted while %s", sess.ID, from)
+
+	steps := o.stopSessionJobs(ctx, sess)
+
+	cfg := o.config.Abort
+	if cfg.ClosePR && sess.PRNumber != nil {
+		if o.closePullRequest(ctx, sess, *sess.PRNumber) {
+			steps = append(steps, fmt.Sprintf("Closed pull request #%d", *sess.PRNumber))
+		}
+	}
+	if cfg.DeleteBranch {
+		if o.deleteSessionBranch(ctx, sess) {
+			steps = append(steps, fmt.Sprintf("Deleted branch `%s`", sess.Branch))
+		}
+	}
+	if sess.HeadSHA != nil {
+		o.reportStatus(ctx, sess, *sess.HeadSHA, statusContextJob, github.StatusError, "Session aborted")
+	}
+	return sess, steps, nil
+}
+
+// abortSession moves a session to the aborted phase and returns it with the
+// phase it left. A non-empty only is the phase the session must be in.
+func (o *Orchestrator) abortSession(ctx context.Context, sessionID string, only session.Phase) (*session.Session, session.Phase, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	sess, err := o.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, "", err
+	}
+	if sess == nil {
+		return nil, "", fmt.Errorf("session not found: %s", sessionID)
+	}
+	from := sess.Phase
+	if only != "" && from != only {
+		return nil, "", &session.TransitionError{From: from, To: session.PhaseAborted}
+	}
+	if err := sess.Abort(); err != nil {
+		return nil, "", err
+	}
+	if err := o.sessions.Update(ctx, sess); err != nil {
+		return nil, "", err
+	}
+	return sess, from, nil
+}
+
+// stopSessionJobs cancels the session's job if it runs in this process and
+// removes the containers and networks of every job of the session that has
+// not finished, which also stops jobs run by another process.
+func (o *Orchestrator) stopSessionJobs(ctx context.Context, sess *session.Session) []string {
+	var steps []string
+	if o.cancelJob(sess.ID) {
+		steps = append(steps, "Canceled the running job")
+	}
+
+	projects, err := o.runningJobProjects(ctx, sess.ID)
+	if err != nil {
+		log.Printf("session %s: failed to find running jobs: %v", sess.ID, err)
+		return steps
+	}
+	if len(projects) == 0 {
+		return steps
+	}
+
+	client, err := docker.New()
+	if err != nil {
+		log.Printf("session %s: failed to connect to docker: %v", sess.ID, err)
+		return steps
+	}
+	defer client.Close()
+
+	for _, project := range projects {
+		resources, err := client.ListComposeResources(ctx, project)
+		if err != nil {
+			log.Printf("session %s: failed to list containers of %s: %v", sess.ID, project, err)
+			continue
+		}
+		removed := 0
+		for _, r := range resources {
+			// Volumes and images follow the job's cleanup level once the
+			// runner notices its containers are gone
+			if r.Project != project || (r.Kind != docker.KindContainer && r.Kind != docker.KindNetwork) {
+				continue
+			}
+			if err := client.RemoveResource(ctx, r); err != nil {
+				log.Printf("session %s: %v", sess.ID, err)
+				continue
+			}
+			if r.Kind == docker.KindContainer {
+				removed++
+			}
+		}
+		if removed > 0 {
+			steps = append(steps, fmt.Sprintf("Stopped %d container(s) of `%s`", removed, project))
+		}
+	}
+	return steps
+}
+
+// runningJobProjects returns the compose projects of the session's jobs that
+// have a container_start but no container_stop event.
+func (o *Orchestrator) runningJobProjects(ctx context.Context, sessionID string) ([]string, error) {
+	events, err := o.sessions.GetEvents(ctx, sessionID, session.EventFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	var order []string
+	running := map[string]string{} // job ID -> compose project
+	for _, event := range events {
+		var payload struct {
+			JobID          string `json:"job_id"`
+			ComposeProject string `json:"compose_project"`
+		}
+		if json.Unmarshal([]byte(event.Payload), &payload) != nil || payload.JobID == "" {
+			continue
+		}
+		switch event.EventType {
+		case session.EventTypeContainerStart:
+			running[payload.JobID] = payload.ComposeProject
+			order = append(order, payload.JobID)
+		case session.EventTypeContainerStop:
+			delete(running, payload.JobID)
+		}
+	}
+
+	var projects []string
+	for _, id := range order {
+		if project, ok := running[id]; ok && project != "" {
+			projects = append(projects, project)
+		}
+	}
+	return projects, nil
+}
+
+// closePullRequest closes the session's pull request if it is still open.
+func (o *Orchestrator) closePullRequest(ctx context.Context, sess *session.Session, number int) bool {
+	pr, err := o.github.GetPullRequest(ctx, sess.RepoOwner, sess.RepoName, number)
+	if err != nil {
+		log.Printf("session %s: failed to get PR #%d: %v", sess.ID, number, err)
+		return false
+	}
+	if pr.State != "open" {
+		return false
+	}
+	if _, err := o.github.UpdatePullRequest(ctx, sess.RepoOwner, sess.RepoName, number, &github.UpdatePullRequestInput{State: "closed"}); err != nil {
+		log.Printf("session %s: failed to close PR #%d: %v", sess.ID, number, err)
+		return false
+	}
+	return true
+}
+
+// deleteSessionBranch deletes the session branch on the remote. A branch
+// that was never pushed is not an error.
+func (o *Orchestrator) deleteSessionBranch(ctx context.Context, sess *session.Session) bool {
+	err := o.github.DeleteBranch(ctx, sess.RepoOwner, sess.RepoName, sess.Branch)
+	if err == nil {
+		return true
+	}
+	var apiErr *github.APIError
+	if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusUnprocessableEntity) {
+		return false
+	}
+	log.Printf("session %s: failed to delete branch %s: %v", sess.ID, sess.Branch, err)
+	return false
+}
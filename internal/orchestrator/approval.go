@@ -0,0 +1,212 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mpm/manfred/internal/audit"
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// approvalExpired is the reason recorded for sessions aborted because
+// their plan was not approved in time.
+const approvalExpired = "approval_expired"
+
+// approvalPolicy returns the approval policy of the session's project, or
+// approval: when the project cannot be found.
+func (o *Orchestrator) approvalPolicy(sess *session.Session) config.ApprovalConfig {
+	_, projectConfig, err := o.config.FindProjectByRepo(sess.RepoOwner, sess.RepoName)
+	if err != nil {
+		log.Printf("session %s: using the default approval policy: %v", sess.ID, err)
+		return o.config.ApprovalPolicy(nil)
+	}
+	return o.config.ApprovalPolicy(projectConfig)
+}
+
+// approvalWait is a session's current wait for approval, since its plan was
+// last posted or edited.
+type approvalWait struct {
+	since     time.Time
+	approvers []string // Distinct approvers so far, in order
+	reminded  bool
+}
+
+// loadApprovalWait replays the session's events into its current wait for
+// approval.
+func (o *Orchestrator) loadApprovalWait(ctx context.Context, sess *session.Session) (*approvalWait, error) {
+	events, err := o.sessions.GetEvents(ctx, sess.ID, session.EventFilter{
+		Types: []session.EventType{
+			session.EventTypePhaseChange,
+			session.EventTypePlanEdited,
+			session.EventTypeCommentReceived,
+			session.EventTypeApprovalReminder,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	w := &approvalWait{since: sess.LastActivity}
+	for _, event := range events {
+		var payload struct {
+			To     string `json:"to"`
+			Source string `json:"source"`
+			User   string `json:"user"`
+		}
+		if json.Unmarshal([]byte(event.Payload), &payload) != nil {
+			continue
+		}
+		switch {
+		case event.EventType == session.EventTypePhaseChange && payload.To == string(session.PhaseAwaitingApproval),
+			event.EventType == session.EventTypePlanEdited:
+			w = &approvalWait{since: event.CreatedAt}
+		case event.EventType == session.EventTypeCommentReceived && payload.Source == "approval" && payload.User != "":
+			if !slices.Contains(w.approvers, payload.User) {
+				w.approvers = append(w.approvers, payload.User)
+			}
+		case event.EventType == session.EventTypeApprovalReminder:
+			w.reminded = true
+		}
+	}
+	return w, nil
+}
+
+// recordApproval records the approval of sender for a session awaiting
+// approval and returns the number of distinct approvers of the current plan.
+// It holds o.mu, so concurrent approvals all count.
+func (o *Orchestrator) recordApproval(ctx context.Context, sessionID, sender string) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	sess, err := o.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return 0, err
+	}
+	if sess == nil {
+		return 0, fmt.Errorf("session not found: %s", sessionID)
+	}
+	if sess.Phase != session.PhaseAwaitingApproval {
+		return 0, &session.TransitionError{From: sess.Phase, To: session.PhaseImplementing}
+	}
+	o.recordEvent(ctx, sess.ID, session.EventTypeCommentReceived, map[string]string{
+		"source": "approval",
+		"user":   sender,
+	})
+	w, err := o.loadApprovalWait(ctx, sess)
+	if err != nil {
+		return 0, err
+	}
+	return len(w.approvers), nil
+}
+
+// autoApproveLabel returns the first label of the issue that the policy
+// auto-approves plans for, or "" when there is none.
+func autoApproveLabel(policy config.ApprovalConfig, issue *github.Issue) string {
+	for _, label := range issue.Labels {
+		for _, auto := range policy.AutoApproveLabels {
+			if strings.EqualFold(label.Name, auto) {
+				return label.Name
+			}
+		}
+	}
+	return ""
+}
+
+// autoApprove approves the plan of a session whose issue carries the
+// auto-approve label and starts implementing it.
+func (o *Orchestrator) autoApprove(ctx context.Context, sess *session.Session, label string) error {
+	sess, err := o.transition(ctx, sess.ID, session.PhaseAwaitingApproval, session.PhaseImplementing)
+	if err != nil {
+		return err
+	}
+	o.audit.Record(ctx, audit.ActionSessionApprove, sess.ID, map[string]string{"auto_approve_label": label})
+	o.recordEvent(ctx, sess.ID, session.EventTypeCommentReceived, map[string]string{
+		"source": "auto_approve",
+		"label":  label,
+	})
+	log.Printf("session %s: plan approved automatically for label %s", sess.ID, label)
+	o.postComment(ctx, sess, sess.IssueNumber, github.FormatAutoApprovedComment(sess.ID, label))
+
+	return o.runImplementation(ctx, sess)
+}
+
+// RunApprovalDeadlines checks the approval deadlines of sessions awaiting
+// approval every interval until ctx is done. Errors are passed to onError.
+func (o *Orchestrator) RunApprovalDeadlines(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := o.CheckApprovalDeadlines(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckApprovalDeadlines enforces the approval policies on sessions awaiting
+// approval as of now: once a plan has waited remind_after, a reminder is
+// posted on the issue, and once it has waited expire_after, the session is
+// aborted and cleaned up like `manfred session abort`. Posting or editing
+// the plan starts the wait over.
+func (o *Orchestrator) CheckApprovalDeadlines(ctx context.Context, now time.Time) error {
+	phase := session.PhaseAwaitingApproval
+	sessions, err := o.sessions.List(ctx, session.SessionFilter{Phase: &phase})
+	if err != nil {
+		return fmt.Errorf("failed to list %s sessions: %w", phase, err)
+	}
+
+	for i := range sessions {
+		sess := &sessions[i]
+		policy := o.approvalPolicy(sess)
+		if policy.RemindAfter <= 0 && policy.ExpireAfter <= 0 {
+			continue
+		}
+		w, err := o.loadApprovalWait(ctx, sess)
+		if err != nil {
+			log.Printf("session %s: failed to load approval events: %v", sess.ID, err)
+			continue
+		}
+
+		waited := now.Sub(w.since)
+		switch {
+		case policy.ExpireAfter > 0 && waited >= policy.ExpireAfter:
+			o.expireApproval(ctx, sess, policy.ExpireAfter)
+		case policy.RemindAfter > 0 && waited >= policy.RemindAfter && !w.reminded:
+			expiresIn := time.Duration(0)
+			if policy.ExpireAfter > 0 {
+				expiresIn = policy.ExpireAfter - waited
+			}
+			o.recordEvent(ctx, sess.ID, session.EventTypeApprovalReminder, map[string]string{
+				"waited": waited.Round(time.Second).String(),
+			})
+			log.Printf("session %s: plan waiting for approval for %s, reminding", sess.ID, waited.Round(time.Second))
+			o.postComment(ctx, sess, sess.IssueNumber, github.FormatApprovalReminderComment(sess.ID, waited, expiresIn))
+		}
+	}
+	return nil
+}
+
+// expireApproval aborts a session whose plan was not approved within after.
+// Sessions approved in the meantime are left alone.
+func (o *Orchestrator) expireApproval(ctx context.Context, sess *session.Session, after time.Duration) {
+	aborted, steps, err := o.abortAndCleanUp(ctx, sess.ID, session.PhaseAwaitingApproval, "", approvalExpired)
+	if err != nil {
+		log.Printf("session %s: failed to expire approval: %v", sess.ID, err)
+		return
+	}
+	log.Printf("session %s: plan not approved within %s", sess.ID, after)
+	o.postComment(ctx, aborted, aborted.IssueNumber, github.FormatApprovalExpiredComment(aborted.ID, after, steps))
+}
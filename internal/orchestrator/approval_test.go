@@ -0,0 +1,139 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/store"
+)
+
+func TestApprovalPolicy(t *testing.T) {
+	var posted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		var comment struct {
+			Body string `json:"body"`
+		}
+		json.NewDecoder(r.Body).Decode(&comment)
+		posted = append(posted, comment.Body)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(github.Comment{ID: int64(len(posted)), Body: comment.Body})
+	}))
+	defer server.Close()
+
+	db, err := store.OpenInMemory()
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	sessions := session.NewSQLStore(db)
+
+	awaiting := func(issue int) *session.Session {
+		sess := session.NewSession("acme", "widgets", issue)
+		if err := sessions.Create(ctx, sess); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if err := sess.SetPlan("Use OAuth"); err != nil {
+			t.Fatalf("SetPlan() error = %v", err)
+		}
+		if err := sessions.Update(ctx, sess); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		if err := sessions.RecordEvent(ctx, sess.ID, session.EventTypePhaseChange, map[string]string{
+			"from": string(session.PhasePlanning),
+			"to":   string(session.PhaseAwaitingApproval),
+		}); err != nil {
+			t.Fatalf("RecordEvent() error = %v", err)
+		}
+		return sess
+	}
+	phase := func(sess *session.Session) session.Phase {
+		got, err := sessions.Get(ctx, sess.ID)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		return got.Phase
+	}
+
+	cfg := &config.Config{Approval: config.ApprovalConfig{
+		Approvers:   2,
+		RemindAfter: 24 * time.Hour,
+		ExpireAfter: 72 * time.Hour,
+	}}
+	o := New(cfg, sessions, github.NewClient("token", github.WithBaseURL(server.URL)))
+
+	t.Run("quorum", func(t *testing.T) {
+		sess := awaiting(7)
+		for _, sender := range []string{"alice", "alice"} {
+			if err := o.Approve(ctx, sess.ID, sender); err != nil {
+				t.Fatalf("Approve(%s) error = %v", sender, err)
+			}
+		}
+		if got := phase(sess); got != session.PhaseAwaitingApproval {
+			t.Fatalf("phase after approvals by one person = %s, want %s", got, session.PhaseAwaitingApproval)
+		}
+		if !strings.Contains(posted[len(posted)-1], "(1 of 2 approvals)") {
+			t.Errorf("last comment = %q, want the approval count", posted[len(posted)-1])
+		}
+
+		// The second approver completes the quorum; without a project to
+		// implement in, implementation itself fails
+		o.Approve(ctx, sess.ID, "bob")
+		if got := phase(sess); got == session.PhaseAwaitingApproval {
+			t.Errorf("phase after approvals by two people = %s, want it to move on", got)
+		}
+	})
+
+	t.Run("deadlines", func(t *testing.T) {
+		sess := awaiting(8)
+		now := time.Now()
+
+		if err := o.CheckApprovalDeadlines(ctx, now.Add(time.Hour)); err != nil {
+			t.Fatalf("CheckApprovalDeadlines() error = %v", err)
+		}
+		before := len(posted)
+		for i := 0; i < 2; i++ {
+			if err := o.CheckApprovalDeadlines(ctx, now.Add(25*time.Hour)); err != nil {
+				t.Fatalf("CheckApprovalDeadlines() error = %v", err)
+			}
+		}
+		if len(posted) != before+1 || !strings.Contains(posted[before], "Waiting for approval") {
+			t.Fatalf("comments after the reminder deadline = %q, want one reminder", posted[before:])
+		}
+
+		if err := o.CheckApprovalDeadlines(ctx, now.Add(73*time.Hour)); err != nil {
+			t.Fatalf("CheckApprovalDeadlines() error = %v", err)
+		}
+		if got := phase(sess); got != session.PhaseAborted {
+			t.Errorf("phase after the expiry deadline = %s, want %s", got, session.PhaseAborted)
+		}
+		if !strings.Contains(posted[len(posted)-1], "Approval expired") {
+			t.Errorf("last comment = %q, want the expiry", posted[len(posted)-1])
+		}
+	})
+}
+
+func TestAutoApproveLabel(t *testing.T) {
+	policy := config.ApprovalConfig{AutoApproveLabels: []string{"docs", "deps"}}
+	issue := &github.Issue{Labels: []github.Label{{Name: "manfred"}, {Name: "Docs"}}}
+	if got := autoApproveLabel(policy, issue); got != "Docs" {
+		t.Errorf("autoApproveLabel() = %q, want Docs", got)
+	}
+	if got := autoApproveLabel(config.ApprovalConfig{}, issue); got != "" {
+		t.Errorf("autoApproveLabel() without labels = %q, want none", got)
+	}
+}
@@ -0,0 +1,110 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// Action is a command a GitHub user can issue to MANFRED.
+type Action string
+
+const (
+	ActionStart   Action = "start"
+	ActionApprove Action = "approve"
+	ActionRetry   Action = "retry"
+	ActionAbort   Action = "abort"
+)
+
+// UnauthorizedError is returned when a sender may not perform an action.
+type UnauthorizedError struct {
+	User   string
+	Action Action
+}
+
+func (e *UnauthorizedError) Error() string {
+	return fmt.Sprintf("%s is not authorized to %s", e.User, e.Action)
+}
+
+// allowList returns the configured allowlist for an action.
+func (o *Orchestrator) allowList(action Action) []string {
+	switch action {
+	case ActionStart:
+		return o.config.Auth.Start
+	case ActionApprove:
+		return o.config.Auth.Approve
+	case ActionRetry:
+		return o.config.Auth.Retry
+	case ActionAbort:
+		// Aborting discards work, so it needs approve rights unless
+		// configured separately
+		if len(o.config.Auth.Abort) > 0 {
+			return o.config.Auth.Abort
+		}
+		return o.config.Auth.Approve
+	default:
+		return nil
+	}
+}
+
+// isAuthorized checks a sender against the allowlist for an action. Entries
+// containing a slash are "org/team" slugs and are checked via the GitHub API.
+func (o *Orchestrator) isAuthorized(ctx context.Context, action Action, sender string) (bool, error) {
+	allowed := o.allowList(action)
+	if len(allowed) == 0 {
+		return true, nil
+	}
+
+	for _, entry := range allowed {
+		org, team, isTeam := strings.Cut(entry, "/")
+		if !isTeam {
+			if strings.EqualFold(entry, sender) {
+				return true, nil
+			}
+			continue
+		}
+		member, err := o.github.IsTeamMember(ctx, org, team, sender)
+		if err != nil {
+			return false, fmt.Errorf("check membership of %s in %s: %w", sender, entry, err)
+		}
+		if member {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// authorize returns an UnauthorizedError if sender may not perform action on
+// the given issue, optionally replying with a "not authorized" comment.
+// sessionID and phase identify the comment for MANFRED's own bookkeeping.
+func (o *Orchestrator) authorize(ctx context.Context, action Action, sender, owner, repo string, number int, sessionID, phase string) error {
+	ok, err := o.isAuthorized(ctx, action, sender)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return nil
+	}
+
+	log.Printf("%s/%s#%d: %s is not authorized to %s", owner, repo, number, sender, action)
+	if o.config.Auth.ReplyUnauthorized {
+		body := github.FormatUnauthorizedComment(sessionID, phase, sender, string(action))
+		if _, err := o.comments.Post(ctx, owner, repo, number, body); err != nil {
+			log.Printf("%s/%s#%d: failed to post comment: %v", owner, repo, number, err)
+		}
+	}
+	return &UnauthorizedError{User: sender, Action: action}
+}
+
+// authorizeSender authorizes sender to perform action on sess like
+// authorize. API keys (github.APISender) were authorized by their scope.
+func (o *Orchestrator) authorizeSender(ctx context.Context, action Action, sender string, sess *session.Session) error {
+	if github.IsAPISender(sender) {
+		return nil
+	}
+	return o.authorize(ctx, action, sender, sess.RepoOwner, sess.RepoName, sess.IssueNumber, sess.ID, string(sess.Phase))
+}
@@ -0,0 +1,79 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+)
+
+func TestIsAuthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/orgs/acme/teams/maintainers/memberships/carol" {
+			json.NewEncoder(w).Encode(map[string]string{"state": "active"})
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{Auth: config.AuthorizationConfig{
+		Approve: []string{"alice", "acme/maintainers"},
+	}}
+	o := New(cfg, nil, github.NewClient("token", github.WithBaseURL(server.URL)))
+
+	tests := []struct {
+		action Action
+		sender string
+		want   bool
+	}{
+		{ActionStart, "anyone", true},
+		{ActionApprove, "alice", true},
+		{ActionApprove, "Alice", true},
+		{ActionApprove, "carol", true},
+		{ActionApprove, "mallory", false},
+		{ActionAbort, "alice", true}, // Falls back to approve
+		{ActionAbort, "mallory", false},
+	}
+
+	for _, tt := range tests {
+		got, err := o.isAuthorized(context.Background(), tt.action, tt.sender)
+		if err != nil {
+			t.Fatalf("isAuthorized(%s, %s) error = %v", tt.action, tt.sender, err)
+		}
+		if got != tt.want {
+			t.Errorf("isAuthorized(%s, %s) = %v, want %v", tt.action, tt.sender, got, tt.want)
+		}
+	}
+}
+
+func TestAuthorizeRepliesWhenConfigured(t *testing.T) {
+	var posted bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			posted = true
+		}
+		json.NewEncoder(w).Encode(github.Comment{ID: 1})
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{Auth: config.AuthorizationConfig{
+		Retry:             []string{"alice"},
+		ReplyUnauthorized: true,
+	}}
+	o := New(cfg, nil, github.NewClient("token", github.WithBaseURL(server.URL)))
+
+	err := o.authorize(context.Background(), ActionRetry, "mallory", "owner", "repo", 1, "owner-repo-issue-1", "error")
+	var unauthorized *UnauthorizedError
+	if !errors.As(err, &unauthorized) {
+		t.Fatalf("authorize() error = %v, want *UnauthorizedError", err)
+	}
+	if !posted {
+		t.Error("expected a not-authorized comment to be posted")
+	}
+}
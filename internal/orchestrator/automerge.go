@@ -0,0 +1,183 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/mpm/manfred/internal/audit"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// AutoMerge merges a session's pull request with the project's auto-merge
+// method once it is approved and every check on its head passed, then
+// completes the session like a merge by anyone else. It reports whether it
+// merged. Sessions outside in_review, drafts, pull requests whose head is
+// not the last commit MANFRED pushed, and pull requests without any checks
+// are left alone, as are merges GitHub refuses, e.g. over branch
+// protection.
+func (o *Orchestrator) AutoMerge(ctx context.Context, sessionID string) (bool, error) {
+	sess, err := o.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+	if sess == nil {
+		return false, fmt.Errorf("session not found: %s", sessionID)
+	}
+	if sess.Phase != session.PhaseInReview || sess.PRNumber == nil || sess.HeadSHA == nil {
+		return false, nil
+	}
+	_, projectConfig, err := o.config.FindProjectByRepo(sess.RepoOwner, sess.RepoName)
+	if err != nil {
+		return false, err
+	}
+	method := o.config.AutoMerge(projectConfig)
+	if method == "" {
+		return false, nil
+	}
+
+	o.mu.Lock()
+	if o.merging[sess.ID] {
+		o.mu.Unlock()
+		return false, nil
+	}
+	o.merging[sess.ID] = true
+	o.mu.Unlock()
+	defer func() {
+		o.mu.Lock()
+		delete(o.merging, sess.ID)
+		o.mu.Unlock()
+	}()
+
+	pr, err := o.github.GetPullRequest(ctx, sess.RepoOwner, sess.RepoName, *sess.PRNumber)
+	if err != nil {
+		return false, fmt.Errorf("get PR #%d: %w", *sess.PRNumber, err)
+	}
+	if pr.State != "open" || pr.Draft || pr.Head.SHA != *sess.HeadSHA {
+		return false, nil
+	}
+	approvers, err := o.approvers(ctx, sess, pr.Number, pr.Head.SHA)
+	if err != nil || len(approvers) == 0 {
+		return false, err
+	}
+	if ok, err := o.checksPassed(ctx, sess, pr.Head.SHA); err != nil || !ok {
+		return false, err
+	}
+
+	result, err := o.github.MergePullRequest(ctx, sess.RepoOwner, sess.RepoName, pr.Number, &github.MergePullRequestInput{
+		MergeMethod: method,
+		SHA:         pr.Head.SHA,
+	})
+	var apiErr *github.APIError
+	if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusMethodNotAllowed || apiErr.StatusCode == http.StatusConflict) {
+		log.Printf("session %s: GitHub refused to merge PR #%d: %v", sess.ID, pr.Number, err)
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("merge PR #%d: %w", pr.Number, err)
+	}
+
+	log.Printf("session %s: PR #%d approved by %s and checks passed, merged (%s)", sess.ID, pr.Number, strings.Join(approvers, ", "), method)
+	o.recordEvent(ctx, sess.ID, session.EventTypeAutoMerged, map[string]interface{}{
+		"number":      pr.Number,
+		"method":      method,
+		"sha":         result.SHA,
+		"approved_by": approvers,
+	})
+	o.audit.Record(ctx, audit.ActionSessionMerge, sess.ID, map[string]string{
+		"pr":     fmt.Sprint(pr.Number),
+		"method": method,
+	})
+
+	pr.Merged, pr.State = true, "closed"
+	return true, o.completeMerge(ctx, sess.ID, pr, method)
+}
+
+// approvers returns who approved head of the pull request in their latest
+// review and may approve, nil if nobody did or anyone's latest review
+// requests changes. Approvals of older commits do not count, so commits
+// pushed after an approval, such as CI fixes, are reviewed again.
+func (o *Orchestrator) approvers(ctx context.Context, sess *session.Session, number int, head string) ([]string, error) {
+	reviews, err := o.github.ListReviews(ctx, sess.RepoOwner, sess.RepoName, number)
+	if err != nil {
+		return nil, fmt.Errorf("list reviews of PR #%d: %w", number, err)
+	}
+
+	latest, commits := map[string]string{}, map[string]string{}
+	var logins []string
+	for _, review := range reviews {
+		state := strings.ToLower(review.State)
+		if state == "commented" || state == "pending" {
+			continue
+		}
+		if _, seen := latest[review.User.Login]; !seen {
+			logins = append(logins, review.User.Login)
+		}
+		latest[review.User.Login] = state
+		commits[review.User.Login] = review.CommitID
+	}
+
+	var approvers []string
+	for _, login := range logins {
+		switch latest[login] {
+		case "changes_requested":
+			return nil, nil
+		case "approved":
+			if commits[login] != head {
+				continue
+			}
+			ok, err := o.mayApprove(ctx, sess, login)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				approvers = append(approvers, login)
+			} else {
+				log.Printf("session %s: ignoring the approval of PR #%d by %s, who may not approve", sess.ID, number, login)
+			}
+		}
+	}
+	return approvers, nil
+}
+
+// mayApprove reports whether login's approval of a pull request counts:
+// login is on the authorization.approve list, or without one has write
+// access to the repository, since anyone can approve pull requests of
+// public ones.
+func (o *Orchestrator) mayApprove(ctx context.Context, sess *session.Session, login string) (bool, error) {
+	if len(o.allowList(ActionApprove)) > 0 {
+		return o.isAuthorized(ctx, ActionApprove, login)
+	}
+	permission, err := o.github.CollaboratorPermission(ctx, sess.RepoOwner, sess.RepoName, login)
+	if err != nil {
+		return false, fmt.Errorf("get permission of %s: %w", login, err)
+	}
+	return permission == "admin" || permission == "write", nil
+}
+
+// checksPassed reports whether sha has checks and every check run and
+// commit status on it passed, MANFRED's own included.
+func (o *Orchestrator) checksPassed(ctx context.Context, sess *session.Session, sha string) (bool, error) {
+	status, err := o.github.GetCombinedStatus(ctx, sess.RepoOwner, sess.RepoName, sha)
+	if err != nil {
+		return false, fmt.Errorf("get status of %s: %w", sha, err)
+	}
+	if status.TotalCount > 0 && status.State != github.StatusSuccess {
+		return false, nil
+	}
+
+	runs, err := o.github.ListCheckRunsForRef(ctx, sess.RepoOwner, sess.RepoName, sha)
+	if err != nil {
+		return false, fmt.Errorf("list check runs of %s: %w", sha, err)
+	}
+	for _, run := range runs {
+		if run.Status != "completed" || !github.PassedConclusion(run.Conclusion) {
+			return false, nil
+		}
+	}
+	return status.TotalCount > 0 || len(runs) > 0, nil
+}
@@ -0,0 +1,154 @@
+package orchestrator
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/store"
+)
+
+func TestAutoMerge(t *testing.T) {
+	var merges, issueRequests []string
+	reviews := `[{"user":{"login":"alice"},"state":"APPROVED","commit_id":"abc123"},{"user":{"login":"bob"},"state":"CHANGES_REQUESTED"}]`
+	checkRuns := `{"check_runs":[{"name":"test","status":"in_progress"}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case r.URL.Path == "/repos/acme/widgets/pulls/12":
+			w.Write([]byte(`{"number":12,"title":"Login","state":"open","head":{"ref":"claude/issue-7","sha":"abc123"}}`))
+		case r.URL.Path == "/repos/acme/widgets/pulls/12/reviews":
+			w.Write([]byte(reviews))
+		case strings.HasSuffix(r.URL.Path, "/permission"):
+			w.Write([]byte(`{"permission":"write"}`))
+		case r.URL.Path == "/repos/acme/widgets/commits/abc123/status":
+			w.Write([]byte(`{"state":"success","total_count":1}`))
+		case r.URL.Path == "/repos/acme/widgets/commits/abc123/check-runs":
+			w.Write([]byte(checkRuns))
+		case r.URL.Path == "/repos/acme/widgets/pulls/12/merge":
+			merges = append(merges, r.Method+" "+string(body))
+			w.Write([]byte(`{"sha":"def456","merged":true}`))
+		case strings.HasPrefix(r.URL.Path, "/repos/acme/widgets/issues/7"):
+			issueRequests = append(issueRequests, r.Method+" "+r.URL.Path+" "+string(body))
+			if r.Method == http.MethodPost {
+				w.WriteHeader(http.StatusCreated)
+			}
+			w.Write([]byte(`{}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	db, err := store.OpenInMemory()
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	sessions := session.NewSQLStore(db)
+	sess := session.NewSession("acme", "widgets", 7)
+	sess.Phase = session.PhaseInReview
+	sess.SetPRNumber(12)
+	sess.SetHeadSHA("abc123")
+	if err := sessions.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	projectsDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(projectsDir, "widgets"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(projectsDir, "widgets", "project.yml"), []byte("repo: https://github.com/acme/widgets.git\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		ProjectsDir: projectsDir,
+		GitHub:      config.GitHubConfig{AutoMerge: config.MergeMethodSquash},
+		PostMerge:   config.PostMergeConfig{CloseIssue: true},
+	}
+	o := New(cfg, sessions, github.NewClient("token", github.WithBaseURL(server.URL)))
+
+	// Changes requested by bob and a running check both hold the merge
+	merged, err := o.AutoMerge(ctx, sess.ID)
+	if err != nil || merged {
+		t.Fatalf("AutoMerge() with changes requested = %v, %v", merged, err)
+	}
+	reviews = `[{"user":{"login":"alice"},"state":"APPROVED","commit_id":"abc123"},{"user":{"login":"bob"},"state":"CHANGES_REQUESTED"},
+		{"user":{"login":"bob"},"state":"COMMENTED"},{"user":{"login":"bob"},"state":"APPROVED","commit_id":"abc123"}]`
+	if merged, err := o.AutoMerge(ctx, sess.ID); err != nil || merged {
+		t.Fatalf("AutoMerge() with a running check = %v, %v", merged, err)
+	}
+	if len(merges) != 0 {
+		t.Fatalf("merges = %q before the checks passed", merges)
+	}
+
+	checkRuns = `{"check_runs":[{"name":"test","status":"completed","conclusion":"success"},
+		{"name":"lint","status":"completed","conclusion":"skipped"}]}`
+	merged, err = o.AutoMerge(ctx, sess.ID)
+	if err != nil || !merged {
+		t.Fatalf("AutoMerge() = %v, %v, want a merge", merged, err)
+	}
+	if len(merges) != 1 || merges[0] != `PUT {"merge_method":"squash","sha":"abc123"}` {
+		t.Errorf("merges = %q, want a squash merge of the session head", merges)
+	}
+	got, err := sessions.Get(ctx, sess.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Phase != session.PhaseCompleted {
+		t.Errorf("phase = %s, want completed", got.Phase)
+	}
+	if len(issueRequests) < 2 || !strings.Contains(issueRequests[0], "MANFRED merged it (squash). Closing this issue.") ||
+		!strings.Contains(issueRequests[1], `PATCH /repos/acme/widgets/issues/7 {"state":"closed",`) {
+		t.Errorf("issue requests = %q, want the merge comment and the issue closed", issueRequests)
+	}
+}
+
+func TestApprovers(t *testing.T) {
+	for _, tt := range []struct {
+		approve []string
+		reviews string
+		want    string
+	}{
+		{nil, `[]`, ""},
+		{nil, `[{"user":{"login":"alice"},"state":"COMMENTED","commit_id":"abc"}]`, ""},
+		{nil, `[{"user":{"login":"alice"},"state":"APPROVED","commit_id":"abc"},{"user":{"login":"alice"},"state":"DISMISSED"}]`, ""},
+		{nil, `[{"user":{"login":"alice"},"state":"APPROVED","commit_id":"abc"},{"user":{"login":"bob"},"state":"APPROVED","commit_id":"abc"}]`, "alice,bob"},
+		// Approvals of an older head
+		{nil, `[{"user":{"login":"alice"},"state":"APPROVED","commit_id":"old"}]`, ""},
+		// Without authorization.approve, only reviewers with write access count
+		{nil, `[{"user":{"login":"alice"},"state":"APPROVED","commit_id":"abc"},{"user":{"login":"mallory"},"state":"APPROVED","commit_id":"abc"}]`, "alice"},
+		// With it, only those on the list
+		{[]string{"bob"}, `[{"user":{"login":"alice"},"state":"APPROVED","commit_id":"abc"},{"user":{"login":"bob"},"state":"APPROVED","commit_id":"abc"}]`, "bob"},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/repos/acme/widgets/collaborators/mallory/permission":
+				w.Write([]byte(`{"permission":"read"}`))
+			case "/repos/acme/widgets/collaborators/alice/permission", "/repos/acme/widgets/collaborators/bob/permission":
+				w.Write([]byte(`{"permission":"write"}`))
+			default:
+				w.Write([]byte(tt.reviews))
+			}
+		}))
+		cfg := &config.Config{Auth: config.AuthorizationConfig{Approve: tt.approve}}
+		o := New(cfg, nil, github.NewClient("token", github.WithBaseURL(server.URL)))
+		got, err := o.approvers(context.Background(), session.NewSession("acme", "widgets", 7), 12, "abc")
+		server.Close()
+		if err != nil || strings.Join(got, ",") != tt.want {
+			t.Errorf("approvers(%v, %s) = %v, %v, want %q", tt.approve, tt.reviews, got, err, tt.want)
+		}
+	}
+}
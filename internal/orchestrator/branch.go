@@ -0,0 +1,169 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// HandlePush checks a push to a session branch. Pushes of commits other than
+// the one MANFRED pushed last pause automation on the branch.
+func (o *Orchestrator) HandlePush(ctx context.Context, sessionID, sha, pusher string) error {
+	sess, err := o.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if sess == nil {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	switch {
+	case sess.Phase.IsTerminal() || sess.Phase == session.PhasePaused || sess.Phase == session.PhasePlanning ||
+		sess.Phase == session.PhaseAwaitingAnswers || sess.Phase == session.PhaseTriaging:
+		return nil
+	case sess.Phase == session.PhaseImplementing || sess.Phase == session.PhaseRevising:
+		// A job is running and may be the pusher. Its head SHA is not known
+		// until it finishes; verifyBranchHead catches manual pushes later.
+		return nil
+	case sess.HeadSHA != nil && *sess.HeadSHA == sha:
+		return nil
+	}
+
+	return o.pauseForManualPush(ctx, sess, sha, pusher)
+}
+
+// verifyBranchHead compares the session branch on GitHub with the last
+// commit MANFRED pushed. If they differ, automation is paused and false is
+// returned.
+func (o *Orchestrator) verifyBranchHead(ctx context.Context, sess *session.Session) (bool, error) {
+	if sess.HeadSHA == nil {
+		return true, nil
+	}
+
+	branch, err := o.github.GetBranch(ctx, sess.RepoOwner, sess.RepoName, sess.Branch)
+	if err != nil {
+		return false, fmt.Errorf("get branch %s: %w", sess.Branch, err)
+	}
+	if branch.Commit.SHA == *sess.HeadSHA {
+		return true, nil
+	}
+
+	return false, o.pauseForManualPush(ctx, sess, branch.Commit.SHA, "someone")
+}
+
+// pauseForManualPush moves a session to the paused phase and explains why.
+func (o *Orchestrator) pauseForManualPush(ctx context.Context, sess *session.Session, sha, pusher string) error {
+	log.Printf("session %s: manual push of %s to %s by %s, pausing automation", sess.ID, sha, sess.Branch, pusher)
+
+	from := sess.Phase
+	if _, err := o.transition(ctx, sess.ID, from, session.PhasePaused); err != nil {
+		return err
+	}
+	o.recordEvent(ctx, sess.ID, session.EventTypeManualPush, map[string]string{
+		"sha":  sha,
+		"user": pusher,
+	})
+
+	number := sess.IssueNumber
+	if sess.PRNumber != nil {
+		number = *sess.PRNumber
+	}
+	o.postComment(ctx, sess, number, github.FormatManualPushComment(sess.ID, string(from), sess.Branch, pusher, sha))
+	return nil
+}
+
+// Resume returns a paused session to the phase it was paused in, taking the
+// current branch head as the new baseline.
+func (o *Orchestrator) Resume(ctx context.Context, sessionID, sender string) error {
+	sess, err := o.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if sess == nil {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	if sess.Phase != session.PhasePaused {
+		return nil
+	}
+	if err := o.authorize(ctx, ActionRetry, sender, sess.RepoOwner, sess.RepoName, sess.IssueNumber, sess.ID, string(sess.Phase)); err != nil {
+		return err
+	}
+
+	branch, err := o.github.GetBranch(ctx, sess.RepoOwner, sess.RepoName, sess.Branch)
+	if err != nil {
+		return fmt.Errorf("get branch %s: %w", sess.Branch, err)
+	}
+
+	to, err := o.pausedFrom(ctx, sess)
+	if err != nil {
+		return err
+	}
+	if err := o.resumeSession(ctx, sess.ID, to, branch.Commit.SHA); err != nil {
+		return err
+	}
+	o.recordEvent(ctx, sess.ID, session.EventTypePhaseChange, map[string]string{
+		"from": string(session.PhasePaused),
+		"to":   string(to),
+	})
+	o.recordEvent(ctx, sess.ID, session.EventTypeCommentReceived, map[string]string{
+		"source": "resume",
+		"user":   sender,
+		"sha":    branch.Commit.SHA,
+	})
+
+	log.Printf("session %s: automation resumed by %s at %s", sess.ID, sender, branch.Commit.SHA)
+	return nil
+}
+
+// resumeSession moves a paused session back to phase with sha as its head.
+func (o *Orchestrator) resumeSession(ctx context.Context, sessionID string, phase session.Phase, sha string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	sess, err := o.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if sess == nil {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	if err := sess.Resume(phase, sha); err != nil {
+		return err
+	}
+	return o.sessions.Update(ctx, sess)
+}
+
+// pausedFrom returns the phase a paused session was paused in, read from its
+// last phase_change event into paused. Sessions paused before there was a
+// paused phase have no such event and return to in_review once they have a
+// pull request.
+func (o *Orchestrator) pausedFrom(ctx context.Context, sess *session.Session) (session.Phase, error) {
+	events, err := o.sessions.GetEvents(ctx, sess.ID, session.EventFilter{})
+	if err != nil {
+		return "", err
+	}
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].EventType != session.EventTypePhaseChange {
+			continue
+		}
+		var payload struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		}
+		if json.Unmarshal([]byte(events[i].Payload), &payload) != nil || payload.To != string(session.PhasePaused) {
+			continue
+		}
+		if from := session.Phase(payload.From); session.PhasePaused.CanTransitionTo(from) && !from.IsTerminal() {
+			return from, nil
+		}
+		break
+	}
+	if sess.PRNumber != nil {
+		return session.PhaseInReview, nil
+	}
+	return session.PhaseAwaitingApproval, nil
+}
@@ -0,0 +1,107 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// IsOwnCheck reports whether a check run or commit status is one MANFRED
+// reports itself, whose failures are not CI failures to fix.
+func IsOwnCheck(name string) bool {
+	return name == statusContextJob || name == statusContextTests
+}
+
+// HandleCIFailure runs a revision round for a session whose pull request
+// failed CI: failure, the output of the failed checks on headSHA, is handed
+// to Claude like review feedback. Only failures of the session's current
+// head while it is in review count. After ci.max_fix_attempts revisions in
+// a row for CI, the failure is left to humans with a comment on the PR.
+func (o *Orchestrator) HandleCIFailure(ctx context.Context, sessionID, headSHA, failure string) error {
+	sess, err := o.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if sess == nil {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	if sess.Phase != session.PhaseInReview {
+		log.Printf("session %s: %s, ignoring CI failure", sess.ID, sess.Phase)
+		return nil
+	}
+	if sess.HeadSHA == nil || *sess.HeadSHA != headSHA {
+		log.Printf("session %s: ignoring CI failure of %s, which is not the branch head", sess.ID, headSHA)
+		return nil
+	}
+	if sess.PRNumber == nil {
+		return fmt.Errorf("session %s has no pull request", sess.ID)
+	}
+	prNumber := *sess.PRNumber
+
+	attempts, gaveUp, err := o.ciFixAttempts(ctx, sess, headSHA)
+	if err != nil {
+		return err
+	}
+	if max := o.config.CI.MaxFixAttempts; attempts >= max {
+		if gaveUp || attempts == 0 {
+			return nil
+		}
+		log.Printf("session %s: CI still fails after %d fix attempt(s), leaving it to humans", sess.ID, attempts)
+		o.recordEvent(ctx, sess.ID, session.EventTypeCIGaveUp, map[string]interface{}{
+			"head_sha": headSHA,
+			"attempts": attempts,
+		})
+		o.postComment(ctx, sess, prNumber, github.FormatCIGaveUpComment(sess.ID, attempts))
+		return nil
+	}
+
+	if ok, err := o.verifyBranchHead(ctx, sess); err != nil || !ok {
+		return err
+	}
+	sess, err = o.transition(ctx, sessionID, session.PhaseInReview, session.PhaseRevising)
+	if err != nil {
+		return err
+	}
+	o.recordEvent(ctx, sess.ID, session.EventTypeCommentReceived, map[string]string{
+		"source":   "ci",
+		"head_sha": headSHA,
+		"feedback": failure,
+	})
+
+	return o.runRevision(ctx, sess, prNumber, failure, true)
+}
+
+// ciFixAttempts counts the revisions for CI since the last one for a
+// review, and reports whether MANFRED already gave up on headSHA.
+func (o *Orchestrator) ciFixAttempts(ctx context.Context, sess *session.Session, headSHA string) (int, bool, error) {
+	events, err := o.sessions.GetEvents(ctx, sess.ID, session.EventFilter{
+		Types: []session.EventType{session.EventTypeCommentReceived, session.EventTypeCIGaveUp},
+	})
+	if err != nil {
+		return 0, false, err
+	}
+
+	attempts, gaveUp := 0, false
+	for _, event := range events {
+		var payload struct {
+			Source  string `json:"source"`
+			HeadSHA string `json:"head_sha"`
+		}
+		if json.Unmarshal([]byte(event.Payload), &payload) != nil {
+			continue
+		}
+		switch {
+		case event.EventType == session.EventTypeCIGaveUp:
+			gaveUp = gaveUp || payload.HeadSHA == headSHA
+		case payload.Source == "review":
+			attempts = 0
+		case payload.Source == "ci":
+			attempts++
+		}
+	}
+	return attempts, gaveUp, nil
+}
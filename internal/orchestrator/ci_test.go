@@ -0,0 +1,96 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/store"
+)
+
+func TestHandleCIFailureGivesUp(t *testing.T) {
+	var posted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			return
+		}
+		var comment struct {
+			Body string `json:"body"`
+		}
+		json.NewDecoder(r.Body).Decode(&comment)
+		posted = append(posted, comment.Body)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(github.Comment{ID: int64(len(posted)), Body: comment.Body})
+	}))
+	defer server.Close()
+
+	db, err := store.OpenInMemory()
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	sessions := session.NewSQLStore(db)
+
+	sess := session.NewSession("acme", "widgets", 7)
+	sess.Phase = session.PhaseInReview
+	sess.SetPRNumber(12)
+	sess.SetHeadSHA("abc123")
+	if err := sessions.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	received := func(source string) {
+		if err := sessions.RecordEvent(ctx, sess.ID, session.EventTypeCommentReceived, map[string]string{
+			"source":   source,
+			"feedback": "FAIL TestLogin",
+		}); err != nil {
+			t.Fatalf("RecordEvent() error = %v", err)
+		}
+	}
+	received("ci")
+	received("review")
+	received("ci")
+	received("ci")
+
+	o := New(&config.Config{CI: config.CIConfig{MaxFixAttempts: 2}}, sessions, github.NewClient("token", github.WithBaseURL(server.URL)))
+
+	// Failures of older commits are not the session's to fix
+	if err := o.HandleCIFailure(ctx, sess.ID, "old456", "FAIL"); err != nil {
+		t.Fatalf("HandleCIFailure(stale) error = %v", err)
+	}
+	if len(posted) != 0 {
+		t.Fatalf("HandleCIFailure(stale) posted %q", posted)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := o.HandleCIFailure(ctx, sess.ID, "abc123", "FAIL"); err != nil {
+			t.Fatalf("HandleCIFailure() error = %v", err)
+		}
+	}
+	if len(posted) != 1 || !strings.Contains(posted[0], "MANFRED made 2 attempts to fix the failing checks") {
+		t.Errorf("posted = %q, want one give-up comment", posted)
+	}
+	got, err := sessions.Get(ctx, sess.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Phase != session.PhaseInReview {
+		t.Errorf("phase = %s, want in_review", got.Phase)
+	}
+
+	// A review starts the count over
+	received("review")
+	if attempts, _, err := o.ciFixAttempts(ctx, sess, "abc123"); err != nil || attempts != 0 {
+		t.Errorf("ciFixAttempts() after a review = %d, %v, want 0", attempts, err)
+	}
+}
@@ -0,0 +1,150 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/prompt"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// clarifications are the clarifying question rounds of the session's
+// current planning, since it last entered planning other than with answers.
+type clarifications struct {
+	rounds    []prompt.Clarification
+	answerIDs map[int64]bool // Comments holding the answers
+	lastJobID string         // Job that asked the last round's questions
+}
+
+// clarificationPayload is the part of event payloads clarifications are
+// read from.
+type clarificationPayload struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	JobID     string `json:"job_id"`
+	Questions string `json:"questions"`
+	Source    string `json:"source"`
+	User      string `json:"user"`
+	Feedback  string `json:"feedback"`
+	CommentID int64  `json:"comment_id"`
+}
+
+// loadClarifications replays the session's events into its clarifications.
+func (o *Orchestrator) loadClarifications(ctx context.Context, sess *session.Session) (*clarifications, error) {
+	events, err := o.sessions.GetEvents(ctx, sess.ID, session.EventFilter{
+		Types: []session.EventType{session.EventTypePhaseChange, session.EventTypeCommentReceived},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &clarifications{answerIDs: map[int64]bool{}}
+	for _, event := range events {
+		var payload clarificationPayload
+		if json.Unmarshal([]byte(event.Payload), &payload) != nil {
+			continue
+		}
+		switch {
+		case event.EventType == session.EventTypePhaseChange && payload.To == string(session.PhasePlanning) &&
+			payload.From != string(session.PhaseAwaitingAnswers):
+			c = &clarifications{answerIDs: map[int64]bool{}}
+		case event.EventType == session.EventTypePhaseChange && payload.To == string(session.PhaseAwaitingAnswers):
+			c.rounds = append(c.rounds, prompt.Clarification{Questions: payload.Questions})
+			c.lastJobID = payload.JobID
+		case event.EventType == session.EventTypeCommentReceived && payload.Source == "answers" && len(c.rounds) > 0:
+			round := &c.rounds[len(c.rounds)-1]
+			round.Answer, round.User = payload.Feedback, payload.User
+			c.answerIDs[payload.CommentID] = true
+		}
+	}
+	return c, nil
+}
+
+// canAsk reports whether Claude may ask another round of questions: only
+// while planning from scratch, up to job.planning.clarify_rounds rounds.
+func (o *Orchestrator) canAsk(c *clarifications, rev *planRevision) bool {
+	return rev == nil && len(c.rounds) < o.config.Job.Planning.ClarifyRounds
+}
+
+// Answer continues planning with the answer to the clarifying questions of
+// a session awaiting answers. sender must be allowed to start sessions;
+// comments by anyone else are not taken as the answer.
+func (o *Orchestrator) Answer(ctx context.Context, sessionID, sender string, commentID int64, answer string) error {
+	allowed, err := o.isAuthorized(ctx, ActionStart, sender)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		log.Printf("session %s: ignoring answer by %s, who may not start sessions", sessionID, sender)
+		return nil
+	}
+
+	sess, err := o.transition(ctx, sessionID, session.PhaseAwaitingAnswers, session.PhasePlanning)
+	if err != nil {
+		return err
+	}
+	o.recordEvent(ctx, sess.ID, session.EventTypeCommentReceived, map[string]interface{}{
+		"source":     "answers",
+		"user":       sender,
+		"comment_id": commentID,
+		"feedback":   answer,
+	})
+	return o.runPlanning(ctx, sess, nil)
+}
+
+// askQuestions posts the clarifying questions of a completed planning job
+// on the issue and moves the session to awaiting_answers.
+func (o *Orchestrator) askQuestions(ctx context.Context, sess *session.Session, j *job.Job, questions string, round int) error {
+	if err := sess.TransitionTo(session.PhaseAwaitingAnswers); err != nil {
+		return err
+	}
+	if err := o.sessions.Update(ctx, sess); err != nil {
+		return err
+	}
+	o.recordEvent(ctx, sess.ID, session.EventTypePhaseChange, map[string]string{
+		"from":      string(session.PhasePlanning),
+		"to":        string(session.PhaseAwaitingAnswers),
+		"job_id":    j.ID,
+		"questions": questions,
+		"round":     strconv.Itoa(round),
+	})
+
+	log.Printf("session %s: asking clarifying questions (round %d)", sess.ID, round)
+	o.postComment(ctx, sess, sess.IssueNumber, github.FormatQuestionsComment(sess.ID, questions, round, o.config.Job.Planning.ClarifyRounds))
+	return nil
+}
+
+// checkQuestions returns the questions of a planning reply that asks
+// instead of planning and the round they make, or a zero round for a plan.
+// It is an error to ask when no more rounds are allowed.
+func (o *Orchestrator) checkQuestions(ctx context.Context, sess *session.Session, rev *planRevision, reply string) (string, int, error) {
+	questions, ok := session.ParseQuestions(reply)
+	if !ok {
+		return "", 0, nil
+	}
+	c, err := o.loadClarifications(ctx, sess)
+	if err != nil {
+		return "", 0, err
+	}
+	if !o.canAsk(c, rev) {
+		return "", 0, fmt.Errorf("claude asked clarifying questions instead of planning, but no more rounds are allowed (job.planning.clarify_rounds: %d)", o.config.Job.Planning.ClarifyRounds)
+	}
+	return questions, len(c.rounds) + 1, nil
+}
+
+// withoutAnswers drops the comments holding answers, which the prompt
+// shows with their questions.
+func withoutAnswers(comments []github.Comment, answerIDs map[int64]bool) []github.Comment {
+	var result []github.Comment
+	for _, c := range comments {
+		if !answerIDs[c.ID] {
+			result = append(result, c)
+		}
+	}
+	return result
+}
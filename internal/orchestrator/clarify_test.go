@@ -0,0 +1,89 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/store"
+)
+
+func TestClarificationRounds(t *testing.T) {
+	var posted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		var comment struct {
+			Body string `json:"body"`
+		}
+		json.NewDecoder(r.Body).Decode(&comment)
+		posted = append(posted, comment.Body)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(github.Comment{ID: int64(len(posted)), Body: comment.Body})
+	}))
+	defer server.Close()
+
+	db, err := store.OpenInMemory()
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	sessions := session.NewSQLStore(db)
+
+	sess := session.NewSession("acme", "widgets", 7)
+	if err := sessions.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	cfg := &config.Config{Job: config.JobConfig{Planning: config.PlanningConfig{ClarifyRounds: 1}}}
+	o := New(cfg, sessions, github.NewClient("token", github.WithBaseURL(server.URL)))
+
+	reply := "```manfred-questions\n1. Which provider?\n```"
+	questions, round, err := o.checkQuestions(ctx, sess, nil, reply)
+	if err != nil || round != 1 || questions != "1. Which provider?" {
+		t.Fatalf("checkQuestions() = %q, %d, %v; want the questions of round 1", questions, round, err)
+	}
+	if _, round, err := o.checkQuestions(ctx, sess, &planRevision{number: 1}, reply); err == nil {
+		t.Errorf("checkQuestions() while revising = round %d, want an error", round)
+	}
+
+	if err := o.askQuestions(ctx, sess, &job.Job{ID: "job_1"}, questions, round); err != nil {
+		t.Fatalf("askQuestions() error = %v", err)
+	}
+	if len(posted) != 1 || !strings.Contains(posted[0], "1. Which provider?") {
+		t.Fatalf("posted comments = %q, want the questions", posted)
+	}
+
+	// The answer sends the session back to planning; without an issue to
+	// fetch, planning itself fails
+	o.Answer(ctx, sess.ID, "alice", 42, "GitHub")
+
+	c, err := o.loadClarifications(ctx, sess)
+	if err != nil {
+		t.Fatalf("loadClarifications() error = %v", err)
+	}
+	if len(c.rounds) != 1 || c.rounds[0].Answer != "GitHub" || c.rounds[0].User != "alice" {
+		t.Errorf("rounds = %+v, want one answered by alice", c.rounds)
+	}
+	if !c.answerIDs[42] || c.lastJobID != "job_1" {
+		t.Errorf("answer IDs = %v, last job = %q; want comment 42 and job_1", c.answerIDs, c.lastJobID)
+	}
+	if o.canAsk(c, nil) {
+		t.Error("canAsk() = true after the last allowed round")
+	}
+	if _, _, err := o.checkQuestions(ctx, sess, nil, reply); err == nil {
+		t.Error("checkQuestions() after the last round succeeded, want an error")
+	}
+}
@@ -0,0 +1,78 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// maxEventPatchBytes caps the patch stored with a job_diff event. A larger
+// patch is kept in full in a file (see config.DiffPath), and uploaded if
+// uploads are enabled.
+const maxEventPatchBytes = 64 << 10
+
+// recordJobDiff stores the job's diff as a session event, so it survives
+// job directory cleanup and can be shown with `manfred job diff`.
+func (o *Orchestrator) recordJobDiff(ctx context.Context, sess *session.Session, j *job.Job) {
+	if j.Diff == nil {
+		return
+	}
+	_, patch, err := job.LoadDiff(o.config.JobsDir, j.ID)
+	if err != nil {
+		return
+	}
+
+	truncated := len(patch) > maxEventPatchBytes
+	var patchFile, patchURL string
+	if truncated {
+		patchFile = o.config.DiffPath(j.ID)
+		if err := writePatchFile(patchFile, patch); err != nil {
+			log.Printf("session %s: failed to keep the diff of job %s: %v", sess.ID, j.ID, err)
+			patchFile = ""
+		}
+		patchURL = o.upload(ctx, sess, "job-"+j.ID+".patch", fmt.Sprintf("MANFRED job %s diff", j.ID), []byte(patch))
+		patch = truncatePatch(patch, maxEventPatchBytes)
+	}
+	o.recordEvent(ctx, sess.ID, session.EventTypeJobDiff, map[string]interface{}{
+		"job_id":        j.ID,
+		"base_sha":      j.Diff.BaseSHA,
+		"head_sha":      j.Diff.HeadSHA,
+		"files_changed": j.Diff.FilesChanged,
+		"insertions":    j.Diff.Insertions,
+		"deletions":     j.Diff.Deletions,
+		"patch":         patch,
+		"truncated":     truncated,
+		"patch_file":    patchFile,
+		"patch_url":     patchURL,
+	})
+}
+
+// writePatchFile writes patch to path, creating its directory.
+func writePatchFile(path, patch string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(patch), 0644)
+}
+
+// truncatePatch cuts patch to at most max bytes at the last file or hunk
+// boundary that fits, so what is kept still applies. A single hunk larger
+// than max is cut at a line boundary instead.
+func truncatePatch(patch string, max int) string {
+	if len(patch) <= max {
+		return patch
+	}
+	head := patch[:max]
+	for _, boundary := range []string{"\ndiff --git ", "\n@@ "} {
+		if i := strings.LastIndex(head, boundary); i > 0 {
+			return head[:i+1]
+		}
+	}
+	return head[:strings.LastIndexByte(head, '\n')+1]
+}
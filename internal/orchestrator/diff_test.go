@@ -0,0 +1,36 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTruncatePatch(t *testing.T) {
+	fileA := "diff --git a/a b/a\n--- a/a\n+++ b/a\n@@ -1 +1 @@\n-one\n+two\n"
+	hunk := "@@ -9 +9 @@\n-nine\n+ten\n"
+	fileB := "diff --git a/b b/b\n--- a/b\n+++ b/b\n@@ -1 +1 @@\n-x\n+y\n"
+	patch := fileA + hunk + fileB
+
+	tests := []struct {
+		name string
+		max  int
+		want string
+	}{
+		{"fits", len(patch), patch},
+		{"file boundary", len(patch) - 1, fileA + hunk},
+		{"hunk boundary", len(fileA) + len(hunk) - 1, fileA},
+		{"line boundary", len("diff --git a/a b/a\n--- a/a\n") + 3, "diff --git a/a b/a\n--- a/a\n"},
+		{"no line fits", 5, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncatePatch(patch, tt.max)
+			if got != tt.want {
+				t.Errorf("truncatePatch(%d) = %q, want %q", tt.max, got, tt.want)
+			}
+			if len(got) > tt.max || !strings.HasPrefix(patch, got) {
+				t.Errorf("truncatePatch(%d) = %q, want a prefix of at most %d bytes", tt.max, got, tt.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/mpm/manfred/internal/audit"
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/notify"
+	"github.com/mpm/manfred/internal/prompt"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// Approve handles plan approval by sender. The session must be awaiting
+// approval; once the approvers the project's approval policy asks for have
+// approved, it moves to implementing, Claude implements the plan on the
+// session branch, and a pull request is opened. An empty sender (`manfred
+// session approve`) is not checked against the allowlists and approves
+// without waiting for the others. An API key (github.APISender) is one
+// approver, authorized by its scope.
+func (o *Orchestrator) Approve(ctx context.Context, sessionID, sender string) error {
+	sess, err := o.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if sess == nil {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	recorded := false
+	if sender != "" {
+		if err := o.authorizeSender(ctx, ActionApprove, sender, sess); err != nil {
+			return err
+		}
+		if needed := o.approvalPolicy(sess).Approvers; needed > 1 {
+			approvals, err := o.recordApproval(ctx, sessionID, sender)
+			if err != nil {
+				return err
+			}
+			if approvals < needed {
+				log.Printf("session %s: approved by %s, %d of %d approvals", sess.ID, sender, approvals, needed)
+				o.postComment(ctx, sess, sess.IssueNumber, github.FormatApprovalProgressComment(sess.ID, sender, approvals, needed))
+				return nil
+			}
+			recorded = true
+		}
+	}
+
+	sess, err = o.transition(ctx, sessionID, session.PhaseAwaitingApproval, session.PhaseImplementing)
+	if err != nil {
+		return err
+	}
+	o.audit.Record(ctx, audit.ActionSessionApprove, sess.ID, nil)
+	if !recorded {
+		o.recordEvent(ctx, sess.ID, session.EventTypeCommentReceived, map[string]string{
+			"source": "approval",
+			"user":   sender,
+		})
+	}
+
+	return o.runImplementation(ctx, sess)
+}
+
+// runImplementation implements the approved plan, pushes the session branch
+// and opens a pull request.
+func (o *Orchestrator) runImplementation(ctx context.Context, sess *session.Session) error {
+	projectName, projectConfig, err := o.config.FindProjectByRepo(sess.RepoOwner, sess.RepoName)
+	if err != nil {
+		return o.fail(ctx, sess, sess.IssueNumber, err)
+	}
+
+	issue, err := o.github.GetIssue(ctx, sess.RepoOwner, sess.RepoName, sess.IssueNumber)
+	if err != nil {
+		return o.fail(ctx, sess, sess.IssueNumber, fmt.Errorf("fetch issue: %w", err))
+	}
+
+	plan := ""
+	if sess.PlanContent != nil {
+		plan = *sess.PlanContent
+	}
+	taskPrompt, err := o.prompts.Build(session.PhaseImplementing, &prompt.Context{
+		Session: sess,
+		Issue:   issue,
+		Plan:    plan,
+	})
+	if err != nil {
+		return o.fail(ctx, sess, sess.IssueNumber, err)
+	}
+
+	log.Printf("session %s: implementing issue #%d", sess.ID, sess.IssueNumber)
+	j, err := o.runJob(ctx, sess, projectName, taskPrompt, job.RunOptions{
+		Branch:    sess.Branch,
+		NewBranch: true,
+		Push:      true,
+	})
+	if err != nil {
+		return o.fail(ctx, sess, sess.IssueNumber, err)
+	}
+	o.recordJobDiff(ctx, sess, j)
+	if j.Status != job.StatusCompleted {
+		return o.failJob(ctx, sess, sess.IssueNumber, j)
+	}
+	return o.finishImplementation(ctx, sess, projectName, projectConfig, issue, j)
+}
+
+// finishImplementation opens the pull request for the branch a completed
+// implementation job pushed, marks a draft ready when the job's tests
+// passed, and moves the session to in_review.
+func (o *Orchestrator) finishImplementation(ctx context.Context, sess *session.Session, projectName string, projectConfig *config.ProjectConfig, issue *github.Issue, j *job.Job) error {
+	if !j.Pushed {
+		return o.fail(ctx, sess, sess.IssueNumber, fmt.Errorf("job %s produced no commits", j.ID))
+	}
+
+	summary := j.CommitMessage
+	if summary == "" {
+		summary = fmt.Sprintf("Implements #%d.", sess.IssueNumber)
+	}
+	o.reportJobStatus(ctx, sess, j.HeadSHA, j, "Implementation pushed")
+	summary = o.linkIfLarge(ctx, sess, summary, "pr-summary.md", fmt.Sprintf("MANFRED summary for %s/%s#%d", sess.RepoOwner, sess.RepoName, sess.IssueNumber))
+
+	body, err := github.FormatPRDescription(o.config.PRTemplate(projectConfig), o.prDescription(ctx, sess, issue, j, summary))
+	if err != nil {
+		log.Printf("session %s: using the default pull request template: %v", sess.ID, err)
+	}
+
+	pr, err := o.createPullRequest(ctx, sess, projectConfig, &github.CreatePullRequestInput{
+		Title: issue.Title,
+		Body:  body,
+		Head:  sess.Branch,
+		Base:  projectConfig.DefaultBranch,
+	})
+	if err != nil {
+		return o.fail(ctx, sess, sess.IssueNumber, fmt.Errorf("create pull request: %w", err))
+	}
+	o.markReady(ctx, sess, pr, projectConfig, j)
+	return o.enterReview(ctx, sess, projectName, pr, j.ID, j.HeadSHA)
+}
+
+// prDescription collects what the pull request body of an implementation
+// job shows: the issue, the approved plan, the diff stat and the test run.
+func (o *Orchestrator) prDescription(ctx context.Context, sess *session.Session, issue *github.Issue, j *job.Job, summary string) *github.PRDescription {
+	d := &github.PRDescription{
+		SessionID:   sess.ID,
+		JobID:       j.ID,
+		IssueNumber: sess.IssueNumber,
+		IssueTitle:  issue.Title,
+		Summary:     summary,
+	}
+	if sess.PlanContent != nil {
+		d.Plan = o.linkIfLarge(ctx, sess, *sess.PlanContent, "plan.md", fmt.Sprintf("MANFRED plan for %s/%s#%d", sess.RepoOwner, sess.RepoName, sess.IssueNumber))
+	}
+	if j.Diff != nil {
+		d.Diff = &github.PRDiffStat{
+			FilesChanged: j.Diff.FilesChanged,
+			Insertions:   j.Diff.Insertions,
+			Deletions:    j.Diff.Deletions,
+		}
+	}
+	if t := j.TestResult; t != nil {
+		d.Tests = &github.PRTestResult{
+			Command:     t.Command,
+			Passed:      t.Passed,
+			ExitCode:    t.ExitCode,
+			Attempts:    t.Attempts,
+			FixAttempts: t.FixAttempts,
+			Output:      t.Output,
+		}
+	}
+	return d
+}
+
+// enterReview records the session's pull request of the branch the job
+// pushed up to headSHA, moves the session to in_review and announces the
+// pull request on the issue.
+func (o *Orchestrator) enterReview(ctx context.Context, sess *session.Session, projectName string, pr *github.PullRequest, jobID, headSHA string) error {
+	sess.SetPRNumber(pr.Number)
+	sess.SetHeadSHA(headSHA)
+	if err := sess.TransitionTo(session.PhaseInReview); err != nil {
+		return err
+	}
+	if err := o.sessions.Update(ctx, sess); err != nil {
+		return err
+	}
+	o.recordEvent(ctx, sess.ID, session.EventTypePRCreated, map[string]interface{}{
+		"number": pr.Number,
+		"url":    pr.HTMLURL,
+		"job_id": jobID,
+	})
+	o.recordEvent(ctx, sess.ID, session.EventTypePhaseChange, map[string]string{
+		"from":   string(session.PhaseImplementing),
+		"to":     string(session.PhaseInReview),
+		"job_id": jobID,
+	})
+
+	o.postComment(ctx, sess, sess.IssueNumber, github.FormatPRCreatedComment(sess.ID, pr.Number))
+
+	n := sessionNotification(sess, notify.EventPRCreated, projectName)
+	n.PRNumber, n.JobID, n.Title, n.URL = pr.Number, jobID, pr.Title, pr.HTMLURL
+	o.notify(ctx, n)
+	return nil
+}
@@ -0,0 +1,171 @@
+package orchestrator
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// statusLabels maps phases to the status label shown on the session's
+// issue and PR. Phases that wait on a person share manfred:blocked; ended
+// sessions carry no status label.
+var statusLabels = map[session.Phase]string{
+	session.PhasePlanning:         "manfred:planning",
+	session.PhaseAwaitingAnswers:  "manfred:awaiting-answers",
+	session.PhaseAwaitingApproval: "manfred:awaiting-approval",
+	session.PhaseImplementing:     "manfred:implementing",
+	session.PhaseInReview:         "manfred:in-review",
+	session.PhaseRevising:         "manfred:revising",
+	session.PhasePaused:           "manfred:blocked",
+	session.PhaseError:            "manfred:blocked",
+	session.PhaseTriaging:         "manfred:triaging",
+}
+
+// isStatusLabel reports whether name is one of the status labels.
+func isStatusLabel(name string) bool {
+	for _, l := range statusLabels {
+		if l == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isManfredLabel reports whether name is a trigger, triage or status label.
+func (o *Orchestrator) isManfredLabel(name string) bool {
+	if isStatusLabel(name) {
+		return true
+	}
+	for _, labels := range [][]string{o.config.Triggers.Labels, o.config.Triggers.TriageLabels} {
+		for _, l := range labels {
+			if strings.EqualFold(l, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// defaultLabels are the colors and descriptions of the labels MANFRED uses,
+// before github.labels.definitions.
+var defaultLabels = []github.Label{
+	{Name: "manfred:planning", Color: "c5def5", Description: "MANFRED is writing a plan"},
+	{Name: "manfred:awaiting-answers", Color: "d4c5f9", Description: "Clarifying questions waiting for answers"},
+	{Name: "manfred:awaiting-approval", Color: "fbca04", Description: "Plan waiting for approval"},
+	{Name: "manfred:implementing", Color: "1d76db", Description: "MANFRED is implementing the plan"},
+	{Name: "manfred:in-review", Color: "0e8a16", Description: "Pull request waiting for review"},
+	{Name: "manfred:revising", Color: "5319e7", Description: "MANFRED is addressing review feedback"},
+	{Name: "manfred:blocked", Color: "b60205", Description: "Session needs attention: paused or failed"},
+	{Name: "manfred:triaging", Color: "bfdadc", Description: "MANFRED is triaging the issue"},
+}
+
+// Labels returns the labels MANFRED uses on a repository: the trigger and
+// triage labels and the status labels, with colors and descriptions from
+// github.labels.definitions where set.
+func Labels(cfg *config.Config) []github.Label {
+	var labels []github.Label
+	for _, name := range cfg.Triggers.Labels {
+		labels = append(labels, github.Label{Name: name, Color: "7057ff", Description: "Start a MANFRED session"})
+	}
+	for _, name := range cfg.Triggers.TriageLabels {
+		labels = append(labels, github.Label{Name: name, Color: "d4c5f9", Description: "Triage with MANFRED, without coding"})
+	}
+	labels = append(labels, defaultLabels...)
+
+	for i, l := range labels {
+		def, ok := cfg.GitHub.Labels.Definitions[strings.ToLower(l.Name)]
+		if !ok {
+			continue
+		}
+		if def.Color != "" {
+			labels[i].Color = strings.TrimPrefix(def.Color, "#")
+		}
+		if def.Description != "" {
+			labels[i].Description = def.Description
+		}
+	}
+	return labels
+}
+
+// syncStatusLabels updates a session's status labels after a phase change,
+// if github.labels.status is set. Phase changes happen with o.mu held, so
+// the GitHub calls run in the background. One session's syncs run one at a
+// time; a phase change during a sync makes it run again, with the session
+// loaded anew.
+func (o *Orchestrator) syncStatusLabels(ctx context.Context, sessionID string) {
+	if !o.config.GitHub.Labels.Status {
+		return
+	}
+	o.labelsMu.Lock()
+	defer o.labelsMu.Unlock()
+	if _, running := o.labelSyncs[sessionID]; running {
+		o.labelSyncs[sessionID] = true
+		return
+	}
+	o.labelSyncs[sessionID] = false
+	go o.runLabelSync(context.WithoutCancel(ctx), sessionID)
+}
+
+// runLabelSync syncs a session's status labels until no further pass was
+// asked for.
+func (o *Orchestrator) runLabelSync(ctx context.Context, sessionID string) {
+	for {
+		sess, err := o.sessions.Get(ctx, sessionID)
+		if err != nil || sess == nil {
+			log.Printf("session %s: failed to load session for status label: %v", sessionID, err)
+		} else {
+			o.syncStatusLabel(ctx, sess)
+		}
+
+		o.labelsMu.Lock()
+		again := o.labelSyncs[sessionID]
+		if again {
+			o.labelSyncs[sessionID] = false
+		} else {
+			delete(o.labelSyncs, sessionID)
+		}
+		o.labelsMu.Unlock()
+		if !again {
+			return
+		}
+	}
+}
+
+// syncStatusLabel puts the status label of the session's phase on its issue
+// and PR and removes other status labels. Failures are logged.
+func (o *Orchestrator) syncStatusLabel(ctx context.Context, sess *session.Session) {
+	want := statusLabels[sess.Phase]
+	numbers := []int{sess.IssueNumber}
+	if sess.PRNumber != nil {
+		numbers = append(numbers, *sess.PRNumber)
+	}
+
+	for _, number := range numbers {
+		labels, err := o.github.ListIssueLabels(ctx, sess.RepoOwner, sess.RepoName, number)
+		if err != nil {
+			log.Printf("session %s: failed to list labels of #%d: %v", sess.ID, number, err)
+			continue
+		}
+
+		present := false
+		for _, l := range labels {
+			switch {
+			case l.Name == want:
+				present = true
+			case isStatusLabel(l.Name):
+				if err := o.github.RemoveLabel(ctx, sess.RepoOwner, sess.RepoName, number, l.Name); err != nil {
+					log.Printf("session %s: failed to remove label %s from #%d: %v", sess.ID, l.Name, number, err)
+				}
+			}
+		}
+		if want != "" && !present {
+			if err := o.github.AddLabel(ctx, sess.RepoOwner, sess.RepoName, number, want); err != nil {
+				log.Printf("session %s: failed to add label %s to #%d: %v", sess.ID, want, number, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/store"
+)
+
+func TestSyncStatusLabel(t *testing.T) {
+	labels := map[string][]github.Label{
+		"/repos/acme/widgets/issues/7/labels": {{Name: "manfred"}, {Name: "manfred:planning"}, {Name: "manfred:skip-ci"}},
+		"/repos/acme/widgets/issues/9/labels": {{Name: "manfred:blocked"}},
+	}
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			json.NewEncoder(w).Encode(labels[r.URL.Path])
+			return
+		}
+		req := r.Method + " " + r.URL.Path
+		if r.Method == http.MethodPost {
+			var names []string
+			json.NewDecoder(r.Body).Decode(&names)
+			req += " " + names[0]
+		}
+		requests = append(requests, req)
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	o := New(&config.Config{}, nil, github.NewClient("token", github.WithBaseURL(server.URL)))
+	sess := session.NewSession("acme", "widgets", 7)
+	sess.Phase = session.PhaseAwaitingApproval
+	pr := 9
+	sess.PRNumber = &pr
+	o.syncStatusLabel(context.Background(), sess)
+
+	want := []string{
+		"DELETE /repos/acme/widgets/issues/7/labels/manfred:planning",
+		"POST /repos/acme/widgets/issues/7/labels manfred:awaiting-approval",
+		"DELETE /repos/acme/widgets/issues/9/labels/manfred:blocked",
+		"POST /repos/acme/widgets/issues/9/labels manfred:awaiting-approval",
+	}
+	if len(requests) != len(want) {
+		t.Fatalf("requests = %v, want %v", requests, want)
+	}
+	for i := range want {
+		if requests[i] != want[i] {
+			t.Errorf("request %d = %q, want %q", i, requests[i], want[i])
+		}
+	}
+}
+
+func TestSyncStatusLabelsInBackground(t *testing.T) {
+	release := make(chan struct{})
+	var (
+		mu    sync.Mutex
+		added []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			<-release // A slow GitHub
+			w.Write([]byte("[]"))
+			return
+		}
+		var names []string
+		json.NewDecoder(r.Body).Decode(&names)
+		mu.Lock()
+		added = append(added, names...)
+		mu.Unlock()
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	db, err := store.OpenInMemory()
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	sessions := session.NewSQLStore(db)
+	sess := session.NewSession("acme", "widgets", 7)
+	sess.Phase = session.PhasePlanning
+	if err := sessions.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	cfg := &config.Config{GitHub: config.GitHubConfig{Labels: config.LabelsConfig{Status: true}}}
+	o := New(cfg, sessions, github.NewClient("token", github.WithBaseURL(server.URL)))
+
+	// Transitions do not wait for GitHub
+	done := make(chan error)
+	go func() {
+		if _, err := o.transition(ctx, sess.ID, session.PhasePlanning, session.PhaseAwaitingApproval); err != nil {
+			done <- err
+			return
+		}
+		_, err := o.transition(ctx, sess.ID, session.PhaseAwaitingApproval, session.PhaseImplementing)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("transition() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("transition() waited for the label sync")
+	}
+	close(release)
+
+	// The second phase change made the sync run again for the latest phase
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		got := slices.Clone(added)
+		mu.Unlock()
+		if slices.Contains(got, "manfred:implementing") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("labels added = %v, want manfred:implementing", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	cfg := &config.Config{
+		Triggers: config.TriggersConfig{Labels: []string{"manfred"}},
+		GitHub: config.GitHubConfig{Labels: config.LabelsConfig{Definitions: map[string]config.LabelConfig{
+			"manfred:blocked": {Color: "#000000"},
+			"manfred":         {Description: "Hand to the robot"},
+		}}},
+	}
+
+	labels := Labels(cfg)
+	if len(labels) != 1+len(defaultLabels) {
+		t.Fatalf("Labels() returned %d labels, want %d", len(labels), 1+len(defaultLabels))
+	}
+	byName := map[string]github.Label{}
+	for _, l := range labels {
+		byName[l.Name] = l
+	}
+	if got := byName["manfred"]; got.Description != "Hand to the robot" || got.Color == "" {
+		t.Errorf("trigger label = %+v, want configured description and default color", got)
+	}
+	if got := byName["manfred:blocked"]; got.Color != "000000" {
+		t.Errorf("manfred:blocked color = %q, want 000000", got.Color)
+	}
+}
@@ -0,0 +1,95 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// HandleMerge completes a session whose PR was merged and performs the
+// configured post-merge housekeeping. Housekeeping failures are logged but
+// do not affect the session, which is already completed. A paused session
+// is completed as well, since the merge ends it either way, and a revising
+// one has its revision job canceled.
+func (o *Orchestrator) HandleMerge(ctx context.Context, sessionID string, pr *github.PullRequest) error {
+	return o.completeMerge(ctx, sessionID, pr, "")
+}
+
+// completeMerge is HandleMerge for a PR MANFRED merged itself with method,
+// or someone else if method is empty. The issue always hears about merges
+// MANFRED made.
+func (o *Orchestrator) completeMerge(ctx context.Context, sessionID string, pr *github.PullRequest, method string) error {
+	sess, err := o.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if sess == nil {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	from := session.PhaseInReview
+	if sess.Phase == session.PhasePaused || sess.Phase == session.PhaseRevising {
+		from = sess.Phase
+	}
+	sess, err = o.transition(ctx, sessionID, from, session.PhaseCompleted)
+	if err != nil {
+		return err
+	}
+	if from == session.PhaseRevising && o.cancelJob(sess.ID) {
+		log.Printf("session %s: canceled the revision of merged PR #%d", sess.ID, pr.Number)
+	}
+	log.Printf("session %s: PR #%d merged, session completed", sess.ID, pr.Number)
+
+	cfg := o.config.PostMerge
+
+	if method != "" {
+		o.postComment(ctx, sess, sess.IssueNumber, github.FormatAutoMergedComment(sess.ID, pr.Number, pr.Title, method, cfg.CloseIssue))
+	} else if cfg.CloseIssue {
+		o.postComment(ctx, sess, sess.IssueNumber, github.FormatCompletedComment(sess.ID, pr.Number, pr.Title))
+	}
+	if cfg.CloseIssue {
+		if err := o.github.CloseIssue(ctx, sess.RepoOwner, sess.RepoName, sess.IssueNumber); err != nil {
+			log.Printf("session %s: failed to close issue #%d: %v", sess.ID, sess.IssueNumber, err)
+		}
+	}
+
+	if cfg.RemoveLabel {
+		o.removeTriggerLabels(ctx, sess)
+	}
+
+	if cfg.DeleteBranch {
+		branch := pr.Head.Ref
+		if branch == "" {
+			branch = sess.Branch
+		}
+		if err := o.github.DeleteBranch(ctx, sess.RepoOwner, sess.RepoName, branch); err != nil {
+			log.Printf("session %s: failed to delete branch %s: %v", sess.ID, branch, err)
+		}
+	}
+
+	return nil
+}
+
+// removeTriggerLabels removes every configured trigger label from the
+// session's issue.
+func (o *Orchestrator) removeTriggerLabels(ctx context.Context, sess *session.Session) {
+	labels, err := o.github.ListIssueLabels(ctx, sess.RepoOwner, sess.RepoName, sess.IssueNumber)
+	if err != nil {
+		log.Printf("session %s: failed to list labels: %v", sess.ID, err)
+		return
+	}
+
+	for _, l := range labels {
+		for _, trigger := range o.config.Triggers.Labels {
+			if !strings.EqualFold(l.Name, trigger) {
+				continue
+			}
+			if err := o.github.RemoveLabel(ctx, sess.RepoOwner, sess.RepoName, sess.IssueNumber, l.Name); err != nil {
+				log.Printf("session %s: failed to remove label %s: %v", sess.ID, l.Name, err)
+			}
+		}
+	}
+}
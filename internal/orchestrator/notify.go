@@ -0,0 +1,29 @@
+package orchestrator
+
+import (
+	"context"
+	"log"
+
+	"github.com/mpm/manfred/internal/notify"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// sessionNotification returns a notification about the session's issue in
+// the given project.
+func sessionNotification(sess *session.Session, event notify.Event, projectName string) notify.Notification {
+	return notify.Notification{
+		Event:       event,
+		Project:     projectName,
+		Repo:        sess.RepoOwner + "/" + sess.RepoName,
+		IssueNumber: sess.IssueNumber,
+		SessionID:   sess.ID,
+	}
+}
+
+// notify sends a notification. Failures are logged and do not affect the
+// session.
+func (o *Orchestrator) notify(ctx context.Context, n notify.Notification) {
+	if err := o.notifier.Notify(context.WithoutCancel(ctx), n); err != nil {
+		log.Printf("session %s: %v", n.SessionID, err)
+	}
+}
@@ -0,0 +1,385 @@
+// Package orchestrator drives GitHub sessions through their workflow phases
+// by running jobs and reporting results back to GitHub.
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/mpm/manfred/internal/anthropic"
+	"github.com/mpm/manfred/internal/audit"
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/notify"
+	"github.com/mpm/manfred/internal/prompt"
+	"github.com/mpm/manfred/internal/queue"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/upload"
+)
+
+// Orchestrator coordinates session phase handlers.
+type Orchestrator struct {
+	config   *config.Config
+	sessions session.Store
+	github   *github.Client
+	comments *github.Commenter
+	prompts  *prompt.Builder
+	queue    *queue.Queue
+	notifier *notify.Notifier
+	uploads  *upload.Uploader   // nil unless uploads.target is set
+	health   *anthropic.Breaker // nil unless job.health is enabled
+	audit    *audit.Log         // nil unless set by SetAudit
+
+	// mu serializes phase transitions so concurrent webhooks cannot start
+	// the same phase twice.
+	mu sync.Mutex
+
+	// merging holds the IDs of sessions whose pull request is being
+	// auto-merged, guarded by mu.
+	merging map[string]bool
+
+	// cancels holds the cancel functions of running jobs by session ID,
+	// for aborting sessions; hubs streams their logs by job ID.
+	jobsMu  sync.Mutex
+	cancels map[string]context.CancelFunc
+	hubs    map[string]*job.Hub
+
+	// labelSyncs holds the IDs of sessions whose status labels are being
+	// synced in the background, true when a later phase change asked for
+	// another pass.
+	labelsMu   sync.Mutex
+	labelSyncs map[string]bool
+}
+
+// New creates a new orchestrator.
+func New(cfg *config.Config, sessions session.Store, gh *github.Client) *Orchestrator {
+	notifier, err := notify.New(cfg.Notify)
+	if err != nil {
+		log.Printf("notify: %v", err)
+	}
+	comments := github.NewCommenter(gh, cfg.GitHub.CommentInterval, cfg.GitHub.CommentCoalesceWindow)
+	uploads, err := upload.New(cfg, gh)
+	if err != nil {
+		log.Printf("uploads: %v", err)
+	}
+	if uploads != nil {
+		comments.SetUploader(uploads, uploads.Threshold())
+	}
+	return &Orchestrator{
+		config:   cfg,
+		sessions: sessions,
+		github:   gh,
+		comments: comments,
+		prompts:  prompt.NewBuilder(),
+		queue:    queue.New(cfg.Queue.MaxConcurrent),
+		notifier: notifier,
+		uploads:  uploads,
+		health:   job.NewHealthCheck(cfg),
+		cancels:  make(map[string]context.CancelFunc),
+		hubs:     make(map[string]*job.Hub),
+		merging:  make(map[string]bool),
+
+		labelSyncs: make(map[string]bool),
+	}
+}
+
+// SetAudit records approvals, aborts, retries and phase transitions in l,
+// attributed to the actor of the context they happen in.
+func (o *Orchestrator) SetAudit(l *audit.Log) {
+	o.audit = l
+}
+
+// Uploads returns the uploader for large outputs, or nil if uploads are
+// disabled.
+func (o *Orchestrator) Uploads() *upload.Uploader {
+	return o.uploads
+}
+
+// Close waits for notifications still being sent. The orchestrator must
+// not run jobs afterwards.
+func (o *Orchestrator) Close() error {
+	return o.notifier.Close()
+}
+
+// Queue returns the queue jobs wait in for a free slot.
+func (o *Orchestrator) Queue() *queue.Queue {
+	return o.queue
+}
+
+// JobLog returns a handler streaming the log of a job running in this
+// process as server-sent events, or nil if there is no such job.
+func (o *Orchestrator) JobLog(jobID string) http.Handler {
+	o.jobsMu.Lock()
+	defer o.jobsMu.Unlock()
+	if hub, ok := o.hubs[jobID]; ok {
+		return hub
+	}
+	return nil
+}
+
+// runJob waits until the Anthropic API is available and a job slot is free,
+// then runs a job of the session for the project. The job is recorded as
+// container_start and container_stop events and in the session's execution
+// state, can be canceled by Abort and its log followed through JobLog.
+func (o *Orchestrator) runJob(ctx context.Context, sess *session.Session, projectName, taskPrompt string, opts job.RunOptions) (*job.Job, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	o.jobsMu.Lock()
+	o.cancels[sess.ID] = cancel
+	o.jobsMu.Unlock()
+	defer func() {
+		o.jobsMu.Lock()
+		delete(o.cancels, sess.ID)
+		o.jobsMu.Unlock()
+	}()
+	o.setExecution(ctx, sess, session.Execution{Step: session.StepQueued})
+
+	// Hold the job while the Anthropic API is down instead of failing it
+	if o.health != nil {
+		if err := o.health.Check(ctx); err != nil {
+			log.Printf("session %s: holding job until the anthropic API is back: %v", sess.ID, err)
+			if err := o.health.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	release, err := o.queue.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wait for a job slot: %w", err)
+	}
+	defer release()
+
+	runner, err := job.NewRunner(o.config, job.WithNotifier(o.notifier))
+	if err != nil {
+		return nil, err
+	}
+	defer runner.Close()
+
+	hub := job.NewHub()
+	var jobID string
+	defer func() {
+		o.jobsMu.Lock()
+		delete(o.hubs, jobID)
+		o.jobsMu.Unlock()
+	}()
+
+	opts.Notify = sessionNotification(sess, "", projectName)
+	opts.Health = o.health
+	opts.LogSinks = append(opts.LogSinks, hub)
+	opts.OnStart = func(j *job.Job) {
+		o.jobsMu.Lock()
+		jobID = j.ID
+		o.hubs[j.ID] = hub
+		o.jobsMu.Unlock()
+		o.setExecution(ctx, sess, session.Execution{
+			Step:           session.StepJob,
+			JobID:          j.ID,
+			ComposeProject: job.ComposeProjectName(j.ID),
+		})
+		o.recordEvent(ctx, sess.ID, session.EventTypeContainerStart, map[string]string{
+			"job_id":          j.ID,
+			"compose_project": job.ComposeProjectName(j.ID),
+		})
+	}
+	j, err := runner.RunWithOptions(ctx, projectName, taskPrompt, opts)
+	if j != nil {
+		o.setExecution(context.WithoutCancel(ctx), sess, session.Execution{Step: session.StepFinishing, JobID: j.ID})
+		payload := map[string]interface{}{
+			"job_id": j.ID,
+		}
+		if u := j.Resources; u != nil {
+			payload["peak_cpu_percent"] = u.PeakCPUPercent
+			payload["peak_memory_bytes"] = u.PeakMemoryBytes
+			payload["memory_limit"] = u.MemoryLimit
+		}
+		o.recordEvent(context.WithoutCancel(ctx), sess.ID, session.EventTypeContainerStop, payload)
+	}
+	return j, err
+}
+
+// cancelJob cancels the running job of a session in this process, if any.
+func (o *Orchestrator) cancelJob(sessionID string) bool {
+	o.jobsMu.Lock()
+	defer o.jobsMu.Unlock()
+	cancel, ok := o.cancels[sessionID]
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// transition loads a session, moves it to the target phase and persists the
+// change. It fails if the session is not in the expected phase.
+func (o *Orchestrator) transition(ctx context.Context, sessionID string, from, to session.Phase) (*session.Session, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	sess, err := o.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if sess == nil {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+	if sess.Phase != from {
+		return nil, &session.TransitionError{From: sess.Phase, To: to}
+	}
+
+	if err := sess.TransitionTo(to); err != nil {
+		return nil, err
+	}
+	if err := o.sessions.Update(ctx, sess); err != nil {
+		return nil, err
+	}
+	o.recordEvent(ctx, sess.ID, session.EventTypePhaseChange, map[string]string{
+		"from": string(from),
+		"to":   string(to),
+	})
+
+	return sess, nil
+}
+
+// SetPhase moves a session to phase without running anything, for
+// operators driving or rescuing a session with `manfred session set-phase`.
+// The transition must be valid from the session's current phase; aborting
+// goes through Abort, which also cleans up. Leaving the error phase clears
+// the session's error. It returns the phase the session left.
+func (o *Orchestrator) SetPhase(ctx context.Context, sessionID string, to session.Phase) (session.Phase, error) {
+	if to == session.PhaseAborted {
+		return "", fmt.Errorf("use abort to move a session to %s", to)
+	}
+
+	o.mu.Lock()
+	sess, err := o.sessions.Get(ctx, sessionID)
+	if err != nil {
+		o.mu.Unlock()
+		return "", err
+	}
+	if sess == nil {
+		o.mu.Unlock()
+		return "", fmt.Errorf("session not found: %s", sessionID)
+	}
+	from := sess.Phase
+	if err := sess.TransitionTo(to); err != nil {
+		o.mu.Unlock()
+		return "", err
+	}
+	if from == session.PhaseError {
+		sess.ErrorMessage = nil
+	}
+	err = o.sessions.Update(ctx, sess)
+	o.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	o.recordEvent(ctx, sess.ID, session.EventTypePhaseChange, map[string]string{
+		"from":   string(from),
+		"to":     string(to),
+		"source": "set-phase",
+	})
+	log.Printf("session %s: phase set from %s to %s", sess.ID, from, to)
+	return from, nil
+}
+
+// fail moves a session to the error phase and reports the error on GitHub.
+// number is the issue or PR the error comment is posted on, which links
+// links. Errors of sessions aborted meanwhile, typically their canceled job,
+// are only logged.
+func (o *Orchestrator) fail(ctx context.Context, sess *session.Session, number int, cause error, links ...github.OutputLink) error {
+	return o.failWithComment(ctx, sess, number, cause, github.FormatErrorComment(sess.ID, string(sess.Phase), cause.Error(), links...))
+}
+
+// failWithComment is fail with body as the error comment.
+func (o *Orchestrator) failWithComment(ctx context.Context, sess *session.Session, number int, cause error, body string) error {
+	phase := sess.Phase
+	sess.SetError(cause.Error())
+	if err := o.sessions.Update(ctx, sess); err != nil {
+		var transitionErr *session.TransitionError
+		if errors.As(err, &transitionErr) && (transitionErr.From == session.PhaseAborted || transitionErr.From == session.PhaseCompleted) {
+			log.Printf("session %s: %s while %s: %v", sess.ID, transitionErr.From, phase, cause)
+			return cause
+		}
+		log.Printf("session %s: failed to persist error: %v", sess.ID, err)
+	}
+	o.recordEvent(ctx, sess.ID, session.EventTypeError, map[string]string{
+		"phase": string(phase),
+		"error": cause.Error(),
+	})
+
+	if sess.HeadSHA != nil {
+		o.reportStatus(ctx, sess, *sess.HeadSHA, statusContextJob, github.StatusError, fmt.Sprintf("Failed while %s", phase))
+	}
+
+	o.postStatusComment(ctx, sess, number, body)
+
+	return cause
+}
+
+// postComment posts a comment on an issue or PR and records it. Comments go
+// through the rate-limited commenter, which splits long bodies like plans
+// into several comments.
+func (o *Orchestrator) postComment(ctx context.Context, sess *session.Session, number int, body string) {
+	comment, err := o.comments.Post(ctx, sess.RepoOwner, sess.RepoName, number, body)
+	o.recordComment(ctx, sess, number, comment, err)
+}
+
+// postStatusComment posts a status comment, like an error or a revision
+// summary, which may be merged into a recent status comment with the same
+// marker. With uploads.target, a long one is truncated with a link to the
+// full text instead of being split.
+func (o *Orchestrator) postStatusComment(ctx context.Context, sess *session.Session, number int, body string) {
+	comment, err := o.comments.PostStatus(ctx, sess.RepoOwner, sess.RepoName, number, body)
+	o.recordComment(ctx, sess, number, comment, err)
+}
+
+// recordComment logs a failed comment and records a posted one. A comment
+// split into parts may be posted in part and still fail.
+func (o *Orchestrator) recordComment(ctx context.Context, sess *session.Session, number int, comment *github.Comment, err error) {
+	if err != nil {
+		log.Printf("session %s: failed to post comment: %v", sess.ID, err)
+	}
+	if comment == nil {
+		return
+	}
+	o.recordEvent(ctx, sess.ID, session.EventTypeCommentPosted, map[string]interface{}{
+		"number":     number,
+		"comment_id": comment.ID,
+	})
+}
+
+// recordEvent records a session event, logging failures instead of aborting.
+// Phase changes and errors, which move the session to the error phase, also
+// update the session's status label.
+func (o *Orchestrator) recordEvent(ctx context.Context, sessionID string, eventType session.EventType, payload interface{}) {
+	if err := o.sessions.RecordEvent(ctx, sessionID, eventType, payload); err != nil {
+		log.Printf("session %s: failed to record %s event: %v", sessionID, eventType, err)
+	}
+	if eventType == session.EventTypePhaseChange || eventType == session.EventTypeError {
+		o.auditPhaseChange(ctx, sessionID, eventType, payload)
+		o.syncStatusLabels(ctx, sessionID)
+	}
+}
+
+// auditPhaseChange records the phase change of a phase_change or error
+// event in the audit log, with the event's payload as details.
+func (o *Orchestrator) auditPhaseChange(ctx context.Context, sessionID string, eventType session.EventType, payload interface{}) {
+	details := map[string]string{}
+	if fields, ok := payload.(map[string]string); ok {
+		for k, v := range fields {
+			details[k] = v
+		}
+	}
+	if eventType == session.EventTypeError {
+		details["from"] = details["phase"]
+		details["to"] = string(session.PhaseError)
+		delete(details, "phase")
+	}
+	o.audit.Record(ctx, audit.ActionSessionPhaseChange, sessionID, details)
+}
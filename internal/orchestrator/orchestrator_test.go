@@ -0,0 +1,164 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/store"
+)
+
+func TestSetPhase(t *testing.T) {
+	db, err := store.OpenInMemory()
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	sessions := session.NewSQLStore(db)
+
+	sess := session.NewSession("acme", "widgets", 7)
+	sess.SetError("planning failed")
+	if err := sessions.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	o := New(&config.Config{}, sessions, nil)
+
+	var transitionErr *session.TransitionError
+	if _, err := o.SetPhase(ctx, sess.ID, session.PhaseInReview); !errors.As(err, &transitionErr) {
+		t.Errorf("SetPhase(in_review) from error = %v, want a TransitionError", err)
+	}
+	if _, err := o.SetPhase(ctx, sess.ID, session.PhaseAborted); err == nil {
+		t.Error("SetPhase(aborted) succeeded, want an error pointing to abort")
+	}
+
+	from, err := o.SetPhase(ctx, sess.ID, session.PhasePlanning)
+	if err != nil {
+		t.Fatalf("SetPhase(planning) error = %v", err)
+	}
+	if from != session.PhaseError {
+		t.Errorf("SetPhase() left %s, want %s", from, session.PhaseError)
+	}
+
+	got, err := sessions.Get(ctx, sess.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Phase != session.PhasePlanning || got.ErrorMessage != nil {
+		t.Errorf("session = %s with error %v, want planning without error", got.Phase, got.ErrorMessage)
+	}
+}
+
+func TestStaleSessions(t *testing.T) {
+	db, err := store.OpenInMemory()
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	sessions := session.NewSQLStore(db)
+
+	now := time.Now().UTC()
+	create := func(issue int, phase session.Phase, idle time.Duration) *session.Session {
+		sess := session.NewSession("acme", "widgets", issue)
+		sess.Phase = phase
+		sess.LastActivity = now.Add(-idle)
+		if err := sessions.Create(ctx, sess); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		return sess
+	}
+	stale := create(1, session.PhaseImplementing, 2*time.Hour)
+	create(2, session.PhaseImplementing, time.Minute)     // Recently active
+	create(3, session.PhaseAwaitingApproval, 2*time.Hour) // No job phase
+	running := create(4, session.PhaseRevising, 2*time.Hour)
+
+	o := New(&config.Config{}, sessions, nil)
+	o.cancels[running.ID] = func() {} // Its job runs in this process
+
+	got, err := o.staleSessions(ctx, time.Hour, now)
+	if err != nil {
+		t.Fatalf("staleSessions() error = %v", err)
+	}
+	if len(got) != 1 || got[0].ID != stale.ID {
+		var ids []string
+		for _, s := range got {
+			ids = append(ids, s.ID)
+		}
+		t.Errorf("staleSessions() = %v, want [%s]", ids, stale.ID)
+	}
+}
+
+func TestResumeHelpers(t *testing.T) {
+	event := func(eventType session.EventType, payload string) session.SessionEvent {
+		return session.SessionEvent{EventType: eventType, Payload: payload}
+	}
+	events := []session.SessionEvent{
+		event(session.EventTypePhaseChange, `{"to":"planning"}`),
+		event(session.EventTypeResumed, `{"phase":"planning"}`),
+		event(session.EventTypePhaseChange, `{"from":"planning","to":"awaiting_approval"}`),
+		event(session.EventTypePhaseChange, `{"from":"awaiting_approval","to":"planning"}`),
+		event(session.EventTypeCommentReceived, `{"source":"revise_plan","feedback":"Use the v2 API"}`),
+		event(session.EventTypeResumed, `{"phase":"planning"}`),
+	}
+
+	if n := resumeAttempts(events, session.PhasePlanning); n != 1 {
+		t.Errorf("resumeAttempts() = %d, want 1 since the phase was last entered", n)
+	}
+
+	sess := session.NewSession("acme", "widgets", 7)
+	previous := "# Plan v1"
+	sess.PlanContent = &previous
+	rev := interruptedPlanRevision(sess, events)
+	if rev == nil || rev.number != 1 || rev.feedback != "Use the v2 API" || rev.previous != previous {
+		t.Errorf("interruptedPlanRevision() = %+v, want revision 1 of the previous plan", rev)
+	}
+	if rev := interruptedPlanRevision(sess, events[:2]); rev != nil {
+		t.Errorf("interruptedPlanRevision(first planning) = %+v, want nil", rev)
+	}
+
+	events = append(events,
+		event(session.EventTypeCommentReceived, `{"source":"review","feedback":"Rename foo"}`),
+		event(session.EventTypeCommentReceived, `{"source":"ci","feedback":"FAIL TestLogin"}`),
+	)
+	if got, ci := lastRevisionFeedback(events); got != "FAIL TestLogin" || !ci {
+		t.Errorf("lastRevisionFeedback() = %q, %v, want the CI failure", got, ci)
+	}
+	if got, ci := lastRevisionFeedback(events[:len(events)-1]); got != "Rename foo" || ci {
+		t.Errorf("lastRevisionFeedback() = %q, %v, want the review feedback", got, ci)
+	}
+}
+
+func TestInterrupted(t *testing.T) {
+	o := New(&config.Config{}, nil, nil)
+	host, _ := os.Hostname()
+
+	tests := []struct {
+		name string
+		exec session.Execution
+		want bool
+	}{
+		{"other host", session.Execution{Step: session.StepJob, Host: host + "-other", PID: 1}, false},
+		{"this process", session.Execution{Step: session.StepJob, Host: host, PID: os.Getpid()}, false},
+		{"dead process", session.Execution{Step: session.StepJob, Host: host, PID: 1 << 30}, true},
+	}
+	for _, tt := range tests {
+		sess := session.NewSession("acme", "widgets", 7)
+		sess.Execution = tt.exec
+		got, err := o.interrupted(context.Background(), sess)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: interrupted() = %v, %v; want %v", tt.name, got, err, tt.want)
+		}
+	}
+}
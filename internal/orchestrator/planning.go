@@ -0,0 +1,325 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mpm/manfred/internal/audit"
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/notify"
+	"github.com/mpm/manfred/internal/prompt"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// StartSession creates a session for an issue and runs the planning phase.
+// sender is the GitHub user who triggered it; trigger describes how (e.g.
+// "label:manfred") and is recorded in the session history. A triaged
+// session of the issue moves on to planning; if a session in any other
+// phase exists for the issue, nothing happens.
+func (o *Orchestrator) StartSession(ctx context.Context, owner, repo string, issueNumber int, sender, trigger string) error {
+	sessionID := session.GenerateSessionID(owner, repo, issueNumber)
+	if err := o.authorize(ctx, ActionStart, sender, owner, repo, issueNumber, sessionID, string(session.PhasePlanning)); err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	existing, err := o.sessions.GetByIssue(ctx, owner, repo, issueNumber)
+	if err != nil {
+		o.mu.Unlock()
+		return err
+	}
+	if existing != nil && existing.Phase != session.PhaseTriaged {
+		o.mu.Unlock()
+		log.Printf("session %s already exists (%s), ignoring trigger", existing.ID, existing.Phase)
+		return nil
+	}
+
+	payload := map[string]string{
+		"to":      string(session.PhasePlanning),
+		"trigger": trigger,
+		"user":    sender,
+	}
+	sess := existing
+	if sess == nil {
+		sess = session.NewSession(owner, repo, issueNumber)
+		err = o.sessions.Create(ctx, sess)
+	} else {
+		payload["from"] = string(sess.Phase)
+		if err = sess.TransitionTo(session.PhasePlanning); err == nil {
+			err = o.sessions.Update(ctx, sess)
+		}
+	}
+	o.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	o.recordEvent(ctx, sess.ID, session.EventTypePhaseChange, payload)
+
+	return o.runPlanning(ctx, sess, nil)
+}
+
+// Retry restarts planning for a session in the error phase, or the triage of
+// a triage-only session that failed. An empty sender (`manfred session
+// retry`) is not checked against the allowlists.
+func (o *Orchestrator) Retry(ctx context.Context, sessionID, sender string) error {
+	sess, err := o.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if sess == nil {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	if sender != "" {
+		if err := o.authorize(ctx, ActionRetry, sender, sess.RepoOwner, sess.RepoName, sess.IssueNumber, sess.ID, string(sess.Phase)); err != nil {
+			return err
+		}
+	}
+
+	triage, err := o.failedTriaging(ctx, sess)
+	if err != nil {
+		return err
+	}
+	to := session.PhasePlanning
+	if triage {
+		to = session.PhaseTriaging
+	}
+	sess, err = o.transition(ctx, sessionID, session.PhaseError, to)
+	if err != nil {
+		return err
+	}
+	sess.ErrorMessage = nil
+	o.audit.Record(ctx, audit.ActionSessionRetry, sess.ID, nil)
+
+	if triage {
+		return o.runTriage(ctx, sess)
+	}
+	return o.runPlanning(ctx, sess, nil)
+}
+
+// planRevision is a plan sent back for changes.
+type planRevision struct {
+	number   int // Revisions of the session's plan so far, including this one
+	previous string
+	feedback string
+}
+
+// RevisePlan sends the plan of a session awaiting approval back to planning
+// with sender's feedback. Claude revises the previous plan, which is posted
+// again for approval. Whoever may approve a plan may request changes to it.
+func (o *Orchestrator) RevisePlan(ctx context.Context, sessionID, sender, feedback string) error {
+	sess, err := o.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if sess == nil {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	if err := o.authorize(ctx, ActionApprove, sender, sess.RepoOwner, sess.RepoName, sess.IssueNumber, sess.ID, string(sess.Phase)); err != nil {
+		return err
+	}
+
+	sess, err = o.transition(ctx, sessionID, session.PhaseAwaitingApproval, session.PhasePlanning)
+	if err != nil {
+		return err
+	}
+	o.recordEvent(ctx, sess.ID, session.EventTypeCommentReceived, map[string]string{
+		"source":   "revise_plan",
+		"user":     sender,
+		"feedback": feedback,
+	})
+
+	// sess was loaded before the transition was recorded, so its metrics
+	// do not count this revision yet
+	rev := &planRevision{number: sess.Metrics.PlanRevisions + 1, feedback: feedback}
+	if sess.PlanContent != nil {
+		rev.previous = *sess.PlanContent
+	}
+	return o.runPlanning(ctx, sess, rev)
+}
+
+// EditPlan replaces the plan of a session awaiting approval with plan, as
+// edited by sender, and posts it on the issue. Approval then implements the
+// edited plan. Whoever may approve a plan may edit it; an empty sender
+// (`manfred session edit-plan`) is not checked against the allowlists.
+func (o *Orchestrator) EditPlan(ctx context.Context, sessionID, sender, plan string) error {
+	plan = strings.TrimSpace(plan)
+	if plan == "" {
+		return fmt.Errorf("the edited plan is empty")
+	}
+	sess, err := o.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if sess == nil {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	if sender != "" {
+		if err := o.authorize(ctx, ActionApprove, sender, sess.RepoOwner, sess.RepoName, sess.IssueNumber, sess.ID, string(sess.Phase)); err != nil {
+			return err
+		}
+	}
+
+	sess, err = o.replacePlan(ctx, sessionID, plan)
+	if err != nil {
+		return err
+	}
+	o.recordEvent(ctx, sess.ID, session.EventTypePlanEdited, map[string]string{"user": sender})
+	o.audit.Record(ctx, audit.ActionSessionEditPlan, sess.ID, nil)
+
+	c := sess.PlanChecklist
+	approval := github.FormatApprovalChecklist(c.Risk, c.Size, c.Files, c.Migration)
+	o.postComment(ctx, sess, sess.IssueNumber, github.FormatEditedPlanComment(sess.ID, plan, approval, sender))
+	return nil
+}
+
+// replacePlan stores plan as the plan of a session still awaiting approval.
+func (o *Orchestrator) replacePlan(ctx context.Context, sessionID, plan string) (*session.Session, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	sess, err := o.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if sess == nil {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+	if sess.Phase != session.PhaseAwaitingApproval {
+		return nil, fmt.Errorf("session %s is %s; only plans awaiting approval can be edited", sessionID, sess.Phase)
+	}
+	sess.PlanContent = &plan
+	sess.LastActivity = time.Now().UTC()
+	if err := o.sessions.Update(ctx, sess); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// runPlanning asks Claude for an implementation plan, or a revision of the
+// previous one when rev is set, posts it on the issue and moves the session
+// to awaiting_approval.
+func (o *Orchestrator) runPlanning(ctx context.Context, sess *session.Session, rev *planRevision) error {
+	projectName, projectConfig, err := o.config.FindProjectByRepo(sess.RepoOwner, sess.RepoName)
+	if err != nil {
+		return o.fail(ctx, sess, sess.IssueNumber, err)
+	}
+
+	issue, err := o.github.GetIssue(ctx, sess.RepoOwner, sess.RepoName, sess.IssueNumber)
+	if err != nil {
+		return o.fail(ctx, sess, sess.IssueNumber, fmt.Errorf("fetch issue: %w", err))
+	}
+	comments, err := o.github.GetIssueComments(ctx, sess.RepoOwner, sess.RepoName, sess.IssueNumber)
+	if err != nil {
+		return o.fail(ctx, sess, sess.IssueNumber, fmt.Errorf("fetch comments: %w", err))
+	}
+
+	clarified, err := o.loadClarifications(ctx, sess)
+	if err != nil {
+		return o.fail(ctx, sess, sess.IssueNumber, err)
+	}
+
+	// Over the API, Claude's reply is the plan; there is no helper to call
+	outputHelper := job.ContainerOutputHelper
+	api := o.config.PlanningMode(projectConfig) == config.PlanningAPI
+	if api {
+		outputHelper = ""
+	}
+	promptCtx := &prompt.Context{
+		Session:        sess,
+		Issue:          issue,
+		Comments:       withoutAnswers(userComments(comments), clarified.answerIDs),
+		OutputHelper:   outputHelper,
+		Clarifications: clarified.rounds,
+		AskQuestions:   o.canAsk(clarified, rev),
+	}
+	if rev != nil {
+		promptCtx.PreviousPlan = rev.previous
+		promptCtx.Feedback = rev.feedback
+	}
+
+	// Answers continue the conversation that asked the questions, if its
+	// job still has it; otherwise Claude plans afresh with the answers
+	phase := session.PhasePlanning
+	opts := job.RunOptions{PlanOnly: true, SaveConversation: !api && promptCtx.AskQuestions}
+	if !api && rev == nil && promptCtx.LastClarification().Answer != "" && job.HasConversation(o.config.JobsDir, clarified.lastJobID) {
+		phase = session.PhaseAwaitingAnswers
+		opts.ContinueJob = clarified.lastJobID
+	}
+	taskPrompt, err := o.prompts.Build(phase, promptCtx)
+	if err != nil {
+		return o.fail(ctx, sess, sess.IssueNumber, err)
+	}
+
+	log.Printf("session %s: planning issue #%d", sess.ID, sess.IssueNumber)
+	j, err := o.runJob(ctx, sess, projectName, taskPrompt, opts)
+	if err != nil {
+		return o.fail(ctx, sess, sess.IssueNumber, err)
+	}
+	if j.Status != job.StatusCompleted {
+		return o.failJob(ctx, sess, sess.IssueNumber, j)
+	}
+	return o.finishPlanning(ctx, sess, projectName, issue, rev, j)
+}
+
+// finishPlanning posts the plan of a completed planning job on the issue
+// and moves the session to awaiting_approval, or, when Claude asked
+// clarifying questions instead, posts those and waits for answers.
+func (o *Orchestrator) finishPlanning(ctx context.Context, sess *session.Session, projectName string, issue *github.Issue, rev *planRevision, j *job.Job) error {
+	questions, round, err := o.checkQuestions(ctx, sess, rev, j.Plan)
+	if err != nil {
+		return o.fail(ctx, sess, sess.IssueNumber, err)
+	}
+	if round > 0 {
+		return o.askQuestions(ctx, sess, j, questions, round)
+	}
+
+	plan, checklist := session.ParsePlanChecklist(j.Plan)
+	if err := sess.SetPlan(plan); err != nil {
+		return err
+	}
+	sess.PlanChecklist = checklist
+	if err := o.sessions.Update(ctx, sess); err != nil {
+		return err
+	}
+	payload := map[string]string{
+		"from":   string(session.PhasePlanning),
+		"to":     string(session.PhaseAwaitingApproval),
+		"job_id": j.ID,
+	}
+	approval := github.FormatApprovalChecklist(checklist.Risk, checklist.Size, checklist.Files, checklist.Migration)
+	body := github.FormatPlanComment(sess.ID, plan, approval)
+	if rev != nil {
+		payload["plan_revision"] = strconv.Itoa(rev.number)
+		body = github.FormatRevisedPlanComment(sess.ID, plan, approval, rev.number)
+	}
+	o.recordEvent(ctx, sess.ID, session.EventTypePhaseChange, payload)
+
+	o.postComment(ctx, sess, sess.IssueNumber, body)
+
+	if label := autoApproveLabel(o.approvalPolicy(sess), issue); label != "" {
+		return o.autoApprove(ctx, sess, label)
+	}
+
+	n := sessionNotification(sess, notify.EventPlanAwaitingApproval, projectName)
+	n.JobID, n.Title, n.URL = j.ID, issue.Title, issue.HTMLURL
+	o.notify(ctx, n)
+	return nil
+}
+
+// userComments drops comments posted by MANFRED itself.
+func userComments(comments []github.Comment) []github.Comment {
+	var result []github.Comment
+	for _, c := range comments {
+		if !github.IsManfredComment(c.Body) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
@@ -0,0 +1,89 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/store"
+)
+
+func TestEditPlan(t *testing.T) {
+	var posted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/repos/acme/widgets/issues/7/comments" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		var comment struct {
+			Body string `json:"body"`
+		}
+		json.NewDecoder(r.Body).Decode(&comment)
+		posted = append(posted, comment.Body)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(github.Comment{ID: int64(len(posted)), Body: comment.Body})
+	}))
+	defer server.Close()
+
+	db, err := store.OpenInMemory()
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	sessions := session.NewSQLStore(db)
+
+	sess := session.NewSession("acme", "widgets", 7)
+	if err := sessions.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	o := New(&config.Config{}, sessions, github.NewClient("token", github.WithBaseURL(server.URL)))
+
+	if err := o.EditPlan(ctx, sess.ID, "", "Use OAuth"); err == nil {
+		t.Error("EditPlan() while planning succeeded, want an error")
+	}
+
+	if err := sess.SetPlan("Use basic auth"); err != nil {
+		t.Fatalf("SetPlan() error = %v", err)
+	}
+	if err := sessions.Update(ctx, sess); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := o.EditPlan(ctx, sess.ID, "", "  \n"); err == nil {
+		t.Error("EditPlan() with an empty plan succeeded, want an error")
+	}
+	if err := o.EditPlan(ctx, sess.ID, "", "Use OAuth\n"); err != nil {
+		t.Fatalf("EditPlan() error = %v", err)
+	}
+
+	got, err := sessions.Get(ctx, sess.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.PlanContent == nil || *got.PlanContent != "Use OAuth" {
+		t.Errorf("plan = %v, want the edited plan", got.PlanContent)
+	}
+	if got.Phase != session.PhaseAwaitingApproval {
+		t.Errorf("phase = %s, want %s", got.Phase, session.PhaseAwaitingApproval)
+	}
+
+	if len(posted) != 1 || !strings.Contains(posted[0], "Use OAuth") {
+		t.Fatalf("posted comments = %q, want the edited plan", posted)
+	}
+	events, err := sessions.GetEvents(ctx, sess.ID, session.EventFilter{Types: []session.EventType{session.EventTypePlanEdited}})
+	if err != nil {
+		t.Fatalf("GetEvents() error = %v", err)
+	}
+	if len(events) != 1 {
+		t.Errorf("got %d plan_edited events, want 1", len(events))
+	}
+}
@@ -0,0 +1,120 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// createPullRequest opens the session's pull request, as a draft when the
+// project's session pull requests start as drafts, and adds the project's
+// labels. Repositories without draft pull requests get a regular one.
+// Pull requests that are not drafts get their reviewers right away.
+func (o *Orchestrator) createPullRequest(ctx context.Context, sess *session.Session, projectConfig *config.ProjectConfig, input *github.CreatePullRequestInput) (*github.PullRequest, error) {
+	input.Draft = o.config.DraftPRs(projectConfig)
+	pr, err := o.github.CreatePullRequest(ctx, sess.RepoOwner, sess.RepoName, input)
+	if input.Draft && draftsUnsupported(err) {
+		log.Printf("session %s: failed to open a draft pull request, opening a regular one: %v", sess.ID, err)
+		input.Draft = false
+		pr, err = o.github.CreatePullRequest(ctx, sess.RepoOwner, sess.RepoName, input)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if labels := projectConfig.PullRequest.Labels; len(labels) > 0 {
+		if err := o.github.AddLabels(ctx, sess.RepoOwner, sess.RepoName, pr.Number, labels); err != nil {
+			log.Printf("session %s: failed to label PR #%d: %v", sess.ID, pr.Number, err)
+		}
+	}
+	if !pr.Draft {
+		o.requestReviewers(ctx, sess, pr.Number, projectConfig)
+	}
+	return pr, nil
+}
+
+// draftsUnsupported reports whether GitHub refused a draft pull request
+// because the repository has none, rather than for another validation
+// failure such as an existing pull request for the branch.
+func draftsUnsupported(err error) bool {
+	var apiErr *github.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity {
+		return false
+	}
+	messages := []string{apiErr.Message}
+	for _, detail := range apiErr.Errors {
+		messages = append(messages, detail.Message)
+	}
+	for _, message := range messages {
+		if strings.Contains(strings.ToLower(message), "draft") {
+			return true
+		}
+	}
+	return false
+}
+
+// verified reports whether a job's tests passed on the code it pushed, which
+// makes a draft pull request ready for review. Projects without a test
+// command have nothing to wait for; jobs whose tests failed, or whose branch
+// was rebased after the tests, are not verified.
+func verified(j *job.Job) bool {
+	if j.TestResult == nil {
+		return true
+	}
+	return j.TestResult.Passed && !j.TestResult.Rebased
+}
+
+// markReady marks the session's draft pull request ready for review, once
+// a job pushed to it passed its tests, and requests the project's
+// reviewers. Pull requests that are not drafts are left alone; failures are
+// logged.
+func (o *Orchestrator) markReady(ctx context.Context, sess *session.Session, pr *github.PullRequest, projectConfig *config.ProjectConfig, j *job.Job) {
+	if !pr.Draft || !verified(j) {
+		return
+	}
+	if err := o.github.MarkPullRequestReady(ctx, pr.NodeID); err != nil {
+		log.Printf("session %s: failed to mark PR #%d ready for review: %v", sess.ID, pr.Number, err)
+		return
+	}
+	pr.Draft = false
+	log.Printf("session %s: tests passed, PR #%d is ready for review", sess.ID, pr.Number)
+	o.recordEvent(ctx, sess.ID, session.EventTypePRReady, map[string]interface{}{
+		"number": pr.Number,
+		"job_id": j.ID,
+	})
+	o.requestReviewers(ctx, sess, pr.Number, projectConfig)
+}
+
+// markDraftReady is markReady for the session's pull request by number,
+// after a revision.
+func (o *Orchestrator) markDraftReady(ctx context.Context, sess *session.Session, number int, j *job.Job) {
+	_, projectConfig, err := o.config.FindProjectByRepo(sess.RepoOwner, sess.RepoName)
+	if err != nil {
+		log.Printf("session %s: %v", sess.ID, err)
+		return
+	}
+	pr, err := o.github.GetPullRequest(ctx, sess.RepoOwner, sess.RepoName, number)
+	if err != nil {
+		log.Printf("session %s: failed to fetch PR #%d: %v", sess.ID, number, err)
+		return
+	}
+	o.markReady(ctx, sess, pr, projectConfig, j)
+}
+
+// requestReviewers asks the project's reviewers to review the pull request.
+func (o *Orchestrator) requestReviewers(ctx context.Context, sess *session.Session, number int, projectConfig *config.ProjectConfig) {
+	users, teams := projectConfig.PullRequest.ReviewerLists()
+	if len(users) == 0 && len(teams) == 0 {
+		return
+	}
+	if err := o.github.RequestReviewers(ctx, sess.RepoOwner, sess.RepoName, number, users, teams); err != nil {
+		log.Printf("session %s: failed to request reviewers for PR #%d: %v", sess.ID, number, err)
+	}
+}
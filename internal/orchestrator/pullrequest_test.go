@@ -0,0 +1,130 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/store"
+)
+
+func TestDraftPullRequest(t *testing.T) {
+	var requests []string
+	draftsSupported := true
+	prExists := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+string(body))
+		switch r.URL.Path {
+		case "/repos/acme/widgets/pulls":
+			var input github.CreatePullRequestInput
+			json.Unmarshal(body, &input)
+			if prExists {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.Write([]byte(`{"message":"Validation Failed","errors":[{"resource":"PullRequest","code":"custom","message":"A pull request already exists for acme:manfred/7."}]}`))
+				return
+			}
+			if input.Draft && !draftsSupported {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.Write([]byte(`{"message":"Draft pull requests are not supported in this repository."}`))
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(github.PullRequest{Number: 12, NodeID: "PR_12", Draft: input.Draft})
+		case "/graphql":
+			w.Write([]byte(`{"data":{"markPullRequestReadyForReview":{"pullRequest":{"isDraft":false}}}}`))
+		default:
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	db, err := store.OpenInMemory()
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	sessions := session.NewSQLStore(db)
+	sess := session.NewSession("acme", "widgets", 7)
+	if err := sessions.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	o := New(&config.Config{GitHub: config.GitHubConfig{DraftPRs: true}}, sessions, github.NewClient("token", github.WithBaseURL(server.URL)))
+	project := &config.ProjectConfig{PullRequest: config.PullRequestConfig{
+		Labels:    []string{"ai-generated"},
+		Reviewers: []string{"alice", "acme/backend"},
+	}}
+	input := func() *github.CreatePullRequestInput {
+		return &github.CreatePullRequestInput{Title: "Login", Head: sess.Branch, Base: "main"}
+	}
+
+	pr, err := o.createPullRequest(ctx, sess, project, input())
+	if err != nil {
+		t.Fatalf("createPullRequest() error = %v", err)
+	}
+	if !pr.Draft {
+		t.Fatal("createPullRequest() opened a regular PR, want a draft")
+	}
+	if len(requests) != 2 || !strings.Contains(requests[1], `/issues/12/labels ["ai-generated"]`) {
+		t.Fatalf("requests = %q, want the PR and its labels", requests)
+	}
+
+	// Failing tests leave the draft alone
+	requests = nil
+	o.markReady(ctx, sess, pr, project, &job.Job{ID: "job_1", TestResult: &job.TestResult{Passed: false}})
+	if len(requests) != 0 || !pr.Draft {
+		t.Fatalf("markReady() after failed tests sent %q", requests)
+	}
+
+	o.markReady(ctx, sess, pr, project, &job.Job{ID: "job_1", TestResult: &job.TestResult{Passed: true}})
+	if pr.Draft || len(requests) != 2 {
+		t.Fatalf("markReady() sent %q, want the ready mutation and the review request", requests)
+	}
+	if !strings.Contains(requests[0], `"id":"PR_12"`) {
+		t.Errorf("mutation = %q, want the PR's node ID", requests[0])
+	}
+	if !strings.Contains(requests[1], `{"reviewers":["alice"],"team_reviewers":["backend"]}`) {
+		t.Errorf("review request = %q, want alice and the backend team", requests[1])
+	}
+
+	// Projects without a test command have nothing to wait for
+	pr.Draft = true
+	requests = nil
+	o.markReady(ctx, sess, pr, project, &job.Job{ID: "job_2"})
+	if pr.Draft || len(requests) != 2 {
+		t.Fatalf("markReady() without tests sent %q, want the PR ready for review", requests)
+	}
+
+	// Other validation failures are not retried without the draft
+	prExists = true
+	requests = nil
+	if _, err := o.createPullRequest(ctx, sess, project, input()); err == nil || len(requests) != 1 {
+		t.Fatalf("createPullRequest() for an existing PR = %v after %q, want the error without a retry", err, requests)
+	}
+	prExists = false
+
+	// Repositories without drafts get a regular PR with its reviewers
+	draftsSupported = false
+	requests = nil
+	pr, err = o.createPullRequest(ctx, sess, project, input())
+	if err != nil {
+		t.Fatalf("createPullRequest() without drafts error = %v", err)
+	}
+	if pr.Draft || len(requests) != 4 || !strings.Contains(requests[3], "/requested_reviewers") {
+		t.Errorf("requests without drafts = %q, want a regular PR with labels and reviewers", requests)
+	}
+}
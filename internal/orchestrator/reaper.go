@@ -0,0 +1,186 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mpm/manfred/internal/docker"
+	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// jobPhases are the phases in which a session has a job running.
+var jobPhases = []session.Phase{session.PhasePlanning, session.PhaseImplementing, session.PhaseRevising, session.PhaseTriaging}
+
+// RunReaper reaps stale sessions every interval until ctx is done. Errors
+// are passed to onError.
+func (o *Orchestrator) RunReaper(ctx context.Context, interval, staleAfter time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := o.ReapStale(ctx, staleAfter); err != nil && ctx.Err() == nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReapStale moves sessions stuck in a job phase to the error phase, which
+// happens when the process running their job died. A session is stuck when
+// its last activity is older than staleAfter, no job of it runs in this
+// process and none of its job containers is running. The error is recorded
+// and commented like a failed job, and what is left of the session's jobs
+// is removed as job.cleanup says. It returns the IDs of reaped sessions.
+func (o *Orchestrator) ReapStale(ctx context.Context, staleAfter time.Duration) ([]string, error) {
+	candidates, err := o.staleSessions(ctx, staleAfter, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var reaped []string
+	for i := range candidates {
+		sess := &candidates[i]
+		projects, err := o.runningJobProjects(ctx, sess.ID)
+		if err != nil {
+			log.Printf("session %s: failed to find running jobs: %v", sess.ID, err)
+			continue
+		}
+		if len(projects) > 0 {
+			running, err := jobContainersRunning(ctx, projects)
+			if err != nil {
+				log.Printf("session %s: failed to check job containers: %v", sess.ID, err)
+				continue
+			}
+			if running {
+				continue
+			}
+		}
+
+		idle := time.Since(sess.LastActivity).Round(time.Second)
+		log.Printf("session %s: stuck %s for %s without a running job, moving it to error", sess.ID, sess.Phase, idle)
+		o.releaseJobs(ctx, sess.ID, projects, "reaped")
+
+		number := sess.IssueNumber
+		if sess.Phase == session.PhaseRevising && sess.PRNumber != nil {
+			number = *sess.PRNumber
+		}
+		o.fail(ctx, sess, number, fmt.Errorf("the job stopped without a result (no activity for %s and no running container); MANFRED was probably restarted while it ran", idle))
+		reaped = append(reaped, sess.ID)
+	}
+	return reaped, nil
+}
+
+// staleSessions returns the sessions in a job phase whose last activity is
+// older than staleAfter and whose job does not run in this process.
+func (o *Orchestrator) staleSessions(ctx context.Context, staleAfter time.Duration, now time.Time) ([]session.Session, error) {
+	var stale []session.Session
+	for _, phase := range jobPhases {
+		sessions, err := o.sessions.List(ctx, session.SessionFilter{Phase: &phase})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s sessions: %w", phase, err)
+		}
+		for _, sess := range sessions {
+			if now.Sub(sess.LastActivity) < staleAfter || o.hasJob(sess.ID) {
+				continue
+			}
+			stale = append(stale, sess)
+		}
+	}
+	return stale, nil
+}
+
+// hasJob reports whether a job of the session runs in this process.
+func (o *Orchestrator) hasJob(sessionID string) bool {
+	o.jobsMu.Lock()
+	defer o.jobsMu.Unlock()
+	_, ok := o.cancels[sessionID]
+	return ok
+}
+
+// jobContainersRunning reports whether any container of the compose
+// projects is running.
+func jobContainersRunning(ctx context.Context, projects []string) (bool, error) {
+	client, err := docker.New()
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to docker: %w", err)
+	}
+	defer client.Close()
+
+	for _, project := range projects {
+		resources, err := client.ListComposeResources(ctx, project)
+		if err != nil {
+			return false, err
+		}
+		for _, r := range resources {
+			if r.Project != project || r.Kind != docker.KindContainer {
+				continue
+			}
+			running, err := client.IsRunning(ctx, r.ID)
+			if err != nil {
+				return false, err
+			}
+			if running {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// releaseJobs records the jobs of compose projects whose runner is gone as
+// stopped for reason and removes what is left of them.
+func (o *Orchestrator) releaseJobs(ctx context.Context, sessionID string, projects []string, reason string) {
+	for _, project := range projects {
+		o.recordEvent(ctx, sessionID, session.EventTypeContainerStop, map[string]string{
+			"job_id": strings.TrimPrefix(project, job.ComposeProjectPrefix),
+			"reason": reason,
+		})
+	}
+	removeJobResources(ctx, sessionID, projects, o.config.Job.Cleanup)
+}
+
+// removeJobResources removes what is left of the compose projects of jobs
+// whose runner is gone, following the job cleanup level since the runner
+// cannot. Failures are logged.
+func removeJobResources(ctx context.Context, sessionID string, projects []string, cleanup string) {
+	if len(projects) == 0 {
+		return
+	}
+	level, err := docker.ParseCleanupLevel(cleanup)
+	if err != nil {
+		level = docker.CleanupContainers
+	}
+	client, err := docker.New()
+	if err != nil {
+		log.Printf("session %s: failed to connect to docker: %v", sessionID, err)
+		return
+	}
+	defer client.Close()
+
+	for _, project := range projects {
+		resources, err := client.ListComposeResources(ctx, project)
+		if err != nil {
+			log.Printf("session %s: failed to list resources of %s: %v", sessionID, project, err)
+			continue
+		}
+		for _, r := range resources {
+			if r.Project != project ||
+				(r.Kind == docker.KindVolume && level == docker.CleanupContainers) ||
+				(r.Kind == docker.KindImage && level != docker.CleanupImages) {
+				continue
+			}
+			if err := client.RemoveResource(ctx, r); err != nil {
+				log.Printf("session %s: %v", sessionID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// setExecution records the execution state of the session's running phase
+// as run by this process. Failures are logged.
+func (o *Orchestrator) setExecution(ctx context.Context, sess *session.Session, exec session.Execution) {
+	exec.Host, _ = os.Hostname()
+	exec.PID = os.Getpid()
+	exec.UpdatedAt = time.Now().UTC()
+	sess.Execution = exec
+	if err := o.sessions.SetExecution(ctx, sess.ID, exec); err != nil {
+		log.Printf("session %s: failed to record execution state: %v", sess.ID, err)
+	}
+}
+
+// ResumeInterrupted picks up sessions whose job phase was interrupted
+// because the process running it died, as `manfred serve` does on startup.
+// The leftover containers of the interrupted job are removed. A job that
+// had completed is finished from its job directory; otherwise an
+// implementing session adopts an open pull request of its branch, and any
+// other phase starts over. Each resumed phase runs in its own goroutine
+// with ctx. A session interrupted more than job.resume.max_attempts times
+// in a row is moved to the error phase instead. It returns the IDs of the
+// resumed sessions.
+func (o *Orchestrator) ResumeInterrupted(ctx context.Context) ([]string, error) {
+	var resumed []string
+	for _, phase := range jobPhases {
+		sessions, err := o.sessions.List(ctx, session.SessionFilter{Phase: &phase})
+		if err != nil {
+			return resumed, fmt.Errorf("failed to list %s sessions: %w", phase, err)
+		}
+		for i := range sessions {
+			sess := &sessions[i]
+			if o.hasJob(sess.ID) {
+				continue
+			}
+			interrupted, err := o.interrupted(ctx, sess)
+			if err != nil {
+				log.Printf("session %s: failed to check for an interrupted %s phase: %v", sess.ID, sess.Phase, err)
+				continue
+			}
+			if interrupted && o.resume(ctx, sess) {
+				resumed = append(resumed, sess.ID)
+			}
+		}
+	}
+	return resumed, nil
+}
+
+// interrupted reports whether the process running the session's phase is
+// gone. A phase run on another host is never interrupted; without an
+// execution record (a phase started by an older version) the phase counts
+// as interrupted unless one of its job containers runs.
+func (o *Orchestrator) interrupted(ctx context.Context, sess *session.Session) (bool, error) {
+	exec := sess.Execution
+	if !exec.IsZero() && exec.Host != "" {
+		host, _ := os.Hostname()
+		if exec.Host != host {
+			return false, nil
+		}
+		return exec.PID != os.Getpid() && !processAlive(exec.PID), nil
+	}
+
+	projects, err := o.runningJobProjects(ctx, sess.ID)
+	if err != nil || len(projects) == 0 {
+		return err == nil, err
+	}
+	running, err := jobContainersRunning(ctx, projects)
+	return !running, err
+}
+
+// processAlive reports whether a process with the PID exists.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// resume cleans up the interrupted phase of a session and starts resuming
+// it, unless it was interrupted too often. It reports whether the session
+// is resumed.
+func (o *Orchestrator) resume(ctx context.Context, sess *session.Session) bool {
+	number := sess.IssueNumber
+	if sess.Phase == session.PhaseRevising && sess.PRNumber != nil {
+		number = *sess.PRNumber
+	}
+
+	events, err := o.sessions.GetEvents(ctx, sess.ID, session.EventFilter{})
+	if err != nil {
+		log.Printf("session %s: failed to load events: %v", sess.ID, err)
+		return false
+	}
+	projects, err := o.runningJobProjects(ctx, sess.ID)
+	if err != nil {
+		log.Printf("session %s: failed to find running jobs: %v", sess.ID, err)
+	}
+	o.releaseJobs(ctx, sess.ID, projects, "interrupted")
+
+	attempt := resumeAttempts(events, sess.Phase) + 1
+	if limit := o.config.Job.Resume.MaxAttempts; attempt > limit {
+		o.fail(ctx, sess, number, fmt.Errorf("%s was interrupted by a restart %d time(s); giving up", sess.Phase, attempt))
+		return false
+	}
+
+	exec := sess.Execution
+	log.Printf("session %s: resuming %s interrupted at step %q (attempt %d)", sess.ID, sess.Phase, exec.Step, attempt)
+	o.recordEvent(ctx, sess.ID, session.EventTypeResumed, map[string]interface{}{
+		"phase":   string(sess.Phase),
+		"step":    string(exec.Step),
+		"job_id":  exec.JobID,
+		"attempt": attempt,
+	})
+	// Counts as activity, so the reaper leaves the session alone
+	o.setExecution(ctx, sess, session.Execution{Step: session.StepQueued})
+
+	go func() {
+		if err := o.resumePhase(ctx, sess, exec, events); err != nil {
+			log.Printf("session %s: resuming %s: %v", sess.ID, sess.Phase, err)
+		}
+	}()
+	return true
+}
+
+// resumePhase continues an interrupted phase as ResumeInterrupted describes.
+// exec is the execution state the phase was interrupted in.
+func (o *Orchestrator) resumePhase(ctx context.Context, sess *session.Session, exec session.Execution, events []session.SessionEvent) error {
+	projectName, projectConfig, err := o.config.FindProjectByRepo(sess.RepoOwner, sess.RepoName)
+	if err != nil {
+		return o.fail(ctx, sess, sess.IssueNumber, err)
+	}
+
+	if sess.Phase == session.PhaseImplementing {
+		pr, err := o.openPullRequest(ctx, sess)
+		if err != nil {
+			return o.fail(ctx, sess, sess.IssueNumber, err)
+		}
+		if pr != nil {
+			log.Printf("session %s: adopting open PR #%d of %s", sess.ID, pr.Number, sess.Branch)
+			return o.enterReview(ctx, sess, projectName, pr, exec.JobID, pr.Head.SHA)
+		}
+	}
+
+	var finished *job.Job
+	if exec.Step == session.StepFinishing && exec.JobID != "" {
+		if j, err := job.Load(o.config.JobsDir, exec.JobID); err == nil && j.Status == job.StatusCompleted {
+			finished = j
+		}
+	}
+
+	switch sess.Phase {
+	case session.PhasePlanning:
+		rev := interruptedPlanRevision(sess, events)
+		if finished == nil {
+			return o.runPlanning(ctx, sess, rev)
+		}
+		issue, err := o.github.GetIssue(ctx, sess.RepoOwner, sess.RepoName, sess.IssueNumber)
+		if err != nil {
+			return o.fail(ctx, sess, sess.IssueNumber, fmt.Errorf("fetch issue: %w", err))
+		}
+		return o.finishPlanning(ctx, sess, projectName, issue, rev, finished)
+
+	case session.PhaseImplementing:
+		if finished == nil {
+			return o.runImplementation(ctx, sess)
+		}
+		issue, err := o.github.GetIssue(ctx, sess.RepoOwner, sess.RepoName, sess.IssueNumber)
+		if err != nil {
+			return o.fail(ctx, sess, sess.IssueNumber, fmt.Errorf("fetch issue: %w", err))
+		}
+		return o.finishImplementation(ctx, sess, projectName, projectConfig, issue, finished)
+
+	case session.PhaseTriaging:
+		if finished == nil {
+			return o.runTriage(ctx, sess)
+		}
+		return o.finishTriage(ctx, sess, o.repoLabels(ctx, sess), finished)
+
+	case session.PhaseRevising:
+		if sess.PRNumber == nil {
+			return o.fail(ctx, sess, sess.IssueNumber, fmt.Errorf("session has no pull request"))
+		}
+		feedback, ci := lastRevisionFeedback(events)
+		if finished != nil {
+			return o.finishRevision(ctx, sess, *sess.PRNumber, finished, ci)
+		}
+		return o.runRevision(ctx, sess, *sess.PRNumber, feedback, ci)
+	}
+	return nil
+}
+
+// openPullRequest returns the open pull request of the session branch, nil
+// if there is none.
+func (o *Orchestrator) openPullRequest(ctx context.Context, sess *session.Session) (*github.PullRequest, error) {
+	prs, err := o.github.ListPullRequests(ctx, sess.RepoOwner, sess.RepoName, &github.ListPullRequestsOptions{
+		State: "open",
+		Head:  sess.RepoOwner + ":" + sess.Branch,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list pull requests: %w", err)
+	}
+	if len(prs) == 0 {
+		return nil, nil
+	}
+	return &prs[0], nil
+}
+
+// eventPayload is the part of event payloads resuming looks at.
+type eventPayload struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Source   string `json:"source"`
+	Feedback string `json:"feedback"`
+	Phase    string `json:"phase"`
+}
+
+// resumeAttempts counts the resumed events since the session last entered
+// phase.
+func resumeAttempts(events []session.SessionEvent, phase session.Phase) int {
+	attempts := 0
+	for _, event := range events {
+		var payload eventPayload
+		json.Unmarshal([]byte(event.Payload), &payload)
+		switch {
+		case event.EventType == session.EventTypePhaseChange && payload.To == string(phase):
+			attempts = 0
+		case event.EventType == session.EventTypeResumed:
+			attempts++
+		}
+	}
+	return attempts
+}
+
+// lastRevisionFeedback returns what the last revision round was asked to
+// address: review feedback, or a CI failure if ci is set.
+func lastRevisionFeedback(events []session.SessionEvent) (feedback string, ci bool) {
+	for _, event := range events {
+		var payload eventPayload
+		if event.EventType == session.EventTypeCommentReceived &&
+			json.Unmarshal([]byte(event.Payload), &payload) == nil &&
+			(payload.Source == "review" || payload.Source == "ci") {
+			feedback, ci = payload.Feedback, payload.Source == "ci"
+		}
+	}
+	return feedback, ci
+}
+
+// interruptedPlanRevision returns the plan revision the session's planning
+// phase was working on, nil if it was planning from scratch.
+func interruptedPlanRevision(sess *session.Session, events []session.SessionEvent) *planRevision {
+	var rev *planRevision
+	revisions := 0
+	for _, event := range events {
+		var payload eventPayload
+		json.Unmarshal([]byte(event.Payload), &payload)
+		switch {
+		case event.EventType == session.EventTypePhaseChange && payload.To == string(session.PhasePlanning):
+			rev = nil
+			if payload.From == string(session.PhaseAwaitingApproval) {
+				revisions++
+			}
+		case event.EventType == session.EventTypeCommentReceived && payload.Source == "revise_plan":
+			rev = &planRevision{number: revisions, feedback: payload.Feedback}
+		}
+	}
+	if rev != nil && sess.PlanContent != nil {
+		rev.previous = *sess.PlanContent
+	}
+	return rev
+}
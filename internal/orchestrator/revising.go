@@ -0,0 +1,149 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/prompt"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// HandleReviewFeedback runs a revision round for a session whose PR received
+// review feedback. The session must be in the in_review phase; it moves to
+// revising while Claude works and back to in_review once the new commits are
+// pushed. Nothing happens while automation on the branch is paused.
+//
+// sender is the reviewer; feedback from one who may not approve the PR (see
+// mayApprove) is ignored, since anyone can review pull requests of public
+// repositories. An empty sender (`manfred session revise`) and API keys
+// (github.APISender) are not checked.
+func (o *Orchestrator) HandleReviewFeedback(ctx context.Context, sessionID, sender, feedback string) error {
+	sess, err := o.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if sess == nil {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	if sess.Phase == session.PhasePaused {
+		log.Printf("session %s: automation paused, ignoring review feedback", sess.ID)
+		return nil
+	}
+	if sender != "" && !github.IsAPISender(sender) {
+		ok, err := o.mayApprove(ctx, sess, sender)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			log.Printf("session %s: ignoring the review of %s, who may not approve", sess.ID, sender)
+			return nil
+		}
+	}
+	if ok, err := o.verifyBranchHead(ctx, sess); err != nil || !ok {
+		return err
+	}
+
+	sess, err = o.transition(ctx, sessionID, session.PhaseInReview, session.PhaseRevising)
+	if err != nil {
+		return err
+	}
+	if sess.PRNumber == nil {
+		return o.fail(ctx, sess, sess.IssueNumber, fmt.Errorf("session has no pull request"))
+	}
+	prNumber := *sess.PRNumber
+
+	o.recordEvent(ctx, sess.ID, session.EventTypeCommentReceived, map[string]string{
+		"source":   "review",
+		"feedback": feedback,
+	})
+
+	return o.runRevision(ctx, sess, prNumber, feedback, false)
+}
+
+// runRevision runs Claude on the session branch to address review feedback
+// on PR prNumber, or the CI failure it describes if ci is set, and pushes the
+// new commits.
+func (o *Orchestrator) runRevision(ctx context.Context, sess *session.Session, prNumber int, feedback string, ci bool) error {
+	projectName, _, err := o.config.FindProjectByRepo(sess.RepoOwner, sess.RepoName)
+	if err != nil {
+		return o.fail(ctx, sess, prNumber, err)
+	}
+
+	taskPrompt, err := o.prompts.Build(session.PhaseRevising, &prompt.Context{
+		Session:   sess,
+		PRNumber:  prNumber,
+		Feedback:  feedback,
+		CIFailure: ci,
+	})
+	if err != nil {
+		return o.fail(ctx, sess, prNumber, err)
+	}
+
+	if sess.HeadSHA != nil {
+		description := "Revising after review"
+		if ci {
+			description = "Fixing CI failure"
+		}
+		o.reportStatus(ctx, sess, *sess.HeadSHA, statusContextJob, github.StatusPending, description)
+	}
+
+	log.Printf("session %s: revising PR #%d", sess.ID, prNumber)
+	j, err := o.runJob(ctx, sess, projectName, taskPrompt, job.RunOptions{
+		Branch: sess.Branch,
+		Push:   true,
+	})
+	if err != nil {
+		return o.fail(ctx, sess, prNumber, err)
+	}
+	o.recordJobDiff(ctx, sess, j)
+	if j.Status != job.StatusCompleted {
+		return o.failJob(ctx, sess, prNumber, j)
+	}
+	return o.finishRevision(ctx, sess, prNumber, j, ci)
+}
+
+// finishRevision moves the session back to in_review after a completed
+// revision job and replies on the PR. A draft PR becomes ready for review
+// when the revision's tests passed.
+func (o *Orchestrator) finishRevision(ctx context.Context, sess *session.Session, prNumber int, j *job.Job, ci bool) error {
+	if j.Pushed {
+		sess.SetHeadSHA(j.HeadSHA)
+		o.reportJobStatus(ctx, sess, j.HeadSHA, j, "Revision pushed")
+		if verified(j) {
+			o.markDraftReady(ctx, sess, prNumber, j)
+		}
+	} else if sess.HeadSHA != nil {
+		o.reportStatus(ctx, sess, *sess.HeadSHA, statusContextJob, github.StatusSuccess, "No changes needed")
+	}
+	if err := sess.TransitionTo(session.PhaseInReview); err != nil {
+		return err
+	}
+	if err := o.sessions.Update(ctx, sess); err != nil {
+		return err
+	}
+	o.recordEvent(ctx, sess.ID, session.EventTypePhaseChange, map[string]string{
+		"from":   string(session.PhaseRevising),
+		"to":     string(session.PhaseInReview),
+		"job_id": j.ID,
+	})
+
+	summary := j.CommitMessage
+	if !j.Pushed {
+		summary = "No changes were needed to address this feedback."
+		if ci {
+			summary = "Claude found nothing to change for this failure."
+		}
+	} else if summary == "" {
+		summary = "New commits have been pushed to this branch."
+	}
+	if ci {
+		o.postStatusComment(ctx, sess, prNumber, github.FormatCIFixComment(sess.ID, summary))
+	} else {
+		o.postStatusComment(ctx, sess, prNumber, github.FormatRevisionComment(sess.ID, summary))
+	}
+
+	return nil
+}
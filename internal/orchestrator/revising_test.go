@@ -0,0 +1,78 @@
+package orchestrator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/store"
+)
+
+func TestHandleReviewFeedbackUnauthorized(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/repos/acme/widgets/collaborators/mallory/permission":
+			w.Write([]byte(`{"permission":"read"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	db, err := store.OpenInMemory()
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	sessions := session.NewSQLStore(db)
+
+	for i, tt := range []struct {
+		approve []string
+		sender  string
+	}{
+		// Without authorization.approve, reviewers need write access
+		{nil, "mallory"},
+		// With it, they need to be on the list
+		{[]string{"alice"}, "bob"},
+	} {
+		sess := session.NewSession("acme", "widgets", 7+i)
+		sess.Phase = session.PhaseInReview
+		sess.SetPRNumber(12)
+		if err := sessions.Create(ctx, sess); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		requests = nil
+
+		cfg := &config.Config{Auth: config.AuthorizationConfig{Approve: tt.approve}}
+		o := New(cfg, sessions, github.NewClient("token", github.WithBaseURL(server.URL)))
+		if err := o.HandleReviewFeedback(ctx, sess.ID, tt.sender, "Rewrite it all"); err != nil {
+			t.Fatalf("HandleReviewFeedback(%s) error = %v", tt.sender, err)
+		}
+
+		got, err := sessions.Get(ctx, sess.ID)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if got.Phase != session.PhaseInReview {
+			t.Errorf("phase after the review of %s = %s, want in_review", tt.sender, got.Phase)
+		}
+		for _, r := range requests {
+			if r != "GET /repos/acme/widgets/collaborators/mallory/permission" {
+				t.Errorf("request %q after the review of %s, want none but the permission check", r, tt.sender)
+			}
+		}
+		if events, _ := sessions.GetEvents(ctx, sess.ID, session.EventFilter{}); len(events) != 0 {
+			t.Errorf("events after the review of %s = %+v, want none", tt.sender, events)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// Status contexts reported on session branch commits.
+const (
+	statusContextJob   = "manfred"
+	statusContextTests = "manfred/tests"
+)
+
+// reportStatus shows MANFRED's progress on a commit of the session branch.
+// GitHub App installations create check runs; token auth uses commit
+// statuses. Failures are logged, never returned.
+func (o *Orchestrator) reportStatus(ctx context.Context, sess *session.Session, sha, name, state, description string) {
+	if !o.config.GitHub.ReportStatus || sha == "" {
+		return
+	}
+
+	var err error
+	if o.github.IsApp() {
+		run := &github.CheckRun{
+			Name:    name,
+			HeadSHA: sha,
+			Status:  "completed",
+			Output:  &github.CheckRunOutput{Title: description, Summary: fmt.Sprintf("Session `%s`", sess.ID)},
+		}
+		switch state {
+		case github.StatusPending:
+			run.Status = "in_progress"
+		case github.StatusError:
+			run.Conclusion = "failure"
+		default:
+			run.Conclusion = state
+		}
+		_, err = o.github.CreateCheckRun(ctx, sess.RepoOwner, sess.RepoName, run)
+	} else {
+		_, err = o.github.CreateCommitStatus(ctx, sess.RepoOwner, sess.RepoName, sha, &github.CommitStatus{
+			State:       state,
+			Context:     name,
+			Description: description,
+		})
+	}
+	if err != nil {
+		log.Printf("session %s: failed to report %s status: %v", sess.ID, name, err)
+	}
+}
+
+// reportJobStatus reports the outcome of a job, including its test run, on
+// the commit it pushed.
+func (o *Orchestrator) reportJobStatus(ctx context.Context, sess *session.Session, sha string, j *job.Job, description string) {
+	o.reportStatus(ctx, sess, sha, statusContextJob, github.StatusSuccess, description)
+
+	result := j.TestResult
+	if result == nil {
+		return
+	}
+	if result.Passed {
+		o.reportStatus(ctx, sess, sha, statusContextTests, github.StatusSuccess,
+			fmt.Sprintf("Tests passed (%d run(s))", result.Attempts))
+	} else {
+		o.reportStatus(ctx, sess, sha, statusContextTests, github.StatusFailure,
+			fmt.Sprintf("Tests failed after %d fix attempt(s)", result.FixAttempts))
+	}
+}
@@ -0,0 +1,59 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/session"
+)
+
+func TestReportJobStatus(t *testing.T) {
+	var statuses []github.CommitStatus
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/acme/widgets/statuses/abc123" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		var status github.CommitStatus
+		json.NewDecoder(r.Body).Decode(&status)
+		statuses = append(statuses, status)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(status)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{GitHub: config.GitHubConfig{ReportStatus: true}}
+	o := New(cfg, nil, github.NewClient("token", github.WithBaseURL(server.URL)))
+	sess := session.NewSession("acme", "widgets", 7)
+
+	j := &job.Job{TestResult: &job.TestResult{Passed: false, Attempts: 3, FixAttempts: 2}}
+	o.reportJobStatus(context.Background(), sess, "abc123", j, "Implementation pushed")
+
+	want := []github.CommitStatus{
+		{State: github.StatusSuccess, Context: statusContextJob, Description: "Implementation pushed"},
+		{State: github.StatusFailure, Context: statusContextTests, Description: "Tests failed after 2 fix attempt(s)"},
+	}
+	if len(statuses) != len(want) {
+		t.Fatalf("got %d statuses, want %d", len(statuses), len(want))
+	}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Errorf("status %d = %+v, want %+v", i, statuses[i], want[i])
+		}
+	}
+}
+
+func TestReportStatusDisabled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+	}))
+	defer server.Close()
+
+	o := New(&config.Config{}, nil, github.NewClient("token", github.WithBaseURL(server.URL)))
+	o.reportStatus(context.Background(), session.NewSession("acme", "widgets", 7), "abc123", statusContextJob, github.StatusPending, "Revising")
+}
@@ -0,0 +1,201 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/prompt"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// StartTriage creates a triage-only session for an issue: Claude reads the
+// issue and the repository and posts suggested labels, affected modules, a
+// rough estimate and possible duplicates, without planning, creating a
+// branch or starting containers. The session ends triaged, from where a
+// trigger label or plan request starts planning. An issue with a session in
+// any other phase is left alone; a triaged one is triaged again.
+func (o *Orchestrator) StartTriage(ctx context.Context, owner, repo string, issueNumber int, sender, trigger string) error {
+	sessionID := session.GenerateSessionID(owner, repo, issueNumber)
+	if err := o.authorize(ctx, ActionStart, sender, owner, repo, issueNumber, sessionID, string(session.PhaseTriaging)); err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	sess, err := o.sessions.GetByIssue(ctx, owner, repo, issueNumber)
+	if err != nil {
+		o.mu.Unlock()
+		return err
+	}
+	payload := map[string]string{
+		"to":      string(session.PhaseTriaging),
+		"trigger": trigger,
+		"user":    sender,
+	}
+	switch {
+	case sess == nil:
+		sess = session.NewSession(owner, repo, issueNumber)
+		sess.Phase = session.PhaseTriaging
+		err = o.sessions.Create(ctx, sess)
+	case sess.Phase == session.PhaseTriaged:
+		payload["from"] = string(sess.Phase)
+		if err = sess.TransitionTo(session.PhaseTriaging); err == nil {
+			err = o.sessions.Update(ctx, sess)
+		}
+	default:
+		o.mu.Unlock()
+		log.Printf("session %s already exists (%s), ignoring triage trigger", sess.ID, sess.Phase)
+		return nil
+	}
+	o.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	o.recordEvent(ctx, sess.ID, session.EventTypePhaseChange, payload)
+	return o.runTriage(ctx, sess)
+}
+
+// runTriage asks Claude to triage the session's issue in a read-only job
+// over the Anthropic API and posts the triage on the issue.
+func (o *Orchestrator) runTriage(ctx context.Context, sess *session.Session) error {
+	projectName, _, err := o.config.FindProjectByRepo(sess.RepoOwner, sess.RepoName)
+	if err != nil {
+		return o.fail(ctx, sess, sess.IssueNumber, err)
+	}
+
+	issue, err := o.github.GetIssue(ctx, sess.RepoOwner, sess.RepoName, sess.IssueNumber)
+	if err != nil {
+		return o.fail(ctx, sess, sess.IssueNumber, fmt.Errorf("fetch issue: %w", err))
+	}
+	comments, err := o.github.GetIssueComments(ctx, sess.RepoOwner, sess.RepoName, sess.IssueNumber)
+	if err != nil {
+		return o.fail(ctx, sess, sess.IssueNumber, fmt.Errorf("fetch comments: %w", err))
+	}
+
+	labels := o.repoLabels(ctx, sess)
+	taskPrompt, err := o.prompts.Build(session.PhaseTriaging, &prompt.Context{
+		Session:    sess,
+		Issue:      issue,
+		Comments:   userComments(comments),
+		Labels:     labels,
+		OpenIssues: o.openIssues(ctx, sess),
+	})
+	if err != nil {
+		return o.fail(ctx, sess, sess.IssueNumber, err)
+	}
+
+	log.Printf("session %s: triaging issue #%d", sess.ID, sess.IssueNumber)
+	j, err := o.runJob(ctx, sess, projectName, taskPrompt, job.RunOptions{PlanOnly: true, Triage: true})
+	if err != nil {
+		return o.fail(ctx, sess, sess.IssueNumber, err)
+	}
+	if j.Status != job.StatusCompleted {
+		return o.failJob(ctx, sess, sess.IssueNumber, j)
+	}
+	return o.finishTriage(ctx, sess, labels, j)
+}
+
+// finishTriage posts the triage of a completed triage job on the issue and
+// moves the session to triaged. Suggested labels the repository does not
+// have are dropped, as is the issue itself from the duplicates.
+func (o *Orchestrator) finishTriage(ctx context.Context, sess *session.Session, labels []github.Label, j *job.Job) error {
+	analysis, triage := session.ParseTriage(j.Plan)
+
+	var suggested []string
+	for _, name := range triage.Labels {
+		for _, l := range labels {
+			if strings.EqualFold(l.Name, name) {
+				suggested = append(suggested, l.Name)
+				break
+			}
+		}
+	}
+	var duplicates []string
+	triage.Duplicates = slices.DeleteFunc(triage.Duplicates, func(n int) bool { return n == sess.IssueNumber })
+	for _, n := range triage.Duplicates {
+		duplicates = append(duplicates, strconv.Itoa(n))
+	}
+
+	if err := sess.TransitionTo(session.PhaseTriaged); err != nil {
+		return err
+	}
+	if err := o.sessions.Update(ctx, sess); err != nil {
+		return err
+	}
+	o.recordEvent(ctx, sess.ID, session.EventTypePhaseChange, map[string]string{
+		"from":       string(session.PhaseTriaging),
+		"to":         string(session.PhaseTriaged),
+		"job_id":     j.ID,
+		"labels":     strings.Join(suggested, ","),
+		"estimate":   triage.Estimate,
+		"duplicates": strings.Join(duplicates, ","),
+	})
+
+	body := github.FormatTriageComment(sess.ID, analysis, suggested, triage.Modules, triage.Estimate, triage.Duplicates)
+	o.postComment(ctx, sess, sess.IssueNumber, body)
+	return nil
+}
+
+// repoLabels returns the labels of the session's repository Claude may
+// suggest: all but MANFRED's own. Failures are logged.
+func (o *Orchestrator) repoLabels(ctx context.Context, sess *session.Session) []github.Label {
+	all, err := o.github.ListLabels(ctx, sess.RepoOwner, sess.RepoName)
+	if err != nil {
+		log.Printf("session %s: failed to list labels: %v", sess.ID, err)
+		return nil
+	}
+	var labels []github.Label
+	for _, l := range all {
+		if !o.isManfredLabel(l.Name) {
+			labels = append(labels, l)
+		}
+	}
+	return labels
+}
+
+// openIssues returns up to job.triage.open_issues of the repository's
+// newest open issues other than the session's, for duplicate detection.
+// Failures are logged.
+func (o *Orchestrator) openIssues(ctx context.Context, sess *session.Session) []github.Issue {
+	limit := o.config.Job.Triage.OpenIssues
+	if limit <= 0 {
+		return nil
+	}
+	all, err := o.github.ListIssues(ctx, sess.RepoOwner, sess.RepoName, &github.ListIssuesOptions{State: "open"})
+	if err != nil {
+		log.Printf("session %s: failed to list open issues: %v", sess.ID, err)
+		return nil
+	}
+	var issues []github.Issue
+	for _, issue := range all {
+		if issue.IsPullRequest() || issue.Number == sess.IssueNumber {
+			continue
+		}
+		if len(issues) == limit {
+			break
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// failedTriaging reports whether the session's last error happened while
+// triaging.
+func (o *Orchestrator) failedTriaging(ctx context.Context, sess *session.Session) (bool, error) {
+	events, err := o.sessions.GetEvents(ctx, sess.ID, session.EventFilter{
+		Types: []session.EventType{session.EventTypeError},
+	})
+	if err != nil || len(events) == 0 {
+		return false, err
+	}
+	var payload eventPayload
+	json.Unmarshal([]byte(events[len(events)-1].Payload), &payload)
+	return payload.Phase == string(session.PhaseTriaging), nil
+}
@@ -0,0 +1,116 @@
+package orchestrator
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/store"
+)
+
+func TestFinishTriage(t *testing.T) {
+	var comments []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method == http.MethodPost && r.URL.Path == "/repos/acme/widgets/issues/7/comments" {
+			comments = append(comments, string(body))
+			w.WriteHeader(http.StatusCreated)
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	db, err := store.OpenInMemory()
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	sessions := session.NewSQLStore(db)
+	sess := session.NewSession("acme", "widgets", 7)
+	sess.Phase = session.PhaseTriaging
+	if err := sessions.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	o := New(&config.Config{}, sessions, github.NewClient("token", github.WithBaseURL(server.URL)))
+	labels := []github.Label{{Name: "Bug"}, {Name: "ui"}}
+	j := &job.Job{ID: "job-1", Plan: "The login form drops the password.\n\n```manfred-triage\n" +
+		`{"labels": ["bug", "security"], "modules": ["web/login"], "estimate": "small", "duplicates": [7, 3]}` +
+		"\n```"}
+	if err := o.finishTriage(ctx, sess, labels, j); err != nil {
+		t.Fatalf("finishTriage() error = %v", err)
+	}
+
+	got, err := sessions.Get(ctx, sess.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Phase != session.PhaseTriaged {
+		t.Errorf("phase = %s, want triaged", got.Phase)
+	}
+	events, err := sessions.GetEvents(ctx, sess.ID, session.EventFilter{Types: []session.EventType{session.EventTypePhaseChange}})
+	if err != nil || len(events) != 1 {
+		t.Fatalf("GetEvents() = %d events, %v, want 1", len(events), err)
+	}
+	for _, want := range []string{`"labels":"Bug"`, `"duplicates":"3"`, `"estimate":"small"`} {
+		if !strings.Contains(events[0].Payload, want) {
+			t.Errorf("event payload = %s, want %s", events[0].Payload, want)
+		}
+	}
+	if len(comments) != 1 {
+		t.Fatalf("comments = %q, want the triage", comments)
+	}
+	for _, want := range []string{"The login form drops the password.", "`Bug`", "#3"} {
+		if !strings.Contains(comments[0], want) {
+			t.Errorf("comment = %s, want %q", comments[0], want)
+		}
+	}
+	if strings.Contains(comments[0], "security") || strings.Contains(comments[0], "#7") {
+		t.Errorf("comment = %s, want unknown labels and the issue itself dropped", comments[0])
+	}
+}
+
+func TestFailedTriaging(t *testing.T) {
+	db, err := store.OpenInMemory()
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	sessions := session.NewSQLStore(db)
+	sess := session.NewSession("acme", "widgets", 7)
+	sess.Phase = session.PhaseError
+	if err := sessions.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	o := New(&config.Config{}, sessions, nil)
+
+	for _, tt := range []struct {
+		phase string
+		want  bool
+	}{
+		{"triaging", true},
+		{"planning", false},
+	} {
+		if err := sessions.RecordEvent(ctx, sess.ID, session.EventTypeError, map[string]string{"phase": tt.phase}); err != nil {
+			t.Fatalf("RecordEvent() error = %v", err)
+		}
+		if got, err := o.failedTriaging(ctx, sess); err != nil || got != tt.want {
+			t.Errorf("failedTriaging() after an error while %s = %v, %v, want %v", tt.phase, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// failJob fails the session with the error of a failed job, linking the job
+// log from the error comment if uploads are enabled. A job that failed to
+// rebase its branch reports the conflicting files instead.
+func (o *Orchestrator) failJob(ctx context.Context, sess *session.Session, number int, j *job.Job) error {
+	cause := fmt.Errorf("job %s failed: %s", j.ID, j.Error)
+	if c := j.Conflict; c != nil {
+		return o.failWithComment(ctx, sess, number, cause, github.FormatConflictComment(sess.ID, string(sess.Phase), j.BranchName, c.Base, c.Files))
+	}
+
+	var links []github.OutputLink
+	if url := o.uploadFile(ctx, sess, j.LogFile(), "job-"+j.ID+".log", fmt.Sprintf("MANFRED job %s log", j.ID)); url != "" {
+		links = append(links, github.OutputLink{Name: "job log", URL: url})
+	}
+	return o.fail(ctx, sess, number, cause, links...)
+}
+
+// uploadFile uploads a file and returns its link, or "" if uploads are
+// disabled or the upload failed, which is logged.
+func (o *Orchestrator) uploadFile(ctx context.Context, sess *session.Session, path, name, description string) string {
+	if o.uploads == nil {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("session %s: failed to read %s for upload: %v", sess.ID, name, err)
+		return ""
+	}
+	return o.upload(ctx, sess, name, description, data)
+}
+
+// upload uploads data and returns its link, or "" if uploads are disabled or
+// the upload failed, which is logged.
+func (o *Orchestrator) upload(ctx context.Context, sess *session.Session, name, description string, data []byte) string {
+	if o.uploads == nil {
+		return ""
+	}
+	up, err := o.uploads.Upload(ctx, name, description, data)
+	if err != nil {
+		log.Printf("session %s: %v", sess.ID, err)
+	}
+	if up == nil {
+		return ""
+	}
+	return up.URL
+}
+
+// linkIfLarge returns text, or, if it is over the upload threshold, text
+// truncated with a link to the full text uploaded as name.
+func (o *Orchestrator) linkIfLarge(ctx context.Context, sess *session.Session, text, name, description string) string {
+	if o.uploads == nil || len(text) <= o.uploads.Threshold() {
+		return text
+	}
+	url := o.upload(ctx, sess, name, description, []byte(text))
+	if url == "" {
+		return text
+	}
+	return github.TruncateComment(text, o.uploads.Threshold(), url)
+}
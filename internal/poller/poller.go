@@ -0,0 +1,313 @@
+// Package poller reads GitHub activity through the API and feeds it to the
+// webhook router as synthesized events, for deployments that cannot receive
+// inbound webhooks.
+package poller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// MinInterval is the shortest poll interval; shorter settings are raised to
+// it to stay well within the API rate limit.
+const MinInterval = 30 * time.Second
+
+// Handler receives the synthesized events; *webhook.Router implements it.
+type Handler interface {
+	HandleEvent(ctx context.Context, event *github.WebhookEvent) error
+}
+
+// SessionLister lists sessions; session.Store implements it.
+type SessionLister interface {
+	List(ctx context.Context, filter session.SessionFilter) ([]session.Session, error)
+}
+
+// Poller periodically lists each configured repository's issue events and
+// comments, and the reviews of pull requests in review. Only activity newer
+// than the first poll is forwarded; activity while MANFRED is not running is
+// not replayed.
+type Poller struct {
+	config   *config.Config
+	sessions SessionLister
+	github   *github.Client
+	handler  Handler
+
+	repos   map[string]*repoCursor // "owner/repo" -> cursor
+	reviews map[string]int64       // "owner/repo#pr" -> last review ID
+	merged  map[string]bool        // "owner/repo#pr" -> merge forwarded
+
+	// polled is set after the first round, which only records cursors
+	polled bool
+
+	// handlers tracks events still being handled
+	handlers sync.WaitGroup
+}
+
+// repoCursor holds the newest IDs already seen in a repository's feeds.
+type repoCursor struct {
+	event   int64
+	comment int64
+}
+
+// New creates a poller that forwards events to handler.
+func New(cfg *config.Config, sessions SessionLister, gh *github.Client, handler Handler) *Poller {
+	return &Poller{
+		config:   cfg,
+		sessions: sessions,
+		github:   gh,
+		handler:  handler,
+		repos:    map[string]*repoCursor{},
+		reviews:  map[string]int64{},
+		merged:   map[string]bool{},
+	}
+}
+
+// Run polls every interval until ctx is done. Errors are passed to onError
+// and do not stop the loop.
+func (p *Poller) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	if interval < MinInterval {
+		interval = MinInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Poll(ctx); err != nil && onError != nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll runs one polling round over all repositories and sessions in review.
+// It returns the first error; later repositories are still polled.
+func (p *Poller) Poll(ctx context.Context) error {
+	repos, err := p.config.GitHubRepos()
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	keep := func(err error) {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, fullName := range repos {
+		owner, name, _ := strings.Cut(fullName, "/")
+		keep(p.pollRepo(ctx, owner, name))
+	}
+	keep(p.pollReviews(ctx))
+	p.polled = true
+	return firstErr
+}
+
+// pollRepo forwards new "labeled" issue events and new comments.
+func (p *Poller) pollRepo(ctx context.Context, owner, name string) error {
+	key := owner + "/" + name
+	repo := github.Repo{Owner: github.User{Login: owner}, Name: name, FullName: key}
+
+	events, err := p.github.ListRepoIssueEvents(ctx, owner, name)
+	if err != nil {
+		return fmt.Errorf("poll %s issue events: %w", key, err)
+	}
+	comments, err := p.github.ListRepoIssueComments(ctx, owner, name)
+	if err != nil {
+		return fmt.Errorf("poll %s comments: %w", key, err)
+	}
+
+	cursor, seen := p.repos[key]
+	if !seen {
+		// First poll of a repository: remember where the feeds are and
+		// forward nothing.
+		cursor = &repoCursor{}
+		p.repos[key] = cursor
+	}
+	lastEvent, lastComment := cursor.event, cursor.comment
+	cursor.event = maxEventID(events, cursor.event)
+	cursor.comment = maxCommentID(comments, cursor.comment)
+	if !seen {
+		return nil
+	}
+
+	if n := len(events); n > 0 && lastEvent > 0 && events[n-1].ID > lastEvent {
+		log.Printf("poller: more than %d issue events in %s since the last poll, some may be missed", n, key)
+	}
+	if n := len(comments); n > 0 && lastComment > 0 && comments[n-1].ID > lastComment {
+		log.Printf("poller: more than %d comments in %s since the last poll, some may be missed", n, key)
+	}
+
+	// Feeds are newest first; forward oldest first.
+	var firstErr error
+	for i := len(events) - 1; i >= 0; i-- {
+		ev := events[i]
+		if ev.ID <= lastEvent || ev.Event != "labeled" || ev.Label == nil || ev.Issue == nil {
+			continue
+		}
+		err := p.dispatch("issues", &github.IssueEvent{
+			Action: "labeled",
+			Issue:  *ev.Issue,
+			Label:  ev.Label,
+			Repo:   repo,
+			Sender: ev.Actor,
+		})
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	for i := len(comments) - 1; i >= 0; i-- {
+		c := comments[i]
+		number := c.IssueNumber()
+		if c.ID <= lastComment || number == 0 || github.IsManfredComment(c.Body) {
+			continue
+		}
+		issue, err := p.github.GetIssue(ctx, owner, name, number)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("poll %s#%d: %w", key, number, err)
+			}
+			continue
+		}
+		err = p.dispatch("issue_comment", &github.IssueCommentEvent{
+			Action:  "created",
+			Issue:   *issue,
+			Comment: c,
+			Repo:    repo,
+			Sender:  c.User,
+		})
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// pollReviews forwards new reviews and merges of session pull requests that
+// are in review.
+func (p *Poller) pollReviews(ctx context.Context) error {
+	phase := session.PhaseInReview
+	sessions, err := p.sessions.List(ctx, session.SessionFilter{Phase: &phase})
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	for _, sess := range sessions {
+		if sess.PRNumber == nil {
+			continue
+		}
+		if err := p.pollPullRequest(ctx, &sess); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// pollPullRequest checks one session pull request for new reviews and for
+// being merged.
+func (p *Poller) pollPullRequest(ctx context.Context, sess *session.Session) error {
+	owner, name, number := sess.RepoOwner, sess.RepoName, *sess.PRNumber
+	key := fmt.Sprintf("%s/%s#%d", owner, name, number)
+	repo := github.Repo{Owner: github.User{Login: owner}, Name: name, FullName: owner + "/" + name}
+
+	pr, err := p.github.GetPullRequest(ctx, owner, name, number)
+	if err != nil {
+		return fmt.Errorf("poll %s: %w", key, err)
+	}
+	reviews, err := p.github.ListReviews(ctx, owner, name, number)
+	if err != nil {
+		return fmt.Errorf("poll %s reviews: %w", key, err)
+	}
+
+	// Pull requests already in review when the poller starts are baselined;
+	// ones entering review later are new to the poller and fully forwarded.
+	// Pending reviews are not submitted yet and keep their ID when they are.
+	last := p.reviews[key]
+	for _, r := range reviews {
+		if strings.EqualFold(r.State, "pending") || r.ID <= last {
+			continue
+		}
+		p.reviews[key] = r.ID
+		if !p.polled {
+			continue
+		}
+		err := p.dispatch("pull_request_review", &github.PullRequestReviewEvent{
+			Action:      "submitted",
+			Review:      r,
+			PullRequest: *pr,
+			Repo:        repo,
+			Sender:      r.User,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if pr.Merged && !p.merged[key] {
+		p.merged[key] = true
+		return p.dispatch("pull_request", &github.PullRequestEvent{
+			Action:      "closed",
+			Number:      number,
+			PullRequest: *pr,
+			Repo:        repo,
+		})
+	}
+	return nil
+}
+
+// dispatch wraps payload as a webhook event and hands it to the router. Like
+// webhook deliveries, events are handled in the background so a long job
+// does not hold up polling.
+func (p *Poller) dispatch(eventType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	event, err := github.ParseWebhookEvent(eventType, data)
+	if err != nil {
+		return err
+	}
+
+	p.handlers.Add(1)
+	go func() {
+		defer p.handlers.Done()
+		if err := p.handler.HandleEvent(context.Background(), event); err != nil {
+			log.Printf("poller: %s event: %v", eventType, err)
+		}
+	}()
+	return nil
+}
+
+func maxEventID(events []github.IssueActivity, id int64) int64 {
+	for _, e := range events {
+		if e.ID > id {
+			id = e.ID
+		}
+	}
+	return id
+}
+
+func maxCommentID(comments []github.Comment, id int64) int64 {
+	for _, c := range comments {
+		if c.ID > id {
+			id = c.ID
+		}
+	}
+	return id
+}
@@ -0,0 +1,146 @@
+package poller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// fakeGitHub serves the endpoints the poller reads from mutable state.
+type fakeGitHub struct {
+	mu       sync.Mutex
+	events   []github.IssueActivity
+	comments []github.Comment
+	reviews  []github.Review
+	merged   bool
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var body interface{}
+	switch r.URL.Path {
+	case "/repos/owner/repo/issues/events":
+		body = f.events
+	case "/repos/owner/repo/issues/comments":
+		body = f.comments
+	case "/repos/owner/repo/issues/5":
+		body = github.Issue{Number: 5, State: "open"}
+	case "/repos/owner/repo/pulls/7":
+		body = github.PullRequest{Number: 7, State: "open", Merged: f.merged}
+	case "/repos/owner/repo/pulls/7/reviews":
+		body = f.reviews
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	json.NewEncoder(w).Encode(body)
+}
+
+type fakeSessions []session.Session
+
+func (f fakeSessions) List(ctx context.Context, filter session.SessionFilter) ([]session.Session, error) {
+	return f, nil
+}
+
+// recorder collects the events handed to the router.
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) HandleEvent(ctx context.Context, event *github.WebhookEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event.Type+":"+event.Action)
+	return nil
+}
+
+func TestPoll(t *testing.T) {
+	fake := &fakeGitHub{
+		events:   []github.IssueActivity{{ID: 10, Event: "labeled", Label: &github.Label{Name: "manfred"}, Issue: &github.Issue{Number: 1}}},
+		comments: []github.Comment{{ID: 100, Body: "old", IssueURL: "https://api.github.com/repos/owner/repo/issues/1"}},
+		reviews:  []github.Review{{ID: 500, State: "COMMENTED"}},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	cfg := &config.Config{ProjectsDir: t.TempDir()}
+	projectDir := filepath.Join(cfg.ProjectsDir, "proj")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "project.yml"), []byte("repo: https://github.com/owner/repo.git\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pr := 7
+	sessions := fakeSessions{{RepoOwner: "owner", RepoName: "repo", PRNumber: &pr, Phase: session.PhaseInReview}}
+	rec := &recorder{}
+	p := New(cfg, sessions, github.NewClient("token", github.WithBaseURL(server.URL)), rec)
+	ctx := context.Background()
+
+	// The first round only records where the feeds are.
+	if err := p.Poll(ctx); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+	p.handlers.Wait()
+	if len(rec.events) != 0 {
+		t.Fatalf("first Poll() forwarded %v, want nothing", rec.events)
+	}
+
+	fake.mu.Lock()
+	fake.events = append([]github.IssueActivity{
+		{ID: 12, Event: "closed", Issue: &github.Issue{Number: 2}},
+		{ID: 11, Event: "labeled", Label: &github.Label{Name: "manfred"}, Issue: &github.Issue{Number: 5}, Actor: github.User{Login: "alice"}},
+	}, fake.events...)
+	fake.comments = append([]github.Comment{
+		{ID: 102, Body: "<!-- manfred:session:s1:phase:planning -->\nstatus", IssueURL: "https://api.github.com/repos/owner/repo/issues/5"},
+		{ID: 101, Body: "/manfred plan", IssueURL: "https://api.github.com/repos/owner/repo/issues/5"},
+	}, fake.comments...)
+	fake.reviews = append(fake.reviews,
+		github.Review{ID: 501, State: "CHANGES_REQUESTED", Body: "fix it"},
+		github.Review{ID: 502, State: "PENDING"},
+	)
+	fake.merged = true
+	fake.mu.Unlock()
+
+	if err := p.Poll(ctx); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+	p.handlers.Wait()
+
+	got := append([]string(nil), rec.events...)
+	sort.Strings(got)
+	want := []string{"issue_comment:created", "issues:labeled", "pull_request:closed", "pull_request_review:submitted"}
+	if len(got) != len(want) {
+		t.Fatalf("forwarded %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("forwarded %v, want %v", got, want)
+			break
+		}
+	}
+
+	// Nothing new: nothing is forwarded again.
+	rec.events = nil
+	if err := p.Poll(ctx); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+	p.handlers.Wait()
+	if len(rec.events) != 0 {
+		t.Errorf("third Poll() forwarded %v, want nothing", rec.events)
+	}
+}
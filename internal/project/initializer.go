@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 
 	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/gitops"
 	"gopkg.in/yaml.v3"
 )
 
@@ -21,33 +22,67 @@ func NewInitializer(cfg *config.Config) *Initializer {
 	return &Initializer{config: cfg}
 }
 
+// InitOptions customize a new project.
+type InitOptions struct {
+	// Template is the stack template (see TemplateNames) used when the
+	// repository has no compose file; empty uses none.
+	Template string
+}
+
+// InitResult describes an initialized project.
+type InitResult struct {
+	ComposeFile      string // Relative to the repository
+	ComposeGenerated bool   // Written from the template
+}
+
 // Init initializes a new project by cloning the repository.
-func (i *Initializer) Init(ctx context.Context, name, repoURL string) error {
+func (i *Initializer) Init(ctx context.Context, name, repoURL string, opts InitOptions) (*InitResult, error) {
 	projectDir := filepath.Join(i.config.ProjectsDir, name)
 	repoDir := filepath.Join(projectDir, "repository")
 
+	var template *Template
+	if opts.Template != "" {
+		t, err := LookupTemplate(opts.Template)
+		if err != nil {
+			return nil, err
+		}
+		template = &t
+	}
+
 	// Check if project already exists
 	if _, err := os.Stat(projectDir); err == nil {
-		return fmt.Errorf("project already exists: %s", name)
+		return nil, fmt.Errorf("project already exists: %s", name)
 	}
 
 	// Create project directory
 	if err := os.MkdirAll(projectDir, 0755); err != nil {
-		return fmt.Errorf("failed to create project directory: %w", err)
+		return nil, fmt.Errorf("failed to create project directory: %w", err)
 	}
 
 	// Clone repository
-	cmd := exec.CommandContext(ctx, "git", "clone", repoURL, repoDir)
-	if output, err := cmd.CombinedOutput(); err != nil {
+	token, err := i.config.GitToken(ctx, repoURL)
+	if err != nil {
+		os.RemoveAll(projectDir)
+		return nil, err
+	}
+	auth := gitops.Auth{Token: token, SSHKey: i.config.GitSSHKey(nil)}
+	if _, err := gitops.Clone(ctx, repoURL, repoDir, gitops.CloneOptions{Auth: auth}); err != nil {
 		os.RemoveAll(projectDir) // Cleanup on failure
-		return fmt.Errorf("failed to clone repository: %w\n%s", err, output)
+		return nil, fmt.Errorf("failed to clone repository: %w", err)
 	}
 
 	// Detect default branch
 	defaultBranch := detectDefaultBranch(ctx, repoDir)
 
-	// Detect compose file
-	composeFile := detectComposeFile(repoDir)
+	// Detect compose file, or generate one from the template
+	composeFile, found := detectComposeFile(repoDir)
+	result := &InitResult{ComposeFile: composeFile}
+	if !found && template != nil {
+		if err := os.WriteFile(filepath.Join(repoDir, composeFile), []byte(template.Compose), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", composeFile, err)
+		}
+		result.ComposeGenerated = true
+	}
 
 	// Generate project.yml
 	projectConfig := config.ProjectConfig{
@@ -60,18 +95,21 @@ func (i *Initializer) Init(ctx context.Context, name, repoURL string) error {
 			Workdir:     "/app",
 		},
 	}
+	if template != nil {
+		projectConfig.Test.Command = template.TestCommand
+	}
 
 	projectYml := filepath.Join(projectDir, "project.yml")
 	data, err := yaml.Marshal(&projectConfig)
 	if err != nil {
-		return fmt.Errorf("failed to serialize project config: %w", err)
+		return nil, fmt.Errorf("failed to serialize project config: %w", err)
 	}
 
 	if err := os.WriteFile(projectYml, data, 0644); err != nil {
-		return fmt.Errorf("failed to write project.yml: %w", err)
+		return nil, fmt.Errorf("failed to write project.yml: %w", err)
 	}
 
-	return nil
+	return result, nil
 }
 
 func detectDefaultBranch(ctx context.Context, repoDir string) string {
@@ -88,7 +126,9 @@ func detectDefaultBranch(ctx context.Context, repoDir string) string {
 	return "main"
 }
 
-func detectComposeFile(repoDir string) string {
+// detectComposeFile returns the repository's compose file and whether it
+// exists; without one, it returns the name a new one gets.
+func detectComposeFile(repoDir string) (string, bool) {
 	// Check for common compose file names
 	candidates := []string{
 		"docker-compose.yml",
@@ -99,9 +139,9 @@ func detectComposeFile(repoDir string) string {
 
 	for _, name := range candidates {
 		if _, err := os.Stat(filepath.Join(repoDir, name)); err == nil {
-			return name
+			return name, true
 		}
 	}
 
-	return "docker-compose.yml"
+	return "docker-compose.yml", false
 }
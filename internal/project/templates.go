@@ -0,0 +1,103 @@
+package project
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Template is a starting point for projects of a common stack whose
+// repository ships no compose file: a compose file running a
+// devcontainer-style image with the stack's toolchain, and the test command
+// for project.yml.
+type Template struct {
+	Name        string
+	Description string
+	Compose     string // docker-compose.yml, with the repository mounted at /app
+	TestCommand string
+}
+
+// templates are the templates of `project init --template`, by name.
+var templates = map[string]Template{
+	"go": {
+		Name:        "go",
+		Description: "Go toolchain (devcontainers/go)",
+		TestCommand: "go test ./...",
+		Compose: `services:
+  app:
+    image: mcr.microsoft.com/devcontainers/go:1
+    command: sleep infinity
+    working_dir: /app
+    volumes:
+      - .:/app
+      - go-cache:/go/pkg/mod
+    environment:
+      - ANTHROPIC_API_KEY
+
+volumes:
+  go-cache:
+`,
+	},
+	"node": {
+		Name:        "node",
+		Description: "Node.js LTS with npm and yarn (devcontainers/javascript-node)",
+		TestCommand: "npm ci && npm test",
+		Compose: `services:
+  app:
+    image: mcr.microsoft.com/devcontainers/javascript-node:lts
+    command: sleep infinity
+    working_dir: /app
+    volumes:
+      - .:/app
+    environment:
+      - ANTHROPIC_API_KEY
+`,
+	},
+	"rails": {
+		Name:        "rails",
+		Description: "Ruby with PostgreSQL (devcontainers/ruby, postgres)",
+		TestCommand: "bundle install && bin/rails db:prepare && bin/rails test",
+		Compose: `services:
+  app:
+    image: mcr.microsoft.com/devcontainers/ruby:3
+    command: sleep infinity
+    working_dir: /app
+    volumes:
+      - .:/app
+      - bundle:/usr/local/rvm/gems
+    environment:
+      - ANTHROPIC_API_KEY
+      - RAILS_ENV=test
+      - DATABASE_URL=postgres://postgres:postgres@db:5432/app_test
+    depends_on:
+      - db
+
+  db:
+    image: postgres:16
+    environment:
+      - POSTGRES_PASSWORD=postgres
+
+volumes:
+  bundle:
+`,
+	},
+}
+
+// LookupTemplate returns the template name.
+func LookupTemplate(name string) (Template, error) {
+	t, ok := templates[name]
+	if !ok {
+		return Template{}, fmt.Errorf("unknown template %q (want %s)", name, strings.Join(TemplateNames(), ", "))
+	}
+	return t, nil
+}
+
+// TemplateNames returns the names of the templates, sorted.
+func TemplateNames() []string {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
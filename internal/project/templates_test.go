@@ -0,0 +1,76 @@
+package project
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/mpm/manfred/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+func TestTemplates(t *testing.T) {
+	for _, name := range TemplateNames() {
+		tmpl, err := LookupTemplate(name)
+		if err != nil {
+			t.Fatalf("LookupTemplate(%q) error = %v", name, err)
+		}
+		var compose struct {
+			Services map[string]struct {
+				Image      string `yaml:"image"`
+				WorkingDir string `yaml:"working_dir"`
+			} `yaml:"services"`
+		}
+		if err := yaml.Unmarshal([]byte(tmpl.Compose), &compose); err != nil {
+			t.Fatalf("%s: compose file does not parse: %v", name, err)
+		}
+		app, ok := compose.Services["app"]
+		if !ok || app.Image == "" || app.WorkingDir != "/app" {
+			t.Errorf("%s: app service = %+v, want an image working in /app", name, app)
+		}
+		if tmpl.TestCommand == "" {
+			t.Errorf("%s: no test command", name)
+		}
+	}
+
+	if _, err := LookupTemplate("cobol"); err == nil {
+		t.Error("LookupTemplate(cobol) succeeded, want error")
+	}
+}
+
+func TestInitWithTemplate(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	src := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "base"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", src}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	cfg := &config.Config{ProjectsDir: t.TempDir()}
+	result, err := NewInitializer(cfg).Init(context.Background(), "widgets", src, InitOptions{Template: "go"})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if !result.ComposeGenerated || result.ComposeFile != "docker-compose.yml" {
+		t.Errorf("Init() = %+v, want a generated docker-compose.yml", result)
+	}
+	data, err := os.ReadFile(filepath.Join(cfg.ProjectsDir, "widgets", "repository", "docker-compose.yml"))
+	if err != nil || string(data) != templates["go"].Compose {
+		t.Errorf("compose file = %q, %v", data, err)
+	}
+	projectConfig, err := cfg.ProjectConfig("widgets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if projectConfig.Test.Command != "go test ./..." || projectConfig.DefaultBranch != "main" {
+		t.Errorf("project.yml test.command = %q, default_branch = %q", projectConfig.Test.Command, projectConfig.DefaultBranch)
+	}
+}
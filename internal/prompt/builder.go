@@ -0,0 +1,99 @@
+// Package prompt builds the prompts sent to Claude for each session phase.
+package prompt
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// Context holds the data available to prompt templates.
+type Context struct {
+	Session  *session.Session
+	Issue    *github.Issue
+	Comments []github.Comment
+
+	// OutputHelper is the container path of the script Claude uses to hand
+	// the plan back (planning). Empty when Claude replies with the plan.
+	OutputHelper string
+
+	// Plan is the approved implementation plan (implementing).
+	Plan string
+
+	// PreviousPlan is the plan sent back with Feedback (planning).
+	PreviousPlan string
+
+	// Clarifications are the clarifying questions Claude asked before
+	// planning and their answers, oldest first (planning, awaiting_answers).
+	Clarifications []Clarification
+
+	// AskQuestions lets Claude reply with clarifying questions instead of
+	// a plan (planning, awaiting_answers).
+	AskQuestions bool
+
+	// PRNumber and Feedback are used when revising a pull request.
+	// Feedback also holds the requested plan changes when replanning.
+	PRNumber int
+	Feedback string
+
+	// CIFailure marks Feedback as the output of failed CI checks rather
+	// than review comments (revising).
+	CIFailure bool
+
+	// Labels are the repository's labels to suggest from, and OpenIssues
+	// its other open issues to detect duplicates among (triaging).
+	Labels     []github.Label
+	OpenIssues []github.Issue
+}
+
+// Clarification is a round of clarifying questions and the reply to them.
+type Clarification struct {
+	Questions string
+	Answer    string
+	User      string // GitHub login of who answered
+}
+
+// LastClarification returns the latest round, the one a continued
+// conversation is answered with.
+func (c *Context) LastClarification() Clarification {
+	if len(c.Clarifications) == 0 {
+		return Clarification{}
+	}
+	return c.Clarifications[len(c.Clarifications)-1]
+}
+
+// Builder renders phase-specific prompts.
+type Builder struct {
+	templates map[session.Phase]*template.Template
+}
+
+// NewBuilder creates a builder with the built-in templates.
+func NewBuilder() *Builder {
+	return &Builder{
+		templates: map[session.Phase]*template.Template{
+			session.PhasePlanning:        template.Must(template.New("planning").Parse(planningTemplate + questionsTemplate)),
+			session.PhaseAwaitingAnswers: template.Must(template.New("answers").Parse(answersTemplate + questionsTemplate)),
+			session.PhaseImplementing:    template.Must(template.New("implementing").Parse(implementingTemplate)),
+			session.PhaseRevising:        template.Must(template.New("revising").Parse(revisingTemplate)),
+			session.PhaseTriaging:        template.Must(template.New("triaging").Parse(triagingTemplate)),
+		},
+	}
+}
+
+// Build renders the prompt for a phase. The awaiting_answers prompt hands
+// the answers to Claude's questions back in the planning conversation.
+func (b *Builder) Build(phase session.Phase, ctx *Context) (string, error) {
+	tmpl, ok := b.templates[phase]
+	if !ok {
+		return "", fmt.Errorf("no prompt template for phase %s", phase)
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, ctx); err != nil {
+		return "", fmt.Errorf("render %s prompt: %w", phase, err)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,207 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+)
+
+func TestBuildPlanning(t *testing.T) {
+	b := NewBuilder()
+	sess := session.NewSession("owner", "repo", 42)
+
+	got, err := b.Build(session.PhasePlanning, &Context{
+		Session: sess,
+		Issue:   &github.Issue{Number: 42, Title: "Add login", Body: "We need a login page."},
+		Comments: []github.Comment{
+			{Body: "Use OAuth", User: github.User{Login: "alice"}, CreatedAt: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)},
+		},
+		OutputHelper: "/manfred-job/bin/manfred-output",
+	})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"issue #42 in repository owner/repo",
+		"Title: Add login",
+		"We need a login page.",
+		"@alice (2026-01-02):",
+		"Use OAuth",
+		"/manfred-job/bin/manfred-output plan",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("prompt missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestBuildPlanningWithoutHelper(t *testing.T) {
+	got, err := NewBuilder().Build(session.PhasePlanning, &Context{
+		Session: session.NewSession("owner", "repo", 42),
+		Issue:   &github.Issue{Number: 42, Title: "Add login"},
+	})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if strings.Contains(got, "plan.md") || !strings.Contains(got, "Reply with the plan as Markdown.") {
+		t.Errorf("prompt should ask for the plan as the reply:\n%s", got)
+	}
+}
+
+func TestBuildPlanningRevision(t *testing.T) {
+	got, err := NewBuilder().Build(session.PhasePlanning, &Context{
+		Session:      session.NewSession("owner", "repo", 42),
+		Issue:        &github.Issue{Number: 42, Title: "Add login"},
+		PreviousPlan: "1. Add a login form",
+		Feedback:     "Use OAuth instead",
+	})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	for _, want := range []string{"1. Add a login form", "Use OAuth instead", "Changes from the previous"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("prompt missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestBuildPlanningQuestions(t *testing.T) {
+	ctx := &Context{
+		Session: session.NewSession("owner", "repo", 42),
+		Issue:   &github.Issue{Number: 42, Title: "Add login"},
+	}
+	got, err := NewBuilder().Build(session.PhasePlanning, ctx)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if strings.Contains(got, "manfred-questions") {
+		t.Errorf("prompt offers questions without AskQuestions:\n%s", got)
+	}
+
+	ctx.AskQuestions = true
+	ctx.Clarifications = []Clarification{{Questions: "1. Which provider?", Answer: "GitHub", User: "alice"}}
+	got, err = NewBuilder().Build(session.PhasePlanning, ctx)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	for _, want := range []string{"manfred-questions", "1. Which provider?", "Answer from @alice:\n\nGitHub"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("prompt missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestBuildAnswers(t *testing.T) {
+	got, err := NewBuilder().Build(session.PhaseAwaitingAnswers, &Context{
+		Session: session.NewSession("owner", "repo", 42),
+		Clarifications: []Clarification{
+			{Questions: "1. Which provider?", Answer: "GitHub", User: "alice"},
+			{Questions: "1. Which scopes?", Answer: "read:user", User: "bob"},
+		},
+		OutputHelper: "/manfred-job/bin/manfred-output",
+	})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	for _, want := range []string{"answered by @bob:\n\nread:user", "Do not ask further questions", "/manfred-job/bin/manfred-output plan"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("prompt missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "GitHub") {
+		t.Errorf("prompt repeats earlier answers:\n%s", got)
+	}
+}
+
+func TestBuildImplementing(t *testing.T) {
+	b := NewBuilder()
+	sess := session.NewSession("owner", "repo", 42)
+
+	got, err := b.Build(session.PhaseImplementing, &Context{
+		Session: sess,
+		Issue:   &github.Issue{Number: 42, Title: "Add login"},
+		Plan:    "1. Add a login handler",
+	})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	for _, want := range []string{"Title: Add login", "1. Add a login handler", "(claude/issue-42)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("prompt missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestBuildRevising(t *testing.T) {
+	b := NewBuilder()
+	sess := session.NewSession("owner", "repo", 42)
+
+	got, err := b.Build(session.PhaseRevising, &Context{
+		Session:  sess,
+		PRNumber: 7,
+		Feedback: "Rename foo to bar",
+	})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if !strings.Contains(got, "pull request #7 on branch claude/issue-42") {
+		t.Errorf("prompt missing PR header:\n%s", got)
+	}
+	if !strings.Contains(got, "Rename foo to bar") {
+		t.Errorf("prompt missing feedback:\n%s", got)
+	}
+	if strings.Contains(got, "CI failed") {
+		t.Errorf("review prompt mentions CI:\n%s", got)
+	}
+
+	got, err = b.Build(session.PhaseRevising, &Context{
+		Session:   sess,
+		PRNumber:  7,
+		Feedback:  "--- FAIL: TestLogin",
+		CIFailure: true,
+	})
+	if err != nil {
+		t.Fatalf("Build() for CI error = %v", err)
+	}
+	if !strings.Contains(got, "CI failed on the latest commit") || !strings.Contains(got, "--- FAIL: TestLogin") {
+		t.Errorf("prompt missing CI failure:\n%s", got)
+	}
+	if strings.Contains(got, "Reviewers left") || !strings.HasSuffix(got, "commit your changes.\nDo not disable or skip the failing checks.\nDo not create a new branch.") {
+		t.Errorf("CI prompt = %q", got)
+	}
+}
+
+func TestBuildTriaging(t *testing.T) {
+	got, err := NewBuilder().Build(session.PhaseTriaging, &Context{
+		Session:    session.NewSession("owner", "repo", 42),
+		Issue:      &github.Issue{Number: 42, Title: "Login fails", Body: "The form hangs."},
+		Labels:     []github.Label{{Name: "bug", Description: "Something is broken"}, {Name: "ui"}},
+		OpenIssues: []github.Issue{{Number: 17, Title: "Login form hangs on submit"}},
+	})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"triaging GitHub issue #42 in repository owner/repo",
+		"The form hangs.",
+		"- bug: Something is broken\n- ui\n",
+		"- #17 Login form hangs on submit\n",
+		"```manfred-triage\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("prompt missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestBuildUnknownPhase(t *testing.T) {
+	b := NewBuilder()
+	if _, err := b.Build(session.PhaseCompleted, &Context{}); err == nil {
+		t.Error("Build(completed) = nil error, want error")
+	}
+}
@@ -0,0 +1,182 @@
+package prompt
+
+// planningTemplate asks Claude for an implementation plan without writing code.
+const planningTemplate = `You are working on GitHub issue #{{.Issue.Number}} in repository {{.Session.RepoOwner}}/{{.Session.RepoName}}.
+
+Title: {{.Issue.Title}}
+
+Description:
+{{.Issue.Body}}
+{{if .Comments}}
+Previous comments:
+{{range .Comments}}
+---
+@{{.User.Login}} ({{.CreatedAt.Format "2006-01-02"}}):
+{{.Body}}
+{{end}}{{end}}
+---
+{{if .Clarifications}}
+Before planning you asked clarifying questions, which were answered:
+{{range .Clarifications}}
+Questions:
+
+{{.Questions}}
+
+Answer from @{{.User}}:
+
+{{.Answer}}
+
+---
+{{end}}{{end}}{{if .PreviousPlan}}
+You proposed this plan before:
+
+{{.PreviousPlan}}
+
+---
+
+It was sent back for changes{{if .Feedback}} with this feedback:
+
+{{.Feedback}}{{else}}.{{end}}
+
+Revise the plan accordingly and end it with a "Changes from the previous
+plan" section listing what changed and why.
+
+---
+{{end}}
+Create a detailed implementation plan for this issue. Include:
+1. Your understanding of the requirements
+2. Files that need to be created or modified
+3. Step-by-step implementation approach
+4. Any questions or clarifications needed
+
+End the plan with your assessment for the approver, as a fenced block:
+
+` + "```" + `manfred-checklist
+{"risk": "low|medium|high", "size": "small|medium|large", "files": ["path/to/file.go"], "migration": false}
+` + "```" + `
+
+"files" lists the files you expect to touch; "migration" is true if a
+database or data migration is required.
+
+Do NOT implement yet. Only plan.
+{{template "questions" .}}{{if .OutputHelper}}
+Write the plan as Markdown to a file outside the repository, e.g. /tmp/plan.md,
+then hand it to MANFRED: {{.OutputHelper}} plan /tmp/plan.md{{else}}
+Reply with the plan as Markdown.{{end}}`
+
+// questionsTemplate offers Claude to ask clarifying questions instead of
+// planning. It is shared by planningTemplate and answersTemplate.
+const questionsTemplate = `{{define "questions"}}{{if .AskQuestions}}
+If the issue leaves open questions that block a good plan and that you cannot
+answer from the code, you may ask them instead of planning: hand back, in
+place of the plan, only a fenced block listing them:
+
+` + "```" + `manfred-questions
+1. Your first question
+` + "```" + `
+
+The questions are posted on the issue and you continue with the answers.
+Ask only what you need; assume sensible defaults for the rest.
+{{end}}{{end}}`
+
+// answersTemplate continues a planning conversation with the answers to
+// Claude's clarifying questions.
+const answersTemplate = `Your clarifying questions were answered by @{{.LastClarification.User}}:
+
+{{.LastClarification.Answer}}
+
+---
+
+Now create the implementation plan as asked before, ending with the
+manfred-checklist block. Do NOT implement yet. Only plan.
+{{if .AskQuestions}}{{template "questions" .}}{{else}}
+Do not ask further questions; list anything still unclear in the plan.
+{{end}}{{if .OutputHelper}}
+Write the plan as Markdown to a file outside the repository, e.g. /tmp/plan.md,
+then hand it to MANFRED: {{.OutputHelper}} plan /tmp/plan.md{{else}}
+Reply with the plan as Markdown.{{end}}`
+
+// implementingTemplate asks Claude to implement an approved plan.
+const implementingTemplate = `You are implementing GitHub issue #{{.Issue.Number}} in repository {{.Session.RepoOwner}}/{{.Session.RepoName}}.
+
+Title: {{.Issue.Title}}
+
+Description:
+{{.Issue.Body}}
+
+---
+
+The following implementation plan has been approved:
+
+{{.Plan}}
+
+---
+
+Implement the plan on the current branch ({{.Session.Branch}}) and commit your changes.
+Do not create a new branch.`
+
+// revisingTemplate asks Claude to address PR review feedback or fix a CI
+// failure.
+const revisingTemplate = `You are revising pull request #{{.PRNumber}} on branch {{.Session.Branch}}.
+{{if .CIFailure}}
+CI failed on the latest commit of this branch with the following output:
+
+{{.Feedback}}
+
+---
+
+Find the cause of the failure, fix it on this branch and commit your changes.
+Do not disable or skip the failing checks.
+{{- else}}
+Reviewers left the following feedback:
+
+{{.Feedback}}
+
+---
+
+Address the feedback by changing the code on this branch and commit your changes.
+{{- end}}
+Do not create a new branch.`
+
+// triagingTemplate asks Claude to triage an issue without planning or
+// writing code.
+const triagingTemplate = `You are triaging GitHub issue #{{.Issue.Number}} in repository {{.Session.RepoOwner}}/{{.Session.RepoName}}.
+
+Title: {{.Issue.Title}}
+
+Description:
+{{.Issue.Body}}
+{{if .Comments}}
+Previous comments:
+{{range .Comments}}
+---
+@{{.User.Login}} ({{.CreatedAt.Format "2006-01-02"}}):
+{{.Body}}
+{{end}}{{end}}
+---
+{{if .Labels}}
+Labels of the repository:
+{{range .Labels}}
+- {{.Name}}{{if .Description}}: {{.Description}}{{end}}{{end}}
+{{end}}{{if .OpenIssues}}
+Other open issues:
+{{range .OpenIssues}}
+- #{{.Number}} {{.Title}}{{end}}
+{{end}}
+Triage this issue; do not plan or implement it. Look at the code only as
+far as needed to tell which parts of the repository it affects. Reply with
+a short Markdown analysis covering:
+1. What the issue asks for, and whether it is a bug, a feature or a question
+2. The modules (directories or packages) it affects
+3. A rough estimate of the work: small (an hour or two), medium (a day) or
+   large (several days)
+4. Open issues above it likely duplicates, if any, and why
+
+End the reply with your assessment as a fenced block:
+
+` + "```" + `manfred-triage
+{"labels": ["bug"], "modules": ["path/to/module"], "estimate": "small|medium|large", "duplicates": [123]}
+` + "```" + `
+
+"labels" suggests labels of the repository listed above; leave it empty if
+none fit. "duplicates" lists issue numbers, empty if there are none.`
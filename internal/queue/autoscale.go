@@ -0,0 +1,146 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/mpm/manfred/internal/config"
+)
+
+// Autoscaling event types.
+const (
+	EventScaleUp   = "scale_up"
+	EventScaleDown = "scale_down"
+)
+
+// minInterval is the shortest accepted check interval.
+const minInterval = time.Second
+
+// Event is the JSON body posted to the autoscaling webhook.
+type Event struct {
+	Event     string    `json:"event"`
+	Reason    string    `json:"reason"`
+	Queue     Stats     `json:"queue"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Autoscaler watches a queue and posts an event to a webhook when a scale
+// threshold is crossed. Events are at least a cooldown apart; while a
+// threshold stays crossed the event is repeated, so an autoscaler can keep
+// adding or removing capacity.
+type Autoscaler struct {
+	queue  *Queue
+	config config.AutoscaleConfig
+	http   *http.Client
+	now    func() time.Time
+
+	lastSent time.Time // When the last event was sent
+}
+
+// NewAutoscaler creates an autoscaler for q whose webhook requests time out
+// after timeout.
+func NewAutoscaler(q *Queue, cfg config.AutoscaleConfig, timeout time.Duration) *Autoscaler {
+	return &Autoscaler{
+		queue:  q,
+		config: cfg,
+		http:   &http.Client{Timeout: timeout},
+		now:    time.Now,
+	}
+}
+
+// Run checks the queue every configured interval until ctx is done. Errors
+// are passed to onError and do not stop the loop.
+func (a *Autoscaler) Run(ctx context.Context, onError func(error)) {
+	interval := a.config.Interval
+	if interval < minInterval {
+		interval = minInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := a.Check(ctx); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+// Check evaluates the thresholds once and posts an event when one is due.
+// It returns the event sent, or nil.
+func (a *Autoscaler) Check(ctx context.Context) (*Event, error) {
+	now := a.now()
+	stats := a.queue.Stats()
+	kind, reason := a.evaluate(stats, now)
+	if kind == "" || (!a.lastSent.IsZero() && now.Sub(a.lastSent) < a.config.Cooldown) {
+		return nil, nil
+	}
+
+	event := &Event{Event: kind, Reason: reason, Queue: stats, Timestamp: now.UTC()}
+	if err := a.send(ctx, event); err != nil {
+		return nil, err
+	}
+	a.lastSent = now
+	return event, nil
+}
+
+// evaluate returns the event type the stats call for and why, or "" when
+// no threshold is crossed.
+func (a *Autoscaler) evaluate(stats Stats, now time.Time) (string, string) {
+	cfg := a.config
+	switch {
+	case cfg.ScaleUpDepth > 0 && stats.Depth >= cfg.ScaleUpDepth:
+		return EventScaleUp, fmt.Sprintf("%d jobs queued, threshold %d", stats.Depth, cfg.ScaleUpDepth)
+	case cfg.ScaleUpWait > 0 && stats.Depth > 0 && stats.OldestWait >= cfg.ScaleUpWait:
+		return EventScaleUp, fmt.Sprintf("oldest queued job waited %s, threshold %s", stats.OldestWait.Round(time.Second), cfg.ScaleUpWait)
+	case cfg.ScaleDownIdle > 0 && !stats.IdleSince.IsZero() && now.Sub(stats.IdleSince) >= cfg.ScaleDownIdle:
+		return EventScaleDown, fmt.Sprintf("idle for %s, threshold %s", now.Sub(stats.IdleSince).Round(time.Second), cfg.ScaleDownIdle)
+	}
+	return "", ""
+}
+
+// send posts an event to the webhook, signed like GitHub webhooks when a
+// secret is configured.
+func (a *Autoscaler) send(ctx context.Context, event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", event.Event, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.config.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", event.Event, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Manfred-Event", event.Event)
+	if a.config.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(a.config.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Manfred-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send %s event: %w", event.Event, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to send %s event: webhook returned %s", event.Event, resp.Status)
+	}
+	return nil
+}
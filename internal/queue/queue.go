@@ -0,0 +1,177 @@
+// Package queue limits how many jobs `manfred serve` runs at once, measures
+// how long jobs wait for a slot and notifies an autoscaler when the queue
+// grows or drains.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// recentWaits is the number of most recent wait times averaged in Stats.
+const recentWaits = 50
+
+// Queue hands out job slots in the order jobs ask for them, as far as the
+// Go scheduler allows.
+type Queue struct {
+	max   int
+	slots chan struct{} // nil when the number of jobs is unlimited
+	now   func() time.Time
+
+	mu        sync.Mutex
+	waiting   map[uint64]time.Time // Enqueue time of each waiting job
+	nextID    uint64
+	running   int
+	started   int64
+	waits     []time.Duration // Ring buffer of the last recentWaits waits
+	idleSince time.Time
+}
+
+// Stats is a point-in-time view of the queue.
+type Stats struct {
+	MaxConcurrent int           // 0 when unlimited
+	Running       int           // Jobs holding a slot
+	Depth         int           // Jobs waiting for a slot
+	OldestWait    time.Duration // How long the longest waiting job has waited
+	AvgWait       time.Duration // Mean wait of recently started jobs
+	MaxWait       time.Duration // Longest wait of recently started jobs
+	Started       int64         // Jobs started since the queue was created
+	IdleSince     time.Time     // When the queue last became empty; zero while busy
+}
+
+// New creates a queue that runs at most maxConcurrent jobs at once; 0 or
+// less means no limit, which still records metrics.
+func New(maxConcurrent int) *Queue {
+	q := &Queue{
+		now:     time.Now,
+		waiting: map[uint64]time.Time{},
+	}
+	if maxConcurrent > 0 {
+		q.max = maxConcurrent
+		q.slots = make(chan struct{}, maxConcurrent)
+	}
+	q.idleSince = q.now()
+	return q
+}
+
+// Acquire blocks until a job slot is free or ctx is done. The returned
+// release function frees the slot and may be called more than once.
+func (q *Queue) Acquire(ctx context.Context) (release func(), err error) {
+	q.mu.Lock()
+	id := q.nextID
+	q.nextID++
+	enqueued := q.now()
+	q.waiting[id] = enqueued
+	q.idleSince = time.Time{}
+	q.mu.Unlock()
+
+	if q.slots != nil {
+		select {
+		case q.slots <- struct{}{}:
+		case <-ctx.Done():
+			q.mu.Lock()
+			delete(q.waiting, id)
+			q.markIdle()
+			q.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+
+	q.mu.Lock()
+	delete(q.waiting, id)
+	q.running++
+	q.started++
+	q.recordWait(q.now().Sub(enqueued))
+	q.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			q.running--
+			q.markIdle()
+			q.mu.Unlock()
+			if q.slots != nil {
+				<-q.slots
+			}
+		})
+	}, nil
+}
+
+// Stats returns the current queue metrics.
+func (q *Queue) Stats() Stats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	stats := Stats{
+		MaxConcurrent: q.max,
+		Running:       q.running,
+		Depth:         len(q.waiting),
+		Started:       q.started,
+		IdleSince:     q.idleSince,
+	}
+	for _, enqueued := range q.waiting {
+		if wait := now.Sub(enqueued); wait > stats.OldestWait {
+			stats.OldestWait = wait
+		}
+	}
+	if len(q.waits) > 0 {
+		var total time.Duration
+		for _, wait := range q.waits {
+			total += wait
+			if wait > stats.MaxWait {
+				stats.MaxWait = wait
+			}
+		}
+		stats.AvgWait = total / time.Duration(len(q.waits))
+	}
+	return stats
+}
+
+// recordWait adds the wait of the job just started to the ring buffer,
+// replacing the oldest entry once it is full. Callers hold q.mu.
+func (q *Queue) recordWait(wait time.Duration) {
+	if len(q.waits) < recentWaits {
+		q.waits = append(q.waits, wait)
+		return
+	}
+	q.waits[(q.started-1)%recentWaits] = wait
+}
+
+// markIdle records the time the queue became empty. Callers hold q.mu.
+func (q *Queue) markIdle() {
+	if q.running == 0 && len(q.waiting) == 0 && q.idleSince.IsZero() {
+		q.idleSince = q.now()
+	}
+}
+
+// MarshalJSON encodes durations in seconds, the unit autoscalers and
+// dashboards expect.
+func (s Stats) MarshalJSON() ([]byte, error) {
+	var idleSince *time.Time
+	if !s.IdleSince.IsZero() {
+		idleSince = &s.IdleSince
+	}
+	return json.Marshal(struct {
+		MaxConcurrent int        `json:"max_concurrent"`
+		Running       int        `json:"running"`
+		Depth         int        `json:"depth"`
+		OldestWait    float64    `json:"oldest_wait_seconds"`
+		AvgWait       float64    `json:"avg_wait_seconds"`
+		MaxWait       float64    `json:"max_wait_seconds"`
+		Started       int64      `json:"started_total"`
+		IdleSince     *time.Time `json:"idle_since,omitempty"`
+	}{
+		MaxConcurrent: s.MaxConcurrent,
+		Running:       s.Running,
+		Depth:         s.Depth,
+		OldestWait:    s.OldestWait.Seconds(),
+		AvgWait:       s.AvgWait.Seconds(),
+		MaxWait:       s.MaxWait.Seconds(),
+		Started:       s.Started,
+		IdleSince:     idleSince,
+	})
+}
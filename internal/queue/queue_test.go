@@ -0,0 +1,180 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+)
+
+// fakeClock is a settable time source for queue and autoscaler tests.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func TestQueue(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	q := New(1)
+	q.now = clock.now
+	q.idleSince = clock.t
+
+	release, err := q.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire() error: %v", err)
+	}
+
+	acquired := make(chan func())
+	go func() {
+		next, err := q.Acquire(context.Background())
+		if err != nil {
+			t.Errorf("Acquire() error: %v", err)
+		}
+		acquired <- next
+	}()
+	waitFor(t, func() bool { return q.Stats().Depth == 1 })
+
+	clock.t = clock.t.Add(30 * time.Second)
+	stats := q.Stats()
+	if stats.Running != 1 || stats.Depth != 1 || stats.OldestWait != 30*time.Second || !stats.IdleSince.IsZero() {
+		t.Errorf("busy stats = %+v, want 1 running, 1 queued waiting 30s", stats)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := q.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire(cancelled) error = %v, want context.Canceled", err)
+	}
+
+	release()
+	release() // Must not free a second slot
+	next := <-acquired
+	next()
+
+	stats = q.Stats()
+	if stats.Running != 0 || stats.Depth != 0 || stats.Started != 2 {
+		t.Errorf("idle stats = %+v, want 0 running, 0 queued, 2 started", stats)
+	}
+	if stats.AvgWait != 15*time.Second || stats.MaxWait != 30*time.Second {
+		t.Errorf("waits = avg %s, max %s; want 15s, 30s", stats.AvgWait, stats.MaxWait)
+	}
+	if !stats.IdleSince.Equal(clock.t) {
+		t.Errorf("IdleSince = %s, want %s", stats.IdleSince, clock.t)
+	}
+}
+
+func TestQueueUnlimited(t *testing.T) {
+	q := New(0)
+	for i := 0; i < 3; i++ {
+		if _, err := q.Acquire(context.Background()); err != nil {
+			t.Fatalf("Acquire() error: %v", err)
+		}
+	}
+	if stats := q.Stats(); stats.Running != 3 || stats.MaxConcurrent != 0 {
+		t.Errorf("stats = %+v, want 3 running without a limit", stats)
+	}
+}
+
+func TestAutoscaler(t *testing.T) {
+	type received struct {
+		Event  string         `json:"event"`
+		Reason string         `json:"reason"`
+		Queue  map[string]any `json:"queue"`
+	}
+	var events []received
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, _ := io.ReadAll(r.Body)
+		var event received
+		if err := github.ValidateWebhookSignature(payload, r.Header.Get("X-Manfred-Signature-256"), "s3cret"); err != nil {
+			t.Errorf("signature: %v", err)
+		}
+		if err := json.Unmarshal(payload, &event); err != nil {
+			t.Errorf("decode event: %v", err)
+		}
+		if got := r.Header.Get("X-Manfred-Event"); got != event.Event {
+			t.Errorf("X-Manfred-Event = %q, want %q", got, event.Event)
+		}
+		events = append(events, event)
+	}))
+	defer server.Close()
+
+	clock := &fakeClock{t: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	q := New(1)
+	q.now = clock.now
+	q.idleSince = clock.t
+
+	a := NewAutoscaler(q, config.AutoscaleConfig{
+		WebhookURL:    server.URL,
+		Secret:        "s3cret",
+		ScaleUpWait:   time.Minute,
+		ScaleDownIdle: 10 * time.Minute,
+		Cooldown:      5 * time.Minute,
+	}, 30*time.Second)
+	a.now = clock.now
+
+	check := func(want string) {
+		t.Helper()
+		event, err := a.Check(context.Background())
+		if err != nil {
+			t.Fatalf("Check() error: %v", err)
+		}
+		got := ""
+		if event != nil {
+			got = event.Event
+		}
+		if got != want {
+			t.Errorf("Check() at %s = %q, want %q", clock.t.Format(time.Kitchen), got, want)
+		}
+	}
+
+	check("")
+
+	release, _ := q.Acquire(context.Background())
+	acquired := make(chan func())
+	go func() {
+		next, _ := q.Acquire(context.Background())
+		acquired <- next
+	}()
+	waitFor(t, func() bool { return q.Stats().Depth == 1 })
+
+	clock.t = clock.t.Add(2 * time.Minute)
+	check(EventScaleUp)
+	clock.t = clock.t.Add(time.Minute)
+	check("") // Cooldown
+	clock.t = clock.t.Add(5 * time.Minute)
+	check(EventScaleUp)
+
+	release()
+	next := <-acquired
+	next()
+
+	clock.t = clock.t.Add(5 * time.Minute)
+	check("")
+	clock.t = clock.t.Add(10 * time.Minute)
+	check(EventScaleDown)
+
+	if len(events) != 3 {
+		t.Fatalf("webhook received %d events, want 3", len(events))
+	}
+	if events[0].Queue["depth"] != 1.0 || events[0].Reason == "" {
+		t.Errorf("first event = %+v, want the queued job and a reason", events[0])
+	}
+}
+
+// waitFor polls cond until it holds or the test times out.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the queue")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
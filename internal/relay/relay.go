@@ -0,0 +1,198 @@
+// Package relay subscribes to a webhook relay channel (smee.io and
+// compatible services) and forwards the deliveries to a local webhook
+// endpoint, for development machines GitHub cannot reach directly.
+package relay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mpm/manfred/internal/github"
+)
+
+const (
+	// maxEventBytes is the largest relay message accepted; GitHub caps
+	// payloads at 25MB and the relay adds the headers around them.
+	maxEventBytes = 26 << 20
+
+	// maxBackoff caps the wait between reconnection attempts.
+	maxBackoff = time.Minute
+)
+
+// Delivery is a webhook delivery received from the relay channel.
+type Delivery struct {
+	Event     string          // X-GitHub-Event
+	ID        string          // X-GitHub-Delivery
+	Signature string          // X-Hub-Signature-256
+	Body      json.RawMessage // Payload as sent by the relay
+}
+
+// Client forwards deliveries from a relay channel to a webhook endpoint.
+type Client struct {
+	source string
+	target string
+	secret string
+	http   *http.Client
+}
+
+// New creates a relay client. When secret is set, deliveries whose
+// signature does not match are dropped instead of forwarded.
+func New(source, target, secret string) *Client {
+	return &Client{
+		source: source,
+		target: target,
+		secret: secret,
+		http:   &http.Client{},
+	}
+}
+
+// Run subscribes to the relay channel until ctx is done, reconnecting with
+// exponential backoff when the stream ends. Every delivery is reported to
+// onDelivery with the forwarding error, if any; connection errors are
+// reported with a nil delivery.
+func (c *Client) Run(ctx context.Context, onDelivery func(*Delivery, error)) error {
+	backoff := time.Second
+	for {
+		connected, err := c.subscribe(ctx, onDelivery)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if connected {
+			backoff = time.Second
+		}
+		if err == nil {
+			err = errors.New("relay stream closed")
+		}
+		onDelivery(nil, fmt.Errorf("%w, reconnecting in %s", err, backoff))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// subscribe reads one server-sent event stream until it ends. It reports
+// whether the connection was established.
+func (c *Client) subscribe(ctx context.Context, onDelivery func(*Delivery, error)) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.source, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("relay returned %s", resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), maxEventBytes)
+
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			// A blank line ends the event
+			if data.Len() > 0 {
+				c.handle(ctx, data.Bytes(), onDelivery)
+				data.Reset()
+			}
+			continue
+		}
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(value, " "))
+		}
+		// event:, id:, retry: and comments carry nothing we need
+	}
+	return true, scanner.Err()
+}
+
+// handle forwards one event's data if it is a webhook delivery. Relay
+// housekeeping events (ready, ping) are ignored.
+func (c *Client) handle(ctx context.Context, data []byte, onDelivery func(*Delivery, error)) {
+	delivery, err := ParseDelivery(data)
+	if err != nil {
+		onDelivery(nil, err)
+		return
+	}
+	if delivery == nil {
+		return
+	}
+	onDelivery(delivery, c.Forward(ctx, delivery))
+}
+
+// ParseDelivery decodes the data of a relay event in the smee.io format:
+// the lowercased request headers and the payload under "body". It returns
+// nil for events that are not GitHub deliveries.
+func ParseDelivery(data []byte) (*Delivery, error) {
+	var msg struct {
+		Event     string          `json:"x-github-event"`
+		ID        string          `json:"x-github-delivery"`
+		Signature string          `json:"x-hub-signature-256"`
+		Body      json.RawMessage `json:"body"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("parse relay event: %w", err)
+	}
+	if msg.Event == "" || len(msg.Body) == 0 {
+		return nil, nil
+	}
+	return &Delivery{
+		Event:     msg.Event,
+		ID:        msg.ID,
+		Signature: msg.Signature,
+		Body:      msg.Body,
+	}, nil
+}
+
+// Forward checks the delivery's signature and posts it to the target with
+// GitHub's headers.
+func (c *Client) Forward(ctx context.Context, d *Delivery) error {
+	if c.secret != "" {
+		if err := github.ValidateWebhookSignature(d.Body, d.Signature, c.secret); err != nil {
+			return fmt.Errorf("delivery %s dropped: %w", d.ID, err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.target, bytes.NewReader(d.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", d.Event)
+	if d.ID != "" {
+		req.Header.Set("X-GitHub-Delivery", d.ID)
+	}
+	if d.Signature != "" {
+		req.Header.Set("X-Hub-Signature-256", d.Signature)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("forward delivery %s: %w", d.ID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("forward delivery %s: %s: %s", d.ID, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
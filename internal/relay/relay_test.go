@@ -0,0 +1,125 @@
+package relay
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func sign(body, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestParseDelivery(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    *Delivery
+		wantErr bool
+	}{
+		{
+			name: "delivery",
+			data: `{"x-github-event":"issues","x-github-delivery":"d1","x-hub-signature-256":"sha256=ab","body":{"action":"labeled"},"query":{},"timestamp":1}`,
+			want: &Delivery{Event: "issues", ID: "d1", Signature: "sha256=ab", Body: []byte(`{"action":"labeled"}`)},
+		},
+		{name: "ready", data: `{}`},
+		{name: "no body", data: `{"x-github-event":"ping"}`},
+		{name: "invalid", data: `ready`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDelivery([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDelivery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("ParseDelivery() = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil || got.Event != tt.want.Event || got.ID != tt.want.ID ||
+				got.Signature != tt.want.Signature || string(got.Body) != string(tt.want.Body) {
+				t.Errorf("ParseDelivery() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunForwardsDeliveries(t *testing.T) {
+	const secret = "s3cret"
+	good := `{"action":"labeled","number":1}`
+	forged := `{"action":"labeled","number":2}`
+
+	var mu sync.Mutex
+	var received []string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got := r.Header.Get("X-Hub-Signature-256"); got != sign(string(body), secret) {
+			t.Errorf("forwarded signature = %q", got)
+		}
+		mu.Lock()
+		received = append(received, r.Header.Get("X-GitHub-Event")+" "+r.Header.Get("X-GitHub-Delivery")+" "+string(body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer target.Close()
+
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "text/event-stream" {
+			t.Errorf("Accept = %q", r.Header.Get("Accept"))
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: ready\ndata: {}\n\n")
+		fmt.Fprint(w, ": keepalive\n\n")
+		fmt.Fprintf(w, "data: {\"x-github-event\":\"issues\",\"x-github-delivery\":\"d1\",\"x-hub-signature-256\":%q,\"body\":%s}\n\n", sign(good, secret), good)
+		fmt.Fprintf(w, "data: {\"x-github-event\":\"issues\",\"x-github-delivery\":\"d2\",\"x-hub-signature-256\":%q,\"body\":%s}\n\n", sign(forged, "wrong"), forged)
+	}))
+	defer source.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var results []string
+	client := New(source.URL, target.URL, secret)
+	client.Run(ctx, func(d *Delivery, err error) {
+		if d == nil {
+			// The stream ended; one pass is enough
+			cancel()
+			return
+		}
+		results = append(results, fmt.Sprintf("%s:%v", d.ID, err != nil))
+	})
+
+	if len(results) != 2 || results[0] != "d1:false" || results[1] != "d2:true" {
+		t.Errorf("results = %v, want [d1:false d2:true]", results)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 || received[0] != "issues d1 "+good {
+		t.Errorf("received = %q, want only d1", received)
+	}
+}
+
+func TestForwardReportsTargetErrors(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid webhook signature", http.StatusUnauthorized)
+	}))
+	defer target.Close()
+
+	client := New("", target.URL, "")
+	err := client.Forward(context.Background(), &Delivery{Event: "issues", ID: "d1", Body: []byte(`{}`)})
+	if err == nil {
+		t.Fatal("Forward() succeeded, want error")
+	}
+}
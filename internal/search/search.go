@@ -0,0 +1,255 @@
+// Package search indexes past work, ticket prompts and entries, session
+// plans and job prompts and commit messages, for full-text search.
+package search
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/store"
+)
+
+// Kind is the kind of an indexed document.
+type Kind string
+
+const (
+	KindTicket  Kind = "ticket"  // Ticket prompt, follow-ups and comments
+	KindSession Kind = "session" // Session plan and error
+	KindJob     Kind = "job"     // Job prompt, plan and commit message
+)
+
+// ParseKind validates a document kind.
+func ParseKind(s string) (Kind, error) {
+	switch kind := Kind(s); kind {
+	case KindTicket, KindSession, KindJob:
+		return kind, nil
+	default:
+		return "", fmt.Errorf("unknown kind %q (want ticket, session or job)", s)
+	}
+}
+
+// minSyncInterval is how long Search trusts the index after a sync. The
+// REST API searches often, the CLI once per process.
+const minSyncInterval = 30 * time.Second
+
+// Document is an indexed piece of past work.
+type Document struct {
+	Kind    Kind
+	Ref     string // Ticket, session or job ID
+	Project string // Project of tickets and jobs, owner/repo of sessions
+	Title   string
+	Body    string
+}
+
+// hash identifies the content of d, to skip unchanged documents on sync.
+func (d Document) hash() string {
+	sum := sha256.Sum256([]byte(d.Project + "\x00" + d.Title + "\x00" + d.Body))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Query selects search results.
+type Query struct {
+	Text    string // Words that must all occur, in any form ("tests" matches "test")
+	Kinds   []Kind // Only these kinds; empty means all
+	Project string // Only this project or owner/repo
+	Limit   int    // At most this many results; 0 means 20
+}
+
+// Result is a document matching a query, best match first.
+type Result struct {
+	Kind    Kind   `json:"kind"`
+	Ref     string `json:"ref"`
+	Project string `json:"project,omitempty"`
+	Title   string `json:"title"`
+	Snippet string `json:"snippet"` // Body excerpt with matches in [brackets]
+}
+
+// SyncStats reports what a sync changed.
+type SyncStats struct {
+	Indexed int // Documents added or updated
+	Removed int // Documents whose source is gone
+	Total   int // Documents in the index
+}
+
+// Index is the search index in the database, synced from the ticket and
+// job directories and the session store.
+type Index struct {
+	db         *store.DB
+	ticketsDir string
+	jobsDir    string
+	sessions   session.Store
+
+	mu       sync.Mutex
+	lastSync time.Time
+}
+
+// NewIndex creates an index of the tickets in ticketsDir, the jobs in
+// jobsDir and the live and archived sessions of sessions.
+func NewIndex(db *store.DB, ticketsDir, jobsDir string, sessions session.Store) *Index {
+	return &Index{db: db, ticketsDir: ticketsDir, jobsDir: jobsDir, sessions: sessions}
+}
+
+// Sync brings the index up to date with its sources.
+func (ix *Index) Sync(ctx context.Context) (*SyncStats, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.sync(ctx)
+}
+
+func (ix *Index) sync(ctx context.Context) (*SyncStats, error) {
+	docs, err := ix.collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	indexed := map[string]string{} // kind/ref -> hash
+	rows, err := ix.db.QueryContext(ctx, `SELECT kind, ref, hash FROM search_index`)
+	if err != nil {
+		return nil, fmt.Errorf("read search index: %w", err)
+	}
+	for rows.Next() {
+		var kind, ref, hash string
+		if err := rows.Scan(&kind, &ref, &hash); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan search index: %w", err)
+		}
+		indexed[kind+"/"+ref] = hash
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read search index: %w", err)
+	}
+
+	remove := ix.db.Rebind(`DELETE FROM search_index WHERE kind = ? AND ref = ?`)
+	insert := ix.db.Rebind(`INSERT INTO search_index (kind, ref, project, hash, title, body) VALUES (?, ?, ?, ?, ?, ?)`)
+	stats := &SyncStats{Total: len(docs)}
+	err = ix.db.Transaction(ctx, func(tx *sql.Tx) error {
+		for key, doc := range docs {
+			hash := doc.hash()
+			old, ok := indexed[key]
+			delete(indexed, key)
+			if ok && old == hash {
+				continue
+			}
+			if ok {
+				if _, err := tx.ExecContext(ctx, remove, string(doc.Kind), doc.Ref); err != nil {
+					return fmt.Errorf("update %s %s: %w", doc.Kind, doc.Ref, err)
+				}
+			}
+			if _, err := tx.ExecContext(ctx, insert, string(doc.Kind), doc.Ref, doc.Project, hash, doc.Title, doc.Body); err != nil {
+				return fmt.Errorf("index %s %s: %w", doc.Kind, doc.Ref, err)
+			}
+			stats.Indexed++
+		}
+		for key := range indexed {
+			kind, ref, _ := strings.Cut(key, "/")
+			if _, err := tx.ExecContext(ctx, remove, kind, ref); err != nil {
+				return fmt.Errorf("remove %s %s: %w", kind, ref, err)
+			}
+			stats.Removed++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	ix.lastSync = time.Now()
+	return stats, nil
+}
+
+// Search returns the documents matching q, best match first. It syncs the
+// index first unless that happened within the last 30 seconds.
+func (ix *Index) Search(ctx context.Context, q Query) ([]Result, error) {
+	if len(strings.Fields(q.Text)) == 0 {
+		return nil, fmt.Errorf("search text is empty")
+	}
+
+	ix.mu.Lock()
+	if time.Since(ix.lastSync) >= minSyncInterval {
+		if _, err := ix.sync(ctx); err != nil {
+			ix.mu.Unlock()
+			return nil, err
+		}
+	}
+	ix.mu.Unlock()
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+
+	var query string
+	var args []interface{}
+	if ix.db.Driver() == store.DriverPostgres {
+		query = `
+			SELECT kind, ref, project, title,
+				ts_headline('english', body, query, 'StartSel=[, StopSel=], MinWords=8, MaxWords=24')
+			FROM search_index, plainto_tsquery('english', ?) AS query
+			WHERE document @@ query
+		`
+		args = append(args, q.Text)
+	} else {
+		query = `
+			SELECT kind, ref, project, title, snippet(search_index, 5, '[', ']', '...', 16)
+			FROM search_index
+			WHERE search_index MATCH ?
+		`
+		args = append(args, matchExpression(q.Text))
+	}
+	if len(q.Kinds) > 0 {
+		query += " AND kind IN (?" + strings.Repeat(", ?", len(q.Kinds)-1) + ")"
+		for _, kind := range q.Kinds {
+			args = append(args, string(kind))
+		}
+	}
+	if q.Project != "" {
+		query += " AND project = ?"
+		args = append(args, q.Project)
+	}
+	if ix.db.Driver() == store.DriverPostgres {
+		query += " ORDER BY ts_rank(document, query) DESC"
+	} else {
+		// Title matches count five times as much as body matches
+		query += " ORDER BY bm25(search_index, 0, 0, 0, 0, 5.0, 1.0)"
+	}
+	query += fmt.Sprintf(" LIMIT %d", limit)
+
+	rows, err := ix.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	defer rows.Close()
+
+	var results []Result
+	for rows.Next() {
+		var r Result
+		var kind string
+		if err := rows.Scan(&kind, &r.Ref, &r.Project, &r.Title, &r.Snippet); err != nil {
+			return nil, fmt.Errorf("scan search result: %w", err)
+		}
+		r.Kind = Kind(kind)
+		r.Snippet = strings.Join(strings.Fields(r.Snippet), " ")
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	return results, nil
+}
+
+// matchExpression turns search text into an FTS5 query requiring every
+// word. Words are quoted, so FTS5 syntax in the text matches literally.
+func matchExpression(text string) string {
+	words := strings.Fields(text)
+	for i, w := range words {
+		words[i] = `"` + strings.ReplaceAll(w, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,119 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/store"
+	"github.com/mpm/manfred/internal/ticket"
+)
+
+func TestIndex(t *testing.T) {
+	db, err := store.OpenInMemory()
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+
+	dir := t.TempDir()
+	ticketsDir, jobsDir := filepath.Join(dir, "tickets"), filepath.Join(dir, "jobs")
+	tickets := ticket.NewFileStore(ticketsDir, "widgets")
+	flaky, err := tickets.Create(ctx, "Fix the flaky login test\n\nIt times out on CI.", ticket.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tickets.Create(ctx, "Add a dark mode toggle", ticket.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	jobDir := filepath.Join(jobsDir, "job_20260101_000000_abcd", ".manfred")
+	for name, content := range map[string]string{
+		filepath.Join(jobDir, "input.json"):           `{"job_id":"job_20260101_000000_abcd","project":"widgets","status":"completed"}`,
+		filepath.Join(jobDir, "..", "prompt.txt"):     "Speed up the login page",
+		filepath.Join(jobDir, "commit_message.txt"):   "Cache session lookups on login\n\nThe login handler hit the database twice.",
+		filepath.Join(jobsDir, "job_broken", ".keep"): "",
+	} {
+		os.MkdirAll(filepath.Dir(name), 0755)
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sessions := session.NewSQLStore(db)
+	sess := session.NewSession("acme", "widgets", 7)
+	plan := "Replace the polling loop in the importer with webhooks."
+	sess.PlanContent = &plan
+	if err := sessions.Create(ctx, sess); err != nil {
+		t.Fatal(err)
+	}
+
+	ix := NewIndex(db, ticketsDir, jobsDir, sessions)
+	stats, err := ix.Sync(ctx)
+	if err != nil {
+		t.Fatalf("Sync() error: %v", err)
+	}
+	if stats.Indexed != 4 || stats.Total != 4 {
+		t.Errorf("Sync() = %+v, want 4 indexed", stats)
+	}
+	if stats, _ := ix.Sync(ctx); stats.Indexed != 0 || stats.Removed != 0 {
+		t.Errorf("second Sync() = %+v, want no changes", stats)
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"all words", Query{Text: "flaky login test"}, []string{flaky.ID}},
+		{"stemming", Query{Text: "tests"}, []string{flaky.ID}},
+		{"kind", Query{Text: "login", Kinds: []Kind{KindJob}}, []string{"job_20260101_000000_abcd"}},
+		{"project", Query{Text: "webhooks", Project: "acme/widgets"}, []string{sess.ID}},
+		{"other project", Query{Text: "webhooks", Project: "widgets"}, nil},
+		{"syntax is literal", Query{Text: `"dark" OR NOT -mode*`}, nil},
+	}
+	for _, tt := range tests {
+		results, err := ix.Search(ctx, tt.query)
+		if err != nil {
+			t.Errorf("%s: Search() error: %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.Ref)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: Search() = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: Search() = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+
+	results, _ := ix.Search(ctx, Query{Text: "flaky"})
+	if len(results) != 1 || results[0].Snippet != "Fix the [flaky] login test It times out on CI." {
+		t.Errorf("Search(flaky) = %+v, want a highlighted snippet", results)
+	}
+
+	// Deleted sources leave the index
+	if err := sessions.Delete(ctx, sess.ID); err != nil {
+		t.Fatal(err)
+	}
+	if stats, _ := ix.Sync(ctx); stats.Removed != 1 || stats.Total != 3 {
+		t.Errorf("Sync() after delete = %+v, want 1 removed", stats)
+	}
+
+	if _, err := ix.Search(ctx, Query{Text: "  "}); err == nil {
+		t.Error("Search(blank) succeeded, want an error")
+	}
+}
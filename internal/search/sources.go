@@ -0,0 +1,155 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/ticket"
+)
+
+// titleLength is the length titles are cut to.
+const titleLength = 80
+
+// collect reads all documents from the sources, keyed by kind/ref.
+func (ix *Index) collect(ctx context.Context) (map[string]Document, error) {
+	docs := map[string]Document{}
+	add := func(d Document) {
+		if strings.TrimSpace(d.Body) != "" {
+			docs[string(d.Kind)+"/"+d.Ref] = d
+		}
+	}
+
+	if err := ix.collectTickets(ctx, add); err != nil {
+		return nil, err
+	}
+	if err := ix.collectJobs(add); err != nil {
+		return nil, err
+	}
+	if ix.sessions != nil {
+		// Live sessions replace archived ones of the same ID
+		archived, err := ix.sessions.ListArchived(ctx, session.SessionFilter{})
+		if err != nil {
+			return nil, err
+		}
+		for i := len(archived) - 1; i >= 0; i-- {
+			add(sessionDocument(&archived[i].Session))
+		}
+		sessions, err := ix.sessions.List(ctx, session.SessionFilter{})
+		if err != nil {
+			return nil, err
+		}
+		for i := range sessions {
+			add(sessionDocument(&sessions[i]))
+		}
+	}
+	return docs, nil
+}
+
+// collectTickets reads the tickets of every project in the tickets directory.
+func (ix *Index) collectTickets(ctx context.Context, add func(Document)) error {
+	entries, err := os.ReadDir(ix.ticketsDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		tickets, err := ticket.NewFileStore(ix.ticketsDir, entry.Name()).List(ctx, nil)
+		if err != nil {
+			return err
+		}
+		for _, t := range tickets {
+			var body []string
+			for _, e := range t.Entries {
+				body = append(body, e.Content)
+			}
+			add(Document{
+				Kind:    KindTicket,
+				Ref:     t.ID,
+				Project: t.Project,
+				Title:   t.PromptPreview(titleLength),
+				Body:    strings.Join(body, "\n\n"),
+			})
+		}
+	}
+	return nil
+}
+
+// collectJobs reads the prompt, plan and commit message of every job in the
+// jobs directory. Jobs without an input record, which predate them or never
+// started, are skipped.
+func (ix *Index) collectJobs(add func(Document)) error {
+	entries, err := os.ReadDir(ix.jobsDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "job_") {
+			continue
+		}
+		j, err := job.Load(ix.jobsDir, entry.Name())
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		title := firstLine(j.CommitMessage)
+		if title == "" {
+			title = firstLine(j.Prompt)
+		}
+		add(Document{
+			Kind:    KindJob,
+			Ref:     j.ID,
+			Project: j.ProjectName,
+			Title:   title,
+			Body:    strings.Join([]string{j.Prompt, j.Plan, j.CommitMessage}, "\n\n"),
+		})
+	}
+	return nil
+}
+
+// sessionDocument indexes a session's plan and error under its issue.
+func sessionDocument(s *session.Session) Document {
+	var body []string
+	if s.PlanContent != nil {
+		body = append(body, *s.PlanContent)
+	}
+	if s.ErrorMessage != nil {
+		body = append(body, *s.ErrorMessage)
+	}
+	return Document{
+		Kind:    KindSession,
+		Ref:     s.ID,
+		Project: s.RepoFullName(),
+		Title:   fmt.Sprintf("%s#%d", s.RepoFullName(), s.IssueNumber),
+		Body:    strings.Join(body, "\n\n"),
+	}
+}
+
+// firstLine returns the first non-empty line of text, cut to titleLength.
+func firstLine(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(line, "# "))
+		if line == "" {
+			continue
+		}
+		if len(line) > titleLength {
+			return line[:titleLength] + "..."
+		}
+		return line
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// envFileProvider resolves env-file:<path>#<NAME> from files of NAME=value
+// lines, as used by docker --env-file and systemd EnvironmentFile. Files are
+// read once.
+type envFileProvider struct {
+	mu    sync.Mutex
+	files map[string]map[string]string
+}
+
+// EnvFileProvider returns a provider for env files.
+func EnvFileProvider() Provider {
+	return &envFileProvider{files: map[string]map[string]string{}}
+}
+
+func (p *envFileProvider) Resolve(ctx context.Context, path, key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("missing #NAME of the variable")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	vars, ok := p.files[path]
+	if !ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		if vars, err = ParseEnvFile(data); err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		p.files[path] = vars
+	}
+
+	value, ok := vars[key]
+	if !ok {
+		return "", fmt.Errorf("%s is not set in %s", key, path)
+	}
+	return value, nil
+}
+
+// ParseEnvFile parses NAME=value lines. Blank lines and # comments are
+// skipped, an export prefix is allowed, and values may be single quoted
+// (literal) or double quoted (with Go escapes).
+func ParseEnvFile(data []byte) (map[string]string, error) {
+	vars := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("line %d: want NAME=value", n)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			value = unquoted
+		}
+		vars[name] = value
+	}
+	return vars, scanner.Err()
+}
@@ -0,0 +1,201 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// KeySize is the size of the store key in bytes (AES-256).
+const KeySize = 32
+
+// KeyEnv is the environment variable holding the base64 store key for the
+// env key source.
+const KeyEnv = "MANFRED_SECRETS_KEY"
+
+// Key sources, the values of secrets.key.
+const (
+	KeyKeyring = "keyring" // OS keyring: libsecret on Linux, Keychain on macOS
+	KeyFile    = "file"    // A key file, readable only by its owner
+	KeyEnvVar  = "env"     // MANFRED_SECRETS_KEY
+)
+
+// keyringService and keyringAccount identify the key in the OS keyring.
+const (
+	keyringService = "manfred"
+	keyringAccount = "secrets-key"
+)
+
+// ErrNoKey is returned by a KeySource that holds no key yet.
+var ErrNoKey = errors.New("no secrets key")
+
+// KeySource holds the store key.
+type KeySource interface {
+	// Key returns the key, or ErrNoKey if there is none.
+	Key() ([]byte, error)
+	// SaveKey stores a new key.
+	SaveKey(key []byte) error
+	// String names the source in messages.
+	String() string
+}
+
+// NewKeySource returns the key source named by secrets.key. keyFile is the
+// key file for the file source.
+func NewKeySource(source, keyFile string) (KeySource, error) {
+	switch source {
+	case KeyKeyring, "":
+		return keyringSource{}, nil
+	case KeyFile:
+		if keyFile == "" {
+			return nil, fmt.Errorf("secrets.key_file is required with secrets.key %s", KeyFile)
+		}
+		return fileSource{path: keyFile}, nil
+	case KeyEnvVar:
+		return envSource{}, nil
+	default:
+		return nil, fmt.Errorf("unknown secrets key source %q", source)
+	}
+}
+
+// NewKey generates a random store key.
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return key, nil
+}
+
+// decodeKey parses a base64 key.
+func decodeKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("key is not valid base64: %w", err)
+	}
+	return key, nil
+}
+
+// envSource reads the key from MANFRED_SECRETS_KEY. It cannot save keys.
+type envSource struct{}
+
+func (envSource) Key() ([]byte, error) {
+	value := os.Getenv(KeyEnv)
+	if value == "" {
+		return nil, ErrNoKey
+	}
+	return decodeKey(value)
+}
+
+func (envSource) SaveKey(key []byte) error {
+	return fmt.Errorf("set %s to a base64 key, e.g. from `openssl rand -base64 %d`", KeyEnv, KeySize)
+}
+
+func (envSource) String() string {
+	return "$" + KeyEnv
+}
+
+// fileSource keeps the key in a file. Keep the file out of backups of the
+// store.
+type fileSource struct {
+	path string
+}
+
+func (f fileSource) Key() ([]byte, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNoKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeKey(string(data))
+}
+
+func (f fileSource) SaveKey(key []byte) error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+		return fmt.Errorf("failed to create key directory: %w", err)
+	}
+	data := base64.StdEncoding.EncodeToString(key) + "\n"
+	// O_EXCL: never overwrite a key that secrets may still be sealed with
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create key file: %w", err)
+	}
+	if _, err := file.WriteString(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	return file.Close()
+}
+
+func (f fileSource) String() string {
+	return "key file " + f.path
+}
+
+// keyringSource keeps the key in the OS keyring through its command-line
+// tool: secret-tool (libsecret) on Linux, security (Keychain) on macOS.
+type keyringSource struct{}
+
+func (keyringSource) Key() ([]byte, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", keyringService, "account", keyringAccount)
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keyringService, "-a", keyringAccount, "-w")
+	default:
+		return nil, fmt.Errorf("no supported keyring on %s, use secrets.key %s or %s", runtime.GOOS, KeyFile, KeyEnvVar)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, fmt.Errorf("%s not found, install it or use secrets.key %s or %s", cmd.Args[0], KeyFile, KeyEnvVar)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) || (err == nil && len(bytes.TrimSpace(out)) == 0) {
+		// Both tools exit non-zero when the item does not exist
+		if msg := strings.TrimSpace(stderr.String()); msg != "" && runtime.GOOS == "linux" {
+			return nil, fmt.Errorf("keyring lookup failed: %s", msg)
+		}
+		return nil, ErrNoKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("keyring lookup failed: %w", err)
+	}
+	return decodeKey(string(out))
+}
+
+func (keyringSource) SaveKey(key []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(key)
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux":
+		cmd = exec.Command("secret-tool", "store", "--label=MANFRED secrets key", "service", keyringService, "account", keyringAccount)
+		cmd.Stdin = strings.NewReader(encoded)
+	case "darwin":
+		// security only takes the password as an argument
+		cmd = exec.Command("security", "add-generic-password", "-s", keyringService, "-a", keyringAccount, "-w", encoded)
+	default:
+		return fmt.Errorf("no supported keyring on %s, use secrets.key %s or %s", runtime.GOOS, KeyFile, KeyEnvVar)
+	}
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to store key in keyring: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (keyringSource) String() string {
+	return "OS keyring"
+}
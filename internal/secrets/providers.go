@@ -0,0 +1,94 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Provider resolves secret references of one scheme, such as vault.
+type Provider interface {
+	// Resolve returns the secret at path, e.g. kv/manfred, under key, e.g.
+	// github_token.
+	Resolve(ctx context.Context, path, key string) (string, error)
+}
+
+// Ref is a reference to a secret held elsewhere, written in place of a
+// setting's value as <scheme>:<path>#<key>, e.g. vault:kv/manfred#github_token.
+// The store scheme names a secret of the encrypted store: store:github_token.
+type Ref struct {
+	Scheme string
+	Path   string
+	Key    string
+}
+
+// String returns the reference as written in the config.
+func (r Ref) String() string {
+	if r.Key == "" {
+		return r.Scheme + ":" + r.Path
+	}
+	return r.Scheme + ":" + r.Path + "#" + r.Key
+}
+
+// Resolver resolves references with a provider per scheme.
+type Resolver struct {
+	providers map[string]Provider
+}
+
+// NewResolver returns a resolver using providers by scheme.
+func NewResolver(providers map[string]Provider) *Resolver {
+	return &Resolver{providers: providers}
+}
+
+// Schemes returns the schemes the resolver knows, sorted.
+func (r *Resolver) Schemes() []string {
+	schemes := make([]string, 0, len(r.providers))
+	for s := range r.providers {
+		schemes = append(schemes, s)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// ParseRef parses value as a reference to a known scheme. It reports false
+// for plain values, including URLs like https://..., whose scheme is not a
+// provider.
+func (r *Resolver) ParseRef(value string) (Ref, bool) {
+	scheme, rest, ok := strings.Cut(value, ":")
+	if !ok || r.providers[scheme] == nil || rest == "" {
+		return Ref{}, false
+	}
+	path, key, _ := strings.Cut(rest, "#")
+	return Ref{Scheme: scheme, Path: path, Key: key}, true
+}
+
+// Resolve returns the secret a reference points to.
+func (r *Resolver) Resolve(ctx context.Context, ref Ref) (string, error) {
+	provider := r.providers[ref.Scheme]
+	if provider == nil {
+		return "", fmt.Errorf("unknown secret provider %q", ref.Scheme)
+	}
+	value, err := provider.Resolve(ctx, ref.Path, ref.Key)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", ref, err)
+	}
+	return value, nil
+}
+
+// storeProvider resolves store:<name> from the encrypted store.
+type storeProvider struct {
+	store *Store
+}
+
+// StoreProvider returns a provider for secrets of the encrypted store.
+func StoreProvider(store *Store) Provider {
+	return storeProvider{store: store}
+}
+
+func (p storeProvider) Resolve(ctx context.Context, path, key string) (string, error) {
+	if !p.store.Has(path) {
+		return "", fmt.Errorf("no secret %s in %s", path, p.store.Path())
+	}
+	return p.store.Get(path)
+}
@@ -0,0 +1,139 @@
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolverParseRef(t *testing.T) {
+	r := NewResolver(map[string]Provider{"vault": EnvFileProvider(), "env-file": EnvFileProvider()})
+
+	tests := []struct {
+		value string
+		want  Ref
+		ok    bool
+	}{
+		{"vault:kv/manfred#github_token", Ref{Scheme: "vault", Path: "kv/manfred", Key: "github_token"}, true},
+		{"env-file:/etc/manfred.env#TOKEN", Ref{Scheme: "env-file", Path: "/etc/manfred.env", Key: "TOKEN"}, true},
+		{"ghp_plain", Ref{}, false},
+		{"https://hooks.slack.com/services/T/B/x", Ref{}, false},
+		{"vault:", Ref{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := r.ParseRef(tt.value)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseRef(%q) = %+v, %v, want %+v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseEnvFile(t *testing.T) {
+	data := []byte(`# comment
+TOKEN=ghp_plain
+export SECRET = 'single $quoted'
+QUOTED="line\nbreak"
+
+EMPTY=
+`)
+	vars, err := ParseEnvFile(data)
+	if err != nil {
+		t.Fatalf("ParseEnvFile: %v", err)
+	}
+	want := map[string]string{
+		"TOKEN":  "ghp_plain",
+		"SECRET": "single $quoted",
+		"QUOTED": "line\nbreak",
+		"EMPTY":  "",
+	}
+	for name, value := range want {
+		if got, ok := vars[name]; !ok || got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+	if len(vars) != len(want) {
+		t.Errorf("got %d variables, want %d", len(vars), len(want))
+	}
+
+	if _, err := ParseEnvFile([]byte("no equals sign\n")); err == nil {
+		t.Error("ParseEnvFile accepted a line without =")
+	}
+}
+
+func TestEnvFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manfred.env")
+	if err := os.WriteFile(path, []byte("GITHUB_TOKEN=ghp_file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p := EnvFileProvider()
+
+	if got, err := p.Resolve(context.Background(), path, "GITHUB_TOKEN"); err != nil || got != "ghp_file" {
+		t.Errorf("Resolve = %q, %v, want ghp_file", got, err)
+	}
+	if _, err := p.Resolve(context.Background(), path, "MISSING"); err == nil {
+		t.Error("Resolve of a missing variable succeeded")
+	}
+}
+
+func TestLookupPath(t *testing.T) {
+	doc := map[string]any{"github": map[string]any{"token": "ghp_sops", "port": float64(8080)}}
+
+	if got, err := lookupPath(doc, "github.token"); err != nil || got != "ghp_sops" {
+		t.Errorf("github.token = %q, %v, want ghp_sops", got, err)
+	}
+	if got, err := lookupPath(doc, "github.port"); err != nil || got != "8080" {
+		t.Errorf("github.port = %q, %v, want 8080", got, err)
+	}
+	if _, err := lookupPath(doc, "github"); err == nil {
+		t.Error("lookupPath of a map succeeded")
+	}
+	if _, err := lookupPath(doc, "github.missing"); err == nil {
+		t.Error("lookupPath of a missing key succeeded")
+	}
+}
+
+func TestVaultProvider(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv/data/manfred":
+			w.Write([]byte(`{"data":{"data":{"github_token":"ghp_v2"}}}`))
+		case "/v1/secret/legacy":
+			w.Write([]byte(`{"data":{"token":"ghp_v1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("VAULT_TOKEN", "s.token")
+	p := VaultProvider(VaultOptions{Addr: server.URL})
+	ctx := context.Background()
+
+	if got, err := p.Resolve(ctx, "kv/manfred", "github_token"); err != nil || got != "ghp_v2" {
+		t.Errorf("KV v2 = %q, %v, want ghp_v2", got, err)
+	}
+	if got, err := p.Resolve(ctx, "secret/legacy", "token"); err != nil || got != "ghp_v1" {
+		t.Errorf("KV v1 = %q, %v, want ghp_v1", got, err)
+	}
+	before := requests
+	if _, err := p.Resolve(ctx, "kv/manfred", "missing"); err == nil {
+		t.Error("Resolve of a missing field succeeded")
+	}
+	if requests != before {
+		t.Errorf("Resolve read kv/manfred again, want it cached")
+	}
+
+	t.Setenv("VAULT_TOKEN", "wrong")
+	if _, err := VaultProvider(VaultOptions{Addr: server.URL}).Resolve(ctx, "kv/manfred", "github_token"); err == nil {
+		t.Error("Resolve with a rejected token succeeded")
+	}
+}
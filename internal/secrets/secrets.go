@@ -0,0 +1,251 @@
+// Package secrets keeps tokens and credentials encrypted at rest. Each
+// secret is sealed with AES-256-GCM under a key held outside the store, in
+// the OS keyring, a key file or the environment, and is only decrypted when
+// it is used.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Names of the secrets MANFRED reads from the store.
+const (
+	AnthropicAPIKey   = "anthropic_api_key"  // credentials.anthropic_api_key
+	GitHubToken       = "github_token"       // github.token
+	WebhookSecret     = "webhook_secret"     // github.webhook_secret
+	ClaudeCredentials = "claude_credentials" // Contents of credentials.claude_credentials_file
+)
+
+// Names lists the known secret names with the setting each one supplies.
+var Names = map[string]string{
+	AnthropicAPIKey:   "credentials.anthropic_api_key",
+	GitHubToken:       "github.token",
+	WebhookSecret:     "github.webhook_secret",
+	ClaudeCredentials: "credentials.claude_credentials_file",
+}
+
+// storeVersion is the version of the store file format.
+const storeVersion = 1
+
+// storeFile is the on-disk format: secret names in the clear, each value
+// sealed separately with its name as additional data, so a value cannot be
+// moved to another name.
+type storeFile struct {
+	Version int               `json:"version"`
+	Secrets map[string]string `json:"secrets"` // Name -> base64(nonce | ciphertext)
+}
+
+// Store is an encrypted secrets file. Reading which secrets exist needs no
+// key; the key is fetched from its source on the first decryption or
+// encryption and kept in memory.
+type Store struct {
+	path string
+	key  KeySource
+
+	mu     sync.Mutex
+	cached []byte
+}
+
+// Open returns the store at path, whose values are sealed with the key from
+// key. The file need not exist yet.
+func Open(path string, key KeySource) *Store {
+	return &Store{path: path, key: key}
+}
+
+// Path returns the store's file path.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Exists reports whether the store file exists.
+func (s *Store) Exists() bool {
+	_, err := os.Stat(s.path)
+	return err == nil
+}
+
+// List returns the names of the stored secrets, sorted.
+func (s *Store) List() ([]string, error) {
+	f, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(f.Secrets))
+	for name := range f.Secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Has reports whether a secret is stored, without decrypting it. A missing or
+// unreadable store has no secrets.
+func (s *Store) Has(name string) bool {
+	f, err := s.read()
+	if err != nil {
+		return false
+	}
+	_, ok := f.Secrets[name]
+	return ok
+}
+
+// Get decrypts a secret. It returns "" and no error if the secret is not
+// stored.
+func (s *Store) Get(name string) (string, error) {
+	f, err := s.read()
+	if err != nil {
+		return "", err
+	}
+	sealed, ok := f.Secrets[name]
+	if !ok {
+		return "", nil
+	}
+
+	gcm, err := s.cipher(false)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("secret %s is corrupt", name)
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret %s: wrong key or corrupt store", name)
+	}
+	return string(plaintext), nil
+}
+
+// Set encrypts and stores a secret, creating the store, and the key if its
+// source has none yet.
+func (s *Store) Set(name, value string) error {
+	f, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	gcm, err := s.cipher(true)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(value), []byte(name))
+	f.Secrets[name] = base64.StdEncoding.EncodeToString(sealed)
+
+	return s.write(f)
+}
+
+// Delete removes a secret. Removing a secret that is not stored is not an
+// error.
+func (s *Store) Delete(name string) error {
+	f, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := f.Secrets[name]; !ok {
+		return nil
+	}
+	delete(f.Secrets, name)
+	return s.write(f)
+}
+
+// Unlock fetches the key and decrypts every secret, to check that the store
+// can be read. It returns the names of the stored secrets.
+func (s *Store) Unlock() ([]string, error) {
+	names, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if _, err := s.Get(name); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+// cipher returns the AES-GCM cipher for the store key. With create, a key is
+// generated and saved if the source has none.
+func (s *Store) cipher(create bool) (cipher.AEAD, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cached == nil {
+		key, err := s.key.Key()
+		if errors.Is(err, ErrNoKey) && create {
+			key, err = NewKey()
+			if err == nil {
+				err = s.key.SaveKey(key)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get secrets key from %s: %w", s.key, err)
+		}
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("secrets key from %s has %d bytes, want %d", s.key, len(key), KeySize)
+		}
+		s.cached = key
+	}
+
+	block, err := aes.NewCipher(s.cached)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// read loads the store file; a missing file is an empty store.
+func (s *Store) read() (*storeFile, error) {
+	f := &storeFile{Version: storeVersion, Secrets: map[string]string{}}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets store: %w", err)
+	}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets store %s: %w", s.path, err)
+	}
+	if f.Version != storeVersion {
+		return nil, fmt.Errorf("secrets store %s has version %d, want %d", s.path, f.Version, storeVersion)
+	}
+	if f.Secrets == nil {
+		f.Secrets = map[string]string{}
+	}
+	return f, nil
+}
+
+// write saves the store file, readable only by its owner.
+func (s *Store) write(f *storeFile) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode secrets store: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create secrets directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write secrets store: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write secrets store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newFileStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "secrets.key")
+	source, err := NewKeySource(KeyFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewKeySource: %v", err)
+	}
+	return Open(filepath.Join(dir, "secrets.json"), source), keyFile
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	store, keyFile := newFileStore(t)
+
+	if got, err := store.Get(GitHubToken); err != nil || got != "" {
+		t.Fatalf("Get on missing store = %q, %v, want empty", got, err)
+	}
+	if err := store.Set(GitHubToken, "ghp_secret"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := store.Set(AnthropicAPIKey, "sk-ant-secret"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// A fresh store reads the key back from the key file
+	source, _ := NewKeySource(KeyFile, keyFile)
+	reopened := Open(store.Path(), source)
+	got, err := reopened.Get(GitHubToken)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got != "ghp_secret" {
+		t.Errorf("Get(%s) = %q, want %q", GitHubToken, got, "ghp_secret")
+	}
+
+	names, err := reopened.Unlock()
+	if err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if want := []string{AnthropicAPIKey, GitHubToken}; strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("Unlock names = %v, want %v", names, want)
+	}
+
+	data, err := os.ReadFile(store.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "ghp_secret") || strings.Contains(string(data), "sk-ant-secret") {
+		t.Errorf("store file contains a plaintext value:\n%s", data)
+	}
+	for _, path := range []string{store.Path(), keyFile} {
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("%s mode = %v, %v, want 0600", path, info.Mode().Perm(), err)
+		}
+	}
+}
+
+func TestStoreHasWithoutKey(t *testing.T) {
+	store, _ := newFileStore(t)
+	if err := store.Set(WebhookSecret, "hook"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// Names are readable without the key
+	locked := Open(store.Path(), envSource{})
+	t.Setenv(KeyEnv, "")
+	if !locked.Has(WebhookSecret) {
+		t.Errorf("Has(%s) = false, want true", WebhookSecret)
+	}
+	if locked.Has(GitHubToken) {
+		t.Errorf("Has(%s) = true, want false", GitHubToken)
+	}
+	if _, err := locked.Get(WebhookSecret); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Get without key: err = %v, want ErrNoKey", err)
+	}
+}
+
+func TestStoreWrongKey(t *testing.T) {
+	store, _ := newFileStore(t)
+	if err := store.Set(GitHubToken, "ghp_secret"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	key, _ := NewKey()
+	t.Setenv(KeyEnv, base64.StdEncoding.EncodeToString(key))
+	other := Open(store.Path(), envSource{})
+	if _, err := other.Get(GitHubToken); err == nil || !strings.Contains(err.Error(), "wrong key") {
+		t.Errorf("Get with wrong key: err = %v, want wrong key error", err)
+	}
+	if _, err := other.Unlock(); err == nil {
+		t.Error("Unlock with wrong key succeeded")
+	}
+}
+
+func TestStoreBindsValueToName(t *testing.T) {
+	store, _ := newFileStore(t)
+	if err := store.Set(GitHubToken, "ghp_secret"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// Moving a sealed value to another name must not decrypt
+	f, err := store.read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Secrets[AnthropicAPIKey] = f.Secrets[GitHubToken]
+	if err := store.write(f); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(AnthropicAPIKey); err == nil {
+		t.Error("Get of a value moved to another name succeeded")
+	}
+}
+
+func TestStoreDelete(t *testing.T) {
+	store, _ := newFileStore(t)
+	if err := store.Set(GitHubToken, "ghp_secret"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := store.Delete(GitHubToken); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if store.Has(GitHubToken) {
+		t.Errorf("Has(%s) after Delete = true", GitHubToken)
+	}
+	if err := store.Delete(GitHubToken); err != nil {
+		t.Errorf("Delete of missing secret: %v", err)
+	}
+}
+
+func TestEnvSource(t *testing.T) {
+	t.Setenv(KeyEnv, "")
+	if _, err := (envSource{}).Key(); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Key with %s unset: err = %v, want ErrNoKey", KeyEnv, err)
+	}
+
+	// The env source cannot create a key, so Set fails with a hint
+	store := Open(filepath.Join(t.TempDir(), "secrets.json"), envSource{})
+	if err := store.Set(GitHubToken, "x"); err == nil || !strings.Contains(err.Error(), KeyEnv) {
+		t.Errorf("Set without key: err = %v, want hint about %s", err, KeyEnv)
+	}
+
+	t.Setenv(KeyEnv, base64.StdEncoding.EncodeToString([]byte("short")))
+	if err := store.Set(GitHubToken, "x"); err == nil || !strings.Contains(err.Error(), "want 32") {
+		t.Errorf("Set with short key: err = %v, want size error", err)
+	}
+}
+
+func TestNewKeySource(t *testing.T) {
+	if _, err := NewKeySource(KeyFile, ""); err == nil {
+		t.Error("file source without key file succeeded")
+	}
+	if _, err := NewKeySource("vault", ""); err == nil {
+		t.Error("unknown source succeeded")
+	}
+	if s, err := NewKeySource("", ""); err != nil || s.String() != "OS keyring" {
+		t.Errorf("default source = %v, %v, want OS keyring", s, err)
+	}
+}
@@ -0,0 +1,70 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// sopsProvider resolves sops:<file>#<key> from SOPS-encrypted YAML, JSON,
+// dotenv or INI files, decrypted with the sops CLI. The key is a dotted path
+// into the document, e.g. github.token. Files are decrypted once.
+type sopsProvider struct {
+	mu   sync.Mutex
+	docs map[string]any
+}
+
+// SOPSProvider returns a provider for SOPS-encrypted files.
+func SOPSProvider() Provider {
+	return &sopsProvider{docs: map[string]any{}}
+}
+
+func (p *sopsProvider) Resolve(ctx context.Context, path, key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("missing #key of the value")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	doc, ok := p.docs[path]
+	if !ok {
+		cmd := exec.CommandContext(ctx, "sops", "--decrypt", "--output-type", "json", path)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("sops --decrypt: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		if err := json.Unmarshal(out, &doc); err != nil {
+			return "", fmt.Errorf("failed to parse decrypted %s: %w", path, err)
+		}
+		p.docs[path] = doc
+	}
+	return lookupPath(doc, key)
+}
+
+// lookupPath returns the string at a dotted path in a decoded JSON document.
+func lookupPath(doc any, key string) (string, error) {
+	v := doc
+	for _, part := range strings.Split(key, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("%s not found", key)
+		}
+		if v, ok = m[part]; !ok {
+			return "", fmt.Errorf("%s not found", key)
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case float64, bool:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("%s is not a scalar", key)
+	}
+}
@@ -0,0 +1,168 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VaultOptions configure the Vault provider. Empty fields fall back to the
+// Vault CLI's environment: VAULT_ADDR, VAULT_TOKEN, ~/.vault-token and
+// VAULT_NAMESPACE.
+type VaultOptions struct {
+	Addr      string
+	TokenFile string
+	Namespace string
+	Client    *http.Client
+}
+
+// vaultProvider resolves vault:<path>#<field> from HashiCorp Vault KV
+// secrets, e.g. vault:kv/manfred#github_token. It reads KV v2 mounts, whose
+// API path is <mount>/data/<rest>, and falls back to KV v1. Secrets are read
+// once.
+type vaultProvider struct {
+	opts VaultOptions
+
+	mu      sync.Mutex
+	secrets map[string]map[string]any
+}
+
+// VaultProvider returns a provider for HashiCorp Vault.
+func VaultProvider(opts VaultOptions) Provider {
+	if opts.Addr == "" {
+		opts.Addr = os.Getenv("VAULT_ADDR")
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &vaultProvider{opts: opts, secrets: map[string]map[string]any{}}
+}
+
+// errVaultNotFound is returned for paths Vault has no secret at.
+var errVaultNotFound = errors.New("not found")
+
+func (p *vaultProvider) Resolve(ctx context.Context, path, key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("missing #field of the secret")
+	}
+	if p.opts.Addr == "" {
+		return "", fmt.Errorf("no Vault address; set secrets.vault.addr or VAULT_ADDR")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	data, ok := p.secrets[path]
+	if !ok {
+		var err error
+		if data, err = p.read(ctx, path); err != nil {
+			return "", err
+		}
+		p.secrets[path] = data
+	}
+
+	switch v := data[key].(type) {
+	case nil:
+		return "", fmt.Errorf("no field %s in %s", key, path)
+	case string:
+		return v, nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// read fetches the secret at path, trying KV v2 first.
+func (p *vaultProvider) read(ctx context.Context, path string) (map[string]any, error) {
+	token, err := p.token()
+	if err != nil {
+		return nil, err
+	}
+	path = strings.Trim(path, "/")
+
+	if mount, rest, ok := strings.Cut(path, "/"); ok {
+		var v2 struct {
+			Data struct {
+				Data map[string]any `json:"data"`
+			} `json:"data"`
+		}
+		err := p.get(ctx, token, mount+"/data/"+rest, &v2)
+		if err == nil && v2.Data.Data != nil {
+			return v2.Data.Data, nil
+		}
+		if err != nil && !errors.Is(err, errVaultNotFound) {
+			return nil, err
+		}
+	}
+
+	var v1 struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := p.get(ctx, token, path, &v1); err != nil {
+		return nil, err
+	}
+	return v1.Data, nil
+}
+
+// get reads /v1/<path> into out.
+func (p *vaultProvider) get(ctx context.Context, token, path string, out any) error {
+	url := strings.TrimRight(p.opts.Addr, "/") + "/v1/" + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if p.opts.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.opts.Namespace)
+	}
+
+	resp, err := p.opts.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Vault: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read Vault response: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%s: %w", path, errVaultNotFound)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("Vault returned %s for %s: %s", resp.Status, path, strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse Vault response: %w", err)
+	}
+	return nil
+}
+
+// token returns VAULT_TOKEN, or the token file's contents.
+func (p *vaultProvider) token() (string, error) {
+	if token := os.Getenv("VAULT_TOKEN"); token != "" && p.opts.TokenFile == "" {
+		return token, nil
+	}
+	file := p.opts.TokenFile
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("no Vault token; set VAULT_TOKEN")
+		}
+		file = filepath.Join(home, ".vault-token")
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("no Vault token; set VAULT_TOKEN or secrets.vault.token_file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
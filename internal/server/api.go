@@ -0,0 +1,239 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mpm/manfred/internal/apikey"
+	"github.com/mpm/manfred/internal/audit"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// SessionActions changes the phase of sessions, as the orchestrator does.
+type SessionActions interface {
+	Approve(ctx context.Context, sessionID, sender string) error
+	Abort(ctx context.Context, sessionID, sender string) error
+}
+
+// sessionJSON is a session as the REST API returns it.
+type sessionJSON struct {
+	ID           string    `json:"id"`
+	Repo         string    `json:"repo"`
+	IssueNumber  int       `json:"issue_number"`
+	PRNumber     *int      `json:"pr_number,omitempty"`
+	Phase        string    `json:"phase"`
+	Branch       string    `json:"branch"`
+	ErrorMessage *string   `json:"error_message,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastActivity time.Time `json:"last_activity"`
+}
+
+func newSessionJSON(sess *session.Session) sessionJSON {
+	return sessionJSON{
+		ID:           sess.ID,
+		Repo:         sess.RepoOwner + "/" + sess.RepoName,
+		IssueNumber:  sess.IssueNumber,
+		PRNumber:     sess.PRNumber,
+		Phase:        string(sess.Phase),
+		Branch:       sess.Branch,
+		ErrorMessage: sess.ErrorMessage,
+		CreatedAt:    sess.CreatedAt,
+		LastActivity: sess.LastActivity,
+	}
+}
+
+// handleListSessions returns the sessions as JSON, most recently active
+// first. ?active=true leaves out finished sessions.
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := s.sessions.List(r.Context(), session.SessionFilter{
+		ActiveOnly: r.URL.Query().Get("active") == "true",
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	list := make([]sessionJSON, 0, len(sessions))
+	for i := range sessions {
+		list = append(list, newSessionJSON(&sessions[i]))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"sessions": list})
+}
+
+func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	sess, ok := s.lookupSession(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, newSessionJSON(sess))
+}
+
+func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.lookupSession(w, r); !ok {
+		return
+	}
+	if err := s.sessions.Delete(r.Context(), r.PathValue("id")); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.audit.Record(r.Context(), audit.ActionSessionDelete, r.PathValue("id"), nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleApproveSession approves the plan of a session awaiting approval on
+// behalf of the request's API key, which counts as one approver towards the
+// approval policy. Implementing it runs a job, so it happens in the
+// background, in a context that keeps the request's actor for the audit log.
+func (s *Server) handleApproveSession(w http.ResponseWriter, r *http.Request) {
+	sess, ok := s.lookupSession(w, r)
+	if !ok {
+		return
+	}
+	if sess.Phase != session.PhaseAwaitingApproval {
+		http.Error(w, "session is "+string(sess.Phase)+", not awaiting approval", http.StatusConflict)
+		return
+	}
+	go func() {
+		if err := s.actions.Approve(context.WithoutCancel(r.Context()), sess.ID, apiSender(r)); err != nil {
+			log.Printf("api: approving session %s: %v", sess.ID, err)
+		}
+	}()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) handleAbortSession(w http.ResponseWriter, r *http.Request) {
+	sess, ok := s.lookupSession(w, r)
+	if !ok {
+		return
+	}
+	if err := s.actions.Abort(context.WithoutCancel(r.Context()), sess.ID, apiSender(r)); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiSender returns the sender session actions of r are taken for: its API
+// key, as github.APISender names it.
+func apiSender(r *http.Request) string {
+	return github.APISender(audit.ActorFrom(r.Context()).Name)
+}
+
+// lookupSession returns the session of the {id} path value, or writes a
+// 404 if there is none.
+func (s *Server) lookupSession(w http.ResponseWriter, r *http.Request) (*session.Session, bool) {
+	sess, err := s.sessions.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if sess == nil {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return nil, false
+	}
+	return sess, true
+}
+
+func (s *Server) handleListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := s.keys.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if keys == nil {
+		keys = []apikey.Key{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": keys})
+}
+
+// handleCreateKey creates a key from a {"name": ..., "scope": ...} body and
+// returns it with its token, which is not shown again.
+func (s *Server) handleCreateKey(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name  string `json:"name"`
+		Scope string `json:"scope"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	scope, err := apikey.ParseScope(req.Scope)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	key, token, err := s.keys.Create(r.Context(), req.Name, scope)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.audit.Record(r.Context(), audit.ActionAPIKeyCreate, strconv.FormatInt(key.ID, 10), map[string]string{
+		"name":  key.Name,
+		"scope": string(key.Scope),
+	})
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"key": key, "token": token})
+}
+
+func (s *Server) handleRevokeKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid key ID", http.StatusBadRequest)
+		return
+	}
+	if err := s.keys.Revoke(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	s.audit.Record(r.Context(), audit.ActionAPIKeyRevoke, r.PathValue("id"), nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListAudit returns audit log entries as JSON, newest first,
+// filtered by the action, target, actor_type, actor and since (RFC 3339)
+// query parameters. limit defaults to 100.
+func (s *Server) handleListAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := audit.Filter{
+		Action:    q.Get("action"),
+		Target:    q.Get("target"),
+		ActorType: audit.ActorType(q.Get("actor_type")),
+		Actor:     q.Get("actor"),
+		Limit:     100,
+	}
+	if since := q.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.Since = t
+	}
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = n
+	}
+
+	entries, err := s.audit.List(r.Context(), filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"entries": entries})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
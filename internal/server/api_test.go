@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mpm/manfred/internal/apikey"
+	"github.com/mpm/manfred/internal/audit"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/store"
+)
+
+type fakeActions struct{ aborted, senders []string }
+
+func (f *fakeActions) Approve(ctx context.Context, sessionID, sender string) error { return nil }
+
+func (f *fakeActions) Abort(ctx context.Context, sessionID, sender string) error {
+	f.aborted = append(f.aborted, sessionID)
+	f.senders = append(f.senders, sender)
+	return nil
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	db, err := store.OpenInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	sessions := session.NewSQLStore(db)
+	keys := apikey.NewSQLStore(db)
+	var ids []string
+	for _, issue := range []int{1, 2} {
+		sess := session.NewSession("acme", "widgets", issue)
+		if err := sessions.Create(ctx, sess); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, sess.ID)
+	}
+
+	actions := &fakeActions{}
+	auditLog := audit.NewLog(audit.NewSQLStore(db))
+	s := New("", "", t.TempDir(), nil, nil)
+	s.SetAPIKeys(keys)
+	s.SetSessions(sessions, actions)
+	s.SetAudit(auditLog)
+	handler := s.Handler()
+
+	remote := "127.0.0.1:4711"
+	do := func(method, path, token, body string) int {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.RemoteAddr = remote
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// Without keys this host may read, but nothing else and nobody else
+	if code := do(http.MethodGet, "/api/v1/sessions", "", ""); code != http.StatusOK {
+		t.Fatalf("GET sessions without keys = %d, want 200", code)
+	}
+	if code := do(http.MethodPost, "/api/v1/keys", "", `{"name":"root","scope":"admin"}`); code != http.StatusForbidden {
+		t.Errorf("POST keys without keys = %d, want 403", code)
+	}
+	if code := do(http.MethodPost, "/api/v1/sessions/"+ids[0]+"/abort", "", ""); code != http.StatusForbidden {
+		t.Errorf("POST abort without keys = %d, want 403", code)
+	}
+	remote = "203.0.113.5:4711"
+	if code := do(http.MethodGet, "/api/v1/sessions", "", ""); code != http.StatusUnauthorized {
+		t.Errorf("GET sessions without keys from elsewhere = %d, want 401", code)
+	}
+
+	_, reader, err := keys.Create(ctx, "dashboard", apikey.ScopeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, operator, err := keys.Create(ctx, "ci", apikey.ScopeOperator)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := ids[0], ids[1]
+	tests := []struct {
+		method, path, token, body string
+		want                      int
+	}{
+		{"GET", "/api/v1/sessions", "", "", http.StatusUnauthorized},
+		{"GET", "/api/v1/sessions", "mfd_unknown", "", http.StatusUnauthorized},
+		{"GET", "/api/v1/sessions", reader, "", http.StatusOK},
+		{"GET", "/api/v1/sessions/" + first, reader, "", http.StatusOK},
+		{"DELETE", "/api/v1/sessions/" + first, reader, "", http.StatusForbidden},
+		{"POST", "/api/v1/sessions/" + first + "/abort", operator, "", http.StatusNoContent},
+		{"DELETE", "/api/v1/sessions/" + second, operator, "", http.StatusNoContent},
+		{"GET", "/api/v1/sessions/" + second, operator, "", http.StatusNotFound},
+		{"POST", "/api/v1/sessions/" + first + "/approve", operator, "", http.StatusConflict},
+		{"GET", "/api/v1/keys", operator, "", http.StatusForbidden},
+		{"POST", "/api/v1/keys", operator, `{"name":"root","scope":"admin"}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if code := do(tt.method, tt.path, tt.token, tt.body); code != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, code, tt.want)
+		}
+	}
+	if len(actions.aborted) != 1 || actions.aborted[0] != first || actions.senders[0] != "api-key:ci" {
+		t.Errorf("aborted = %v by %v, want [%s] by api-key:ci", actions.aborted, actions.senders, first)
+	}
+	entries, err := auditLog.List(ctx, audit.Filter{Action: audit.ActionSessionDelete})
+	if err != nil || len(entries) != 1 || entries[0].Target != second || entries[0].Actor.String() != "api_key:ci" {
+		t.Errorf("audited deletions = %+v, %v, want %s by api_key:ci", entries, err, second)
+	}
+	if code := do(http.MethodGet, "/api/v1/audit", operator, ""); code != http.StatusForbidden {
+		t.Errorf("GET audit as operator = %d, want 403", code)
+	}
+
+	_, admin, err := keys.Create(ctx, "ops", apikey.ScopeAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := do(http.MethodPost, "/api/v1/keys", admin, `{"name":"bot","scope":"read"}`); code != http.StatusCreated {
+		t.Errorf("POST keys as admin = %d, want 201", code)
+	}
+	if code := do(http.MethodDelete, "/api/v1/keys/1", admin, ""); code != http.StatusNoContent {
+		t.Errorf("DELETE keys/1 as admin = %d, want 204", code)
+	}
+	if code := do(http.MethodGet, "/api/v1/audit?actor=ops&action=api_key.revoke", admin, ""); code != http.StatusOK {
+		t.Errorf("GET audit as admin = %d, want 200", code)
+	}
+	if code := do(http.MethodGet, "/api/v1/sessions", reader, ""); code != http.StatusUnauthorized {
+		t.Errorf("GET sessions with a revoked key = %d, want 401", code)
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/mpm/manfred/internal/job"
+)
+
+// handleListArtifacts returns the artifacts of a job as JSON.
+func (s *Server) handleListArtifacts(w http.ResponseWriter, r *http.Request) {
+	artifacts, err := job.ListArtifacts(s.jobsDir, r.PathValue("id"))
+	if errors.Is(err, os.ErrNotExist) {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if artifacts == nil {
+		artifacts = []job.Artifact{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"job_id":    r.PathValue("id"),
+		"artifacts": artifacts,
+	})
+}
+
+// handleGetArtifact downloads a single artifact file.
+func (s *Server) handleGetArtifact(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("path")
+	p, err := job.ArtifactPath(s.jobsDir, r.PathValue("id"), name)
+	if errors.Is(err, os.ErrNotExist) {
+		http.Error(w, "artifact not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		http.Error(w, "artifact not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		http.Error(w, "artifact not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(name)}))
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
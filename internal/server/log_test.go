@@ -0,0 +1,54 @@
+package server
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpm/manfred/internal/job"
+)
+
+type fakeLogs map[string]*job.Hub
+
+func (f fakeLogs) JobLog(jobID string) http.Handler {
+	if hub, ok := f[jobID]; ok {
+		return hub
+	}
+	return nil
+}
+
+func TestJobLog(t *testing.T) {
+	hub := job.NewHub()
+	s := New("", "", t.TempDir(), nil, nil)
+	s.SetJobLogs(fakeLogs{"job_1": hub})
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/v1/jobs/job_2/log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("log of a job not running = %d, want 404", resp.StatusCode)
+	}
+
+	resp, err = http.Get(server.URL + "/api/v1/jobs/job_1/log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+	hub.WriteEntry(job.Entry{Time: time.Now(), Source: "CLAUDE", Message: "hello"})
+	hub.Close()
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "data: ") || !strings.Contains(line, `"message":"hello"`) {
+		t.Errorf("first event = %q, %v, want the entry", line, err)
+	}
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mpm/manfred/internal/search"
+)
+
+// handleSearch returns the past work matching the q query parameter as JSON,
+// best match first, optionally limited to the comma-separated kinds of kind
+// (ticket, session, job) and to project. limit defaults to 20.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := search.Query{
+		Text:    params.Get("q"),
+		Project: params.Get("project"),
+	}
+	if strings.TrimSpace(query.Text) == "" {
+		http.Error(w, "missing q", http.StatusBadRequest)
+		return
+	}
+	if kinds := params.Get("kind"); kinds != "" {
+		for _, k := range strings.Split(kinds, ",") {
+			kind, err := search.ParseKind(strings.TrimSpace(k))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			query.Kinds = append(query.Kinds, kind)
+		}
+	}
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		query.Limit = n
+	}
+
+	results, err := s.search.Search(r.Context(), query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if results == nil {
+		results = []search.Result{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"results": results})
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mpm/manfred/internal/search"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/store"
+)
+
+func TestSearch(t *testing.T) {
+	db, err := store.OpenInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	sessions := session.NewSQLStore(db)
+	sess := session.NewSession("acme", "widgets", 3)
+	plan := "Retry flaky uploads with exponential backoff."
+	sess.PlanContent = &plan
+	if err := sessions.Create(ctx, sess); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New("", "", t.TempDir(), nil, nil)
+	s.SetSearch(search.NewIndex(db, t.TempDir(), t.TempDir(), sessions))
+	handler := s.Handler()
+
+	tests := []struct {
+		query string
+		code  int
+		want  int
+	}{
+		{"?q=flaky+uploads", http.StatusOK, 1},
+		{"?q=flaky&kind=session,job&project=acme/widgets", http.StatusOK, 1},
+		{"?q=flaky&kind=ticket", http.StatusOK, 0},
+		{"?q=flaky&kind=issue", http.StatusBadRequest, 0},
+		{"?q=flaky&limit=0", http.StatusBadRequest, 0},
+		{"", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/search"+tt.query, nil)
+		r.RemoteAddr = "127.0.0.1:4711"
+		handler.ServeHTTP(w, r)
+		if w.Code != tt.code {
+			t.Errorf("GET search%s = %d, want %d", tt.query, w.Code, tt.code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		var body struct {
+			Results []search.Result `json:"results"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Results) != tt.want {
+			t.Errorf("GET search%s = %d results, want %d", tt.query, len(body.Results), tt.want)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mpm/manfred/internal/apikey"
+	"github.com/mpm/manfred/internal/audit"
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+)
+
+// MetaClient fetches the address ranges GitHub delivers webhooks from.
+type MetaClient interface {
+	Meta(ctx context.Context) (*github.Meta, error)
+}
+
+// Allowlist holds the address ranges webhook deliveries may come from: the
+// configured CIDRs plus, once fetched, GitHub's hook ranges.
+type Allowlist struct {
+	static     []netip.Prefix
+	trustProxy bool
+
+	mu     sync.RWMutex
+	github []netip.Prefix
+}
+
+// NewAllowlist creates an allowlist of cidrs. With trustProxy, the client
+// address is the last hop of X-Forwarded-For, as added by a reverse proxy
+// in front of the server.
+func NewAllowlist(cidrs []string, trustProxy bool) (*Allowlist, error) {
+	a := &Allowlist{trustProxy: trustProxy}
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+		}
+		a.static = append(a.static, prefix)
+	}
+	return a, nil
+}
+
+// Refresh replaces the GitHub ranges with the current hook ranges of the
+// meta API. On failure the previous ranges stay in effect.
+func (a *Allowlist) Refresh(ctx context.Context, client MetaClient) error {
+	meta, err := client.Meta(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch GitHub hook ranges: %w", err)
+	}
+	var prefixes []netip.Prefix
+	for _, cidr := range meta.Hooks {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return fmt.Errorf("GitHub published an invalid hook range %q: %w", cidr, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	a.mu.Lock()
+	a.github = prefixes
+	a.mu.Unlock()
+	return nil
+}
+
+// RunRefresh refreshes the GitHub ranges every interval until ctx is done.
+func (a *Allowlist) RunRefresh(ctx context.Context, client MetaClient, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.Refresh(ctx, client); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Allows reports whether the client of r is in one of the ranges.
+func (a *Allowlist) Allows(r *http.Request) bool {
+	addr, ok := clientAddr(r, a.trustProxy)
+	if !ok {
+		return false
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, prefixes := range [][]netip.Prefix{a.static, a.github} {
+		for _, prefix := range prefixes {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// clientAddr returns the address of the client of r: the peer, or with
+// trustProxy the last X-Forwarded-For hop, which the proxy itself added.
+func clientAddr(r *http.Request, trustProxy bool) (netip.Addr, bool) {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if forwarded := r.Header.Values("X-Forwarded-For"); trustProxy && len(forwarded) > 0 {
+		hops := strings.Split(forwarded[len(forwarded)-1], ",")
+		host = strings.TrimSpace(hops[len(hops)-1])
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// requireScope wraps next so it only serves requests whose credentials
+// grant scope: an API key of the scope or above, or the server.auth token
+// or basic auth credentials, which grant admin. While neither server.auth
+// nor any API key is configured, requests from this host without a proxy
+// in between get read access and all others none. Actions of the request
+// are audited as done by the key (api_key), or by "server.auth" or
+// "anonymous" (http).
+func (s *Server) requireScope(scope apikey.Scope, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		granted, actor, err := s.authenticate(r)
+		if err != nil {
+			log.Printf("server: authenticating %s %s: %v", r.Method, r.URL.Path, err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if granted == "" {
+			if s.auth.Username != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="manfred"`)
+			}
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !granted.Allows(scope) {
+			http.Error(w, fmt.Sprintf("forbidden: requires the %s scope", scope), http.StatusForbidden)
+			return
+		}
+		next(w, r.WithContext(audit.WithActor(r.Context(), actor)))
+	})
+}
+
+// authenticate returns the scope the credentials of r grant, or "" if they
+// grant none, and the actor they identify.
+func (s *Server) authenticate(r *http.Request) (apikey.Scope, audit.Actor, error) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && s.keys != nil && apikey.IsToken(token) {
+		key, err := s.keys.Authenticate(r.Context(), token)
+		if err != nil || key == nil {
+			return "", audit.Actor{}, err
+		}
+		return key.Scope, audit.Actor{Type: audit.ActorAPIKey, Name: key.Name}, nil
+	}
+	if s.auth.Enabled() {
+		if authorized(s.auth, r) {
+			return apikey.ScopeAdmin, audit.Actor{Type: audit.ActorHTTP, Name: "server.auth"}, nil
+		}
+		return "", audit.Actor{}, nil
+	}
+	if s.keys != nil {
+		active, err := s.keys.CountActive(r.Context())
+		if err != nil || active > 0 {
+			return "", audit.Actor{}, err
+		}
+	}
+	if !fromLoopback(r) {
+		return "", audit.Actor{}, nil
+	}
+	return apikey.ScopeRead, audit.Actor{Type: audit.ActorHTTP, Name: "anonymous"}, nil
+}
+
+// fromLoopback reports whether r comes straight from this host. A request
+// a local reverse proxy forwarded does not count, whatever trust_proxy says.
+func fromLoopback(r *http.Request) bool {
+	if len(r.Header.Values("X-Forwarded-For")) > 0 {
+		return false
+	}
+	addr, ok := clientAddr(r, false)
+	return ok && addr.IsLoopback()
+}
+
+// authorized reports whether r carries the bearer token or the basic auth
+// credentials of auth, compared in constant time.
+func authorized(auth config.ServerAuthConfig, r *http.Request) bool {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && auth.Token != "" {
+		return equal(token, auth.Token)
+	}
+	if user, password, ok := r.BasicAuth(); ok && auth.Username != "" {
+		// Both are compared, so timing does not tell which one was wrong
+		userOK := equal(user, auth.Username)
+		return equal(password, auth.Password) && userOK
+	}
+	return false
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// certReloader serves the certificate in certFile and keyFile, loading it
+// again when either file changes, so renewed certificates are picked up
+// without a restart.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modified time.Time
+}
+
+// newCertReloader loads the certificate once to fail early on bad files.
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := c.GetCertificate(nil); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate. A certificate that
+// fails to reload is logged and the previous one kept.
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var modified time.Time
+	for _, file := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			if c.cert != nil {
+				return c.cert, nil
+			}
+			return nil, fmt.Errorf("failed to read TLS certificate: %w", err)
+		}
+		if info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+	}
+	if c.cert != nil && !modified.After(c.modified) {
+		return c.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		if c.cert != nil {
+			log.Printf("server: keeping the previous TLS certificate: %v", err)
+			c.modified = modified
+			return c.cert, nil
+		}
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	c.cert, c.modified = &cert, modified
+	return c.cert, nil
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mpm/manfred/internal/apikey"
+	"github.com/mpm/manfred/internal/audit"
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+)
+
+type fakeMeta struct{ hooks []string }
+
+func (f fakeMeta) Meta(ctx context.Context) (*github.Meta, error) {
+	return &github.Meta{Hooks: f.hooks}, nil
+}
+
+func TestAllowlist(t *testing.T) {
+	allowlist, err := NewAllowlist([]string{"10.1.0.0/16"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := allowlist.Refresh(context.Background(), fakeMeta{hooks: []string{"192.30.252.0/22", "2a0a:a440::/29"}}); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	request := func(remote, forwarded string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, config.WebhookPath, nil)
+		r.RemoteAddr = remote
+		if forwarded != "" {
+			r.Header.Set("X-Forwarded-For", forwarded)
+		}
+		return r
+	}
+	tests := []struct {
+		remote, forwarded string
+		want              bool
+	}{
+		{"192.30.252.10:4711", "", true},
+		{"[2a0a:a440::1]:4711", "", true},
+		{"[::ffff:10.1.2.3]:4711", "", true},
+		{"203.0.113.5:4711", "", false},
+		{"203.0.113.5:4711", "192.30.252.10", false}, // Proxy not trusted
+	}
+	for _, tt := range tests {
+		if got := allowlist.Allows(request(tt.remote, tt.forwarded)); got != tt.want {
+			t.Errorf("Allows(%s, X-Forwarded-For %q) = %v, want %v", tt.remote, tt.forwarded, got, tt.want)
+		}
+	}
+
+	allowlist.trustProxy = true
+	if !allowlist.Allows(request("127.0.0.1:4711", "203.0.113.5, 192.30.252.10")) {
+		t.Error("Allows() with a trusted proxy rejected the last forwarded hop")
+	}
+	if allowlist.Allows(request("127.0.0.1:4711", "192.30.252.10, 203.0.113.5")) {
+		t.Error("Allows() with a trusted proxy accepted a spoofed first hop")
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	s := New("", "", t.TempDir(), nil, nil)
+	s.SetAuth(config.ServerAuthConfig{Token: "s3cret", Username: "admin", Password: "hunter2"})
+	var actor audit.Actor
+	handler := s.requireScope(apikey.ScopeAdmin, func(w http.ResponseWriter, r *http.Request) {
+		actor = audit.ActorFrom(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name string
+		set  func(*http.Request)
+		want int
+	}{
+		{"none", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer s3cret") }, http.StatusNoContent},
+		{"wrong token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") }, http.StatusUnauthorized},
+		{"basic", func(r *http.Request) { r.SetBasicAuth("admin", "hunter2") }, http.StatusNoContent},
+		{"wrong password", func(r *http.Request) { r.SetBasicAuth("admin", "hunter3") }, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/queue", nil)
+		tt.set(r)
+		w := httptest.NewRecorder()
+		actor = audit.Actor{}
+		handler.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+		// server.auth is not an API key
+		if w.Code == http.StatusNoContent && actor.String() != "http:server.auth" {
+			t.Errorf("%s: actor = %v, want http:server.auth", tt.name, actor)
+		}
+	}
+}
+
+func TestHandlerAuth(t *testing.T) {
+	s := New("", "", t.TempDir(), nil, nil)
+	s.SetAuth(config.ServerAuthConfig{Token: "s3cret"})
+	handler := s.Handler()
+
+	for path, want := range map[string]int{
+		"/health":                      http.StatusOK,
+		"/api/v1/jobs/job_1/artifacts": http.StatusUnauthorized,
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != want {
+			t.Errorf("GET %s = %d, want %d", path, w.Code, want)
+		}
+	}
+}
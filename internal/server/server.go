@@ -0,0 +1,260 @@
+// Package server provides the MANFRED HTTP server that receives GitHub webhooks
+// and serves the REST API.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mpm/manfred/internal/apikey"
+	"github.com/mpm/manfred/internal/audit"
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/queue"
+	"github.com/mpm/manfred/internal/search"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/webhook"
+)
+
+// maxPayloadBytes is the largest webhook payload accepted (GitHub caps at 25MB).
+const maxPayloadBytes = 25 << 20
+
+// Server serves the webhook endpoint, health check and REST API.
+type Server struct {
+	addr          string
+	webhookSecret string
+	jobsDir       string
+	router        *webhook.Router
+	queue         *queue.Queue
+
+	tls       *certReloader           // Set by SetTLS
+	auth      config.ServerAuthConfig // Set by SetAuth
+	allowlist *Allowlist              // Set by SetWebhookAllowlist
+	keys      apikey.Store            // Set by SetAPIKeys
+	sessions  session.Store           // Set by SetSessions
+	actions   SessionActions          // Set by SetSessions
+	audit     *audit.Log              // Set by SetAudit
+	search    *search.Index           // Set by SetSearch
+	logs      JobLogs                 // Set by SetJobLogs
+}
+
+// JobLogs streams the logs of running jobs, as the orchestrator does.
+type JobLogs interface {
+	// JobLog returns a handler streaming the job's log, or nil if the job
+	// is not running in this process.
+	JobLog(jobID string) http.Handler
+}
+
+// New creates a new server listening on addr. Job artifacts are served from
+// jobsDir and queue metrics are read from q.
+func New(addr, webhookSecret, jobsDir string, router *webhook.Router, q *queue.Queue) *Server {
+	return &Server{
+		addr:          addr,
+		webhookSecret: webhookSecret,
+		jobsDir:       jobsDir,
+		router:        router,
+		queue:         q,
+	}
+}
+
+// SetTLS makes the server listen for HTTPS with the certificate and key in
+// PEM files, which are reloaded when they change.
+func (s *Server) SetTLS(certFile, keyFile string) error {
+	reloader, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	s.tls = reloader
+	return nil
+}
+
+// SetAuth requires the token or basic auth credentials of auth for the REST
+// API.
+func (s *Server) SetAuth(auth config.ServerAuthConfig) {
+	s.auth = auth
+}
+
+// SetAPIKeys accepts the keys in keys for the REST API, each limited to
+// its scope, and serves the key management endpoints.
+func (s *Server) SetAPIKeys(keys apikey.Store) {
+	s.keys = keys
+}
+
+// SetSessions serves the session endpoints of the REST API from sessions,
+// with actions approving and aborting them.
+func (s *Server) SetSessions(sessions session.Store, actions SessionActions) {
+	s.sessions = sessions
+	s.actions = actions
+}
+
+// SetAudit records the deletions and API key changes made through the REST
+// API in l, and serves the audit log.
+func (s *Server) SetAudit(l *audit.Log) {
+	s.audit = l
+}
+
+// SetSearch serves full-text search of past work from ix.
+func (s *Server) SetSearch(ix *search.Index) {
+	s.search = ix
+}
+
+// SetJobLogs serves the live logs of running jobs from logs.
+func (s *Server) SetJobLogs(logs JobLogs) {
+	s.logs = logs
+}
+
+// SetWebhookAllowlist rejects webhook deliveries from addresses outside a.
+func (s *Server) SetWebhookAllowlist(a *Allowlist) {
+	s.allowlist = a
+}
+
+// Handler returns the HTTP handler with all routes registered. Each REST
+// API route requires a scope of the credentials of SetAuth or SetAPIKeys;
+// the health check is open and webhooks are authenticated by their
+// signature and SetWebhookAllowlist.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", s.handleHealth)
+	mux.HandleFunc("POST "+config.WebhookPath, s.handleGitHubWebhook)
+
+	api := func(pattern string, scope apikey.Scope, handler http.HandlerFunc) {
+		mux.Handle(pattern, s.requireScope(scope, handler))
+	}
+	api("GET /api/v1/jobs/{id}/artifacts", apikey.ScopeRead, s.handleListArtifacts)
+	api("GET /api/v1/jobs/{id}/artifacts/{path...}", apikey.ScopeRead, s.handleGetArtifact)
+	api("GET /api/v1/queue", apikey.ScopeRead, s.handleQueue)
+	if s.logs != nil {
+		api("GET /api/v1/jobs/{id}/log", apikey.ScopeRead, s.handleJobLog)
+	}
+	if s.sessions != nil {
+		api("GET /api/v1/sessions", apikey.ScopeRead, s.handleListSessions)
+		api("GET /api/v1/sessions/{id}", apikey.ScopeRead, s.handleGetSession)
+		api("DELETE /api/v1/sessions/{id}", apikey.ScopeOperator, s.handleDeleteSession)
+		api("POST /api/v1/sessions/{id}/approve", apikey.ScopeOperator, s.handleApproveSession)
+		api("POST /api/v1/sessions/{id}/abort", apikey.ScopeOperator, s.handleAbortSession)
+	}
+	if s.keys != nil {
+		api("GET /api/v1/keys", apikey.ScopeAdmin, s.handleListKeys)
+		api("POST /api/v1/keys", apikey.ScopeAdmin, s.handleCreateKey)
+		api("DELETE /api/v1/keys/{id}", apikey.ScopeAdmin, s.handleRevokeKey)
+	}
+	if s.audit != nil {
+		api("GET /api/v1/audit", apikey.ScopeAdmin, s.handleListAudit)
+	}
+	if s.search != nil {
+		api("GET /api/v1/search", apikey.ScopeRead, s.handleSearch)
+	}
+	return mux
+}
+
+// ListenAndServe runs the server until ctx is cancelled, then shuts down
+// gracefully.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		if s.tls != nil {
+			srv.TLSConfig = &tls.Config{
+				MinVersion:     tls.VersionTLS12,
+				GetCertificate: s.tls.GetCertificate,
+			}
+			log.Printf("server: listening on %s (HTTPS)", s.addr)
+			errCh <- srv.ListenAndServeTLS("", "")
+			return
+		}
+		log.Printf("server: listening on %s", s.addr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("server: shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	io.WriteString(w, "ok\n")
+}
+
+// handleQueue returns the job queue metrics as JSON.
+func (s *Server) handleQueue(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.queue.Stats())
+}
+
+// handleJobLog streams the log of a running job as server-sent events.
+func (s *Server) handleJobLog(w http.ResponseWriter, r *http.Request) {
+	stream := s.logs.JobLog(r.PathValue("id"))
+	if stream == nil {
+		http.Error(w, "job not running", http.StatusNotFound)
+		return
+	}
+	stream.ServeHTTP(w, r)
+}
+
+func (s *Server) handleGitHubWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.allowlist != nil && !s.allowlist.Allows(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadBytes))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	if s.webhookSecret != "" {
+		if err := github.ValidateWebhookSignature(payload, r.Header.Get("X-Hub-Signature-256"), s.webhookSecret); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	eventType := r.Header.Get("X-GitHub-Event")
+	if eventType == "" {
+		http.Error(w, "missing X-GitHub-Event header", http.StatusBadRequest)
+		return
+	}
+
+	event, err := github.ParseWebhookEvent(eventType, payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Jobs can run for a long time, so handle the event in the background
+	// and acknowledge the delivery right away.
+	delivery := r.Header.Get("X-GitHub-Delivery")
+	go func() {
+		if err := s.router.HandleEvent(context.Background(), event); err != nil {
+			log.Printf("webhook: delivery %s (%s): %v", delivery, eventType, err)
+		}
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,196 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// archivePageSize is how many sessions Archive loads per query.
+const archivePageSize = 200
+
+// ArchivedSession is a terminal session moved out of the active tables,
+// together with its events.
+type ArchivedSession struct {
+	Session    Session        `json:"session"`
+	Events     []SessionEvent `json:"events"`
+	ArchivedAt time.Time      `json:"archived_at"`
+}
+
+// ArchiveFilter selects the sessions to archive.
+type ArchiveFilter struct {
+	// Phases limits archiving to these terminal phases; empty means all
+	// terminal phases. Active phases are never archived.
+	Phases []Phase
+
+	// Before archives only sessions last active before this time
+	Before time.Time
+}
+
+// Archive moves the terminal sessions matching filter and their events to
+// the archive table, and returns them oldest first. With dryRun it only
+// returns them.
+func (s *SQLStore) Archive(ctx context.Context, filter ArchiveFilter, dryRun bool) ([]Session, error) {
+	phases := filter.Phases
+	if len(phases) == 0 {
+		phases = []Phase{PhaseCompleted, PhaseError, PhaseAborted, PhaseTriaged}
+	}
+
+	for _, phase := range phases {
+		if !phase.IsTerminal() {
+			return nil, fmt.Errorf("cannot archive sessions in the active phase %s", phase)
+		}
+	}
+
+	// Page through the candidates oldest first, continuing after the last
+	// one seen, so neither the query nor a page grows with the table
+	conditions := []string{"phase IN (?" + strings.Repeat(", ?", len(phases)-1) + ")"}
+	var args []interface{}
+	for _, phase := range phases {
+		args = append(args, string(phase))
+	}
+	if !filter.Before.IsZero() {
+		conditions = append(conditions, "last_activity < ?")
+		args = append(args, filter.Before.UTC())
+	}
+
+	var candidates []Session
+	for {
+		query, pageArgs := listQuery+" WHERE "+strings.Join(conditions, " AND "), args
+		if n := len(candidates); n > 0 {
+			last := candidates[n-1]
+			query += " AND (last_activity > ? OR (last_activity = ? AND id > ?))"
+			pageArgs = append(append([]interface{}{}, args...), last.LastActivity, last.LastActivity, last.ID)
+		}
+		query += fmt.Sprintf(" ORDER BY last_activity ASC, id ASC LIMIT %d", archivePageSize)
+
+		page, err := s.querySessions(ctx, query, pageArgs...)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, page...)
+		if len(page) < archivePageSize {
+			break
+		}
+	}
+	if dryRun {
+		return candidates, nil
+	}
+
+	var archived []Session
+	for _, sess := range candidates {
+		if err := s.archive(ctx, sess); err != nil {
+			return archived, err
+		}
+		archived = append(archived, sess)
+	}
+	return archived, nil
+}
+
+// archive moves a session and its events to the archive table.
+func (s *SQLStore) archive(ctx context.Context, sess Session) error {
+	events, err := s.GetEvents(ctx, sess.ID, EventFilter{})
+	if err != nil {
+		return err
+	}
+	if events == nil {
+		events = []SessionEvent{}
+	}
+	sessionJSON, err := json.Marshal(sess)
+	if err != nil {
+		return fmt.Errorf("marshal session %s: %w", sess.ID, err)
+	}
+	eventsJSON, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("marshal events of %s: %w", sess.ID, err)
+	}
+
+	insert := s.db.Rebind(`
+		INSERT INTO archived_sessions (
+			id, repo_owner, repo_name, issue_number, phase,
+			last_activity, archived_at, session, events
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	// The phase check keeps a session that left its terminal phase since it
+	// was listed, e.g. by a retry, out of the archive
+	remove := s.db.Rebind(`DELETE FROM sessions WHERE id = ? AND phase = ?`)
+
+	return s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, remove, sess.ID, string(sess.Phase))
+		if err != nil {
+			return fmt.Errorf("delete session %s: %w", sess.ID, err)
+		}
+		if rows, err := result.RowsAffected(); err != nil {
+			return fmt.Errorf("get rows affected: %w", err)
+		} else if rows == 0 {
+			return fmt.Errorf("session %s changed while archiving", sess.ID)
+		}
+		_, err = tx.ExecContext(ctx, insert,
+			sess.ID, sess.RepoOwner, sess.RepoName, sess.IssueNumber, string(sess.Phase),
+			sess.LastActivity, time.Now().UTC(), string(sessionJSON), string(eventsJSON))
+		if err != nil {
+			return fmt.Errorf("archive session %s: %w", sess.ID, err)
+		}
+		return nil
+	})
+}
+
+// ListArchived returns archived sessions matching the filter, most recently
+// archived first. ActiveOnly matches nothing, as only terminal sessions are
+// archived.
+func (s *SQLStore) ListArchived(ctx context.Context, filter SessionFilter) ([]ArchivedSession, error) {
+	if filter.ActiveOnly {
+		return nil, nil
+	}
+
+	var conditions []string
+	var args []interface{}
+	if filter.RepoOwner != "" {
+		conditions = append(conditions, "repo_owner = ?")
+		args = append(args, filter.RepoOwner)
+	}
+	if filter.RepoName != "" {
+		conditions = append(conditions, "repo_name = ?")
+		args = append(args, filter.RepoName)
+	}
+	if filter.Phase != nil {
+		conditions = append(conditions, "phase = ?")
+		args = append(args, string(*filter.Phase))
+	}
+
+	query := `SELECT session, events, archived_at FROM archived_sessions`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY archive_id DESC" + s.limitOffset(filter.Limit, filter.Offset)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list archived sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var archived []ArchivedSession
+	for rows.Next() {
+		var a ArchivedSession
+		var sessionJSON, eventsJSON string
+		if err := rows.Scan(&sessionJSON, &eventsJSON, &a.ArchivedAt); err != nil {
+			return nil, fmt.Errorf("scan archived session: %w", err)
+		}
+		if err := json.Unmarshal([]byte(sessionJSON), &a.Session); err != nil {
+			return nil, fmt.Errorf("unmarshal archived session: %w", err)
+		}
+		if err := json.Unmarshal([]byte(eventsJSON), &a.Events); err != nil {
+			return nil, fmt.Errorf("unmarshal archived events of %s: %w", a.Session.ID, err)
+		}
+		archived = append(archived, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate archived sessions: %w", err)
+	}
+
+	return archived, nil
+}
@@ -0,0 +1,128 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSQLStoreArchive(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	old := time.Now().Add(-100 * 24 * time.Hour).UTC()
+	for i, phase := range []Phase{PhaseCompleted, PhaseAborted, PhaseInReview, PhaseCompleted} {
+		sess := NewSession("owner", "repo", i+1)
+		sess.Phase = phase
+		if i < 3 {
+			sess.LastActivity = old
+		}
+		if err := store.Create(ctx, sess); err != nil {
+			t.Fatalf("Create() = %v, want nil", err)
+		}
+		store.RecordEvent(ctx, sess.ID, EventTypeCommentPosted, map[string]int{"issue": i + 1})
+	}
+	before := time.Now().Add(-90 * 24 * time.Hour)
+
+	if _, err := store.Archive(ctx, ArchiveFilter{Phases: []Phase{PhaseInReview}}, false); err == nil {
+		t.Error("Archive(in_review) succeeded, want an error")
+	}
+
+	pending, err := store.Archive(ctx, ArchiveFilter{Before: before}, true)
+	if err != nil || len(pending) != 2 {
+		t.Fatalf("Archive(dry run) = %d sessions, %v, want 2", len(pending), err)
+	}
+	if n, _ := store.Count(ctx, SessionFilter{}); n != 4 {
+		t.Errorf("Count() after dry run = %d, want 4", n)
+	}
+
+	archived, err := store.Archive(ctx, ArchiveFilter{Phases: []Phase{PhaseCompleted}, Before: before}, false)
+	if err != nil || len(archived) != 1 || archived[0].IssueNumber != 1 {
+		t.Fatalf("Archive(completed) = %+v, %v, want issue 1", archived, err)
+	}
+	if sess, _ := store.Get(ctx, archived[0].ID); sess != nil {
+		t.Errorf("Get(%s) after archive = %+v, want nil", archived[0].ID, sess)
+	}
+	if n, _ := store.Count(ctx, SessionFilter{}); n != 3 {
+		t.Errorf("Count() after archive = %d, want 3", n)
+	}
+
+	list, err := store.ListArchived(ctx, SessionFilter{RepoOwner: "owner"})
+	if err != nil || len(list) != 1 {
+		t.Fatalf("ListArchived() = %d sessions, %v, want 1", len(list), err)
+	}
+	got := list[0]
+	if got.Session.ID != archived[0].ID || got.Session.Phase != PhaseCompleted || !got.Session.LastActivity.Equal(old) {
+		t.Errorf("archived session = %+v, want issue 1 completed at %v", got.Session, old)
+	}
+	if len(got.Events) != 1 || got.Events[0].EventType != EventTypeCommentPosted {
+		t.Errorf("archived events = %+v, want the comment_posted event", got.Events)
+	}
+	if got.ArchivedAt.IsZero() {
+		t.Error("ArchivedAt is zero")
+	}
+
+	// The issue can be picked up again and archived a second time
+	again := NewSession("owner", "repo", 1)
+	again.Phase = PhaseCompleted
+	if err := store.Create(ctx, again); err != nil {
+		t.Fatalf("Create() after archive = %v, want nil", err)
+	}
+	if archived, err := store.Archive(ctx, ArchiveFilter{Phases: []Phase{PhaseCompleted}}, false); err != nil || len(archived) != 2 {
+		t.Errorf("Archive(completed) = %d sessions, %v, want 2", len(archived), err)
+	}
+	if list, _ := store.ListArchived(ctx, SessionFilter{}); len(list) != 3 {
+		t.Errorf("ListArchived() = %d sessions, want 3", len(list))
+	}
+}
+
+func TestSQLStoreArchivePages(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	// More sessions than fit in a page, most of them equally old
+	ctx := context.Background()
+	old := time.Now().Add(-time.Hour).UTC()
+	total := archivePageSize + 5
+	for i := 1; i <= total; i++ {
+		sess := NewSession("owner", "repo", i)
+		sess.Phase = PhaseCompleted
+		sess.LastActivity = old
+		if i == total {
+			sess.LastActivity = old.Add(-time.Minute)
+		}
+		if err := store.Create(ctx, sess); err != nil {
+			t.Fatalf("Create() = %v, want nil", err)
+		}
+	}
+
+	pending, err := store.Archive(ctx, ArchiveFilter{}, true)
+	if err != nil || len(pending) != total {
+		t.Fatalf("Archive(dry run) = %d sessions, %v, want %d", len(pending), err, total)
+	}
+	if pending[0].IssueNumber != total {
+		t.Errorf("first session = issue %d, want the oldest, issue %d", pending[0].IssueNumber, total)
+	}
+	seen := map[string]bool{}
+	for _, sess := range pending {
+		if seen[sess.ID] {
+			t.Fatalf("Archive(dry run) returned %s twice", sess.ID)
+		}
+		seen[sess.ID] = true
+	}
+
+	archived, err := store.Archive(ctx, ArchiveFilter{}, false)
+	if err != nil || len(archived) != total {
+		t.Fatalf("Archive() = %d sessions, %v, want %d", len(archived), err, total)
+	}
+
+	// An offset without a limit skips the most recently archived sessions
+	list, err := store.ListArchived(ctx, SessionFilter{Offset: 10})
+	if err != nil || len(list) != total-10 {
+		t.Fatalf("ListArchived(offset 10) = %d sessions, %v, want %d", len(list), err, total-10)
+	}
+	if list[len(list)-1].Session.IssueNumber != total {
+		t.Errorf("last listed = issue %d, want the first archived, issue %d", list[len(list)-1].Session.IssueNumber, total)
+	}
+}
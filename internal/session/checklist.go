@@ -0,0 +1,114 @@
+package session
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ChecklistFence is the info string of the fenced JSON block the planning
+// prompt asks Claude to end its plan with.
+const ChecklistFence = "manfred-checklist"
+
+// maxChecklistFiles caps the files kept from a checklist, so a plan listing
+// the whole tree does not flood the comment.
+const maxChecklistFiles = 25
+
+// checklistBlock matches a manfred-checklist fenced block.
+var checklistBlock = regexp.MustCompile("(?s)```" + ChecklistFence + "[ \t]*\n(.*?)\n?```")
+
+// PlanChecklist is Claude's structured assessment of a plan. It fills in the
+// approval checklist on the plan comment and is kept on the session for
+// analytics. The zero value means no assessment was given.
+type PlanChecklist struct {
+	Risk      string   `json:"risk,omitempty"`      // low, medium or high
+	Size      string   `json:"size,omitempty"`      // small, medium or large
+	Files     []string `json:"files,omitempty"`     // Files likely touched
+	Migration *bool    `json:"migration,omitempty"` // Whether a migration is required; nil if not assessed
+}
+
+// IsZero reports whether the checklist holds no assessment.
+func (c PlanChecklist) IsZero() bool {
+	return c.Risk == "" && c.Size == "" && len(c.Files) == 0 && c.Migration == nil
+}
+
+// Value stores the checklist as JSON, or NULL when it is empty.
+func (c PlanChecklist) Value() (driver.Value, error) {
+	if c.IsZero() {
+		return nil, nil
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("encode plan checklist: %w", err)
+	}
+	return string(data), nil
+}
+
+// Scan reads a checklist stored by Value.
+func (c *PlanChecklist) Scan(src any) error {
+	*c = PlanChecklist{}
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("scan plan checklist: unsupported type %T", src)
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("decode plan checklist: %w", err)
+	}
+	return nil
+}
+
+// ParsePlanChecklist splits the last manfred-checklist block off a plan. It
+// returns the plan without any checklist blocks and the parsed checklist.
+// Unknown risk or size values and a block that is not valid JSON are dropped,
+// leaving those fields unassessed rather than failing the plan.
+func ParsePlanChecklist(plan string) (string, PlanChecklist) {
+	matches := checklistBlock.FindAllStringSubmatch(plan, -1)
+	if len(matches) == 0 {
+		return plan, PlanChecklist{}
+	}
+	stripped := strings.TrimSpace(checklistBlock.ReplaceAllString(plan, ""))
+
+	var raw PlanChecklist
+	if err := json.Unmarshal([]byte(matches[len(matches)-1][1]), &raw); err != nil {
+		return stripped, PlanChecklist{}
+	}
+
+	c := PlanChecklist{
+		Risk:      oneOf(raw.Risk, "low", "medium", "high"),
+		Size:      oneOf(raw.Size, "small", "medium", "large"),
+		Migration: raw.Migration,
+	}
+	seen := map[string]bool{}
+	for _, f := range raw.Files {
+		f = strings.TrimSpace(f)
+		if f == "" || seen[f] {
+			continue
+		}
+		seen[f] = true
+		if len(c.Files) == maxChecklistFiles {
+			break
+		}
+		c.Files = append(c.Files, f)
+	}
+	return stripped, c
+}
+
+// oneOf returns v lower-cased if it is one of allowed, and "" otherwise.
+func oneOf(v string, allowed ...string) string {
+	v = strings.ToLower(strings.TrimSpace(v))
+	for _, a := range allowed {
+		if v == a {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package session
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePlanChecklist(t *testing.T) {
+	yes := true
+	tests := []struct {
+		name     string
+		plan     string
+		wantPlan string
+		want     PlanChecklist
+	}{
+		{
+			name:     "no block",
+			plan:     "1. Do this",
+			wantPlan: "1. Do this",
+		},
+		{
+			name: "block",
+			plan: "1. Do this\n\n```manfred-checklist\n" +
+				`{"risk": "High", "size": "small", "files": ["a.go", "b.go", "a.go", " "], "migration": true}` +
+				"\n```\n",
+			wantPlan: "1. Do this",
+			want: PlanChecklist{
+				Risk:      "high",
+				Size:      "small",
+				Files:     []string{"a.go", "b.go"},
+				Migration: &yes,
+			},
+		},
+		{
+			name:     "unknown values",
+			plan:     "1. Do this\n```manfred-checklist\n{\"risk\": \"extreme\", \"size\": \"medium\"}\n```",
+			wantPlan: "1. Do this",
+			want:     PlanChecklist{Size: "medium"},
+		},
+		{
+			name:     "invalid json",
+			plan:     "1. Do this\n```manfred-checklist\n{risk: low}\n```",
+			wantPlan: "1. Do this",
+		},
+		{
+			name: "last block wins",
+			plan: "```manfred-checklist\n{\"risk\": \"low\"}\n```\n1. Do this\n" +
+				"```manfred-checklist\n{\"risk\": \"medium\"}\n```",
+			wantPlan: "1. Do this",
+			want:     PlanChecklist{Risk: "medium"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, got := ParsePlanChecklist(tt.plan)
+			if plan != tt.wantPlan {
+				t.Errorf("plan = %q, want %q", plan, tt.wantPlan)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checklist = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanChecklistValueScan(t *testing.T) {
+	no := false
+	c := PlanChecklist{Risk: "low", Files: []string{"a.go"}, Migration: &no}
+
+	v, err := c.Value()
+	if err != nil {
+		t.Fatalf("Value() error = %v", err)
+	}
+	var got PlanChecklist
+	if err := got.Scan(v); err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, c) {
+		t.Errorf("round trip = %+v, want %+v", got, c)
+	}
+
+	if v, _ := (PlanChecklist{}).Value(); v != nil {
+		t.Errorf("zero Value() = %v, want nil", v)
+	}
+	if err := got.Scan(nil); err != nil || !got.IsZero() {
+		t.Errorf("Scan(nil) = %+v, %v, want zero checklist", got, err)
+	}
+}
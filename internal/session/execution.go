@@ -0,0 +1,74 @@
+package session
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Step is how far a session's job phase (planning, implementing, revising)
+// has got.
+type Step string
+
+const (
+	// StepQueued waits for the Anthropic API or a free job slot
+	StepQueued Step = "queued"
+
+	// StepJob runs the job in its compose project
+	StepJob Step = "job"
+
+	// StepFinishing has the job's result and is publishing it: posting
+	// the plan, opening the pull request or replying to the review
+	StepFinishing Step = "finishing"
+)
+
+// Execution records the state of a session's running phase, so a serve
+// daemon restarted mid-phase can tell which process ran it, clean up its
+// job and resume. It is cleared when the session changes phase; the zero
+// value means nothing runs.
+type Execution struct {
+	Step           Step      `json:"step"`
+	JobID          string    `json:"job_id,omitempty"`
+	ComposeProject string    `json:"compose_project,omitempty"`
+	Host           string    `json:"host,omitempty"` // Hostname of the process running the phase
+	PID            int       `json:"pid,omitempty"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// IsZero reports whether no execution is recorded.
+func (e Execution) IsZero() bool {
+	return e.Step == ""
+}
+
+// Value stores the execution as JSON, or NULL when it is empty.
+func (e Execution) Value() (driver.Value, error) {
+	if e.IsZero() {
+		return nil, nil
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("encode execution: %w", err)
+	}
+	return string(data), nil
+}
+
+// Scan reads an execution stored by Value.
+func (e *Execution) Scan(src any) error {
+	*e = Execution{}
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("scan execution: unsupported type %T", src)
+	}
+	if err := json.Unmarshal(data, e); err != nil {
+		return fmt.Errorf("decode execution: %w", err)
+	}
+	return nil
+}
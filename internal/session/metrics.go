@@ -0,0 +1,126 @@
+package session
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Metrics measures the human side of a session: how often a PR went back for
+// revisions and how long the session waited on people. Durations only cover
+// phases the session has already left.
+type Metrics struct {
+	// RevisionRounds counts how often the session entered the revising phase
+	RevisionRounds int
+
+	// PlanRevisions counts how often a plan was sent back for revision
+	PlanRevisions int
+
+	// AwaitingApprovalSeconds is the time spent waiting for plan approval
+	AwaitingApprovalSeconds int64
+
+	// InReviewSeconds is the time the pull request spent in review
+	InReviewSeconds int64
+}
+
+// AwaitingApproval returns the time spent waiting for plan approval.
+func (m Metrics) AwaitingApproval() time.Duration {
+	return time.Duration(m.AwaitingApprovalSeconds) * time.Second
+}
+
+// InReview returns the time the pull request spent in review.
+func (m Metrics) InReview() time.Duration {
+	return time.Duration(m.InReviewSeconds) * time.Second
+}
+
+// ComputeMetrics replays a session's phase change and error events, in the
+// order they were recorded.
+func ComputeMetrics(events []SessionEvent) Metrics {
+	var m Metrics
+	var current Phase
+	var since time.Time
+
+	for _, event := range events {
+		var payload struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		}
+		switch event.EventType {
+		case EventTypePhaseChange:
+			if err := json.Unmarshal([]byte(event.Payload), &payload); err != nil || payload.To == "" {
+				continue
+			}
+		case EventTypeError:
+			payload.To = string(PhaseError)
+		default:
+			continue
+		}
+
+		if current == "" {
+			current = Phase(payload.From)
+			since = event.CreatedAt
+		}
+		elapsed := int64(event.CreatedAt.Sub(since) / time.Second)
+		switch current {
+		case PhaseAwaitingApproval:
+			m.AwaitingApprovalSeconds += elapsed
+		case PhaseInReview:
+			m.InReviewSeconds += elapsed
+		}
+
+		previous := current
+		current = Phase(payload.To)
+		since = event.CreatedAt
+		switch {
+		case current == PhaseRevising:
+			m.RevisionRounds++
+		case current == PhasePlanning && previous == PhaseAwaitingApproval:
+			m.PlanRevisions++
+		}
+	}
+
+	return m
+}
+
+// MetricsSummary aggregates Metrics over a set of sessions.
+type MetricsSummary struct {
+	Sessions int     // Sessions included
+	Revised  int     // Sessions with at least one revision round
+	Totals   Metrics // Sums over all included sessions
+
+	// PlanRisk counts sessions per risk level assessed in their plan
+	// checklist, e.g. "high"; sessions without one are left out
+	PlanRisk map[string]int
+
+	// Migrations counts sessions whose plan checklist requires a migration
+	Migrations int
+
+	// Resources summarizes the peak container usage of the sessions' jobs
+	Resources ResourceSummary
+}
+
+// ResourceSummary aggregates the peak container usage that monitored jobs
+// record with their container_stop event.
+type ResourceSummary struct {
+	Jobs           int     // Monitored jobs included
+	MaxMemoryBytes int64   // Highest peak memory usage
+	AvgMemoryBytes int64   // Mean peak memory usage
+	MaxCPUPercent  float64 // Highest peak CPU usage, in percent of one CPU
+}
+
+// average divides a total over the summary's sessions.
+func (s *MetricsSummary) average(total time.Duration) time.Duration {
+	if s.Sessions == 0 {
+		return 0
+	}
+	return (total / time.Duration(s.Sessions)).Round(time.Second)
+}
+
+// AvgAwaitingApproval returns the mean time a session waited for approval.
+func (s *MetricsSummary) AvgAwaitingApproval() time.Duration {
+	return s.average(s.Totals.AwaitingApproval())
+}
+
+// AvgInReview returns the mean time a session spent in review.
+func (s *MetricsSummary) AvgInReview() time.Duration {
+	return s.average(s.Totals.InReview())
+}
@@ -0,0 +1,99 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeMetrics(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	change := func(minutes int, from, to Phase) SessionEvent {
+		payload := `{"from":"` + string(from) + `","to":"` + string(to) + `"}`
+		if from == "" {
+			payload = `{"to":"` + string(to) + `"}`
+		}
+		return SessionEvent{EventType: EventTypePhaseChange, Payload: payload, CreatedAt: at(minutes)}
+	}
+
+	tests := []struct {
+		name   string
+		events []SessionEvent
+		want   Metrics
+	}{
+		{
+			name: "no events",
+			want: Metrics{},
+		},
+		{
+			name: "full loop with two revisions",
+			events: []SessionEvent{
+				change(0, "", PhasePlanning),
+				change(5, PhasePlanning, PhaseAwaitingApproval),
+				{EventType: EventTypeCommentPosted, Payload: `{}`, CreatedAt: at(6)},
+				change(65, PhaseAwaitingApproval, PhaseImplementing),
+				change(80, PhaseImplementing, PhaseInReview),
+				change(110, PhaseInReview, PhaseRevising),
+				change(120, PhaseRevising, PhaseInReview),
+				change(140, PhaseInReview, PhaseRevising),
+				change(150, PhaseRevising, PhaseInReview),
+				change(200, PhaseInReview, PhaseCompleted),
+			},
+			want: Metrics{
+				RevisionRounds:          2,
+				AwaitingApprovalSeconds: 60 * 60,
+				InReviewSeconds:         (30 + 20 + 50) * 60,
+			},
+		},
+		{
+			name: "error while awaiting approval",
+			events: []SessionEvent{
+				change(0, PhasePlanning, PhaseAwaitingApproval),
+				{EventType: EventTypeError, Payload: `{"phase":"awaiting_approval"}`, CreatedAt: at(10)},
+				change(20, PhaseError, PhasePlanning),
+			},
+			want: Metrics{AwaitingApprovalSeconds: 10 * 60},
+		},
+		{
+			name: "plan sent back for revision",
+			events: []SessionEvent{
+				change(0, PhasePlanning, PhaseAwaitingApproval),
+				change(30, PhaseAwaitingApproval, PhasePlanning),
+				change(35, PhasePlanning, PhaseAwaitingApproval),
+				change(45, PhaseAwaitingApproval, PhaseImplementing),
+			},
+			want: Metrics{PlanRevisions: 1, AwaitingApprovalSeconds: 40 * 60},
+		},
+		{
+			name: "current phase is not counted",
+			events: []SessionEvent{
+				change(0, PhaseImplementing, PhaseInReview),
+			},
+			want: Metrics{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ComputeMetrics(tt.events); got != tt.want {
+				t.Errorf("ComputeMetrics() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricsSummaryAverages(t *testing.T) {
+	summary := &MetricsSummary{
+		Sessions: 4,
+		Totals:   Metrics{AwaitingApprovalSeconds: 3600, InReviewSeconds: 90},
+	}
+	if got, want := summary.AvgAwaitingApproval(), 15*time.Minute; got != want {
+		t.Errorf("AvgAwaitingApproval() = %v, want %v", got, want)
+	}
+	if got, want := summary.AvgInReview(), 23*time.Second; got != want {
+		t.Errorf("AvgInReview() = %v, want %v", got, want)
+	}
+	if got := (&MetricsSummary{}).AvgInReview(); got != 0 {
+		t.Errorf("AvgInReview() with no sessions = %v, want 0", got)
+	}
+}
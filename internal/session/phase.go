@@ -13,6 +13,10 @@ const (
 	// PhasePlanning is the initial phase where Claude creates an implementation plan.
 	PhasePlanning Phase = "planning"
 
+	// PhaseAwaitingAnswers is when Claude asked clarifying questions before
+	// writing the plan, and waits for a reply on the issue.
+	PhaseAwaitingAnswers Phase = "awaiting_answers"
+
 	// PhaseAwaitingApproval is when the plan has been posted and awaits user approval.
 	PhaseAwaitingApproval Phase = "awaiting_approval"
 
@@ -25,23 +29,43 @@ const (
 	// PhaseRevising is when Claude is addressing PR feedback.
 	PhaseRevising Phase = "revising"
 
+	// PhasePaused is when automation is stopped, e.g. after someone pushed
+	// to the session branch, until it is resumed.
+	PhasePaused Phase = "paused"
+
 	// PhaseCompleted is the terminal state after PR is merged.
 	PhaseCompleted Phase = "completed"
 
 	// PhaseError indicates the session encountered an unrecoverable error.
 	PhaseError Phase = "error"
+
+	// PhaseAborted is the terminal state after someone aborted the session.
+	PhaseAborted Phase = "aborted"
+
+	// PhaseTriaging is when Claude triages the issue of a triage-only
+	// session, without planning or writing code.
+	PhaseTriaging Phase = "triaging"
+
+	// PhaseTriaged ends a triage-only session once the triage is posted. A
+	// trigger label or plan request starts planning from it.
+	PhaseTriaged Phase = "triaged"
 )
 
 // AllPhases returns all valid phases.
 func AllPhases() []Phase {
 	return []Phase{
 		PhasePlanning,
+		PhaseAwaitingAnswers,
 		PhaseAwaitingApproval,
 		PhaseImplementing,
 		PhaseInReview,
 		PhaseRevising,
+		PhasePaused,
 		PhaseCompleted,
 		PhaseError,
+		PhaseAborted,
+		PhaseTriaging,
+		PhaseTriaged,
 	}
 }
 
@@ -49,27 +73,32 @@ func AllPhases() []Phase {
 func ActivePhases() []Phase {
 	return []Phase{
 		PhasePlanning,
+		PhaseAwaitingAnswers,
 		PhaseAwaitingApproval,
 		PhaseImplementing,
 		PhaseInReview,
 		PhaseRevising,
+		PhasePaused,
+		PhaseTriaging,
 	}
 }
 
 // IsValid returns true if the phase is a recognized value.
 func (p Phase) IsValid() bool {
 	switch p {
-	case PhasePlanning, PhaseAwaitingApproval, PhaseImplementing,
-		PhaseInReview, PhaseRevising, PhaseCompleted, PhaseError:
+	case PhasePlanning, PhaseAwaitingAnswers, PhaseAwaitingApproval, PhaseImplementing,
+		PhaseInReview, PhaseRevising, PhasePaused, PhaseCompleted, PhaseError, PhaseAborted,
+		PhaseTriaging, PhaseTriaged:
 		return true
 	default:
 		return false
 	}
 }
 
-// IsTerminal returns true if the phase is a terminal state (completed or error).
+// IsTerminal returns true if the phase is a terminal state (completed, error,
+// aborted or triaged).
 func (p Phase) IsTerminal() bool {
-	return p == PhaseCompleted || p == PhaseError
+	return p == PhaseCompleted || p == PhaseError || p == PhaseAborted || p == PhaseTriaged
 }
 
 // IsActive returns true if the phase represents active work.
@@ -87,6 +116,8 @@ func (p Phase) DisplayName() string {
 	switch p {
 	case PhasePlanning:
 		return "Planning"
+	case PhaseAwaitingAnswers:
+		return "Awaiting Answers"
 	case PhaseAwaitingApproval:
 		return "Awaiting Approval"
 	case PhaseImplementing:
@@ -95,10 +126,18 @@ func (p Phase) DisplayName() string {
 		return "In Review"
 	case PhaseRevising:
 		return "Revising"
+	case PhasePaused:
+		return "Paused"
 	case PhaseCompleted:
 		return "Completed"
 	case PhaseError:
 		return "Error"
+	case PhaseAborted:
+		return "Aborted"
+	case PhaseTriaging:
+		return "Triaging"
+	case PhaseTriaged:
+		return "Triaged"
 	default:
 		return string(p)
 	}
@@ -116,13 +155,18 @@ func ParsePhase(s string) (Phase, error) {
 // validTransitions defines the allowed state transitions.
 // Key is the current phase, value is the list of phases it can transition to.
 var validTransitions = map[Phase][]Phase{
-	PhasePlanning:         {PhaseAwaitingApproval, PhaseError},
-	PhaseAwaitingApproval: {PhasePlanning, PhaseImplementing, PhaseError},
-	PhaseImplementing:     {PhaseInReview, PhaseError},
-	PhaseInReview:         {PhaseRevising, PhaseCompleted, PhaseError},
-	PhaseRevising:         {PhaseInReview, PhaseError},
+	PhasePlanning:         {PhaseAwaitingAnswers, PhaseAwaitingApproval, PhaseError, PhaseAborted},
+	PhaseAwaitingAnswers:  {PhasePlanning, PhaseError, PhaseAborted},
+	PhaseAwaitingApproval: {PhasePlanning, PhaseImplementing, PhasePaused, PhaseError, PhaseAborted},
+	PhaseImplementing:     {PhaseInReview, PhaseError, PhaseAborted},
+	PhaseInReview:         {PhaseRevising, PhasePaused, PhaseCompleted, PhaseError, PhaseAborted},
+	PhaseRevising:         {PhaseInReview, PhaseCompleted, PhaseError, PhaseAborted},
+	PhasePaused:           {PhaseAwaitingApproval, PhaseInReview, PhaseCompleted, PhaseError, PhaseAborted},
 	PhaseCompleted:        {}, // Terminal - no transitions
-	PhaseError:            {PhasePlanning}, // Can retry from error
+	PhaseError:            {PhasePlanning, PhaseTriaging, PhaseAborted}, // Can retry from error, or give up
+	PhaseAborted:          {}, // Terminal - no transitions
+	PhaseTriaging:         {PhaseTriaged, PhaseError, PhaseAborted},
+	PhaseTriaged:          {PhasePlanning, PhaseTriaging}, // A session can start from a triage, or triage again
 }
 
 // CanTransitionTo returns true if a transition from the current phase to the target is valid.
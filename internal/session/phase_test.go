@@ -14,8 +14,12 @@ func TestPhaseIsValid(t *testing.T) {
 		{PhaseImplementing, true},
 		{PhaseInReview, true},
 		{PhaseRevising, true},
+		{PhasePaused, true},
 		{PhaseCompleted, true},
 		{PhaseError, true},
+		{PhaseAborted, true},
+		{PhaseTriaging, true},
+		{PhaseTriaged, true},
 		{Phase("invalid"), false},
 		{Phase(""), false},
 	}
@@ -39,8 +43,12 @@ func TestPhaseIsTerminal(t *testing.T) {
 		{PhaseImplementing, false},
 		{PhaseInReview, false},
 		{PhaseRevising, false},
+		{PhasePaused, false},
 		{PhaseCompleted, true},
 		{PhaseError, true},
+		{PhaseAborted, true},
+		{PhaseTriaging, false},
+		{PhaseTriaged, true},
 	}
 
 	for _, tt := range tests {
@@ -63,6 +71,13 @@ func TestPhaseCanTransitionTo(t *testing.T) {
 		{PhasePlanning, PhaseError, true},
 		{PhasePlanning, PhaseImplementing, false},
 		{PhasePlanning, PhaseCompleted, false},
+		{PhasePlanning, PhaseAwaitingAnswers, true},
+
+		// From Awaiting Answers
+		{PhaseAwaitingAnswers, PhasePlanning, true},
+		{PhaseAwaitingAnswers, PhaseAborted, true},
+		{PhaseAwaitingAnswers, PhaseAwaitingApproval, false},
+		{PhaseAwaitingAnswers, PhaseImplementing, false},
 
 		// From Awaiting Approval
 		{PhaseAwaitingApproval, PhasePlanning, true},
@@ -85,7 +100,7 @@ func TestPhaseCanTransitionTo(t *testing.T) {
 		// From Revising
 		{PhaseRevising, PhaseInReview, true},
 		{PhaseRevising, PhaseError, true},
-		{PhaseRevising, PhaseCompleted, false},
+		{PhaseRevising, PhaseCompleted, true}, // Merged mid-revision
 
 		// From Completed (terminal)
 		{PhaseCompleted, PhasePlanning, false},
@@ -94,6 +109,37 @@ func TestPhaseCanTransitionTo(t *testing.T) {
 		// From Error (can retry)
 		{PhaseError, PhasePlanning, true},
 		{PhaseError, PhaseImplementing, false},
+
+		// Pausing and resuming
+		{PhaseAwaitingApproval, PhasePaused, true},
+		{PhaseInReview, PhasePaused, true},
+		{PhasePlanning, PhasePaused, false},
+		{PhaseImplementing, PhasePaused, false},
+		{PhaseRevising, PhasePaused, false},
+		{PhasePaused, PhaseAwaitingApproval, true},
+		{PhasePaused, PhaseInReview, true},
+		{PhasePaused, PhaseCompleted, true},
+		{PhasePaused, PhaseRevising, false},
+		{PhasePaused, PhaseImplementing, false},
+
+		// Aborting
+		{PhasePaused, PhaseAborted, true},
+		{PhasePlanning, PhaseAborted, true},
+		{PhaseImplementing, PhaseAborted, true},
+		{PhaseInReview, PhaseAborted, true},
+		{PhaseError, PhaseAborted, true},
+		{PhaseCompleted, PhaseAborted, false},
+		{PhaseAborted, PhasePlanning, false},
+		{PhaseAborted, PhaseError, false},
+
+		// Triage
+		{PhaseTriaging, PhaseTriaged, true},
+		{PhaseTriaging, PhasePlanning, false},
+		{PhaseTriaging, PhaseAborted, true},
+		{PhaseTriaged, PhasePlanning, true},
+		{PhaseTriaged, PhaseTriaging, true},
+		{PhaseTriaged, PhaseImplementing, false},
+		{PhaseError, PhaseTriaging, true},
 	}
 
 	for _, tt := range tests {
@@ -117,12 +163,17 @@ func TestParsePhase(t *testing.T) {
 		{"planning", PhasePlanning, false},
 		{"PLANNING", PhasePlanning, false},
 		{"  planning  ", PhasePlanning, false},
+		{"awaiting_answers", PhaseAwaitingAnswers, false},
 		{"awaiting_approval", PhaseAwaitingApproval, false},
 		{"implementing", PhaseImplementing, false},
 		{"in_review", PhaseInReview, false},
 		{"revising", PhaseRevising, false},
+		{"paused", PhasePaused, false},
 		{"completed", PhaseCompleted, false},
 		{"error", PhaseError, false},
+		{"aborted", PhaseAborted, false},
+		{"triaging", PhaseTriaging, false},
+		{"triaged", PhaseTriaged, false},
 		{"invalid", Phase(""), true},
 		{"", Phase(""), true},
 	}
@@ -176,8 +227,10 @@ func TestPhaseDisplayName(t *testing.T) {
 		{PhaseImplementing, "Implementing"},
 		{PhaseInReview, "In Review"},
 		{PhaseRevising, "Revising"},
+		{PhasePaused, "Paused"},
 		{PhaseCompleted, "Completed"},
 		{PhaseError, "Error"},
+		{PhaseAborted, "Aborted"},
 	}
 
 	for _, tt := range tests {
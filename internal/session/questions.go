@@ -0,0 +1,25 @@
+package session
+
+import (
+	"regexp"
+	"strings"
+)
+
+// QuestionsFence is the info string of the fenced block the planning prompt
+// asks Claude to reply with instead of a plan when it needs clarification.
+const QuestionsFence = "manfred-questions"
+
+// questionsBlock matches a manfred-questions fenced block.
+var questionsBlock = regexp.MustCompile("(?s)```" + QuestionsFence + "[ \t]*\n(.*?)\n?```")
+
+// ParseQuestions returns the clarifying questions of a planning reply and
+// whether it has any. Text around the block is ignored: a reply with
+// questions is not a plan.
+func ParseQuestions(reply string) (string, bool) {
+	matches := questionsBlock.FindStringSubmatch(reply)
+	if matches == nil {
+		return "", false
+	}
+	questions := strings.TrimSpace(matches[1])
+	return questions, questions != ""
+}
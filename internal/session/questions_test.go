@@ -0,0 +1,35 @@
+package session
+
+import "testing"
+
+func TestParseQuestions(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  string
+		ok    bool
+	}{
+		{
+			name:  "plan",
+			reply: "1. Do this\n\n```manfred-checklist\n{\"risk\": \"low\"}\n```",
+		},
+		{
+			name:  "questions",
+			reply: "I need to know more.\n\n```manfred-questions\n1. Which OAuth provider?\n2. Keep basic auth?\n```\n",
+			want:  "1. Which OAuth provider?\n2. Keep basic auth?",
+			ok:    true,
+		},
+		{
+			name:  "empty block",
+			reply: "```manfred-questions\n\n```",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseQuestions(tt.reply)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("ParseQuestions() = %q, %v; want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
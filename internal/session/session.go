@@ -39,6 +39,20 @@ type Session struct {
 	// ErrorMessage stores the error message if phase is Error
 	ErrorMessage *string
 
+	// HeadSHA is the last commit MANFRED pushed to Branch
+	HeadSHA *string
+
+	// PlanChecklist is Claude's structured assessment of the current plan
+	PlanChecklist PlanChecklist
+
+	// Execution is the state of the running phase's job, cleared when the
+	// phase changes
+	Execution Execution
+
+	// Metrics tracks revision rounds and time spent waiting on people. It is
+	// computed from the session's events by the store.
+	Metrics Metrics
+
 	// CreatedAt is when the session was created
 	CreatedAt time.Time
 
@@ -83,6 +97,7 @@ func (s *Session) TransitionTo(target Phase) error {
 		return err
 	}
 	s.Phase = target
+	s.Execution = Execution{}
 	s.LastActivity = time.Now().UTC()
 	return nil
 }
@@ -101,6 +116,11 @@ func (s *Session) Approve() error {
 	return s.TransitionTo(PhaseImplementing)
 }
 
+// Abort transitions the session to the aborted terminal phase.
+func (s *Session) Abort() error {
+	return s.TransitionTo(PhaseAborted)
+}
+
 // SetPRNumber sets the PR number after PR creation.
 func (s *Session) SetPRNumber(prNumber int) {
 	s.PRNumber = &prNumber
@@ -109,9 +129,11 @@ func (s *Session) SetPRNumber(prNumber int) {
 
 // SetError transitions the session to error state with a message.
 func (s *Session) SetError(msg string) error {
-	if err := s.TransitionTo(PhaseError); err != nil {
-		// Force transition to error even if not normally allowed
+	if err := s.TransitionTo(PhaseError); err != nil && s.Phase != PhaseAborted {
+		// Force transition to error even if not normally allowed, except
+		// out of aborted, which is final
 		s.Phase = PhaseError
+		s.Execution = Execution{}
 	}
 	s.ErrorMessage = &msg
 	s.LastActivity = time.Now().UTC()
@@ -130,6 +152,31 @@ func (s *Session) ClearContainerID() {
 	s.LastActivity = time.Now().UTC()
 }
 
+// SetHeadSHA records the last commit MANFRED pushed to the session branch.
+func (s *Session) SetHeadSHA(sha string) {
+	s.HeadSHA = &sha
+	s.LastActivity = time.Now().UTC()
+}
+
+// Pause stops automated changes to the session branch by moving to the
+// paused phase.
+func (s *Session) Pause() error {
+	return s.TransitionTo(PhasePaused)
+}
+
+// Resume returns a paused session to phase, the one it was paused in,
+// accepting sha as the new branch head.
+func (s *Session) Resume(phase Phase, sha string) error {
+	if s.Phase != PhasePaused {
+		return &TransitionError{From: s.Phase, To: phase}
+	}
+	if err := s.TransitionTo(phase); err != nil {
+		return err
+	}
+	s.HeadSHA = &sha
+	return nil
+}
+
 // Touch updates the last activity timestamp.
 func (s *Session) Touch() {
 	s.LastActivity = time.Now().UTC()
@@ -162,13 +209,21 @@ func (s *Session) Validate() error {
 type EventType string
 
 const (
-	EventTypePhaseChange   EventType = "phase_change"
-	EventTypeCommentPosted EventType = "comment_posted"
-	EventTypeCommentReceived EventType = "comment_received"
-	EventTypePRCreated     EventType = "pr_created"
-	EventTypeError         EventType = "error"
-	EventTypeContainerStart EventType = "container_start"
-	EventTypeContainerStop EventType = "container_stop"
+	EventTypePhaseChange      EventType = "phase_change"
+	EventTypeCommentPosted    EventType = "comment_posted"
+	EventTypeCommentReceived  EventType = "comment_received"
+	EventTypePRCreated        EventType = "pr_created"
+	EventTypeError            EventType = "error"
+	EventTypeContainerStart   EventType = "container_start"
+	EventTypeContainerStop    EventType = "container_stop"
+	EventTypeManualPush       EventType = "manual_push"
+	EventTypeJobDiff          EventType = "job_diff"
+	EventTypeResumed          EventType = "resumed"
+	EventTypePlanEdited       EventType = "plan_edited"
+	EventTypeApprovalReminder EventType = "approval_reminder"
+	EventTypePRReady          EventType = "pr_ready"
+	EventTypeCIGaveUp         EventType = "ci_gave_up"
+	EventTypeAutoMerged       EventType = "auto_merged"
 )
 
 // SessionEvent represents an event in the session's history.
@@ -200,3 +255,21 @@ type SessionFilter struct {
 	// Offset skips the first N results
 	Offset int
 }
+
+// EventFilter defines criteria for filtering a session's events.
+type EventFilter struct {
+	// Types filters by event type; empty means all types
+	Types []EventType
+
+	// Since returns only events at or after this time
+	Since time.Time
+
+	// Until returns only events before this time
+	Until time.Time
+
+	// Limit limits the number of results
+	Limit int
+
+	// Offset skips the first N results
+	Offset int
+}
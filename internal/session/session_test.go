@@ -188,6 +188,36 @@ func TestSessionSetContainerID(t *testing.T) {
 	}
 }
 
+func TestSessionPauseResume(t *testing.T) {
+	sess := NewSession("owner", "repo", 1)
+	sess.SetHeadSHA("aaa")
+
+	if err := sess.Pause(); err == nil {
+		t.Error("Pause() while planning = nil, want error")
+	}
+
+	sess.Phase = PhaseInReview
+	if err := sess.Pause(); err != nil {
+		t.Fatalf("Pause() = %v, want nil", err)
+	}
+	if sess.Phase != PhasePaused {
+		t.Errorf("Phase = %q after Pause(), want %q", sess.Phase, PhasePaused)
+	}
+
+	if err := sess.Resume(PhaseRevising, "bbb"); err == nil {
+		t.Error("Resume(revising) = nil, want error")
+	}
+	if err := sess.Resume(PhaseInReview, "bbb"); err != nil {
+		t.Fatalf("Resume() = %v, want nil", err)
+	}
+	if sess.Phase != PhaseInReview {
+		t.Errorf("Phase = %q after Resume(), want %q", sess.Phase, PhaseInReview)
+	}
+	if sess.HeadSHA == nil || *sess.HeadSHA != "bbb" {
+		t.Errorf("HeadSHA = %v, want %q", sess.HeadSHA, "bbb")
+	}
+}
+
 func TestSessionTouch(t *testing.T) {
 	sess := NewSession("owner", "repo", 1)
 	originalTime := sess.LastActivity
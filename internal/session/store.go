@@ -22,9 +22,19 @@ type Store interface {
 	// GetByIssue retrieves a session by repository and issue number.
 	GetByIssue(ctx context.Context, owner, repo string, issueNumber int) (*Session, error)
 
+	// GetByPR retrieves a session by repository and pull request number.
+	GetByPR(ctx context.Context, owner, repo string, prNumber int) (*Session, error)
+
+	// GetByBranch retrieves a session by repository and branch name.
+	GetByBranch(ctx context.Context, owner, repo, branch string) (*Session, error)
+
 	// Update updates an existing session.
 	Update(ctx context.Context, s *Session) error
 
+	// SetExecution records the state of a session's running phase without
+	// touching the rest of the session.
+	SetExecution(ctx context.Context, id string, exec Execution) error
+
 	// Delete deletes a session by ID.
 	Delete(ctx context.Context, id string) error
 
@@ -34,25 +44,44 @@ type Store interface {
 	// RecordEvent records an event in the session's history.
 	RecordEvent(ctx context.Context, sessionID string, eventType EventType, payload interface{}) error
 
-	// GetEvents retrieves events for a session.
-	GetEvents(ctx context.Context, sessionID string) ([]SessionEvent, error)
+	// GetEvents retrieves the events of a session matching the filter,
+	// oldest first.
+	GetEvents(ctx context.Context, sessionID string, filter EventFilter) ([]SessionEvent, error)
+
+	// GetJobEvent retrieves the latest event of a type recorded for a job.
+	GetJobEvent(ctx context.Context, eventType EventType, jobID string) (*SessionEvent, error)
+
+	// JobIDs returns the IDs of jobs recorded for sessions matching the filter.
+	JobIDs(ctx context.Context, filter SessionFilter) ([]string, error)
 
 	// Count returns the number of sessions matching the filter.
 	Count(ctx context.Context, filter SessionFilter) (int, error)
+
+	// RefreshMetrics recomputes a session's metrics from its events.
+	RefreshMetrics(ctx context.Context, sessionID string) (*Metrics, error)
+
+	// MetricsSummary aggregates the metrics of sessions matching the filter.
+	MetricsSummary(ctx context.Context, filter SessionFilter) (*MetricsSummary, error)
+
+	// Archive moves terminal sessions and their events to the archive.
+	Archive(ctx context.Context, filter ArchiveFilter, dryRun bool) ([]Session, error)
+
+	// ListArchived returns archived sessions matching the filter.
+	ListArchived(ctx context.Context, filter SessionFilter) ([]ArchivedSession, error)
 }
 
-// SQLiteStore implements Store using SQLite.
-type SQLiteStore struct {
+// SQLStore implements Store on the SQLite or Postgres database db.
+type SQLStore struct {
 	db *store.DB
 }
 
-// NewSQLiteStore creates a new SQLite-backed session store.
-func NewSQLiteStore(db *store.DB) *SQLiteStore {
-	return &SQLiteStore{db: db}
+// NewSQLStore creates a new database-backed session store.
+func NewSQLStore(db *store.DB) *SQLStore {
+	return &SQLStore{db: db}
 }
 
 // Create creates a new session.
-func (s *SQLiteStore) Create(ctx context.Context, sess *Session) error {
+func (s *SQLStore) Create(ctx context.Context, sess *Session) error {
 	if err := sess.Validate(); err != nil {
 		return fmt.Errorf("invalid session: %w", err)
 	}
@@ -61,8 +90,8 @@ func (s *SQLiteStore) Create(ctx context.Context, sess *Session) error {
 		INSERT INTO sessions (
 			id, repo_owner, repo_name, issue_number, pr_number,
 			phase, branch, container_id, plan_content, error_message,
-			created_at, last_activity
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			created_at, last_activity, head_sha, plan_checklist, execution
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	_, err := s.db.ExecContext(ctx, query,
@@ -78,9 +107,12 @@ func (s *SQLiteStore) Create(ctx context.Context, sess *Session) error {
 		sess.ErrorMessage,
 		sess.CreatedAt,
 		sess.LastActivity,
+		sess.HeadSHA,
+		sess.PlanChecklist,
+		sess.Execution,
 	)
 	if err != nil {
-		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		if store.IsUniqueViolation(err) {
 			return fmt.Errorf("session already exists for %s/%s#%d", sess.RepoOwner, sess.RepoName, sess.IssueNumber)
 		}
 		return fmt.Errorf("create session: %w", err)
@@ -90,11 +122,12 @@ func (s *SQLiteStore) Create(ctx context.Context, sess *Session) error {
 }
 
 // Get retrieves a session by ID.
-func (s *SQLiteStore) Get(ctx context.Context, id string) (*Session, error) {
+func (s *SQLStore) Get(ctx context.Context, id string) (*Session, error) {
 	query := `
 		SELECT id, repo_owner, repo_name, issue_number, pr_number,
 			   phase, branch, container_id, plan_content, error_message,
-			   created_at, last_activity
+			   created_at, last_activity, head_sha, plan_checklist, execution,
+			   revision_rounds, plan_revisions, awaiting_approval_seconds, in_review_seconds
 		FROM sessions
 		WHERE id = ?
 	`
@@ -114,6 +147,13 @@ func (s *SQLiteStore) Get(ctx context.Context, id string) (*Session, error) {
 		&sess.ErrorMessage,
 		&sess.CreatedAt,
 		&sess.LastActivity,
+		&sess.HeadSHA,
+		&sess.PlanChecklist,
+		&sess.Execution,
+		&sess.Metrics.RevisionRounds,
+		&sess.Metrics.PlanRevisions,
+		&sess.Metrics.AwaitingApprovalSeconds,
+		&sess.Metrics.InReviewSeconds,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -127,11 +167,12 @@ func (s *SQLiteStore) Get(ctx context.Context, id string) (*Session, error) {
 }
 
 // GetByIssue retrieves a session by repository and issue number.
-func (s *SQLiteStore) GetByIssue(ctx context.Context, owner, repo string, issueNumber int) (*Session, error) {
+func (s *SQLStore) GetByIssue(ctx context.Context, owner, repo string, issueNumber int) (*Session, error) {
 	query := `
 		SELECT id, repo_owner, repo_name, issue_number, pr_number,
 			   phase, branch, container_id, plan_content, error_message,
-			   created_at, last_activity
+			   created_at, last_activity, head_sha, plan_checklist, execution,
+			   revision_rounds, plan_revisions, awaiting_approval_seconds, in_review_seconds
 		FROM sessions
 		WHERE repo_owner = ? AND repo_name = ? AND issue_number = ?
 	`
@@ -151,6 +192,13 @@ func (s *SQLiteStore) GetByIssue(ctx context.Context, owner, repo string, issueN
 		&sess.ErrorMessage,
 		&sess.CreatedAt,
 		&sess.LastActivity,
+		&sess.HeadSHA,
+		&sess.PlanChecklist,
+		&sess.Execution,
+		&sess.Metrics.RevisionRounds,
+		&sess.Metrics.PlanRevisions,
+		&sess.Metrics.AwaitingApprovalSeconds,
+		&sess.Metrics.InReviewSeconds,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -163,8 +211,100 @@ func (s *SQLiteStore) GetByIssue(ctx context.Context, owner, repo string, issueN
 	return sess, nil
 }
 
-// Update updates an existing session.
-func (s *SQLiteStore) Update(ctx context.Context, sess *Session) error {
+// GetByPR retrieves a session by repository and pull request number.
+func (s *SQLStore) GetByPR(ctx context.Context, owner, repo string, prNumber int) (*Session, error) {
+	query := `
+		SELECT id, repo_owner, repo_name, issue_number, pr_number,
+			   phase, branch, container_id, plan_content, error_message,
+			   created_at, last_activity, head_sha, plan_checklist, execution,
+			   revision_rounds, plan_revisions, awaiting_approval_seconds, in_review_seconds
+		FROM sessions
+		WHERE repo_owner = ? AND repo_name = ? AND pr_number = ?
+	`
+
+	sess := &Session{}
+	var phase string
+	err := s.db.QueryRowContext(ctx, query, owner, repo, prNumber).Scan(
+		&sess.ID,
+		&sess.RepoOwner,
+		&sess.RepoName,
+		&sess.IssueNumber,
+		&sess.PRNumber,
+		&phase,
+		&sess.Branch,
+		&sess.ContainerID,
+		&sess.PlanContent,
+		&sess.ErrorMessage,
+		&sess.CreatedAt,
+		&sess.LastActivity,
+		&sess.HeadSHA,
+		&sess.PlanChecklist,
+		&sess.Execution,
+		&sess.Metrics.RevisionRounds,
+		&sess.Metrics.PlanRevisions,
+		&sess.Metrics.AwaitingApprovalSeconds,
+		&sess.Metrics.InReviewSeconds,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get session by PR: %w", err)
+	}
+
+	sess.Phase = Phase(phase)
+	return sess, nil
+}
+
+// GetByBranch retrieves a session by repository and branch name.
+func (s *SQLStore) GetByBranch(ctx context.Context, owner, repo, branch string) (*Session, error) {
+	query := `
+		SELECT id, repo_owner, repo_name, issue_number, pr_number,
+			   phase, branch, container_id, plan_content, error_message,
+			   created_at, last_activity, head_sha, plan_checklist, execution,
+			   revision_rounds, plan_revisions, awaiting_approval_seconds, in_review_seconds
+		FROM sessions
+		WHERE repo_owner = ? AND repo_name = ? AND branch = ?
+	`
+
+	sess := &Session{}
+	var phase string
+	err := s.db.QueryRowContext(ctx, query, owner, repo, branch).Scan(
+		&sess.ID,
+		&sess.RepoOwner,
+		&sess.RepoName,
+		&sess.IssueNumber,
+		&sess.PRNumber,
+		&phase,
+		&sess.Branch,
+		&sess.ContainerID,
+		&sess.PlanContent,
+		&sess.ErrorMessage,
+		&sess.CreatedAt,
+		&sess.LastActivity,
+		&sess.HeadSHA,
+		&sess.PlanChecklist,
+		&sess.Execution,
+		&sess.Metrics.RevisionRounds,
+		&sess.Metrics.PlanRevisions,
+		&sess.Metrics.AwaitingApprovalSeconds,
+		&sess.Metrics.InReviewSeconds,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get session by branch: %w", err)
+	}
+
+	sess.Phase = Phase(phase)
+	return sess, nil
+}
+
+// Update updates an existing session. An aborted or completed session stays
+// so: a handler still holding an older copy gets a TransitionError instead
+// of reviving it.
+func (s *SQLStore) Update(ctx context.Context, sess *Session) error {
 	if err := sess.Validate(); err != nil {
 		return fmt.Errorf("invalid session: %w", err)
 	}
@@ -176,19 +316,32 @@ func (s *SQLiteStore) Update(ctx context.Context, sess *Session) error {
 			container_id = ?,
 			plan_content = ?,
 			error_message = ?,
-			last_activity = ?
+			last_activity = ?,
+			head_sha = ?,
+			plan_checklist = ?,
+			execution = ?
 		WHERE id = ?
 	`
-
-	result, err := s.db.ExecContext(ctx, query,
+	args := []interface{}{
 		sess.PRNumber,
 		string(sess.Phase),
 		sess.ContainerID,
 		sess.PlanContent,
 		sess.ErrorMessage,
 		sess.LastActivity,
+		sess.HeadSHA,
+		sess.PlanChecklist,
+		sess.Execution,
 		sess.ID,
-	)
+	}
+	for _, terminal := range []Phase{PhaseAborted, PhaseCompleted} {
+		if sess.Phase != terminal {
+			query += " AND phase != ?"
+			args = append(args, string(terminal))
+		}
+	}
+
+	result, err := s.db.ExecContext(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("update session: %w", err)
 	}
@@ -198,14 +351,31 @@ func (s *SQLiteStore) Update(ctx context.Context, sess *Session) error {
 		return fmt.Errorf("get rows affected: %w", err)
 	}
 	if rows == 0 {
+		current, err := s.Get(ctx, sess.ID)
+		if err != nil {
+			return err
+		}
+		if current != nil && (current.Phase == PhaseAborted || current.Phase == PhaseCompleted) {
+			return &TransitionError{From: current.Phase, To: sess.Phase}
+		}
 		return fmt.Errorf("session not found: %s", sess.ID)
 	}
 
 	return nil
 }
 
+// SetExecution records the execution state of a session in a job phase and
+// counts it as activity. Aborted sessions are left alone.
+func (s *SQLStore) SetExecution(ctx context.Context, id string, exec Execution) error {
+	query := `UPDATE sessions SET execution = ?, last_activity = ? WHERE id = ? AND phase != ?`
+	if _, err := s.db.ExecContext(ctx, query, exec, time.Now().UTC(), id, string(PhaseAborted)); err != nil {
+		return fmt.Errorf("set execution: %w", err)
+	}
+	return nil
+}
+
 // Delete deletes a session by ID.
-func (s *SQLiteStore) Delete(ctx context.Context, id string) error {
+func (s *SQLStore) Delete(ctx context.Context, id string) error {
 	query := `DELETE FROM sessions WHERE id = ?`
 
 	result, err := s.db.ExecContext(ctx, query, id)
@@ -224,35 +394,20 @@ func (s *SQLiteStore) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
-// List returns sessions matching the filter criteria.
-func (s *SQLiteStore) List(ctx context.Context, filter SessionFilter) ([]Session, error) {
-	var conditions []string
-	var args []interface{}
-
-	if filter.RepoOwner != "" {
-		conditions = append(conditions, "repo_owner = ?")
-		args = append(args, filter.RepoOwner)
-	}
-	if filter.RepoName != "" {
-		conditions = append(conditions, "repo_name = ?")
-		args = append(args, filter.RepoName)
-	}
-	if filter.Phase != nil {
-		conditions = append(conditions, "phase = ?")
-		args = append(args, string(*filter.Phase))
-	}
-	if filter.ActiveOnly {
-		conditions = append(conditions, "phase NOT IN (?, ?)")
-		args = append(args, string(PhaseCompleted), string(PhaseError))
-	}
-
-	query := `
+// listQuery selects the sessions scanned by querySessions.
+const listQuery = `
 		SELECT id, repo_owner, repo_name, issue_number, pr_number,
 			   phase, branch, container_id, plan_content, error_message,
-			   created_at, last_activity
+			   created_at, last_activity, head_sha, plan_checklist, execution,
+			   revision_rounds, plan_revisions, awaiting_approval_seconds, in_review_seconds
 		FROM sessions
 	`
 
+// List returns sessions matching the filter criteria.
+func (s *SQLStore) List(ctx context.Context, filter SessionFilter) ([]Session, error) {
+	conditions, args := filterConditions(filter)
+
+	query := listQuery
 	if len(conditions) > 0 {
 		query += " WHERE " + strings.Join(conditions, " AND ")
 	}
@@ -266,6 +421,12 @@ func (s *SQLiteStore) List(ctx context.Context, filter SessionFilter) ([]Session
 		}
 	}
 
+	return s.querySessions(ctx, query, args...)
+}
+
+// querySessions runs listQuery, extended with conditions and an order, and
+// scans the sessions it returns.
+func (s *SQLStore) querySessions(ctx context.Context, query string, args ...interface{}) ([]Session, error) {
 	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("list sessions: %w", err)
@@ -289,6 +450,13 @@ func (s *SQLiteStore) List(ctx context.Context, filter SessionFilter) ([]Session
 			&sess.ErrorMessage,
 			&sess.CreatedAt,
 			&sess.LastActivity,
+			&sess.HeadSHA,
+			&sess.PlanChecklist,
+			&sess.Execution,
+			&sess.Metrics.RevisionRounds,
+			&sess.Metrics.PlanRevisions,
+			&sess.Metrics.AwaitingApprovalSeconds,
+			&sess.Metrics.InReviewSeconds,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("scan session: %w", err)
@@ -304,8 +472,24 @@ func (s *SQLiteStore) List(ctx context.Context, filter SessionFilter) ([]Session
 	return sessions, nil
 }
 
+// limitOffset returns the LIMIT and OFFSET clauses of a page; zero values
+// leave them out.
+func (s *SQLStore) limitOffset(limit, offset int) string {
+	var clause string
+	if limit > 0 {
+		clause += fmt.Sprintf(" LIMIT %d", limit)
+	} else if offset > 0 && s.db.Driver() == store.DriverSQLite {
+		// SQLite only takes OFFSET after a LIMIT
+		clause += " LIMIT -1"
+	}
+	if offset > 0 {
+		clause += fmt.Sprintf(" OFFSET %d", offset)
+	}
+	return clause
+}
+
 // RecordEvent records an event in the session's history.
-func (s *SQLiteStore) RecordEvent(ctx context.Context, sessionID string, eventType EventType, payload interface{}) error {
+func (s *SQLStore) RecordEvent(ctx context.Context, sessionID string, eventType EventType, payload interface{}) error {
 	var payloadJSON string
 	if payload != nil {
 		data, err := json.Marshal(payload)
@@ -325,19 +509,43 @@ func (s *SQLiteStore) RecordEvent(ctx context.Context, sessionID string, eventTy
 		return fmt.Errorf("record event: %w", err)
 	}
 
+	if eventType == EventTypePhaseChange || eventType == EventTypeError {
+		if _, err := s.RefreshMetrics(ctx, sessionID); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// GetEvents retrieves events for a session.
-func (s *SQLiteStore) GetEvents(ctx context.Context, sessionID string) ([]SessionEvent, error) {
+// GetEvents retrieves the events of a session matching the filter, oldest
+// first.
+func (s *SQLStore) GetEvents(ctx context.Context, sessionID string, filter EventFilter) ([]SessionEvent, error) {
 	query := `
 		SELECT id, session_id, event_type, payload, created_at
 		FROM session_events
 		WHERE session_id = ?
-		ORDER BY created_at ASC
 	`
+	args := []interface{}{sessionID}
+
+	if len(filter.Types) > 0 {
+		query += " AND event_type IN (?" + strings.Repeat(", ?", len(filter.Types)-1) + ")"
+		for _, t := range filter.Types {
+			args = append(args, string(t))
+		}
+	}
+	if !filter.Since.IsZero() {
+		query += " AND created_at >= ?"
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		query += " AND created_at < ?"
+		args = append(args, filter.Until.UTC())
+	}
+
+	query += " ORDER BY created_at ASC, id ASC" + s.limitOffset(filter.Limit, filter.Offset)
 
-	rows, err := s.db.QueryContext(ctx, query, sessionID)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("get events: %w", err)
 	}
@@ -368,8 +576,90 @@ func (s *SQLiteStore) GetEvents(ctx context.Context, sessionID string) ([]Sessio
 	return events, nil
 }
 
+// GetJobEvent retrieves the latest event of a type whose payload names the
+// job. Returns nil if there is none.
+func (s *SQLStore) GetJobEvent(ctx context.Context, eventType EventType, jobID string) (*SessionEvent, error) {
+	query := `
+		SELECT id, session_id, event_type, payload, created_at
+		FROM session_events
+		WHERE event_type = ? AND ` + s.db.JSONText("payload", "job_id") + ` = ?
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+
+	var event SessionEvent
+	var typ string
+	err := s.db.QueryRowContext(ctx, query, string(eventType), jobID).Scan(
+		&event.ID,
+		&event.SessionID,
+		&typ,
+		&event.Payload,
+		&event.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get job event: %w", err)
+	}
+
+	event.EventType = EventType(typ)
+	return &event, nil
+}
+
+// JobIDs returns the IDs of jobs named in the events of sessions matching
+// the filter.
+func (s *SQLStore) JobIDs(ctx context.Context, filter SessionFilter) ([]string, error) {
+	conditions, args := filterConditions(filter)
+
+	query := `
+		SELECT DISTINCT job_id FROM (
+			SELECT session_id, ` + s.db.JSONText("payload", "job_id") + ` AS job_id
+			FROM session_events
+		) AS job_events
+		WHERE job_id IS NOT NULL
+	`
+	if len(conditions) > 0 {
+		query += " AND session_id IN (SELECT id FROM sessions WHERE " + strings.Join(conditions, " AND ") + ")"
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list job IDs: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan job ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
 // Count returns the number of sessions matching the filter.
-func (s *SQLiteStore) Count(ctx context.Context, filter SessionFilter) (int, error) {
+func (s *SQLStore) Count(ctx context.Context, filter SessionFilter) (int, error) {
+	conditions, args := filterConditions(filter)
+
+	query := `SELECT COUNT(*) FROM sessions`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var count int
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count sessions: %w", err)
+	}
+
+	return count, nil
+}
+
+// filterConditions returns the WHERE conditions and arguments for a filter.
+func filterConditions(filter SessionFilter) ([]string, []interface{}) {
 	var conditions []string
 	var args []interface{}
 
@@ -386,20 +676,116 @@ func (s *SQLiteStore) Count(ctx context.Context, filter SessionFilter) (int, err
 		args = append(args, string(*filter.Phase))
 	}
 	if filter.ActiveOnly {
-		conditions = append(conditions, "phase NOT IN (?, ?)")
-		args = append(args, string(PhaseCompleted), string(PhaseError))
+		active := ActivePhases()
+		conditions = append(conditions, "phase IN (?"+strings.Repeat(", ?", len(active)-1)+")")
+		for _, p := range active {
+			args = append(args, string(p))
+		}
 	}
 
-	query := `SELECT COUNT(*) FROM sessions`
+	return conditions, args
+}
+
+// RefreshMetrics recomputes a session's metrics from its events and stores
+// them. Update never writes the metric columns, so a stale Session cannot
+// overwrite them.
+func (s *SQLStore) RefreshMetrics(ctx context.Context, sessionID string) (*Metrics, error) {
+	events, err := s.GetEvents(ctx, sessionID, EventFilter{})
+	if err != nil {
+		return nil, err
+	}
+	metrics := ComputeMetrics(events)
+
+	query := `
+		UPDATE sessions SET
+			revision_rounds = ?,
+			plan_revisions = ?,
+			awaiting_approval_seconds = ?,
+			in_review_seconds = ?
+		WHERE id = ?
+	`
+
+	_, err = s.db.ExecContext(ctx, query,
+		metrics.RevisionRounds,
+		metrics.PlanRevisions,
+		metrics.AwaitingApprovalSeconds,
+		metrics.InReviewSeconds,
+		sessionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("update session metrics: %w", err)
+	}
+
+	return &metrics, nil
+}
+
+// MetricsSummary aggregates the metrics of sessions matching the filter.
+func (s *SQLStore) MetricsSummary(ctx context.Context, filter SessionFilter) (*MetricsSummary, error) {
+	conditions, args := filterConditions(filter)
+	risk := s.db.JSONText("plan_checklist", "risk")
+	migration := s.db.JSONText("plan_checklist", "migration")
+
+	query := `
+		SELECT COUNT(*),
+			   COALESCE(SUM(CASE WHEN revision_rounds > 0 THEN 1 ELSE 0 END), 0),
+			   COALESCE(SUM(revision_rounds), 0),
+			   COALESCE(SUM(plan_revisions), 0),
+			   COALESCE(SUM(awaiting_approval_seconds), 0),
+			   COALESCE(SUM(in_review_seconds), 0),
+			   COALESCE(SUM(CASE WHEN ` + risk + ` = 'low' THEN 1 ELSE 0 END), 0),
+			   COALESCE(SUM(CASE WHEN ` + risk + ` = 'medium' THEN 1 ELSE 0 END), 0),
+			   COALESCE(SUM(CASE WHEN ` + risk + ` = 'high' THEN 1 ELSE 0 END), 0),
+			   COALESCE(SUM(CASE WHEN ` + migration + ` IN ('1', 'true') THEN 1 ELSE 0 END), 0)
+		FROM sessions
+	`
 	if len(conditions) > 0 {
 		query += " WHERE " + strings.Join(conditions, " AND ")
 	}
 
-	var count int
-	err := s.db.QueryRowContext(ctx, query, args...).Scan(&count)
+	summary := &MetricsSummary{}
+	var low, medium, high int
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(
+		&summary.Sessions,
+		&summary.Revised,
+		&summary.Totals.RevisionRounds,
+		&summary.Totals.PlanRevisions,
+		&summary.Totals.AwaitingApprovalSeconds,
+		&summary.Totals.InReviewSeconds,
+		&low,
+		&medium,
+		&high,
+		&summary.Migrations,
+	)
 	if err != nil {
-		return 0, fmt.Errorf("count sessions: %w", err)
+		return nil, fmt.Errorf("summarize session metrics: %w", err)
+	}
+	summary.PlanRisk = map[string]int{"low": low, "medium": medium, "high": high}
+
+	query = `
+		SELECT COUNT(*), COALESCE(MAX(memory), 0), COALESCE(AVG(memory), 0), COALESCE(MAX(cpu), 0)
+		FROM (
+			SELECT session_id,
+				   ` + s.db.JSONNumber("payload", "peak_memory_bytes", "BIGINT") + ` AS memory,
+				   ` + s.db.JSONNumber("payload", "peak_cpu_percent", "DOUBLE PRECISION") + ` AS cpu
+			FROM session_events
+			WHERE event_type = ?
+		) AS job_resources
+		WHERE memory IS NOT NULL
+	`
+	if len(conditions) > 0 {
+		query += " AND session_id IN (SELECT id FROM sessions WHERE " + strings.Join(conditions, " AND ") + ")"
+	}
+	var avgMemory float64
+	err = s.db.QueryRowContext(ctx, query, append([]interface{}{string(EventTypeContainerStop)}, args...)...).Scan(
+		&summary.Resources.Jobs,
+		&summary.Resources.MaxMemoryBytes,
+		&avgMemory,
+		&summary.Resources.MaxCPUPercent,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("summarize job resources: %w", err)
 	}
+	summary.Resources.AvgMemoryBytes = int64(avgMemory)
 
-	return count, nil
+	return summary, nil
 }
@@ -3,11 +3,12 @@ package session
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/mpm/manfred/internal/store"
 )
 
-func setupTestStore(t *testing.T) (*SQLiteStore, func()) {
+func setupTestStore(t *testing.T) (*SQLStore, func()) {
 	t.Helper()
 
 	db, err := store.OpenInMemory()
@@ -20,7 +21,7 @@ func setupTestStore(t *testing.T) (*SQLiteStore, func()) {
 		t.Fatalf("migrate db: %v", err)
 	}
 
-	sessionStore := NewSQLiteStore(db)
+	sessionStore := NewSQLStore(db)
 
 	cleanup := func() {
 		db.Close()
@@ -29,7 +30,7 @@ func setupTestStore(t *testing.T) (*SQLiteStore, func()) {
 	return sessionStore, cleanup
 }
 
-func TestSQLiteStoreCreate(t *testing.T) {
+func TestSQLStoreCreate(t *testing.T) {
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
@@ -60,7 +61,7 @@ func TestSQLiteStoreCreate(t *testing.T) {
 	}
 }
 
-func TestSQLiteStoreCreateDuplicate(t *testing.T) {
+func TestSQLStoreCreateDuplicate(t *testing.T) {
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
@@ -80,7 +81,7 @@ func TestSQLiteStoreCreateDuplicate(t *testing.T) {
 	}
 }
 
-func TestSQLiteStoreGetByIssue(t *testing.T) {
+func TestSQLStoreGetByIssue(t *testing.T) {
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
@@ -109,7 +110,72 @@ func TestSQLiteStoreGetByIssue(t *testing.T) {
 	}
 }
 
-func TestSQLiteStoreUpdate(t *testing.T) {
+func TestSQLStoreGetByPR(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	sess := NewSession("owner", "repo", 42)
+	sess.SetPRNumber(7)
+	store.Create(ctx, sess)
+
+	got, err := store.GetByPR(ctx, "owner", "repo", 7)
+	if err != nil {
+		t.Fatalf("GetByPR() = %v, want nil", err)
+	}
+	if got == nil {
+		t.Fatal("GetByPR() = nil, want session")
+	}
+	if got.ID != sess.ID {
+		t.Errorf("ID = %q, want %q", got.ID, sess.ID)
+	}
+
+	// Non-existent
+	got, err = store.GetByPR(ctx, "owner", "repo", 42)
+	if err != nil {
+		t.Fatalf("GetByPR() non-existent = %v, want nil", err)
+	}
+	if got != nil {
+		t.Errorf("GetByPR() non-existent = %v, want nil", got)
+	}
+}
+
+func TestSQLStoreGetByBranch(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	sess := NewSession("owner", "repo", 42)
+	sess.SetHeadSHA("abc123")
+	sess.Phase = PhaseInReview
+	sess.Pause()
+	store.Create(ctx, sess)
+
+	got, err := store.GetByBranch(ctx, "owner", "repo", sess.Branch)
+	if err != nil {
+		t.Fatalf("GetByBranch() = %v, want nil", err)
+	}
+	if got == nil {
+		t.Fatal("GetByBranch() = nil, want session")
+	}
+	if got.HeadSHA == nil || *got.HeadSHA != "abc123" {
+		t.Errorf("HeadSHA = %v, want abc123", got.HeadSHA)
+	}
+	if got.Phase != PhasePaused {
+		t.Errorf("Phase = %q, want %q", got.Phase, PhasePaused)
+	}
+
+	// Non-existent
+	got, err = store.GetByBranch(ctx, "owner", "repo", "main")
+	if err != nil {
+		t.Fatalf("GetByBranch() non-existent = %v, want nil", err)
+	}
+	if got != nil {
+		t.Errorf("GetByBranch() non-existent = %v, want nil", got)
+	}
+}
+
+func TestSQLStoreUpdate(t *testing.T) {
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
@@ -123,6 +189,7 @@ func TestSQLiteStoreUpdate(t *testing.T) {
 	sess.PRNumber = &prNum
 	plan := "Implementation plan"
 	sess.PlanContent = &plan
+	sess.PlanChecklist = PlanChecklist{Risk: "medium", Files: []string{"main.go"}}
 
 	err := store.Update(ctx, sess)
 	if err != nil {
@@ -140,9 +207,101 @@ func TestSQLiteStoreUpdate(t *testing.T) {
 	if got.PlanContent == nil || *got.PlanContent != plan {
 		t.Errorf("PlanContent = %v, want %q", got.PlanContent, plan)
 	}
+	if got.PlanChecklist.Risk != "medium" || len(got.PlanChecklist.Files) != 1 {
+		t.Errorf("PlanChecklist = %+v, want risk medium and one file", got.PlanChecklist)
+	}
 }
 
-func TestSQLiteStoreDelete(t *testing.T) {
+func TestSQLStoreUpdateAborted(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	sess := NewSession("owner", "repo", 42)
+	store.Create(ctx, sess)
+
+	// A handler still holding the planning session must not revive it
+	stale := *sess
+	if err := sess.Abort(); err != nil {
+		t.Fatalf("Abort() = %v", err)
+	}
+	if err := store.Update(ctx, sess); err != nil {
+		t.Fatalf("Update(aborted) = %v, want nil", err)
+	}
+
+	stale.Phase = PhaseAwaitingApproval
+	err := store.Update(ctx, &stale)
+	if _, ok := err.(*TransitionError); !ok {
+		t.Errorf("Update(stale) = %v, want TransitionError", err)
+	}
+	if got, _ := store.Get(ctx, sess.ID); got.Phase != PhaseAborted {
+		t.Errorf("Phase = %q, want %q", got.Phase, PhaseAborted)
+	}
+
+	// Updating the aborted session itself still works
+	sess.ClearContainerID()
+	if err := store.Update(ctx, sess); err != nil {
+		t.Errorf("Update(aborted again) = %v, want nil", err)
+	}
+}
+
+func TestSQLStoreUpdateCompleted(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	sess := NewSession("owner", "repo", 42)
+	sess.Phase = PhaseRevising
+	store.Create(ctx, sess)
+
+	// A revision failing after the PR was merged must not move it to error
+	stale := *sess
+	sess.Phase = PhaseCompleted
+	if err := store.Update(ctx, sess); err != nil {
+		t.Fatalf("Update(completed) = %v, want nil", err)
+	}
+	stale.SetError("job canceled")
+	err := store.Update(ctx, &stale)
+	if te, ok := err.(*TransitionError); !ok || te.From != PhaseCompleted {
+		t.Errorf("Update(stale) = %v, want TransitionError from completed", err)
+	}
+	if got, _ := store.Get(ctx, sess.ID); got.Phase != PhaseCompleted {
+		t.Errorf("Phase = %q, want %q", got.Phase, PhaseCompleted)
+	}
+}
+
+func TestSQLStoreSetExecution(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	sess := NewSession("owner", "repo", 42)
+	if err := store.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() = %v", err)
+	}
+
+	exec := Execution{Step: StepJob, JobID: "job_1", ComposeProject: "manfred-job_1", Host: "worker", PID: 4242}
+	if err := store.SetExecution(ctx, sess.ID, exec); err != nil {
+		t.Fatalf("SetExecution() = %v", err)
+	}
+	got, _ := store.Get(ctx, sess.ID)
+	if got.Execution != exec {
+		t.Errorf("Execution = %+v, want %+v", got.Execution, exec)
+	}
+
+	// Leaving the phase clears it
+	if err := got.SetPlan("plan"); err != nil {
+		t.Fatalf("SetPlan() = %v", err)
+	}
+	if err := store.Update(ctx, got); err != nil {
+		t.Fatalf("Update() = %v", err)
+	}
+	if got, _ := store.Get(ctx, sess.ID); !got.Execution.IsZero() {
+		t.Errorf("Execution after SetPlan = %+v, want none", got.Execution)
+	}
+}
+
+func TestSQLStoreDelete(t *testing.T) {
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
@@ -171,7 +330,7 @@ func TestSQLiteStoreDelete(t *testing.T) {
 	}
 }
 
-func TestSQLiteStoreList(t *testing.T) {
+func TestSQLStoreList(t *testing.T) {
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
@@ -242,7 +401,7 @@ func TestSQLiteStoreList(t *testing.T) {
 	}
 }
 
-func TestSQLiteStoreCount(t *testing.T) {
+func TestSQLStoreCount(t *testing.T) {
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
@@ -279,7 +438,7 @@ func TestSQLiteStoreCount(t *testing.T) {
 	}
 }
 
-func TestSQLiteStoreRecordEvent(t *testing.T) {
+func TestSQLStoreRecordEvent(t *testing.T) {
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
@@ -302,7 +461,7 @@ func TestSQLiteStoreRecordEvent(t *testing.T) {
 	}
 
 	// Get events
-	events, err := store.GetEvents(ctx, sess.ID)
+	events, err := store.GetEvents(ctx, sess.ID, EventFilter{})
 	if err != nil {
 		t.Fatalf("GetEvents() = %v, want nil", err)
 	}
@@ -318,7 +477,51 @@ func TestSQLiteStoreRecordEvent(t *testing.T) {
 	}
 }
 
-func TestSQLiteStoreEventsDeletedWithSession(t *testing.T) {
+func TestSQLStoreEventFilter(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	sess := NewSession("owner", "repo", 42)
+	store.Create(ctx, sess)
+
+	start := time.Now()
+	for _, eventType := range []EventType{EventTypeCommentReceived, EventTypeCommentPosted, EventTypeError, EventTypeCommentPosted, EventTypeCommentPosted} {
+		if err := store.RecordEvent(ctx, sess.ID, eventType, nil); err != nil {
+			t.Fatalf("RecordEvent() = %v, want nil", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter EventFilter
+		want   int
+	}{
+		{"all", EventFilter{}, 5},
+		{"one type", EventFilter{Types: []EventType{EventTypeCommentPosted}}, 3},
+		{"two types", EventFilter{Types: []EventType{EventTypeError, EventTypeCommentReceived}}, 2},
+		{"since", EventFilter{Since: start.Add(-time.Minute)}, 5},
+		{"since future", EventFilter{Since: start.Add(time.Hour)}, 0},
+		{"until past", EventFilter{Until: start.Add(-time.Minute)}, 0},
+		{"limit", EventFilter{Limit: 2}, 2},
+		{"offset", EventFilter{Offset: 4}, 1},
+		{"page", EventFilter{Types: []EventType{EventTypeCommentPosted}, Limit: 2, Offset: 2}, 1},
+	}
+	for _, tt := range tests {
+		events, err := store.GetEvents(ctx, sess.ID, tt.filter)
+		if err != nil || len(events) != tt.want {
+			t.Errorf("%s: GetEvents() = %d events, %v, want %d", tt.name, len(events), err, tt.want)
+		}
+	}
+
+	// Pages follow recording order
+	page, _ := store.GetEvents(ctx, sess.ID, EventFilter{Limit: 2, Offset: 1})
+	if len(page) != 2 || page[0].EventType != EventTypeCommentPosted || page[1].EventType != EventTypeError {
+		t.Errorf("GetEvents(limit 2, offset 1) = %+v, want comment_posted, error", page)
+	}
+}
+
+func TestSQLStoreEventsDeletedWithSession(t *testing.T) {
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
@@ -333,7 +536,7 @@ func TestSQLiteStoreEventsDeletedWithSession(t *testing.T) {
 	store.Delete(ctx, sess.ID)
 
 	// Events should be gone too (cascade delete)
-	events, err := store.GetEvents(ctx, sess.ID)
+	events, err := store.GetEvents(ctx, sess.ID, EventFilter{})
 	if err != nil {
 		t.Fatalf("GetEvents() after delete = %v, want nil", err)
 	}
@@ -341,3 +544,96 @@ func TestSQLiteStoreEventsDeletedWithSession(t *testing.T) {
 		t.Errorf("GetEvents() after delete len = %d, want 0", len(events))
 	}
 }
+
+func TestSQLStoreMetrics(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	sess := NewSession("owner", "repo", 42)
+	if err := store.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	other := NewSession("owner", "repo", 43)
+	if err := store.Create(ctx, other); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	for _, to := range []Phase{PhaseRevising, PhaseInReview, PhaseRevising} {
+		if err := store.RecordEvent(ctx, sess.ID, EventTypePhaseChange, map[string]string{"to": string(to)}); err != nil {
+			t.Fatalf("RecordEvent() error = %v", err)
+		}
+	}
+
+	got, err := store.Get(ctx, sess.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Metrics.RevisionRounds != 2 {
+		t.Errorf("Metrics.RevisionRounds = %d, want 2", got.Metrics.RevisionRounds)
+	}
+
+	// Update must not reset the metrics computed from events
+	sess.Touch()
+	if err := store.Update(ctx, sess); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	summary, err := store.MetricsSummary(ctx, SessionFilter{})
+	if err != nil {
+		t.Fatalf("MetricsSummary() error = %v", err)
+	}
+	if summary.Sessions != 2 || summary.Revised != 1 || summary.Totals.RevisionRounds != 2 {
+		t.Errorf("MetricsSummary() = %+v, want 2 sessions, 1 revised, 2 rounds", summary)
+	}
+	if summary.Resources.Jobs != 0 {
+		t.Errorf("MetricsSummary().Resources = %+v, want no monitored jobs", summary.Resources)
+	}
+
+	store.RecordEvent(ctx, sess.ID, EventTypeContainerStop, map[string]interface{}{"job_id": "job_a", "peak_memory_bytes": 100, "peak_cpu_percent": 50.5})
+	store.RecordEvent(ctx, other.ID, EventTypeContainerStop, map[string]interface{}{"job_id": "job_b", "peak_memory_bytes": 300, "peak_cpu_percent": 20})
+	store.RecordEvent(ctx, other.ID, EventTypeContainerStop, map[string]string{"job_id": "job_c"})
+
+	summary, err = store.MetricsSummary(ctx, SessionFilter{})
+	if err != nil {
+		t.Fatalf("MetricsSummary() error = %v", err)
+	}
+	want := ResourceSummary{Jobs: 2, MaxMemoryBytes: 300, AvgMemoryBytes: 200, MaxCPUPercent: 50.5}
+	if summary.Resources != want {
+		t.Errorf("MetricsSummary().Resources = %+v, want %+v", summary.Resources, want)
+	}
+}
+
+func TestSQLStoreJobIDs(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	active := NewSession("owner", "repo", 1)
+	done := NewSession("owner", "repo", 2)
+	done.Phase = PhaseCompleted
+	store.Create(ctx, active)
+	store.Create(ctx, done)
+
+	store.RecordEvent(ctx, active.ID, EventTypePhaseChange, map[string]string{"from": "planning", "to": "awaiting_approval", "job_id": "job_a"})
+	store.RecordEvent(ctx, active.ID, EventTypeJobDiff, map[string]string{"job_id": "job_a"})
+	store.RecordEvent(ctx, active.ID, EventTypeCommentPosted, "Posted plan comment")
+	store.RecordEvent(ctx, active.ID, EventTypeCommentPosted, nil)
+	store.RecordEvent(ctx, done.ID, EventTypePhaseChange, map[string]string{"from": "implementing", "to": "in_review", "job_id": "job_b"})
+
+	ids, err := store.JobIDs(ctx, SessionFilter{ActiveOnly: true})
+	if err != nil {
+		t.Fatalf("JobIDs() = %v, want nil", err)
+	}
+	if len(ids) != 1 || ids[0] != "job_a" {
+		t.Errorf("JobIDs(active) = %v, want [job_a]", ids)
+	}
+
+	ids, err = store.JobIDs(ctx, SessionFilter{})
+	if err != nil {
+		t.Fatalf("JobIDs() = %v, want nil", err)
+	}
+	if len(ids) != 2 {
+		t.Errorf("JobIDs(all) = %v, want 2 IDs", ids)
+	}
+}
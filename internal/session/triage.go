@@ -0,0 +1,74 @@
+package session
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// TriageFence is the info string of the fenced JSON block the triage
+// prompt asks Claude to end its triage with.
+const TriageFence = "manfred-triage"
+
+// maxTriageItems caps the labels, modules and duplicates kept from a triage.
+const maxTriageItems = 10
+
+// triageBlock matches a manfred-triage fenced block.
+var triageBlock = regexp.MustCompile("(?s)```" + TriageFence + "[ \t]*\n(.*?)\n?```")
+
+// Triage is Claude's structured assessment of an issue in a triage-only
+// session. The zero value means no assessment was given.
+type Triage struct {
+	Labels     []string `json:"labels,omitempty"`     // Suggested labels
+	Modules    []string `json:"modules,omitempty"`    // Affected directories or packages
+	Estimate   string   `json:"estimate,omitempty"`   // small, medium or large
+	Duplicates []int    `json:"duplicates,omitempty"` // Issues this one likely duplicates
+}
+
+// ParseTriage splits the last manfred-triage block off a triage reply. It
+// returns the reply without any triage blocks and the parsed assessment.
+// Like ParsePlanChecklist, an unknown estimate or a block that is not valid
+// JSON leaves those fields unassessed.
+func ParseTriage(reply string) (string, Triage) {
+	matches := triageBlock.FindAllStringSubmatch(reply, -1)
+	if len(matches) == 0 {
+		return reply, Triage{}
+	}
+	stripped := strings.TrimSpace(triageBlock.ReplaceAllString(reply, ""))
+
+	var raw Triage
+	if err := json.Unmarshal([]byte(matches[len(matches)-1][1]), &raw); err != nil {
+		return stripped, Triage{}
+	}
+
+	t := Triage{
+		Labels:   uniqueStrings(raw.Labels),
+		Modules:  uniqueStrings(raw.Modules),
+		Estimate: oneOf(raw.Estimate, "small", "medium", "large"),
+	}
+	seen := map[int]bool{}
+	for _, n := range raw.Duplicates {
+		if n <= 0 || seen[n] || len(t.Duplicates) == maxTriageItems {
+			continue
+		}
+		seen[n] = true
+		t.Duplicates = append(t.Duplicates, n)
+	}
+	return stripped, t
+}
+
+// uniqueStrings trims values and drops empty ones and repeats, keeping at
+// most maxTriageItems.
+func uniqueStrings(values []string) []string {
+	var result []string
+	seen := map[string]bool{}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" || seen[v] || len(result) == maxTriageItems {
+			continue
+		}
+		seen[v] = true
+		result = append(result, v)
+	}
+	return result
+}
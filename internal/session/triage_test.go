@@ -0,0 +1,51 @@
+package session
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTriage(t *testing.T) {
+	tests := []struct {
+		name      string
+		reply     string
+		wantReply string
+		want      Triage
+	}{
+		{
+			name:      "no block",
+			reply:     "A bug in the login form.",
+			wantReply: "A bug in the login form.",
+		},
+		{
+			name: "block",
+			reply: "A bug in the login form.\n\n```manfred-triage\n" +
+				`{"labels": ["bug", " ", "bug", "ui"], "modules": ["web/login"], "estimate": "Small", "duplicates": [12, 0, 12]}` +
+				"\n```\n",
+			wantReply: "A bug in the login form.",
+			want: Triage{
+				Labels:     []string{"bug", "ui"},
+				Modules:    []string{"web/login"},
+				Estimate:   "small",
+				Duplicates: []int{12},
+			},
+		},
+		{
+			name:      "invalid JSON",
+			reply:     "A bug.\n```manfred-triage\n{labels: bug}\n```",
+			wantReply: "A bug.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply, got := ParseTriage(tt.reply)
+			if reply != tt.wantReply {
+				t.Errorf("ParseTriage() reply = %q, want %q", reply, tt.wantReply)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTriage() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
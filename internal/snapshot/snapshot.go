@@ -0,0 +1,242 @@
+// Package snapshot writes read-only JSON snapshots of MANFRED state for
+// static dashboards and backups.
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/ticket"
+)
+
+// recentJobs is the number of most recent jobs included in a snapshot.
+const recentJobs = 20
+
+// Snapshot is the JSON document written by the exporter.
+type Snapshot struct {
+	GeneratedAt time.Time               `json:"generated_at"`
+	Sessions    []SessionSummary        `json:"sessions"`
+	Jobs        JobsSummary             `json:"jobs"`
+	Queue       map[string]QueueSummary `json:"queue"`
+}
+
+// SessionSummary is a compact view of a session.
+type SessionSummary struct {
+	ID           string    `json:"id"`
+	Repo         string    `json:"repo"`
+	Issue        int       `json:"issue"`
+	PR           *int      `json:"pr,omitempty"`
+	Phase        string    `json:"phase"`
+	Error        string    `json:"error,omitempty"`
+	LastActivity time.Time `json:"last_activity"`
+
+	RevisionRounds          int   `json:"revision_rounds"`
+	PlanRevisions           int   `json:"plan_revisions"`
+	AwaitingApprovalSeconds int64 `json:"awaiting_approval_seconds"`
+	InReviewSeconds         int64 `json:"in_review_seconds"`
+}
+
+// JobsSummary lists job directories found on disk.
+type JobsSummary struct {
+	Total  int      `json:"total"`
+	Recent []string `json:"recent"`
+}
+
+// QueueSummary holds ticket counts for a project.
+type QueueSummary struct {
+	Pending    int `json:"pending"`
+	InProgress int `json:"in_progress"`
+	Error      int `json:"error"`
+	Completed  int `json:"completed"`
+	Cancelled  int `json:"cancelled"`
+}
+
+// Exporter builds and writes snapshots.
+type Exporter struct {
+	config   *config.Config
+	sessions session.Store
+}
+
+// NewExporter creates a new snapshot exporter. sessions may be nil, in which
+// case the snapshot contains no sessions.
+func NewExporter(cfg *config.Config, sessions session.Store) *Exporter {
+	return &Exporter{config: cfg, sessions: sessions}
+}
+
+// Build collects the current state into a snapshot.
+func (e *Exporter) Build(ctx context.Context) (*Snapshot, error) {
+	snap := &Snapshot{
+		GeneratedAt: time.Now().UTC(),
+		Sessions:    []SessionSummary{},
+		Queue:       map[string]QueueSummary{},
+	}
+
+	if e.sessions != nil {
+		sessions, err := e.sessions.List(ctx, session.SessionFilter{})
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range sessions {
+			summary := SessionSummary{
+				ID:           s.ID,
+				Repo:         s.RepoFullName(),
+				Issue:        s.IssueNumber,
+				PR:           s.PRNumber,
+				Phase:        string(s.Phase),
+				LastActivity: s.LastActivity,
+
+				RevisionRounds:          s.Metrics.RevisionRounds,
+				PlanRevisions:           s.Metrics.PlanRevisions,
+				AwaitingApprovalSeconds: s.Metrics.AwaitingApprovalSeconds,
+				InReviewSeconds:         s.Metrics.InReviewSeconds,
+			}
+			if s.ErrorMessage != nil {
+				summary.Error = *s.ErrorMessage
+			}
+			snap.Sessions = append(snap.Sessions, summary)
+		}
+	}
+
+	jobs, err := e.jobsSummary()
+	if err != nil {
+		return nil, err
+	}
+	snap.Jobs = jobs
+
+	entries, err := os.ReadDir(e.config.TicketsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		stats, err := ticket.NewFileStore(e.config.TicketsDir, entry.Name()).Stats(ctx)
+		if err != nil {
+			return nil, err
+		}
+		snap.Queue[entry.Name()] = QueueSummary{
+			Pending:    stats[ticket.StatusPending],
+			InProgress: stats[ticket.StatusInProgress],
+			Error:      stats[ticket.StatusError],
+			Completed:  stats[ticket.StatusCompleted],
+			Cancelled:  stats[ticket.StatusCancelled],
+		}
+	}
+
+	return snap, nil
+}
+
+// jobsSummary counts job directories. Job IDs sort chronologically.
+func (e *Exporter) jobsSummary() (JobsSummary, error) {
+	summary := JobsSummary{Recent: []string{}}
+
+	entries, err := os.ReadDir(e.config.JobsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return summary, nil
+		}
+		return summary, err
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), "job_") {
+			ids = append(ids, entry.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+
+	summary.Total = len(ids)
+	if len(ids) > recentJobs {
+		ids = ids[:recentJobs]
+	}
+	summary.Recent = append(summary.Recent, ids...)
+	return summary, nil
+}
+
+// Export builds a snapshot and writes it to dest. Destinations starting with
+// s3:// are uploaded with the aws CLI; anything else is a local file path.
+func (e *Exporter) Export(ctx context.Context, dest string) error {
+	snap, err := e.Build(ctx)
+	if err != nil {
+		return fmt.Errorf("build snapshot: %w", err)
+	}
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("marshal snapshot: %w", err)
+	}
+
+	if strings.HasPrefix(dest, "s3://") {
+		return uploadS3(ctx, data, dest)
+	}
+	return writeFileAtomic(dest, data)
+}
+
+// Run exports a snapshot every interval until ctx is cancelled.
+// Errors are passed to onError and do not stop the loop.
+func (e *Exporter) Run(ctx context.Context, dest string, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.Export(ctx, dest); err != nil && onError != nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// writeFileAtomic writes data to a temp file and renames it into place so
+// readers never see a partial snapshot.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create snapshot directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".snapshot-*.json")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// uploadS3 streams data to an S3 object using the aws CLI.
+func uploadS3(ctx context.Context, data []byte, dest string) error {
+	cmd := exec.CommandContext(ctx, "aws", "s3", "cp", "-", dest, "--content-type", "application/json")
+	cmd.Stdin = bytes.NewReader(data)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("upload snapshot to %s: %w\n%s", dest, err, output)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/store"
+	"github.com/mpm/manfred/internal/ticket"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{JobsDir: filepath.Join(dir, "jobs"), TicketsDir: filepath.Join(dir, "tickets")}
+
+	db, err := store.OpenInMemory()
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	sessions := session.NewSQLStore(db)
+	sess := session.NewSession("acme", "widgets", 7)
+	pr, msg := 12, "push rejected"
+	sess.Phase = session.PhaseError
+	sess.PRNumber = &pr
+	sess.ErrorMessage = &msg
+	if err := sessions.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	for i := 1; i <= recentJobs+2; i++ {
+		if err := os.MkdirAll(filepath.Join(cfg.JobsDir, fmt.Sprintf("job_20260101_%06d", i)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.MkdirAll(filepath.Join(cfg.JobsDir, "not-a-job"), 0755)
+
+	tickets := ticket.NewFileStore(cfg.TicketsDir, "widgets")
+	for _, prompt := range []string{"Fix the login form", "Add dark mode"} {
+		if _, err := tickets.Create(ctx, prompt, ticket.CreateOptions{}); err != nil {
+			t.Fatalf("ticket Create() error = %v", err)
+		}
+	}
+	done, err := tickets.NextPending(ctx)
+	if err != nil || done == nil {
+		t.Fatalf("NextPending() = %v, %v", done, err)
+	}
+	done.Status = ticket.StatusCompleted
+	if err := tickets.Update(ctx, done); err != nil {
+		t.Fatalf("ticket Update() error = %v", err)
+	}
+
+	e := NewExporter(cfg, sessions)
+	want, err := e.Build(ctx)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	dest := filepath.Join(dir, "out", "snapshot.json")
+	if err := e.Export(ctx, dest); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	data, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("read snapshot: %v", err)
+	}
+	var got Snapshot
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unmarshal snapshot: %v", err)
+	}
+	if got.GeneratedAt.Before(want.GeneratedAt) {
+		t.Errorf("generated_at = %v, want at or after %v", got.GeneratedAt, want.GeneratedAt)
+	}
+	got.GeneratedAt = want.GeneratedAt
+
+	// Timestamps lose their monotonic reading in JSON
+	for i := range want.Sessions {
+		want.Sessions[i].LastActivity = want.Sessions[i].LastActivity.Round(0)
+	}
+	for i := range got.Sessions {
+		got.Sessions[i].LastActivity = got.Sessions[i].LastActivity.Round(0)
+	}
+	if !reflect.DeepEqual(&got, want) {
+		t.Errorf("snapshot read back = %+v, want %+v", got, *want)
+	}
+
+	if len(got.Sessions) != 1 {
+		t.Fatalf("sessions = %+v, want one", got.Sessions)
+	}
+	s := got.Sessions[0]
+	if s.Repo != "acme/widgets" || s.Issue != 7 || s.PR == nil || *s.PR != 12 || s.Phase != "error" || s.Error != msg {
+		t.Errorf("session = %+v", s)
+	}
+	if got.Jobs.Total != recentJobs+2 || len(got.Jobs.Recent) != recentJobs || got.Jobs.Recent[0] != fmt.Sprintf("job_20260101_%06d", recentJobs+2) {
+		t.Errorf("jobs = %+v, want %d jobs, newest first", got.Jobs, recentJobs+2)
+	}
+	if q := got.Queue["widgets"]; q != (QueueSummary{Pending: 1, Completed: 1}) {
+		t.Errorf("queue = %+v, want 1 pending and 1 completed", got.Queue)
+	}
+
+	// Exporting again replaces the file without leaving temp files behind
+	if err := e.Export(ctx, dest); err != nil {
+		t.Fatalf("second Export() error = %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(dest))
+	if len(entries) != 1 {
+		t.Errorf("snapshot directory has %d entries, want only the snapshot", len(entries))
+	}
+	info, err := os.Stat(dest)
+	if err != nil {
+		t.Fatalf("stat snapshot: %v", err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("snapshot mode = %v, want 0644", info.Mode().Perm())
+	}
+}
+
+func TestBuildEmpty(t *testing.T) {
+	dir := t.TempDir()
+	e := NewExporter(&config.Config{JobsDir: filepath.Join(dir, "jobs"), TicketsDir: filepath.Join(dir, "tickets")}, nil)
+	snap, err := e.Build(context.Background())
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatalf("marshal snapshot: %v", err)
+	}
+	var got map[string]any
+	json.Unmarshal(data, &got)
+	// Dashboards iterate these, so they are empty lists and objects, not null
+	for _, key := range []string{"sessions", "queue"} {
+		if got[key] == nil {
+			t.Errorf("%s = null in %s", key, data)
+		}
+	}
+	if jobs := got["jobs"].(map[string]any); jobs["recent"] == nil || jobs["total"] != float64(0) {
+		t.Errorf("jobs = %v, want an empty summary", jobs)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "snapshot.json")
+	e := NewExporter(&config.Config{JobsDir: filepath.Join(dir, "jobs"), TicketsDir: filepath.Join(dir, "tickets")}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e.Run(ctx, dest, time.Hour, func(err error) { t.Errorf("Run() error = %v", err) })
+	if _, err := os.Stat(dest); err != nil {
+		t.Errorf("Run() did not export before waiting: %v", err)
+	}
+}
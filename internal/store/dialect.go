@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Driver is a database backend.
+type Driver string
+
+const (
+	DriverSQLite   Driver = "sqlite"   // A local file, the default
+	DriverPostgres Driver = "postgres" // A shared server, for teams running MANFRED as a service
+)
+
+// ParseDriver validates a driver name. Empty means sqlite.
+func ParseDriver(s string) (Driver, error) {
+	switch driver := Driver(s); driver {
+	case "":
+		return DriverSQLite, nil
+	case DriverSQLite, DriverPostgres:
+		return driver, nil
+	default:
+		return "", fmt.Errorf("unknown database driver %q (want sqlite or postgres)", s)
+	}
+}
+
+// Driver returns the backend of the database.
+func (db *DB) Driver() Driver {
+	return db.driver
+}
+
+// ExecContext runs a query written with ? placeholders, see Rebind.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return db.DB.ExecContext(ctx, db.Rebind(query), args...)
+}
+
+// QueryContext runs a query written with ? placeholders, see Rebind.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return db.DB.QueryContext(ctx, db.Rebind(query), args...)
+}
+
+// QueryRowContext runs a query written with ? placeholders, see Rebind.
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return db.DB.QueryRowContext(ctx, db.Rebind(query), args...)
+}
+
+// Rebind rewrites the ? placeholders of query into the numbered $1, $2, ...
+// Postgres expects. Queries are written once, in SQLite's syntax; question
+// marks inside string literals are left alone.
+func (db *DB) Rebind(query string) string {
+	if db.driver != DriverPostgres || !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	n, quoted := 0, false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == '?' && !quoted:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// JSONText returns an expression for the field of the JSON object in
+// column, as text: booleans are 1/0 on SQLite and true/false on Postgres.
+// It is NULL when the column holds no JSON, like event payloads stored as
+// an empty string.
+func (db *DB) JSONText(column, field string) string {
+	if db.driver == DriverPostgres {
+		return fmt.Sprintf("(CASE WHEN %s <> '' THEN %s::jsonb ->> '%s' END)", column, column, field)
+	}
+	return fmt.Sprintf("(CASE WHEN json_valid(%s) THEN CAST(json_extract(%s, '$.%s') AS TEXT) END)", column, column, field)
+}
+
+// JSONNumber returns an expression for the numeric field of the JSON object
+// in column, as sqlType (BIGINT or DOUBLE PRECISION). It is NULL when the
+// column holds no JSON or the object lacks the field.
+func (db *DB) JSONNumber(column, field, sqlType string) string {
+	if db.driver == DriverPostgres {
+		return fmt.Sprintf("(CASE WHEN %s <> '' THEN (%s::jsonb ->> '%s')::%s END)", column, column, field, sqlType)
+	}
+	return fmt.Sprintf("(CASE WHEN json_valid(%s) THEN json_extract(%s, '$.%s') END)", column, column, field)
+}
+
+// IsUniqueViolation reports whether err is a unique constraint violation.
+func IsUniqueViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "UNIQUE constraint failed") || // SQLite
+		strings.Contains(msg, "SQLSTATE 23505") // Postgres unique_violation
+}
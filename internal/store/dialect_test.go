@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	sqlite := &DB{driver: DriverSQLite}
+	postgres := &DB{driver: DriverPostgres}
+
+	query := `SELECT id FROM sessions WHERE phase = ? AND notes <> 'why?' AND id = ?`
+	if got := sqlite.Rebind(query); got != query {
+		t.Errorf("sqlite Rebind() = %q, want the query unchanged", got)
+	}
+	want := `SELECT id FROM sessions WHERE phase = $1 AND notes <> 'why?' AND id = $2`
+	if got := postgres.Rebind(query); got != want {
+		t.Errorf("postgres Rebind() = %q, want %q", got, want)
+	}
+}
+
+func TestJSONText(t *testing.T) {
+	db, err := OpenInMemory()
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		payload string
+		want    *string
+	}{
+		{`{"job_id":"job-1"}`, strPtr("job-1")},
+		{`{"other":1}`, nil},
+		{``, nil},
+	}
+	for _, tt := range tests {
+		var got *string
+		err := db.QueryRowContext(context.Background(), `SELECT `+db.JSONText("payload", "job_id")+` FROM (SELECT ? AS payload) AS events`, tt.payload).Scan(&got)
+		if err != nil {
+			t.Fatalf("JSONText(%q) error: %v", tt.payload, err)
+		}
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("JSONText(%q) = %v, want %v", tt.payload, got, tt.want)
+		}
+	}
+}
+
+func TestParseDriver(t *testing.T) {
+	for in, want := range map[string]Driver{"": DriverSQLite, "sqlite": DriverSQLite, "postgres": DriverPostgres} {
+		if got, err := ParseDriver(in); err != nil || got != want {
+			t.Errorf("ParseDriver(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseDriver("mysql"); err == nil {
+		t.Error("ParseDriver(mysql) succeeded, want an error")
+	}
+}
+
+func strPtr(s string) *string { return &s }
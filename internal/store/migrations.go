@@ -6,12 +6,22 @@ import (
 	"fmt"
 )
 
-// Migration represents a database schema migration.
+// Migration represents a database schema migration. Up and Down are
+// SQLite; Postgres replaces Up where Postgres needs different SQL.
 type Migration struct {
 	Version     int
 	Description string
 	Up          string
 	Down        string
+	Postgres    string
+}
+
+// upSQL returns the Up SQL of m for driver.
+func (m Migration) upSQL(driver Driver) string {
+	if driver == DriverPostgres && m.Postgres != "" {
+		return m.Postgres
+	}
+	return m.Up
 }
 
 // migrations is the ordered list of all database migrations.
@@ -46,6 +56,27 @@ var migrations = []Migration{
 			DROP INDEX IF EXISTS idx_sessions_repo;
 			DROP TABLE IF EXISTS sessions;
 		`,
+		Postgres: `
+			CREATE TABLE IF NOT EXISTS sessions (
+				id TEXT PRIMARY KEY,
+				repo_owner TEXT NOT NULL,
+				repo_name TEXT NOT NULL,
+				issue_number INTEGER NOT NULL,
+				pr_number INTEGER,
+				phase TEXT NOT NULL DEFAULT 'planning',
+				branch TEXT NOT NULL,
+				container_id TEXT,
+				plan_content TEXT,
+				error_message TEXT,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				last_activity TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				UNIQUE(repo_owner, repo_name, issue_number)
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_sessions_repo ON sessions(repo_owner, repo_name);
+			CREATE INDEX IF NOT EXISTS idx_sessions_phase ON sessions(phase);
+			CREATE INDEX IF NOT EXISTS idx_sessions_last_activity ON sessions(last_activity);
+		`,
 	},
 	{
 		Version:     2,
@@ -67,6 +98,18 @@ var migrations = []Migration{
 			DROP INDEX IF EXISTS idx_session_events_session;
 			DROP TABLE IF EXISTS session_events;
 		`,
+		Postgres: `
+			CREATE TABLE IF NOT EXISTS session_events (
+				id BIGSERIAL PRIMARY KEY,
+				session_id TEXT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+				event_type TEXT NOT NULL,
+				payload TEXT,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_session_events_session ON session_events(session_id);
+			CREATE INDEX IF NOT EXISTS idx_session_events_type ON session_events(event_type);
+		`,
 	},
 	{
 		Version:     3,
@@ -82,10 +125,387 @@ var migrations = []Migration{
 			DROP TABLE IF EXISTS schema_migrations;
 		`,
 	},
+	{
+		Version:     4,
+		Description: "Track pushed head SHA and paused automation on sessions",
+		Up: `
+			ALTER TABLE sessions ADD COLUMN head_sha TEXT;
+			ALTER TABLE sessions ADD COLUMN paused INTEGER NOT NULL DEFAULT 0;
+			CREATE INDEX IF NOT EXISTS idx_sessions_branch ON sessions(repo_owner, repo_name, branch);
+		`,
+		Down: `
+			DROP INDEX IF EXISTS idx_sessions_branch;
+			ALTER TABLE sessions DROP COLUMN paused;
+			ALTER TABLE sessions DROP COLUMN head_sha;
+		`,
+	},
+	{
+		Version:     5,
+		Description: "Track revision rounds and review latency on sessions",
+		Up: `
+			ALTER TABLE sessions ADD COLUMN revision_rounds INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE sessions ADD COLUMN awaiting_approval_seconds INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE sessions ADD COLUMN in_review_seconds INTEGER NOT NULL DEFAULT 0;
+		`,
+		Down: `
+			ALTER TABLE sessions DROP COLUMN in_review_seconds;
+			ALTER TABLE sessions DROP COLUMN awaiting_approval_seconds;
+			ALTER TABLE sessions DROP COLUMN revision_rounds;
+		`,
+	},
+	{
+		Version:     6,
+		Description: "Track plan revisions on sessions",
+		Up: `
+			ALTER TABLE sessions ADD COLUMN plan_revisions INTEGER NOT NULL DEFAULT 0;
+		`,
+		Down: `
+			ALTER TABLE sessions DROP COLUMN plan_revisions;
+		`,
+	},
+	{
+		// SQLite cannot add a CHECK constraint to an existing table, so
+		// triggers reject unknown phases instead; Postgres can. Sessions
+		// paused by the old flag keep their state as the paused phase.
+		Version:     7,
+		Description: "Replace the paused flag with paused and aborted phases",
+		Up: `
+			UPDATE sessions SET phase = 'paused'
+				WHERE paused = 1 AND phase IN ('awaiting_approval', 'in_review');
+			ALTER TABLE sessions DROP COLUMN paused;
+
+			CREATE TRIGGER IF NOT EXISTS sessions_phase_insert
+			BEFORE INSERT ON sessions
+			WHEN NEW.phase NOT IN ('planning', 'awaiting_approval', 'implementing', 'in_review',
+				'revising', 'paused', 'completed', 'error', 'aborted')
+			BEGIN
+				SELECT RAISE(ABORT, 'invalid session phase');
+			END;
+
+			CREATE TRIGGER IF NOT EXISTS sessions_phase_update
+			BEFORE UPDATE OF phase ON sessions
+			WHEN NEW.phase NOT IN ('planning', 'awaiting_approval', 'implementing', 'in_review',
+				'revising', 'paused', 'completed', 'error', 'aborted')
+			BEGIN
+				SELECT RAISE(ABORT, 'invalid session phase');
+			END;
+		`,
+		Down: `
+			DROP TRIGGER IF EXISTS sessions_phase_update;
+			DROP TRIGGER IF EXISTS sessions_phase_insert;
+			ALTER TABLE sessions ADD COLUMN paused INTEGER NOT NULL DEFAULT 0;
+			UPDATE sessions SET paused = 1, phase = 'in_review'
+				WHERE phase = 'paused' AND pr_number IS NOT NULL;
+			UPDATE sessions SET paused = 1, phase = 'awaiting_approval'
+				WHERE phase = 'paused';
+		`,
+		Postgres: `
+			UPDATE sessions SET phase = 'paused'
+				WHERE paused = 1 AND phase IN ('awaiting_approval', 'in_review');
+			ALTER TABLE sessions DROP COLUMN paused;
+
+			ALTER TABLE sessions ADD CONSTRAINT sessions_phase_check
+				CHECK (phase IN ('planning', 'awaiting_approval', 'implementing', 'in_review',
+					'revising', 'paused', 'completed', 'error', 'aborted'));
+		`,
+	},
+	{
+		Version:     8,
+		Description: "Store the plan approval checklist on sessions",
+		Up: `
+			ALTER TABLE sessions ADD COLUMN plan_checklist TEXT;
+		`,
+		Down: `
+			ALTER TABLE sessions DROP COLUMN plan_checklist;
+		`,
+	},
+	{
+		Version:     9,
+		Description: "Store the execution state of running phases on sessions",
+		Up: `
+			ALTER TABLE sessions ADD COLUMN execution TEXT;
+		`,
+		Down: `
+			ALTER TABLE sessions DROP COLUMN execution;
+		`,
+	},
+	{
+		Version:     10,
+		Description: "Create api_keys table",
+		Up: `
+			CREATE TABLE IF NOT EXISTS api_keys (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL,
+				scope TEXT NOT NULL CHECK (scope IN ('read', 'operator', 'admin')),
+				prefix TEXT NOT NULL,
+				token_hash TEXT NOT NULL UNIQUE,
+				created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				last_used_at TIMESTAMP,
+				revoked_at TIMESTAMP
+			);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS api_keys;
+		`,
+		Postgres: `
+			CREATE TABLE IF NOT EXISTS api_keys (
+				id BIGSERIAL PRIMARY KEY,
+				name TEXT NOT NULL,
+				scope TEXT NOT NULL CHECK (scope IN ('read', 'operator', 'admin')),
+				prefix TEXT NOT NULL,
+				token_hash TEXT NOT NULL UNIQUE,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				last_used_at TIMESTAMPTZ,
+				revoked_at TIMESTAMPTZ
+			);
+		`,
+	},
+	{
+		// Not tied to sessions by a foreign key: entries outlive deleted
+		// sessions and revoked keys.
+		Version:     11,
+		Description: "Create audit_log table",
+		Up: `
+			CREATE TABLE IF NOT EXISTS audit_log (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				actor_type TEXT NOT NULL,
+				actor TEXT NOT NULL DEFAULT '',
+				action TEXT NOT NULL,
+				target TEXT NOT NULL DEFAULT '',
+				details TEXT
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log(target);
+			CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+		`,
+		Down: `
+			DROP INDEX IF EXISTS idx_audit_log_created_at;
+			DROP INDEX IF EXISTS idx_audit_log_target;
+			DROP TABLE IF EXISTS audit_log;
+		`,
+		Postgres: `
+			CREATE TABLE IF NOT EXISTS audit_log (
+				id BIGSERIAL PRIMARY KEY,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				actor_type TEXT NOT NULL,
+				actor TEXT NOT NULL DEFAULT '',
+				action TEXT NOT NULL,
+				target TEXT NOT NULL DEFAULT '',
+				details TEXT
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log(target);
+			CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+		`,
+	},
+	{
+		// Replaces the session_id index: event queries filter and page by
+		// time within a session.
+		Version:     12,
+		Description: "Index session_events by session and time",
+		Up: `
+			CREATE INDEX IF NOT EXISTS idx_session_events_session_created ON session_events(session_id, created_at);
+			DROP INDEX IF EXISTS idx_session_events_session;
+		`,
+		Down: `
+			CREATE INDEX IF NOT EXISTS idx_session_events_session ON session_events(session_id);
+			DROP INDEX IF EXISTS idx_session_events_session_created;
+		`,
+	},
+	{
+		// Sessions and events are stored as JSON: archived rows are only
+		// read back whole. A session ID can be archived more than once when
+		// its issue is picked up again.
+		Version:     13,
+		Description: "Create archived_sessions table",
+		Up: `
+			CREATE TABLE IF NOT EXISTS archived_sessions (
+				archive_id INTEGER PRIMARY KEY AUTOINCREMENT,
+				id TEXT NOT NULL,
+				repo_owner TEXT NOT NULL,
+				repo_name TEXT NOT NULL,
+				issue_number INTEGER NOT NULL,
+				phase TEXT NOT NULL,
+				last_activity TIMESTAMP NOT NULL,
+				archived_at TIMESTAMP NOT NULL,
+				session TEXT NOT NULL,
+				events TEXT NOT NULL
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_archived_sessions_id ON archived_sessions(id);
+			CREATE INDEX IF NOT EXISTS idx_archived_sessions_repo ON archived_sessions(repo_owner, repo_name);
+		`,
+		Down: `
+			DROP INDEX IF EXISTS idx_archived_sessions_repo;
+			DROP INDEX IF EXISTS idx_archived_sessions_id;
+			DROP TABLE IF EXISTS archived_sessions;
+		`,
+		Postgres: `
+			CREATE TABLE IF NOT EXISTS archived_sessions (
+				archive_id BIGSERIAL PRIMARY KEY,
+				id TEXT NOT NULL,
+				repo_owner TEXT NOT NULL,
+				repo_name TEXT NOT NULL,
+				issue_number INTEGER NOT NULL,
+				phase TEXT NOT NULL,
+				last_activity TIMESTAMPTZ NOT NULL,
+				archived_at TIMESTAMPTZ NOT NULL,
+				session TEXT NOT NULL,
+				events TEXT NOT NULL
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_archived_sessions_id ON archived_sessions(id);
+			CREATE INDEX IF NOT EXISTS idx_archived_sessions_repo ON archived_sessions(repo_owner, repo_name);
+		`,
+	},
+	{
+		// Tickets and jobs live in files, so the index is a copy that
+		// search.Index keeps in sync; hash detects changed documents.
+		// Postgres has no FTS5 and indexes a tsvector instead.
+		Version:     14,
+		Description: "Create search_index table",
+		Up: `
+			CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(
+				kind UNINDEXED,
+				ref UNINDEXED,
+				project UNINDEXED,
+				hash UNINDEXED,
+				title,
+				body,
+				tokenize = 'porter unicode61'
+			);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS search_index;
+		`,
+		Postgres: `
+			CREATE TABLE IF NOT EXISTS search_index (
+				kind TEXT NOT NULL,
+				ref TEXT NOT NULL,
+				project TEXT NOT NULL DEFAULT '',
+				hash TEXT NOT NULL,
+				title TEXT NOT NULL,
+				body TEXT NOT NULL,
+				document tsvector GENERATED ALWAYS AS (to_tsvector('english', title || ' ' || body)) STORED,
+				PRIMARY KEY (kind, ref)
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_search_index_document ON search_index USING GIN (document);
+		`,
+	},
+	{
+		Version:     15,
+		Description: "Add the awaiting_answers session phase",
+		Up: `
+			DROP TRIGGER IF EXISTS sessions_phase_insert;
+			DROP TRIGGER IF EXISTS sessions_phase_update;
+
+			CREATE TRIGGER sessions_phase_insert
+			BEFORE INSERT ON sessions
+			WHEN NEW.phase NOT IN ('planning', 'awaiting_answers', 'awaiting_approval', 'implementing',
+				'in_review', 'revising', 'paused', 'completed', 'error', 'aborted')
+			BEGIN
+				SELECT RAISE(ABORT, 'invalid session phase');
+			END;
+
+			CREATE TRIGGER sessions_phase_update
+			BEFORE UPDATE OF phase ON sessions
+			WHEN NEW.phase NOT IN ('planning', 'awaiting_answers', 'awaiting_approval', 'implementing',
+				'in_review', 'revising', 'paused', 'completed', 'error', 'aborted')
+			BEGIN
+				SELECT RAISE(ABORT, 'invalid session phase');
+			END;
+		`,
+		Down: `
+			UPDATE sessions SET phase = 'error', error_message = 'clarifying questions are not supported by this version'
+				WHERE phase = 'awaiting_answers';
+
+			DROP TRIGGER IF EXISTS sessions_phase_insert;
+			DROP TRIGGER IF EXISTS sessions_phase_update;
+
+			CREATE TRIGGER sessions_phase_insert
+			BEFORE INSERT ON sessions
+			WHEN NEW.phase NOT IN ('planning', 'awaiting_approval', 'implementing', 'in_review',
+				'revising', 'paused', 'completed', 'error', 'aborted')
+			BEGIN
+				SELECT RAISE(ABORT, 'invalid session phase');
+			END;
+
+			CREATE TRIGGER sessions_phase_update
+			BEFORE UPDATE OF phase ON sessions
+			WHEN NEW.phase NOT IN ('planning', 'awaiting_approval', 'implementing', 'in_review',
+				'revising', 'paused', 'completed', 'error', 'aborted')
+			BEGIN
+				SELECT RAISE(ABORT, 'invalid session phase');
+			END;
+		`,
+		Postgres: `
+			ALTER TABLE sessions DROP CONSTRAINT sessions_phase_check;
+			ALTER TABLE sessions ADD CONSTRAINT sessions_phase_check
+				CHECK (phase IN ('planning', 'awaiting_answers', 'awaiting_approval', 'implementing',
+					'in_review', 'revising', 'paused', 'completed', 'error', 'aborted'));
+		`,
+	},
+	{
+		Version:     16,
+		Description: "Add the triaging and triaged session phases",
+		Up: `
+			DROP TRIGGER IF EXISTS sessions_phase_insert;
+			DROP TRIGGER IF EXISTS sessions_phase_update;
+
+			CREATE TRIGGER sessions_phase_insert
+			BEFORE INSERT ON sessions
+			WHEN NEW.phase NOT IN ('planning', 'awaiting_answers', 'awaiting_approval', 'implementing',
+				'in_review', 'revising', 'paused', 'completed', 'error', 'aborted', 'triaging', 'triaged')
+			BEGIN
+				SELECT RAISE(ABORT, 'invalid session phase');
+			END;
+
+			CREATE TRIGGER sessions_phase_update
+			BEFORE UPDATE OF phase ON sessions
+			WHEN NEW.phase NOT IN ('planning', 'awaiting_answers', 'awaiting_approval', 'implementing',
+				'in_review', 'revising', 'paused', 'completed', 'error', 'aborted', 'triaging', 'triaged')
+			BEGIN
+				SELECT RAISE(ABORT, 'invalid session phase');
+			END;
+		`,
+		Down: `
+			UPDATE sessions SET phase = 'error', error_message = 'issue triage is not supported by this version'
+				WHERE phase = 'triaging';
+			UPDATE sessions SET phase = 'completed'
+				WHERE phase = 'triaged';
+
+			DROP TRIGGER IF EXISTS sessions_phase_insert;
+			DROP TRIGGER IF EXISTS sessions_phase_update;
+
+			CREATE TRIGGER sessions_phase_insert
+			BEFORE INSERT ON sessions
+			WHEN NEW.phase NOT IN ('planning', 'awaiting_answers', 'awaiting_approval', 'implementing',
+				'in_review', 'revising', 'paused', 'completed', 'error', 'aborted')
+			BEGIN
+				SELECT RAISE(ABORT, 'invalid session phase');
+			END;
+
+			CREATE TRIGGER sessions_phase_update
+			BEFORE UPDATE OF phase ON sessions
+			WHEN NEW.phase NOT IN ('planning', 'awaiting_answers', 'awaiting_approval', 'implementing',
+				'in_review', 'revising', 'paused', 'completed', 'error', 'aborted')
+			BEGIN
+				SELECT RAISE(ABORT, 'invalid session phase');
+			END;
+		`,
+		Postgres: `
+			ALTER TABLE sessions DROP CONSTRAINT sessions_phase_check;
+			ALTER TABLE sessions ADD CONSTRAINT sessions_phase_check
+				CHECK (phase IN ('planning', 'awaiting_answers', 'awaiting_approval', 'implementing',
+					'in_review', 'revising', 'paused', 'completed', 'error', 'aborted', 'triaging', 'triaged'));
+		`,
+	},
 }
 
 // runMigrations applies all pending migrations to the database.
-func runMigrations(ctx context.Context, db *sql.DB) error {
+func runMigrations(ctx context.Context, db *DB) error {
 	// First ensure the migrations table exists (bootstrap)
 	_, err := db.ExecContext(ctx, `
 		CREATE TABLE IF NOT EXISTS schema_migrations (
@@ -129,13 +549,13 @@ func runMigrations(ctx context.Context, db *sql.DB) error {
 			return fmt.Errorf("begin transaction for migration %d: %w", m.Version, err)
 		}
 
-		if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+		if _, err := tx.ExecContext(ctx, m.upSQL(db.driver)); err != nil {
 			tx.Rollback()
 			return fmt.Errorf("apply migration %d (%s): %w", m.Version, m.Description, err)
 		}
 
 		if _, err := tx.ExecContext(ctx,
-			"INSERT INTO schema_migrations (version, description) VALUES (?, ?)",
+			db.Rebind("INSERT INTO schema_migrations (version, description) VALUES (?, ?)"),
 			m.Version, m.Description); err != nil {
 			tx.Rollback()
 			return fmt.Errorf("record migration %d: %w", m.Version, err)
@@ -0,0 +1,195 @@
+//go:build postgres
+
+package store_test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mpm/manfred/internal/apikey"
+	"github.com/mpm/manfred/internal/audit"
+	"github.com/mpm/manfred/internal/search"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/store"
+)
+
+// openPostgres connects to the server at MANFRED_TEST_POSTGRES_URL, in a
+// schema of its own that is dropped when the test ends.
+func openPostgres(t *testing.T) *store.DB {
+	t.Helper()
+	raw := os.Getenv("MANFRED_TEST_POSTGRES_URL")
+	if raw == "" {
+		t.Skip("MANFRED_TEST_POSTGRES_URL not set")
+	}
+	b := make([]byte, 4)
+	rand.Read(b)
+	schema := "manfred_test_" + hex.EncodeToString(b)
+
+	admin, err := store.OpenPostgres(raw)
+	if err != nil {
+		t.Fatalf("OpenPostgres() error = %v", err)
+	}
+	if _, err := admin.ExecContext(context.Background(), "CREATE SCHEMA "+schema); err != nil {
+		admin.Close()
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.ExecContext(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+		admin.Close()
+	})
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parse MANFRED_TEST_POSTGRES_URL: %v", err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	db, err := store.OpenPostgres(u.String())
+	if err != nil {
+		t.Fatalf("OpenPostgres() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestPostgresMigrate(t *testing.T) {
+	db := openPostgres(t)
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := db.Migrate(ctx); err != nil {
+			t.Fatalf("Migrate() run %d error = %v", i+1, err)
+		}
+	}
+	if got, err := store.CurrentVersion(ctx, db.DB); err != nil || got != store.LatestVersion() {
+		t.Errorf("CurrentVersion() = %d, %v, want %d", got, err, store.LatestVersion())
+	}
+}
+
+func TestPostgresSessions(t *testing.T) {
+	db := openPostgres(t)
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	sessions := session.NewSQLStore(db)
+
+	// The sessions_phase_check constraint must accept every phase
+	sess := session.NewSession("acme", "widgets", 7)
+	if err := sessions.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	for _, phase := range session.AllPhases() {
+		if phase.IsTerminal() {
+			continue
+		}
+		sess.Phase = phase
+		if err := sessions.Update(ctx, sess); err != nil {
+			t.Errorf("Update() to %s error = %v", phase, err)
+		}
+	}
+
+	// JSONText and JSONNumber lookups in event payloads and the checklist
+	high, migration := "high", true
+	sess.PlanChecklist = session.PlanChecklist{Risk: high, Migration: &migration}
+	if err := sessions.Update(ctx, sess); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := sessions.RecordEvent(ctx, sess.ID, session.EventTypeContainerStop, map[string]interface{}{
+		"job_id":            "job_1",
+		"peak_memory_bytes": 1 << 20,
+		"peak_cpu_percent":  42.5,
+	}); err != nil {
+		t.Fatalf("RecordEvent() error = %v", err)
+	}
+	if err := sessions.RecordEvent(ctx, sess.ID, session.EventTypeCommentPosted, nil); err != nil {
+		t.Fatalf("RecordEvent() without payload error = %v", err)
+	}
+	event, err := sessions.GetJobEvent(ctx, session.EventTypeContainerStop, "job_1")
+	if err != nil || event == nil || event.SessionID != sess.ID {
+		t.Errorf("GetJobEvent() = %+v, %v, want the container_stop event", event, err)
+	}
+	if ids, err := sessions.JobIDs(ctx, session.SessionFilter{}); err != nil || len(ids) != 1 || ids[0] != "job_1" {
+		t.Errorf("JobIDs() = %v, %v, want [job_1]", ids, err)
+	}
+	summary, err := sessions.MetricsSummary(ctx, session.SessionFilter{})
+	if err != nil {
+		t.Fatalf("MetricsSummary() error = %v", err)
+	}
+	if summary.PlanRisk["high"] != 1 || summary.Migrations != 1 || summary.Resources.MaxMemoryBytes != 1<<20 || summary.Resources.MaxCPUPercent != 42.5 {
+		t.Errorf("MetricsSummary() = %+v", summary)
+	}
+
+	// Archiving rebinds the queries it runs inside a transaction
+	sess.Phase = session.PhaseCompleted
+	if err := sessions.Update(ctx, sess); err != nil {
+		t.Fatalf("Update() to completed error = %v", err)
+	}
+	archived, err := sessions.Archive(ctx, session.ArchiveFilter{Before: time.Now().Add(time.Hour)}, false)
+	if err != nil || len(archived) != 1 {
+		t.Fatalf("Archive() = %d sessions, %v, want 1", len(archived), err)
+	}
+	list, err := sessions.ListArchived(ctx, session.SessionFilter{})
+	if err != nil || len(list) != 1 || len(list[0].Events) != 2 {
+		t.Errorf("ListArchived() = %+v, %v, want the session with 2 events", list, err)
+	}
+	if got, _ := sessions.Get(ctx, sess.ID); got != nil {
+		t.Errorf("Get() after Archive() = %+v, want nil", got)
+	}
+}
+
+func TestPostgresSearch(t *testing.T) {
+	db := openPostgres(t)
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	sessions := session.NewSQLStore(db)
+	sess := session.NewSession("acme", "widgets", 7)
+	plan := "Replace the polling loop in the importer with webhooks."
+	sess.PlanContent = &plan
+	if err := sessions.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	ix := search.NewIndex(db, dir+"/tickets", dir+"/jobs", sessions)
+	if _, err := ix.Sync(ctx); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	results, err := ix.Search(ctx, search.Query{Text: "importer webhook"})
+	if err != nil || len(results) != 1 || results[0].Ref != sess.ID {
+		t.Errorf("Search() = %+v, %v, want the session", results, err)
+	}
+}
+
+func TestPostgresKeysAndAudit(t *testing.T) {
+	db := openPostgres(t)
+	ctx := context.Background()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	keys := apikey.NewSQLStore(db)
+	key, token, err := keys.Create(ctx, "ci", apikey.ScopeRead)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if got, err := keys.Authenticate(ctx, token); err != nil || got == nil || got.ID != key.ID {
+		t.Errorf("Authenticate() = %+v, %v, want key %d", got, err, key.ID)
+	}
+
+	logs := audit.NewSQLStore(db)
+	entry := &audit.Entry{Actor: audit.Actor{Type: audit.ActorCLI, Name: "alice"}, Action: "session.abort", Target: "s1", Details: map[string]string{"reason": "test"}}
+	if err := logs.Record(ctx, entry); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if got, err := logs.List(ctx, audit.Filter{Action: "session.abort"}); err != nil || len(got) != 1 || got[0].Details["reason"] != "test" {
+		t.Errorf("List() = %+v, %v, want the entry", got, err)
+	}
+}
@@ -0,0 +1,203 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errSQLiteOnly is returned by the operations on the database file when
+// the database is Postgres, which is backed up with its own tools.
+var errSQLiteOnly = errors.New("only supported with the sqlite database driver; use pg_dump or your provider's backups for Postgres")
+
+// Checkpoint modes accepted by Checkpoint, see
+// https://www.sqlite.org/pragma.html#pragma_wal_checkpoint.
+var checkpointModes = map[string]bool{
+	"PASSIVE":  true,
+	"FULL":     true,
+	"RESTART":  true,
+	"TRUNCATE": true,
+}
+
+// CheckpointResult reports the outcome of a WAL checkpoint.
+type CheckpointResult struct {
+	Busy         bool // The checkpoint could not finish, e.g. a reader held the WAL
+	WALPages     int  // Pages in the WAL
+	Checkpointed int  // Pages copied back into the database
+}
+
+// Checkpoint copies the WAL back into the database file. mode is one of
+// PASSIVE, FULL, RESTART or TRUNCATE; empty means PASSIVE.
+func (db *DB) Checkpoint(ctx context.Context, mode string) (*CheckpointResult, error) {
+	mode = strings.ToUpper(mode)
+	if mode == "" {
+		mode = "PASSIVE"
+	}
+	if !checkpointModes[mode] {
+		return nil, fmt.Errorf("unknown checkpoint mode %q (want passive, full, restart or truncate)", mode)
+	}
+	if db.driver != DriverSQLite {
+		return nil, errSQLiteOnly
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var busy int
+	var result CheckpointResult
+	row := db.QueryRowContext(ctx, "PRAGMA wal_checkpoint("+mode+")")
+	if err := row.Scan(&busy, &result.WALPages, &result.Checkpointed); err != nil {
+		return nil, fmt.Errorf("checkpoint database: %w", err)
+	}
+	result.Busy = busy != 0
+	return &result, nil
+}
+
+// Backup writes a consistent copy of the database to a local file with
+// VACUUM INTO. Writers are not blocked while the copy is made, and the
+// copy has no WAL, so it is usable on its own. An existing file at dest is
+// replaced atomically.
+func (db *DB) Backup(ctx context.Context, dest string) error {
+	if db.driver != DriverSQLite {
+		return errSQLiteOnly
+	}
+	dir := filepath.Dir(dest)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create backup directory: %w", err)
+	}
+
+	// VACUUM INTO refuses to overwrite, so write next to dest and rename
+	tmp := filepath.Join(dir, fmt.Sprintf(".%s.%d.tmp", filepath.Base(dest), time.Now().UnixNano()))
+	defer os.Remove(tmp)
+
+	db.mu.RLock()
+	_, err := db.ExecContext(ctx, "VACUUM INTO ?", tmp)
+	db.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("back up database: %w", err)
+	}
+
+	if err := os.Rename(tmp, dest); err != nil {
+		return fmt.Errorf("back up database: %w", err)
+	}
+	return nil
+}
+
+// Replicate writes a backup of the database to dest. Destinations starting
+// with s3:// are uploaded with the aws CLI; anything else is a local path.
+func (db *DB) Replicate(ctx context.Context, dest string) error {
+	if !strings.HasPrefix(dest, "s3://") {
+		return db.Backup(ctx, dest)
+	}
+
+	tmp, err := os.MkdirTemp("", "manfred-replica-")
+	if err != nil {
+		return fmt.Errorf("create replica directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	file := filepath.Join(tmp, "manfred.db")
+	if err := db.Backup(ctx, file); err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "aws", "s3", "cp", "--only-show-errors", file, dest)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("upload replica to %s: %w\n%s", dest, err, output)
+	}
+	return nil
+}
+
+// RunReplication replicates the database to dest every interval until ctx
+// is cancelled, then once more so the replica holds the final state.
+// Errors are passed to onError and do not stop the loop.
+func (db *DB) RunReplication(ctx context.Context, dest string, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// ctx is done, so the final copy gets its own deadline
+			final, cancel := context.WithTimeout(context.Background(), time.Minute)
+			err := db.Replicate(final, dest)
+			cancel()
+			if err != nil && onError != nil {
+				onError(err)
+			}
+			return
+		case <-ticker.C:
+		}
+
+		if err := db.Replicate(ctx, dest); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+// Restore copies a replica written by Replicate to path. It refuses to
+// replace an existing database unless force is set; the database must not
+// be open while it is restored. A missing replica is reported as
+// os.ErrNotExist.
+func Restore(ctx context.Context, src, path string, force bool) error {
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("database %s already exists", path)
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("check database: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create database directory: %w", err)
+	}
+	tmp := filepath.Join(dir, fmt.Sprintf(".%s.%d.restore", filepath.Base(path), time.Now().UnixNano()))
+	defer os.Remove(tmp)
+
+	if strings.HasPrefix(src, "s3://") {
+		cmd := exec.CommandContext(ctx, "aws", "s3", "cp", "--only-show-errors", src, tmp)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			if strings.Contains(string(output), "(404)") {
+				return fmt.Errorf("download replica from %s: %w", src, os.ErrNotExist)
+			}
+			return fmt.Errorf("download replica from %s: %w\n%s", src, err, output)
+		}
+	} else if err := copyFile(src, tmp); err != nil {
+		return fmt.Errorf("copy replica from %s: %w", src, err)
+	}
+
+	// A WAL left over from the replaced database would be replayed into
+	// the restored one
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(path + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("remove %s: %w", path+suffix, err)
+		}
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("restore database: %w", err)
+	}
+	return nil
+}
+
+// copyFile copies the file src to dest.
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+	replica := filepath.Join(dir, "replica.db")
+	path := filepath.Join(dir, "data", "manfred.db")
+	if err := os.WriteFile(replica, []byte("replica"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Restore(context.Background(), filepath.Join(dir, "missing.db"), path, false); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Restore(missing replica) error = %v, want os.ErrNotExist", err)
+	}
+
+	if err := Restore(context.Background(), replica, path, false); err != nil {
+		t.Fatalf("Restore() error: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "replica" {
+		t.Errorf("restored database = %q, want %q", data, "replica")
+	}
+
+	if err := Restore(context.Background(), replica, path, false); err == nil {
+		t.Error("Restore() replaced an existing database without force")
+	}
+
+	// A stale WAL must not be replayed into the restored database
+	if err := os.WriteFile(path+"-wal", []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Restore(context.Background(), replica, path, true); err != nil {
+		t.Fatalf("Restore(force) error: %v", err)
+	}
+	if _, err := os.Stat(path + "-wal"); !os.IsNotExist(err) {
+		t.Errorf("stale WAL still exists: %v", err)
+	}
+}
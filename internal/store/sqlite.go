@@ -12,16 +12,33 @@ import (
 	_ "modernc.org/sqlite"
 )
 
-// DB wraps a SQLite database connection with Manfred-specific configuration.
+// DB wraps a SQLite or Postgres database connection with Manfred-specific
+// configuration.
 type DB struct {
 	*sql.DB
-	path string
-	mu   sync.RWMutex
+	driver Driver
+	path   string
+	mu     sync.RWMutex
+
+	externalCheckpoints bool
+}
+
+// Option configures a database opened with Open.
+type Option func(*DB)
+
+// WithExternalCheckpoints leaves WAL checkpoints to an external replicator
+// such as litestream: automatic checkpoints are disabled and Close does not
+// truncate the WAL, so no frame is folded into the database before the
+// replicator has copied it.
+func WithExternalCheckpoints() Option {
+	return func(db *DB) {
+		db.externalCheckpoints = true
+	}
 }
 
 // Open creates or opens a SQLite database at the specified path.
 // It configures the database with WAL mode for better concurrency.
-func Open(path string) (*DB, error) {
+func Open(path string, opts ...Option) (*DB, error) {
 	// Ensure directory exists
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -45,6 +62,13 @@ func Open(path string) (*DB, error) {
 		"PRAGMA busy_timeout=5000",
 		"PRAGMA synchronous=NORMAL",
 	}
+	result := &DB{driver: DriverSQLite, path: path}
+	for _, opt := range opts {
+		opt(result)
+	}
+	if result.externalCheckpoints {
+		pragmas = append(pragmas, "PRAGMA wal_autocheckpoint=0")
+	}
 
 	for _, pragma := range pragmas {
 		if _, err := db.Exec(pragma); err != nil {
@@ -53,10 +77,8 @@ func Open(path string) (*DB, error) {
 		}
 	}
 
-	return &DB{
-		DB:   db,
-		path: path,
-	}, nil
+	result.DB = db
+	return result, nil
 }
 
 // OpenInMemory creates an in-memory SQLite database for testing.
@@ -73,12 +95,13 @@ func OpenInMemory() (*DB, error) {
 	}
 
 	return &DB{
-		DB:   db,
-		path: ":memory:",
+		DB:     db,
+		driver: DriverSQLite,
+		path:   ":memory:",
 	}, nil
 }
 
-// Path returns the database file path.
+// Path returns the database file path, empty for Postgres.
 func (db *DB) Path() string {
 	return db.path
 }
@@ -88,8 +111,8 @@ func (db *DB) Close() error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	// Checkpoint WAL before closing
-	if db.path != ":memory:" {
+	// Checkpoint WAL before closing, unless a replicator owns checkpoints
+	if db.driver == DriverSQLite && db.path != ":memory:" && !db.externalCheckpoints {
 		_, _ = db.DB.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
 	}
 
@@ -101,10 +124,11 @@ func (db *DB) Migrate(ctx context.Context) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	return runMigrations(ctx, db.DB)
+	return runMigrations(ctx, db)
 }
 
-// Transaction executes a function within a database transaction.
+// Transaction executes a function within a database transaction. Queries
+// on the transaction are not rebound; write them with db.Rebind.
 func (db *DB) Transaction(ctx context.Context, fn func(*sql.Tx) error) error {
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
@@ -2,15 +2,28 @@ package ticket
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
 	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/notify"
 )
 
+// IssueClient is the part of the GitHub client the processor uses to report
+// results on the issues tickets were imported from.
+type IssueClient interface {
+	AddIssueComment(ctx context.Context, owner, repo string, number int, body string) (*github.Comment, error)
+	CloseIssue(ctx context.Context, owner, repo string, number int) error
+}
+
 // Processor handles ticket-to-job orchestration.
 type Processor struct {
-	config *config.Config
+	config       *config.Config
+	issues       IssueClient
+	pullRequests job.PullRequestClient
 }
 
 // NewProcessor creates a new ticket processor.
@@ -18,6 +31,18 @@ func NewProcessor(cfg *config.Config) *Processor {
 	return &Processor{config: cfg}
 }
 
+// SetPullRequestClient sets the client used to open the pull requests of
+// jobs of projects with several repositories.
+func (p *Processor) SetPullRequestClient(c job.PullRequestClient) {
+	p.pullRequests = c
+}
+
+// SetIssueClient sets the client used to sync completed tickets back to
+// their GitHub issues when tickets.sync_issues is on.
+func (p *Processor) SetIssueClient(c IssueClient) {
+	p.issues = c
+}
+
 // Process processes a ticket by running it as a job.
 // If ticketID is empty, processes the next pending ticket.
 // Returns the updated ticket after processing.
@@ -51,8 +76,8 @@ func (p *Processor) Process(ctx context.Context, project string, ticketID string
 		return nil, fmt.Errorf("ticket %s is not pending (status: %s)", ticket.ID, ticket.Status)
 	}
 
-	// Get the prompt content
-	prompt := ticket.PromptContent()
+	// Get the prompt content with its follow-ups
+	prompt := ticket.JobPrompt()
 	if prompt == "" {
 		return nil, fmt.Errorf("ticket %s has no prompt content", ticket.ID)
 	}
@@ -67,33 +92,64 @@ func (p *Processor) Process(ctx context.Context, project string, ticketID string
 	runner, err := job.NewRunner(p.config)
 	if err != nil {
 		ticket.Status = StatusError
-		ticket.AddEntry(EntryTypeComment, "manfred", fmt.Sprintf("Failed to create job runner: %v", err))
+		ticket.AddEntry(EntryTypeComment, AuthorManfred, fmt.Sprintf("Failed to create job runner: %v", err))
 		store.Update(ctx, ticket)
 		return ticket, fmt.Errorf("failed to create job runner: %w", err)
 	}
 	defer runner.Close()
 
-	j, err := runner.Run(ctx, project, prompt)
+	// A ticket on an existing branch pushes its work back to it, one
+	// imported from an issue to a new branch the issue can link
+	j, err := runner.RunWithOptions(ctx, project, prompt, job.RunOptions{
+		Branch:       ticket.Branch,
+		Push:         ticket.Branch != "" || ticket.Issue != "",
+		Notify:       notify.Notification{CreatedBy: ticket.CreatedBy},
+		PullRequests: p.pullRequests,
+	})
+	var duplicate *job.DuplicateError
+	if errors.As(err, &duplicate) {
+		ticket.Status = StatusError
+		ticket.DuplicateOf = duplicate.JobID
+		ticket.AddEntry(EntryTypeComment, AuthorManfred, fmt.Sprintf("Skipped: %v", err))
+		store.Update(ctx, ticket)
+		return ticket, fmt.Errorf("skipped: %w", err)
+	}
 	if err != nil {
 		ticket.Status = StatusError
-		ticket.AddEntry(EntryTypeComment, "manfred", fmt.Sprintf("Job failed: %v", err))
+		ticket.AddEntry(EntryTypeComment, AuthorManfred, fmt.Sprintf("Job failed: %v", err))
 		store.Update(ctx, ticket)
 		return ticket, fmt.Errorf("job failed: %w", err)
 	}
 
 	// Update ticket with job results
 	ticket.JobID = j.ID
+	ticket.InputHash = j.InputHash
+	ticket.DuplicateOf = j.DuplicateOf
 
 	if j.Status == job.StatusCompleted {
 		ticket.Status = StatusCompleted
 		comment := fmt.Sprintf("Job completed: %s", j.ID)
+		if j.Pushed {
+			comment += fmt.Sprintf("\nPushed to branch %s", j.BranchName)
+		}
+		if j.TestResult != nil {
+			if j.TestResult.Passed {
+				comment += "\nTests: passed"
+			} else {
+				comment += "\nTests: failed"
+			}
+		}
 		if j.CommitMessage != "" {
 			comment += fmt.Sprintf("\n\nCommit message:\n%s", j.CommitMessage)
 		}
-		ticket.AddEntry(EntryTypeComment, "manfred", comment)
+		if j.DuplicateOf != "" {
+			comment += fmt.Sprintf("\nDuplicate of job %s", j.DuplicateOf)
+		}
+		ticket.AddEntry(EntryTypeComment, AuthorManfred, comment)
+		p.syncIssue(ctx, ticket, j)
 	} else {
 		ticket.Status = StatusError
-		ticket.AddEntry(EntryTypeComment, "manfred", fmt.Sprintf("Job failed: %s\nError: %s", j.ID, j.Error))
+		ticket.AddEntry(EntryTypeComment, AuthorManfred, fmt.Sprintf("Job failed: %s\nError: %s", j.ID, j.Error))
 	}
 
 	if err := store.Update(ctx, ticket); err != nil {
@@ -102,3 +158,90 @@ func (p *Processor) Process(ctx context.Context, project string, ticketID string
 
 	return ticket, nil
 }
+
+// syncIssue comments the result of a completed ticket's job on the issue it
+// was imported from, and closes it if tickets.close_issues is on and the
+// job pushed its work without failing tests; otherwise the issue stays open.
+// Failures are noted on the ticket; they do not fail it.
+func (p *Processor) syncIssue(ctx context.Context, ticket *Ticket, j *job.Job) {
+	if ticket.Issue == "" || !p.config.Tickets.SyncIssues || p.issues == nil {
+		return
+	}
+	owner, repo, number, err := ParseIssueRef(ticket.Issue)
+	if err == nil {
+		_, err = p.issues.AddIssueComment(ctx, owner, repo, number, IssueResultComment(ticket, j))
+	}
+	closing := p.config.Tickets.CloseIssues && delivered(j)
+	if err == nil && closing {
+		err = p.issues.CloseIssue(ctx, owner, repo, number)
+	}
+	if err != nil {
+		ticket.AddEntry(EntryTypeComment, AuthorManfred, fmt.Sprintf("Failed to sync issue %s: %v", ticket.Issue, err))
+		return
+	}
+	comment := fmt.Sprintf("Posted the result on issue %s", ticket.Issue)
+	switch {
+	case closing:
+		comment += " and closed it"
+	case p.config.Tickets.CloseIssues:
+		comment += " and left it open, since the work was not pushed or its tests failed"
+	}
+	ticket.AddEntry(EntryTypeComment, AuthorManfred, comment)
+}
+
+// delivered reports whether a job pushed its work and its tests, if any,
+// passed.
+func delivered(j *job.Job) bool {
+	if j.TestResult != nil && !j.TestResult.Passed {
+		return false
+	}
+	if j.Pushed {
+		return true
+	}
+	for _, r := range j.Repos {
+		if r.Pushed {
+			return true
+		}
+	}
+	return false
+}
+
+// IssueResultComment returns the comment reporting a ticket's completed job
+// on the issue it was imported from.
+func IssueResultComment(ticket *Ticket, j *job.Job) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "MANFRED completed ticket `%s` in job `%s`.\n", ticket.ID, j.ID)
+	owner, repo, _, err := ParseIssueRef(ticket.Issue)
+	if j.Pushed {
+		if err == nil {
+			fmt.Fprintf(&b, "\nPushed to branch [`%s`](https://github.com/%s/%s/tree/%s).\n", j.BranchName, owner, repo, j.BranchName)
+		} else {
+			fmt.Fprintf(&b, "\nPushed to branch `%s`.\n", j.BranchName)
+		}
+	} else if len(j.Repos) == 0 {
+		b.WriteString("\nNothing was pushed, so the work is only in the job's workspace.\n")
+	}
+	if ticket.PullRequest != "" {
+		if prOwner, prRepo, n, err := ParsePullRequestRef(ticket.PullRequest, ""); err == nil {
+			fmt.Fprintf(&b, "\nPull request: https://github.com/%s/%s/pull/%d\n", prOwner, prRepo, n)
+		} else {
+			fmt.Fprintf(&b, "\nPull request: %s\n", ticket.PullRequest)
+		}
+	}
+	for _, r := range j.Repos {
+		if r.PullRequest != "" {
+			fmt.Fprintf(&b, "\nPull request for %s: %s\n", r.Name, r.PullRequest)
+		}
+	}
+	if j.TestResult != nil {
+		if j.TestResult.Passed {
+			b.WriteString("\nTests: passed\n")
+		} else {
+			b.WriteString("\nTests: failed\n")
+		}
+	}
+	if j.CommitMessage != "" {
+		fmt.Fprintf(&b, "\n<details><summary>Commit message</summary>\n\n```\n%s\n```\n\n</details>\n", strings.TrimSpace(j.CommitMessage))
+	}
+	return b.String()
+}
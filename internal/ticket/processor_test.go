@@ -0,0 +1,103 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/job"
+)
+
+type fakeIssueClient struct {
+	comments []string
+	closed   []int
+	err      error
+}
+
+func (f *fakeIssueClient) AddIssueComment(ctx context.Context, owner, repo string, number int, body string) (*github.Comment, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.comments = append(f.comments, owner+"/"+repo+": "+body)
+	return &github.Comment{}, nil
+}
+
+func (f *fakeIssueClient) CloseIssue(ctx context.Context, owner, repo string, number int) error {
+	f.closed = append(f.closed, number)
+	return nil
+}
+
+func TestProcessorSyncIssue(t *testing.T) {
+	j := &job.Job{ID: "job_1", Pushed: true, BranchName: "manfred/job_1", CommitMessage: "Fix login\n"}
+	unpushed := &job.Job{ID: "job_1", CommitMessage: "Fix login\n"}
+	failing := &job.Job{ID: "job_1", Pushed: true, BranchName: "manfred/job_1", TestResult: &job.TestResult{Passed: false}}
+	leftOpen := "Posted the result on issue acme/widgets#4 and left it open, since the work was not pushed or its tests failed"
+	syncAndClose := config.TicketsConfig{SyncIssues: true, CloseIssues: true}
+
+	tests := []struct {
+		name        string
+		issue       string
+		job         *job.Job
+		cfg         config.TicketsConfig
+		clientErr   error
+		wantComment bool
+		wantClosed  bool
+		wantEntry   string
+	}{
+		{"sync and close", "acme/widgets#4", j, syncAndClose, nil, true, true, "Posted the result on issue acme/widgets#4 and closed it"},
+		{"sync only", "acme/widgets#4", j, config.TicketsConfig{SyncIssues: true}, nil, true, false, "Posted the result on issue acme/widgets#4"},
+		{"not pushed", "acme/widgets#4", unpushed, syncAndClose, nil, true, false, leftOpen},
+		{"tests failed", "acme/widgets#4", failing, syncAndClose, nil, true, false, leftOpen},
+		{"disabled", "acme/widgets#4", j, config.TicketsConfig{CloseIssues: true}, nil, false, false, ""},
+		{"not imported", "", j, syncAndClose, nil, false, false, ""},
+		{"failure", "acme/widgets#4", j, syncAndClose, errors.New("forbidden"), false, false, "Failed to sync issue acme/widgets#4: forbidden"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeIssueClient{err: tt.clientErr}
+			p := NewProcessor(&config.Config{Tickets: tt.cfg})
+			p.SetIssueClient(client)
+			tk := &Ticket{ID: "ticket_1", Issue: tt.issue}
+
+			p.syncIssue(context.Background(), tk, tt.job)
+
+			if got := len(client.comments) == 1; got != tt.wantComment {
+				t.Errorf("commented = %v, want %v", got, tt.wantComment)
+			}
+			if tt.wantComment && !strings.HasPrefix(client.comments[0], "acme/widgets: MANFRED completed ticket `ticket_1` in job `job_1`") {
+				t.Errorf("comment = %q", client.comments[0])
+			}
+			if got := len(client.closed) == 1 && client.closed[0] == 4; got != tt.wantClosed {
+				t.Errorf("closed = %v, want %v", client.closed, tt.wantClosed)
+			}
+			var entry string
+			if len(tk.Entries) > 0 {
+				entry = tk.Entries[len(tk.Entries)-1].Content
+			}
+			if entry != tt.wantEntry {
+				t.Errorf("ticket entry = %q, want %q", entry, tt.wantEntry)
+			}
+		})
+	}
+}
+
+func TestIssueResultComment(t *testing.T) {
+	tk := &Ticket{ID: "ticket_1", Issue: "acme/widgets#4", PullRequest: "acme/widgets#9"}
+	got := IssueResultComment(tk, &job.Job{ID: "job_1", Pushed: true, BranchName: "manfred/job_1"})
+	for _, want := range []string{
+		"https://github.com/acme/widgets/tree/manfred/job_1",
+		"https://github.com/acme/widgets/pull/9",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("IssueResultComment() = %q, want %q", got, want)
+		}
+	}
+
+	got = IssueResultComment(&Ticket{ID: "ticket_1", Issue: "acme/widgets#4"}, &job.Job{ID: "job_1"})
+	if !strings.Contains(got, "Nothing was pushed") {
+		t.Errorf("IssueResultComment() = %q, want a note that nothing was pushed", got)
+	}
+}
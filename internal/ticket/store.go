@@ -14,7 +14,7 @@ import (
 type Store interface {
 	List(ctx context.Context, status *Status) ([]Ticket, error)
 	Get(ctx context.Context, id string) (*Ticket, error)
-	Create(ctx context.Context, prompt string) (*Ticket, error)
+	Create(ctx context.Context, prompt string, opts CreateOptions) (*Ticket, error)
 	Update(ctx context.Context, ticket *Ticket) error
 	Stats(ctx context.Context) (map[Status]int, error)
 	NextPending(ctx context.Context) (*Ticket, error)
@@ -73,14 +73,15 @@ func (s *FileStore) Get(ctx context.Context, id string) (*Ticket, error) {
 	return nil, nil
 }
 
-// Create creates a new ticket with the given prompt.
-func (s *FileStore) Create(ctx context.Context, prompt string) (*Ticket, error) {
+// Create creates a new ticket with the given prompt. Its creator is also the
+// author of the prompt entry.
+func (s *FileStore) Create(ctx context.Context, prompt string, opts CreateOptions) (*Ticket, error) {
 	if err := s.ensureDirectories(); err != nil {
 		return nil, err
 	}
 
-	ticket := New(s.project)
-	ticket.AddEntry(EntryTypePrompt, "user", prompt)
+	ticket := New(s.project, opts)
+	ticket.AddEntry(EntryTypePrompt, ticket.Creator(), prompt)
 
 	if err := s.saveTicket(ticket); err != nil {
 		return nil, err
@@ -0,0 +1,28 @@
+package ticket
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFileStoreCreateAttribution(t *testing.T) {
+	store := NewFileStore(t.TempDir(), "widgets")
+	ctx := context.Background()
+
+	created, err := store.Create(ctx, "Fix the login form", CreateOptions{CreatedBy: "alice"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	got, err := store.Get(ctx, created.ID)
+	if err != nil || got == nil {
+		t.Fatalf("Get() = %v, %v", got, err)
+	}
+	if got.CreatedBy != "alice" || got.Entries[0].Author != "alice" {
+		t.Errorf("CreatedBy = %q, prompt author = %q; want alice", got.CreatedBy, got.Entries[0].Author)
+	}
+
+	legacy := &Ticket{}
+	if legacy.Creator() != "user" {
+		t.Errorf("Creator() of a ticket without created_by = %q, want user", legacy.Creator())
+	}
+}
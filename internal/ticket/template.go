@@ -0,0 +1,100 @@
+package ticket
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/mpm/manfred/internal/config"
+)
+
+// templateExt is the file extension of ticket templates.
+const templateExt = ".md"
+
+// TemplateDirs returns the directories searched for a project's ticket
+// templates, highest precedence first: templates/ in the project directory,
+// then .manfred/templates/ in the project's repository.
+func TemplateDirs(cfg *config.Config, project string) []string {
+	return []string{
+		filepath.Join(cfg.ProjectsDir, project, "templates"),
+		filepath.Join(cfg.ProjectRepositoryPath(project), ".manfred", "templates"),
+	}
+}
+
+// FindTemplate returns the path of the named template in the first
+// directory that has it.
+func FindTemplate(dirs []string, name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid template name: %q", name)
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name+templateExt)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("template not found: %s (looked in %s)", name, strings.Join(dirs, ", "))
+}
+
+// ListTemplates returns the names of the templates in dirs, sorted.
+func ListTemplates(dirs []string) ([]string, error) {
+	seen := map[string]bool{}
+	var names []string
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read templates: %w", err)
+		}
+		for _, e := range entries {
+			name, ok := strings.CutSuffix(e.Name(), templateExt)
+			if e.IsDir() || !ok || seen[name] {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// RenderTemplate loads a template file and fills in its placeholders, e.g.
+// {{.issue}}, from vars. A placeholder without a value is an error.
+func RenderTemplate(path string, vars map[string]string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read template: %w", err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), templateExt)
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, vars); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// ParseVars parses key=value pairs as given to --var.
+func ParseVars(pairs []string) (map[string]string, error) {
+	vars := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid variable %q, want key=value", pair)
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
@@ -0,0 +1,81 @@
+package ticket
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTemplate(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+templateExt), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	local := filepath.Join(t.TempDir(), "templates")
+	repo := filepath.Join(t.TempDir(), ".manfred", "templates")
+	dirs := []string{local, repo}
+
+	writeTemplate(t, repo, "bugfix", "Fix issue #{{.issue}}.\n\n{{.prompt}}\n")
+	writeTemplate(t, repo, "feature", "Repo feature template")
+	writeTemplate(t, local, "feature", "Local feature template")
+
+	names, err := ListTemplates(dirs)
+	if err != nil {
+		t.Fatalf("ListTemplates() error: %v", err)
+	}
+	if strings.Join(names, ",") != "bugfix,feature" {
+		t.Errorf("ListTemplates() = %v, want [bugfix feature]", names)
+	}
+
+	path, err := FindTemplate(dirs, "feature")
+	if err != nil {
+		t.Fatalf("FindTemplate() error: %v", err)
+	}
+	if filepath.Dir(path) != local {
+		t.Errorf("FindTemplate() = %s, want the project directory template", path)
+	}
+
+	path, err = FindTemplate(dirs, "bugfix")
+	if err != nil {
+		t.Fatalf("FindTemplate() error: %v", err)
+	}
+	got, err := RenderTemplate(path, map[string]string{"issue": "123", "prompt": "Login fails."})
+	if err != nil {
+		t.Fatalf("RenderTemplate() error: %v", err)
+	}
+	if want := "Fix issue #123.\n\nLogin fails."; got != want {
+		t.Errorf("RenderTemplate() = %q, want %q", got, want)
+	}
+
+	if _, err := RenderTemplate(path, map[string]string{"issue": "123"}); err == nil {
+		t.Error("RenderTemplate() with a missing variable succeeded, want error")
+	}
+	for _, name := range []string{"missing", "../bugfix", ""} {
+		if _, err := FindTemplate(dirs, name); err == nil {
+			t.Errorf("FindTemplate(%q) succeeded, want error", name)
+		}
+	}
+}
+
+func TestParseVars(t *testing.T) {
+	vars, err := ParseVars([]string{"issue=123", "title=a=b", "empty="})
+	if err != nil {
+		t.Fatalf("ParseVars() error: %v", err)
+	}
+	if vars["issue"] != "123" || vars["title"] != "a=b" || vars["empty"] != "" {
+		t.Errorf("ParseVars() = %v", vars)
+	}
+
+	for _, bad := range []string{"issue", "=123"} {
+		if _, err := ParseVars([]string{bad}); err == nil {
+			t.Errorf("ParseVars(%q) succeeded, want error", bad)
+		}
+	}
+}
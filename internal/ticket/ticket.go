@@ -4,6 +4,9 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"os"
+	"os/user"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -16,11 +19,12 @@ const (
 	StatusInProgress Status = "in_progress"
 	StatusError      Status = "error"
 	StatusCompleted  Status = "completed"
+	StatusCancelled  Status = "cancelled" // Abandoned before it was processed
 )
 
 // AllStatuses returns all valid ticket statuses.
 func AllStatuses() []Status {
-	return []Status{StatusPending, StatusInProgress, StatusError, StatusCompleted}
+	return []Status{StatusPending, StatusInProgress, StatusError, StatusCompleted, StatusCancelled}
 }
 
 // EntryType represents the type of a ticket entry.
@@ -29,8 +33,15 @@ type EntryType string
 const (
 	EntryTypePrompt  EntryType = "prompt"
 	EntryTypeComment EntryType = "comment"
+
+	// EntryTypeFollowUp refines the prompt; jobs get it appended
+	EntryTypeFollowUp EntryType = "follow_up"
 )
 
+// AuthorManfred is the author of the comments MANFRED adds to tickets, such
+// as job results.
+const AuthorManfred = "manfred"
+
 // Entry represents a prompt or comment on a ticket.
 type Entry struct {
 	Type      EntryType `yaml:"type"`
@@ -45,19 +56,82 @@ type Ticket struct {
 	Project   string    `yaml:"project"`
 	Status    Status    `yaml:"status"`
 	CreatedAt time.Time `yaml:"created_at"`
+	CreatedBy string    `yaml:"created_by,omitempty"` // OS user or API caller who created the ticket
 	JobID     string    `yaml:"job_id,omitempty"`
 	Entries   []Entry   `yaml:"entries"`
+
+	// Branch is an existing branch the job checks out and pushes back to
+	// instead of a new manfred/<job-id> branch; PullRequest is the pull
+	// request (owner/repo#N) it was taken from, if any
+	Branch      string `yaml:"branch,omitempty"`
+	PullRequest string `yaml:"pull_request,omitempty"`
+
+	// Issue is the GitHub issue (owner/repo#N) the ticket was imported from
+	Issue string `yaml:"issue,omitempty"`
+
+	// InputHash is the job's input hash; DuplicateOf is the earlier job
+	// with the same project, prompt and base branch, if any
+	InputHash   string `yaml:"input_hash,omitempty"`
+	DuplicateOf string `yaml:"duplicate_of,omitempty"`
+}
+
+// CreateOptions describe a new ticket beyond its prompt.
+type CreateOptions struct {
+	CreatedBy   string // Who creates the ticket, see CurrentUser
+	Branch      string // Existing branch to work on and push to
+	PullRequest string // Pull request of Branch, as owner/repo#N
+	Issue       string // GitHub issue the ticket is imported from, as owner/repo#N
 }
 
 // New creates a new ticket with a generated ID.
-func New(project string) *Ticket {
+func New(project string, opts CreateOptions) *Ticket {
 	return &Ticket{
-		ID:        generateTicketID(),
-		Project:   project,
-		Status:    StatusPending,
-		CreatedAt: time.Now(),
-		Entries:   []Entry{},
+		ID:          generateTicketID(),
+		Project:     project,
+		Status:      StatusPending,
+		CreatedAt:   time.Now(),
+		CreatedBy:   opts.CreatedBy,
+		Entries:     []Entry{},
+		Branch:      opts.Branch,
+		PullRequest: opts.PullRequest,
+		Issue:       opts.Issue,
+	}
+}
+
+// IssuePrompt returns the prompt of a ticket imported from a GitHub issue:
+// its title as a heading, its body, and a link back to the issue.
+func IssuePrompt(title, body, url string) string {
+	prompt := "# " + strings.TrimSpace(title) + "\n\n"
+	if body = strings.TrimSpace(body); body != "" {
+		prompt += body + "\n\n"
+	}
+	return prompt + "Imported from " + url
+}
+
+// Creator returns who created the ticket, "user" for tickets that predate
+// created_by.
+func (t *Ticket) Creator() string {
+	if t.CreatedBy == "" {
+		return "user"
 	}
+	return t.CreatedBy
+}
+
+// CurrentUser returns the name of the OS user running MANFRED, as tickets
+// created from the CLI are attributed to. Under sudo it is the user who ran
+// sudo, so tickets created through a shared service account keep their
+// author.
+func CurrentUser() string {
+	if name := os.Getenv("SUDO_USER"); name != "" {
+		return name
+	}
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "user"
 }
 
 // AddEntry adds an entry to the ticket.
@@ -80,6 +154,75 @@ func (t *Ticket) PromptContent() string {
 	return ""
 }
 
+// Reopen moves an errored, completed or cancelled ticket back to pending on
+// behalf of by, so it is processed again. With clearJob, the ticket forgets
+// its last job, as if it had never run.
+func (t *Ticket) Reopen(by string, clearJob bool) error {
+	switch t.Status {
+	case StatusError, StatusCompleted, StatusCancelled:
+	default:
+		return fmt.Errorf("ticket %s is %s; only errored, completed and cancelled tickets can be reopened", t.ID, t.Status)
+	}
+
+	comment := fmt.Sprintf("Reopened by %s (was %s)", by, t.Status)
+	if clearJob && t.JobID != "" {
+		comment += fmt.Sprintf("; cleared job %s", t.JobID)
+	}
+	if clearJob {
+		t.JobID = ""
+		t.InputHash = ""
+		t.DuplicateOf = ""
+	}
+	t.Status = StatusPending
+	t.AddEntry(EntryTypeComment, AuthorManfred, comment)
+	return nil
+}
+
+// Cancel marks a pending ticket as abandoned on behalf of by, with an
+// optional reason.
+func (t *Ticket) Cancel(by, reason string) error {
+	if t.Status != StatusPending {
+		return fmt.Errorf("ticket %s is %s; only pending tickets can be cancelled", t.ID, t.Status)
+	}
+
+	comment := fmt.Sprintf("Cancelled by %s", by)
+	if reason != "" {
+		comment += ": " + reason
+	}
+	t.Status = StatusCancelled
+	t.AddEntry(EntryTypeComment, AuthorManfred, comment)
+	return nil
+}
+
+// FollowUps returns the follow-up entries of the ticket, oldest first.
+func (t *Ticket) FollowUps() []Entry {
+	var followUps []Entry
+	for _, e := range t.Entries {
+		if e.Type == EntryTypeFollowUp {
+			followUps = append(followUps, e)
+		}
+	}
+	return followUps
+}
+
+// JobPrompt returns the prompt a job for the ticket runs: the first prompt
+// entry, followed by the follow-ups refining it.
+func (t *Ticket) JobPrompt() string {
+	prompt := t.PromptContent()
+	followUps := t.FollowUps()
+	if prompt == "" || len(followUps) == 0 {
+		return prompt
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(prompt, "\n"))
+	b.WriteString("\n\n## Follow-ups\n\nThe task was refined after it was written. Where a follow-up contradicts the\ntask above, the follow-up wins.\n")
+	for _, e := range followUps {
+		fmt.Fprintf(&b, "\n### %s, %s\n\n%s\n", e.Author, e.Timestamp.Format("2006-01-02 15:04"), strings.TrimSpace(e.Content))
+	}
+	return b.String()
+}
+
 // PromptPreview returns a truncated preview of the prompt.
 func (t *Ticket) PromptPreview(maxLen int) string {
 	content := t.PromptContent()
@@ -98,6 +241,46 @@ func (t *Ticket) PromptPreview(maxLen int) string {
 	return content
 }
 
+// ParsePullRequestRef parses a pull request reference: owner/repo#N, or #N
+// or N for a pull request of defaultRepo (owner/repo).
+func ParsePullRequestRef(ref, defaultRepo string) (owner, repo string, number int, err error) {
+	owner, repo, number, ok := parseRef(ref, defaultRepo)
+	if !ok {
+		return "", "", 0, fmt.Errorf("invalid pull request %q: want owner/repo#N or N", ref)
+	}
+	return owner, repo, number, nil
+}
+
+// ParseIssueRef parses the owner/repo#N of the issue a ticket was imported
+// from.
+func ParseIssueRef(ref string) (owner, repo string, number int, err error) {
+	owner, repo, number, ok := parseRef(ref, "")
+	if !ok {
+		return "", "", 0, fmt.Errorf("invalid issue %q: want owner/repo#N", ref)
+	}
+	return owner, repo, number, nil
+}
+
+// parseRef parses owner/repo#N, or N in defaultRepo.
+func parseRef(ref, defaultRepo string) (owner, repo string, number int, ok bool) {
+	repoPart, numberPart, found := strings.Cut(ref, "#")
+	if !found {
+		repoPart, numberPart = "", ref
+	}
+	if repoPart == "" {
+		repoPart = defaultRepo
+	}
+	number, err := strconv.Atoi(numberPart)
+	if err != nil || number <= 0 {
+		return "", "", 0, false
+	}
+	owner, repo, found = strings.Cut(repoPart, "/")
+	if !found || owner == "" || repo == "" || strings.Contains(repo, "/") {
+		return "", "", 0, false
+	}
+	return owner, repo, number, true
+}
+
 // generateTicketID creates a unique ticket identifier.
 func generateTicketID() string {
 	// Format: ticket_YYYYMMDD_HHMMSS_xxxx
@@ -0,0 +1,98 @@
+package ticket
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePullRequestRef(t *testing.T) {
+	tests := []struct {
+		ref       string
+		wantOwner string
+		wantRepo  string
+		wantNum   int
+		wantErr   bool
+	}{
+		{ref: "acme/api#12", wantOwner: "acme", wantRepo: "api", wantNum: 12},
+		{ref: "#7", wantOwner: "acme", wantRepo: "widgets", wantNum: 7},
+		{ref: "7", wantOwner: "acme", wantRepo: "widgets", wantNum: 7},
+		{ref: "acme/api#x", wantErr: true},
+		{ref: "api#3", wantErr: true},
+		{ref: "0", wantErr: true},
+	}
+	for _, tt := range tests {
+		owner, repo, number, err := ParsePullRequestRef(tt.ref, "acme/widgets")
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParsePullRequestRef(%q) succeeded, want an error", tt.ref)
+			}
+			continue
+		}
+		if err != nil || owner != tt.wantOwner || repo != tt.wantRepo || number != tt.wantNum {
+			t.Errorf("ParsePullRequestRef(%q) = %s, %s, %d, %v; want %s, %s, %d",
+				tt.ref, owner, repo, number, err, tt.wantOwner, tt.wantRepo, tt.wantNum)
+		}
+	}
+}
+
+func TestJobPrompt(t *testing.T) {
+	tk := New("widgets", CreateOptions{CreatedBy: "alice"})
+	tk.AddEntry(EntryTypePrompt, "alice", "Fix the login form\n")
+	if got := tk.JobPrompt(); got != "Fix the login form\n" {
+		t.Errorf("JobPrompt() without follow-ups = %q, want the prompt", got)
+	}
+
+	tk.AddEntry(EntryTypeComment, AuthorManfred, "Job completed: job_1")
+	tk.AddEntry(EntryTypeFollowUp, "bob", "Also validate the email field")
+	tk.AddEntry(EntryTypeComment, "bob", "Looks good otherwise")
+
+	got := tk.JobPrompt()
+	if !strings.HasPrefix(got, "Fix the login form\n\n## Follow-ups") || !strings.Contains(got, "Also validate the email field") {
+		t.Errorf("JobPrompt() = %q, want the prompt followed by the follow-up", got)
+	}
+	if strings.Contains(got, "job_1") || strings.Contains(got, "Looks good") {
+		t.Errorf("JobPrompt() = %q, includes comments", got)
+	}
+	if strings.Count(got, "### bob") != 1 {
+		t.Errorf("JobPrompt() = %q, want one follow-up by bob", got)
+	}
+}
+
+func TestReopenAndCancel(t *testing.T) {
+	tk := &Ticket{ID: "ticket_1", Status: StatusError, JobID: "job_1", InputHash: "abc"}
+
+	if err := tk.Cancel("alice", ""); err == nil {
+		t.Error("Cancel() of an errored ticket succeeded, want error")
+	}
+	if err := tk.Reopen("alice", true); err != nil {
+		t.Fatalf("Reopen() error = %v", err)
+	}
+	if tk.Status != StatusPending || tk.JobID != "" || tk.InputHash != "" {
+		t.Errorf("after Reopen(clearJob) status = %s, job = %q, hash = %q", tk.Status, tk.JobID, tk.InputHash)
+	}
+	if err := tk.Reopen("alice", false); err == nil {
+		t.Error("Reopen() of a pending ticket succeeded, want error")
+	}
+
+	if err := tk.Cancel("alice", "superseded"); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	last := tk.Entries[len(tk.Entries)-1]
+	if tk.Status != StatusCancelled || last.Content != "Cancelled by alice: superseded" {
+		t.Errorf("after Cancel() status = %s, last entry = %q", tk.Status, last.Content)
+	}
+}
+
+func TestIssuePrompt(t *testing.T) {
+	got := IssuePrompt(" Fix login ", "The form rejects valid emails.\n", "https://github.com/acme/widgets/issues/4")
+	want := "# Fix login\n\nThe form rejects valid emails.\n\nImported from https://github.com/acme/widgets/issues/4"
+	if got != want {
+		t.Errorf("IssuePrompt() = %q, want %q", got, want)
+	}
+
+	got = IssuePrompt("Fix login", "", "https://github.com/acme/widgets/issues/4")
+	want = "# Fix login\n\nImported from https://github.com/acme/widgets/issues/4"
+	if got != want {
+		t.Errorf("IssuePrompt() without body = %q, want %q", got, want)
+	}
+}
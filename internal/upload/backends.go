@@ -0,0 +1,106 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mpm/manfred/internal/github"
+)
+
+// maxPresignExpiry is the longest lifetime of an S3 presigned URL.
+const maxPresignExpiry = 7 * 24 * time.Hour
+
+// presignExpiry returns the lifetime of presigned links: the retention,
+// capped at what S3 allows.
+func presignExpiry(retention time.Duration) time.Duration {
+	if retention <= 0 || retention > maxPresignExpiry {
+		return maxPresignExpiry
+	}
+	return retention
+}
+
+// gistBackend stores uploads as secret gists.
+type gistBackend struct {
+	client *github.Client
+}
+
+func (b *gistBackend) Put(ctx context.Context, key, description string, data []byte) (string, string, error) {
+	gist, err := b.client.CreateGist(ctx, description, key, string(data))
+	if err != nil {
+		return "", "", err
+	}
+	return gist.ID, gist.HTMLURL, nil
+}
+
+func (b *gistBackend) Delete(ctx context.Context, ref string) error {
+	return b.client.DeleteGist(ctx, ref)
+}
+
+// s3Backend stores uploads in S3 with the aws CLI. Links are baseURL/key
+// when the bucket is served publicly, e.g. through a CDN, or else presigned
+// URLs, which expire after at most 7 days.
+type s3Backend struct {
+	prefix  string // s3://bucket/prefix
+	baseURL string
+	expiry  time.Duration
+}
+
+func (b *s3Backend) Put(ctx context.Context, key, description string, data []byte) (string, string, error) {
+	dest := b.prefix + "/" + key
+	cmd := exec.CommandContext(ctx, "aws", "s3", "cp", "--only-show-errors", "-", dest, "--content-type", "text/plain; charset=utf-8")
+	cmd.Stdin = bytes.NewReader(data)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", "", fmt.Errorf("aws s3 cp: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	if b.baseURL != "" {
+		return dest, strings.TrimRight(b.baseURL, "/") + "/" + key, nil
+	}
+	cmd = exec.CommandContext(ctx, "aws", "s3", "presign", dest, "--expires-in", fmt.Sprint(int(b.expiry.Seconds())))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return dest, "", fmt.Errorf("aws s3 presign: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return dest, strings.TrimSpace(string(out)), nil
+}
+
+func (b *s3Backend) Delete(ctx context.Context, ref string) error {
+	cmd := exec.CommandContext(ctx, "aws", "s3", "rm", "--only-show-errors", ref)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("aws s3 rm: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// dirBackend stores uploads in a directory served at baseURL by a web
+// server.
+type dirBackend struct {
+	dir     string
+	baseURL string
+}
+
+func (b *dirBackend) Put(ctx context.Context, key, description string, data []byte) (string, string, error) {
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	path := filepath.Join(b.dir, key)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", "", err
+	}
+	return path, strings.TrimRight(b.baseURL, "/") + "/" + key, nil
+}
+
+func (b *dirBackend) Delete(ctx context.Context, ref string) error {
+	if err := os.Remove(ref); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+// Package upload stores large outputs, such as job logs, full diffs and long
+// status comments, as secret gists, in S3 or in a served directory, so
+// comments and PRs can link to them instead of inlining them. Uploads are
+// recorded in an index and deleted once they are older than the retention.
+package upload
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+)
+
+// Upload is an uploaded output.
+type Upload struct {
+	Ref     string    `json:"ref"` // Backend reference: gist ID, S3 URL or file path
+	URL     string    `json:"url"` // Link for comments and PRs
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+}
+
+// Backend stores uploads.
+type Backend interface {
+	// Put stores data under key and returns its reference and link.
+	Put(ctx context.Context, key, description string, data []byte) (ref, url string, err error)
+	// Delete removes the upload with the reference ref.
+	Delete(ctx context.Context, ref string) error
+}
+
+// Uploader uploads outputs to a backend and keeps the index of uploads.
+type Uploader struct {
+	backend   Backend
+	index     string
+	threshold int
+	retention time.Duration
+
+	mu sync.Mutex
+}
+
+// New returns the uploader for uploads.target, or nil if uploads are
+// disabled. gh is used for gists. The index is kept in the data directory.
+func New(cfg *config.Config, gh *github.Client) (*Uploader, error) {
+	u := cfg.Uploads
+	var backend Backend
+	switch {
+	case u.Target == "":
+		return nil, nil
+	case u.Target == config.UploadsGist:
+		if gh == nil {
+			return nil, errors.New("uploads.target gist needs GitHub credentials")
+		}
+		backend = &gistBackend{client: gh}
+	case strings.HasPrefix(u.Target, "s3://"):
+		backend = &s3Backend{prefix: strings.TrimRight(u.Target, "/"), baseURL: u.BaseURL, expiry: presignExpiry(u.Retention)}
+	default:
+		backend = &dirBackend{dir: u.Target, baseURL: u.BaseURL}
+	}
+	return NewWithBackend(backend, filepath.Join(cfg.DataDir, "uploads.json"), u.Threshold, u.Retention), nil
+}
+
+// NewWithBackend returns an uploader storing uploads in backend and the
+// index at index. Outputs over threshold bytes are linked instead of
+// inlined; uploads older than retention are pruned, none if zero.
+func NewWithBackend(backend Backend, index string, threshold int, retention time.Duration) *Uploader {
+	return &Uploader{backend: backend, index: index, threshold: threshold, retention: retention}
+}
+
+// Threshold returns the size in bytes over which outputs are uploaded
+// instead of inlined.
+func (u *Uploader) Threshold() int {
+	return u.threshold
+}
+
+// Upload stores data under name, e.g. job.log, and records it in the index.
+// description says what it is, e.g. for gists.
+func (u *Uploader) Upload(ctx context.Context, name, description string, data []byte) (*Upload, error) {
+	key, err := uploadKey(name)
+	if err != nil {
+		return nil, err
+	}
+	ref, url, err := u.backend.Put(ctx, key, description, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+	up := Upload{Ref: ref, URL: url, Name: name, Created: time.Now().UTC()}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	uploads, err := u.readIndex()
+	if err == nil {
+		err = u.writeIndex(append(uploads, up))
+	}
+	if err != nil {
+		return &up, fmt.Errorf("uploaded %s, but failed to record it: %w", name, err)
+	}
+	return &up, nil
+}
+
+// UploadLink uploads data like Upload and returns its link. It makes the
+// Uploader a github.Uploader.
+func (u *Uploader) UploadLink(ctx context.Context, name, description string, data []byte) (string, error) {
+	up, err := u.Upload(ctx, name, description, data)
+	if up == nil {
+		return "", err
+	}
+	return up.URL, err
+}
+
+// List returns the recorded uploads, oldest first.
+func (u *Uploader) List() ([]Upload, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.readIndex()
+}
+
+// Prune deletes the uploads older than the retention and returns them.
+// Uploads that cannot be deleted stay in the index and are retried on the
+// next prune. With dryRun, nothing is deleted.
+func (u *Uploader) Prune(ctx context.Context, dryRun bool) ([]Upload, []error) {
+	if u.retention <= 0 {
+		return nil, nil
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	uploads, err := u.readIndex()
+	if err != nil {
+		return nil, []error{err}
+	}
+	cutoff := time.Now().Add(-u.retention)
+
+	var kept, removed []Upload
+	var errs []error
+	for _, up := range uploads {
+		if up.Created.After(cutoff) {
+			kept = append(kept, up)
+			continue
+		}
+		if !dryRun {
+			if err := u.backend.Delete(ctx, up.Ref); err != nil {
+				errs = append(errs, fmt.Errorf("failed to delete upload %s: %w", up.Ref, err))
+				kept = append(kept, up)
+				continue
+			}
+		}
+		removed = append(removed, up)
+	}
+	if !dryRun && len(removed) > 0 {
+		if err := u.writeIndex(kept); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return removed, errs
+}
+
+// Retention returns how long uploads are kept; zero keeps them.
+func (u *Uploader) Retention() time.Duration {
+	return u.retention
+}
+
+// readIndex loads the index; a missing index is empty.
+func (u *Uploader) readIndex() ([]Upload, error) {
+	data, err := os.ReadFile(u.index)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload index: %w", err)
+	}
+	var uploads []Upload
+	if err := json.Unmarshal(data, &uploads); err != nil {
+		return nil, fmt.Errorf("failed to parse upload index %s: %w", u.index, err)
+	}
+	return uploads, nil
+}
+
+// writeIndex replaces the index.
+func (u *Uploader) writeIndex(uploads []Upload) error {
+	data, err := json.MarshalIndent(uploads, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode upload index: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(u.index), 0755); err != nil {
+		return fmt.Errorf("failed to create upload index directory: %w", err)
+	}
+	tmp := u.index + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write upload index: %w", err)
+	}
+	if err := os.Rename(tmp, u.index); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write upload index: %w", err)
+	}
+	return nil
+}
+
+// unsafeKeyChars are replaced in upload names.
+var unsafeKeyChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// uploadKey returns a unique, hard to guess key for name: the date, random
+// hex and the name.
+func uploadKey(name string) (string, error) {
+	random := make([]byte, 12)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate upload key: %w", err)
+	}
+	name = unsafeKeyChars.ReplaceAllString(name, "-")
+	return fmt.Sprintf("%s-%s-%s", time.Now().UTC().Format("20060102"), hex.EncodeToString(random), name), nil
+}
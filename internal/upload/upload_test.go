@@ -0,0 +1,127 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploader_DirBackend(t *testing.T) {
+	dir := t.TempDir()
+	u := NewWithBackend(&dirBackend{dir: filepath.Join(dir, "uploads"), baseURL: "https://files.example/manfred/"}, filepath.Join(dir, "uploads.json"), 100, time.Hour)
+
+	up, err := u.Upload(context.Background(), "job 1.log", "log", []byte("output"))
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if !strings.HasPrefix(up.URL, "https://files.example/manfred/") || !strings.HasSuffix(up.URL, "-job-1.log") {
+		t.Errorf("URL = %q, want base URL and sanitized name", up.URL)
+	}
+	data, err := os.ReadFile(up.Ref)
+	if err != nil || string(data) != "output" {
+		t.Errorf("stored file = %q, %v, want %q", data, err, "output")
+	}
+
+	uploads, err := u.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(uploads) != 1 || uploads[0].Name != "job 1.log" {
+		t.Errorf("List() = %+v, want the upload", uploads)
+	}
+}
+
+func TestUploadKeyIsUnique(t *testing.T) {
+	a, _ := uploadKey("x.log")
+	b, _ := uploadKey("x.log")
+	if a == b {
+		t.Errorf("uploadKey() returned %q twice", a)
+	}
+}
+
+// fakeBackend stores uploads in memory.
+type fakeBackend struct {
+	deleted []string
+	failing map[string]bool
+}
+
+func (b *fakeBackend) Put(ctx context.Context, key, description string, data []byte) (string, string, error) {
+	return key, "https://uploads.example/" + key, nil
+}
+
+func (b *fakeBackend) Delete(ctx context.Context, ref string) error {
+	if b.failing[ref] {
+		return errors.New("delete failed")
+	}
+	b.deleted = append(b.deleted, ref)
+	return nil
+}
+
+func TestUploader_Prune(t *testing.T) {
+	dir := t.TempDir()
+	backend := &fakeBackend{failing: map[string]bool{"stuck": true}}
+	u := NewWithBackend(backend, filepath.Join(dir, "uploads.json"), 100, 24*time.Hour)
+
+	now := time.Now()
+	if err := u.writeIndex([]Upload{
+		{Ref: "old", Name: "old.log", Created: now.Add(-48 * time.Hour)},
+		{Ref: "stuck", Name: "stuck.log", Created: now.Add(-48 * time.Hour)},
+		{Ref: "new", Name: "new.log", Created: now.Add(-time.Hour)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, errs := u.Prune(context.Background(), true)
+	if len(removed) != 2 || len(backend.deleted) != 0 {
+		t.Errorf("dry run: removed = %d, deleted = %d, want 2 and 0", len(removed), len(backend.deleted))
+	}
+
+	removed, errs = u.Prune(context.Background(), false)
+	if len(removed) != 1 || removed[0].Ref != "old" {
+		t.Errorf("removed = %+v, want old", removed)
+	}
+	if len(errs) != 1 {
+		t.Errorf("errs = %v, want the failed delete", errs)
+	}
+
+	// Failed deletes stay in the index for the next prune
+	uploads, _ := u.List()
+	var refs []string
+	for _, up := range uploads {
+		refs = append(refs, up.Ref)
+	}
+	if got := strings.Join(refs, ","); got != "stuck,new" {
+		t.Errorf("index = %s, want stuck,new", got)
+	}
+}
+
+func TestUploader_NoRetention(t *testing.T) {
+	backend := &fakeBackend{}
+	u := NewWithBackend(backend, filepath.Join(t.TempDir(), "uploads.json"), 100, 0)
+	if err := u.writeIndex([]Upload{{Ref: "old", Created: time.Now().Add(-1000 * time.Hour)}}); err != nil {
+		t.Fatal(err)
+	}
+	if removed, _ := u.Prune(context.Background(), false); len(removed) != 0 {
+		t.Errorf("Prune() without retention removed %d uploads", len(removed))
+	}
+}
+
+func TestPresignExpiry(t *testing.T) {
+	tests := []struct {
+		retention time.Duration
+		want      time.Duration
+	}{
+		{0, maxPresignExpiry},
+		{24 * time.Hour, 24 * time.Hour},
+		{30 * 24 * time.Hour, maxPresignExpiry},
+	}
+	for _, tt := range tests {
+		if got := presignExpiry(tt.retention); got != tt.want {
+			t.Errorf("presignExpiry(%v) = %v, want %v", tt.retention, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/orchestrator"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// maxFailedJobs is how many failed jobs of a workflow run have their logs
+// handed to Claude.
+const maxFailedJobs = 3
+
+// handleWorkflowRun starts a revision round when a GitHub Actions workflow
+// fails on a session's pull request, and tries to auto-merge it when one
+// succeeds.
+func (r *Router) handleWorkflowRun(ctx context.Context, event *github.WebhookEvent) error {
+	ev, err := event.AsWorkflowRunEvent()
+	if err != nil {
+		return err
+	}
+	run := ev.WorkflowRun
+	owner, repo := ev.Repo.Owner.Login, ev.Repo.Name
+	if ev.Action != "completed" {
+		return nil
+	}
+	if run.Conclusion == "success" {
+		return r.checkPassed(ctx, owner, repo, run.HeadBranch)
+	}
+	if !github.FailedConclusion(run.Conclusion) {
+		return nil
+	}
+
+	sess, err := r.ciSession(ctx, owner, repo, run.HeadBranch, run.HeadSHA, run.Name)
+	if err != nil || sess == nil {
+		return err
+	}
+
+	failure, err := r.workflowFailure(ctx, owner, repo, &run)
+	if err != nil {
+		return err
+	}
+	return r.orchestrator.HandleCIFailure(ctx, sess.ID, run.HeadSHA, failure)
+}
+
+// handleCheckRun starts a revision round when a check run of another CI
+// system fails on a session's pull request, and tries to auto-merge it when
+// any check passes. Failed check runs of GitHub Actions are handled through
+// their workflow run, MANFRED's own are not CI.
+func (r *Router) handleCheckRun(ctx context.Context, event *github.WebhookEvent) error {
+	ev, err := event.AsCheckRunEvent()
+	if err != nil {
+		return err
+	}
+	run := ev.CheckRun
+	if ev.Action != "completed" || run.CheckSuite == nil {
+		return nil
+	}
+	if github.PassedConclusion(run.Conclusion) {
+		return r.checkPassed(ctx, ev.Repo.Owner.Login, ev.Repo.Name, run.CheckSuite.HeadBranch)
+	}
+	if !github.FailedConclusion(run.Conclusion) {
+		return nil
+	}
+	if (run.App != nil && run.App.Slug == github.ActionsApp) || orchestrator.IsOwnCheck(run.Name) {
+		return nil
+	}
+
+	sess, err := r.ciSession(ctx, ev.Repo.Owner.Login, ev.Repo.Name, run.CheckSuite.HeadBranch, run.HeadSHA, run.Name)
+	if err != nil || sess == nil {
+		return err
+	}
+
+	return r.orchestrator.HandleCIFailure(ctx, sess.ID, run.HeadSHA, checkRunFailure(&run))
+}
+
+// checkPassed gives the pull request of branch's session a chance to be
+// auto-merged after one of its checks passed.
+func (r *Router) checkPassed(ctx context.Context, owner, repo, branch string) error {
+	if branch == "" {
+		return nil
+	}
+	sess, err := r.sessions.GetByBranch(ctx, owner, repo, branch)
+	if err != nil || sess == nil {
+		return err
+	}
+	_, err = r.orchestrator.AutoMerge(ctx, sess.ID)
+	return err
+}
+
+// ciSession returns the session whose branch a failed CI run of check
+// belongs to, nil if fixing CI failures is off, check is ignored, or the run
+// is not for the session's current head in review.
+func (r *Router) ciSession(ctx context.Context, owner, repo, branch, sha, check string) (*session.Session, error) {
+	ci := r.config.CI
+	if !ci.FixFailures || ci.MaxFixAttempts == 0 || branch == "" {
+		return nil, nil
+	}
+	for _, name := range ci.Ignore {
+		if strings.EqualFold(name, check) {
+			return nil, nil
+		}
+	}
+
+	sess, err := r.sessions.GetByBranch(ctx, owner, repo, branch)
+	if err != nil || sess == nil {
+		return nil, err
+	}
+	if sess.Phase != session.PhaseInReview {
+		log.Printf("webhook: session %s is %s, ignoring failure of %s", sess.ID, sess.Phase, check)
+		return nil, nil
+	}
+	if sess.HeadSHA == nil || *sess.HeadSHA != sha {
+		log.Printf("webhook: session %s: ignoring failure of %s on %s, which is not the branch head", sess.ID, check, sha)
+		return nil, nil
+	}
+	return sess, nil
+}
+
+// workflowFailure describes a failed workflow run by the log tails of its
+// failed jobs.
+func (r *Router) workflowFailure(ctx context.Context, owner, repo string, run *github.WorkflowRun) (string, error) {
+	jobs, err := r.github.ListWorkflowRunJobs(ctx, owner, repo, run.ID)
+	if err != nil {
+		return "", fmt.Errorf("list jobs of workflow run %d: %w", run.ID, err)
+	}
+
+	parts := []string{fmt.Sprintf("Workflow %q failed: %s", run.Name, run.HTMLURL)}
+	failed := 0
+	for _, j := range jobs {
+		if !github.FailedConclusion(j.Conclusion) {
+			continue
+		}
+		if failed++; failed > maxFailedJobs {
+			parts = append(parts, fmt.Sprintf("Job %q failed as well; its log is left out.", j.Name))
+			continue
+		}
+
+		header := fmt.Sprintf("Job %q failed", j.Name)
+		if step := j.FailedStep(); step != "" {
+			header += fmt.Sprintf(" in step %q", step)
+		}
+		logs, err := r.github.GetWorkflowJobLogs(ctx, owner, repo, j.ID)
+		if err != nil {
+			log.Printf("webhook: failed to fetch the log of job %d: %v", j.ID, err)
+			parts = append(parts, fmt.Sprintf("%s (%s). Its log could not be fetched.", header, j.HTMLURL))
+			continue
+		}
+		tail := r.logTail(logs)
+		fence := github.CodeFence(tail)
+		parts = append(parts, fmt.Sprintf("%s. End of its log:\n\n%s\n%s\n%s", header, fence, tail, fence))
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// logTimestamp matches the timestamp GitHub Actions puts in front of every
+// log line.
+var logTimestamp = regexp.MustCompile(`(?m)^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(\.\d+)?Z `)
+
+// logTail returns the last ci.log_bytes of a job log without timestamps,
+// starting at a line.
+func (r *Router) logTail(logs string) string {
+	logs = strings.TrimSpace(logTimestamp.ReplaceAllString(logs, ""))
+	if max := r.config.CI.LogBytes; max > 0 && len(logs) > max {
+		logs = logs[len(logs)-max:]
+		if i := strings.IndexByte(logs, '\n'); i >= 0 {
+			logs = logs[i+1:]
+		}
+	}
+	return logs
+}
+
+// checkRunFailure describes a failed check run by its output.
+func checkRunFailure(run *github.CheckRun) string {
+	parts := []string{fmt.Sprintf("Check %q failed: %s", run.Name, run.HTMLURL)}
+	if out := run.Output; out != nil {
+		for _, s := range []string{out.Title, out.Summary, out.Text} {
+			if s = strings.TrimSpace(s); s != "" {
+				parts = append(parts, s)
+			}
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
@@ -0,0 +1,69 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+)
+
+func TestWorkflowFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/acme/widgets/actions/runs/5/jobs":
+			w.Write([]byte(`{"jobs":[
+				{"id":1,"name":"lint","conclusion":"success"},
+				{"id":2,"name":"test","conclusion":"failure","html_url":"https://github.com/acme/widgets/runs/2",
+				 "steps":[{"name":"Checkout","conclusion":"success"},{"name":"Run tests","conclusion":"failure"}]}
+			]}`))
+		case "/repos/acme/widgets/actions/jobs/2/logs":
+			w.Write([]byte("2026-10-16T09:00:00.1234567Z go: downloading deps\n" +
+				"2026-10-16T09:00:01.0000000Z --- FAIL: TestLogin\n" +
+				"2026-10-16T09:00:01.0000000Z FAIL\tgithub.com/acme/widgets\n" +
+				"2026-10-16T09:00:01.0000000Z ```\n"))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	r := &Router{
+		config: &config.Config{CI: config.CIConfig{LogBytes: 60}},
+		github: github.NewClient("token", github.WithBaseURL(server.URL)),
+	}
+	run := &github.WorkflowRun{ID: 5, Name: "CI", HTMLURL: "https://github.com/acme/widgets/actions/runs/5"}
+	got, err := r.workflowFailure(context.Background(), "acme", "widgets", run)
+	if err != nil {
+		t.Fatalf("workflowFailure() error = %v", err)
+	}
+	for _, want := range []string{
+		`Workflow "CI" failed: https://github.com/acme/widgets/actions/runs/5`,
+		`Job "test" failed in step "Run tests". End of its log:`,
+		// The fence in the log does not close the one around it
+		"````\n--- FAIL: TestLogin\nFAIL\tgithub.com/acme/widgets\n```\n````",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("workflowFailure() lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "lint") || strings.Contains(got, "downloading") || strings.Contains(got, "2026-10-16") {
+		t.Errorf("workflowFailure() = %q, want only the tail of the failed job without timestamps", got)
+	}
+}
+
+func TestCheckRunFailure(t *testing.T) {
+	run := &github.CheckRun{
+		Name:    "buildkite/widgets",
+		HTMLURL: "https://github.com/acme/widgets/runs/9",
+		Output:  &github.CheckRunOutput{Title: "Build failed", Summary: "1 step failed", Text: " "},
+	}
+	want := "Check \"buildkite/widgets\" failed: https://github.com/acme/widgets/runs/9\n\nBuild failed\n\n1 step failed"
+	if got := checkRunFailure(run); got != want {
+		t.Errorf("checkRunFailure() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,174 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// handleIssues starts a session when an issue is labeled with a trigger
+// label, or opened with one already applied, and a triage-only session for
+// a triage label. A trigger label wins over a triage label.
+func (r *Router) handleIssues(ctx context.Context, event *github.WebhookEvent) error {
+	ev, err := event.AsIssueEvent()
+	if err != nil {
+		return err
+	}
+
+	var labels []github.Label
+	switch ev.Action {
+	case "labeled":
+		if ev.Label != nil {
+			labels = []github.Label{*ev.Label}
+		}
+	case "opened":
+		labels = ev.Issue.Labels
+	}
+
+	var triage string
+	for _, l := range labels {
+		if r.isTriggerLabel(l.Name) {
+			return r.startSession(ctx, &ev.Repo, &ev.Issue, ev.Sender.Login, "label:"+l.Name)
+		}
+		if triage == "" && isLabel(r.config.Triggers.TriageLabels, l.Name) {
+			triage = l.Name
+		}
+	}
+	if triage == "" {
+		return nil
+	}
+	if ok, err := r.canStart(ctx, &ev.Repo, &ev.Issue, "label:"+triage); !ok {
+		return err
+	}
+	return r.orchestrator.StartTriage(ctx, ev.Repo.Owner.Login, ev.Repo.Name, ev.Issue.Number, ev.Sender.Login, "label:"+triage)
+}
+
+// handleIssueComment handles commands in issue and PR comments: approving a
+// plan, replacing it with an edited one or sending it back for revision,
+// retrying a failed session, resuming paused automation, aborting a session,
+// or asking for a plan to start a session. Any other comment on the issue of
+// a session awaiting answers answers Claude's clarifying questions.
+func (r *Router) handleIssueComment(ctx context.Context, event *github.WebhookEvent) error {
+	ev, err := event.AsIssueCommentEvent()
+	if err != nil {
+		return err
+	}
+	if ev.Action != "created" {
+		return nil
+	}
+	body := ev.Comment.Body
+	if github.IsManfredComment(body) {
+		return nil
+	}
+
+	owner, repo := ev.Repo.Owner.Login, ev.Repo.Name
+	var sess *session.Session
+	if ev.Issue.IsPullRequest() {
+		sess, err = r.sessions.GetByPR(ctx, owner, repo, ev.Issue.Number)
+	} else {
+		sess, err = r.sessions.GetByIssue(ctx, owner, repo, ev.Issue.Number)
+	}
+	if err != nil {
+		return err
+	}
+
+	if sess != nil {
+		if sess.Phase == session.PhaseAwaitingApproval {
+			// An edit without a plan is ignored rather than mistaken for
+			// an approval or a revision request
+			if plan, ok := github.ParsePlanEdit(body); ok {
+				if plan == "" {
+					return nil
+				}
+				return r.orchestrator.EditPlan(ctx, sess.ID, ev.Sender.Login, plan)
+			}
+			if feedback, ok := github.ParsePlanRevision(body); ok {
+				return r.orchestrator.RevisePlan(ctx, sess.ID, ev.Sender.Login, feedback)
+			}
+		}
+		switch {
+		case sess.Phase.CanTransitionTo(session.PhaseAborted) && github.IsAbortRequest(body):
+			return r.orchestrator.Abort(ctx, sess.ID, ev.Sender.Login)
+		case sess.Phase == session.PhasePaused && github.IsResumeRequest(body):
+			return r.orchestrator.Resume(ctx, sess.ID, ev.Sender.Login)
+		case sess.Phase == session.PhaseAwaitingApproval && github.IsApproval(body):
+			return r.orchestrator.Approve(ctx, sess.ID, ev.Sender.Login)
+		case sess.Phase == session.PhaseError && github.IsRetryRequest(body):
+			return r.orchestrator.Retry(ctx, sess.ID, ev.Sender.Login)
+		case sess.Phase == session.PhaseAwaitingAnswers && !ev.Issue.IsPullRequest():
+			return r.orchestrator.Answer(ctx, sess.ID, ev.Sender.Login, ev.Comment.ID, body)
+		case sess.Phase == session.PhaseTriaged && !ev.Issue.IsPullRequest() && github.IsPlanRequest(body):
+			return r.startSession(ctx, &ev.Repo, &ev.Issue, ev.Sender.Login, "comment:"+ev.Sender.Login)
+		}
+		return nil
+	}
+
+	if ev.Issue.IsPullRequest() || !github.IsPlanRequest(body) {
+		return nil
+	}
+	return r.startSession(ctx, &ev.Repo, &ev.Issue, ev.Sender.Login, "comment:"+ev.Sender.Login)
+}
+
+// startSession checks the repository allowlist and hands the issue to the
+// orchestrator, which authorizes the sender.
+func (r *Router) startSession(ctx context.Context, repo *github.Repo, issue *github.Issue, sender, trigger string) error {
+	if ok, err := r.canStart(ctx, repo, issue, trigger); !ok {
+		return err
+	}
+	return r.orchestrator.StartSession(ctx, repo.Owner.Login, repo.Name, issue.Number, sender, trigger)
+}
+
+// canStart reports whether trigger may start a session on the issue: the
+// repository is allowed and has a project, and the issue is open.
+func (r *Router) canStart(ctx context.Context, repo *github.Repo, issue *github.Issue, trigger string) (bool, error) {
+	owner, name := repo.Owner.Login, repo.Name
+
+	if !r.repoAllowed(owner, name) {
+		log.Printf("webhook: %s/%s is not in allowed_repos, ignoring %s", owner, name, trigger)
+		return false, nil
+	}
+	if issue.State != "" && issue.State != "open" {
+		return false, nil
+	}
+	if _, _, err := r.config.FindProjectByRepo(owner, name); err != nil {
+		return false, fmt.Errorf("cannot start session for %s/%s#%d: %w", owner, name, issue.Number, err)
+	}
+	return true, nil
+}
+
+func (r *Router) isTriggerLabel(name string) bool {
+	return isLabel(r.config.Triggers.Labels, name)
+}
+
+// isLabel reports whether name is one of labels, ignoring case.
+func isLabel(labels []string, name string) bool {
+	for _, l := range labels {
+		if strings.EqualFold(l, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// repoAllowed checks owner/name against triggers.allowed_repos. Entries may
+// use "owner/*" to allow every repository of an owner.
+func (r *Router) repoAllowed(owner, name string) bool {
+	allowed := r.config.Triggers.AllowedRepos
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		o, n, ok := strings.Cut(a, "/")
+		if !ok || !strings.EqualFold(o, owner) {
+			continue
+		}
+		if n == "*" || strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/mpm/manfred/internal/config"
+)
+
+func TestRouterRepoAllowed(t *testing.T) {
+	tests := []struct {
+		allowed []string
+		owner   string
+		repo    string
+		want    bool
+	}{
+		{nil, "owner", "repo", true},
+		{[]string{"owner/repo"}, "owner", "repo", true},
+		{[]string{"owner/repo"}, "Owner", "Repo", true},
+		{[]string{"owner/repo"}, "owner", "other", false},
+		{[]string{"owner/*"}, "owner", "other", true},
+		{[]string{"owner/*"}, "someone", "repo", false},
+		{[]string{"invalid"}, "owner", "repo", false},
+	}
+
+	for _, tt := range tests {
+		r := &Router{config: &config.Config{Triggers: config.TriggersConfig{AllowedRepos: tt.allowed}}}
+		if got := r.repoAllowed(tt.owner, tt.repo); got != tt.want {
+			t.Errorf("repoAllowed(%v, %s/%s) = %v, want %v", tt.allowed, tt.owner, tt.repo, got, tt.want)
+		}
+	}
+}
+
+func TestRouterIsTriggerLabel(t *testing.T) {
+	r := &Router{config: &config.Config{Triggers: config.TriggersConfig{Labels: []string{"manfred"}}}}
+	if !r.isTriggerLabel("Manfred") {
+		t.Error("isTriggerLabel(Manfred) = false, want true")
+	}
+	if r.isTriggerLabel("bug") {
+		t.Error("isTriggerLabel(bug) = true, want false")
+	}
+}
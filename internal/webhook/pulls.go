@@ -0,0 +1,37 @@
+package webhook
+
+import (
+	"context"
+	"log"
+
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// handlePullRequest completes a session when its PR is merged, also while
+// it is being revised.
+func (r *Router) handlePullRequest(ctx context.Context, event *github.WebhookEvent) error {
+	ev, err := event.AsPullRequestEvent()
+	if err != nil {
+		return err
+	}
+	if ev.Action != "closed" || !ev.PullRequest.Merged {
+		return nil
+	}
+
+	sess, err := r.sessions.GetByPR(ctx, ev.Repo.Owner.Login, ev.Repo.Name, ev.PullRequest.Number)
+	if err != nil {
+		return err
+	}
+	if sess == nil {
+		return nil
+	}
+	switch sess.Phase {
+	case session.PhaseInReview, session.PhasePaused, session.PhaseRevising:
+	default:
+		log.Printf("webhook: session %s is %s, ignoring merge", sess.ID, sess.Phase)
+		return nil
+	}
+
+	return r.orchestrator.HandleMerge(ctx, sess.ID, &ev.PullRequest)
+}
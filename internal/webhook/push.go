@@ -0,0 +1,30 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/mpm/manfred/internal/github"
+)
+
+// handlePush lets the orchestrator check pushes to session branches for
+// manual changes.
+func (r *Router) handlePush(ctx context.Context, event *github.WebhookEvent) error {
+	ev, err := event.AsPushEvent()
+	if err != nil {
+		return err
+	}
+	branch := ev.Branch()
+	if branch == "" || ev.Deleted {
+		return nil
+	}
+
+	sess, err := r.sessions.GetByBranch(ctx, ev.Repo.Owner.Login, ev.Repo.Name, branch)
+	if err != nil {
+		return err
+	}
+	if sess == nil {
+		return nil
+	}
+
+	return r.orchestrator.HandlePush(ctx, sess.ID, ev.After, ev.Sender.Login)
+}
@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// handlePullRequestReview starts a revision round when a review requesting
+// changes or commenting is submitted on a session's pull request. An
+// approving review only gives the pull request a chance to be auto-merged.
+func (r *Router) handlePullRequestReview(ctx context.Context, event *github.WebhookEvent) error {
+	ev, err := event.AsPullRequestReviewEvent()
+	if err != nil {
+		return err
+	}
+	if ev.Action != "submitted" {
+		return nil
+	}
+
+	owner, repo := ev.Repo.Owner.Login, ev.Repo.Name
+	sess, err := r.sessions.GetByPR(ctx, owner, repo, ev.PullRequest.Number)
+	if err != nil {
+		return err
+	}
+	if sess == nil {
+		return nil
+	}
+	if sess.Phase != session.PhaseInReview {
+		log.Printf("webhook: session %s is %s, ignoring review", sess.ID, sess.Phase)
+		return nil
+	}
+
+	switch strings.ToLower(ev.Review.State) {
+	case "approved":
+		_, err := r.orchestrator.AutoMerge(ctx, sess.ID)
+		return err
+	case "changes_requested", "commented":
+	default:
+		return nil
+	}
+
+	feedback, err := r.reviewFeedback(ctx, owner, repo, ev)
+	if err != nil {
+		return err
+	}
+	if feedback == "" {
+		return nil
+	}
+
+	return r.orchestrator.HandleReviewFeedback(ctx, sess.ID, ev.Review.User.Login, feedback)
+}
+
+// reviewFeedback combines a review's body and its line comments into a
+// single feedback text.
+func (r *Router) reviewFeedback(ctx context.Context, owner, repo string, ev *github.PullRequestReviewEvent) (string, error) {
+	var parts []string
+
+	if !github.IsManfredComment(ev.Review.Body) {
+		if body := github.ExtractFeedback(ev.Review.Body); body != "" {
+			parts = append(parts, body)
+		}
+	}
+
+	comments, err := r.github.GetReviewComments(ctx, owner, repo, ev.PullRequest.Number, ev.Review.ID)
+	if err != nil {
+		return "", fmt.Errorf("get review comments: %w", err)
+	}
+	for _, c := range comments {
+		body := github.ExtractFeedback(c.Body)
+		if body == "" {
+			continue
+		}
+		location := c.Path
+		if c.Line != nil {
+			location = fmt.Sprintf("%s:%d", c.Path, *c.Line)
+		}
+		parts = append(parts, fmt.Sprintf("On %s:\n%s", location, body))
+	}
+
+	return strings.Join(parts, "\n\n"), nil
+}
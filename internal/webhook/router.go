@@ -0,0 +1,71 @@
+// Package webhook routes GitHub webhook events to session workflows.
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/mpm/manfred/internal/audit"
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/orchestrator"
+	"github.com/mpm/manfred/internal/session"
+)
+
+// Router dispatches webhook events to the orchestrator.
+type Router struct {
+	config       *config.Config
+	sessions     session.Store
+	github       *github.Client
+	orchestrator *orchestrator.Orchestrator
+}
+
+// NewRouter creates a new event router.
+func NewRouter(cfg *config.Config, sessions session.Store, gh *github.Client, orch *orchestrator.Orchestrator) *Router {
+	return &Router{
+		config:       cfg,
+		sessions:     sessions,
+		github:       gh,
+		orchestrator: orch,
+	}
+}
+
+// Events lists the GitHub events HandleEvent acts on, which a repository
+// webhook has to deliver.
+var Events = []string{"issues", "issue_comment", "push", "pull_request", "pull_request_review", "check_run", "workflow_run"}
+
+// HandleEvent dispatches a webhook event. Events that do not concern a
+// session are ignored. What the event causes is audited as done by its
+// sender.
+func (r *Router) HandleEvent(ctx context.Context, event *github.WebhookEvent) error {
+	var base struct {
+		Sender github.User `json:"sender"`
+	}
+	if err := json.Unmarshal(event.Payload, &base); err == nil && base.Sender.Login != "" {
+		ctx = audit.WithActor(ctx, audit.Actor{Type: audit.ActorGitHub, Name: base.Sender.Login})
+	}
+
+	switch event.Type {
+	case "issues":
+		return r.handleIssues(ctx, event)
+	case "issue_comment":
+		return r.handleIssueComment(ctx, event)
+	case "push":
+		return r.handlePush(ctx, event)
+	case "pull_request":
+		return r.handlePullRequest(ctx, event)
+	case "pull_request_review":
+		return r.handlePullRequestReview(ctx, event)
+	case "workflow_run":
+		return r.handleWorkflowRun(ctx, event)
+	case "check_run":
+		return r.handleCheckRun(ctx, event)
+	// pull_request_review_comment events are not handled separately: every
+	// line comment belongs to a review, and the review's submitted event
+	// carries all of its comments at once.
+	default:
+		log.Printf("webhook: ignoring %s event", event.Type)
+		return nil
+	}
+}
@@ -0,0 +1,341 @@
+// Package manfred is the public Go API for embedding MANFRED in other
+// programs instead of shelling out to the CLI.
+//
+// A minimal embedding runs a job for a project configured under the
+// projects directory:
+//
+//	cfg, err := manfred.LoadConfig("")
+//	if err != nil {
+//		return err
+//	}
+//	m, err := manfred.New(cfg)
+//	if err != nil {
+//		return err
+//	}
+//	j, err := m.RunJob(ctx, "my-project", "Fix the failing tests", manfred.RunOptions{})
+//
+// GitHub-driven sessions additionally need a session store and GitHub
+// credentials:
+//
+//	store, err := manfred.OpenStore(ctx, cfg.Database.Path)
+//	if err != nil {
+//		return err
+//	}
+//	defer store.Close()
+//	m, err := manfred.New(cfg, manfred.WithStore(store))
+//	sess, err := m.CreateSession(ctx, "acme", "widgets", 42, "alice")
+//
+// The types re-exported here are aliases of MANFRED's internal types; their
+// fields and methods are part of this API.
+package manfred
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mpm/manfred/internal/config"
+	"github.com/mpm/manfred/internal/github"
+	"github.com/mpm/manfred/internal/job"
+	"github.com/mpm/manfred/internal/orchestrator"
+	"github.com/mpm/manfred/internal/session"
+	"github.com/mpm/manfred/internal/store"
+	"github.com/mpm/manfred/internal/ticket"
+)
+
+// Configuration.
+type (
+	Config        = config.Config
+	ProjectConfig = config.ProjectConfig
+)
+
+// Jobs.
+type (
+	Job        = job.Job
+	JobStatus  = job.Status
+	RunOptions = job.RunOptions
+	TestResult = job.TestResult
+)
+
+// Job statuses.
+const (
+	JobPending   = job.StatusPending
+	JobRunning   = job.StatusRunning
+	JobCompleted = job.StatusCompleted
+	JobFailed    = job.StatusFailed
+)
+
+// Sessions.
+type (
+	Session       = session.Session
+	SessionEvent  = session.SessionEvent
+	SessionFilter = session.SessionFilter
+	Phase         = session.Phase
+	Store         = session.Store
+)
+
+// Session phases.
+const (
+	PhasePlanning         = session.PhasePlanning
+	PhaseAwaitingApproval = session.PhaseAwaitingApproval
+	PhaseImplementing     = session.PhaseImplementing
+	PhaseInReview         = session.PhaseInReview
+	PhaseRevising         = session.PhaseRevising
+	PhaseCompleted        = session.PhaseCompleted
+	PhaseError            = session.PhaseError
+)
+
+// Tickets.
+type (
+	Ticket       = ticket.Ticket
+	TicketStatus = ticket.Status
+)
+
+// GitHubClient is the GitHub API client used for sessions.
+type GitHubClient = github.Client
+
+// UnauthorizedError is returned when a sender may not start, approve or
+// retry a session.
+type UnauthorizedError = orchestrator.UnauthorizedError
+
+var (
+	// ErrNoStore is returned by session methods when no store was given.
+	ErrNoStore = errors.New("manfred: no session store configured")
+
+	// ErrNoGitHub is returned by session methods when no GitHub credentials
+	// are configured.
+	ErrNoGitHub = errors.New("manfred: no GitHub token or GitHub App configured")
+)
+
+// LoadConfig reads the configuration like the CLI does: from path, or from
+// config.yaml in ~/.manfred or the working directory when path is empty,
+// with MANFRED_* environment variables and defaults applied. It uses the
+// global viper instance.
+func LoadConfig(path string) (*Config, error) {
+	if err := config.ReadFile(path); err != nil {
+		return nil, err
+	}
+	return config.Load()
+}
+
+// SQLStore is a session store backed by MANFRED's SQLite database.
+type SQLStore struct {
+	*session.SQLStore
+	db *store.DB
+}
+
+// OpenStore opens (and migrates) the SQLite database at path.
+func OpenStore(ctx context.Context, path string) (*SQLStore, error) {
+	db, err := store.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	if err := db.Migrate(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate database: %w", err)
+	}
+	return &SQLStore{SQLStore: session.NewSQLStore(db), db: db}, nil
+}
+
+// Close closes the database.
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// NewGitHubClient creates a GitHub client from the configuration, using
+// GitHub App authentication when an app ID is configured and the personal
+// access token otherwise.
+func NewGitHubClient(cfg *Config) (*GitHubClient, error) {
+	return cfg.GitHubClient()
+}
+
+// Manfred runs jobs and drives GitHub sessions.
+type Manfred struct {
+	config    *Config
+	store     Store
+	github    *GitHubClient
+	logOutput io.Writer
+
+	orch *orchestrator.Orchestrator
+}
+
+// Option configures a Manfred.
+type Option func(*Manfred)
+
+// WithStore sets the session store. Without one, only RunJob is available.
+func WithStore(s Store) Option {
+	return func(m *Manfred) {
+		m.store = s
+	}
+}
+
+// WithGitHubClient sets the GitHub client instead of creating one from the
+// configuration.
+func WithGitHubClient(c *GitHubClient) Option {
+	return func(m *Manfred) {
+		m.github = c
+	}
+}
+
+// WithLogOutput sends the log of jobs started with RunJob to w instead of
+// stdout.
+func WithLogOutput(w io.Writer) Option {
+	return func(m *Manfred) {
+		m.logOutput = w
+	}
+}
+
+// New creates a Manfred from a configuration. A GitHub client is created
+// from the configuration when credentials are configured and none was given.
+func New(cfg *Config, opts ...Option) (*Manfred, error) {
+	m := &Manfred{config: cfg}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if m.github == nil && (cfg.GitHub.Token != "" || cfg.GitHub.AppID != 0) {
+		client, err := NewGitHubClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		m.github = client
+	}
+	if m.store != nil && m.github != nil {
+		m.orch = orchestrator.New(cfg, m.store, m.github)
+	}
+	return m, nil
+}
+
+// Config returns the configuration.
+func (m *Manfred) Config() *Config {
+	return m.config
+}
+
+// Store returns the session store, or nil.
+func (m *Manfred) Store() Store {
+	return m.store
+}
+
+// RunJob runs prompt as a job for a project and returns the finished job.
+// A failed job is returned with Status JobFailed and a nil error; the error
+// is only set when the job could not be started. Jobs of projects with
+// several repositories open their pull requests with the GitHub client, if
+// there is one.
+func (m *Manfred) RunJob(ctx context.Context, project, prompt string, opts RunOptions) (*Job, error) {
+	runner, err := job.NewRunner(m.config)
+	if err != nil {
+		return nil, err
+	}
+	defer runner.Close()
+
+	if m.logOutput != nil {
+		runner.SetOutput(m.logOutput)
+	}
+	if opts.PullRequests == nil && m.github != nil {
+		opts.PullRequests = m.github
+	}
+	return runner.RunWithOptions(ctx, project, prompt, opts)
+}
+
+// CreateTicket queues prompt as a pending ticket for a project, attributed
+// to createdBy: the identity the embedding program authenticated, e.g. the
+// owner of an API token. Job notifications of the ticket are routed by it
+// (notify.sinks[].created_by). An empty createdBy is the OS user running the
+// program.
+func (m *Manfred) CreateTicket(ctx context.Context, project, prompt, createdBy string) (*Ticket, error) {
+	if createdBy == "" {
+		createdBy = ticket.CurrentUser()
+	}
+	return ticket.NewFileStore(m.config.TicketsDir, project).Create(ctx, prompt, ticket.CreateOptions{CreatedBy: createdBy})
+}
+
+// requireOrchestrator returns the session orchestrator, or an error naming
+// what is missing.
+func (m *Manfred) requireOrchestrator() (*orchestrator.Orchestrator, error) {
+	switch {
+	case m.store == nil:
+		return nil, ErrNoStore
+	case m.github == nil:
+		return nil, ErrNoGitHub
+	}
+	return m.orch, nil
+}
+
+// CreateSession starts a session for a GitHub issue on behalf of sender and
+// runs the planning phase. It blocks until the plan is posted (or planning
+// fails) and returns the session. An existing session for the issue is
+// returned unchanged.
+func (m *Manfred) CreateSession(ctx context.Context, owner, repo string, issueNumber int, sender string) (*Session, error) {
+	orch, err := m.requireOrchestrator()
+	if err != nil {
+		return nil, err
+	}
+	if err := orch.StartSession(ctx, owner, repo, issueNumber, sender, "sdk"); err != nil {
+		var unauthorized *UnauthorizedError
+		if errors.As(err, &unauthorized) {
+			return nil, err
+		}
+		// Planning failures leave the session in the error phase.
+		sess, getErr := m.store.GetByIssue(ctx, owner, repo, issueNumber)
+		if getErr != nil || sess == nil {
+			return nil, err
+		}
+		return sess, err
+	}
+	return m.store.GetByIssue(ctx, owner, repo, issueNumber)
+}
+
+// Approve approves a session's plan on behalf of sender, implements it and
+// opens a pull request.
+func (m *Manfred) Approve(ctx context.Context, sessionID, sender string) error {
+	orch, err := m.requireOrchestrator()
+	if err != nil {
+		return err
+	}
+	return orch.Approve(ctx, sessionID, sender)
+}
+
+// Retry restarts planning for a session in the error phase.
+func (m *Manfred) Retry(ctx context.Context, sessionID, sender string) error {
+	orch, err := m.requireOrchestrator()
+	if err != nil {
+		return err
+	}
+	return orch.Retry(ctx, sessionID, sender)
+}
+
+// Resume re-enables automation on a session paused after a manual push.
+func (m *Manfred) Resume(ctx context.Context, sessionID, sender string) error {
+	orch, err := m.requireOrchestrator()
+	if err != nil {
+		return err
+	}
+	return orch.Resume(ctx, sessionID, sender)
+}
+
+// ReviseSession runs a revision round on a session's pull request with the
+// given feedback.
+func (m *Manfred) ReviseSession(ctx context.Context, sessionID, feedback string) error {
+	orch, err := m.requireOrchestrator()
+	if err != nil {
+		return err
+	}
+	return orch.HandleReviewFeedback(ctx, sessionID, "", feedback)
+}
+
+// Session returns a session by ID, or nil if it does not exist.
+func (m *Manfred) Session(ctx context.Context, id string) (*Session, error) {
+	if m.store == nil {
+		return nil, ErrNoStore
+	}
+	return m.store.Get(ctx, id)
+}
+
+// Sessions lists sessions matching filter.
+func (m *Manfred) Sessions(ctx context.Context, filter SessionFilter) ([]Session, error) {
+	if m.store == nil {
+		return nil, ErrNoStore
+	}
+	return m.store.List(ctx, filter)
+}
@@ -0,0 +1,56 @@
+package manfred
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNewWithoutStore(t *testing.T) {
+	cfg := &Config{}
+	cfg.GitHub.Token = "token"
+
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if m.Store() != nil {
+		t.Error("Store() != nil without WithStore")
+	}
+
+	ctx := context.Background()
+	if _, err := m.CreateSession(ctx, "acme", "widgets", 1, "alice"); !errors.Is(err, ErrNoStore) {
+		t.Errorf("CreateSession() error = %v, want ErrNoStore", err)
+	}
+	if err := m.Approve(ctx, "id", "alice"); !errors.Is(err, ErrNoStore) {
+		t.Errorf("Approve() error = %v, want ErrNoStore", err)
+	}
+	if _, err := m.Sessions(ctx, SessionFilter{}); !errors.Is(err, ErrNoStore) {
+		t.Errorf("Sessions() error = %v, want ErrNoStore", err)
+	}
+}
+
+func TestNewWithoutGitHub(t *testing.T) {
+	var store Store = &SQLStore{}
+	m, err := New(&Config{}, WithStore(store))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := m.Retry(context.Background(), "id", "alice"); !errors.Is(err, ErrNoGitHub) {
+		t.Errorf("Retry() error = %v, want ErrNoGitHub", err)
+	}
+}
+
+func TestNewGitHubClientAppConfig(t *testing.T) {
+	cfg := &Config{}
+	cfg.GitHub.AppID = 1
+
+	_, err := NewGitHubClient(cfg)
+	if err == nil || !strings.Contains(err.Error(), "installation_id") {
+		t.Errorf("NewGitHubClient() error = %v, want missing installation_id", err)
+	}
+	if _, err := New(cfg); err == nil {
+		t.Error("New() error = nil, want invalid GitHub App config")
+	}
+}